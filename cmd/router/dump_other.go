@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import (
+	"log/slog"
+
+	"v2Routing/routing"
+)
+
+// dumpOnSignal is a no-op where SIGUSR1 does not exist
+func dumpOnSignal(router *routing.OnChainV2Router, dir string, logger *slog.Logger) {}
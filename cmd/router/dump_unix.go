@@ -0,0 +1,28 @@
+//go:build unix
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"v2Routing/routing"
+)
+
+// dumpOnSignal writes a debug dump of router to dir every time the process receives SIGUSR1
+func dumpOnSignal(router *routing.OnChainV2Router, dir string, logger *slog.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			path, err := router.WriteDebugDump(dir)
+			if err != nil {
+				logger.Error("writing debug dump failed", "error", err)
+				continue
+			}
+			logger.Info("wrote debug dump", "path", path)
+		}
+	}()
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+
+	"v2Routing/routing"
+)
+
+func main() {
+	options := &globalOptions{}
+	if err := newRootCommand(options).Execute(); err != nil {
+		// with --json errors are output like results, so scripts read a single stream
+		if options.json {
+			writeJSON(os.Stdout, routing.NewErrorResponse(err))
+		} else {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// globalOptions are the flags every subcommand takes, overriding the settings file and the
+// environment
+type globalOptions struct {
+	config       string
+	chain        string
+	rpc          []string
+	slippageBips int64
+	logLevel     string
+	logFormat    string
+	// set by --json on the commands printing results
+	json bool
+}
+
+func newRootCommand(options *globalOptions) *cobra.Command {
+	root := &cobra.Command{
+		Use:   "router",
+		Short: "Quote and serve Uniswap V2 routes",
+		// usage is for mistyped flags, not for a node that is down
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&options.config, "config", os.Getenv("ROUTER_CONFIG"), "YAML settings file, see router.example.yaml (ROUTER_CONFIG)")
+	flags.StringVar(&options.chain, "chain", "", "chain to route on by name or ID (ROUTER_CHAIN)")
+	flags.StringSliceVar(&options.rpc, "rpc", nil, "RPC endpoints in order of preference, repeated or separated by commas (ROUTER_RPC_URLS)")
+	flags.Int64Var(&options.slippageBips, "slippage-bips", 0, "slippage tolerance of quotes in basis points (ROUTER_SLIPPAGE_BIPS)")
+	flags.StringVar(&options.logLevel, "log-level", "", "debug, info, warn or error, logs go to stderr (ROUTER_LOG_LEVEL)")
+	flags.StringVar(&options.logFormat, "log-format", "", "text or json (ROUTER_LOG_FORMAT)")
+	root.AddCommand(
+		newQuoteCommand(options),
+		newPoolsCommand(options),
+		newServeCommand(options),
+		newDoctorCommand(options),
+		newDecodeCommand(),
+		newRegressCommand(),
+		newDashboardCommand(),
+	)
+	return root
+}
+
+// loadSettings reads the settings the way routing.LoadSettings does, with the flags set on cmd in
+// place of their environment variables
+func (o *globalOptions) loadSettings(cmd *cobra.Command) (*routing.Settings, error) {
+	overrides := map[string]string{}
+	if o.chain != "" {
+		overrides["ROUTER_CHAIN"] = o.chain
+	}
+	if len(o.rpc) > 0 {
+		overrides["ROUTER_RPC_URLS"] = strings.Join(o.rpc, ",")
+	}
+	if cmd.Flags().Changed("slippage-bips") {
+		overrides["ROUTER_SLIPPAGE_BIPS"] = strconv.FormatInt(o.slippageBips, 10)
+	}
+	if o.logLevel != "" {
+		overrides["ROUTER_LOG_LEVEL"] = o.logLevel
+	}
+	if o.logFormat != "" {
+		overrides["ROUTER_LOG_FORMAT"] = o.logFormat
+	}
+	return routing.LoadSettings(o.config, func(name string) string {
+		if value, ok := overrides[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	})
+}
+
+// app is the router wired up from the settings, shared by the subcommands that talk to a node
+type app struct {
+	chain                 routing.Chain
+	rpcClient             *ethclient.Client
+	rpcEndpoints          *routing.FailoverTransport
+	registry              *routing.PoolRegistry
+	config                routing.V2RouterConfig
+	exchangeRateProvider  routing.ExchangeRateProvider
+	topTokensProvider     routing.TopTokensProvider
+	tokenDecimalsProvider routing.TokenDecimalsProvider
+	poolsProvider         routing.PoolsProvider
+	// nil on chains without ENS
+	ens    *routing.ENSResolver
+	logger *slog.Logger
+}
+
+func (o *globalOptions) connect(cmd *cobra.Command) (*app, error) {
+	settings, err := o.loadSettings(cmd)
+	if err != nil {
+		return nil, err
+	}
+	chain := settings.ChainConfig()
+	logger := settings.Logger(os.Stderr)
+	rpcClient, rpcEndpoints, err := routing.DialFailoverEthClient(routing.FailoverConfig{URLs: settings.RPCURLs(), LoadBalance: settings.RPC.LoadBalance, Timeout: settings.RPC.Timeout, Logger: logger})
+	if err != nil {
+		return nil, err
+	}
+	go rpcEndpoints.Run(context.Background())
+	// every on-chain read is tried again with backoff when the node is rate limiting or unreachable
+	retry := settings.RetryPolicy()
+	retry.Logger = logger
+	// Uniswap pair addresses are computed locally, Sushiswap's are looked up on its factory
+	pairProvider := routing.NewCreate2TradingPairProvider(chain.V2Factory, chain.V2InitCodeHash)
+	poolReservesProvider := routing.NewSingleflightPoolReservesProvider(routing.NewRetryingPoolReservesProvider(routing.NewOnChainPoolReservesProvider(rpcClient), retry))
+	var tokenDecimalsProvider routing.TokenDecimalsProvider = routing.NewRetryingTokenDecimalsProvider(routing.NewOnChainTokenDecimalsProvider(rpcClient), retry)
+	// pools, decimals and pair addresses read from chain survive restarts and are refreshed daily
+	registry := openRegistry(chain, settings.Cache.RegistryTTL, logger)
+	if registry != nil {
+		tokenDecimalsProvider = registry.TokenDecimalsProvider(tokenDecimalsProvider)
+	}
+	adapters := []routing.DEXAdapter{routing.NewV2ForkAdapter("uniswap-v2", chain.V2Factory, pairProvider, 30)}
+	if chain.SushiswapFactory != (common.Address{}) {
+		onChainSushiswapPairs, err := routing.NewOnChainTradingPairProvider(chain.SushiswapFactory, rpcClient)
+		if err != nil {
+			return nil, err
+		}
+		var sushiswapPairProvider routing.TradingPairProvider = routing.NewRetryingTradingPairProvider(onChainSushiswapPairs, retry)
+		if registry != nil {
+			sushiswapPairProvider = registry.TradingPairProvider(sushiswapPairProvider)
+		}
+		adapters = append(adapters, routing.NewV2ForkAdapter("sushiswap", chain.SushiswapFactory, sushiswapPairProvider, 30))
+	}
+	// decimals never change, so every token is read at most once per process
+	tokenDecimalsProvider = routing.NewCachedTokenDecimalsProvider(tokenDecimalsProvider, settings.Cache.DecimalsSize)
+	pairTokensProvider := routing.NewRetryingPairTokensProvider(routing.NewOnChainPairTokensProvider(rpcClient), retry)
+	topTokensProvider := &routing.StaticTopTokensProvider{Tokens: chain.BaseTokens}
+	onChainPools := routing.NewOnChainPoolsProvider(pairProvider, topTokensProvider, poolReservesProvider, tokenDecimalsProvider, settings.MinLiquidityUSD)
+	onChainPools.SetChain(chain)
+	var poolsProvider routing.PoolsProvider = onChainPools
+	if registry != nil {
+		poolsProvider = registry.PoolsProvider(poolsProvider)
+	}
+	var ens *routing.ENSResolver
+	if chain.ENSRegistry != (common.Address{}) {
+		ens = routing.NewENSResolver(rpcClient, chain.ENSRegistry)
+	}
+	return &app{
+		chain:                 chain,
+		rpcClient:             rpcClient,
+		rpcEndpoints:          rpcEndpoints,
+		registry:              registry,
+		exchangeRateProvider:  routing.NewOnChainExchangeRateProvider(pairProvider, poolReservesProvider, tokenDecimalsProvider, pairTokensProvider),
+		topTokensProvider:     topTokensProvider,
+		tokenDecimalsProvider: tokenDecimalsProvider,
+		poolsProvider:         poolsProvider,
+		ens:                   ens,
+		logger:                logger,
+		config: routing.V2RouterConfig{
+			DEXAdapters:          adapters,
+			PoolProvider:         poolsProvider,
+			TradingPairProvider:  pairProvider,
+			PoolReservesProvider: routing.NewRetryingPoolReservesProvider(routing.NewMulticallPoolReservesProvider(rpcClient), retry),
+			TopTokensProvider:    topTokensProvider,
+			PairTokensProvider:   pairTokensProvider,
+			BlockNumberProvider:  routing.NewRetryingBlockNumberProvider(rpcClient, retry),
+			PoolTypeProvider:     routing.NewRetryingPoolTypeProvider(routing.NewOnChainPoolTypeProvider(rpcClient), retry),
+			TotalSupplyProvider:  routing.NewRetryingTotalSupplyProvider(routing.NewOnChainTotalSupplyProvider(rpcClient), retry),
+			// stamped on every quote for auditing which deployment produced it
+			Deployment: routing.Deployment{
+				Environment:  os.Getenv("ROUTER_ENVIRONMENT"),
+				DeploymentID: os.Getenv("ROUTER_DEPLOYMENT_ID"),
+				License:      os.Getenv("ROUTER_DATA_LICENSE"),
+			},
+			Chain:        &chain,
+			Parallelism:  settings.Concurrency.Parallelism,
+			SlippageBips: settings.SlippageBips,
+			Logger:       logger,
+		},
+	}, nil
+}
+
+func (a *app) Close() {
+	if a.registry != nil {
+		a.registry.Close()
+	}
+	a.rpcClient.Close()
+}
+
+func newPoolsCommand(options *globalOptions) *cobra.Command {
+	pools := &cobra.Command{
+		Use:   "pools",
+		Short: "Inspect the pools routes are searched over",
+	}
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "Print the pools between the base tokens holding the minimum liquidity",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := options.connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer app.Close()
+			pools, err := app.poolsProvider.GetPools(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if options.json {
+				return writeJSON(cmd.OutOrStdout(), pools)
+			}
+			for _, pool := range pools {
+				fmt.Fprintln(cmd.OutOrStdout(), pool.Contract, pool.Token0, pool.Token1)
+			}
+			return nil
+		},
+	}
+	list.Flags().BoolVar(&options.json, "json", false, "print the pools as a JSON array")
+	pools.AddCommand(list)
+	return pools
+}
+
+// serve answers quotes over HTTP, without gas pricing since a gas price read at startup would go
+// stale
+func newServeCommand(options *globalOptions) *cobra.Command {
+	var addr string
+	var security routing.ServerSecurity
+	serve := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve quotes over HTTP",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := options.connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer app.Close()
+			config := app.config
+			// route timings, cache hit rates and requests per RPC endpoint are served on /metrics
+			config.Metrics = routing.NewMetrics()
+			config.Metrics.SetChain(app.chain)
+			config.Metrics.WatchEndpoints(app.rpcEndpoints)
+			if cache, ok := app.tokenDecimalsProvider.(*routing.CachedTokenDecimalsProvider); ok {
+				config.Metrics.WatchCache("token decimals", cache)
+			}
+			// spans of routes, pool discovery, reserve reads and RPC calls go to an OpenTelemetry
+			// collector when one is configured the standard way
+			if endpoint := otlpTracesEndpoint(); endpoint != "" {
+				serviceName := os.Getenv("OTEL_SERVICE_NAME")
+				if serviceName == "" {
+					serviceName = "router"
+				}
+				tracer := routing.NewOTLPTracer(endpoint, serviceName)
+				config.Tracer = tracer
+				go func() {
+					for {
+						app.logger.Warn("exporting spans failed", "error", tracer.Run(context.Background()))
+					}
+				}()
+				app.logger.Info("exporting spans", "endpoint", endpoint)
+			}
+			// reserves follow Sync events while the server runs, falling back to per-quote reads that
+			// concurrent quotes of the same block share until the next header
+			if app.chain.WSURL == "" {
+				app.logger.Info("reading reserves per quote, no websocket endpoint", "chain", app.chain.Name)
+			} else if wsClient, err := ethclient.Dial(app.chain.WSURL); err != nil {
+				app.logger.Warn("reading reserves per quote, websocket unavailable", "error", err)
+			} else {
+				blockCache := routing.NewBlockReservesCache(wsClient, config.PoolReservesProvider)
+				go keepRunning(app.logger, "head subscription", blockCache.Run)
+				config.Metrics.WatchCache("block reserves", blockCache)
+				syncProvider, err := routing.NewSyncPoolReservesProvider(wsClient, blockCache)
+				if err != nil {
+					return err
+				}
+				config.PoolReservesProvider = syncProvider
+				go keepRunning(app.logger, "sync subscription", syncProvider.Run)
+			}
+			router := routing.NewOnChainV2Router(config)
+			// kill -USR1 dumps the last graph, caches and routes in flight for debugging bad quotes
+			dumpOnSignal(router, os.TempDir(), app.logger)
+			app.logger.Info("serving quotes", "addr", addr, "tls", security.CertFile != "" || len(security.AutocertHosts) > 0, "client_certificates", security.ClientCAFile != "", "allowed_ips", security.AllowedIPs)
+			return routing.NewQuoteServer(router, app.poolsProvider).ListenAndServe(addr, security)
+		},
+	}
+	flags := serve.Flags()
+	flags.StringVar(&addr, "addr", ":8080", "address to listen on")
+	flags.StringVar(&security.CertFile, "tls-cert", "", "PEM certificate chain to serve TLS with")
+	flags.StringVar(&security.KeyFile, "tls-key", "", "PEM private key of --tls-cert")
+	flags.StringSliceVar(&security.AutocertHosts, "autocert-host", nil, "hostname to get a Let's Encrypt certificate for instead of --tls-cert, repeated or separated by commas")
+	flags.StringVar(&security.AutocertDir, "autocert-dir", "", "directory to cache Let's Encrypt certificates in")
+	flags.StringVar(&security.ClientCAFile, "client-ca", "", "PEM CA certificates client certificates must be signed by, requires TLS")
+	flags.StringSliceVar(&security.AllowedIPs, "allow-ip", nil, "client IP or CIDR range allowed to connect, repeated or separated by commas, every client when unset")
+	return serve
+}
+
+// otlpTracesEndpoint is the OTLP/HTTP traces URL from OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or
+// OTEL_EXPORTER_OTLP_ENDPOINT with /v1/traces appended, empty when neither is set
+func otlpTracesEndpoint() string {
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+func newDoctorCommand(options *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the deployment and exit non-zero when a check fails",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := options.connect(cmd)
+			if err != nil {
+				return err
+			}
+			code := doctor(app.rpcClient, app.rpcEndpoints, app.config, app.topTokensProvider, app.tokenDecimalsProvider)
+			app.Close()
+			if code != 0 {
+				os.Exit(code)
+			}
+			return nil
+		},
+	}
+}
+
+func newDecodeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "decode <calldata> [value]",
+		Short: "Print the swaps of a Router02 or Universal Router call",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(decode(args))
+		},
+	}
+}
+
+func newRegressCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "regress <snapshot dir> [baseline] [candidate]",
+		Short: "Compare two route searches over recorded graphs",
+		Long:  "Compare two route searches over recorded graphs, failing when the candidate pays less for any trade.",
+		Args:  cobra.RangeArgs(1, 3),
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(regress(args))
+		},
+	}
+}
+
+func newDashboardCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "dashboard",
+		Short: "Print a Grafana dashboard of the metrics served on /metrics",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dashboard, err := routing.GrafanaDashboard()
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(dashboard)
+			return err
+		},
+	}
+}
+
+// openRegistry opens the pool registry of chain in the user's cache directory, the router runs
+// without one when it cannot be opened, e.g. while another router process holds it
+func openRegistry(chain routing.Chain, ttl time.Duration, logger *slog.Logger) *routing.PoolRegistry {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		logger.Warn("running without a pool registry", "error", err)
+		return nil
+	}
+	// mainnet keeps the directory it had before other chains were supported
+	name := "registry"
+	if chain.ID != routing.Mainnet.ID {
+		name += "-" + chain.Name
+	}
+	if ttl == 0 {
+		ttl = 24 * time.Hour
+	}
+	registry, err := routing.OpenPoolRegistry(filepath.Join(cacheDir, "v2routing", name), ttl)
+	if err != nil {
+		logger.Warn("running without a pool registry", "error", err)
+		return nil
+	}
+	return registry
+}
+
+// writeJSON writes value as indented JSON, the format of --json
+func writeJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// keepRunning resubscribes whenever the named subscription drops
+func keepRunning(logger *slog.Logger, name string, run func(ctx context.Context) error) {
+	for {
+		err := run(context.Background())
+		logger.Warn("subscription ended, resubscribing", "subscription", name, "error", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// decode prints the swaps of router calldata, the optional value is the wei sent with it
+func decode(args []string) int {
+	data, err := hexutil.Decode(args[0])
+	if err != nil {
+		fmt.Println("invalid calldata:", err)
+		return 1
+	}
+	var value *big.Int
+	if len(args) > 1 {
+		var ok bool
+		if value, ok = new(big.Int).SetString(args[1], 10); !ok {
+			fmt.Println("invalid value:", args[1])
+			return 1
+		}
+	}
+	swaps, err := routing.DecodeSwapCalldata(data, value)
+	if err != nil {
+		fmt.Println("error decoding", err)
+		return 1
+	}
+	for _, swap := range swaps {
+		fmt.Println("swap:", swap.Method, "path:", swap.Path)
+		if len(swap.Fees) > 0 {
+			fmt.Println("fee tiers:", swap.Fees)
+		}
+		if swap.ExactOut {
+			fmt.Println("amount out:", swap.AmountOut, "max amount in:", swap.AmountInMax)
+		} else {
+			fmt.Println("amount in:", swap.AmountIn, "min amount out:", swap.AmountOutMin)
+		}
+		fmt.Println("recipient:", swap.Recipient, "deadline:", swap.Deadline)
+	}
+	return 0
+}
+
+// regress prints how a candidate routing strategy compares with a baseline and returns the exit code
+func regress(args []string) int {
+	names := []string{"dp", "v2math"}
+	copy(names, args[1:])
+	baseline, candidate := routing.RoutingStrategies[names[0]], routing.RoutingStrategies[names[1]]
+	if baseline == nil || candidate == nil {
+		fmt.Println("unknown strategy, available: dp, exhaustive, v2math")
+		return 1
+	}
+	snapshots, err := routing.LoadRegressionSnapshots(args[0])
+	if err != nil {
+		fmt.Println("error loading snapshots", err)
+		return 1
+	}
+	report, err := routing.CompareStrategies(context.Background(), baseline, candidate, snapshots)
+	if err != nil {
+		fmt.Println("error comparing strategies", err)
+		return 1
+	}
+	for _, diff := range report.Diffs {
+		fmt.Printf("%v %v -> %v amount %v: %v %v%v, %v %v%v (%+.4f%%)\n", diff.Snapshot, diff.Trade.TokenIn, diff.Trade.TokenOut, diff.Trade.AmountIn,
+			report.Baseline, diff.BaselineOut, diff.BaselineErr, report.Candidate, diff.CandidateOut, diff.CandidateErr, diff.ChangePercent)
+	}
+	fmt.Printf("%v vs %v over %v trades in %v snapshots: %v wins (%.1f%%), %v losses (%.1f%%), %v ties, %v unroutable\n",
+		report.Candidate, report.Baseline, report.Trades, len(snapshots), report.Wins, report.WinRate()*100, report.Losses, report.LossRate()*100, report.Ties, report.Unroutable)
+	if report.Losses > 0 {
+		return 1
+	}
+	return 0
+}
+
+// doctor prints a pass/fail report of the deployment and returns the exit code
+func doctor(rpcClient *ethclient.Client, rpcEndpoints *routing.FailoverTransport, config routing.V2RouterConfig, topTokensProvider routing.TopTokensProvider, tokenDecimalsProvider routing.TokenDecimalsProvider) int {
+	chain := *config.Chain
+	backends := map[string]func(ctx context.Context) error{}
+	if chain.WSURL != "" {
+		backends["websocket sync subscription"] = func(ctx context.Context) error {
+			wsClient, err := ethclient.DialContext(ctx, chain.WSURL)
+			if err != nil {
+				return err
+			}
+			defer wsClient.Close()
+			_, err = wsClient.BlockNumber(ctx)
+			return err
+		}
+	}
+	// every endpoint is checked on its own, the failover hides a dead one from the other checks
+	rpcEndpoints.HealthCheck(context.Background())
+	for i, status := range rpcEndpoints.Status() {
+		status := status
+		backends[fmt.Sprintf("rpc endpoint %v (%v)", i+1, status.URL)] = func(ctx context.Context) error { return status.Err }
+	}
+	contracts := map[string]common.Address{
+		"uniswap v2 factory": chain.V2Factory,
+		"multicall3":         common.HexToAddress(routing.MULTICALL3_ADDRESS),
+		"uniswap v3 quoter":  chain.V3QuoterV2,
+	}
+	if chain.SushiswapFactory != (common.Address{}) {
+		contracts["sushiswap factory"] = chain.SushiswapFactory
+	}
+	checks := routing.NewDoctor(routing.DoctorConfig{
+		Client:                rpcClient,
+		Chain:                 &chain,
+		Contracts:             contracts,
+		TopTokensProvider:     topTokensProvider,
+		TokenDecimalsProvider: tokenDecimalsProvider,
+		Router:                routing.NewOnChainV2Router(config),
+		Backends:              backends,
+	}).Run(context.Background())
+	code := 0
+	for _, check := range checks {
+		if check.Err != nil {
+			fmt.Printf("FAIL %v: %v\n", check.Name, check.Err)
+			code = 1
+			continue
+		}
+		fmt.Printf("PASS %v\n", check.Name)
+	}
+	return code
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/spf13/cobra"
+
+	"v2Routing/routing"
+)
+
+// quoteOptions are the flags of router quote
+type quoteOptions struct {
+	in      string
+	out     string
+	amount  string
+	maxHops int
+	v3      bool
+	// address or ENS name, the swap is built for it when set
+	recipient string
+}
+
+func newQuoteCommand(options *globalOptions) *cobra.Command {
+	quoteOptions := &quoteOptions{}
+	quote := &cobra.Command{
+		Use:     "quote",
+		Short:   "Print the best route for a trade",
+		Example: "  router quote --in WETH --out USDC --amount 1.5 --max-hops 3",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := options.connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer app.Close()
+			return runQuote(cmd, app, quoteOptions, options.json)
+		},
+	}
+	flags := quote.Flags()
+	flags.StringVar(&quoteOptions.in, "in", "", "token to sell, an address, the symbol of a base token or an ENS name")
+	flags.StringVar(&quoteOptions.out, "out", "", "token to buy, an address, the symbol of a base token or an ENS name")
+	flags.StringVar(&quoteOptions.amount, "amount", "", "amount to sell in token units, e.g. 1.5")
+	flags.IntVar(&quoteOptions.maxHops, "max-hops", routing.AutoMaxHops, "most swaps on the route, 0 picks the depth from the tokens")
+	flags.BoolVar(&quoteOptions.v3, "v3", true, "route the trade through Uniswap V3 pools as well")
+	flags.StringVar(&quoteOptions.recipient, "recipient", "", "build the Router02 swap paying this address or ENS name")
+	flags.BoolVar(&options.json, "json", false, "print the quote as JSON")
+	quote.MarkFlagRequired("in")
+	quote.MarkFlagRequired("out")
+	quote.MarkFlagRequired("amount")
+	return quote
+}
+
+// quoteOutput is what router quote prints, as JSON with --json
+type quoteOutput struct {
+	*routing.QuoteResponse
+	// mid-price of the direct pair adjusted for decimals, and what it pays for the trade
+	ExchangeRate    string `json:"exchangeRate,omitempty"`
+	DirectAmountOut string `json:"directAmountOut,omitempty"`
+	// symbols and decimals of the tokens on the path, empty for tokens whose decimals cannot be read
+	PathTokens []routing.Token `json:"pathTokens,omitempty"`
+	UniswapURL string          `json:"uniswapUrl,omitempty"`
+	V3         *v3Output       `json:"v3,omitempty"`
+	Swap       *swapOutput     `json:"swap,omitempty"`
+}
+
+// swapOutput is the Router02 transaction executing the quote, built with --recipient
+type swapOutput struct {
+	Recipient common.Address `json:"recipient"`
+	To        common.Address `json:"to,omitempty"`
+	Method    string         `json:"method,omitempty"`
+	Value     string         `json:"value,omitempty"`
+	Data      hexutil.Bytes  `json:"data,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// v3Output is the same trade routed through Uniswap V3 pools, for comparison
+type v3Output struct {
+	AmountOut string          `json:"amountOut,omitempty"`
+	Hops      []routing.V3Hop `json:"hops,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+func runQuote(cmd *cobra.Command, app *app, options *quoteOptions, asJSON bool) error {
+	ctx := routing.WithRequestID(cmd.Context(), routing.NewRequestID())
+	symbols := routing.NewOnChainTokenSymbolProvider(app.rpcClient)
+	tokens := routing.NewTokenMetadataProvider(app.tokenDecimalsProvider, symbols)
+	tokenA, err := routing.ResolveToken(ctx, options.in, app.chain.BaseTokens, symbols, app.ens)
+	if err != nil {
+		return fmt.Errorf("--in: %w", err)
+	}
+	tokenB, err := routing.ResolveToken(ctx, options.out, app.chain.BaseTokens, symbols, app.ens)
+	if err != nil {
+		return fmt.Errorf("--out: %w", err)
+	}
+	var recipient common.Address
+	if options.recipient != "" {
+		if recipient, err = routing.ResolveAddress(ctx, options.recipient, app.ens); err != nil {
+			return fmt.Errorf("--recipient: %w", err)
+		}
+	}
+	if tokenA == tokenB {
+		return fmt.Errorf("--in and --out are the same token %v: %w", tokenA, routing.ErrSameToken)
+	}
+	tokenIn, err := tokens.GetToken(ctx, tokenA)
+	if err != nil {
+		return fmt.Errorf("--in: %w", err)
+	}
+	tokenOut, err := tokens.GetToken(ctx, tokenB)
+	if err != nil {
+		return fmt.Errorf("--out: %w", err)
+	}
+	amountIn, err := tokenIn.ParseAmount(options.amount)
+	if err != nil {
+		return fmt.Errorf("--amount: %w", err)
+	}
+	if amountIn.Sign() <= 0 {
+		return fmt.Errorf("--amount: %q is not positive", options.amount)
+	}
+
+	config := app.config
+	if gasPrice, err := app.rpcClient.SuggestGasPrice(ctx); err == nil {
+		config.GasPricing = &routing.GasPricing{GasPerSwap: routing.DefaultGasPerSwap, BaseFee: gasPrice}
+	}
+	router := routing.NewOnChainV2Router(config)
+	amountOut, path, metadata, err := router.RouteWithMetadata(ctx, amountIn, tokenA, tokenB, options.maxHops)
+	if err != nil {
+		return fmt.Errorf("routing: %w", err)
+	}
+	slippageBips := config.SlippageBips
+	if slippageBips == 0 {
+		slippageBips = routing.DefaultSlippageBips
+	}
+	response, err := routing.NewQuoteResponse(tokenA, tokenB, amountIn, amountOut, path, metadata, slippageBips)
+	if err != nil {
+		return err
+	}
+	response.SetTokens(tokenIn, tokenOut)
+	output := &quoteOutput{QuoteResponse: response}
+	for _, address := range path {
+		token, _ := tokens.GetToken(ctx, address)
+		output.PathTokens = append(output.PathTokens, token)
+	}
+	if price, err := app.exchangeRateProvider.GetExchangeRate(ctx, tokenA, tokenB); err == nil {
+		rate, _ := price.Float64()
+		output.ExchangeRate = strconv.FormatFloat(rate, 'g', 10, 64)
+	}
+	if directOut, err := app.exchangeRateProvider.GetQuote(ctx, tokenA, tokenB, amountIn); err == nil {
+		output.DirectAmountOut = directOut.String()
+	}
+	if link, err := routing.UniswapAppURL(app.chain.ID, tokenA, tokenB, amountIn, tokenIn.Decimals); err == nil {
+		output.UniswapURL = link
+	}
+	if options.v3 {
+		output.V3 = routeV3(ctx, app, amountIn, tokenA, tokenB)
+	}
+	if options.recipient != "" {
+		quote, err := routing.NewQuote(tokenA, tokenB, amountIn, amountOut, metadata, slippageBips)
+		if err != nil {
+			return err
+		}
+		output.Swap = buildSwap(app.chain, quote, recipient)
+	}
+	if asJSON {
+		return writeJSON(cmd.OutOrStdout(), output)
+	}
+	printQuote(cmd.OutOrStdout(), output)
+	return nil
+}
+
+// printQuote writes amounts in token units and tokens by their symbols
+func printQuote(out io.Writer, output *quoteOutput) {
+	tokenIn, tokenOut := *output.TokenInInfo, *output.TokenOutInfo
+	names := map[common.Address]string{}
+	for _, token := range append(output.PathTokens, tokenIn, tokenOut) {
+		if token.Address != (common.Address{}) {
+			names[token.Address] = token.String()
+		}
+	}
+	name := func(address common.Address) string {
+		if name, ok := names[address]; ok {
+			return name
+		}
+		return address.Hex()
+	}
+	format := func(token routing.Token, amount string) string {
+		units, _ := new(big.Int).SetString(amount, 10)
+		return token.FormatAmount(units)
+	}
+
+	if output.ExchangeRate != "" {
+		fmt.Fprintln(out, "1", tokenIn, "equals", output.ExchangeRate, tokenOut, "on the direct pair")
+	}
+	if output.DirectAmountOut != "" {
+		fmt.Fprintln(out, "direct pair pays", format(tokenOut, output.DirectAmountOut), "for", output.Formatted.AmountIn)
+	}
+	for _, warning := range output.ReserveWarnings {
+		fmt.Fprintln(out, "warning:", warning)
+	}
+	fmt.Fprintln(out, "best amount out:", output.Formatted.AmountOut)
+	fmt.Fprintln(out, "minimum amount out at", output.SlippageBips, "bps slippage:", output.Formatted.AmountOutMin)
+	if output.Formatted.GasCost != "" {
+		fmt.Fprintln(out, "gas cost:", output.Formatted.GasCost)
+	}
+	path := make([]string, len(output.Path))
+	for i, address := range output.Path {
+		path[i] = name(address)
+	}
+	fmt.Fprintln(out, "best path:", strings.Join(path, " -> "))
+	for _, hop := range output.Hops {
+		fmt.Fprintln(out, "hop:", name(hop.TokenIn), "->", name(hop.TokenOut), "on", hop.DEX)
+	}
+	fmt.Fprintf(out, "price impact: %.2f%%\n", output.PriceImpactPercent)
+	if output.PriceImpactWarning != "" {
+		fmt.Fprintln(out, "warning:", output.PriceImpactWarning)
+	}
+	if output.UniswapURL != "" {
+		fmt.Fprintln(out, "trade it on the Uniswap app:", output.UniswapURL)
+	}
+	if output.Swap != nil {
+		printSwap(out, output.Swap)
+	}
+	if output.V3 == nil {
+		return
+	}
+	if output.V3.Error != "" {
+		fmt.Fprintln(out, "error routing through v3", output.V3.Error)
+		return
+	}
+	fmt.Fprintln(out, "best v3 amount out:", format(tokenOut, output.V3.AmountOut))
+	for _, hop := range output.V3.Hops {
+		fmt.Fprintln(out, "v3 hop:", name(hop.TokenIn), "->", name(hop.TokenOut), "fee tier", hop.Fee)
+	}
+}
+
+func printSwap(out io.Writer, swap *swapOutput) {
+	if swap.Error != "" {
+		fmt.Fprintln(out, "error building the swap for", swap.Recipient, swap.Error)
+		return
+	}
+	fmt.Fprintln(out, "swap paying", swap.Recipient, "calls", swap.Method, "on", swap.To, "with value", swap.Value)
+	fmt.Fprintln(out, "calldata:", swap.Data)
+}
+
+// buildSwap builds the Router02 call executing quote, a failure such as a hop on Sushiswap is
+// reported in the output rather than failing the quote
+func buildSwap(chain routing.Chain, quote *routing.Quote, recipient common.Address) *swapOutput {
+	builder := routing.NewTxBuilder(chain.Router02, nil)
+	builder.SetChain(chain)
+	swap, err := builder.Build(quote, routing.SwapOptions{Recipient: recipient})
+	if err != nil {
+		return &swapOutput{Recipient: recipient, Error: err.Error()}
+	}
+	return &swapOutput{Recipient: recipient, To: swap.To, Method: swap.Method, Value: swap.Value.String(), Data: swap.Data}
+}
+
+// routeV3 routes the same trade through Uniswap V3 pools, a failure is reported in the output
+// rather than failing the quote
+func routeV3(ctx context.Context, app *app, amountIn *big.Int, tokenA, tokenB common.Address) *v3Output {
+	v3Pools, v3Quotes := routing.NewOnChainV3PoolProvider(app.rpcClient), routing.NewOnChainV3QuoteProvider(app.rpcClient)
+	v3Pools.SetChain(app.chain)
+	v3Quotes.SetChain(app.chain)
+	v3Router := routing.NewOnChainV3Router(
+		v3Pools,
+		v3Quotes,
+		app.topTokensProvider,
+		app.rpcClient,
+	)
+	v3Router.SetLogger(app.logger)
+	v3Route, err := v3Router.Route(ctx, amountIn, tokenA, tokenB, 2)
+	if err != nil {
+		return &v3Output{Error: err.Error()}
+	}
+	return &v3Output{AmountOut: v3Route.AmountOut.String(), Hops: v3Route.Hops}
+}
@@ -1,14 +0,0 @@
-package main
-
-const FACTORY_ADDRESS = "0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f"
-const INIT_CODE_HASH = "0x96e8ac4277198ff8b6f785478aa9a39f403cb768dd02cbee326c3e7da348845f"
-const WETH_USDC = "0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc"
-const USDC = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
-const WETH = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
-const DAI = "0x6B175474E89094C44Da98b954EedeAC495271d0F"
-const UNI = "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984"
-const WBTC = "0x2260fac5e5542a773aa44fbcfedf7c193bc2c599"
-const USDT = "0xdac17f958d2ee523a2206206994597c13d831ec7"
-const PAXG = "0x45804880de22913dafe09f4980848ece6ecbaf78"
-const WISE = "0x66a0f676479cee1d7373f3dc2e2952778bff5bd6"
-const MAINNET_INFURA_RPC = "https://mainnet.infura.io/v3/c75a0117c6cd4c84a4a8bf62ac9979e7"
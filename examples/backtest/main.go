@@ -0,0 +1,99 @@
+// Command backtest replays recorded Sync events block by block and quotes the same trade after
+// every block, showing how a route would have priced over time without a node
+//
+//	go run ./examples/backtest -events routing/testdata/sync_swap_events.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"v2Routing/routing"
+)
+
+// staticPools searches a fixed set of pools, backtests must not discover pools created later
+type staticPools []routing.Pool
+
+func (p staticPools) GetPools(ctx context.Context) ([]routing.Pool, error) {
+	return p, nil
+}
+
+func main() {
+	events := flag.String("events", "routing/testdata/sync_swap_events.json", "JSON array of pair logs in the eth_getLogs format")
+	in := flag.String("in", routing.WETH, "token to sell")
+	out := flag.String("out", routing.USDC, "token to buy")
+	amount := flag.String("amount", "1", "amount to sell in the smallest unit of -in")
+	flag.Parse()
+
+	tokenIn, err := routing.ParseAddress(*in)
+	if err != nil {
+		log.Fatal("invalid -in: ", err)
+	}
+	tokenOut, err := routing.ParseAddress(*out)
+	if err != nil {
+		log.Fatal("invalid -out: ", err)
+	}
+	amountIn, ok := new(big.Int).SetString(*amount, 10)
+	if !ok || amountIn.Sign() <= 0 {
+		log.Fatal("invalid -amount ", *amount)
+	}
+	logs, err := routing.LoadEventFixture(*events)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := backtest(context.Background(), os.Stdout, logs, amountIn, tokenIn, tokenOut); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// backtest prints the quote after each block of logs, blocks where the trade cannot be routed
+// are reported and skipped
+func backtest(ctx context.Context, w io.Writer, logs []types.Log, amountIn *big.Int, tokenIn, tokenOut common.Address) error {
+	reserves := &routing.EventPoolReservesProvider{}
+	replayer, err := routing.NewPoolEventReplayer(reserves)
+	if err != nil {
+		return err
+	}
+	pairProvider := routing.NewCreate2TradingPairProvider(common.HexToAddress(routing.FACTORY_ADDRESS), common.HexToHash(routing.INIT_CODE_HASH))
+	pair, err := pairProvider.GetTradingPair(ctx, tokenIn, tokenOut)
+	if err != nil {
+		return err
+	}
+	router := routing.NewOnChainV2Router(routing.V2RouterConfig{
+		PoolProvider:         staticPools{{Token0: tokenIn, Token1: tokenOut, Contract: pair}},
+		TradingPairProvider:  pairProvider,
+		PoolReservesProvider: reserves,
+	})
+
+	blocks := make(map[uint64][]types.Log)
+	for _, log := range logs {
+		blocks[log.BlockNumber] = append(blocks[log.BlockNumber], log)
+	}
+	numbers := make([]uint64, 0, len(blocks))
+	for number := range blocks {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	for _, number := range numbers {
+		if err := replayer.Replay(ctx, blocks[number]); err != nil {
+			return err
+		}
+		quote, err := router.Route(ctx, amountIn, tokenIn, tokenOut, 1)
+		if err != nil {
+			fmt.Fprintf(w, "block %v: %v\n", number, err)
+			continue
+		}
+		fmt.Fprintf(w, "block %v: %v -> %v (price impact %.2f%%)\n", number, amountIn, quote.AmountOut, quote.PriceImpactPercent())
+	}
+	return nil
+}
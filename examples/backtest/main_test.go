@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/routing"
+)
+
+func TestBacktest(t *testing.T) {
+	logs, err := routing.LoadEventFixture("../../routing/testdata/sync_swap_events.json")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	var out bytes.Buffer
+	if err := backtest(context.Background(), &out, logs, big.NewInt(5), common.HexToAddress(routing.WETH), common.HexToAddress(routing.USDC)); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %v lines want one per block in\n%v", len(lines), out.String())
+	}
+	// USDC is token0, WETH/USDC reserves go 21/100 at 16000000 and 20/110 at 16000001, the only
+	// log of 16000002 was removed by a reorg so its quote is unchanged
+	for i, want := range []string{"block 16000000: 5 -> 19 ", "block 16000001: 5 -> 21 ", "block 16000002: 5 -> 21 "} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("got %q want prefix %q", lines[i], want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Command customvenue routes across Uniswap V2 and a venue the library has no adapter for, by
+// implementing routing.DEXAdapter outside the routing package
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/routing"
+	"v2Routing/v2math"
+)
+
+// SHIBASWAP_FACTORY_ADDRESS is the ShibaSwap factory on mainnet, a Uniswap V2 fork charging 0.3%
+const SHIBASWAP_FACTORY_ADDRESS = "0x115934131916C8b277DD010Ee02de363c09d037c"
+
+// shibaSwap prices swaps with v2math instead of the fork adapter of the routing package, any
+// venue with reserves and a closed form for amounts can be plugged in the same way
+type shibaSwap struct {
+	pairs routing.TradingPairProvider
+}
+
+func (s shibaSwap) Name() string {
+	return "shibaswap"
+}
+
+func (s shibaSwap) FactoryAddress() common.Address {
+	return common.HexToAddress(SHIBASWAP_FACTORY_ADDRESS)
+}
+
+func (s shibaSwap) Fee() *big.Rat {
+	return big.NewRat(3, 1000)
+}
+
+func (s shibaSwap) GetPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	return s.pairs.GetTradingPair(ctx, tokenA, tokenB)
+}
+
+func (s shibaSwap) GetAmountOut(amountIn, reserveIn, reserveOut *big.Int) (*big.Int, error) {
+	return v2math.GetAmountOut(amountIn, reserveIn, reserveOut, v2math.FeeFromBips(30))
+}
+
+func (s shibaSwap) GetAmountIn(amountOut, reserveIn, reserveOut *big.Int) (*big.Int, error) {
+	return v2math.GetAmountIn(amountOut, reserveIn, reserveOut, v2math.FeeFromBips(30))
+}
+
+func main() {
+	rpcURL := flag.String("rpc", routing.MAINNET_INFURA_RPC, "Ethereum JSON-RPC endpoint")
+	flag.Parse()
+
+	client, err := routing.DialEthClient(*rpcURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	uniswapPairs := routing.NewCreate2TradingPairProvider(common.HexToAddress(routing.FACTORY_ADDRESS), common.HexToHash(routing.INIT_CODE_HASH))
+	shibaSwapPairs, err := routing.NewOnChainTradingPairProvider(common.HexToAddress(SHIBASWAP_FACTORY_ADDRESS), client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reserves := routing.NewMulticallPoolReservesProvider(client)
+	decimals := routing.NewOnChainTokenDecimalsProvider(client)
+	router := routing.NewOnChainV2Router(routing.V2RouterConfig{
+		DEXAdapters:          venues(uniswapPairs, shibaSwapPairs),
+		PoolProvider:         routing.NewOnChainPoolsProvider(uniswapPairs, &routing.StaticTopTokensProvider{}, reserves, decimals, 0),
+		TradingPairProvider:  uniswapPairs,
+		PoolReservesProvider: reserves,
+	})
+
+	quote, err := router.Route(context.Background(), big.NewInt(1e18), common.HexToAddress(routing.WETH), common.HexToAddress(routing.USDC), 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("1 WETH buys", quote.AmountOut, "USDC units")
+	for _, hop := range quote.Hops {
+		fmt.Println("  via", hop.DEX, hop.Pair.Hex())
+	}
+}
+
+func venues(uniswapPairs, shibaSwapPairs routing.TradingPairProvider) []routing.DEXAdapter {
+	return []routing.DEXAdapter{routing.NewUniswapV2Adapter(uniswapPairs), shibaSwap{pairs: shibaSwapPairs}}
+}
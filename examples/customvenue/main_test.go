@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/examples/internal/memchain"
+	"v2Routing/routing"
+)
+
+func TestCustomVenueRoute(t *testing.T) {
+	weth, usdc := common.HexToAddress(routing.WETH), common.HexToAddress(routing.USDC)
+	uniswap, shibaSwapFactory := common.HexToAddress(routing.FACTORY_ADDRESS), common.HexToAddress(SHIBASWAP_FACTORY_ADDRESS)
+	chain := memchain.New()
+	chain.AddPool(uniswap, weth, usdc, big.NewInt(1000000), big.NewInt(2000000000))
+	// ShibaSwap pays more for WETH, so the route should go through it
+	pair := chain.AddPool(shibaSwapFactory, weth, usdc, big.NewInt(1000000), big.NewInt(2100000000))
+
+	config := chain.RouterConfig()
+	config.DEXAdapters = venues(chain.Pairs(uniswap), chain.Pairs(shibaSwapFactory))
+	quote, err := routing.NewOnChainV2Router(config).Route(context.Background(), big.NewInt(1000), weth, usdc, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(quote.Hops) != 1 || quote.Hops[0].DEX != "shibaswap" || quote.Hops[0].Pair != pair {
+		t.Errorf("got hops %+v want one hop through the shibaswap pair %v", quote.Hops, pair.Hex())
+	}
+}
@@ -0,0 +1,72 @@
+// Command embedserver mounts the quote API under /v1 of an existing HTTP service, next to its own
+// endpoints and middleware
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"v2Routing/routing"
+)
+
+func main() {
+	chainName := flag.String("chain", "mainnet", "chain to quote on, by name or ID")
+	rpcURLs := flag.String("rpc", "", "JSON-RPC endpoints separated by commas, tried in order when one fails, defaults to the chain's public endpoint")
+	addr := flag.String("addr", ":8080", "address to listen on")
+	flag.Parse()
+
+	chain, err := routing.LookupChain(*chainName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *rpcURLs == "" {
+		*rpcURLs = chain.RPCURL
+	}
+	client, endpoints, err := routing.DialFailoverEthClient(routing.FailoverConfig{URLs: strings.Split(*rpcURLs, ",")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	go endpoints.Run(context.Background())
+	pairProvider := routing.NewCreate2TradingPairProvider(chain.V2Factory, chain.V2InitCodeHash)
+	// quotes of the same block share their reserve reads, which are tried again when the node
+	// has a hiccup instead of failing the quote
+	retry := routing.DefaultRetryPolicy
+	reserves := routing.NewSingleflightPoolReservesProvider(routing.NewRetryingPoolReservesProvider(routing.NewMulticallPoolReservesProvider(client), retry))
+	decimals := routing.NewCachedTokenDecimalsProvider(routing.NewRetryingTokenDecimalsProvider(routing.NewOnChainTokenDecimalsProvider(client), retry), 0)
+	pools := routing.NewOnChainPoolsProvider(pairProvider, &routing.StaticTopTokensProvider{Tokens: chain.BaseTokens}, reserves, decimals, 0)
+	pools.SetChain(chain)
+	router := routing.NewOnChainV2Router(routing.V2RouterConfig{
+		PoolProvider:         pools,
+		TradingPairProvider:  pairProvider,
+		PoolReservesProvider: reserves,
+		BlockNumberProvider:  routing.NewRetryingBlockNumberProvider(client, retry),
+		Deployment:           routing.Deployment{Environment: "example"},
+		Chain:                &chain,
+	})
+
+	log.Println("serving quotes on", *addr+"/v1/quote")
+	log.Fatal(http.ListenAndServe(*addr, newHandler(router, pools)))
+}
+
+// newHandler serves the routes of the host service and the quote API under /v1
+func newHandler(router *routing.OnChainV2Router, pools routing.PoolsProvider) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.Handle("/v1/", http.StripPrefix("/v1", routing.NewQuoteServer(router, pools)))
+	return accessLog(mux)
+}
+
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Println(r.Method, r.URL.Path, time.Since(start))
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/examples/internal/memchain"
+	"v2Routing/routing"
+)
+
+func TestEmbeddedQuoteServer(t *testing.T) {
+	chain := memchain.New()
+	chain.AddPool(common.HexToAddress(routing.FACTORY_ADDRESS), common.HexToAddress(routing.WETH), common.HexToAddress(routing.USDC), big.NewInt(1000000), big.NewInt(2000000000))
+	server := httptest.NewServer(newHandler(routing.NewOnChainV2Router(chain.RouterConfig()), chain))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/v1/quote?tokenIn=" + routing.WETH + "&tokenOut=" + routing.USDC + "&amountIn=1000")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v want 200", response.StatusCode)
+	}
+	var quote routing.QuoteResponse
+	if err := json.NewDecoder(response.Body).Decode(&quote); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(quote.Hops) != 1 || quote.AmountOut == "" || quote.AmountOut == "0" {
+		t.Errorf("got quote %+v want one hop paying out", quote)
+	}
+
+	health, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	health.Body.Close()
+	if health.StatusCode != http.StatusOK {
+		t.Errorf("got health status %v want 200", health.StatusCode)
+	}
+}
@@ -0,0 +1,77 @@
+// Package memchain serves V2 pools from memory so the examples can be run in tests without a node
+package memchain
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/routing"
+	"v2Routing/v2math"
+)
+
+// Chain holds pools of any number of factories, pair addresses are computed like Uniswap's CREATE2
+type Chain struct {
+	pools    []routing.Pool
+	reserves map[common.Address][2]*big.Int
+}
+
+func New() *Chain {
+	return &Chain{reserves: make(map[common.Address][2]*big.Int)}
+}
+
+// AddPool creates the tokenA/tokenB pair of factory with the given reserves
+func (c *Chain) AddPool(factory, tokenA, tokenB common.Address, reserveA, reserveB *big.Int) common.Address {
+	pair := routing.ComputePairAddress(factory, common.HexToHash(routing.INIT_CODE_HASH), tokenA, tokenB)
+	c.pools = append(c.pools, routing.Pool{Token0: tokenA, Token1: tokenB, Contract: pair})
+	if token0, _ := v2math.SortTokens(tokenA, tokenB); token0 != tokenA {
+		reserveA, reserveB = reserveB, reserveA
+	}
+	c.reserves[pair] = [2]*big.Int{reserveA, reserveB}
+	return pair
+}
+
+func (c *Chain) GetPools(ctx context.Context) ([]routing.Pool, error) {
+	return c.pools, nil
+}
+
+// GetTradingPair looks pairs up on the Uniswap V2 factory
+func (c *Chain) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	return c.Pairs(common.HexToAddress(routing.FACTORY_ADDRESS)).GetTradingPair(ctx, tokenA, tokenB)
+}
+
+func (c *Chain) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	reserves, ok := c.reserves[pairAddress]
+	if !ok {
+		return nil, nil, routing.ErrPairNotDeployed
+	}
+	return new(big.Int).Set(reserves[0]), new(big.Int).Set(reserves[1]), nil
+}
+
+// Pairs looks pairs up on factory, returning the zero address for pairs that were not added
+func (c *Chain) Pairs(factory common.Address) routing.TradingPairProvider {
+	return factoryPairs{chain: c, factory: factory}
+}
+
+// RouterConfig routes through the Uniswap V2 pools of the chain
+func (c *Chain) RouterConfig() routing.V2RouterConfig {
+	return routing.V2RouterConfig{
+		PoolProvider:         c,
+		TradingPairProvider:  c,
+		PoolReservesProvider: c,
+	}
+}
+
+type factoryPairs struct {
+	chain   *Chain
+	factory common.Address
+}
+
+func (p factoryPairs) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	pair := routing.ComputePairAddress(p.factory, common.HexToHash(routing.INIT_CODE_HASH), tokenA, tokenB)
+	if _, ok := p.chain.reserves[pair]; !ok {
+		return common.Address{}, nil
+	}
+	return pair, nil
+}
@@ -0,0 +1,74 @@
+// Command simplequote prints the best Uniswap V2 route for a single trade
+//
+//	go run ./examples/simplequote -in 0xc02a... -out 0xa0b8... -amount 1000000000000000000
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/routing"
+)
+
+func main() {
+	rpcURL := flag.String("rpc", routing.MAINNET_INFURA_RPC, "Ethereum JSON-RPC endpoint")
+	in := flag.String("in", routing.WETH, "token to sell")
+	out := flag.String("out", routing.USDC, "token to buy")
+	amount := flag.String("amount", "1000000000000000000", "amount to sell in the smallest unit of -in")
+	hops := flag.Int("hops", 3, "maximum number of pools in the route")
+	flag.Parse()
+
+	tokenIn, err := routing.ParseAddress(*in)
+	if err != nil {
+		log.Fatal("invalid -in: ", err)
+	}
+	tokenOut, err := routing.ParseAddress(*out)
+	if err != nil {
+		log.Fatal("invalid -out: ", err)
+	}
+	amountIn, ok := new(big.Int).SetString(*amount, 10)
+	if !ok || amountIn.Sign() <= 0 {
+		log.Fatal("invalid -amount ", *amount)
+	}
+
+	client, err := routing.DialEthClient(*rpcURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pairProvider := routing.NewCreate2TradingPairProvider(common.HexToAddress(routing.FACTORY_ADDRESS), common.HexToHash(routing.INIT_CODE_HASH))
+	reserves := routing.NewMulticallPoolReservesProvider(client)
+	decimals := routing.NewCachedTokenDecimalsProvider(routing.NewOnChainTokenDecimalsProvider(client), 0)
+	router := routing.NewOnChainV2Router(routing.V2RouterConfig{
+		PoolProvider:         routing.NewOnChainPoolsProvider(pairProvider, &routing.StaticTopTokensProvider{}, reserves, decimals, 0),
+		TradingPairProvider:  pairProvider,
+		PoolReservesProvider: reserves,
+		BlockNumberProvider:  client,
+	})
+	if err := printQuote(context.Background(), os.Stdout, router, amountIn, tokenIn, tokenOut, *hops); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// printQuote is everything a program needs to quote once it has a router
+func printQuote(ctx context.Context, w io.Writer, router routing.V2Router, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) error {
+	quote, err := router.Route(ctx, amountIn, tokenIn, tokenOut, maxHops)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "amount out:", quote.AmountOut)
+	for _, hop := range quote.Hops {
+		fmt.Fprintf(w, "  %v -> %v via %v (%v)\n", hop.TokenIn.Hex(), hop.TokenOut.Hex(), hop.Pair.Hex(), hop.DEX)
+	}
+	fmt.Fprintf(w, "price impact: %.2f%%\n", quote.PriceImpactPercent())
+	if quote.HasHighPriceImpact() {
+		fmt.Fprintln(w, "warning: high price impact, consider a smaller trade")
+	}
+	return nil
+}
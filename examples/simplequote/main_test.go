@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/examples/internal/memchain"
+	"v2Routing/routing"
+)
+
+func TestPrintQuote(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(routing.WETH), common.HexToAddress(routing.USDC), common.HexToAddress(routing.DAI)
+	chain := memchain.New()
+	factory := common.HexToAddress(routing.FACTORY_ADDRESS)
+	chain.AddPool(factory, weth, usdc, big.NewInt(1000000), big.NewInt(2000000000))
+	chain.AddPool(factory, usdc, dai, big.NewInt(1000000000), big.NewInt(1100000000))
+	router := routing.NewOnChainV2Router(chain.RouterConfig())
+
+	var out bytes.Buffer
+	if err := printQuote(context.Background(), &out, router, big.NewInt(1000), weth, dai, 3); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if got := strings.Count(out.String(), " -> "); got != 2 {
+		t.Errorf("got %v hops want 2 in\n%v", got, out.String())
+	}
+	if strings.Contains(out.String(), "warning") {
+		t.Errorf("got a price impact warning for a small trade in\n%v", out.String())
+	}
+
+	out.Reset()
+	if err := printQuote(context.Background(), &out, router, big.NewInt(100000), weth, usdc, 1); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if !strings.Contains(out.String(), "warning: high price impact") {
+		t.Errorf("got no price impact warning for a large trade in\n%v", out.String())
+	}
+}
@@ -0,0 +1,213 @@
+package pathfinder
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SpotPricer is a Pool that knows its marginal rate, FindArbitrage only considers such pools
+type SpotPricer interface {
+	// SpotRate is the output per unit of input of an infinitesimal swap net of fees, nil when the
+	// pool is empty
+	SpotRate() *big.Rat
+}
+
+// Cycle is a route from a token back to itself that pays more than it takes
+type Cycle struct {
+	// starts and ends at the token
+	Path []common.Address
+	// for every swap, the index of its pool in Graph.Pools
+	Pools []int
+	// product of the spot rates of the swaps, above 1
+	Rate *big.Rat
+	// the input making the most profit at the current reserves and that profit, both zero when
+	// rounding to whole units eats the profit
+	AmountIn *big.Int
+	Profit   *big.Int
+}
+
+// FindArbitrage looks for cycles of up to maxHops swaps that start and end at token and pay more
+// than they take at the pools' spot rates. It runs Bellman-Ford on -log(rate) bounded by hops:
+// layer i holds the lightest walk of exactly i swaps from token to every token, so a negative
+// weight back at token is a profitable cycle. The lightest walk of every length is returned when
+// it visits no token twice, the most profitable first.
+func FindArbitrage(ctx context.Context, graph *Graph, token common.Address, maxHops int) ([]*Cycle, error) {
+	start := tokenIndex(graph.Tokens, token)
+	if start < 0 {
+		return nil, nil
+	}
+	type edge struct {
+		from, to, pool int
+		weight         float64
+	}
+	edges := []edge{}
+	for pair, pools := range graph.Pools {
+		from, to := tokenIndex(graph.Tokens, pair.TokenIn), tokenIndex(graph.Tokens, pair.TokenOut)
+		if from < 0 || to < 0 {
+			continue
+		}
+		for i, pool := range pools {
+			rate := spotRate(pool)
+			if rate == nil {
+				continue
+			}
+			f, _ := rate.Float64()
+			edges = append(edges, edge{from: from, to: to, pool: i, weight: -math.Log(f)})
+		}
+	}
+	// keeps the layers independent of map order
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].from != edges[j].from {
+			return edges[i].from < edges[j].from
+		}
+		if edges[i].to != edges[j].to {
+			return edges[i].to < edges[j].to
+		}
+		return edges[i].pool < edges[j].pool
+	})
+
+	weights := make([][]float64, maxHops+1)
+	prev := make([][]int, maxHops+1)
+	for i := range weights {
+		weights[i] = make([]float64, len(graph.Tokens))
+		prev[i] = make([]int, len(graph.Tokens))
+		for t := range weights[i] {
+			weights[i][t] = math.Inf(1)
+		}
+	}
+	weights[0][start] = 0
+	cycles := []*Cycle{}
+	for i := 1; i <= maxHops; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for k, e := range edges {
+			// a walk back at token is a cycle and ends there
+			if (e.from == start && i > 1) || math.IsInf(weights[i-1][e.from], 1) {
+				continue
+			}
+			if weight := weights[i-1][e.from] + e.weight; weight < weights[i][e.to] {
+				weights[i][e.to], prev[i][e.to] = weight, k
+			}
+		}
+		if i < 2 || weights[i][start] >= 0 {
+			continue
+		}
+
+		path := make([]common.Address, i+1)
+		pools := make([]int, i)
+		path[i] = token
+		for hop, t := i, start; hop > 0; hop-- {
+			e := edges[prev[hop][t]]
+			path[hop-1], pools[hop-1] = graph.Tokens[e.from], e.pool
+			t = e.from
+		}
+		if cycle := newCycle(graph, path, pools); cycle != nil {
+			cycles = append(cycles, cycle)
+		}
+	}
+	sort.SliceStable(cycles, func(i, j int) bool {
+		if c := cycles[i].Profit.Cmp(cycles[j].Profit); c != 0 {
+			return c > 0
+		}
+		return cycles[i].Rate.Cmp(cycles[j].Rate) > 0
+	})
+	return cycles, nil
+}
+
+func spotRate(pool Pool) *big.Rat {
+	pricer, ok := pool.(SpotPricer)
+	if !ok {
+		return nil
+	}
+	rate := pricer.SpotRate()
+	if rate == nil || rate.Sign() <= 0 {
+		return nil
+	}
+	return rate
+}
+
+// newCycle prices the walk along path exactly, nil when it visits a token twice or its rates do
+// not multiply to more than 1 after all
+func newCycle(graph *Graph, path []common.Address, pools []int) *Cycle {
+	visited := make(map[common.Address]bool)
+	for _, token := range path[1:] {
+		if visited[token] {
+			return nil
+		}
+		visited[token] = true
+	}
+	cycle := &Cycle{Path: path, Pools: pools, Rate: big.NewRat(1, 1)}
+	for i, pool := range pools {
+		cycle.Rate.Mul(cycle.Rate, spotRate(graph.Pools[Pair{path[i], path[i+1]}][pool]))
+	}
+	if cycle.Rate.Cmp(big.NewRat(1, 1)) <= 0 {
+		return nil
+	}
+	cycle.AmountIn, cycle.Profit = cycle.optimalInput(graph)
+	return cycle
+}
+
+// profit is what swapping amountIn around the cycle makes, nil when a pool cannot take it
+func (c *Cycle) profit(graph *Graph, amountIn *big.Int) *big.Int {
+	amount := amountIn
+	for i, pool := range c.Pools {
+		var ok bool
+		amount, ok = ExactIn.price(graph.Pools[Pair{c.Path[i], c.Path[i+1]}][pool], amount)
+		if !ok {
+			return nil
+		}
+	}
+	return amount.Sub(amount, amountIn)
+}
+
+// optimalInput finds the input making the most profit. For constant product pools the profit is
+// concave in the input, but rounding to whole units makes it noisy for small inputs: the most
+// profitable power of two is kept while doubling the input until the cycle loses money after
+// having paid, then a ternary search narrows down the range around it.
+func (c *Cycle) optimalInput(graph *Graph) (*big.Int, *big.Int) {
+	more := func(a, b *big.Int) bool {
+		return a != nil && (b == nil || a.Cmp(b) > 0)
+	}
+	bestAmount, bestProfit := new(big.Int), new(big.Int)
+	paid := false
+	for amount := big.NewInt(1); amount.BitLen() <= 256; amount = new(big.Int).Lsh(amount, 1) {
+		profit := c.profit(graph, amount)
+		if profit == nil {
+			continue
+		}
+		if profit.Sign() > 0 {
+			paid = true
+		} else if paid {
+			break
+		}
+		if more(profit, bestProfit) {
+			bestAmount, bestProfit = amount, profit
+		}
+	}
+	if bestProfit.Sign() == 0 {
+		return bestAmount, bestProfit
+	}
+
+	low, high := new(big.Int).Rsh(bestAmount, 1), new(big.Int).Lsh(bestAmount, 1)
+	three := big.NewInt(3)
+	for new(big.Int).Sub(high, low).Cmp(three) > 0 {
+		third := new(big.Int).Quo(new(big.Int).Sub(high, low), three)
+		m1, m2 := new(big.Int).Add(low, third), new(big.Int).Sub(high, third)
+		if more(c.profit(graph, m2), c.profit(graph, m1)) {
+			low = m1
+		} else {
+			high = m2
+		}
+	}
+	for amount := low; amount.Cmp(high) <= 0; amount = new(big.Int).Add(amount, big.NewInt(1)) {
+		if profit := c.profit(graph, amount); more(profit, bestProfit) {
+			bestAmount, bestProfit = amount, profit
+		}
+	}
+	return bestAmount, bestProfit
+}
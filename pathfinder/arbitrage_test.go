@@ -0,0 +1,88 @@
+package pathfinder
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestFindArbitrage(t *testing.T) {
+	// token 1 trades at 2 of token 0 and token 2 at 4, except in the second pool of 1/2 where it
+	// is 10% cheaper
+	graph := newGraphFake(4)
+	graph.addPool(0, 1, big.NewInt(1000000000), big.NewInt(500000000))
+	graph.addPool(1, 2, big.NewInt(1000000000), big.NewInt(500000000))
+	graph.addPool(1, 2, big.NewInt(1000000000), big.NewInt(550000000))
+	graph.addPool(0, 2, big.NewInt(2000000000), big.NewInt(500000000))
+	graph.addPool(2, 3, big.NewInt(1000000000), big.NewInt(1000000000))
+
+	cycles, err := FindArbitrage(context.Background(), &graph.Graph, token(0), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(cycles) != 1 {
+		t.Fatalf("got cycles %+v want one", cycles)
+	}
+	cycle := cycles[0]
+	// buys token 2 cheap in the second pool of 1/2 and sells it directly
+	if len(cycle.Path) != 4 || cycle.Path[0] != token(0) || cycle.Path[1] != token(1) || cycle.Path[2] != token(2) || cycle.Path[3] != token(0) || cycle.Pools[1] != 1 {
+		t.Fatalf("got cycle %v through pools %v", cycle.Path, cycle.Pools)
+	}
+	if cycle.Rate.Cmp(big.NewRat(1, 1)) <= 0 || cycle.Profit.Sign() <= 0 {
+		t.Fatalf("got rate %v profit %v want both positive", cycle.Rate, cycle.Profit)
+	}
+	result := &Result{Path: cycle.Path, Pools: cycle.Pools}
+	if out := reprice(t, &graph.Graph, ExactIn, cycle.AmountIn, result); new(big.Int).Sub(out, cycle.AmountIn).Cmp(cycle.Profit) != 0 {
+		t.Errorf("got %v for %v want a profit of %v", out, cycle.AmountIn, cycle.Profit)
+	}
+	// trading 1% more or less makes no more profit
+	for _, percent := range []int64{99, 101} {
+		amountIn := new(big.Int).Quo(new(big.Int).Mul(cycle.AmountIn, big.NewInt(percent)), big.NewInt(100))
+		if out := reprice(t, &graph.Graph, ExactIn, amountIn, result); new(big.Int).Sub(out, amountIn).Cmp(cycle.Profit) > 0 {
+			t.Errorf("got a profit of %v for %v above %v for %v", new(big.Int).Sub(out, amountIn), amountIn, cycle.Profit, cycle.AmountIn)
+		}
+	}
+
+	// the cycle takes 3 swaps, and every cycle through token 3 would visit token 2 twice
+	if cycles, err := FindArbitrage(context.Background(), &graph.Graph, token(0), 2); err != nil || len(cycles) != 0 {
+		t.Errorf("got cycles %+v error %v want none within 2 swaps", cycles, err)
+	}
+	if cycles, err := FindArbitrage(context.Background(), &graph.Graph, token(3), 5); err != nil || len(cycles) != 0 {
+		t.Errorf("got cycles %+v error %v want none through token 3", cycles, err)
+	}
+}
+
+func TestFindArbitrageBetweenPoolsOfAPair(t *testing.T) {
+	graph := newGraphFake(2)
+	graph.addPool(0, 1, big.NewInt(1000000), big.NewInt(1000000))
+	graph.addPool(0, 1, big.NewInt(1000000), big.NewInt(1100000))
+	cycles, err := FindArbitrage(context.Background(), &graph.Graph, token(1), 2)
+	if err != nil || len(cycles) != 1 || cycles[0].Pools[0] != 0 || cycles[0].Pools[1] != 1 || cycles[0].Profit.Sign() <= 0 {
+		t.Errorf("got cycles %+v error %v want buying token 0 in the first pool and selling it in the second", cycles, err)
+	}
+}
+
+func TestFindArbitrageConsistentPrices(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		// pools priced from one price per token never pay around a cycle once fees are charged
+		n := 2 + rng.Intn(5)
+		prices := make([]int64, n)
+		for t := range prices {
+			prices[t] = 1 + rng.Int63n(1000)
+		}
+		graph := newGraphFake(n)
+		for a := 0; a < n; a++ {
+			for b := a + 1; b < n; b++ {
+				for pools := rng.Intn(3); pools > 0; pools-- {
+					depth := big.NewInt(1 + rng.Int63n(1e9))
+					graph.addPool(a, b, new(big.Int).Mul(depth, big.NewInt(prices[b])), new(big.Int).Mul(depth, big.NewInt(prices[a])))
+				}
+			}
+		}
+		if cycles, err := FindArbitrage(context.Background(), &graph.Graph, token(rng.Intn(n)), 1+rng.Intn(4)); err != nil || len(cycles) != 0 {
+			t.Fatalf("got cycles %+v error %v want none", cycles, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package pathfinder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultExhaustiveMaxTokens is the largest graph Exhaustive searches unless told otherwise
+const DefaultExhaustiveMaxTokens = 16
+
+// ErrGraphTooLarge is returned by Exhaustive for graphs with more than MaxTokens tokens
+var ErrGraphTooLarge = errors.New("graph too large for an exhaustive search")
+
+// Exhaustive is a depth first search over every path of up to MaxHops swaps that visits no
+// token twice, swapping through the best pool of each pair for the amount at hand. The paths grow
+// exponentially with the hops, so it is meant for small graphs and as a reference to check other
+// algorithms against: unlike HopBounded it does not stop when a hop brings no improvement.
+type Exhaustive struct {
+	// defaults to DefaultExhaustiveMaxTokens
+	MaxTokens int
+}
+
+func (Exhaustive) Name() string {
+	return "exhaustive"
+}
+
+func (e Exhaustive) FindRoute(ctx context.Context, graph *Graph, request Request) (*Result, error) {
+	maxTokens := e.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = DefaultExhaustiveMaxTokens
+	}
+	if len(graph.Tokens) > maxTokens {
+		return nil, fmt.Errorf("%w: %v tokens, at most %v", ErrGraphTooLarge, len(graph.Tokens), maxTokens)
+	}
+	start, target := request.TokenIn, request.TokenOut
+	if request.TradeType == ExactOut {
+		start, target = target, start
+	}
+	if tokenIndex(graph.Tokens, start) < 0 || tokenIndex(graph.Tokens, target) < 0 {
+		return nil, ErrNoRoute
+	}
+
+	search := &exhaustiveSearch{ctx: ctx, graph: graph, request: request, target: target, visited: map[common.Address]bool{start: true}}
+	if err := search.walk(start, request.Amount, []common.Address{start}, []int{}); err != nil {
+		return nil, err
+	}
+	if search.best == nil {
+		return nil, ErrNoRoute
+	}
+	if request.TradeType == ExactOut {
+		reverse(search.bestPath)
+		reverse(search.bestPools)
+	}
+	return &Result{Amount: search.best, Path: search.bestPath, Pools: search.bestPools, Stats: search.stats}, nil
+}
+
+type exhaustiveSearch struct {
+	ctx     context.Context
+	graph   *Graph
+	request Request
+	target  common.Address
+	visited map[common.Address]bool
+
+	best      *big.Int
+	bestNet   *big.Int
+	bestPath  []common.Address
+	bestPools []int
+	stats     Stats
+}
+
+// walk extends path, which reached token with amount, by every pair to an unvisited token
+func (s *exhaustiveSearch) walk(token common.Address, amount *big.Int, path []common.Address, pools []int) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	tradeType := s.request.TradeType
+	if token == s.target {
+		net := tradeType.NetAmount(amount, len(pools), s.request.HopCost)
+		if s.best == nil || tradeType.Better(net, s.bestNet) {
+			s.best, s.bestNet = amount, net
+			s.bestPath = append([]common.Address{}, path...)
+			s.bestPools = append([]int{}, pools...)
+		}
+		return nil
+	}
+	if len(pools) == s.request.MaxHops {
+		return nil
+	}
+	for _, next := range s.graph.Tokens {
+		if s.visited[next] {
+			continue
+		}
+		candidates, ok := s.graph.Pools[tradeType.pair(token, next)]
+		if !ok {
+			continue
+		}
+		s.stats.PairHits++
+		var nextAmount *big.Int
+		nextPool := -1
+		for pool, hop := range candidates {
+			s.stats.EdgesEvaluated++
+			candidate, ok := tradeType.price(hop, amount)
+			if ok && (nextAmount == nil || tradeType.Better(candidate, nextAmount)) {
+				nextAmount, nextPool = candidate, pool
+			}
+		}
+		if nextAmount == nil {
+			continue
+		}
+		s.visited[next] = true
+		err := s.walk(next, nextAmount, append(path, next), append(pools, nextPool))
+		s.visited[next] = false
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package pathfinder
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HopBounded is a Bellman-Ford style search bounded by hops. Exact-in walks forward from tokenIn
+// keeping the largest output per token, exact-out walks backwards from tokenOut keeping the
+// smallest input per token; every hop extends the amounts of the previous one through every pair.
+// It stops once another hop no longer improves the result, and with a HopCost the best result of
+// every depth is ranked net of the cost of its swaps.
+type HopBounded struct {
+	// optional, saves the search after every hop and resumes from the last saved one
+	Checkpoints Checkpoints
+	// optional, called after every hop with the best amount of the target so far, nil while it
+	// is unreachable
+	OnHop func(hop int, amount *big.Int)
+}
+
+// Ref points at the token, hop and pool an amount was reached from
+type Ref struct {
+	Token common.Address
+	Hop   int
+	// index into the Graph.Pools entry of the pair
+	Pool int
+}
+
+// State is a HopBounded search after Hop hops. Amounts[i][t] is the best amount of Graph.Tokens[t]
+// within i hops of the start, nil when unreachable, and Prev[i] the Ref it was reached from.
+type State struct {
+	Hop      int
+	Amounts  [][]*big.Int
+	Prev     []map[common.Address]Ref
+	Best     *big.Int
+	BestHops int
+}
+
+// Checkpoints keeps the progress of a HopBounded search, e.g. in a file, so an interrupted deep
+// search does not start over
+type Checkpoints interface {
+	// Load returns the state to resume from, nil to start over. It is only valid for the same
+	// graph and request.
+	Load() (*State, error)
+	// Save is called after every hop, the state's layers are not modified afterwards
+	Save(state *State) error
+	// Clear is called once the search ran to the end, whether it found a route or not
+	Clear()
+}
+
+func (HopBounded) Name() string {
+	return "dp"
+}
+
+func (h HopBounded) FindRoute(ctx context.Context, graph *Graph, request Request) (*Result, error) {
+	tokens, tradeType, maxHops := graph.Tokens, request.TradeType, request.MaxHops
+	start, target := tokenIndex(tokens, request.TokenIn), tokenIndex(tokens, request.TokenOut)
+	if start < 0 || target < 0 {
+		return nil, ErrNoRoute
+	}
+	if tradeType == ExactOut {
+		start, target = target, start
+	}
+
+	amounts := make([][]*big.Int, maxHops+1)
+	prev := make([]map[common.Address]Ref, maxHops+1)
+	var best *big.Int
+	bestHops := 0
+	stats := Stats{}
+
+	firstHop := 0
+	if h.Checkpoints != nil {
+		state, err := h.Checkpoints.Load()
+		if err != nil {
+			return nil, err
+		}
+		if state != nil && state.Hop <= maxHops {
+			copy(amounts, state.Amounts[:state.Hop+1])
+			copy(prev, state.Prev[:state.Hop+1])
+			best, bestHops = state.Best, state.BestHops
+			firstHop = state.Hop + 1
+		}
+	}
+
+	for i := firstHop; i <= maxHops; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		amounts[i] = make([]*big.Int, len(tokens))
+		prev[i] = make(map[common.Address]Ref)
+		if i == 0 {
+			amounts[0][start] = new(big.Int).Set(request.Amount)
+		} else {
+			// amounts reachable with fewer hops stay reachable
+			copy(amounts[i], amounts[i-1])
+			for token, ref := range prev[i-1] {
+				prev[i][token] = ref
+			}
+			for from := range tokens {
+				for to := range tokens {
+					if from == to {
+						continue
+					}
+					fromAmount := amounts[i-1][from]
+					if fromAmount == nil {
+						stats.PrunedCandidates++
+						continue
+					}
+					pools, ok := graph.Pools[tradeType.pair(tokens[from], tokens[to])]
+					if !ok {
+						continue
+					}
+					stats.PairHits++
+					for pool, hop := range pools {
+						stats.EdgesEvaluated++
+						candidate, ok := tradeType.price(hop, fromAmount)
+						if !ok {
+							continue
+						}
+						if amounts[i][to] == nil || tradeType.Better(candidate, amounts[i][to]) {
+							amounts[i][to] = candidate
+							prev[i][tokens[to]] = Ref{Token: tokens[from], Hop: i - 1, Pool: pool}
+						}
+					}
+				}
+			}
+			if h.OnHop != nil {
+				h.OnHop(i, amounts[i][target])
+			}
+
+			if result := amounts[i][target]; result != nil {
+				// stop once another hop no longer improves the result
+				if previous := amounts[i-1][target]; previous != nil && !tradeType.Better(result, previous) {
+					break
+				}
+				if best == nil || tradeType.Better(tradeType.NetAmount(result, swapCount(prev, tokens[target], i), request.HopCost), tradeType.NetAmount(best, swapCount(prev, tokens[target], bestHops), request.HopCost)) {
+					best, bestHops = result, i
+				}
+			}
+		}
+		if h.Checkpoints != nil {
+			state := &State{Hop: i, Amounts: amounts[:i+1], Prev: prev[:i+1], Best: best, BestHops: bestHops}
+			if err := h.Checkpoints.Save(state); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if h.Checkpoints != nil {
+		h.Checkpoints.Clear()
+	}
+
+	if best == nil {
+		return nil, ErrNoRoute
+	}
+	path, pools := tracePath(prev, tokens[target], bestHops)
+	if tradeType == ExactIn {
+		reverse(path)
+		reverse(pools)
+	}
+	return &Result{Amount: best, Path: path, Pools: pools, Stats: stats}, nil
+}
+
+// tracePath walks the predecessors of token at layer hop back to the start token,
+// pools[i] is the pool used between path[i] and path[i+1]
+func tracePath(prev []map[common.Address]Ref, token common.Address, hop int) ([]common.Address, []int) {
+	path := []common.Address{token}
+	pools := []int{}
+	for {
+		ref, ok := prev[hop][token]
+		if !ok {
+			return path, pools
+		}
+		path = append(path, ref.Token)
+		pools = append(pools, ref.Pool)
+		token, hop = ref.Token, ref.Hop
+	}
+}
+
+func swapCount(prev []map[common.Address]Ref, token common.Address, hop int) int {
+	_, pools := tracePath(prev, token, hop)
+	return len(pools)
+}
+
+func tokenIndex(tokens []common.Address, token common.Address) int {
+	for i := range tokens {
+		if tokens[i] == token {
+			return i
+		}
+	}
+	return -1
+}
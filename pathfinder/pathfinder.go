@@ -0,0 +1,147 @@
+// Package pathfinder finds the best route through a snapshot of pool liquidity. The graph and the
+// pools' pricing are plain data, so route search algorithms can be swapped and tested without any
+// RPC: HopBounded is the search the router uses, Exhaustive tries every path of small graphs.
+package pathfinder
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrNoRoute is returned when tokenOut cannot be reached from tokenIn within maxHops
+var ErrNoRoute = errors.New("no route")
+
+type TradeType int
+
+const (
+	// the input amount is fixed and the output is maximized
+	ExactIn TradeType = iota
+	// the output amount is fixed and the input is minimized
+	ExactOut
+)
+
+func (t TradeType) String() string {
+	if t == ExactOut {
+		return "exactout"
+	}
+	return "exactin"
+}
+
+// Better reports whether candidate beats current for this trade type
+func (t TradeType) Better(candidate, current *big.Int) bool {
+	if t == ExactOut {
+		return candidate.Cmp(current) < 0
+	}
+	return candidate.Cmp(current) > 0
+}
+
+// Pool prices swaps through one pool of a pair, oriented in the swap direction
+type Pool interface {
+	// GetAmountOut returns the output for amountIn, an error when the pool is empty
+	GetAmountOut(amountIn *big.Int) (*big.Int, error)
+	// GetAmountIn returns the input paying amountOut, an error when the pool is too shallow
+	GetAmountIn(amountOut *big.Int) (*big.Int, error)
+}
+
+// Pair is a token pair in the swap direction
+type Pair struct {
+	TokenIn  common.Address
+	TokenOut common.Address
+}
+
+// Graph is the liquidity a search runs on
+type Graph struct {
+	Tokens []common.Address
+	// pools of every pair with liquidity, keyed and oriented by swap direction
+	Pools map[Pair][]Pool
+}
+
+// Request is the trade to route. Amount is the input of exact-in trades and the output of
+// exact-out ones.
+type Request struct {
+	TokenIn   common.Address
+	TokenOut  common.Address
+	TradeType TradeType
+	Amount    *big.Int
+	// most swaps a route may take
+	MaxHops int
+	// optional, routes are then ranked by their amount net of HopCost per swap, e.g. the gas cost
+	// of a swap in the token the caller does not fix
+	HopCost *big.Int
+}
+
+// Result is the best route found: the amount the caller does not fix, the tokens from tokenIn to
+// tokenOut and, for every swap, the index of its pool in Graph.Pools
+type Result struct {
+	Amount *big.Int
+	Path   []common.Address
+	Pools  []int
+	Stats  Stats
+}
+
+// Stats counts the work of a search
+type Stats struct {
+	// number of (input, output) hops priced
+	EdgesEvaluated int
+	// pair lookups that found pools
+	PairHits int
+	// hops skipped because the input token was not reachable yet
+	PrunedCandidates int
+}
+
+// Algorithm is a route search over a Graph
+type Algorithm interface {
+	Name() string
+	// FindRoute returns ErrNoRoute when no route within request.MaxHops prices
+	FindRoute(ctx context.Context, graph *Graph, request Request) (*Result, error)
+}
+
+// Algorithms are the algorithms of the package by name
+var Algorithms = map[string]Algorithm{
+	"dp":         HopBounded{},
+	"exhaustive": Exhaustive{},
+}
+
+// price swaps amount through pool, walking backwards from the output for exact-out trades.
+// Failed and zero amounts mean the pool cannot take the trade.
+func (t TradeType) price(pool Pool, amount *big.Int) (*big.Int, bool) {
+	var priced *big.Int
+	var err error
+	if t == ExactOut {
+		priced, err = pool.GetAmountIn(amount)
+	} else {
+		priced, err = pool.GetAmountOut(amount)
+	}
+	return priced, err == nil && priced.Sign() > 0
+}
+
+// pair is the pair swapping from to to, exact-out searches walk against the swap direction so
+// to is the token paid in
+func (t TradeType) pair(from, to common.Address) Pair {
+	if t == ExactOut {
+		return Pair{TokenIn: to, TokenOut: from}
+	}
+	return Pair{TokenIn: from, TokenOut: to}
+}
+
+// NetAmount charges hopCost for every swap of a route: exact-in receives less, exact-out pays
+// more. amount is returned as is without a hopCost.
+func (t TradeType) NetAmount(amount *big.Int, swaps int, hopCost *big.Int) *big.Int {
+	if hopCost == nil {
+		return amount
+	}
+	cost := new(big.Int).Mul(hopCost, big.NewInt(int64(swaps)))
+	if t == ExactOut {
+		return cost.Add(amount, cost)
+	}
+	return cost.Sub(amount, cost)
+}
+
+func reverse[T any](items []T) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
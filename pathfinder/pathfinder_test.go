@@ -0,0 +1,238 @@
+package pathfinder
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/v2math"
+)
+
+// constantProduct is a Uniswap V2 pool oriented in the swap direction
+type constantProduct struct {
+	reserveIn, reserveOut *big.Int
+}
+
+func (p constantProduct) GetAmountOut(amountIn *big.Int) (*big.Int, error) {
+	return v2math.GetAmountOut(amountIn, p.reserveIn, p.reserveOut, v2math.UniswapV2Fee)
+}
+
+func (p constantProduct) GetAmountIn(amountOut *big.Int) (*big.Int, error) {
+	return v2math.GetAmountIn(amountOut, p.reserveIn, p.reserveOut, v2math.UniswapV2Fee)
+}
+
+func (p constantProduct) SpotRate() *big.Rat {
+	if p.reserveIn.Sign() == 0 {
+		return nil
+	}
+	rate := new(big.Rat).SetFrac(p.reserveOut, p.reserveIn)
+	return rate.Mul(rate, big.NewRat(997, 1000))
+}
+
+func token(i int) common.Address {
+	return common.BigToAddress(big.NewInt(int64(i + 1)))
+}
+
+// graphFake builds a Graph of tokens 0 to n-1
+type graphFake struct {
+	Graph
+}
+
+func newGraphFake(n int) *graphFake {
+	g := &graphFake{Graph{Pools: make(map[Pair][]Pool)}}
+	for i := 0; i < n; i++ {
+		g.Tokens = append(g.Tokens, token(i))
+	}
+	return g
+}
+
+func (g *graphFake) addPool(a, b int, reserveA, reserveB *big.Int) {
+	forward, backward := Pair{token(a), token(b)}, Pair{token(b), token(a)}
+	g.Pools[forward] = append(g.Pools[forward], constantProduct{reserveA, reserveB})
+	g.Pools[backward] = append(g.Pools[backward], constantProduct{reserveB, reserveA})
+}
+
+// reprice swaps amount along the result's path and pools again
+func reprice(t *testing.T, graph *Graph, tradeType TradeType, amount *big.Int, result *Result) *big.Int {
+	t.Helper()
+	if len(result.Path) != len(result.Pools)+1 {
+		t.Fatalf("got path %v with pools %v", result.Path, result.Pools)
+	}
+	if tradeType == ExactOut {
+		for i := len(result.Pools) - 1; i >= 0; i-- {
+			amount, _ = graph.Pools[Pair{result.Path[i], result.Path[i+1]}][result.Pools[i]].GetAmountIn(amount)
+		}
+		return amount
+	}
+	for i, pool := range result.Pools {
+		amount, _ = graph.Pools[Pair{result.Path[i], result.Path[i+1]}][pool].GetAmountOut(amount)
+	}
+	return amount
+}
+
+func TestAlgorithmsPreferDeeperMultiHop(t *testing.T) {
+	graph := newGraphFake(3)
+	graph.addPool(0, 2, big.NewInt(100000), big.NewInt(150000000))
+	graph.addPool(0, 1, big.NewInt(1000000), big.NewInt(2000000000))
+	graph.addPool(1, 2, big.NewInt(1000000000), big.NewInt(1100000000))
+	// a second, shallower pool of the first pair
+	graph.addPool(0, 1, big.NewInt(10000), big.NewInt(20000000))
+
+	for _, algorithm := range Algorithms {
+		for _, tradeType := range []TradeType{ExactIn, ExactOut} {
+			amount := big.NewInt(1000)
+			if tradeType == ExactOut {
+				amount = big.NewInt(1000000)
+			}
+			request := Request{TokenIn: token(0), TokenOut: token(2), TradeType: tradeType, Amount: amount, MaxHops: 3}
+			result, err := algorithm.FindRoute(context.Background(), &graph.Graph, request)
+			if err != nil {
+				t.Fatalf("%v %v: got error %v", algorithm.Name(), tradeType, err)
+			}
+			if len(result.Path) != 3 || result.Path[0] != token(0) || result.Path[1] != token(1) || result.Path[2] != token(2) || result.Pools[0] != 0 {
+				t.Errorf("%v %v: got path %v pools %v want through the deep pools of token 1", algorithm.Name(), tradeType, result.Path, result.Pools)
+			}
+			if repriced := reprice(t, &graph.Graph, tradeType, amount, result); repriced.Cmp(result.Amount) != 0 {
+				t.Errorf("%v %v: got %v repricing the route want %v", algorithm.Name(), tradeType, repriced, result.Amount)
+			}
+			if result.Stats.EdgesEvaluated == 0 {
+				t.Errorf("%v %v: got no edges evaluated", algorithm.Name(), tradeType)
+			}
+		}
+	}
+}
+
+func TestExhaustiveNeverLosesToHopBounded(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		// pools priced consistently from one price per token, so no cycle pays
+		n := 3 + rng.Intn(4)
+		prices := make([]int64, n)
+		for t := range prices {
+			prices[t] = 1 + rng.Int63n(1000)
+		}
+		graph := newGraphFake(n)
+		for a := 0; a < n; a++ {
+			for b := a + 1; b < n; b++ {
+				if rng.Intn(3) == 0 {
+					continue
+				}
+				depth := big.NewInt(1 + rng.Int63n(1e9))
+				graph.addPool(a, b, new(big.Int).Mul(depth, big.NewInt(prices[b])), new(big.Int).Mul(depth, big.NewInt(prices[a])))
+			}
+		}
+		request := Request{TokenIn: token(0), TokenOut: token(n - 1), TradeType: ExactIn, Amount: big.NewInt(1 + rng.Int63n(1e9)), MaxHops: 1 + rng.Intn(4)}
+		dp, dpErr := HopBounded{}.FindRoute(context.Background(), &graph.Graph, request)
+		exhaustive, exhaustiveErr := Exhaustive{}.FindRoute(context.Background(), &graph.Graph, request)
+		if errors.Is(exhaustiveErr, ErrNoRoute) {
+			if !errors.Is(dpErr, ErrNoRoute) {
+				t.Fatalf("got dp route %+v error %v where no exhaustive route exists", dp, dpErr)
+			}
+			continue
+		}
+		if dpErr != nil || exhaustiveErr != nil {
+			t.Fatalf("got errors %v and %v", dpErr, exhaustiveErr)
+		}
+		if dp.Amount.Cmp(exhaustive.Amount) > 0 {
+			t.Fatalf("got dp %v via %v above exhaustive %v via %v", dp.Amount, dp.Path, exhaustive.Amount, exhaustive.Path)
+		}
+		if len(exhaustive.Pools) > request.MaxHops || reprice(t, &graph.Graph, ExactIn, request.Amount, exhaustive).Cmp(exhaustive.Amount) != 0 {
+			t.Fatalf("got exhaustive route %+v not within %v hops or not repricing", exhaustive, request.MaxHops)
+		}
+	}
+}
+
+// memoryCheckpoints keeps the last saved state, cancel is called after hop cancelAt is saved
+type memoryCheckpoints struct {
+	state    *State
+	cancelAt int
+	cancel   func()
+	cleared  bool
+}
+
+func (c *memoryCheckpoints) Load() (*State, error) {
+	return c.state, nil
+}
+
+func (c *memoryCheckpoints) Save(state *State) error {
+	c.state = state
+	if state.Hop == c.cancelAt && c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+func (c *memoryCheckpoints) Clear() {
+	c.state, c.cleared = nil, true
+}
+
+func TestHopBoundedResumesFromCheckpoint(t *testing.T) {
+	// only walking the chain in order doubles the amount, so every hop improves the output
+	graph := newGraphFake(6)
+	for a := 0; a < 6; a++ {
+		for b := a + 1; b < 6; b++ {
+			reserveB := big.NewInt(1000000000)
+			if b == a+1 {
+				reserveB = big.NewInt(2000000000)
+			}
+			graph.addPool(a, b, big.NewInt(1000000000), reserveB)
+		}
+	}
+	request := Request{TokenIn: token(0), TokenOut: token(5), Amount: big.NewInt(1000), MaxHops: 5}
+	want, err := HopBounded{}.FindRoute(context.Background(), &graph.Graph, request)
+	if err != nil || len(want.Path) != 6 {
+		t.Fatalf("got route %+v error %v want the whole chain", want, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	checkpoints := &memoryCheckpoints{cancelAt: 2, cancel: cancel}
+	if _, err := (HopBounded{Checkpoints: checkpoints}).FindRoute(ctx, &graph.Graph, request); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v want context.Canceled", err)
+	}
+	checkpoints.cancel = nil
+	var hops []int
+	got, err := HopBounded{Checkpoints: checkpoints, OnHop: func(hop int, amount *big.Int) { hops = append(hops, hop) }}.FindRoute(context.Background(), &graph.Graph, request)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if got.Amount.Cmp(want.Amount) != 0 || len(got.Path) != len(want.Path) || got.Stats.EdgesEvaluated >= want.Stats.EdgesEvaluated {
+		t.Errorf("got %v via %v after %v edges want %v via %v after fewer than %v", got.Amount, got.Path, got.Stats.EdgesEvaluated, want.Amount, want.Path, want.Stats.EdgesEvaluated)
+	}
+	if len(hops) != 3 || hops[0] != 3 || !checkpoints.cleared {
+		t.Errorf("got hops %v cleared %v want hops 3 to 5 and the checkpoint cleared", hops, checkpoints.cleared)
+	}
+}
+
+func TestHopCostAndErrors(t *testing.T) {
+	graph := newGraphFake(3)
+	graph.addPool(0, 2, big.NewInt(1000000), big.NewInt(1000000))
+	graph.addPool(0, 1, big.NewInt(1000000), big.NewInt(1100000))
+	graph.addPool(1, 2, big.NewInt(1000000), big.NewInt(1000000))
+
+	for _, algorithm := range Algorithms {
+		request := Request{TokenIn: token(0), TokenOut: token(2), Amount: big.NewInt(1000), MaxHops: 2}
+		result, err := algorithm.FindRoute(context.Background(), &graph.Graph, request)
+		if err != nil || len(result.Pools) != 2 {
+			t.Errorf("%v: got route %+v error %v want the two hop route paying more", algorithm.Name(), result, err)
+		}
+		// the second swap costs more than the two hop route gains
+		request.HopCost = big.NewInt(200)
+		result, err = algorithm.FindRoute(context.Background(), &graph.Graph, request)
+		if err != nil || len(result.Pools) != 1 {
+			t.Errorf("%v: got route %+v error %v want the direct route net of the hop cost", algorithm.Name(), result, err)
+		}
+
+		request = Request{TokenIn: token(0), TokenOut: common.HexToAddress("0x1234"), Amount: big.NewInt(1000), MaxHops: 2}
+		if _, err := algorithm.FindRoute(context.Background(), &graph.Graph, request); !errors.Is(err, ErrNoRoute) {
+			t.Errorf("%v: got error %v want ErrNoRoute for a token outside the graph", algorithm.Name(), err)
+		}
+	}
+
+	if _, err := (Exhaustive{MaxTokens: 2}).FindRoute(context.Background(), &graph.Graph, Request{TokenIn: token(0), TokenOut: token(2), Amount: big.NewInt(1000), MaxHops: 2}); !errors.Is(err, ErrGraphTooLarge) {
+		t.Errorf("got error %v want ErrGraphTooLarge", err)
+	}
+}
@@ -0,0 +1,203 @@
+package pathfinder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// errPoolBlocked prices the pools TopK takes out of a deviation's search
+var errPoolBlocked = errors.New("pool blocked")
+
+// TopK returns up to k routes that differ in their tokens or pools, best first, with Yen's
+// algorithm: the first route is the best algorithm finds, every further one is the best deviation
+// from a route found before. A deviation keeps the first swaps of a route and searches the rest
+// with the tokens of those swaps removed, so it takes no cycle, and with the next pool of every
+// route found so far that shares them blocked, so it is new. Fewer than k routes are returned when
+// no more exist within request.MaxHops, ErrNoRoute when none does.
+func TopK(ctx context.Context, algorithm Algorithm, graph *Graph, request Request, k int) ([]*Result, error) {
+	if k < 1 {
+		return nil, errors.New("k must be at least 1")
+	}
+	tradeType := request.TradeType
+	best, err := algorithm.FindRoute(ctx, graph, request)
+	if err != nil {
+		return nil, err
+	}
+	found := []route{searchOrder(tradeType, best)}
+	seen := map[string]bool{found[0].key(): true}
+	candidates := []route{}
+	for len(found) < k {
+		last := found[len(found)-1]
+		for i := range last.pools {
+			deviation, err := deviate(ctx, algorithm, graph, request, found, last, i)
+			if errors.Is(err, ErrNoRoute) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if deviation != nil && !seen[deviation.key()] {
+				seen[deviation.key()] = true
+				candidates = append(candidates, *deviation)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		next := 0
+		for i := range candidates {
+			if tradeType.Better(candidates[i].net(request), candidates[next].net(request)) {
+				next = i
+			}
+		}
+		found = append(found, candidates[next])
+		candidates = append(candidates[:next], candidates[next+1:]...)
+	}
+
+	// a deviation can beat the route it left when algorithm does not search exhaustively
+	sort.SliceStable(found, func(i, j int) bool {
+		return tradeType.Better(found[i].net(request), found[j].net(request))
+	})
+	results := make([]*Result, len(found))
+	for i := range found {
+		results[i] = found[i].result(tradeType)
+	}
+	return results, nil
+}
+
+// route is a route in search order, from the token of the fixed amount: exact-out routes run from
+// tokenOut back to tokenIn. pools[i] is the pool between tokens[i] and tokens[i+1].
+type route struct {
+	tokens []common.Address
+	pools  []int
+	amount *big.Int
+	stats  Stats
+}
+
+func searchOrder(tradeType TradeType, result *Result) route {
+	r := route{tokens: append([]common.Address{}, result.Path...), pools: append([]int{}, result.Pools...), amount: result.Amount, stats: result.Stats}
+	if tradeType == ExactOut {
+		reverse(r.tokens)
+		reverse(r.pools)
+	}
+	return r
+}
+
+func (r route) result(tradeType TradeType) *Result {
+	result := &Result{Amount: r.amount, Path: append([]common.Address{}, r.tokens...), Pools: append([]int{}, r.pools...), Stats: r.stats}
+	if tradeType == ExactOut {
+		reverse(result.Path)
+		reverse(result.Pools)
+	}
+	return result
+}
+
+func (r route) key() string {
+	return fmt.Sprint(r.tokens, r.pools)
+}
+
+func (r route) net(request Request) *big.Int {
+	return request.TradeType.NetAmount(r.amount, len(r.pools), request.HopCost)
+}
+
+// sharesRoot reports whether r takes the same first swaps as other up to other's token i
+func (r route) sharesRoot(other route, i int) bool {
+	if len(r.tokens) <= i {
+		return false
+	}
+	for j := 0; j <= i; j++ {
+		if r.tokens[j] != other.tokens[j] || (j < i && r.pools[j] != other.pools[j]) {
+			return false
+		}
+	}
+	return true
+}
+
+// deviate returns the best route that takes the first i swaps of last and leaves it at its token
+// i through a pool no found route sharing those swaps takes next, nil when that route has a cycle
+func deviate(ctx context.Context, algorithm Algorithm, graph *Graph, request Request, found []route, last route, i int) (*route, error) {
+	tradeType := request.TradeType
+	amount := request.Amount
+	for j := 0; j < i; j++ {
+		var ok bool
+		amount, ok = tradeType.price(graph.Pools[tradeType.pair(last.tokens[j], last.tokens[j+1])][last.pools[j]], amount)
+		if !ok {
+			return nil, ErrNoRoute
+		}
+	}
+	removed := make(map[common.Address]bool)
+	for _, token := range last.tokens[:i] {
+		removed[token] = true
+	}
+	blocked := make(map[Pair]map[int]bool)
+	for _, r := range found {
+		if len(r.pools) <= i || !r.sharesRoot(last, i) {
+			continue
+		}
+		pair := tradeType.pair(r.tokens[i], r.tokens[i+1])
+		if blocked[pair] == nil {
+			blocked[pair] = make(map[int]bool)
+		}
+		blocked[pair][r.pools[i]] = true
+	}
+
+	spurRequest := Request{TokenIn: last.tokens[i], TokenOut: request.TokenOut, TradeType: tradeType, Amount: amount, MaxHops: request.MaxHops - i, HopCost: request.HopCost}
+	if tradeType == ExactOut {
+		spurRequest.TokenIn, spurRequest.TokenOut = request.TokenIn, last.tokens[i]
+	}
+	result, err := algorithm.FindRoute(ctx, restrict(graph, removed, blocked), spurRequest)
+	if err != nil {
+		return nil, err
+	}
+	spur := searchOrder(tradeType, result)
+	deviation := route{
+		tokens: append(append([]common.Address{}, last.tokens[:i]...), spur.tokens...),
+		pools:  append(append([]int{}, last.pools[:i]...), spur.pools...),
+		amount: spur.amount,
+		stats:  spur.stats,
+	}
+	visited := make(map[common.Address]bool)
+	for _, token := range deviation.tokens {
+		if visited[token] {
+			return nil, nil
+		}
+		visited[token] = true
+	}
+	return &deviation, nil
+}
+
+// restrict is graph without the removed tokens and with the blocked pools failing to price, so
+// pool indices stay valid
+func restrict(graph *Graph, removed map[common.Address]bool, blocked map[Pair]map[int]bool) *Graph {
+	restricted := &Graph{Pools: make(map[Pair][]Pool, len(graph.Pools))}
+	for _, token := range graph.Tokens {
+		if !removed[token] {
+			restricted.Tokens = append(restricted.Tokens, token)
+		}
+	}
+	for pair, pools := range graph.Pools {
+		if indices := blocked[pair]; len(indices) > 0 {
+			pools = append([]Pool{}, pools...)
+			for index := range indices {
+				pools[index] = blockedPool{}
+			}
+		}
+		restricted.Pools[pair] = pools
+	}
+	return restricted
+}
+
+type blockedPool struct{}
+
+func (blockedPool) GetAmountOut(amountIn *big.Int) (*big.Int, error) {
+	return nil, errPoolBlocked
+}
+
+func (blockedPool) GetAmountIn(amountOut *big.Int) (*big.Int, error) {
+	return nil, errPoolBlocked
+}
@@ -0,0 +1,135 @@
+package pathfinder
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// allRoutes prices every simple path of up to maxHops swaps through every combination of pools
+func allRoutes(graph *graphFake, tradeType TradeType, start, target int, amount *big.Int, maxHops int) []*big.Int {
+	amounts := []*big.Int{}
+	visited := map[int]bool{start: true}
+	var walk func(from int, amount *big.Int, hops int)
+	walk = func(from int, amount *big.Int, hops int) {
+		if from == target {
+			amounts = append(amounts, amount)
+			return
+		}
+		if hops == maxHops {
+			return
+		}
+		for next := range graph.Tokens {
+			if visited[next] {
+				continue
+			}
+			for _, pool := range graph.Pools[tradeType.pair(token(from), token(next))] {
+				nextAmount, ok := tradeType.price(pool, amount)
+				if !ok {
+					continue
+				}
+				visited[next] = true
+				walk(next, nextAmount, hops+1)
+				visited[next] = false
+			}
+		}
+	}
+	walk(start, amount, 0)
+	sort.Slice(amounts, func(i, j int) bool {
+		return tradeType.Better(amounts[i], amounts[j])
+	})
+	return amounts
+}
+
+func TestTopKMatchesEveryRoute(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		n := 3 + rng.Intn(3)
+		prices := make([]int64, n)
+		for t := range prices {
+			prices[t] = 1 + rng.Int63n(1000)
+		}
+		graph := newGraphFake(n)
+		for a := 0; a < n; a++ {
+			for b := a + 1; b < n; b++ {
+				// up to two pools per pair, priced apart so the second pool is a real alternative
+				for pools := rng.Intn(3); pools > 0; pools-- {
+					depth := big.NewInt(1 + rng.Int63n(1e9))
+					skew := 90 + rng.Int63n(20)
+					graph.addPool(a, b, new(big.Int).Mul(depth, big.NewInt(prices[b]*100)), new(big.Int).Mul(depth, big.NewInt(prices[a]*skew)))
+				}
+			}
+		}
+		tradeType := []TradeType{ExactIn, ExactOut}[rng.Intn(2)]
+		request := Request{TokenIn: token(0), TokenOut: token(n - 1), TradeType: tradeType, Amount: big.NewInt(1 + rng.Int63n(1e6)), MaxHops: 1 + rng.Intn(3)}
+		start, target := 0, n-1
+		if tradeType == ExactOut {
+			start, target = target, start
+		}
+		want := allRoutes(graph, tradeType, start, target, request.Amount, request.MaxHops)
+		k := 1 + rng.Intn(6)
+
+		// an exact search per deviation makes Yen's algorithm exact as well
+		got, err := TopK(context.Background(), Exhaustive{}, &graph.Graph, request, k)
+		if len(want) == 0 {
+			if !errors.Is(err, ErrNoRoute) {
+				t.Fatalf("got routes %v error %v want ErrNoRoute", got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if len(want) > k {
+			want = want[:k]
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %v routes want %v", len(got), len(want))
+		}
+		seen := make(map[string]bool)
+		for j, result := range got {
+			if result.Amount.Cmp(want[j]) != 0 {
+				t.Fatalf("%v route %v: got %v want %v", tradeType, j, result.Amount, want[j])
+			}
+			if repriced := reprice(t, &graph.Graph, tradeType, request.Amount, result); repriced.Cmp(result.Amount) != 0 || result.Path[0] != request.TokenIn || result.Path[len(result.Path)-1] != request.TokenOut {
+				t.Fatalf("got route %+v repricing to %v", result, repriced)
+			}
+			key := route{tokens: result.Path, pools: result.Pools}.key()
+			if seen[key] {
+				t.Fatalf("got route %v twice", key)
+			}
+			seen[key] = true
+		}
+
+		// the router's search is not exact once pools are priced apart, but its routes still price
+		// and come best first
+		dp, err := TopK(context.Background(), HopBounded{}, &graph.Graph, request, k)
+		if err != nil || len(dp) == 0 || len(dp) > k {
+			t.Fatalf("got %v dp routes error %v", len(dp), err)
+		}
+		for j, result := range dp {
+			if repriced := reprice(t, &graph.Graph, tradeType, request.Amount, result); repriced.Cmp(result.Amount) != 0 {
+				t.Fatalf("got dp route %+v repricing to %v", result, repriced)
+			}
+			if j > 0 && tradeType.Better(result.Amount, dp[j-1].Amount) {
+				t.Fatalf("got dp route %v better than the one before", j)
+			}
+		}
+	}
+}
+
+func TestTopKDistinguishesPools(t *testing.T) {
+	graph := newGraphFake(2)
+	graph.addPool(0, 1, big.NewInt(1000000), big.NewInt(2000000))
+	graph.addPool(0, 1, big.NewInt(1000000), big.NewInt(3000000))
+	results, err := TopK(context.Background(), HopBounded{}, &graph.Graph, Request{TokenIn: token(0), TokenOut: token(1), Amount: big.NewInt(1000), MaxHops: 2}, 5)
+	if err != nil || len(results) != 2 || results[0].Pools[0] != 1 || results[1].Pools[0] != 0 {
+		t.Fatalf("got results %+v error %v want the deeper priced pool, then the other", results, err)
+	}
+	if _, err := TopK(context.Background(), HopBounded{}, &graph.Graph, Request{TokenIn: token(0), TokenOut: token(1), Amount: big.NewInt(1000), MaxHops: 2}, 0); err == nil {
+		t.Errorf("got no error for k 0")
+	}
+}
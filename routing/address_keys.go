@@ -0,0 +1,44 @@
+package routing
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pairKey identifies a directed hop between two tokens. Map keys are always built from
+// common.Address values, never from hex strings, so mixed-case input cannot cause cache misses.
+type pairKey struct {
+	tokenIn  common.Address
+	tokenOut common.Address
+}
+
+func newPairKey(tokenIn, tokenOut common.Address) pairKey {
+	return pairKey{tokenIn: tokenIn, tokenOut: tokenOut}
+}
+
+// ParseAddress accepts an address in any letter case and returns its canonical form
+func ParseAddress(input string) (common.Address, error) {
+	input = strings.TrimSpace(input)
+	if !common.IsHexAddress(input) {
+		return common.Address{}, fmt.Errorf("invalid address %q", input)
+	}
+	return common.HexToAddress(input), nil
+}
+
+// AddressKey returns the canonical lowercase form of an address, for keys that must be strings
+// (file names, JSON object keys)
+func AddressKey(address common.Address) string {
+	return strings.ToLower(address.Hex())
+}
+
+// sortAddresses orders addresses by their bytes, so searches over them break ties the same way
+// whatever order the providers returned them in
+func sortAddresses(addresses []common.Address) {
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+}
@@ -0,0 +1,59 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseAddressIgnoresCase(t *testing.T) {
+	lower, err := ParseAddress(strings.ToLower(WETH))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	upper, err := ParseAddress("0x" + strings.ToUpper(WETH[2:]))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if lower != upper {
+		t.Errorf("got %v and %v for the same address", lower, upper)
+	}
+	if AddressKey(lower) != strings.ToLower(WETH) {
+		t.Errorf("got key %v want %v", AddressKey(lower), strings.ToLower(WETH))
+	}
+	if _, err := ParseAddress("0x1234"); err == nil {
+		t.Errorf("expected error for a short address")
+	}
+}
+
+func TestRouteWithMixedCaseInput(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(mustParseAddress(t, WETH), mustParseAddress(t, USDC), 100, 200000)
+	graph.addPool(mustParseAddress(t, USDC), mustParseAddress(t, DAI), 1000, 1100)
+	router := newFakeRouter(graph)
+
+	tokenIn := mustParseAddress(t, "0x"+strings.ToUpper(WETH[2:]))
+	tokenOut := mustParseAddress(t, strings.ToLower(DAI))
+	_, path, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(10), tokenIn, tokenOut, 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// the inputs must be recognized as tokens already in the graph, not added twice
+	if metadata.TokensConsidered != 3 {
+		t.Errorf("got %d tokens considered want 3", metadata.TokensConsidered)
+	}
+	if len(path) != 3 {
+		t.Errorf("got path %v want WETH -> USDC -> DAI", path)
+	}
+}
+
+func mustParseAddress(t *testing.T, input string) common.Address {
+	address, err := ParseAddress(input)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	return address
+}
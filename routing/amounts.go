@@ -0,0 +1,130 @@
+package routing
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/v2math"
+)
+
+// Uniswap V2 charges 0.3% on the input amount, expressed as 997/1000
+var (
+	feeNumerator   = big.NewInt(997)
+	feeDenominator = big.NewInt(1000)
+)
+
+// hopReserves holds the reserves of a pair oriented in the direction of the swap, it is the
+// pathfinder.Pool and SpotPricer the route search and arbitrage detection price hops with
+type hopReserves struct {
+	reserveIn  *big.Int
+	reserveOut *big.Int
+	pair       common.Address
+	// nil for Uniswap V2 math
+	dex DEXAdapter
+}
+
+func (h hopReserves) GetAmountOut(amountIn *big.Int) (*big.Int, error) {
+	if h.dex != nil {
+		return h.dex.GetAmountOut(amountIn, h.reserveIn, h.reserveOut)
+	}
+	return getAmountOut(amountIn, h.reserveIn, h.reserveOut)
+}
+
+func (h hopReserves) GetAmountIn(amountOut *big.Int) (*big.Int, error) {
+	if h.dex != nil {
+		return h.dex.GetAmountIn(amountOut, h.reserveIn, h.reserveOut)
+	}
+	return getAmountIn(amountOut, h.reserveIn, h.reserveOut)
+}
+
+// SpotRate is the output per unit of input of an infinitesimal swap net of the fee, nil for an
+// empty pool
+func (h hopReserves) SpotRate() *big.Rat {
+	if h.reserveIn.Sign() == 0 {
+		return nil
+	}
+	rate := new(big.Rat).SetFrac(h.reserveOut, h.reserveIn)
+	return rate.Mul(rate, new(big.Rat).Sub(big.NewRat(1, 1), h.fee()))
+}
+
+// fee returns the share of the input kept by the pool as a fraction of one
+func (h hopReserves) fee() *big.Rat {
+	if h.dex != nil {
+		return h.dex.Fee()
+	}
+	return new(big.Rat).SetFrac(new(big.Int).Sub(feeDenominator, feeNumerator), feeDenominator)
+}
+
+// getAmountOut mirrors UniswapV2Library.getAmountOut, the division truncates so the
+// result is never more than the pair would actually pay
+func getAmountOut(amountIn, reserveIn, reserveOut *big.Int) (*big.Int, error) {
+	return getAmountOutWithFee(amountIn, reserveIn, reserveOut, feeNumerator, feeDenominator)
+}
+
+// getAmountOutWithFee is getAmountOut for forks charging feeDenominator-feeNumerator per feeDenominator
+func getAmountOutWithFee(amountIn, reserveIn, reserveOut, feeNumerator, feeDenominator *big.Int) (*big.Int, error) {
+	return v2math.GetAmountOut(amountIn, reserveIn, reserveOut, v2math.Fee{Numerator: feeNumerator, Denominator: feeDenominator})
+}
+
+// getAmountIn mirrors UniswapV2Library.getAmountIn, the result is rounded up so paying
+// it always yields at least amountOut
+func getAmountIn(amountOut, reserveIn, reserveOut *big.Int) (*big.Int, error) {
+	return getAmountInWithFee(amountOut, reserveIn, reserveOut, feeNumerator, feeDenominator)
+}
+
+// getAmountInWithFee is getAmountIn for forks charging feeDenominator-feeNumerator per feeDenominator
+func getAmountInWithFee(amountOut, reserveIn, reserveOut, feeNumerator, feeDenominator *big.Int) (*big.Int, error) {
+	return v2math.GetAmountIn(amountOut, reserveIn, reserveOut, v2math.Fee{Numerator: feeNumerator, Denominator: feeDenominator})
+}
+
+// getAmountsOut prices an exact-in trade hop by hop, rounding every intermediate amount down.
+// amounts[0] is amountIn and amounts[len(hops)] is the final output.
+func getAmountsOut(amountIn *big.Int, hops []hopReserves) ([]*big.Int, error) {
+	amounts := make([]*big.Int, len(hops)+1)
+	amounts[0] = new(big.Int).Set(amountIn)
+	for i, hop := range hops {
+		amountOut, err := hop.GetAmountOut(amounts[i])
+		if err != nil {
+			return nil, err
+		}
+		amounts[i+1] = amountOut
+	}
+	return amounts, nil
+}
+
+// getAmountsIn prices an exact-out trade backwards from the last hop, rounding every
+// intermediate amount up. amounts[0] is the required input and amounts[len(hops)] is amountOut.
+func getAmountsIn(amountOut *big.Int, hops []hopReserves) ([]*big.Int, error) {
+	amounts := make([]*big.Int, len(hops)+1)
+	amounts[len(hops)] = new(big.Int).Set(amountOut)
+	for i := len(hops) - 1; i >= 0; i-- {
+		amountIn, err := hops[i].GetAmountIn(amounts[i+1])
+		if err != nil {
+			return nil, err
+		}
+		amounts[i] = amountIn
+	}
+	return amounts, nil
+}
+
+// pathPriceImpact returns the share of output lost to trade size along hops, ignoring the
+// LP fee and integer rounding so it grows strictly with amountIn: 1 - Π reserveIn/(reserveIn + amountIn')
+// where amountIn' is the fee-adjusted amount entering each hop
+func pathPriceImpact(amountIn *big.Int, hops []hopReserves) *big.Rat {
+	if amountIn.Sign() <= 0 {
+		return new(big.Rat)
+	}
+	retained := big.NewRat(1, 1)
+	amount := new(big.Rat).SetInt(amountIn)
+	for _, hop := range hops {
+		fee := new(big.Rat).Sub(big.NewRat(1, 1), hop.fee())
+		reserveIn := new(big.Rat).SetInt(hop.reserveIn)
+		reserveOut := new(big.Rat).SetInt(hop.reserveOut)
+		amountWithFee := new(big.Rat).Mul(amount, fee)
+		denominator := new(big.Rat).Add(reserveIn, amountWithFee)
+		retained.Mul(retained, new(big.Rat).Quo(reserveIn, denominator))
+		amount = new(big.Rat).Quo(new(big.Rat).Mul(amountWithFee, reserveOut), denominator)
+	}
+	return new(big.Rat).Sub(big.NewRat(1, 1), retained)
+}
@@ -0,0 +1,106 @@
+package routing
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// pairAccepts reproduces the K invariant check in UniswapV2Pair.swap
+func pairAccepts(amountIn, amountOut, reserveIn, reserveOut *big.Int) bool {
+	if amountOut.Cmp(reserveOut) >= 0 {
+		return false
+	}
+	balanceIn := new(big.Int).Add(reserveIn, amountIn)
+	balanceOut := new(big.Int).Sub(reserveOut, amountOut)
+	balanceInAdjusted := new(big.Int).Sub(new(big.Int).Mul(balanceIn, big.NewInt(1000)), new(big.Int).Mul(amountIn, big.NewInt(3)))
+	balanceOutAdjusted := new(big.Int).Mul(balanceOut, big.NewInt(1000))
+	k := new(big.Int).Mul(new(big.Int).Mul(reserveIn, reserveOut), big.NewInt(1000000))
+	return new(big.Int).Mul(balanceInAdjusted, balanceOutAdjusted).Cmp(k) >= 0
+}
+
+func randomAmount(rng *rand.Rand, maxDigits int) *big.Int {
+	digits := 1 + rng.Intn(maxDigits)
+	amount := big.NewInt(int64(1 + rng.Intn(9)))
+	for i := 1; i < digits; i++ {
+		amount.Mul(amount, big.NewInt(10))
+		amount.Add(amount, big.NewInt(int64(rng.Intn(10))))
+	}
+	return amount
+}
+
+func TestGetAmountOutNeverExceedsChain(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		reserveIn, reserveOut, amountIn := randomAmount(rng, 30), randomAmount(rng, 30), randomAmount(rng, 30)
+		amountOut, err := getAmountOut(amountIn, reserveIn, reserveOut)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if amountOut.Sign() > 0 && !pairAccepts(amountIn, amountOut, reserveIn, reserveOut) {
+			t.Fatalf("pair rejects %d out for %d in with reserves %d/%d", amountOut, amountIn, reserveIn, reserveOut)
+		}
+		// rounding down must be tight, one more wei of output breaks the invariant
+		if pairAccepts(amountIn, new(big.Int).Add(amountOut, big.NewInt(1)), reserveIn, reserveOut) {
+			t.Fatalf("%d out for %d in with reserves %d/%d is not rounded down tightly", amountOut, amountIn, reserveIn, reserveOut)
+		}
+	}
+}
+
+func TestGetAmountInAlwaysCoversOutput(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 2000; i++ {
+		reserveIn, reserveOut := randomAmount(rng, 30), randomAmount(rng, 30)
+		amountOut := new(big.Int).Rand(rng, reserveOut)
+		if amountOut.Sign() == 0 {
+			continue
+		}
+		amountIn, err := getAmountIn(amountOut, reserveIn, reserveOut)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if !pairAccepts(amountIn, amountOut, reserveIn, reserveOut) {
+			t.Fatalf("pair rejects %d in for %d out with reserves %d/%d", amountIn, amountOut, reserveIn, reserveOut)
+		}
+	}
+
+	if _, err := getAmountIn(big.NewInt(100), big.NewInt(100), big.NewInt(100)); err == nil {
+		t.Errorf("expected error when draining the whole pool")
+	}
+}
+
+func TestMultiHopRounding(t *testing.T) {
+	hops := []hopReserves{
+		{reserveIn: big.NewInt(1000000007), reserveOut: big.NewInt(3000000011)},
+		{reserveIn: big.NewInt(5000000003), reserveOut: big.NewInt(700000001)},
+		{reserveIn: big.NewInt(900000013), reserveOut: big.NewInt(40000000009)},
+	}
+
+	amountsOut, err := getAmountsOut(big.NewInt(123456789), hops)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	for i, hop := range hops {
+		if !pairAccepts(amountsOut[i], amountsOut[i+1], hop.reserveIn, hop.reserveOut) {
+			t.Errorf("exact-in hop %d pays more than the pair allows", i)
+		}
+	}
+
+	amountsIn, err := getAmountsIn(amountsOut[len(hops)], hops)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	for i, hop := range hops {
+		if !pairAccepts(amountsIn[i], amountsIn[i+1], hop.reserveIn, hop.reserveOut) {
+			t.Errorf("exact-out hop %d requires less input than the pair allows", i)
+		}
+	}
+	// feeding the exact-out input through the exact-in math must deliver at least the requested output
+	roundTrip, err := getAmountsOut(amountsIn[0], hops)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if roundTrip[len(hops)].Cmp(amountsOut[len(hops)]) < 0 {
+		t.Errorf("got %d out want at least %d", roundTrip[len(hops)], amountsOut[len(hops)])
+	}
+}
@@ -0,0 +1,139 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// V2 pairs are ERC20 tokens themselves, so their ABI covers allowance and approve
+var erc20Parsed = mustParseABI(MainABI)
+
+type allowanceKey struct {
+	token common.Address
+	owner common.Address
+}
+
+// ApprovalManager makes sure the router may spend the tokens a swap sells. Allowances are read
+// once per token and owner and then kept up to date with the approvals sent through the manager.
+type ApprovalManager struct {
+	caller   bind.ContractCaller
+	executor *Executor
+	spender  common.Address
+	// approve the maximum uint256 instead of the amount needed, saving an approval per swap
+	infinite bool
+
+	mu         sync.Mutex
+	allowances map[allowanceKey]*big.Int
+}
+
+// NewApprovalManager manages allowances to spender, usually ROUTER02_ADDRESS. executor sends the
+// approvals and may be nil when they are only built.
+func NewApprovalManager(caller bind.ContractCaller, executor *Executor, spender common.Address, infinite bool) *ApprovalManager {
+	return &ApprovalManager{
+		caller:     caller,
+		executor:   executor,
+		spender:    spender,
+		infinite:   infinite,
+		allowances: make(map[allowanceKey]*big.Int),
+	}
+}
+
+// Allowance returns how much of token the spender may move for owner
+func (m *ApprovalManager) Allowance(ctx context.Context, token, owner common.Address) (*big.Int, error) {
+	key := allowanceKey{token: token, owner: owner}
+	m.mu.Lock()
+	allowance, ok := m.allowances[key]
+	m.mu.Unlock()
+	if ok {
+		return new(big.Int).Set(allowance), nil
+	}
+	caller, err := NewMainCaller(token, m.caller)
+	if err != nil {
+		return nil, err
+	}
+	allowance, err = caller.Allowance(newCallOpts(ctx), owner, m.spender)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.allowances[key] = allowance
+	m.mu.Unlock()
+	return new(big.Int).Set(allowance), nil
+}
+
+// BuildApprove returns the approve call letting the spender move amount of token, or the maximum
+// when the manager approves infinitely
+func (m *ApprovalManager) BuildApprove(token common.Address, amount *big.Int) (*SwapTx, error) {
+	data, err := erc20Parsed.Pack("approve", m.spender, m.approvalAmount(amount))
+	if err != nil {
+		return nil, err
+	}
+	return &SwapTx{To: token, Data: data, Value: new(big.Int), Method: "approve"}, nil
+}
+
+func (m *ApprovalManager) approvalAmount(amount *big.Int) *big.Int {
+	if m.infinite {
+		return math.MaxBig256
+	}
+	return amount
+}
+
+// EnsureAllowance sends an approval from the executor's account when its allowance of token is
+// below amount. It returns the hash of the approval, or the zero hash when none was needed.
+// The approval should be mined before swapping, see WaitForReceipt.
+func (m *ApprovalManager) EnsureAllowance(ctx context.Context, token common.Address, amount *big.Int) (common.Hash, error) {
+	if m.executor == nil {
+		return common.Hash{}, errors.New("approval manager has no executor to send approvals")
+	}
+	owner := m.executor.Address()
+	allowance, err := m.Allowance(ctx, token, owner)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if allowance.Cmp(amount) >= 0 {
+		return common.Hash{}, nil
+	}
+	approve, err := m.BuildApprove(token, amount)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	hash, err := m.executor.send(ctx, approve)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// approve replaces the allowance rather than adding to it
+	m.mu.Lock()
+	m.allowances[allowanceKey{token: token, owner: owner}] = m.approvalAmount(amount)
+	m.mu.Unlock()
+	return hash, nil
+}
+
+// Spent lowers the cached allowance after a swap sold amount of token, tokens keep infinite
+// allowances untouched
+func (m *ApprovalManager) Spent(token, owner common.Address, amount *big.Int) {
+	key := allowanceKey{token: token, owner: owner}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	allowance, ok := m.allowances[key]
+	if !ok || allowance.Cmp(math.MaxBig256) == 0 {
+		return
+	}
+	if allowance.Cmp(amount) <= 0 {
+		m.allowances[key] = new(big.Int)
+		return
+	}
+	m.allowances[key] = new(big.Int).Sub(allowance, amount)
+}
+
+// Invalidate drops the cached allowance, e.g. after approving outside the manager
+func (m *ApprovalManager) Invalidate(token, owner common.Address) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.allowances, allowanceKey{token: token, owner: owner})
+}
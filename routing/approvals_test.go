@@ -0,0 +1,95 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// allowanceFake answers allowance calls from a fixed table
+type allowanceFake struct {
+	allowances map[allowanceKey]*big.Int
+	calls      int
+}
+
+func (f *allowanceFake) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (f *allowanceFake) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	f.calls++
+	method := erc20Parsed.Methods["allowance"]
+	if string(call.Data[:4]) != string(method.ID) {
+		return nil, errors.New("execution reverted")
+	}
+	args, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	allowance, ok := f.allowances[allowanceKey{token: *call.To, owner: args[0].(common.Address)}]
+	if !ok {
+		allowance = new(big.Int)
+	}
+	return method.Outputs.Pack(allowance)
+}
+
+func TestApprovalManager(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	backend := &transactionBackendFake{}
+	executor := NewExecutor(backend, signer, NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil))
+	tokens := &allowanceFake{allowances: map[allowanceKey]*big.Int{
+		{token: weth, owner: signer.Address()}: big.NewInt(5000),
+	}}
+	manager := NewApprovalManager(tokens, executor, common.HexToAddress(ROUTER02_ADDRESS), false)
+
+	// enough allowance, nothing is sent and the allowance is only read once
+	for i := 0; i < 2; i++ {
+		hash, err := manager.EnsureAllowance(context.Background(), weth, big.NewInt(1000))
+		if err != nil || hash != (common.Hash{}) {
+			t.Fatalf("got hash %v error %v want no approval", hash, err)
+		}
+	}
+	if tokens.calls != 1 || len(backend.sent) != 0 {
+		t.Errorf("got %v allowance reads and %v transactions want 1 and 0", tokens.calls, len(backend.sent))
+	}
+
+	manager.Spent(weth, signer.Address(), big.NewInt(4500))
+	hash, err := manager.EnsureAllowance(context.Background(), weth, big.NewInt(1000))
+	if err != nil || len(backend.sent) != 1 || backend.sent[0].Hash() != hash {
+		t.Fatalf("got hash %v error %v after spending the allowance want an approval", hash, err)
+	}
+	approve := backend.sent[0]
+	args, err := erc20Parsed.Methods["approve"].Inputs.Unpack(approve.Data()[4:])
+	if err != nil || *approve.To() != weth || args[0].(common.Address) != common.HexToAddress(ROUTER02_ADDRESS) || args[1].(*big.Int).Int64() != 1000 {
+		t.Errorf("got approve to %v args %v error %v want the router approved for 1000 WETH", approve.To(), args, err)
+	}
+	if allowance, _ := manager.Allowance(context.Background(), weth, signer.Address()); allowance.Int64() != 1000 {
+		t.Errorf("got cached allowance %v want the approved 1000", allowance)
+	}
+
+	infinite := NewApprovalManager(tokens, executor, common.HexToAddress(ROUTER02_ADDRESS), true)
+	if _, err := infinite.EnsureAllowance(context.Background(), usdc, big.NewInt(1)); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	args, _ = erc20Parsed.Methods["approve"].Inputs.Unpack(backend.sent[1].Data()[4:])
+	if args[1].(*big.Int).Cmp(math.MaxBig256) != 0 {
+		t.Errorf("got approval of %v want the maximum", args[1])
+	}
+	infinite.Spent(usdc, signer.Address(), big.NewInt(1000))
+	if allowance, _ := infinite.Allowance(context.Background(), usdc, signer.Address()); allowance.Cmp(math.MaxBig256) != 0 {
+		t.Errorf("got allowance %v after a swap want the infinite allowance untouched", allowance)
+	}
+
+	infinite.Invalidate(usdc, signer.Address())
+	if allowance, _ := infinite.Allowance(context.Background(), usdc, signer.Address()); allowance.Sign() != 0 {
+		t.Errorf("got allowance %v after invalidating want the on-chain 0", allowance)
+	}
+}
@@ -0,0 +1,64 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/pathfinder"
+)
+
+// ArbitrageCycle is a cycle of swaps from Token back to itself that pays more than it takes
+type ArbitrageCycle struct {
+	Token common.Address
+	// pools and reserves of the cycle in swap order
+	Hops []RouteHop
+	// product of the spot rates of the hops net of fees, above 1
+	Rate *big.Rat
+	// the input making the most profit at the current reserves and that profit, in Token's
+	// smallest unit and before gas. Both are zero when rounding eats the profit.
+	AmountIn *big.Int
+	Profit   *big.Int
+	// block the reserves were read at, nil when reads were not pinned
+	BlockNumber *big.Int
+}
+
+// FindArbitrage surfaces the profitable cycles of up to maxHops swaps that start and end at token
+// in the graph the router searches, priced at the reserves of one block, the most profitable first.
+// See pathfinder.FindArbitrage for the search, AutoMaxHops searches cycles of every supported length.
+func (r *OnChainV2Router) FindArbitrage(ctx context.Context, token common.Address, maxHops int) ([]*ArbitrageCycle, error) {
+	if maxHops == AutoMaxHops {
+		maxHops = maxSupportedHops
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	maxHops, err = r.resolveMaxHops(ctx, token, token, maxHops)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := r.buildGraph(ctx, token, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	found, err := pathfinder.FindArbitrage(ctx, graph.searchGraph(), token, maxHops)
+	if err != nil {
+		return nil, err
+	}
+	cycles := make([]*ArbitrageCycle, len(found))
+	for i, cycle := range found {
+		hops := graph.resultHops(&pathfinder.Result{Path: cycle.Path, Pools: cycle.Pools})
+		cycles[i] = &ArbitrageCycle{
+			Token:       token,
+			Hops:        routeHops(cycle.Path, hops),
+			Rate:        cycle.Rate,
+			AmountIn:    cycle.AmountIn,
+			Profit:      cycle.Profit,
+			BlockNumber: BlockNumberFromContext(ctx),
+		}
+		logDebug(ctx, r.logger, "arbitrage cycle", "token", token, "hops", len(hops), "profit", cycle.Profit)
+	}
+	return cycles, nil
+}
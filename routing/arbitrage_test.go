@@ -0,0 +1,46 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestFindArbitrage(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	graph := &v2GraphFake{}
+	// WETH buys 10% more DAI through USDC than it costs in the direct pool
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	graph.addPool(usdc, dai, 1000000000, 1100000000)
+	graph.addPool(weth, dai, 1000000, 2000000000)
+	router := newFakeRouter(graph)
+
+	cycles, err := router.FindArbitrage(context.Background(), weth, AutoMaxHops)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(cycles) != 1 {
+		t.Fatalf("got cycles %+v want one", cycles)
+	}
+	cycle := cycles[0]
+	if len(cycle.Hops) != 3 || cycle.Hops[0].TokenOut != usdc || cycle.Hops[1].TokenOut != dai || cycle.Hops[2].TokenOut != weth || cycle.Hops[2].Pair != fakePairAddress(weth, dai) {
+		t.Fatalf("got hops %+v want WETH -> USDC -> DAI -> WETH", cycle.Hops)
+	}
+	if cycle.Token != weth || cycle.Rate.Cmp(big.NewRat(1, 1)) <= 0 || cycle.Profit.Sign() <= 0 {
+		t.Errorf("got cycle %+v want a profitable one", cycle)
+	}
+	route, err := router.Route(context.Background(), cycle.AmountIn, weth, dai, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	back, err := router.Route(context.Background(), route.AmountOut, dai, weth, 1)
+	if err != nil || new(big.Int).Sub(back.AmountOut, cycle.AmountIn).Cmp(cycle.Profit) != 0 {
+		t.Errorf("got %v back for %v error %v want a profit of %v", back, cycle.AmountIn, err, cycle.Profit)
+	}
+
+	if _, err := router.FindArbitrage(context.Background(), weth, 9); err == nil {
+		t.Errorf("got no error for 9 hops")
+	}
+}
@@ -0,0 +1,82 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BidAsk is the executable price of trading Amount of tokenA in both directions,
+// both sides are priced from the same reserves
+type BidAsk struct {
+	// size of both sides in tokenA
+	Amount *big.Int
+	// tokenB received for selling Amount of tokenA
+	BidAmount *big.Int
+	BidPath   []common.Address
+	// tokenB paid for buying Amount of tokenA
+	AskAmount *big.Int
+	AskPath   []common.Address
+	// tokenB per tokenA in raw units, exact
+	Bid *big.Rat
+	Ask *big.Rat
+	// (Ask - Bid) relative to the midpoint of Ask and Bid, rounded to a float for display
+	Spread float64
+	// block both sides were read at, nil when reads were not pinned
+	BlockNumber *big.Int
+}
+
+// GetBidAsk quotes selling and buying amount of tokenA against tokenB from one reserve snapshot
+func (r *OnChainV2Router) GetBidAsk(ctx context.Context, tokenA, tokenB common.Address, amount *big.Int, maxHops int) (*BidAsk, error) {
+	if tokenA == tokenB {
+		return nil, errors.New("tokenA and tokenB cannot be the same")
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	maxHops, err = r.resolveMaxHops(ctx, tokenA, tokenB, maxHops)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := r.buildGraph(ctx, tokenA, tokenB, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	bidAmount, bidPath, _, err := searchRoute(ctx, graph, exactIn, amount, maxHops, nil, &RouteMetadata{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	// buying tokenA is an exact-out trade from tokenB
+	reversed := *graph
+	reversed.tokenInIndex, reversed.tokenOutIndex = graph.tokenOutIndex, graph.tokenInIndex
+	askAmount, askPath, _, err := searchRoute(ctx, &reversed, exactOut, amount, maxHops, nil, &RouteMetadata{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	bid := new(big.Rat).SetFrac(bidAmount, amount)
+	ask := new(big.Rat).SetFrac(askAmount, amount)
+	// (ask - bid) / ((ask + bid) / 2), the amount cancels out
+	spread, _ := new(big.Rat).SetFrac(
+		new(big.Int).Mul(new(big.Int).Sub(askAmount, bidAmount), big.NewInt(2)),
+		new(big.Int).Add(askAmount, bidAmount),
+	).Float64()
+	return &BidAsk{
+		Amount:      new(big.Int).Set(amount),
+		BidAmount:   bidAmount,
+		BidPath:     bidPath,
+		AskAmount:   askAmount,
+		AskPath:     askPath,
+		Bid:         bid,
+		Ask:         ask,
+		Spread:      spread,
+		BlockNumber: BlockNumberFromContext(ctx),
+	}, nil
+}
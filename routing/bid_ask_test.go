@@ -0,0 +1,47 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGetBidAsk(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	reservesProvider := &blockRecordingReservesProvider{PoolReservesProvider: graph, seenBlocks: map[string]int{}}
+	router := newFakeRouter(graph)
+	router.poolReservesProvider = reservesProvider
+	router.blockNumberProvider = staticBlockNumberProvider(16000000)
+
+	amount := big.NewInt(1000)
+	quote, err := router.GetBidAsk(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDC), amount, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	wantBid, _ := getAmountOut(amount, big.NewInt(1000000), big.NewInt(2000000000))
+	wantAsk, _ := getAmountIn(amount, big.NewInt(2000000000), big.NewInt(1000000))
+	if quote.BidAmount.Cmp(wantBid) != 0 || quote.AskAmount.Cmp(wantAsk) != 0 {
+		t.Errorf("got bid %v ask %v want %v and %v", quote.BidAmount, quote.AskAmount, wantBid, wantAsk)
+	}
+	if len(quote.BidPath) != 2 || quote.BidPath[0] != common.HexToAddress(WETH) {
+		t.Errorf("got bid path %v want WETH -> USDC", quote.BidPath)
+	}
+	if len(quote.AskPath) != 2 || quote.AskPath[0] != common.HexToAddress(USDC) {
+		t.Errorf("got ask path %v want USDC -> WETH", quote.AskPath)
+	}
+	if quote.Bid.Cmp(quote.Ask) >= 0 {
+		t.Errorf("got bid %v not below ask %v", quote.Bid, quote.Ask)
+	}
+	// two 0.3% fees plus price impact on both sides
+	if quote.Spread < 0.006 || quote.Spread > 0.01 {
+		t.Errorf("got spread %v want between 0.6%% and 1%%", quote.Spread)
+	}
+
+	// both sides come from a single read of the pool
+	if quote.BlockNumber.Uint64() != 16000000 || reservesProvider.seenBlocks["16000000"] != 1 {
+		t.Errorf("got block %v and reads %v want one read at 16000000", quote.BlockNumber, reservesProvider.seenBlocks)
+	}
+}
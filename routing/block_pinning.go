@@ -0,0 +1,35 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// BlockNumberProvider returns the latest block number, *ethclient.Client satisfies it
+type BlockNumberProvider interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+type blockNumberKey struct{}
+
+// WithBlockNumber pins every on-chain read made with the returned context to one block
+func WithBlockNumber(ctx context.Context, blockNumber *big.Int) context.Context {
+	return context.WithValue(ctx, blockNumberKey{}, new(big.Int).Set(blockNumber))
+}
+
+// BlockNumberFromContext returns the pinned block, or nil when reads should use the latest block
+func BlockNumberFromContext(ctx context.Context) *big.Int {
+	blockNumber, _ := ctx.Value(blockNumberKey{}).(*big.Int)
+	return blockNumber
+}
+
+// newCallOpts builds the call options for a read, honoring a block pinned on the context
+func newCallOpts(ctx context.Context) *bind.CallOpts {
+	return &bind.CallOpts{
+		Context:     ctx,
+		Pending:     false,
+		BlockNumber: BlockNumberFromContext(ctx),
+	}
+}
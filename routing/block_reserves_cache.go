@@ -0,0 +1,192 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HeadSubscriber streams new block headers, *ethclient.Client satisfies it over a websocket
+type HeadSubscriber interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// BlockReservesCache shares reserves between all quotes pinned to the same block. Reserves are
+// keyed by pair and block, reads that are not pinned or see a state override go straight to
+// provider. Run drops the entries of older blocks as soon as a new header arrives, without it
+// they are dropped once a read is pinned to a newer block.
+type BlockReservesCache struct {
+	subscriber HeadSubscriber
+	provider   PoolReservesProvider
+	// lookups of pinned reads, unpinned ones cannot be cached
+	counters cacheCounters
+
+	mu sync.RWMutex
+	// newest block seen, entries of older blocks are gone
+	head     uint64
+	reserves map[common.Address][2]*big.Int
+}
+
+func NewBlockReservesCache(subscriber HeadSubscriber, provider PoolReservesProvider) *BlockReservesCache {
+	return &BlockReservesCache{subscriber: subscriber, provider: provider}
+}
+
+// Run invalidates the cache on every new header until ctx is done or the subscription fails
+func (c *BlockReservesCache) Run(ctx context.Context) error {
+	headers := make(chan *types.Header, 16)
+	subscription, err := c.subscriber.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		return err
+	}
+	defer subscription.Unsubscribe()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-subscription.Err():
+			if err == nil {
+				err = errors.New("head subscription closed")
+			}
+			return err
+		case header := <-headers:
+			c.advance(header.Number.Uint64())
+		}
+	}
+}
+
+// advance moves the cache to blockNumber, a reorg to an older block does not move it back
+func (c *BlockReservesCache) advance(blockNumber uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if blockNumber > c.head {
+		c.head, c.reserves = blockNumber, nil
+	}
+}
+
+func (c *BlockReservesCache) cached(blockNumber uint64, pairAddress common.Address) (*big.Int, *big.Int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	reserves, ok := c.reserves[pairAddress]
+	if blockNumber != c.head || !ok {
+		return nil, nil, false
+	}
+	return new(big.Int).Set(reserves[0]), new(big.Int).Set(reserves[1]), true
+}
+
+func (c *BlockReservesCache) store(blockNumber uint64, pairAddress common.Address, reserve0, reserve1 *big.Int) {
+	c.advance(blockNumber)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// the head moved on while reading
+	if blockNumber != c.head {
+		return
+	}
+	if c.reserves == nil {
+		c.reserves = make(map[common.Address][2]*big.Int)
+	}
+	c.reserves[pairAddress] = [2]*big.Int{new(big.Int).Set(reserve0), new(big.Int).Set(reserve1)}
+}
+
+// cacheBlock is the block the reads of ctx are cached under, nil when they cannot be cached
+func cacheBlock(ctx context.Context) *big.Int {
+	if StateOverrideFromContext(ctx) != nil {
+		return nil
+	}
+	return BlockNumberFromContext(ctx)
+}
+
+func (c *BlockReservesCache) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	blockNumber := cacheBlock(ctx)
+	if blockNumber == nil {
+		return c.provider.GetPoolReserves(ctx, pairAddress)
+	}
+	reserve0, reserve1, ok := c.cached(blockNumber.Uint64(), pairAddress)
+	c.counters.count(ok)
+	if ok {
+		return reserve0, reserve1, nil
+	}
+	reserve0, reserve1, err := c.provider.GetPoolReserves(ctx, pairAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.store(blockNumber.Uint64(), pairAddress, reserve0, reserve1)
+	return reserve0, reserve1, nil
+}
+
+// GetPoolReservesBatch serves cached pairs from memory and reads the rest in one batch when
+// provider supports it
+func (c *BlockReservesCache) GetPoolReservesBatch(ctx context.Context, pairAddresses []common.Address) ([]*big.Int, []*big.Int, error) {
+	reserves0 := make([]*big.Int, len(pairAddresses))
+	reserves1 := make([]*big.Int, len(pairAddresses))
+	blockNumber := cacheBlock(ctx)
+	missing := []int{}
+	for i, pairAddress := range pairAddresses {
+		var ok bool
+		if blockNumber != nil {
+			reserves0[i], reserves1[i], ok = c.cached(blockNumber.Uint64(), pairAddress)
+		}
+		if !ok {
+			missing = append(missing, i)
+		}
+	}
+	if blockNumber != nil {
+		c.counters.hits.Add(uint64(len(pairAddresses) - len(missing)))
+	}
+	if len(missing) == 0 {
+		return reserves0, reserves1, nil
+	}
+	batchProvider, ok := c.provider.(BatchPoolReservesProvider)
+	// GetPoolReserves counts the misses it reads
+	if !ok {
+		for _, i := range missing {
+			var err error
+			reserves0[i], reserves1[i], err = c.GetPoolReserves(ctx, pairAddresses[i])
+			if errors.Is(err, ErrPairNotDeployed) {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return reserves0, reserves1, nil
+	}
+	if blockNumber != nil {
+		c.counters.misses.Add(uint64(len(missing)))
+	}
+	missingAddresses := make([]common.Address, len(missing))
+	for k, i := range missing {
+		missingAddresses[k] = pairAddresses[i]
+	}
+	missing0, missing1, err := batchProvider.GetPoolReservesBatch(ctx, missingAddresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, i := range missing {
+		reserves0[i], reserves1[i] = missing0[k], missing1[k]
+		if blockNumber != nil && missing0[k] != nil {
+			c.store(blockNumber.Uint64(), pairAddresses[i], missing0[k], missing1[k])
+		}
+	}
+	return reserves0, reserves1, nil
+}
+
+// InvalidatePools drops the cached reserves of pairs, and those of provider when it caches them too
+func (c *BlockReservesCache) InvalidatePools(ctx context.Context, pairs []common.Address) error {
+	c.mu.Lock()
+	for _, pair := range pairs {
+		delete(c.reserves, pair)
+	}
+	c.mu.Unlock()
+	return invalidateProvider(ctx, c.provider, pairs)
+}
+
+func (c *BlockReservesCache) CacheSummary() CacheSummary {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counters.summary(len(c.reserves), "block "+new(big.Int).SetUint64(c.head).String())
+}
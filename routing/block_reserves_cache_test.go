@@ -0,0 +1,151 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// headSubscriberFake hands the header channel to the test so it can push new blocks
+type headSubscriberFake struct {
+	headers    chan<- *types.Header
+	subscribed chan struct{}
+}
+
+func (f *headSubscriberFake) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	f.headers = ch
+	close(f.subscribed)
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	}), nil
+}
+
+func TestBlockReservesCache(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000, 2000)
+	pair := fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC))
+	node := &countingReservesProvider{PoolReservesProvider: graph}
+	cache := NewBlockReservesCache(nil, node)
+
+	// unpinned reads cannot be cached
+	cache.GetPoolReserves(context.Background(), pair)
+	cache.GetPoolReserves(context.Background(), pair)
+	if node.reads != 2 {
+		t.Errorf("got %v node reads for unpinned reads want 2", node.reads)
+	}
+
+	atBlock := WithBlockNumber(context.Background(), big.NewInt(100))
+	reserve0, reserve1, err := cache.GetPoolReserves(atBlock, pair)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if reserve0.Int64() != 2000 || reserve1.Int64() != 1000 {
+		t.Errorf("got reserves %v %v want 2000 1000", reserve0, reserve1)
+	}
+	// callers may modify what they get back
+	reserve0.SetInt64(0)
+	reserve0, _, _ = cache.GetPoolReserves(atBlock, pair)
+	if node.reads != 3 {
+		t.Errorf("got %v node reads for reads at the same block want 3", node.reads)
+	}
+	if reserve0.Int64() != 2000 {
+		t.Errorf("got cached reserve0 %v want 2000", reserve0)
+	}
+
+	// a read pinned to the next block drops the entries of the previous one
+	cache.GetPoolReserves(WithBlockNumber(context.Background(), big.NewInt(101)), pair)
+	if node.reads != 4 {
+		t.Errorf("got %v node reads after a newer block want 4", node.reads)
+	}
+	// reads pinned to an older block are not cached
+	cache.GetPoolReserves(atBlock, pair)
+	cache.GetPoolReserves(atBlock, pair)
+	if node.reads != 6 {
+		t.Errorf("got %v node reads at an older block want 6", node.reads)
+	}
+	// neither are reads of a simulated state
+	simulated := WithStateOverride(WithBlockNumber(context.Background(), big.NewInt(101)), StateOverride{pair: {}})
+	cache.GetPoolReserves(simulated, pair)
+	if node.reads != 7 {
+		t.Errorf("got %v node reads with a state override want 7", node.reads)
+	}
+}
+
+func TestBlockReservesCacheRun(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000, 2000)
+	pair := fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC))
+	node := &countingReservesProvider{PoolReservesProvider: graph}
+	subscriber := &headSubscriberFake{subscribed: make(chan struct{})}
+	cache := NewBlockReservesCache(subscriber, node)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- cache.Run(ctx) }()
+	<-subscriber.subscribed
+
+	atBlock := WithBlockNumber(context.Background(), big.NewInt(100))
+	cache.GetPoolReserves(atBlock, pair)
+	cache.GetPoolReserves(atBlock, pair)
+	if node.reads != 1 {
+		t.Errorf("got %v node reads want 1", node.reads)
+	}
+
+	subscriber.headers <- &types.Header{Number: big.NewInt(101)}
+	deadline := time.Now().Add(time.Second)
+	for cache.CacheSummary().Entries != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if summary := cache.CacheSummary(); summary.Entries != 0 || summary.Detail != "block 101" {
+		t.Errorf("got summary %+v after a new header want no entries at block 101", summary)
+	}
+	// quotes still pinned to the old block read through
+	cache.GetPoolReserves(atBlock, pair)
+	if node.reads != 2 {
+		t.Errorf("got %v node reads after a new header want 2", node.reads)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got error %v want context.Canceled", err)
+	}
+}
+
+func TestBlockReservesCacheBatch(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000, 2000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 1000, 3000)
+	wethUSDC := fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC))
+	wethDAI := fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(DAI))
+	node := &multicallFake{graph: graph}
+	cache := NewBlockReservesCache(nil, NewMulticallPoolReservesProvider(node))
+	atBlock := WithBlockNumber(context.Background(), big.NewInt(100))
+
+	if _, _, err := cache.GetPoolReserves(atBlock, wethUSDC); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	reserves0, reserves1, err := cache.GetPoolReservesBatch(atBlock, []common.Address{wethUSDC, wethDAI})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if reserves0[0].Int64() != 2000 || reserves1[0].Int64() != 1000 {
+		t.Errorf("got WETH/USDC reserves %v %v want 2000 1000", reserves0[0], reserves1[0])
+	}
+	if reserves0[1] == nil || reserves1[1] == nil {
+		t.Fatalf("got no WETH/DAI reserves")
+	}
+	if node.calls != 2 {
+		t.Errorf("got %v multicalls want 2", node.calls)
+	}
+	// everything is cached now
+	cache.GetPoolReservesBatch(atBlock, []common.Address{wethUSDC, wethDAI})
+	if node.calls != 2 {
+		t.Errorf("got %v multicalls for a cached batch want 2", node.calls)
+	}
+}
@@ -0,0 +1,130 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// routes losing more than 1% to trade size are worth searching again with more tokens
+	defaultExpansionPriceImpact = 0.01
+	defaultMaxExpansionTokens   = 8
+)
+
+// NeighborTokensProvider returns the tokens that share a high-liquidity pool with token
+type NeighborTokensProvider interface {
+	GetNeighborTokens(ctx context.Context, token common.Address) ([]common.Address, error)
+}
+
+// CandidateExpansion searches again with the neighbors of tokenIn and tokenOut when the pools
+// of the router's PoolProvider yield no route or one with a high price impact
+type CandidateExpansion struct {
+	Neighbors NeighborTokensProvider
+	// most tokens added to a search, defaults to defaultMaxExpansionTokens
+	MaxTokens int
+	// price impact above which a route is searched again, defaults to defaultExpansionPriceImpact
+	MaxPriceImpact float64
+}
+
+// poor reports whether the result of a search is worth expanding
+func (e *CandidateExpansion) poor(found *graphSearch, err error) bool {
+	var noRoute *NoRouteError
+	if errors.As(err, &noRoute) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	maxPriceImpact := e.MaxPriceImpact
+	if maxPriceImpact == 0 {
+		maxPriceImpact = defaultExpansionPriceImpact
+	}
+	priceImpact, _ := found.priceImpact().Float64()
+	return priceImpact > maxPriceImpact
+}
+
+// tokens alternates between the neighbors of tokenIn and tokenOut so both sides get candidates
+// when MaxTokens cuts the list short
+func (e *CandidateExpansion) tokens(ctx context.Context, tokenIn, tokenOut common.Address) ([]common.Address, error) {
+	maxTokens := e.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultMaxExpansionTokens
+	}
+	neighborsIn, err := e.Neighbors.GetNeighborTokens(ctx, tokenIn)
+	if err != nil {
+		return nil, err
+	}
+	neighborsOut, err := e.Neighbors.GetNeighborTokens(ctx, tokenOut)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[common.Address]bool{tokenIn: true, tokenOut: true}
+	tokens := []common.Address{}
+	for i := 0; i < len(neighborsIn) || i < len(neighborsOut); i++ {
+		for _, neighbors := range [][]common.Address{neighborsIn, neighborsOut} {
+			if i >= len(neighbors) || seen[neighbors[i]] || len(tokens) == maxTokens {
+				continue
+			}
+			seen[neighbors[i]] = true
+			tokens = append(tokens, neighbors[i])
+		}
+	}
+	return tokens, nil
+}
+
+// graphSearch is the best route through one graph
+type graphSearch struct {
+	tradeType tradeType
+	graph     *routeGraph
+	result    *big.Int
+	path      []common.Address
+	hops      []hopReserves
+	hopCost   *big.Int
+	// input of the route, the fixed amount for exact-in and result for exact-out
+	amountIn *big.Int
+}
+
+func (s *graphSearch) priceImpact() *big.Rat {
+	return pathPriceImpact(s.amountIn, s.hops)
+}
+
+// better ranks two searches by their amount net of gas
+func (s *graphSearch) better(other *graphSearch) bool {
+	return s.tradeType.Better(s.tradeType.NetAmount(s.result, len(s.hops), s.hopCost), other.tradeType.NetAmount(other.result, len(other.hops), other.hopCost))
+}
+
+// PoolsNeighborTokensProvider takes the neighbors of a token from the pools of a PoolsProvider,
+// e.g. a LogScanPoolsProvider with MinLiquidityUSD so only high-liquidity pools are considered
+type PoolsNeighborTokensProvider struct {
+	poolsProvider PoolsProvider
+}
+
+func NewPoolsNeighborTokensProvider(poolsProvider PoolsProvider) *PoolsNeighborTokensProvider {
+	return &PoolsNeighborTokensProvider{poolsProvider: poolsProvider}
+}
+
+// GetNeighborTokens returns the tokens paired with token in the order of the pools
+func (p *PoolsNeighborTokensProvider) GetNeighborTokens(ctx context.Context, token common.Address) ([]common.Address, error) {
+	pools, err := p.poolsProvider.GetPools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[common.Address]bool)
+	neighbors := []common.Address{}
+	for _, pool := range pools {
+		neighbor := pool.Token1
+		if pool.Token1 == token {
+			neighbor = pool.Token0
+		} else if pool.Token0 != token {
+			continue
+		}
+		if !seen[neighbor] {
+			seen[neighbor] = true
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors, nil
+}
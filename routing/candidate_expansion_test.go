@@ -0,0 +1,99 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newExpandingRouter searches the pools of base and expands into every pool of all
+func newExpandingRouter(base, all *v2GraphFake) *OnChainV2Router {
+	router := newFakeRouter(all)
+	router.poolProvider = base
+	router.candidateExpansion = &CandidateExpansion{Neighbors: NewPoolsNeighborTokensProvider(all)}
+	return router
+}
+
+func TestCandidateExpansion(t *testing.T) {
+	weth, usdc, dai, wise := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI), common.HexToAddress(WISE)
+	base := &v2GraphFake{}
+	base.addPool(weth, dai, 100000, 150000000)
+	all := &v2GraphFake{}
+	all.addPool(weth, dai, 100000, 150000000)
+	all.addPool(weth, usdc, 1000000, 2000000000)
+	all.addPool(usdc, dai, 1000000000, 1100000000)
+	all.addPool(usdc, wise, 1000000000, 1000000000)
+	router := newExpandingRouter(base, all)
+
+	// the direct pool is too shallow, USDC shares deep pools with both tokens
+	_, path, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(10000), weth, dai, 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if want := []common.Address{weth, usdc, dai}; !reflect.DeepEqual(path, want) {
+		t.Errorf("got path %v want %v", path, want)
+	}
+	if want := []common.Address{usdc}; !reflect.DeepEqual(metadata.ExpandedTokens, want) {
+		t.Errorf("got expanded tokens %v want %v", metadata.ExpandedTokens, want)
+	}
+	if metadata.TokensConsidered != 3 {
+		t.Errorf("got %v tokens considered want 3", metadata.TokensConsidered)
+	}
+
+	// a small trade through the direct pool is good enough
+	_, path, metadata, err = router.RouteWithMetadata(context.Background(), big.NewInt(10), weth, dai, 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if want := []common.Address{weth, dai}; !reflect.DeepEqual(path, want) {
+		t.Errorf("got path %v want %v", path, want)
+	}
+	if metadata.ExpandedTokens != nil {
+		t.Errorf("got expanded tokens %v for a good route", metadata.ExpandedTokens)
+	}
+
+	// WISE is only paired with USDC, which none of the base pools contain
+	_, path, metadata, err = router.RouteWithMetadata(context.Background(), big.NewInt(1000), weth, wise, 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if want := []common.Address{weth, usdc, wise}; !reflect.DeepEqual(path, want) {
+		t.Errorf("got path %v want %v", path, want)
+	}
+	if len(metadata.ExpandedTokens) == 0 {
+		t.Errorf("got no expanded tokens for a route found through them")
+	}
+
+	// without expansion the base pools find no route
+	router.candidateExpansion = nil
+	if _, err := router.Route(context.Background(), big.NewInt(1000), weth, wise, 3); err == nil {
+		t.Errorf("got a route to WISE without expansion")
+	}
+}
+
+func TestCandidateExpansionTokens(t *testing.T) {
+	weth, usdc, dai, usdt, wbtc := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI), common.HexToAddress(USDT), common.HexToAddress(WBTC)
+	all := &v2GraphFake{}
+	all.addPool(weth, usdc, 1, 1)
+	all.addPool(weth, wbtc, 1, 1)
+	all.addPool(dai, usdc, 1, 1)
+	all.addPool(usdt, dai, 1, 1)
+	expansion := &CandidateExpansion{Neighbors: NewPoolsNeighborTokensProvider(all), MaxTokens: 2}
+
+	// neighbors alternate between both sides, USDC is shared and listed once
+	tokens, err := expansion.tokens(context.Background(), weth, dai)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if want := []common.Address{usdc, wbtc}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got tokens %v want %v", tokens, want)
+	}
+	expansion.MaxTokens = 0
+	tokens, _ = expansion.tokens(context.Background(), weth, dai)
+	if want := []common.Address{usdc, wbtc, usdt}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got tokens %v want %v", tokens, want)
+	}
+}
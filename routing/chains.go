@@ -0,0 +1,166 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Chain is where the router's contracts and tokens are deployed on one chain. Permit2 and
+// Multicall3 share their address on every chain, see PERMIT2_ADDRESS and MULTICALL3_ADDRESS.
+type Chain struct {
+	ID int64
+	// name ROUTER_CHAIN and LookupChain accept, as app.uniswap.org spells it
+	Name string
+	// Uniswap V2 factory and the init code hash its pair addresses are computed with
+	V2Factory      common.Address
+	V2InitCodeHash common.Hash
+	// block the V2 factory was deployed at, 0 when pool discovery scans from genesis
+	V2FactoryStartBlock uint64
+	Router02            common.Address
+	// zero on chains without a Sushiswap V2 deployment
+	SushiswapFactory common.Address
+	V3Factory        common.Address
+	V3QuoterV2       common.Address
+	UniversalRouter  common.Address
+	// WETH on Ethereum and its rollups, WMATIC on Polygon
+	WrappedNative common.Address
+	// ENS registry names are resolved through, zero on chains without ENS, see NewENSResolver
+	ENSRegistry common.Address
+	// USD stablecoins liquidity is valued against, at $1
+	Stablecoins []common.Address
+	// tokens searched between by default, see StaticTopTokensProvider
+	BaseTokens []common.Address
+	// public endpoints used when none are configured, WSURL is empty without one
+	RPCURL string
+	WSURL  string
+	// Uniswap V2 subgraph, empty where there is none
+	SubgraphURL string
+}
+
+var (
+	Mainnet = Chain{
+		ID:                  1,
+		Name:                "mainnet",
+		V2Factory:           common.HexToAddress(FACTORY_ADDRESS),
+		V2InitCodeHash:      common.HexToHash(INIT_CODE_HASH),
+		V2FactoryStartBlock: UNISWAP_V2_FACTORY_START_BLOCK,
+		Router02:            common.HexToAddress(ROUTER02_ADDRESS),
+		SushiswapFactory:    common.HexToAddress(SUSHISWAP_FACTORY_ADDRESS),
+		V3Factory:           common.HexToAddress(UNISWAP_V3_FACTORY_ADDRESS),
+		V3QuoterV2:          common.HexToAddress(UNISWAP_V3_QUOTER_V2_ADDRESS),
+		UniversalRouter:     common.HexToAddress(UNIVERSAL_ROUTER_ADDRESS),
+		WrappedNative:       common.HexToAddress(WETH),
+		ENSRegistry:         common.HexToAddress(ENS_REGISTRY_ADDRESS),
+		Stablecoins:         addresses(USDC, USDT, DAI),
+		BaseTokens:          addresses(WETH, USDC, DAI, USDT, WBTC, UNI),
+		RPCURL:              MAINNET_INFURA_RPC,
+		WSURL:               MAINNET_INFURA_WS,
+		SubgraphURL:         UNISWAP_V2_SUBGRAPH_URL,
+	}
+	Polygon = Chain{
+		ID:               137,
+		Name:             "polygon",
+		V2Factory:        common.HexToAddress("0x9e5A52f57b3038F1B8EeE45F28b3C1967e22799C"),
+		V2InitCodeHash:   common.HexToHash(INIT_CODE_HASH),
+		Router02:         common.HexToAddress("0xedf6066a2b290C185783862C7F4776A2C8077AD1"),
+		SushiswapFactory: common.HexToAddress("0xc35DADB65012eC5796536bD9864eD8773aBc74C4"),
+		V3Factory:        common.HexToAddress(UNISWAP_V3_FACTORY_ADDRESS),
+		V3QuoterV2:       common.HexToAddress(UNISWAP_V3_QUOTER_V2_ADDRESS),
+		UniversalRouter:  common.HexToAddress(UNIVERSAL_ROUTER_ADDRESS),
+		// WMATIC
+		WrappedNative: common.HexToAddress("0x0d500B1d8E8eF31E21C99d1Db9A6444d3ADf1270"),
+		// USDC, bridged USDC.e, USDT and DAI
+		Stablecoins: addresses("0x3c499c542cEF5E3811e1192ce70d8cC03d5c3359", "0x2791Bca1f2de4661ED88A30C99A7a9449Aa84174", "0xc2132D05D31c914a87C6611C10748AEb04B58e8F", "0x8f3Cf7ad23Cd3CaDbD9735AFf958023239c6A063"),
+		// WMATIC, USDC, USDC.e, USDT, DAI, WETH and WBTC
+		BaseTokens: addresses("0x0d500B1d8E8eF31E21C99d1Db9A6444d3ADf1270", "0x3c499c542cEF5E3811e1192ce70d8cC03d5c3359", "0x2791Bca1f2de4661ED88A30C99A7a9449Aa84174", "0xc2132D05D31c914a87C6611C10748AEb04B58e8F",
+			"0x8f3Cf7ad23Cd3CaDbD9735AFf958023239c6A063", "0x7ceB23fD6bC0adD59E62ac25578270cFf1b9f619", "0x1BFD67037B42Cf73acF2047067bd4F2C47D9BfD6"),
+		RPCURL: "https://polygon-rpc.com",
+	}
+	Base = Chain{
+		ID:               8453,
+		Name:             "base",
+		V2Factory:        common.HexToAddress("0x8909Dc15e40173Ff4699343b6eB8132c65e18eC6"),
+		V2InitCodeHash:   common.HexToHash(INIT_CODE_HASH),
+		Router02:         common.HexToAddress("0x4752ba5DBc23f44D87826276BF6Fd6b1C372aD24"),
+		SushiswapFactory: common.HexToAddress("0x71524B4f93c58fcbF659783284E38825f0622859"),
+		V3Factory:        common.HexToAddress("0x33128a8fC17869897dcE68Ed026d694621f6FDfD"),
+		V3QuoterV2:       common.HexToAddress("0x3d4e44Eb1374240CE5F1B871ab261CD16335B76a"),
+		UniversalRouter:  common.HexToAddress(UNIVERSAL_ROUTER_ADDRESS),
+		WrappedNative:    common.HexToAddress("0x4200000000000000000000000000000000000006"),
+		// USDC, bridged USDbC and DAI
+		Stablecoins: addresses("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", "0xd9aAEc86B65D86f6A7B5B1b0c42FFA531710b6CA", "0x50c5725949A6F0c72E6C4a641F24049A917DB0Cb"),
+		// WETH, USDC, USDbC, DAI and cbETH
+		BaseTokens: addresses("0x4200000000000000000000000000000000000006", "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", "0xd9aAEc86B65D86f6A7B5B1b0c42FFA531710b6CA",
+			"0x50c5725949A6F0c72E6C4a641F24049A917DB0Cb", "0x2Ae3F1Ec7F1F5012CFEab0185bfc7aa3cf0DEc22"),
+		RPCURL: "https://mainnet.base.org",
+	}
+	Arbitrum = Chain{
+		ID:               42161,
+		Name:             "arbitrum",
+		V2Factory:        common.HexToAddress("0xf1D7CC64Fb4452F05c498126312eBE29f30Fbcf9"),
+		V2InitCodeHash:   common.HexToHash(INIT_CODE_HASH),
+		Router02:         common.HexToAddress("0x4752ba5DBc23f44D87826276BF6Fd6b1C372aD24"),
+		SushiswapFactory: common.HexToAddress("0xc35DADB65012eC5796536bD9864eD8773aBc74C4"),
+		V3Factory:        common.HexToAddress(UNISWAP_V3_FACTORY_ADDRESS),
+		V3QuoterV2:       common.HexToAddress(UNISWAP_V3_QUOTER_V2_ADDRESS),
+		UniversalRouter:  common.HexToAddress(UNIVERSAL_ROUTER_ADDRESS),
+		WrappedNative:    common.HexToAddress("0x82aF49447D8a07e3bd95BD0d56f35241523fBab1"),
+		// USDC, bridged USDC.e, USDT and DAI
+		Stablecoins: addresses("0xaf88d065e77c8cC2239327C5EDb3A432268e5831", "0xFF970A61A04b1cA14834A43f5dE4533eBDDB5CC8", "0xFd086bC7CD5C481DCC9C85ebE478A1C0b69FCbb9", "0xDA10009cBd5D07dd0CeCc66161FC93D7c9000da1"),
+		// WETH, USDC, USDC.e, USDT, DAI, WBTC and ARB
+		BaseTokens: addresses("0x82aF49447D8a07e3bd95BD0d56f35241523fBab1", "0xaf88d065e77c8cC2239327C5EDb3A432268e5831", "0xFF970A61A04b1cA14834A43f5dE4533eBDDB5CC8", "0xFd086bC7CD5C481DCC9C85ebE478A1C0b69FCbb9",
+			"0xDA10009cBd5D07dd0CeCc66161FC93D7c9000da1", "0x2f2a2543B76A4166549F7aaB2e75Bef0aefC5B0f", "0x912CE59144191C1204E64559FE8253a0e49E6548"),
+		RPCURL: "https://arb1.arbitrum.io/rpc",
+	}
+)
+
+// Chains is the chain registry by chain ID
+var Chains = map[int64]Chain{
+	Mainnet.ID:  Mainnet,
+	Polygon.ID:  Polygon,
+	Base.ID:     Base,
+	Arbitrum.ID: Arbitrum,
+}
+
+// ChainByID returns the registered chain with the ID
+func ChainByID(id int64) (Chain, error) {
+	chain, ok := Chains[id]
+	if !ok {
+		return Chain{}, fmt.Errorf("chain %v is not registered, known chains are %v", id, chainNames())
+	}
+	return chain, nil
+}
+
+// LookupChain returns the registered chain with a name or decimal chain ID, e.g. "base" or "8453"
+func LookupChain(nameOrID string) (Chain, error) {
+	if id, err := strconv.ParseInt(nameOrID, 10, 64); err == nil {
+		return ChainByID(id)
+	}
+	for _, chain := range Chains {
+		if strings.EqualFold(chain.Name, nameOrID) {
+			return chain, nil
+		}
+	}
+	return Chain{}, fmt.Errorf("chain %q is not registered, known chains are %v", nameOrID, chainNames())
+}
+
+func chainNames() string {
+	names := []string{}
+	for _, chain := range Chains {
+		names = append(names, chain.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func addresses(hexes ...string) []common.Address {
+	result := make([]common.Address, len(hexes))
+	for i, hex := range hexes {
+		result[i] = common.HexToAddress(hex)
+	}
+	return result
+}
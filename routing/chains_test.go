@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestLookupChain(t *testing.T) {
+	tests := []struct {
+		nameOrID string
+		want     int64
+	}{
+		{"mainnet", 1},
+		{"Polygon", 137},
+		{"8453", 8453},
+		{"arbitrum", 42161},
+	}
+	for _, test := range tests {
+		chain, err := LookupChain(test.nameOrID)
+		if err != nil || chain.ID != test.want {
+			t.Errorf("%v: got chain %v error %v want %v", test.nameOrID, chain.ID, err, test.want)
+		}
+	}
+	for _, unknown := range []string{"solana", "10"} {
+		if _, err := LookupChain(unknown); err == nil {
+			t.Errorf("%v: got no error for an unregistered chain", unknown)
+		}
+	}
+
+	for id, chain := range Chains {
+		if chain.ID != id || chain.V2Factory == (common.Address{}) || chain.Router02 == (common.Address{}) || chain.WrappedNative == (common.Address{}) || chain.RPCURL == "" {
+			t.Errorf("chain %v: incomplete registration %+v", id, chain)
+		}
+		if len(chain.Stablecoins) == 0 || len(chain.BaseTokens) == 0 || chain.BaseTokens[0] != chain.WrappedNative {
+			t.Errorf("chain %v: got stablecoins %v base tokens %v want both, led by the wrapped native token", id, chain.Stablecoins, chain.BaseTokens)
+		}
+	}
+}
+
+func TestRouteOnOtherChain(t *testing.T) {
+	wmatic, usdc, dai := Polygon.WrappedNative, Polygon.Stablecoins[0], Polygon.Stablecoins[3]
+	graph := &v2GraphFake{}
+	graph.addPool(wmatic, dai, 1000000000, 1000000000)
+	graph.addPool(wmatic, usdc, 1000000000, 1000000000)
+	graph.addPool(usdc, dai, 1000000000, 1010000000)
+	router := newFakeRouter(graph)
+	router.chain = Polygon
+	router.gasPricing = &GasPricing{GasPerSwap: DefaultGasPerSwap, BaseFee: big.NewInt(1)}
+
+	// gas is priced through WMATIC, Mainnet's WETH is not in the graph
+	_, path, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(1000000), wmatic, dai, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(path) != 2 || metadata.GasCost == nil {
+		t.Errorf("got path %v gas cost %v want the direct pair priced with gas", path, metadata.GasCost)
+	}
+
+	// native swaps wrap the chain's token
+	quote, err := router.Route(context.Background(), big.NewInt(1000000), wmatic, dai, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	builder := NewTxBuilder(Polygon.Router02, nil)
+	if _, err := builder.Build(quote, SwapOptions{Recipient: common.HexToAddress("0x1234"), NativeIn: true}); err == nil {
+		t.Error("got no error paying WETH for a route starting at WMATIC")
+	}
+	builder.SetChain(Polygon)
+	if swap, err := builder.Build(quote, SwapOptions{Recipient: common.HexToAddress("0x1234"), NativeIn: true}); err != nil || swap.Method != "swapExactETHForTokens" || swap.Value.Cmp(big.NewInt(1000000)) != 0 {
+		t.Errorf("got swap %+v error %v want a native swap paying 1000000", swap, err)
+	}
+}
+
+func TestUSDPricesUseChainStablecoins(t *testing.T) {
+	wmatic, usdc := Polygon.WrappedNative, Polygon.Stablecoins[0]
+	pools := []Pool{{Token0: wmatic, Token1: usdc}}
+	balances := [][2]*big.Rat{{big.NewRat(1000, 1), big.NewRat(500, 1)}}
+	prices := usdPrices(pools, balances, Polygon.Stablecoins)
+	if prices[wmatic].Cmp(big.NewRat(1, 2)) != 0 {
+		t.Errorf("got WMATIC price %v want 0.5", prices[wmatic])
+	}
+	if len(usdPrices(pools, balances, Mainnet.Stablecoins)) != len(Mainnet.Stablecoins) {
+		t.Error("got Polygon tokens priced from Mainnet's stablecoins")
+	}
+}
@@ -0,0 +1,37 @@
+package routing
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultParallelism bounds concurrent RPC calls when no limit is configured
+const defaultParallelism = 8
+
+// forEachParallel calls fn for every index in [0, n) with at most parallelism calls in flight.
+// It stops scheduling new calls once ctx is done or a call fails, and returns the first error.
+func forEachParallel(ctx context.Context, n, parallelism int, fn func(ctx context.Context, i int) error) error {
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(parallelism)
+	for i := 0; i < n; i++ {
+		if groupCtx.Err() != nil {
+			break
+		}
+		i := i
+		group.Go(func() error {
+			if err := groupCtx.Err(); err != nil {
+				return err
+			}
+			return fn(groupCtx, i)
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return err
+	}
+	// the parent context may have been cancelled before anything failed
+	return ctx.Err()
+}
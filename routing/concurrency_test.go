@@ -0,0 +1,63 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEachParallelLimitsConcurrency(t *testing.T) {
+	var inFlight, maxInFlight, calls int32
+	err := forEachParallel(context.Background(), 20, 3, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			seen := atomic.LoadInt32(&maxInFlight)
+			if current <= seen || atomic.CompareAndSwapInt32(&maxInFlight, seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if calls != 20 {
+		t.Errorf("got %d calls want 20", calls)
+	}
+	if maxInFlight > 3 {
+		t.Errorf("got %d calls in flight want at most 3", maxInFlight)
+	}
+}
+
+func TestForEachParallelStopsOnError(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+	var calls int32
+	err := forEachParallel(context.Background(), 100, 1, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		if i == 2 {
+			return errLookup
+		}
+		return nil
+	})
+	if !errors.Is(err, errLookup) {
+		t.Errorf("got error %v want %v", err, errLookup)
+	}
+	if calls >= 100 {
+		t.Errorf("got %d calls, want the remaining calls skipped", calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = forEachParallel(ctx, 10, 2, func(ctx context.Context, i int) error {
+		t.Errorf("call %d ran after cancellation", i)
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v want context.Canceled", err)
+	}
+}
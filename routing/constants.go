@@ -0,0 +1,25 @@
+package routing
+
+const FACTORY_ADDRESS = "0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f"
+const UNISWAP_V2_FACTORY_START_BLOCK = 10000835
+const INIT_CODE_HASH = "0x96e8ac4277198ff8b6f785478aa9a39f403cb768dd02cbee326c3e7da348845f"
+const WETH_USDC = "0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc"
+const USDC = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+const WETH = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+const DAI = "0x6B175474E89094C44Da98b954EedeAC495271d0F"
+const UNI = "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984"
+const WBTC = "0x2260fac5e5542a773aa44fbcfedf7c193bc2c599"
+const USDT = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+const PAXG = "0x45804880de22913dafe09f4980848ece6ecbaf78"
+const WISE = "0x66a0f676479cee1d7373f3dc2e2952778bff5bd6"
+const MAINNET_INFURA_RPC = "https://mainnet.infura.io/v3/c75a0117c6cd4c84a4a8bf62ac9979e7"
+const MAINNET_INFURA_WS = "wss://mainnet.infura.io/ws/v3/c75a0117c6cd4c84a4a8bf62ac9979e7"
+const MULTICALL3_ADDRESS = "0xcA11bde05977b3631167028862bE2a173976CA11"
+const UNISWAP_V3_FACTORY_ADDRESS = "0x1F98431c8aD98523631AE4a59f267346ea31F984"
+const UNISWAP_V3_QUOTER_V2_ADDRESS = "0x61fFE014bA17989E743c5F6cB21bF9697530B21e"
+const SUSHISWAP_FACTORY_ADDRESS = "0xC0AEe478e3658e2610c5F7A4A2E1777cE9e4f2Ac"
+const ROUTER02_ADDRESS = "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"
+const PERMIT2_ADDRESS = "0x000000000022D473030F116dDEE9F6B43aC78BA3"
+const ENS_REGISTRY_ADDRESS = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+const UNIVERSAL_ROUTER_ADDRESS = "0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"
+const UNISWAP_V2_SUBGRAPH_URL = "https://api.thegraph.com/subgraphs/name/uniswap/uniswap-v2"
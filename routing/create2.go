@@ -0,0 +1,43 @@
+package routing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"v2Routing/v2math"
+)
+
+// ErrPairNotDeployed is returned for reserves of a pair address without code, which happens for
+// addresses computed with CREATE2 for pairs that were never created
+var ErrPairNotDeployed = errors.New("pair not deployed")
+
+// ComputePairAddress returns the address the V2 factory deploys the tokenA/tokenB pair at:
+// keccak256(0xff ++ factory ++ keccak256(token0 ++ token1) ++ initCodeHash)[12:]
+func ComputePairAddress(factory common.Address, initCodeHash common.Hash, tokenA, tokenB common.Address) common.Address {
+	token0, token1 := v2math.SortTokens(tokenA, tokenB)
+	salt := crypto.Keccak256Hash(token0.Bytes(), token1.Bytes())
+	return crypto.CreateAddress2(factory, salt, initCodeHash.Bytes())
+}
+
+// Create2TradingPairProvider computes pair addresses locally instead of calling factory.getPair,
+// saving a round trip per token pair. The address is returned whether or not the pair was created,
+// reading its reserves fails with ErrPairNotDeployed when it was not. Forks with an unknown init
+// code hash keep using OnChainTradingPairProvider.
+type Create2TradingPairProvider struct {
+	factory      common.Address
+	initCodeHash common.Hash
+}
+
+func NewCreate2TradingPairProvider(factory common.Address, initCodeHash common.Hash) *Create2TradingPairProvider {
+	return &Create2TradingPairProvider{factory: factory, initCodeHash: initCodeHash}
+}
+
+func (p *Create2TradingPairProvider) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	if tokenA == tokenB {
+		return common.Address{}, nil
+	}
+	return ComputePairAddress(p.factory, p.initCodeHash, tokenA, tokenB), nil
+}
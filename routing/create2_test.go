@@ -0,0 +1,55 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestComputePairAddress(t *testing.T) {
+	// the token order must not matter
+	for _, tokens := range [][2]string{{WETH, USDC}, {USDC, WETH}} {
+		got := ComputePairAddress(common.HexToAddress(FACTORY_ADDRESS), common.HexToHash(INIT_CODE_HASH), common.HexToAddress(tokens[0]), common.HexToAddress(tokens[1]))
+		if got != common.HexToAddress(WETH_USDC) {
+			t.Errorf("got %v want %v", got, WETH_USDC)
+		}
+	}
+}
+
+// everyPairProvider returns an address for every pair like CREATE2 does, created or not
+type everyPairProvider struct{}
+
+func (everyPairProvider) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	return fakePairAddress(tokenA, tokenB), nil
+}
+
+func TestRouteSkipsUndeployedPairs(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1000000000)
+	router := newFakeRouter(graph)
+	router.tradingPairProvider = everyPairProvider{}
+	router.poolReservesProvider = &MulticallPoolReservesProvider{caller: &multicallFake{graph: graph, unknownHasNoCode: true}}
+
+	quote, err := router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if path := quote.Path(); len(path) != 3 || path[1] != common.HexToAddress(USDC) {
+		t.Errorf("got path %v want WETH -> USDC -> DAI", path)
+	}
+
+	// undeployed pairs do not count as pools of the token
+	_, err = router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WISE), common.HexToAddress(DAI), 2)
+	var noRouteErr *NoRouteError
+	if !errors.As(err, &noRouteErr) || len(noRouteErr.Reasons) != 1 || noRouteErr.Reasons[0] != NoPoolsForTokenIn {
+		t.Errorf("got error %v want %v", err, NoPoolsForTokenIn)
+	}
+
+	if _, _, err := router.poolReservesProvider.GetPoolReserves(context.Background(), fakePairAddress(common.HexToAddress(WISE), common.HexToAddress(DAI))); !errors.Is(err, ErrPairNotDeployed) {
+		t.Errorf("got error %v want ErrPairNotDeployed", err)
+	}
+}
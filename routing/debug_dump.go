@@ -0,0 +1,200 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/v2math"
+)
+
+// DebugDump is a snapshot of the router's state for post-mortem debugging of bad quotes
+type DebugDump struct {
+	Time time.Time `json:"time"`
+	// the most recently built pool graph, nil before the first route
+	Graph *GraphDump `json:"graph"`
+	// summaries of the caching providers the router uses, by role
+	Caches   map[string]CacheSummary `json:"caches"`
+	InFlight []InFlightRoute         `json:"inFlight"`
+}
+
+// GraphDump is a pool graph as the route search saw it
+type GraphDump struct {
+	BuiltAt time.Time `json:"builtAt"`
+	// block the reserves were read at, nil when reads were not pinned
+	BlockNumber *big.Int         `json:"blockNumber"`
+	Tokens      []common.Address `json:"tokens"`
+	Pools       []GraphPool      `json:"pools"`
+	// every pair looked up and whether it was searched
+	Edges []EdgeDiagnostic `json:"edges"`
+}
+
+// GraphPool is one pool of the graph, reserves are decimal strings in the tokens' smallest unit
+type GraphPool struct {
+	Token0   common.Address `json:"token0"`
+	Token1   common.Address `json:"token1"`
+	Pair     common.Address `json:"pair"`
+	DEX      string         `json:"dex"`
+	Reserve0 string         `json:"reserve0"`
+	Reserve1 string         `json:"reserve1"`
+}
+
+// CacheSummary describes the contents of a cache without listing them
+type CacheSummary struct {
+	Entries int    `json:"entries"`
+	Detail  string `json:"detail,omitempty"`
+	// lookups served from the cache and lookups passed on to the provider, since the start
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// cacheCounters counts the lookups of a cache for its CacheSummary
+type cacheCounters struct {
+	hits, misses atomic.Uint64
+}
+
+func (c *cacheCounters) count(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// summary is a CacheSummary of entries with the counted lookups
+func (c *cacheCounters) summary(entries int, detail string) CacheSummary {
+	return CacheSummary{Entries: entries, Detail: detail, Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// cacheSummarizer is implemented by providers that keep state between routes
+type cacheSummarizer interface {
+	CacheSummary() CacheSummary
+}
+
+// InFlightRoute is a route search that has started and not returned yet
+type InFlightRoute struct {
+	RequestID string         `json:"requestId"`
+	TradeType string         `json:"tradeType"`
+	TokenIn   common.Address `json:"tokenIn"`
+	TokenOut  common.Address `json:"tokenOut"`
+	Amount    string         `json:"amount"`
+	Started   time.Time      `json:"started"`
+}
+
+// debugState is the part of the router's state only kept for DebugDump
+type debugState struct {
+	mu        sync.Mutex
+	nextID    uint64
+	inFlight  map[uint64]InFlightRoute
+	lastGraph *GraphDump
+}
+
+// trackRoute lists a route as in flight until the returned func is called
+func (r *OnChainV2Router) trackRoute(ctx context.Context, tradeType tradeType, amount *big.Int, tokenIn, tokenOut common.Address) func() {
+	r.debug.mu.Lock()
+	defer r.debug.mu.Unlock()
+	if r.debug.inFlight == nil {
+		r.debug.inFlight = make(map[uint64]InFlightRoute)
+	}
+	id := r.debug.nextID
+	r.debug.nextID++
+	r.debug.inFlight[id] = InFlightRoute{
+		RequestID: RequestIDFromContext(ctx),
+		TradeType: tradeType.String(),
+		TokenIn:   tokenIn,
+		TokenOut:  tokenOut,
+		Amount:    amount.String(),
+		Started:   time.Now(),
+	}
+	return func() {
+		r.debug.mu.Lock()
+		defer r.debug.mu.Unlock()
+		delete(r.debug.inFlight, id)
+	}
+}
+
+// recordGraph keeps graph as the last one built, pools are listed once with the lower token first
+func (r *OnChainV2Router) recordGraph(ctx context.Context, graph *routeGraph) {
+	dump := &GraphDump{BuiltAt: time.Now(), BlockNumber: BlockNumberFromContext(ctx), Tokens: graph.tokens, Edges: graph.edges}
+	for key, pools := range graph.reserves {
+		if token0, _ := v2math.SortTokens(key.tokenIn, key.tokenOut); token0 != key.tokenIn {
+			continue
+		}
+		for _, pool := range pools {
+			dump.Pools = append(dump.Pools, GraphPool{
+				Token0:   key.tokenIn,
+				Token1:   key.tokenOut,
+				Pair:     pool.pair,
+				DEX:      pool.dex.Name(),
+				Reserve0: pool.reserveIn.String(),
+				Reserve1: pool.reserveOut.String(),
+			})
+		}
+	}
+	sort.Slice(dump.Pools, func(i, j int) bool {
+		return bytes.Compare(dump.Pools[i].Pair.Bytes(), dump.Pools[j].Pair.Bytes()) < 0
+	})
+	r.debug.mu.Lock()
+	defer r.debug.mu.Unlock()
+	r.debug.lastGraph = dump
+}
+
+// caches are the providers of the router that keep state between routes, by name
+func (r *OnChainV2Router) caches() map[string]cacheSummarizer {
+	providers := map[string]interface{}{
+		"pool reserves": r.poolReservesProvider,
+		"pools":         r.poolProvider,
+		"top tokens":    r.topTokensProvider,
+		"pair tokens":   r.pairTokensProvider,
+		"pool types":    r.poolTypeProvider,
+		"transfer fees": r.transferFeeProvider,
+	}
+	caches := make(map[string]cacheSummarizer)
+	for name, provider := range providers {
+		if summarizer, ok := provider.(cacheSummarizer); ok {
+			caches[name] = summarizer
+		}
+	}
+	return caches
+}
+
+// DebugDump returns the last pool graph, the caches of the providers and the routes in flight
+func (r *OnChainV2Router) DebugDump() DebugDump {
+	dump := DebugDump{Time: time.Now(), Caches: make(map[string]CacheSummary), InFlight: []InFlightRoute{}}
+	for name, cache := range r.caches() {
+		dump.Caches[name] = cache.CacheSummary()
+	}
+
+	r.debug.mu.Lock()
+	defer r.debug.mu.Unlock()
+	dump.Graph = r.debug.lastGraph
+	for _, route := range r.debug.inFlight {
+		dump.InFlight = append(dump.InFlight, route)
+	}
+	sort.Slice(dump.InFlight, func(i, j int) bool { return dump.InFlight[i].Started.Before(dump.InFlight[j].Started) })
+	return dump
+}
+
+// WriteDebugDump writes DebugDump as JSON to a new file in dir and returns its path
+func (r *OnChainV2Router) WriteDebugDump(dir string) (string, error) {
+	dump := r.DebugDump()
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("router-dump-%v.json", dump.Time.UnixNano()))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,60 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDebugDump(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1000000000)
+	router := newFakeRouter(graph)
+	// never queried with maxHops set, reported as an empty cache
+	router.topTokensProvider = NewSubgraphTopTokensProvider(SubgraphTopTokensConfig{URL: "http://localhost"})
+
+	if dump := router.DebugDump(); dump.Graph != nil || len(dump.InFlight) != 0 {
+		t.Errorf("got dump %+v want no graph and nothing in flight before routing", dump)
+	}
+	if _, err := router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 2); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	done := router.trackRoute(WithRequestID(context.Background(), "stuck"), exactOut, big.NewInt(5), common.HexToAddress(WETH), common.HexToAddress(USDC))
+	path, err := router.WriteDebugDump(t.TempDir())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	var dump DebugDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if dump.Graph == nil || len(dump.Graph.Tokens) != 3 || len(dump.Graph.Pools) != 2 {
+		t.Fatalf("got graph %+v want 3 tokens and 2 pools", dump.Graph)
+	}
+	for _, pool := range dump.Graph.Pools {
+		if pool.Pair == fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC)) && pool.Reserve0 != "2000000000" && pool.Reserve1 != "2000000000" {
+			t.Errorf("got pool %+v want the WETH/USDC reserves", pool)
+		}
+	}
+	if summary, ok := dump.Caches["top tokens"]; !ok || summary.Entries != 0 {
+		t.Errorf("got caches %v want an empty top tokens cache", dump.Caches)
+	}
+	if len(dump.InFlight) != 1 || dump.InFlight[0].RequestID != "stuck" || dump.InFlight[0].Amount != "5" || dump.InFlight[0].TradeType != exactOut.String() {
+		t.Errorf("got in flight %+v want the stuck route", dump.InFlight)
+	}
+
+	done()
+	if dump := router.DebugDump(); len(dump.InFlight) != 0 {
+		t.Errorf("got in flight %+v want none", dump.InFlight)
+	}
+}
@@ -0,0 +1,107 @@
+package routing
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultDecimalsCacheSize holds the decimals of far more tokens than a route ever touches
+const defaultDecimalsCacheSize = 10000
+
+// CachedTokenDecimalsProvider keeps the decimals of the most recently used tokens in memory, since
+// decimals never change they are not invalidated. Concurrent misses for the same token share one
+// read. To persist decimals across restarts, wrap a PoolRegistry's TokenDecimalsProvider.
+type CachedTokenDecimalsProvider struct {
+	provider TokenDecimalsProvider
+	size     int
+	group    singleflight.Group
+	counters cacheCounters
+
+	mu sync.Mutex
+	// most recently used first
+	order   *list.List
+	entries map[common.Address]*list.Element
+}
+
+type decimalsEntry struct {
+	token    common.Address
+	decimals uint8
+}
+
+// NewCachedTokenDecimalsProvider caches up to size tokens, 0 for defaultDecimalsCacheSize
+func NewCachedTokenDecimalsProvider(provider TokenDecimalsProvider, size int) *CachedTokenDecimalsProvider {
+	if size <= 0 {
+		size = defaultDecimalsCacheSize
+	}
+	return &CachedTokenDecimalsProvider{
+		provider: provider,
+		size:     size,
+		order:    list.New(),
+		entries:  make(map[common.Address]*list.Element),
+	}
+}
+
+func (p *CachedTokenDecimalsProvider) GetTokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	decimals, ok := p.cached(tokenAddress)
+	p.counters.count(ok)
+	if ok {
+		return decimals, nil
+	}
+	result, err, _ := p.group.Do(tokenAddress.Hex(), func() (interface{}, error) {
+		decimals, err := p.provider.GetTokenDecimals(ctx, tokenAddress)
+		if err != nil {
+			return nil, err
+		}
+		p.store(tokenAddress, decimals)
+		return decimals, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(uint8), nil
+}
+
+func (p *CachedTokenDecimalsProvider) cached(token common.Address) (uint8, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	element, ok := p.entries[token]
+	if !ok {
+		return 0, false
+	}
+	p.order.MoveToFront(element)
+	return element.Value.(decimalsEntry).decimals, true
+}
+
+// Prefetch stores the decimals of tokens read elsewhere, e.g. TokenListTopTokensProvider.Tokens
+// after verifying a token list on chain, so they are not read one token at a time
+func (p *CachedTokenDecimalsProvider) Prefetch(tokens []Token) {
+	for _, token := range tokens {
+		p.store(token.Address, token.Decimals)
+	}
+}
+
+func (p *CachedTokenDecimalsProvider) store(token common.Address, decimals uint8) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if element, ok := p.entries[token]; ok {
+		p.order.MoveToFront(element)
+		return
+	}
+	p.entries[token] = p.order.PushFront(decimalsEntry{token: token, decimals: decimals})
+	if p.order.Len() > p.size {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.entries, oldest.Value.(decimalsEntry).token)
+	}
+}
+
+func (p *CachedTokenDecimalsProvider) CacheSummary() CacheSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.counters.summary(p.order.Len(), fmt.Sprintf("capacity %v", p.size))
+}
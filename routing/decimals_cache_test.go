@@ -0,0 +1,33 @@
+package routing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCachedTokenDecimalsProvider(t *testing.T) {
+	ctx := context.Background()
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	decimals := &TokenDecimalsProviderMock{}
+	decimals.On("GetTokenDecimals", ctx, mock.Anything).Return()
+	provider := NewCachedTokenDecimalsProvider(decimals, 2)
+
+	for i := 0; i < 3; i++ {
+		provider.GetTokenDecimals(ctx, weth)
+		provider.GetTokenDecimals(ctx, usdc)
+	}
+	decimals.AssertNumberOfCalls(t, "GetTokenDecimals", 2)
+
+	// DAI evicts WETH, the least recently used token
+	provider.GetTokenDecimals(ctx, dai)
+	provider.GetTokenDecimals(ctx, usdc)
+	decimals.AssertNumberOfCalls(t, "GetTokenDecimals", 3)
+	provider.GetTokenDecimals(ctx, weth)
+	decimals.AssertNumberOfCalls(t, "GetTokenDecimals", 4)
+	if summary := provider.CacheSummary(); summary.Entries != 2 {
+		t.Errorf("got %v cached tokens want 2", summary.Entries)
+	}
+}
@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// AlgorithmVersion identifies the route search, bumped whenever the same reserves can price differently
+const AlgorithmVersion = "v2-dp-1"
+
+// Deployment describes the router build and data behind a quote so downstream systems can audit
+// which deployment produced a price. It is stamped on every Quote and RouteMetadata, and sent by
+// QuoteServer as response headers.
+type Deployment struct {
+	Environment  string `json:"environment,omitempty"`
+	DeploymentID string `json:"deploymentId,omitempty"`
+	// where pools and reserves are read from, defaults to the names of the DEX adapters
+	DataSources []string `json:"dataSources,omitempty"`
+	// terms the quote data is provided under
+	License string `json:"license,omitempty"`
+	// defaults to AlgorithmVersion
+	AlgorithmVersion string `json:"algorithmVersion"`
+	// VCS revision of the binary, read from its build info when empty
+	Build string `json:"build,omitempty"`
+}
+
+// withDefaults fills the fields the router can tell itself
+func (d Deployment) withDefaults(adapters []DEXAdapter) *Deployment {
+	if d.AlgorithmVersion == "" {
+		d.AlgorithmVersion = AlgorithmVersion
+	}
+	if d.Build == "" {
+		d.Build = vcsRevision()
+	}
+	if len(d.DataSources) == 0 {
+		for _, adapter := range adapters {
+			d.DataSources = append(d.DataSources, adapter.Name())
+		}
+	}
+	return &d
+}
+
+// setHeaders stamps the deployment on an HTTP response
+func (d *Deployment) setHeaders(header http.Header) {
+	for name, value := range map[string]string{
+		"X-Router-Environment":       d.Environment,
+		"X-Router-Deployment-Id":     d.DeploymentID,
+		"X-Router-Data-Sources":      strings.Join(d.DataSources, ", "),
+		"X-Router-License":           d.License,
+		"X-Router-Algorithm-Version": d.AlgorithmVersion,
+		"X-Router-Build":             d.Build,
+	} {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+}
+
+func vcsRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDeploymentStamping(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	router := NewOnChainV2Router(V2RouterConfig{
+		PoolProvider:         graph,
+		TradingPairProvider:  graph,
+		PoolReservesProvider: graph,
+		Deployment:           Deployment{Environment: "staging", DeploymentID: "eu-1", License: "CC-BY-NC-4.0"},
+	})
+
+	quote, err := router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(USDC), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	deployment := quote.Deployment
+	if deployment == nil || deployment.Environment != "staging" || deployment.DeploymentID != "eu-1" {
+		t.Fatalf("got deployment %+v want staging eu-1", deployment)
+	}
+	if deployment.AlgorithmVersion != AlgorithmVersion || !reflect.DeepEqual(deployment.DataSources, []string{"uniswap-v2"}) {
+		t.Errorf("got algorithm %v data sources %v want the defaults", deployment.AlgorithmVersion, deployment.DataSources)
+	}
+
+	recorder := httptest.NewRecorder()
+	NewQuoteServer(router, graph).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/quote?tokenIn="+WETH+"&tokenOut="+USDC+"&amountIn=1000", nil))
+	if got := recorder.Header().Get("X-Router-Deployment-Id"); got != "eu-1" {
+		t.Errorf("got deployment id header %q want eu-1", got)
+	}
+	if got := recorder.Header().Get("X-Router-License"); got != "CC-BY-NC-4.0" {
+		t.Errorf("got license header %q want CC-BY-NC-4.0", got)
+	}
+	var response QuoteResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if response.Deployment == nil || response.Deployment.AlgorithmVersion != AlgorithmVersion {
+		t.Errorf("got deployment %+v in the response", response.Deployment)
+	}
+}
@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DEXAdapter describes a constant product exchange the router can build its graph from
+type DEXAdapter interface {
+	Name() string
+	FactoryAddress() common.Address
+	// share of the input kept by the pool, 3/1000 for Uniswap V2
+	Fee() *big.Rat
+	// returns the zero address when the pair was never created
+	GetPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error)
+	GetAmountOut(amountIn, reserveIn, reserveOut *big.Int) (*big.Int, error)
+	GetAmountIn(amountOut, reserveIn, reserveOut *big.Int) (*big.Int, error)
+}
+
+// V2ForkAdapter covers Uniswap V2 and its forks, which only differ by factory and fee
+type V2ForkAdapter struct {
+	name           string
+	factory        common.Address
+	pairProvider   TradingPairProvider
+	feeNumerator   *big.Int
+	feeDenominator *big.Int
+}
+
+// NewV2ForkAdapter builds an adapter for a fork charging feeBips basis points per swap,
+// e.g. 25 for PancakeSwap V2
+func NewV2ForkAdapter(name string, factory common.Address, pairProvider TradingPairProvider, feeBips int64) *V2ForkAdapter {
+	return &V2ForkAdapter{
+		name:           name,
+		factory:        factory,
+		pairProvider:   pairProvider,
+		feeNumerator:   big.NewInt(10000 - feeBips),
+		feeDenominator: big.NewInt(10000),
+	}
+}
+
+func NewUniswapV2Adapter(pairProvider TradingPairProvider) *V2ForkAdapter {
+	return NewV2ForkAdapter("uniswap-v2", common.HexToAddress(FACTORY_ADDRESS), pairProvider, 30)
+}
+
+func NewSushiswapAdapter(pairProvider TradingPairProvider) *V2ForkAdapter {
+	return NewV2ForkAdapter("sushiswap", common.HexToAddress(SUSHISWAP_FACTORY_ADDRESS), pairProvider, 30)
+}
+
+func (a *V2ForkAdapter) Name() string {
+	return a.name
+}
+
+func (a *V2ForkAdapter) FactoryAddress() common.Address {
+	return a.factory
+}
+
+func (a *V2ForkAdapter) Fee() *big.Rat {
+	return new(big.Rat).SetFrac(new(big.Int).Sub(a.feeDenominator, a.feeNumerator), a.feeDenominator)
+}
+
+func (a *V2ForkAdapter) GetPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	return a.pairProvider.GetTradingPair(ctx, tokenA, tokenB)
+}
+
+func (a *V2ForkAdapter) GetAmountOut(amountIn, reserveIn, reserveOut *big.Int) (*big.Int, error) {
+	return getAmountOutWithFee(amountIn, reserveIn, reserveOut, a.feeNumerator, a.feeDenominator)
+}
+
+func (a *V2ForkAdapter) GetAmountIn(amountOut, reserveIn, reserveOut *big.Int) (*big.Int, error) {
+	return getAmountInWithFee(amountOut, reserveIn, reserveOut, a.feeNumerator, a.feeDenominator)
+}
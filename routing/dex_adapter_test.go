@@ -0,0 +1,123 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// dexPairsFake is the pair registry of one DEX, its pair addresses are salted by the DEX name
+type dexPairsFake struct {
+	name  string
+	pairs map[pairKey]common.Address
+}
+
+func (d *dexPairsFake) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	return d.pairs[newPairKey(tokenA, tokenB)], nil
+}
+
+// addDEXPool lists a pool on dex and records its reserves in graph under the DEX's pair address
+func addDEXPool(graph *v2GraphFake, dex *dexPairsFake, tokenA, tokenB common.Address, reserveA, reserveB int64) {
+	if dex.pairs == nil {
+		dex.pairs = make(map[pairKey]common.Address)
+	}
+	pair := common.BytesToAddress(crypto.Keccak256([]byte(dex.name), fakePairAddress(tokenA, tokenB).Bytes()))
+	dex.pairs[newPairKey(tokenA, tokenB)] = pair
+	dex.pairs[newPairKey(tokenB, tokenA)] = pair
+	graph.addPool(tokenA, tokenB, reserveA, reserveB)
+	graph.reserves[pair] = graph.reserves[fakePairAddress(tokenA, tokenB)]
+}
+
+func TestRouteAcrossDEXAdapters(t *testing.T) {
+	graph := &v2GraphFake{}
+	uniswap := &dexPairsFake{name: "uniswap-v2"}
+	sushiswap := &dexPairsFake{name: "sushiswap"}
+	addDEXPool(graph, uniswap, common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	addDEXPool(graph, uniswap, common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1000000000)
+	// sushiswap pays slightly more USDC per WETH but has no USDC/DAI pool
+	addDEXPool(graph, sushiswap, common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2010000000)
+	router := newFakeRouter(graph)
+	router.dexAdapters = []DEXAdapter{NewUniswapV2Adapter(uniswap), NewSushiswapAdapter(sushiswap)}
+
+	amountIn := big.NewInt(1000)
+	amountOut, path, metadata, err := router.RouteWithMetadata(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(DAI), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(path) != 3 || len(metadata.Hops) != 2 {
+		t.Fatalf("got path %v hops %+v want WETH -> USDC -> DAI", path, metadata.Hops)
+	}
+	wantDEX := []string{"sushiswap", "uniswap-v2"}
+	wantPair := []common.Address{sushiswap.pairs[newPairKey(path[0], path[1])], uniswap.pairs[newPairKey(path[1], path[2])]}
+	for i, hop := range metadata.Hops {
+		if hop.DEX != wantDEX[i] || hop.Pair != wantPair[i] || hop.TokenIn != path[i] || hop.TokenOut != path[i+1] {
+			t.Errorf("hop %d: got %+v want %v via %v", i, hop, wantDEX[i], wantPair[i])
+		}
+	}
+	usdc, _ := getAmountOut(amountIn, big.NewInt(1000000), big.NewInt(2010000000))
+	wantOut, _ := getAmountOut(usdc, big.NewInt(1000000000), big.NewInt(1000000000))
+	if amountOut.Cmp(wantOut) != 0 {
+		t.Errorf("got %v want %v", amountOut, wantOut)
+	}
+}
+
+func TestRouteParallelPoolsByAmount(t *testing.T) {
+	graph := &v2GraphFake{}
+	uniswap := &dexPairsFake{name: "uniswap-v2"}
+	pancakeswap := &dexPairsFake{name: "pancakeswap"}
+	weth, dai := common.HexToAddress(WETH), common.HexToAddress(DAI)
+	// the cheaper pool is shallower, it wins small trades and loses large ones to price impact
+	addDEXPool(graph, uniswap, weth, dai, 10000000000, 10000000000)
+	addDEXPool(graph, pancakeswap, weth, dai, 100000000, 100000000)
+	router := newFakeRouter(graph)
+	router.dexAdapters = []DEXAdapter{NewUniswapV2Adapter(uniswap), NewV2ForkAdapter("pancakeswap", common.Address{}, pancakeswap, 25)}
+
+	for _, test := range []struct {
+		amountIn int64
+		want     *dexPairsFake
+	}{
+		{10000, pancakeswap},
+		{1000000, uniswap},
+	} {
+		_, _, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(test.amountIn), weth, dai, 1)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if len(metadata.Hops) != 1 || metadata.Hops[0].Pair != test.want.pairs[newPairKey(weth, dai)] {
+			t.Errorf("amountIn %v: got hops %+v want the %v pool", test.amountIn, metadata.Hops, test.want.name)
+		}
+	}
+}
+
+func TestV2ForkAdapterFee(t *testing.T) {
+	pancakeswap := NewV2ForkAdapter("pancakeswap", common.Address{}, nil, 25)
+	gotOut, err := pancakeswap.GetAmountOut(big.NewInt(1000), big.NewInt(1000000), big.NewInt(1000000))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// 1000 * 9975 * 1e6 / (1e6 * 10000 + 1000 * 9975)
+	if gotOut.Cmp(big.NewInt(996)) != 0 {
+		t.Errorf("got %v want 996", gotOut)
+	}
+	if pancakeswap.Fee().Cmp(big.NewRat(25, 10000)) != 0 {
+		t.Errorf("got fee %v want 0.25%%", pancakeswap.Fee())
+	}
+
+	// the Uniswap adapter matches the library math
+	uniswap := NewUniswapV2Adapter(nil)
+	for _, amountIn := range []int64{1, 1000, 123456} {
+		want, _ := getAmountOut(big.NewInt(amountIn), big.NewInt(1000000), big.NewInt(2000000))
+		got, _ := uniswap.GetAmountOut(big.NewInt(amountIn), big.NewInt(1000000), big.NewInt(2000000))
+		if got.Cmp(want) != 0 {
+			t.Errorf("amountIn %d: got %v want %v", amountIn, got, want)
+		}
+		wantIn, _ := getAmountIn(big.NewInt(amountIn), big.NewInt(1000000), big.NewInt(2000000))
+		gotIn, _ := uniswap.GetAmountIn(big.NewInt(amountIn), big.NewInt(1000000), big.NewInt(2000000))
+		if gotIn.Cmp(wantIn) != 0 {
+			t.Errorf("amountOut %d: got %v want %v", amountIn, gotIn, wantIn)
+		}
+	}
+}
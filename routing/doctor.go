@@ -0,0 +1,186 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// defaultMaxClockSkew tolerates a few missed slots between the latest block and the local clock
+const defaultMaxClockSkew = time.Minute
+
+// DoctorClient is the part of an ethclient.Client the health checks use
+type DoctorClient interface {
+	bind.ContractCaller
+	BlockNumberProvider
+	ChainID(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// DoctorCheck is the outcome of one health check, Err is nil when it passed
+type DoctorCheck struct {
+	Name string
+	Err  error
+}
+
+// DoctorConfig configures NewDoctor, every field but Client is optional
+type DoctorConfig struct {
+	Client DoctorClient
+	// defaults to the ID of Chain
+	ChainID *big.Int
+	// defaults to Mainnet, the quote check trades its wrapped native token for its first stablecoin
+	Chain *Chain
+	// contracts that must have code, by name
+	Contracts             map[string]common.Address
+	TopTokensProvider     TopTokensProvider
+	TokenDecimalsProvider TokenDecimalsProvider
+	// checked with a known-good quote of 1 wrapped native token, WETH -> USDC on Mainnet
+	Router *OnChainV2Router
+	// reachability checks of caches and other backends, by name
+	Backends     map[string]func(ctx context.Context) error
+	MaxClockSkew time.Duration
+}
+
+// Doctor runs the self-test of a deployment: RPC, chain, contracts, token list, clock, backends and a quote
+type Doctor struct {
+	config DoctorConfig
+	now    func() time.Time
+}
+
+func NewDoctor(config DoctorConfig) *Doctor {
+	if config.Chain == nil {
+		config.Chain = &Mainnet
+	}
+	if config.ChainID == nil {
+		config.ChainID = big.NewInt(config.Chain.ID)
+	}
+	if config.MaxClockSkew == 0 {
+		config.MaxClockSkew = defaultMaxClockSkew
+	}
+	return &Doctor{config: config, now: time.Now}
+}
+
+// Run executes every check, later checks still run when earlier ones fail
+func (d *Doctor) Run(ctx context.Context) []DoctorCheck {
+	checks := []DoctorCheck{
+		{Name: "rpc connectivity", Err: d.checkRPC(ctx)},
+		{Name: "chain id", Err: d.checkChainID(ctx)},
+	}
+	names := make([]string, 0, len(d.config.Contracts))
+	for name := range d.config.Contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checks = append(checks, DoctorCheck{Name: name + " code", Err: d.checkCode(ctx, d.config.Contracts[name])})
+	}
+	if d.config.TopTokensProvider != nil {
+		checks = append(checks, DoctorCheck{Name: "token list", Err: d.checkTokenList(ctx)})
+	}
+	checks = append(checks, DoctorCheck{Name: "clock skew", Err: d.checkClockSkew(ctx)})
+	names = names[:0]
+	for name := range d.config.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checks = append(checks, DoctorCheck{Name: name, Err: d.config.Backends[name](ctx)})
+	}
+	if d.config.Router != nil {
+		checks = append(checks, DoctorCheck{Name: "wrapped native -> stablecoin quote", Err: d.checkQuote(ctx)})
+	}
+	return checks
+}
+
+func (d *Doctor) checkRPC(ctx context.Context) error {
+	_, err := d.config.Client.BlockNumber(ctx)
+	return err
+}
+
+func (d *Doctor) checkChainID(ctx context.Context) error {
+	chainID, err := d.config.Client.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	if chainID.Cmp(d.config.ChainID) != 0 {
+		return fmt.Errorf("connected to chain %v, expected %v", chainID, d.config.ChainID)
+	}
+	return nil
+}
+
+func (d *Doctor) checkCode(ctx context.Context, address common.Address) error {
+	code, err := d.config.Client.CodeAt(ctx, address, nil)
+	if err != nil {
+		return err
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("no code at %v", address.String())
+	}
+	return nil
+}
+
+// checkTokenList requires distinct tokens that are contracts with readable decimals
+func (d *Doctor) checkTokenList(ctx context.Context) error {
+	tokens, err := d.config.TopTokensProvider.GetTopTokens(ctx)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return errors.New("no top tokens")
+	}
+	seen := make(map[common.Address]bool)
+	for _, token := range tokens {
+		if seen[token] {
+			return fmt.Errorf("token %v is listed twice", token.String())
+		}
+		seen[token] = true
+		if err := d.checkCode(ctx, token); err != nil {
+			return fmt.Errorf("token %v: %w", token.String(), err)
+		}
+		if d.config.TokenDecimalsProvider == nil {
+			continue
+		}
+		if _, err := d.config.TokenDecimalsProvider.GetTokenDecimals(ctx, token); err != nil {
+			return fmt.Errorf("token %v decimals: %w", token.String(), err)
+		}
+	}
+	return nil
+}
+
+// checkClockSkew compares the local clock with the timestamp of the latest block
+func (d *Doctor) checkClockSkew(ctx context.Context) error {
+	header, err := d.config.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	skew := d.now().Sub(time.Unix(int64(header.Time), 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > d.config.MaxClockSkew {
+		return fmt.Errorf("local clock is %v away from block %v", skew.Round(time.Second), header.Number)
+	}
+	return nil
+}
+
+func (d *Doctor) checkQuote(ctx context.Context) error {
+	if len(d.config.Chain.Stablecoins) == 0 {
+		return fmt.Errorf("chain %v has no stablecoins to quote", d.config.Chain.Name)
+	}
+	oneToken := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	quote, err := d.config.Router.Route(ctx, oneToken, d.config.Chain.WrappedNative, d.config.Chain.Stablecoins[0], AutoMaxHops)
+	if err != nil {
+		return err
+	}
+	if quote.AmountOut.Sign() <= 0 {
+		return errors.New("quote returned no stablecoin")
+	}
+	return nil
+}
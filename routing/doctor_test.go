@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type doctorClientFake struct {
+	chainID   int64
+	code      map[common.Address]bool
+	blockTime time.Time
+}
+
+func (c *doctorClientFake) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if c.code[contract] {
+		return []byte{1}, nil
+	}
+	return nil, nil
+}
+
+func (c *doctorClientFake) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *doctorClientFake) BlockNumber(ctx context.Context) (uint64, error) {
+	return 16000000, nil
+}
+
+func (c *doctorClientFake) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(c.chainID), nil
+}
+
+func (c *doctorClientFake) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(16000000), Time: uint64(c.blockTime.Unix())}, nil
+}
+
+func TestDoctor(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000000000000000, 2000000000)
+	client := &doctorClientFake{
+		chainID:   1,
+		code:      map[common.Address]bool{common.HexToAddress(FACTORY_ADDRESS): true},
+		blockTime: now.Add(-12 * time.Second),
+	}
+	for _, token := range []string{WETH, USDC, DAI, USDT, WBTC, UNI} {
+		client.code[common.HexToAddress(token)] = true
+	}
+	doctor := NewDoctor(DoctorConfig{
+		Client:                client,
+		Contracts:             map[string]common.Address{"uniswap v2 factory": common.HexToAddress(FACTORY_ADDRESS), "multicall3": common.HexToAddress(MULTICALL3_ADDRESS)},
+		TopTokensProvider:     &StaticTopTokensProvider{},
+		TokenDecimalsProvider: graph,
+		Router:                newFakeRouter(graph),
+		Backends:              map[string]func(ctx context.Context) error{"websocket": func(ctx context.Context) error { return errors.New("dial failed") }},
+	})
+	doctor.now = func() time.Time { return now }
+
+	failed := map[string]bool{}
+	for _, check := range doctor.Run(context.Background()) {
+		if check.Err != nil {
+			failed[check.Name] = true
+		}
+	}
+	want := map[string]bool{"multicall3 code": true, "websocket": true}
+	if len(failed) != len(want) {
+		t.Errorf("got failed checks %v want %v", failed, want)
+	}
+	for name := range want {
+		if !failed[name] {
+			t.Errorf("got check %v passing want it failed", name)
+		}
+	}
+
+	client.chainID = 5
+	client.blockTime = now.Add(-time.Hour)
+	failed = map[string]bool{}
+	for _, check := range doctor.Run(context.Background()) {
+		if check.Err != nil {
+			failed[check.Name] = true
+		}
+	}
+	if !failed["chain id"] || !failed["clock skew"] {
+		t.Errorf("got failed checks %v want chain id and clock skew among them", failed)
+	}
+}
@@ -0,0 +1,70 @@
+package routing
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EdgeStatus tells whether a candidate pair made it into the search graph and why not
+type EdgeStatus int
+
+const (
+	// the pair is searched
+	EdgeActive EdgeStatus = iota
+	// the factory has no pair for the tokens
+	EdgeNoPair
+	// the pair address was computed but nothing is deployed there
+	EdgeNotDeployed
+	// the factory returned a contract that is not a V2 pair
+	EdgeNotV2Pool
+)
+
+func (s EdgeStatus) String() string {
+	switch s {
+	case EdgeActive:
+		return "active"
+	case EdgeNoPair:
+		return "no pair"
+	case EdgeNotDeployed:
+		return "not deployed"
+	default:
+		return "not a v2 pool"
+	}
+}
+
+func (s EdgeStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *EdgeStatus) UnmarshalText(text []byte) error {
+	for status := EdgeActive; status <= EdgeNotV2Pool; status++ {
+		if status.String() == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown edge status %q", text)
+}
+
+// EdgeDiagnostic is the outcome of looking up one token pair on one DEX while building the graph,
+// missing pairs are left out of the search instead of failing the route
+type EdgeDiagnostic struct {
+	TokenA common.Address `json:"tokenA"`
+	TokenB common.Address `json:"tokenB"`
+	DEX    string         `json:"dex"`
+	// zero for EdgeNoPair
+	Pair   common.Address `json:"pair"`
+	Status EdgeStatus     `json:"status"`
+}
+
+// skippedEdges counts the candidate pairs left out of the graph
+func skippedEdges(edges []EdgeDiagnostic) int {
+	skipped := 0
+	for _, edge := range edges {
+		if edge.Status != EdgeActive {
+			skipped++
+		}
+	}
+	return skipped
+}
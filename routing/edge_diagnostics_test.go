@@ -0,0 +1,49 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRouteReportsSkippedEdges(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(DAI), common.HexToAddress(USDC), 1000000000, 1000000000)
+	router := newFakeRouter(graph)
+
+	_, _, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(WISE), 2)
+	if err == nil {
+		t.Fatal("expected no route to WISE")
+	}
+	statuses := make(map[EdgeStatus]int)
+	for _, edge := range metadata.Edges {
+		statuses[edge.Status]++
+		if edge.Status == EdgeNoPair && edge.Pair != (common.Address{}) {
+			t.Errorf("edge %v -> %v has no pair but lists %v", edge.TokenA, edge.TokenB, edge.Pair)
+		}
+	}
+	// WISE is paired with none of the three other tokens
+	if statuses[EdgeActive] != 3 || statuses[EdgeNoPair] != 3 {
+		t.Errorf("got edge statuses %v want 3 active and 3 without a pair", statuses)
+	}
+
+	// computed addresses without code and contracts that are not pairs are reported as such
+	router.tradingPairProvider = everyPairProvider{}
+	router.poolReservesProvider = &MulticallPoolReservesProvider{caller: &multicallFake{graph: graph, unknownHasNoCode: true}}
+	router.poolTypeProvider = poolTypeProviderMock{fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC)): PoolTypeUnknown}
+	_, _, metadata, err = router.RouteWithMetadata(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(WISE), 2)
+	if err == nil {
+		t.Fatal("expected no route to WISE")
+	}
+	statuses = make(map[EdgeStatus]int)
+	for _, edge := range metadata.Edges {
+		statuses[edge.Status]++
+	}
+	if statuses[EdgeActive] != 2 || statuses[EdgeNotV2Pool] != 1 || statuses[EdgeNotDeployed] != 3 || statuses[EdgeNoPair] != 0 {
+		t.Errorf("got edge statuses %v want 2 active, 1 not a v2 pool and 3 not deployed", statuses)
+	}
+}
@@ -0,0 +1,123 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrENSNameNotFound is returned for names without a resolver or without an address set
+var ErrENSNameNotFound = errors.New("ENS name not found")
+
+// the registry's resolver and the resolver's addr, both take the namehash of the name
+const ensABI = `[
+	{"name":"resolver","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"name":"addr","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]}
+]`
+
+// ENSResolver resolves ENS names like dai.tokens.ethers.eth to the address their resolver
+// returns, through the ENS registry of Chain.ENSRegistry
+type ENSResolver struct {
+	caller   bind.ContractCaller
+	registry common.Address
+}
+
+func NewENSResolver(caller bind.ContractCaller, registry common.Address) *ENSResolver {
+	return &ENSResolver{caller: caller, registry: registry}
+}
+
+// IsENSName reports whether input is shaped like an ENS name rather than an address or a symbol:
+// dot separated labels without spaces
+func IsENSName(input string) bool {
+	input = strings.TrimSpace(input)
+	if common.IsHexAddress(input) || strings.ContainsAny(input, " \t") {
+		return false
+	}
+	labels := strings.Split(input, ".")
+	for _, label := range labels {
+		if label == "" {
+			return false
+		}
+	}
+	return len(labels) > 1
+}
+
+// NameHash is the EIP-137 namehash of name. Names are only lowercased, not normalized with the
+// full ENSIP-15 rules, so names outside ASCII may hash differently than in wallets.
+func NameHash(name string) common.Hash {
+	node := common.Hash{}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node.Bytes(), crypto.Keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// Resolve returns the address name resolves to, ErrENSNameNotFound when it has none
+func (r *ENSResolver) Resolve(ctx context.Context, name string) (common.Address, error) {
+	if !IsENSName(name) {
+		return common.Address{}, fmt.Errorf("%q is not an ENS name", name)
+	}
+	node := NameHash(name)
+	resolver, err := r.call(ctx, r.registry, "resolver", node)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("reading resolver of %v: %w", name, err)
+	}
+	if resolver == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%w: %v has no resolver", ErrENSNameNotFound, name)
+	}
+	address, err := r.call(ctx, resolver, "addr", node)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("resolving %v: %w", name, err)
+	}
+	if address == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%w: %v has no address", ErrENSNameNotFound, name)
+	}
+	return address, nil
+}
+
+func (r *ENSResolver) call(ctx context.Context, contract common.Address, method string, node common.Hash) (common.Address, error) {
+	parsed, err := abi.JSON(strings.NewReader(ensABI))
+	if err != nil {
+		return common.Address{}, err
+	}
+	input, err := parsed.Pack(method, node)
+	if err != nil {
+		return common.Address{}, err
+	}
+	output, err := r.caller.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: input}, BlockNumberFromContext(ctx))
+	if err != nil {
+		return common.Address{}, err
+	}
+	values, err := parsed.Unpack(method, output)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return values[0].(common.Address), nil
+}
+
+// ResolveAddress parses input as an address, or else resolves it as an ENS name, e.g. the
+// recipient of a swap. ens may be nil on chains without ENS, names are errors then.
+func ResolveAddress(ctx context.Context, input string, ens *ENSResolver) (common.Address, error) {
+	if address, err := ParseAddress(input); err == nil {
+		return address, nil
+	}
+	if !IsENSName(input) {
+		return common.Address{}, fmt.Errorf("%q is neither an address nor an ENS name", strings.TrimSpace(input))
+	}
+	if ens == nil {
+		return common.Address{}, fmt.Errorf("cannot resolve %v, ENS is not deployed on this chain", strings.TrimSpace(input))
+	}
+	return ens.Resolve(ctx, input)
+}
@@ -0,0 +1,83 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ensFake answers resolver(node) on the registry and addr(node) on resolvers, both from the
+// records of the called contract
+type ensFake map[common.Address]map[common.Hash]common.Address
+
+func (f ensFake) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (f ensFake) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	records, ok := f[*call.To]
+	if !ok {
+		return nil, errors.New("execution reverted")
+	}
+	node := common.BytesToHash(call.Data[4:36])
+	return common.LeftPadBytes(records[node].Bytes(), 32), nil
+}
+
+func TestNameHash(t *testing.T) {
+	// vectors from EIP-137
+	tests := map[string]string{
+		"":        "0x0000000000000000000000000000000000000000000000000000000000000000",
+		"eth":     "0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae",
+		"foo.eth": "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+		"Foo.ETH": "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+	}
+	for name, want := range tests {
+		if got := NameHash(name); got != common.HexToHash(want) {
+			t.Errorf("NameHash(%q) = %v want %v", name, got, want)
+		}
+	}
+}
+
+func TestENSResolver(t *testing.T) {
+	registry, resolver := common.HexToAddress(ENS_REGISTRY_ADDRESS), common.HexToAddress("0x4976fb03C32e5B8cfe2b6cCB31c09Ba78EBaBa41")
+	alice, dai := common.HexToAddress("0x1234"), common.HexToAddress(DAI)
+	ens := NewENSResolver(ensFake{
+		registry: {NameHash("alice.eth"): resolver, NameHash("dai.tokens.ethers.eth"): resolver, NameHash("unset.eth"): resolver},
+		resolver: {NameHash("alice.eth"): alice, NameHash("dai.tokens.ethers.eth"): dai},
+	}, registry)
+	ctx := context.Background()
+
+	if address, err := ResolveAddress(ctx, "alice.eth", ens); err != nil || address != alice {
+		t.Errorf("got %v error %v want alice's address", address, err)
+	}
+	if address, err := ResolveAddress(ctx, WETH, nil); err != nil || address != common.HexToAddress(WETH) {
+		t.Errorf("got %v error %v want addresses to pass through without ENS", address, err)
+	}
+	for _, name := range []string{"unset.eth", "nobody.eth"} {
+		if _, err := ResolveAddress(ctx, name, ens); !errors.Is(err, ErrENSNameNotFound) {
+			t.Errorf("%v: got error %v want ErrENSNameNotFound", name, err)
+		}
+	}
+	for _, input := range []string{"alice", "alice..eth", "0x1234"} {
+		if _, err := ResolveAddress(ctx, input, ens); err == nil {
+			t.Errorf("%q: got no error for an input that is neither an address nor a name", input)
+		}
+	}
+	if _, err := ResolveAddress(ctx, "alice.eth", nil); err == nil {
+		t.Error("got no error resolving a name on a chain without ENS")
+	}
+
+	// token inputs fall back to names when no base token has the symbol, USDC.e stays a symbol
+	usdce := common.HexToAddress("0xFF970A61A04b1cA14834A43f5dE4533eBDDB5CC8")
+	symbols := tokenSymbolsFake{usdce: "USDC.e"}
+	if token, err := ResolveToken(ctx, "dai.tokens.ethers.eth", []common.Address{usdce}, symbols, ens); err != nil || token != dai {
+		t.Errorf("got %v error %v want DAI", token, err)
+	}
+	if token, err := ResolveToken(ctx, "usdc.e", []common.Address{usdce}, symbols, ens); err != nil || token != usdce {
+		t.Errorf("got %v error %v want the base token with the symbol", token, err)
+	}
+}
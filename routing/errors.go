@@ -0,0 +1,21 @@
+package routing
+
+import (
+	"errors"
+
+	"v2Routing/v2math"
+)
+
+// Errors callers can branch on with errors.Is, they are returned wrapped with the tokens, pairs
+// or limits involved
+var (
+	ErrSameToken = errors.New("tokenIn and tokenOut cannot be the same")
+	// the tokens have no pair, or its CREATE2 address was never deployed
+	ErrPairNotFound = errors.New("pair not found")
+	// matched by NoRouteError
+	ErrNoRoute         = errors.New("no route")
+	ErrMaxHopsExceeded = errors.New("maxHops exceeds the limit")
+	// a pool is empty or too shallow for the amount, matched by InsufficientReservesError and by
+	// NoRouteErrors for that reason. It is v2math's error so amounts priced by either package match.
+	ErrInsufficientLiquidity = v2math.ErrInsufficientReserves
+)
@@ -0,0 +1,150 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// PoolEventHandler consumes decoded V2 pair events, e.g. to keep reserves up to date
+type PoolEventHandler interface {
+	HandleSync(ctx context.Context, event *MainSync) error
+	HandleSwap(ctx context.Context, event *MainSwap) error
+}
+
+// PoolEventReplayer feeds recorded pair logs through a set of handlers in chain order,
+// so event-driven behavior can be exercised deterministically without a live node
+type PoolEventReplayer struct {
+	filterer  *MainFilterer
+	syncTopic common.Hash
+	swapTopic common.Hash
+	handlers  []PoolEventHandler
+}
+
+func NewPoolEventReplayer(handlers ...PoolEventHandler) (*PoolEventReplayer, error) {
+	parsed, err := abi.JSON(strings.NewReader(MainABI))
+	if err != nil {
+		return nil, err
+	}
+	// the address is irrelevant for decoding, logs carry their own pair address
+	filterer, err := NewMainFilterer(common.Address{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PoolEventReplayer{
+		filterer:  filterer,
+		syncTopic: parsed.Events["Sync"].ID,
+		swapTopic: parsed.Events["Swap"].ID,
+		handlers:  handlers,
+	}, nil
+}
+
+// LoadEventFixture reads a JSON array of logs in the eth_getLogs response format
+func LoadEventFixture(path string) ([]types.Log, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var logs []types.Log
+	if err := json.Unmarshal(data, &logs); err != nil {
+		return nil, fmt.Errorf("decoding event fixture %v: %w", path, err)
+	}
+	return logs, nil
+}
+
+// Replay dispatches Sync and Swap logs to every handler ordered by (block, log index).
+// Removed logs and events other than Sync/Swap are skipped.
+func (r *PoolEventReplayer) Replay(ctx context.Context, logs []types.Log) error {
+	ordered := make([]types.Log, len(logs))
+	copy(ordered, logs)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].BlockNumber != ordered[j].BlockNumber {
+			return ordered[i].BlockNumber < ordered[j].BlockNumber
+		}
+		return ordered[i].Index < ordered[j].Index
+	})
+	for _, log := range ordered {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if log.Removed || len(log.Topics) == 0 {
+			continue
+		}
+		switch log.Topics[0] {
+		case r.syncTopic:
+			event, err := r.filterer.ParseSync(log)
+			if err != nil {
+				return fmt.Errorf("decoding Sync log %v/%v: %w", log.BlockNumber, log.Index, err)
+			}
+			for _, handler := range r.handlers {
+				if err := handler.HandleSync(ctx, event); err != nil {
+					return err
+				}
+			}
+		case r.swapTopic:
+			event, err := r.filterer.ParseSwap(log)
+			if err != nil {
+				return fmt.Errorf("decoding Swap log %v/%v: %w", log.BlockNumber, log.Index, err)
+			}
+			for _, handler := range r.handlers {
+				if err := handler.HandleSwap(ctx, event); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// EventPoolReservesProvider is a PoolReservesProvider backed purely by Sync events
+type EventPoolReservesProvider struct {
+	mu       sync.RWMutex
+	reserves map[common.Address][2]*big.Int
+	swaps    map[common.Address]int
+}
+
+func (p *EventPoolReservesProvider) HandleSync(ctx context.Context, event *MainSync) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reserves == nil {
+		p.reserves = make(map[common.Address][2]*big.Int)
+	}
+	p.reserves[event.Raw.Address] = [2]*big.Int{event.Reserve0, event.Reserve1}
+	return nil
+}
+
+func (p *EventPoolReservesProvider) HandleSwap(ctx context.Context, event *MainSwap) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.swaps == nil {
+		p.swaps = make(map[common.Address]int)
+	}
+	p.swaps[event.Raw.Address]++
+	return nil
+}
+
+func (p *EventPoolReservesProvider) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	reserves, ok := p.reserves[pairAddress]
+	if !ok {
+		return nil, nil, fmt.Errorf("no Sync event seen for pair %v", pairAddress.String())
+	}
+	return new(big.Int).Set(reserves[0]), new(big.Int).Set(reserves[1]), nil
+}
+
+// SwapCount returns how many Swap events were observed for the pair
+func (p *EventPoolReservesProvider) SwapCount(pairAddress common.Address) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.swaps[pairAddress]
+}
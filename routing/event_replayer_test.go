@@ -0,0 +1,41 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestPoolEventReplayer(t *testing.T) {
+	ctx := context.Background()
+	logs, err := LoadEventFixture("testdata/sync_swap_events.json")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	reservesProvider := &EventPoolReservesProvider{}
+	replayer, err := NewPoolEventReplayer(reservesProvider)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if err := replayer.Replay(ctx, logs); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	// the fixture is out of order and ends with a removed log, the latest live Sync must win
+	reserve0, reserve1, err := reservesProvider.GetPoolReserves(ctx, common.HexToAddress(WETH_USDC))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if reserve0.Cmp(big.NewInt(110)) != 0 || reserve1.Cmp(big.NewInt(20)) != 0 {
+		t.Errorf("got %d/%d want 110/20", reserve0, reserve1)
+	}
+	if got := reservesProvider.SwapCount(common.HexToAddress(WETH_USDC)); got != 1 {
+		t.Errorf("got %d swaps want 1", got)
+	}
+
+	if _, _, err := reservesProvider.GetPoolReserves(ctx, common.HexToAddress(WETH)); err == nil {
+		t.Errorf("expected error for a pair without Sync events")
+	}
+}
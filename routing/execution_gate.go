@@ -0,0 +1,366 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrExecutionNotFound = errors.New("no pending execution with this id, it may have expired")
+	ErrInvalidApproval   = errors.New("approval is not signed by an approver")
+	ErrSignatureRequired = errors.New("approvals must be signed")
+	ErrExecutionSending  = errors.New("execution is already being sent")
+	ErrDeadlineTooEarly  = errors.New("swap deadline passes before the execution can be approved and sent")
+	defaultExecutionTTL  = 15 * time.Minute
+	// time left after the last moment of approval to simulate and send the swap
+	executionSendMargin   = 5 * time.Minute
+	pendingExecutionGlob  = "execution-*.json"
+	pendingExecutionPaths = "execution-%v.json"
+)
+
+// ExecutionGateConfig configures NewExecutionGate
+type ExecutionGateConfig struct {
+	// swaps selling more than the threshold of a token, in its smallest unit, wait for a second
+	// approval, swaps of other tokens are sent right away
+	Thresholds map[common.Address]*big.Int
+	// accounts whose signed approval messages release a pending execution, required unless
+	// AllowUnsignedConfirm is set
+	Approvers []common.Address
+	// lets Confirm, and Handler without a signature, release pending executions. Only set it when
+	// whoever can reach them is trusted, e.g. behind an authenticated operator endpoint.
+	AllowUnsignedConfirm bool
+	// how long an execution waits for its approval, 15 minutes when zero
+	TTL time.Duration
+	// pending executions are kept as JSON files here so they survive restarts
+	Dir string
+}
+
+// PendingExecution is a swap held back until a second approval
+type PendingExecution struct {
+	ID   string  `json:"id"`
+	Swap *SwapTx `json:"swap"`
+	// what the swap was built from, to simulate it again when it is approved
+	Quote     *Quote         `json:"quote"`
+	Options   SwapOptions    `json:"options"`
+	Token     common.Address `json:"token"`
+	Amount    *big.Int       `json:"amount"`
+	CreatedAt time.Time      `json:"createdAt"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+	// what the swap counts towards the exposure limits of the TxBuilder, counted again when the
+	// gate is loaded after a restart
+	Exposure *ExposureReservation `json:"exposure,omitempty"`
+}
+
+// ApprovalMessage is the text approvers sign (EIP-191 personal message) to release the execution,
+// it commits to the exact call that will be sent
+func (p *PendingExecution) ApprovalMessage() string {
+	digest := crypto.Keccak256Hash(p.Swap.To.Bytes(), common.LeftPadBytes(p.Swap.Value.Bytes(), 32), p.Swap.Data)
+	return fmt.Sprintf("approve execution %v of %v to %v with call digest %v", p.ID, p.Swap.Method, p.Swap.To.Hex(), digest.Hex())
+}
+
+// ApprovalRequiredError is returned by ExecutionGate.Execute for swaps held back for approval
+type ApprovalRequiredError struct {
+	Execution *PendingExecution
+}
+
+func (e *ApprovalRequiredError) Error() string {
+	return fmt.Sprintf("swap of %v %v needs a second approval, pending as %v until %v", e.Execution.Amount, e.Execution.Token.Hex(), e.Execution.ID, e.Execution.ExpiresAt.Format(time.RFC3339))
+}
+
+// ExecutionGate sends small swaps through an Executor directly and holds large ones until a second
+// approval, either a message signed by an approver or a confirmation through Handler
+type ExecutionGate struct {
+	executor *Executor
+	config   ExecutionGateConfig
+	now      func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*PendingExecution
+	// approved executions being sent, they stay pending until the send succeeds
+	sending map[string]bool
+}
+
+// NewExecutionGate loads the executions still pending in config.Dir
+func NewExecutionGate(executor *Executor, config ExecutionGateConfig) (*ExecutionGate, error) {
+	if len(config.Approvers) == 0 && !config.AllowUnsignedConfirm {
+		return nil, errors.New("execution gate needs approvers, or AllowUnsignedConfirm to release executions without a signature")
+	}
+	if config.TTL == 0 {
+		config.TTL = defaultExecutionTTL
+	}
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	g := &ExecutionGate{executor: executor, config: config, now: time.Now, pending: make(map[string]*PendingExecution), sending: make(map[string]bool)}
+	paths, err := filepath.Glob(filepath.Join(config.Dir, pendingExecutionGlob))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var execution PendingExecution
+		if err := json.Unmarshal(data, &execution); err != nil {
+			return nil, fmt.Errorf("decoding pending execution %v: %w", path, err)
+		}
+		if execution.Swap != nil {
+			executor.builder.restore(execution.Swap, execution.Exposure)
+		}
+		g.pending[execution.ID] = &execution
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expireLocked()
+	return g, nil
+}
+
+// Execute sends the swap of quote, or holds it and returns an ApprovalRequiredError when it sells
+// more than the threshold of its input token. Held swaps must stay valid until they expire plus a
+// few minutes to send them: without a deadline in options they get one, and an earlier deadline
+// fails with ErrDeadlineTooEarly.
+func (g *ExecutionGate) Execute(ctx context.Context, quote *Quote, options SwapOptions) (common.Hash, error) {
+	threshold, ok := g.config.Thresholds[quote.TokenIn]
+	if !ok || quote.AmountInMax == nil || quote.AmountInMax.Cmp(threshold) <= 0 {
+		return g.executor.Execute(ctx, quote, options)
+	}
+	now := g.now()
+	expiresAt := now.Add(g.config.TTL)
+	earliest := expiresAt.Add(executionSendMargin)
+	if options.Deadline.IsZero() {
+		options.Deadline = earliest
+	} else if options.Deadline.Before(earliest) {
+		return common.Hash{}, fmt.Errorf("%w: deadline %v, expiry %v", ErrDeadlineTooEarly, options.Deadline.Format(time.RFC3339), expiresAt.Format(time.RFC3339))
+	}
+	swap, err := g.executor.builder.Build(quote, options)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	execution := &PendingExecution{
+		ID:        NewRequestID(),
+		Swap:      swap,
+		Quote:     quote,
+		Options:   options,
+		Token:     quote.TokenIn,
+		Amount:    quote.AmountInMax,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+		Exposure:  swap.exposure,
+	}
+	data, err := json.MarshalIndent(execution, "", "  ")
+	if err != nil {
+		g.executor.builder.Release(swap)
+		return common.Hash{}, err
+	}
+	if err := os.WriteFile(g.path(execution.ID), data, 0o600); err != nil {
+		g.executor.builder.Release(swap)
+		return common.Hash{}, err
+	}
+	g.mu.Lock()
+	g.pending[execution.ID] = execution
+	g.mu.Unlock()
+	return common.Hash{}, &ApprovalRequiredError{Execution: execution}
+}
+
+// Pending returns the executions waiting for approval, oldest first
+func (g *ExecutionGate) Pending() []*PendingExecution {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expireLocked()
+	pending := make([]*PendingExecution, 0, len(g.pending))
+	for _, execution := range g.pending {
+		pending = append(pending, execution)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending
+}
+
+// ApproveWithSignature sends the execution when signature is an approver's signature of its
+// ApprovalMessage. Like Executor.Execute, the swap is simulated first, against the state at approval
+// time, and it stays pending when the simulation or the send fails.
+func (g *ExecutionGate) ApproveWithSignature(ctx context.Context, id string, signature []byte) (common.Hash, error) {
+	execution, err := g.take(id, func(execution *PendingExecution) error {
+		signer, err := recoverPersonalSigner(execution.ApprovalMessage(), signature)
+		if err != nil {
+			return err
+		}
+		for _, approver := range g.config.Approvers {
+			if signer == approver {
+				return nil
+			}
+		}
+		return ErrInvalidApproval
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return g.send(ctx, execution)
+}
+
+// Confirm sends the execution without a signature, only allowed with AllowUnsignedConfirm. The
+// swap is simulated and sent as in ApproveWithSignature.
+func (g *ExecutionGate) Confirm(ctx context.Context, id string) (common.Hash, error) {
+	execution, err := g.take(id, func(*PendingExecution) error {
+		if !g.config.AllowUnsignedConfirm {
+			return ErrSignatureRequired
+		}
+		return nil
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return g.send(ctx, execution)
+}
+
+// take marks the execution as being sent once check accepts it, so it cannot be sent twice
+func (g *ExecutionGate) take(id string, check func(*PendingExecution) error) (*PendingExecution, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expireLocked()
+	execution, ok := g.pending[id]
+	if !ok {
+		return nil, ErrExecutionNotFound
+	}
+	if g.sending[id] {
+		return nil, ErrExecutionSending
+	}
+	if err := check(execution); err != nil {
+		return nil, err
+	}
+	g.sending[id] = true
+	return execution, nil
+}
+
+// send simulates and sends a taken execution, removing it once sent and putting it back otherwise
+func (g *ExecutionGate) send(ctx context.Context, execution *PendingExecution) (common.Hash, error) {
+	var hash common.Hash
+	var err error
+	if execution.Quote == nil && (g.executor.simulator != nil || g.executor.revalidator != nil) {
+		err = errors.New("pending execution has no quote to check it against")
+	} else {
+		hash, err = g.executor.simulateAndSend(ctx, execution.Swap, execution.Quote, execution.Options)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.sending, execution.ID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	delete(g.pending, execution.ID)
+	if err := os.Remove(g.path(execution.ID)); err != nil && !os.IsNotExist(err) {
+		return hash, fmt.Errorf("sent as %v but removing the pending execution: %w", hash.Hex(), err)
+	}
+	return hash, nil
+}
+
+func (g *ExecutionGate) expireLocked() {
+	now := g.now()
+	for id, execution := range g.pending {
+		if now.After(execution.ExpiresAt) && !g.sending[id] {
+			delete(g.pending, id)
+			os.Remove(g.path(id))
+			g.executor.builder.Release(execution.Swap)
+		}
+	}
+}
+
+func (g *ExecutionGate) path(id string) string {
+	return filepath.Join(g.config.Dir, fmt.Sprintf(pendingExecutionPaths, id))
+}
+
+// recoverPersonalSigner returns the account that signed message as an EIP-191 personal message
+func recoverPersonalSigner(message string, signature []byte) (common.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, ErrInvalidApproval
+	}
+	sig := make([]byte, len(signature))
+	copy(sig, signature)
+	// wallets return v as 27 or 28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	publicKey, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil {
+		return common.Address{}, ErrInvalidApproval
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// Handler serves the pending executions for approvers:
+//
+//	GET  /pending                             executions waiting for approval with their messages
+//	POST /approve {"id": "", "signature": ""}  sends the execution, the signature may be omitted
+//	                                          only with AllowUnsignedConfirm
+func (g *ExecutionGate) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pending", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		type pendingResponse struct {
+			*PendingExecution
+			ApprovalMessage string `json:"approvalMessage"`
+		}
+		response := []pendingResponse{}
+		for _, execution := range g.Pending() {
+			response = append(response, pendingResponse{PendingExecution: execution, ApprovalMessage: execution.ApprovalMessage()})
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+	mux.HandleFunc("/approve", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		var request struct {
+			ID        string `json:"id"`
+			Signature string `json:"signature"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		var hash common.Hash
+		var err error
+		if strings.TrimSpace(request.Signature) == "" {
+			hash, err = g.Confirm(r.Context(), request.ID)
+		} else {
+			signature, decodeErr := hexutil.Decode(request.Signature)
+			if decodeErr != nil {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "signature: " + decodeErr.Error()})
+				return
+			}
+			hash, err = g.ApproveWithSignature(r.Context(), request.ID, signature)
+		}
+		switch {
+		case errors.Is(err, ErrExecutionNotFound):
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrInvalidApproval), errors.Is(err, ErrSignatureRequired):
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrExecutionSending):
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, map[string]common.Hash{"transactionHash": hash})
+		}
+	})
+	return mux
+}
@@ -0,0 +1,222 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestExecutionGate(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	router := newFakeRouter(graph)
+	small, _ := router.Route(context.Background(), big.NewInt(1000), weth, usdc, 1)
+	large, _ := router.Route(context.Background(), big.NewInt(100000), weth, usdc, 1)
+
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	approverKey, _ := crypto.GenerateKey()
+	backend := &transactionBackendFake{}
+	builder := NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), map[common.Address]ExposureLimit{weth: {PerDay: big.NewInt(1000000)}})
+	executor := NewExecutor(backend, signer, builder)
+	config := ExecutionGateConfig{
+		Thresholds: map[common.Address]*big.Int{weth: big.NewInt(50000)},
+		Approvers:  []common.Address{crypto.PubkeyToAddress(approverKey.PublicKey)},
+		// longer than the default swap deadline
+		TTL: time.Hour,
+		Dir: t.TempDir(),
+	}
+	gate, err := NewExecutionGate(executor, config)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	options := SwapOptions{Recipient: signer.Address()}
+
+	if _, err := gate.Execute(context.Background(), small, options); err != nil || len(backend.sent) != 1 {
+		t.Fatalf("got error %v and %v transactions want the small swap sent", err, len(backend.sent))
+	}
+	_, err = gate.Execute(context.Background(), large, options)
+	var required *ApprovalRequiredError
+	if !errors.As(err, &required) || len(backend.sent) != 1 {
+		t.Fatalf("got error %v and %v transactions want the large swap held", err, len(backend.sent))
+	}
+	pending := required.Execution
+	if _, err := gate.Execute(context.Background(), large, SwapOptions{Recipient: signer.Address(), Deadline: pending.ExpiresAt}); !errors.Is(err, ErrDeadlineTooEarly) {
+		t.Errorf("got error %v want ErrDeadlineTooEarly for a swap expiring with its approval", err)
+	}
+
+	// the pending execution survives a restart
+	gate, err = NewExecutionGate(executor, config)
+	if err != nil || len(gate.Pending()) != 1 || gate.Pending()[0].ID != pending.ID {
+		t.Fatalf("got pending %v error %v after reloading want %v", gate.Pending(), err, pending.ID)
+	}
+	if _, err := gate.Confirm(context.Background(), pending.ID); !errors.Is(err, ErrSignatureRequired) {
+		t.Errorf("got error %v want ErrSignatureRequired", err)
+	}
+	wrongKey, _ := crypto.GenerateKey()
+	wrong, _ := crypto.Sign(accounts.TextHash([]byte(pending.ApprovalMessage())), wrongKey)
+	if _, err := gate.ApproveWithSignature(context.Background(), pending.ID, wrong); !errors.Is(err, ErrInvalidApproval) {
+		t.Errorf("got error %v want ErrInvalidApproval", err)
+	}
+
+	signature, _ := crypto.Sign(accounts.TextHash([]byte(pending.ApprovalMessage())), approverKey)
+	signature[crypto.RecoveryIDOffset] += 27
+
+	// an execution being sent cannot be approved again or expire
+	if _, err := gate.take(pending.ID, func(*PendingExecution) error { return nil }); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if _, err := gate.ApproveWithSignature(context.Background(), pending.ID, signature); !errors.Is(err, ErrExecutionSending) {
+		t.Errorf("got error %v want ErrExecutionSending", err)
+	}
+	gate.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if len(gate.Pending()) != 1 {
+		t.Errorf("got %v pending executions want the one being sent kept", len(gate.Pending()))
+	}
+	gate.now = time.Now
+	delete(gate.sending, pending.ID)
+
+	// approval simulates the swap again, and it stays pending when the simulation fails
+	amounts := []*big.Int{large.AmountIn, large.AmountOut}
+	taxed := new(big.Int).Div(new(big.Int).Mul(large.AmountOut, big.NewInt(98)), big.NewInt(100))
+	trace := &traceServiceFake{frame: swapFrame(usdc, signer.Address(), amounts, taxed)}
+	executor.SetSimulator(newSimulatorFake(t, map[string]interface{}{"debug": trace}))
+	var simulationErr *SimulationError
+	if _, err := gate.ApproveWithSignature(context.Background(), pending.ID, signature); !errors.As(err, &simulationErr) || len(backend.sent) != 1 {
+		t.Fatalf("got error %v and %v transactions want a SimulationError and nothing sent", err, len(backend.sent))
+	}
+	if len(gate.Pending()) != 1 {
+		t.Fatalf("got %v pending executions want the refused one kept", len(gate.Pending()))
+	}
+	trace.frame = swapFrame(usdc, signer.Address(), amounts, large.AmountOut)
+
+	body := `{"id": "` + pending.ID + `", "signature": "` + hexutil.Encode(signature) + `"}`
+	recorder := httptest.NewRecorder()
+	gate.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/approve", strings.NewReader(body)))
+	if recorder.Code != http.StatusOK || len(backend.sent) != 2 {
+		t.Fatalf("got status %v body %v and %v transactions want the large swap sent", recorder.Code, recorder.Body, len(backend.sent))
+	}
+	swaps, err := DecodeSwapCalldata(backend.sent[1].Data(), backend.sent[1].Value())
+	if err != nil || swaps[0].AmountIn.Cmp(large.AmountIn) != 0 {
+		t.Fatalf("got swaps %+v error %v want the held swap", swaps, err)
+	}
+	// still valid when approved at the last moment
+	if deadline := swaps[0].Deadline.Int64(); deadline < pending.ExpiresAt.Add(executionSendMargin).Unix() {
+		t.Errorf("got deadline %v want at least %v after expiry", time.Unix(deadline, 0), executionSendMargin)
+	}
+	recorder = httptest.NewRecorder()
+	gate.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/approve", strings.NewReader(body)))
+	if recorder.Code != http.StatusNotFound || len(backend.sent) != 2 {
+		t.Errorf("got status %v approving twice want 404", recorder.Code)
+	}
+
+	// unapproved executions expire, on disk too, and stop counting towards the daily limits
+	sentExposure := builder.DailyExposure(weth)
+	gate.Execute(context.Background(), large, options)
+	gate.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if len(gate.Pending()) != 0 {
+		t.Errorf("got %v pending executions after their ttl want none", len(gate.Pending()))
+	}
+	if used := builder.DailyExposure(weth); used.Cmp(sentExposure) != 0 {
+		t.Errorf("got %v WETH used want %v of the sent swaps only", used, sentExposure)
+	}
+	if reloaded, _ := NewExecutionGate(executor, config); len(reloaded.Pending()) != 0 {
+		t.Errorf("got %v pending executions after reloading want the expired one removed", len(reloaded.Pending()))
+	}
+}
+
+func TestExecutionGateRestoresExposure(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	large, _ := newFakeRouter(graph).Route(context.Background(), big.NewInt(100000), weth, usdc, 1)
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	// room for one large swap a day
+	limits := map[common.Address]ExposureLimit{weth: {PerDay: big.NewInt(150000)}}
+	newExecutor := func() *Executor {
+		return NewExecutor(&transactionBackendFake{}, signer, NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), limits))
+	}
+	config := ExecutionGateConfig{Thresholds: map[common.Address]*big.Int{weth: big.NewInt(50000)}, AllowUnsignedConfirm: true, Dir: t.TempDir()}
+	gate, err := NewExecutionGate(newExecutor(), config)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	var required *ApprovalRequiredError
+	if _, err := gate.Execute(context.Background(), large, SwapOptions{Recipient: signer.Address()}); !errors.As(err, &required) {
+		t.Fatalf("got error %v want the large swap held", err)
+	}
+
+	// a restarted process counts the held swap towards the day again
+	executor := newExecutor()
+	gate, err = NewExecutionGate(executor, config)
+	if err != nil || len(gate.Pending()) != 1 {
+		t.Fatalf("got %v pending executions error %v after restarting want 1", len(gate.Pending()), err)
+	}
+	if used := executor.builder.DailyExposure(weth); used.Cmp(large.AmountInMax) != 0 {
+		t.Errorf("got %v WETH used after restarting want %v of the held swap", used, large.AmountInMax)
+	}
+	var limitErr *ExposureLimitError
+	if _, err := executor.builder.Build(large, SwapOptions{Recipient: signer.Address()}); !errors.As(err, &limitErr) {
+		t.Errorf("got error %v want the daily limit taken by the held swap", err)
+	}
+	// loading the gate again in the same process does not count it twice
+	if _, err := NewExecutionGate(executor, config); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if used := executor.builder.DailyExposure(weth); used.Cmp(large.AmountInMax) != 0 {
+		t.Errorf("got %v WETH used after reloading want %v", used, large.AmountInMax)
+	}
+
+	// and releases it when it expires
+	gate.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if len(gate.Pending()) != 0 {
+		t.Fatalf("got %v pending executions after their ttl want none", len(gate.Pending()))
+	}
+	if used := executor.builder.DailyExposure(weth); used.Sign() != 0 {
+		t.Errorf("got %v WETH used after the held swap expired want 0", used)
+	}
+}
+
+func TestExecutionGateUnsignedConfirm(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	large, _ := newFakeRouter(graph).Route(context.Background(), big.NewInt(100000), weth, usdc, 1)
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	backend := &transactionBackendFake{}
+	executor := NewExecutor(backend, signer, NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil))
+	config := ExecutionGateConfig{Thresholds: map[common.Address]*big.Int{weth: big.NewInt(50000)}, Dir: t.TempDir()}
+
+	// without approvers anyone reaching the gate could confirm, so that has to be asked for
+	if _, err := NewExecutionGate(executor, config); err == nil {
+		t.Fatalf("got no error for a gate without approvers")
+	}
+	config.AllowUnsignedConfirm = true
+	gate, err := NewExecutionGate(executor, config)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	_, err = gate.Execute(context.Background(), large, SwapOptions{Recipient: signer.Address()})
+	var required *ApprovalRequiredError
+	if !errors.As(err, &required) {
+		t.Fatalf("got error %v want the large swap held", err)
+	}
+	recorder := httptest.NewRecorder()
+	gate.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/approve", strings.NewReader(`{"id": "`+required.Execution.ID+`"}`)))
+	if recorder.Code != http.StatusOK || len(backend.sent) != 1 {
+		t.Errorf("got status %v body %v and %v transactions want the swap confirmed", recorder.Code, recorder.Body, len(backend.sent))
+	}
+}
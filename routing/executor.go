@@ -0,0 +1,293 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var ErrTransactionReverted = errors.New("transaction reverted")
+
+// TransactionBackend is the part of ethclient.Client needed to send transactions and follow them
+type TransactionBackend interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Executor builds, signs and broadcasts the swaps of quotes. It is optional, quoting never needs a key.
+type Executor struct {
+	backend TransactionBackend
+	signer  *Signer
+	builder *TxBuilder
+	// optional, when set swaps are simulated before they are sent
+	simulator *Simulator
+	// optional, when set routes are priced again at the latest block before they are sent and
+	// stale ones rerouted when reroute is set
+	revalidator RouteRevalidator
+	reroute     bool
+	// optional, told about the pools of every trade WaitForTrade sees land
+	invalidator PoolInvalidator
+}
+
+func NewExecutor(backend TransactionBackend, signer *Signer, builder *TxBuilder) *Executor {
+	return &Executor{backend: backend, signer: signer, builder: builder}
+}
+
+// Execute sends the swap of quote as an EIP-1559 transaction from the signer's account and returns
+// its hash without waiting for it to be mined, see WaitForReceipt. The gas limit is the estimate
+// plus 20% and the fee cap twice the current base fee plus the suggested tip.
+func (e *Executor) Execute(ctx context.Context, quote *Quote, options SwapOptions) (common.Hash, error) {
+	swap, err := e.builder.Build(quote, options)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	hash, err := e.sendBuilt(ctx, swap, quote, options)
+	var stale *StaleRouteError
+	if !e.reroute || !errors.As(err, &stale) {
+		return hash, err
+	}
+	rerouted, err := e.requote(ctx, stale)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	swap, err = e.builder.Build(rerouted, options)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return e.sendBuilt(ctx, swap, rerouted, options)
+}
+
+// requote routes the trade of a stale quote again and keeps the new route when it still meets the
+// slippage limits of the stale one. The swap is then bounded by the tighter of both limits.
+func (e *Executor) requote(ctx context.Context, stale *StaleRouteError) (*Quote, error) {
+	quote := stale.Quote
+	rerouted, err := e.revalidator.Requote(ctx, quote)
+	if err != nil {
+		return nil, fmt.Errorf("%v, rerouting: %w", stale, err)
+	}
+	if !withinLimits(quote, rerouted) {
+		return nil, &StaleRouteError{Quote: quote, Revalidated: rerouted}
+	}
+	if rerouted.AmountOutMin.Cmp(quote.AmountOutMin) < 0 {
+		rerouted.AmountOutMin = quote.AmountOutMin
+	}
+	if rerouted.AmountInMax.Cmp(quote.AmountInMax) > 0 {
+		rerouted.AmountInMax = quote.AmountInMax
+	}
+	return rerouted, nil
+}
+
+// sendBuilt simulates and sends a swap built by the executor's builder, releasing its exposure
+// when it is not sent
+func (e *Executor) sendBuilt(ctx context.Context, swap *SwapTx, quote *Quote, options SwapOptions) (common.Hash, error) {
+	hash, err := e.simulateAndSend(ctx, swap, quote, options)
+	if err != nil {
+		e.builder.Release(swap)
+	}
+	return hash, err
+}
+
+// simulateAndSend sends swap once its route, when a revalidator is set, still meets the limits of
+// quote and the simulator, when set, finds nothing wrong with it
+func (e *Executor) simulateAndSend(ctx context.Context, swap *SwapTx, quote *Quote, options SwapOptions) (common.Hash, error) {
+	if err := e.revalidate(ctx, quote); err != nil {
+		return common.Hash{}, err
+	}
+	if err := e.simulate(ctx, swap, quote, options); err != nil {
+		return common.Hash{}, err
+	}
+	return e.send(ctx, swap)
+}
+
+// SetSimulator makes the executor simulate every swap before sending it and refuse, with a
+// SimulationError, those the simulation flags
+func (e *Executor) SetSimulator(simulator *Simulator) {
+	e.simulator = simulator
+}
+
+// SetRevalidator makes the executor price the route of every quote again at the latest block before
+// sending its swap and refuse, with a StaleRouteError, routes that no longer meet the quote's
+// slippage limits. With reroute, Execute routes such trades again instead and sends the new route
+// when it still meets them; swaps held by an ExecutionGate are never rerouted, their approval
+// covers the exact call.
+func (e *Executor) SetRevalidator(revalidator RouteRevalidator, reroute bool) {
+	e.revalidator, e.reroute = revalidator, reroute
+}
+
+func (e *Executor) revalidate(ctx context.Context, quote *Quote) error {
+	if e.revalidator == nil {
+		return nil
+	}
+	revalidated, err := e.revalidator.RevalidateQuote(ctx, quote)
+	if err != nil {
+		return fmt.Errorf("revalidating the route: %w", err)
+	}
+	if !withinLimits(quote, revalidated) {
+		return &StaleRouteError{Quote: quote, Revalidated: revalidated}
+	}
+	return nil
+}
+
+func (e *Executor) simulate(ctx context.Context, swap *SwapTx, quote *Quote, options SwapOptions) error {
+	if e.simulator == nil {
+		return nil
+	}
+	result, err := e.simulator.Simulate(ctx, e.signer.Address(), swap, quote, options)
+	if err != nil {
+		return fmt.Errorf("simulating %v: %w", swap.Method, err)
+	}
+	if !result.OK() {
+		return &SimulationError{Result: result}
+	}
+	return nil
+}
+
+// ExecuteWithPermit2 sends the swap of quote through the Universal Router, approving it with a
+// Permit2 signature instead of an approve transaction. Permit2 itself needs a one-time allowance
+// of the token, ErrPermit2NotApproved is returned without it.
+func (e *Executor) ExecuteWithPermit2(ctx context.Context, caller bind.ContractCaller, quote *Quote, options SwapOptions) (common.Hash, error) {
+	if options.NativeIn {
+		return e.Execute(ctx, quote, options)
+	}
+	if quote.AmountInMax == nil {
+		return common.Hash{}, errNoSlippageLimits
+	}
+	erc20, err := NewMainCaller(quote.TokenIn, caller)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	allowance, err := erc20.Allowance(newCallOpts(ctx), e.signer.Address(), common.HexToAddress(PERMIT2_ADDRESS))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if allowance.Cmp(quote.AmountInMax) < 0 {
+		return common.Hash{}, ErrPermit2NotApproved
+	}
+	chainID, err := e.backend.ChainID(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// the permit expires with the swap
+	if options.Deadline.IsZero() {
+		options.Deadline = e.builder.now().Add(DefaultSwapDeadline)
+	}
+	permit, err := SignPermit2(ctx, caller, e.signer, chainID, quote.TokenIn, e.builder.universalRouter, quote.AmountInMax, options.Deadline)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	swap, err := e.builder.BuildUniversalRouter(quote, options, permit)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return e.sendBuilt(ctx, swap, quote, options)
+}
+
+// Address is the account transactions are sent from
+func (e *Executor) Address() common.Address {
+	return e.signer.Address()
+}
+
+// send signs and broadcasts call from the signer's account
+func (e *Executor) send(ctx context.Context, call *SwapTx) (common.Hash, error) {
+	chainID, err := e.backend.ChainID(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	from := e.signer.Address()
+	nonce, err := e.backend.PendingNonceAt(ctx, from)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	head, err := e.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if head.BaseFee == nil {
+		return common.Hash{}, errors.New("chain has no base fee, EIP-1559 transactions are not supported")
+	}
+	tip, err := e.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	gas, err := e.backend.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &call.To, Value: call.Value, Data: call.Data})
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("estimating gas of %v: %w", call.Method, err)
+	}
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	tx, err := e.signer.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas * 6 / 5,
+		To:        &call.To,
+		Value:     call.Value,
+		Data:      call.Data,
+	}), chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := e.backend.SendTransaction(ctx, tx); err != nil {
+		return common.Hash{}, fmt.Errorf("sending %v: %w", call.Method, err)
+	}
+	return tx.Hash(), nil
+}
+
+// SetPoolInvalidator makes WaitForTrade invalidate the pools of every trade that lands, e.g. in the
+// router quoting them or, through a QuoteServerInvalidator, a QuoteServer other clients quote from
+func (e *Executor) SetPoolInvalidator(invalidator PoolInvalidator) {
+	e.invalidator = invalidator
+}
+
+// WaitForTrade waits for the receipt of the swap of quote sent as hash like WaitForReceipt, and
+// once it succeeded invalidates the pools of quote's route with the invalidator, when set
+func (e *Executor) WaitForTrade(ctx context.Context, hash common.Hash, quote *Quote, pollInterval time.Duration) (*types.Receipt, error) {
+	receipt, err := WaitForReceipt(ctx, e.backend, hash, pollInterval)
+	if err != nil || e.invalidator == nil {
+		return receipt, err
+	}
+	pairs := make([]common.Address, len(quote.Hops))
+	for i, hop := range quote.Hops {
+		pairs[i] = hop.Pair
+	}
+	if err := e.invalidator.InvalidatePools(ctx, pairs); err != nil {
+		return receipt, fmt.Errorf("trade landed, invalidating its pools: %w", err)
+	}
+	return receipt, nil
+}
+
+// WaitForReceipt polls for the receipt of hash every pollInterval until it is mined or ctx is
+// done, mined transactions that reverted return their receipt with ErrTransactionReverted
+func WaitForReceipt(ctx context.Context, backend TransactionBackend, hash common.Hash, pollInterval time.Duration) (*types.Receipt, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := backend.TransactionReceipt(ctx, hash)
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return receipt, ErrTransactionReverted
+			}
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
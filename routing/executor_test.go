@@ -0,0 +1,108 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// transactionBackendFake mines sent transactions after receiptPolls receipt lookups
+type transactionBackendFake struct {
+	sent         []*types.Transaction
+	estimated    []ethereum.CallMsg
+	receiptPolls int
+	status       uint64
+}
+
+func (b *transactionBackendFake) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (b *transactionBackendFake) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return 7, nil
+}
+
+func (b *transactionBackendFake) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: big.NewInt(30e9)}, nil
+}
+
+func (b *transactionBackendFake) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1e9), nil
+}
+
+func (b *transactionBackendFake) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	b.estimated = append(b.estimated, call)
+	return 150000, nil
+}
+
+func (b *transactionBackendFake) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+func (b *transactionBackendFake) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if b.receiptPolls > 0 {
+		b.receiptPolls--
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{TxHash: txHash, Status: b.status}, nil
+}
+
+func TestExecutor(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	quote, err := newFakeRouter(graph).Route(context.Background(), big.NewInt(1000), weth, usdc, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	backend := &transactionBackendFake{receiptPolls: 2, status: types.ReceiptStatusSuccessful}
+	executor := NewExecutor(backend, signer, NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil))
+
+	hash, err := executor.Execute(context.Background(), quote, SwapOptions{Recipient: signer.Address(), NativeIn: true})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(backend.sent) != 1 || backend.sent[0].Hash() != hash {
+		t.Fatalf("got %v transactions sent want one with hash %v", len(backend.sent), hash)
+	}
+	tx := backend.sent[0]
+	from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), tx)
+	if err != nil || from != signer.Address() {
+		t.Errorf("got sender %v error %v want %v", from, err, signer.Address())
+	}
+	if tx.Nonce() != 7 || tx.Gas() != 180000 || tx.GasFeeCap().Int64() != 61e9 || tx.GasTipCap().Int64() != 1e9 {
+		t.Errorf("got nonce %v gas %v fee cap %v tip %v want 7, 180000, 61 gwei, 1 gwei", tx.Nonce(), tx.Gas(), tx.GasFeeCap(), tx.GasTipCap())
+	}
+	if *tx.To() != common.HexToAddress(ROUTER02_ADDRESS) || tx.Value().Cmp(quote.AmountIn) != 0 || backend.estimated[0].From != signer.Address() {
+		t.Errorf("got tx to %v value %v want the router paid the quote's input", tx.To(), tx.Value())
+	}
+	swaps, err := DecodeSwapCalldata(tx.Data(), tx.Value())
+	if err != nil || swaps[0].Method != "swapExactETHForTokens" {
+		t.Errorf("got swaps %+v error %v want swapExactETHForTokens", swaps, err)
+	}
+
+	receipt, err := WaitForReceipt(context.Background(), backend, hash, time.Millisecond)
+	if err != nil || receipt.TxHash != hash {
+		t.Errorf("got receipt %+v error %v want the receipt of %v", receipt, err, hash)
+	}
+	backend.status = types.ReceiptStatusFailed
+	if _, err := WaitForReceipt(context.Background(), backend, hash, time.Millisecond); !errors.Is(err, ErrTransactionReverted) {
+		t.Errorf("got error %v want ErrTransactionReverted", err)
+	}
+	backend.receiptPolls = 1000
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := WaitForReceipt(ctx, backend, hash, time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v want the context deadline", err)
+	}
+}
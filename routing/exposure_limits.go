@@ -0,0 +1,163 @@
+package routing
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ExposureLimit caps how much of a token TxBuilder builds swaps for, counting what is sold and
+// what is bought, in the token's smallest unit. A nil field is not limited.
+type ExposureLimit struct {
+	PerSwap *big.Int
+	// reset at midnight UTC
+	PerDay *big.Int
+}
+
+// ExposureLimitError is returned by TxBuilder.Build for swaps that would exceed an ExposureLimit
+type ExposureLimitError struct {
+	Token common.Address
+	// "swap" or "day"
+	Period string
+	Limit  *big.Int
+	// amount of the swap and, for daily limits, the amount of swaps built earlier that day
+	Amount *big.Int
+	Used   *big.Int
+}
+
+func (e *ExposureLimitError) Error() string {
+	if e.Period == "day" {
+		return fmt.Sprintf("swap of %v %v exceeds the daily limit of %v, %v already built today", e.Amount, e.Token.String(), e.Limit, e.Used)
+	}
+	return fmt.Sprintf("swap of %v %v exceeds the per-swap limit of %v", e.Amount, e.Token.String(), e.Limit)
+}
+
+// exposureTracker keeps the volume of every limited token built on the current day
+type exposureTracker struct {
+	limits map[common.Address]ExposureLimit
+
+	mu   sync.Mutex
+	day  time.Time
+	used map[common.Address]*big.Int
+	// ids of the reservations of the day counted in used
+	reserved map[string]bool
+}
+
+func newExposureTracker(limits map[common.Address]ExposureLimit) *exposureTracker {
+	return &exposureTracker{limits: limits, used: make(map[common.Address]*big.Int), reserved: make(map[string]bool)}
+}
+
+// ReservedAmount is an amount of a token a swap sells or buys
+type ReservedAmount struct {
+	Token  common.Address `json:"token"`
+	Amount *big.Int       `json:"amount"`
+}
+
+// ExposureReservation is what one built swap counted towards the daily limits of its day. It is
+// saved with pending executions so a restarted ExecutionGate counts them again.
+type ExposureReservation struct {
+	ID      string           `json:"id"`
+	Day     time.Time        `json:"day"`
+	Amounts []ReservedAmount `json:"amounts"`
+}
+
+// startDayLocked resets the volumes when now is on a later day than the last swap
+func (t *exposureTracker) startDayLocked(now time.Time) {
+	if day := now.UTC().Truncate(24 * time.Hour); !day.Equal(t.day) {
+		t.day, t.used, t.reserved = day, make(map[common.Address]*big.Int), make(map[string]bool)
+	}
+}
+
+// reserve checks the worst case amounts of a swap against the limits in order and counts them
+// towards the day, either all amounts are counted or none
+func (t *exposureTracker) reserve(now time.Time, amounts []ReservedAmount) (*ExposureReservation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.startDayLocked(now)
+	for _, swapped := range amounts {
+		token, amount := swapped.Token, swapped.Amount
+		limit, ok := t.limits[token]
+		if !ok {
+			continue
+		}
+		if limit.PerSwap != nil && amount.Cmp(limit.PerSwap) > 0 {
+			return nil, &ExposureLimitError{Token: token, Period: "swap", Limit: limit.PerSwap, Amount: amount}
+		}
+		used := t.usedLocked(token)
+		if limit.PerDay != nil && new(big.Int).Add(used, amount).Cmp(limit.PerDay) > 0 {
+			return nil, &ExposureLimitError{Token: token, Period: "day", Limit: limit.PerDay, Amount: amount, Used: used}
+		}
+	}
+	reservation := &ExposureReservation{ID: NewRequestID(), Day: t.day, Amounts: amounts}
+	t.countLocked(reservation)
+	return reservation, nil
+}
+
+// restore counts a reservation made before a restart towards its day again. It was within the
+// limits when it was made, so it is not checked against them again. Reservations of an earlier day
+// and reservations counted already are ignored.
+func (t *exposureTracker) restore(now time.Time, reservation *ExposureReservation) {
+	if reservation == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.startDayLocked(now)
+	if !reservation.Day.Equal(t.day) || t.reserved[reservation.ID] {
+		return
+	}
+	t.countLocked(reservation)
+}
+
+func (t *exposureTracker) countLocked(reservation *ExposureReservation) {
+	for _, swapped := range reservation.Amounts {
+		if _, ok := t.limits[swapped.Token]; ok {
+			t.used[swapped.Token] = new(big.Int).Add(t.usedLocked(swapped.Token), swapped.Amount)
+		}
+	}
+	t.reserved[reservation.ID] = true
+}
+
+// release stops counting a reservation towards its day, reservations of an earlier day are gone
+// already
+func (t *exposureTracker) release(reservation *ExposureReservation) {
+	if reservation == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !reservation.Day.Equal(t.day) || !t.reserved[reservation.ID] {
+		return
+	}
+	delete(t.reserved, reservation.ID)
+	for _, swapped := range reservation.Amounts {
+		if _, ok := t.limits[swapped.Token]; !ok {
+			continue
+		}
+		used := t.usedLocked(swapped.Token).Sub(t.usedLocked(swapped.Token), swapped.Amount)
+		if used.Sign() < 0 {
+			used.SetInt64(0)
+		}
+		t.used[swapped.Token] = used
+	}
+}
+
+func (t *exposureTracker) usedLocked(token common.Address) *big.Int {
+	if used, ok := t.used[token]; ok {
+		return new(big.Int).Set(used)
+	}
+	return new(big.Int)
+}
+
+// usedOn returns the volume of token built on the day of now
+func (t *exposureTracker) usedOn(now time.Time, token common.Address) *big.Int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !now.UTC().Truncate(24 * time.Hour).Equal(t.day) {
+		return new(big.Int)
+	}
+	return t.usedLocked(token)
+}
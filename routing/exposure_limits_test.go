@@ -0,0 +1,75 @@
+package routing
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestExposureLimits(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	quote := func(amountIn int64) *Quote {
+		return &Quote{
+			TokenIn:      weth,
+			TokenOut:     usdc,
+			AmountIn:     big.NewInt(amountIn),
+			AmountOut:    big.NewInt(amountIn * 2),
+			AmountOutMin: big.NewInt(amountIn * 2),
+			AmountInMax:  big.NewInt(amountIn),
+			Hops:         []RouteHop{{TokenIn: weth, TokenOut: usdc, DEX: "uniswap-v2"}},
+		}
+	}
+	builder := NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), map[common.Address]ExposureLimit{
+		weth: {PerSwap: big.NewInt(600), PerDay: big.NewInt(1000)},
+		// USDC bought is only limited per day
+		usdc: {PerDay: big.NewInt(1800)},
+	})
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	builder.now = func() time.Time { return now }
+	options := SwapOptions{Recipient: common.HexToAddress("0x1234")}
+
+	if _, err := builder.Build(quote(500), options); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	var limitErr *ExposureLimitError
+	if _, err := builder.Build(quote(700), options); !errors.As(err, &limitErr) || limitErr.Period != "swap" || limitErr.Token != weth {
+		t.Errorf("got error %v want the per-swap WETH limit", err)
+	}
+	// 500 + 450 WETH fits the day but 1000 + 900 USDC does not
+	if _, err := builder.Build(quote(450), options); !errors.As(err, &limitErr) || limitErr.Period != "day" || limitErr.Token != usdc || limitErr.Used.Int64() != 1000 {
+		t.Errorf("got error %v want the daily USDC limit with 1000 used", err)
+	}
+	// rejected swaps are not counted
+	if used := builder.DailyExposure(weth); used.Int64() != 500 {
+		t.Errorf("got %v WETH used want 500", used)
+	}
+	swap, err := builder.Build(quote(400), options)
+	if err != nil {
+		t.Fatalf("got error %v for a swap within the limits", err)
+	}
+	// a swap that is not sent gives its volume back, once
+	builder.Release(swap)
+	builder.Release(swap)
+	if used := builder.DailyExposure(weth); used.Int64() != 500 {
+		t.Errorf("got %v WETH used after releasing want 500", used)
+	}
+	if swap, err = builder.Build(quote(400), options); err != nil {
+		t.Errorf("got error %v building the released swap again", err)
+	}
+
+	now = now.Add(12 * time.Hour)
+	if used := builder.DailyExposure(weth); used.Sign() != 0 {
+		t.Errorf("got %v WETH used on a new day want 0", used)
+	}
+	if _, err := builder.Build(quote(600), options); err != nil {
+		t.Errorf("got error %v on a new day", err)
+	}
+	// yesterday's swap does not free today's volume
+	builder.Release(swap)
+	if used := builder.DailyExposure(weth); used.Int64() != 600 {
+		t.Errorf("got %v WETH used after releasing an earlier day's swap want 600", used)
+	}
+}
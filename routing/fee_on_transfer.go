@@ -0,0 +1,151 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TransferFee is the share of every transfer a fee-on-transfer token keeps, in basis points
+type TransferFee struct {
+	Token common.Address `json:"token"`
+	Bips  int64          `json:"bips"`
+}
+
+// TransferFeeProvider measures the transfer fee of a token by moving amount of it out of pair,
+// which holds a balance of it. Tokens without a fee return 0.
+type TransferFeeProvider interface {
+	GetTransferFee(ctx context.Context, token, pair common.Address, amount *big.Int) (int64, error)
+}
+
+// the account simulated transfers are sent to, an address no token exempts from its fee
+var transferFeeProbe = common.HexToAddress("0x00000000000000000000000000000000000fee01")
+
+// SimulatedTransferFeeProvider traces a transfer from the pair with debug_traceCall and compares
+// the Transfer events reaching the recipient with the amount sent. Fees rarely change, so the
+// fee of every token is measured once per process.
+type SimulatedTransferFeeProvider struct {
+	client *rpc.Client
+
+	mu       sync.Mutex
+	fees     map[common.Address]int64
+	counters cacheCounters
+}
+
+func NewSimulatedTransferFeeProvider(client *rpc.Client) *SimulatedTransferFeeProvider {
+	return &SimulatedTransferFeeProvider{client: client, fees: make(map[common.Address]int64)}
+}
+
+func (p *SimulatedTransferFeeProvider) GetTransferFee(ctx context.Context, token, pair common.Address, amount *big.Int) (int64, error) {
+	p.mu.Lock()
+	fee, ok := p.fees[token]
+	p.mu.Unlock()
+	p.counters.count(ok)
+	if ok {
+		return fee, nil
+	}
+	if amount.Sign() <= 0 {
+		return 0, fmt.Errorf("pair %v holds no %v to transfer", pair.String(), token.String())
+	}
+	data, err := erc20Parsed.Pack("transfer", transferFeeProbe, amount)
+	if err != nil {
+		return 0, err
+	}
+	var frame callFrame
+	call := ethereum.CallMsg{From: pair, To: &token, Data: data}
+	config := map[string]interface{}{"tracer": "callTracer", "tracerConfig": map[string]interface{}{"withLog": true}}
+	if err := p.client.CallContext(ctx, &frame, "debug_traceCall", toCallArg(call), toBlockNumberArg(BlockNumberFromContext(ctx)), config); err != nil {
+		return 0, err
+	}
+	if frame.Error != "" {
+		return 0, fmt.Errorf("transfer of %v from pair %v reverted: %v %v", token.String(), pair.String(), frame.Error, frame.RevertReason)
+	}
+	arrived := received(&frame, token, SwapOptions{Recipient: transferFeeProbe})
+	fee = transferFeeBips(amount, arrived)
+	p.mu.Lock()
+	p.fees[token] = fee
+	p.mu.Unlock()
+	return fee, nil
+}
+
+func (p *SimulatedTransferFeeProvider) CacheSummary() CacheSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	taxed := 0
+	for _, fee := range p.fees {
+		if fee > 0 {
+			taxed++
+		}
+	}
+	return p.counters.summary(len(p.fees), fmt.Sprintf("%v fee-on-transfer tokens", taxed))
+}
+
+// transferFeeBips is the share of sent that did not arrive, rounded up so quotes never overstate
+// what arrives
+func transferFeeBips(sent, arrived *big.Int) int64 {
+	if arrived.Cmp(sent) >= 0 {
+		return 0
+	}
+	lost := new(big.Int).Sub(sent, arrived)
+	lost.Mul(lost, big.NewInt(10000))
+	lost.Add(lost, new(big.Int).Sub(sent, big.NewInt(1)))
+	return lost.Div(lost, sent).Int64()
+}
+
+// transferFees measures the fee of every token of path, tokenIn on the first pair and the others
+// on the pair they are bought from. A token whose fee cannot be measured is logged and treated as
+// untaxed, the quote is still usable for the common tokens without a fee.
+func (r *OnChainV2Router) transferFees(ctx context.Context, path []common.Address, hops []hopReserves) []TransferFee {
+	fees := []TransferFee{}
+	for i, token := range path {
+		hop, balance := hops[0], hops[0].reserveIn
+		if i > 0 {
+			hop, balance = hops[i-1], hops[i-1].reserveOut
+		}
+		// a small share of the pair's balance, so limits on transfer sizes are not hit
+		amount := new(big.Int).Div(balance, big.NewInt(1000))
+		if amount.Sign() == 0 {
+			amount = new(big.Int).Set(balance)
+		}
+		bips, err := r.transferFeeProvider.GetTransferFee(ctx, token, hop.pair, amount)
+		if err != nil {
+			logWarn(ctx, r.logger, "transfer fee unknown", "token", token, "error", err)
+			continue
+		}
+		if bips > 0 {
+			fees = append(fees, TransferFee{Token: token, Bips: bips})
+		}
+	}
+	return fees
+}
+
+// amountOutAfterTransferFees prices an exact-in route as Router02's SupportingFeeOnTransferTokens
+// swaps execute it: every transfer into a pair and to the recipient loses the token's fee
+func amountOutAfterTransferFees(amountIn *big.Int, path []common.Address, hops []hopReserves, fees []TransferFee) (*big.Int, error) {
+	bips := make(map[common.Address]int64)
+	for _, fee := range fees {
+		bips[fee.Token] = fee.Bips
+	}
+	amount := afterTransferFee(amountIn, bips[path[0]])
+	for i, hop := range hops {
+		out, err := hop.GetAmountOut(amount)
+		if err != nil {
+			return nil, err
+		}
+		amount = afterTransferFee(out, bips[path[i+1]])
+	}
+	return amount, nil
+}
+
+func afterTransferFee(amount *big.Int, bips int64) *big.Int {
+	if bips == 0 {
+		return amount
+	}
+	kept := new(big.Int).Mul(amount, big.NewInt(10000-bips))
+	return kept.Div(kept, big.NewInt(10000))
+}
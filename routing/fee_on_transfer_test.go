@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type transferFeeFake map[common.Address]int64
+
+func (f transferFeeFake) GetTransferFee(ctx context.Context, token, pair common.Address, amount *big.Int) (int64, error) {
+	return f[token], nil
+}
+
+func TestSimulatedTransferFeeProvider(t *testing.T) {
+	token, pair := common.HexToAddress("0x7a7"), common.HexToAddress(WETH_USDC)
+	transfer := func(to common.Address, amount int64) callLog {
+		return callLog{Address: token, Topics: []common.Hash{transferTopic, common.BytesToHash(pair.Bytes()), common.BytesToHash(to.Bytes())}, Data: common.LeftPadBytes(big.NewInt(amount).Bytes(), 32)}
+	}
+	// 4.999% goes to the token's treasury, rounded up to 500 bips
+	frame := callFrame{Type: "CALL", To: &token, Logs: []callLog{transfer(common.HexToAddress("0x7ea5"), 4999), transfer(transferFeeProbe, 95001)}}
+	server := rpc.NewServer()
+	if err := server.RegisterName("debug", &traceServiceFake{frame: frame}); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	provider := NewSimulatedTransferFeeProvider(rpc.DialInProc(server))
+	fee, err := provider.GetTransferFee(context.Background(), token, pair, big.NewInt(100000))
+	if err != nil || fee != 500 {
+		t.Errorf("got fee %v error %v want 500 bips", fee, err)
+	}
+	// fees are measured once
+	server.Stop()
+	if fee, err := provider.GetTransferFee(context.Background(), token, pair, big.NewInt(100000)); err != nil || fee != 500 {
+		t.Errorf("got fee %v error %v want the cached 500 bips", fee, err)
+	}
+}
+
+func TestRouteWithTransferFees(t *testing.T) {
+	weth, taxed := common.HexToAddress(WETH), common.HexToAddress("0x7a7")
+	graph := &v2GraphFake{}
+	graph.addPool(weth, taxed, 1000000, 2000000000)
+	router := newFakeRouter(graph)
+	untaxed, err := router.Route(context.Background(), big.NewInt(1000), weth, taxed, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	router.transferFeeProvider = transferFeeFake{taxed: 500}
+	quote, err := router.Route(context.Background(), big.NewInt(1000), weth, taxed, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	want := new(big.Int).Div(new(big.Int).Mul(untaxed.AmountOut, big.NewInt(9500)), big.NewInt(10000))
+	if !quote.HasTransferFees() || quote.TransferFees[0] != (TransferFee{Token: taxed, Bips: 500}) || quote.AmountOut.Cmp(want) != 0 {
+		t.Errorf("got fees %v amount out %v want 500 bips of %v and %v", quote.TransferFees, quote.AmountOut, taxed.String(), want)
+	}
+
+	builder := NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil)
+	swap, err := builder.Build(quote, SwapOptions{Recipient: common.HexToAddress("0x1234")})
+	if err != nil || swap.Method != "swapExactTokensForTokensSupportingFeeOnTransferTokens" {
+		t.Errorf("got %v error %v want the supporting method", swap, err)
+	}
+	exactOut, err := router.RouteExactOut(context.Background(), weth, taxed, big.NewInt(1000), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if _, err := builder.Build(exactOut, SwapOptions{Recipient: common.HexToAddress("0x1234")}); err == nil || !strings.Contains(err.Error(), "exact-in") {
+		t.Errorf("got error %v building an exact-out swap of a taxed token", err)
+	}
+}
@@ -0,0 +1,56 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultGasPerSwap is roughly the gas a single V2 hop adds to a router swap
+const DefaultGasPerSwap = 100000
+
+// GasPricing charges every swap of a route its gas cost, so longer routes only win
+// when their extra output pays for the extra hops
+type GasPricing struct {
+	// DefaultGasPerSwap when zero
+	GasPerSwap uint64
+	// price per gas in wei
+	BaseFee *big.Int
+}
+
+// hopCost converts the gas of one swap into quoteToken using the best route in graph from weth,
+// the chain's wrapped native token
+func (p *GasPricing) hopCost(ctx context.Context, graph *routeGraph, weth, quoteToken common.Address, maxHops int) (*big.Int, error) {
+	if p.BaseFee == nil {
+		return nil, errors.New("gas pricing requires a base fee")
+	}
+	gasPerSwap := p.GasPerSwap
+	if gasPerSwap == 0 {
+		gasPerSwap = DefaultGasPerSwap
+	}
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gasPerSwap), p.BaseFee)
+	if quoteToken == weth || cost.Sign() == 0 {
+		return cost, nil
+	}
+	wethGraph := *graph
+	wethGraph.tokenInIndex, wethGraph.tokenOutIndex = -1, -1
+	for i, token := range graph.tokens {
+		if token == weth {
+			wethGraph.tokenInIndex = i
+		}
+		if token == quoteToken {
+			wethGraph.tokenOutIndex = i
+		}
+	}
+	if wethGraph.tokenInIndex < 0 || wethGraph.tokenOutIndex < 0 {
+		return nil, fmt.Errorf("cannot price gas in %v: the wrapped native token is not in the graph", quoteToken.String())
+	}
+	converted, _, _, err := searchRoute(ctx, &wethGraph, exactIn, cost, maxHops, nil, &RouteMetadata{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot price gas in %v: %w", quoteToken.String(), err)
+	}
+	return converted, nil
+}
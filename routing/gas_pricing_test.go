@@ -0,0 +1,55 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRouteGasAdjustedSelection(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 1000000000, 1000000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000000, 1000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1010000000)
+	router := newFakeRouter(graph)
+	amountIn := big.NewInt(1000000)
+
+	// the 2 hop route pays about 0.3% more before gas
+	_, path, metadata, err := router.RouteWithMetadata(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(DAI), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(path) != 3 {
+		t.Fatalf("got path %v want the 2 hop route", path)
+	}
+	if metadata.GasCost != nil {
+		t.Errorf("got gas cost %v without gas pricing", metadata.GasCost)
+	}
+
+	// at 100k WETH wei per swap the extra hop costs more than it gains
+	router.gasPricing = &GasPricing{GasPerSwap: DefaultGasPerSwap, BaseFee: big.NewInt(1)}
+	amountOut, path, metadata, err := router.RouteWithMetadata(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(DAI), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(path) != 2 {
+		t.Fatalf("got path %v want the direct pair", path)
+	}
+	wantOut, _ := getAmountOut(amountIn, big.NewInt(1000000000), big.NewInt(1000000000))
+	if amountOut.Cmp(wantOut) != 0 {
+		t.Errorf("got %v want the gross output %v", amountOut, wantOut)
+	}
+	// one swap of 100k WETH wei converted to DAI through the deeper route
+	if metadata.GasCost == nil || metadata.GasCost.Cmp(big.NewInt(90000)) < 0 || metadata.GasCost.Cmp(big.NewInt(110000)) > 0 {
+		t.Errorf("got gas cost %v want about 100000", metadata.GasCost)
+	}
+
+	// a zero GasPerSwap is DefaultGasPerSwap, not free swaps
+	router.gasPricing = &GasPricing{BaseFee: big.NewInt(1)}
+	_, defaultPath, defaultMetadata, err := router.RouteWithMetadata(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(DAI), 2)
+	if err != nil || len(defaultPath) != 2 || defaultMetadata.GasCost.Cmp(metadata.GasCost) != 0 {
+		t.Errorf("got path %v gas cost %v error %v want the direct pair at %v", defaultPath, defaultMetadata.GasCost, err, metadata.GasCost)
+	}
+}
@@ -0,0 +1,139 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// QuoteResponse is the JSON form of a route, the body of GET /quote and the output of router quote
+// --json. Amounts are decimal strings in the tokens' smallest unit so they survive JSON number
+// precision.
+type QuoteResponse struct {
+	TokenIn   common.Address `json:"tokenIn"`
+	TokenOut  common.Address `json:"tokenOut"`
+	AmountIn  string         `json:"amountIn"`
+	AmountOut string         `json:"amountOut"`
+	// least output to accept when submitting the swap, AmountOut less SlippageBips basis points
+	AmountOutMin string           `json:"amountOutMin"`
+	SlippageBips int64            `json:"slippageBips"`
+	Path         []common.Address `json:"path"`
+	Hops         []RouteHop       `json:"hops"`
+	PriceImpact  float64          `json:"priceImpact"`
+	// PriceImpact in percent, with a warning when it exceeds HighPriceImpact
+	PriceImpactPercent float64 `json:"priceImpactPercent"`
+	PriceImpactWarning string  `json:"priceImpactWarning,omitempty"`
+	// gas cost of the route in tokenOut, omitted without gas pricing
+	GasCost         string           `json:"gasCost,omitempty"`
+	BlockNumber     *big.Int         `json:"blockNumber,omitempty"`
+	RequestID       string           `json:"requestId"`
+	ReserveWarnings []ReserveWarning `json:"reserveWarnings,omitempty"`
+	// tokens of the route taking a fee on transfer, AmountOut is already net of them
+	TransferFees []TransferFee `json:"transferFees,omitempty"`
+	// change since the same request was quoted at the previous block, omitted for the first quote
+	Diff       *QuoteDiff  `json:"diff,omitempty"`
+	Deployment *Deployment `json:"deployment,omitempty"`
+	// symbols and decimals of the tokens and the amounts in their units, omitted until SetTokens
+	TokenInInfo  *Token            `json:"tokenInInfo,omitempty"`
+	TokenOutInfo *Token            `json:"tokenOutInfo,omitempty"`
+	Formatted    *FormattedAmounts `json:"formatted,omitempty"`
+}
+
+// NewQuoteResponse is the JSON form of a route returned by RouteWithMetadata, with AmountOutMin
+// at slippageBips
+func NewQuoteResponse(tokenIn, tokenOut common.Address, amountIn, amountOut *big.Int, path []common.Address, metadata *RouteMetadata, slippageBips int64) (*QuoteResponse, error) {
+	amountOutMin, err := AmountOutMin(amountOut, slippageBips)
+	if err != nil {
+		return nil, err
+	}
+	response := &QuoteResponse{
+		TokenIn:            tokenIn,
+		TokenOut:           tokenOut,
+		AmountIn:           amountIn.String(),
+		AmountOut:          amountOut.String(),
+		AmountOutMin:       amountOutMin.String(),
+		SlippageBips:       slippageBips,
+		Path:               path,
+		Hops:               metadata.Hops,
+		PriceImpactPercent: priceImpactPercent(metadata.PriceImpact),
+		BlockNumber:        metadata.BlockNumber,
+		RequestID:          metadata.RequestID,
+		ReserveWarnings:    metadata.ReserveWarnings,
+		TransferFees:       metadata.TransferFees,
+		Deployment:         metadata.Deployment,
+	}
+	if metadata.PriceImpact != nil {
+		response.PriceImpact, _ = metadata.PriceImpact.Float64()
+	}
+	if response.PriceImpactPercent > HighPriceImpact*100 {
+		response.PriceImpactWarning = fmt.Sprintf("price impact of %.2f%% exceeds %v%%", response.PriceImpactPercent, HighPriceImpact*100)
+	}
+	if metadata.GasCost != nil {
+		response.GasCost = metadata.GasCost.String()
+	}
+	return response, nil
+}
+
+// SetTokens adds the symbols and decimals of the tokens and the amounts in their units
+func (r *QuoteResponse) SetTokens(tokenIn, tokenOut Token) {
+	format := func(token Token, amount string) string {
+		units, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			return ""
+		}
+		return token.FormatAmount(units)
+	}
+	r.TokenInInfo, r.TokenOutInfo = &tokenIn, &tokenOut
+	r.Formatted = &FormattedAmounts{
+		AmountIn:     format(tokenIn, r.AmountIn),
+		AmountOut:    format(tokenOut, r.AmountOut),
+		AmountOutMin: format(tokenOut, r.AmountOutMin),
+		GasCost:      format(tokenOut, r.GasCost),
+	}
+}
+
+// ErrorResponse is the JSON form of an error. Code names the error callers can branch on, empty
+// for errors without one.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+	// why no route was found, see NoRouteError
+	Reasons []NoRouteReason `json:"reasons,omitempty"`
+	// every invalid setting, see SettingsError
+	Problems []string `json:"problems,omitempty"`
+}
+
+// errorCodes are checked in order, a NoRouteError for shallow pools matches
+// ErrInsufficientLiquidity as well
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrSameToken, "same_token"},
+	{ErrNoRoute, "no_route"},
+	{ErrPairNotFound, "pair_not_found"},
+	{ErrMaxHopsExceeded, "max_hops_exceeded"},
+	{ErrInsufficientLiquidity, "insufficient_liquidity"},
+}
+
+// NewErrorResponse is the JSON form of err, coded by the error it wraps
+func NewErrorResponse(err error) ErrorResponse {
+	response := ErrorResponse{Error: err.Error()}
+	for _, errorCode := range errorCodes {
+		if errors.Is(err, errorCode.err) {
+			response.Code = errorCode.code
+			break
+		}
+	}
+	var noRouteErr *NoRouteError
+	if errors.As(err, &noRouteErr) {
+		response.Reasons = noRouteErr.Reasons
+	}
+	var settingsErr *SettingsError
+	if errors.As(err, &settingsErr) {
+		response.Code, response.Problems = "invalid_settings", settingsErr.Problems
+	}
+	return response
+}
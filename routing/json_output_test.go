@@ -0,0 +1,64 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNewQuoteResponse(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	metadata := &RouteMetadata{
+		PriceImpact: big.NewRat(3, 100),
+		GasCost:     big.NewInt(1500),
+		RequestID:   "request",
+	}
+	// amounts beyond float64 precision stay exact
+	amountOut, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	response, err := NewQuoteResponse(weth, usdc, big.NewInt(1000), amountOut, []common.Address{weth, usdc}, metadata, 100)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	for _, want := range []string{`"amountOut":"123456789012345678901234567890"`, `"amountOutMin":"122222221122222222112222222211"`, `"gasCost":"1500"`, `"priceImpactWarning":"price impact of 3.00% exceeds 1%"`} {
+		if !strings.Contains(string(encoded), want) {
+			t.Errorf("got %s want %s", encoded, want)
+		}
+	}
+	if _, err := NewQuoteResponse(weth, usdc, big.NewInt(1000), amountOut, nil, metadata, 10000); err == nil {
+		t.Error("got no error for a slippage of 100%")
+	}
+}
+
+func TestNewErrorResponse(t *testing.T) {
+	shallow := &NoRouteError{Reasons: []NoRouteReason{InsufficientLiquidity}}
+	tests := []struct {
+		err     error
+		code    string
+		reasons int
+	}{
+		{fmt.Errorf("routing: %w", ErrSameToken), "same_token", 0},
+		{fmt.Errorf("routing: %w", shallow), "no_route", 1},
+		{fmt.Errorf("%w between a and b", ErrPairNotFound), "pair_not_found", 0},
+		{errors.New("connection refused"), "", 0},
+	}
+	for _, test := range tests {
+		response := NewErrorResponse(test.err)
+		if response.Error != test.err.Error() || response.Code != test.code || len(response.Reasons) != test.reasons {
+			t.Errorf("%v: got %+v want code %q with %v reasons", test.err, response, test.code, test.reasons)
+		}
+	}
+
+	response := NewErrorResponse(&SettingsError{Source: "router.yaml", Problems: []string{"chain: unknown", "slippageBips: too high"}})
+	if response.Code != "invalid_settings" || len(response.Problems) != 2 {
+		t.Errorf("got %+v want both problems", response)
+	}
+}
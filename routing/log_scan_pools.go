@@ -0,0 +1,201 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// defaultLogScanBlockRange stays below the 10k log limit most RPC providers put on eth_getLogs
+const defaultLogScanBlockRange = 2000
+
+// pairCreatedTopic is the topic of PairCreated(address indexed token0, address indexed token1, address pair, uint)
+var pairCreatedTopic = crypto.Keccak256Hash([]byte("PairCreated(address,address,address,uint256)"))
+
+// LogScanConfig configures NewLogScanPoolsProvider
+type LogScanConfig struct {
+	Factory             common.Address
+	Filterer            ethereum.LogFilterer
+	BlockNumberProvider BlockNumberProvider
+	// first block scanned, the factory's deployment block, e.g. Chain.V2FactoryStartBlock
+	StartBlock uint64
+	// blocks per eth_getLogs request, defaults to defaultLogScanBlockRange
+	BlockRange uint64
+	// optional, file the scan progress is saved to after every range and resumed from
+	CheckpointPath string
+	// optional, when both are set pools below MinLiquidityUSD are dropped like in OnChainPoolsProvider
+	PoolReservesProvider  PoolReservesProvider
+	TokenDecimalsProvider TokenDecimalsProvider
+	// defaults to defaultMinLiquidityUSD
+	MinLiquidityUSD float64
+	// valued at $1 to price the pools, defaults to Mainnet's
+	Stablecoins []common.Address
+	// optional, scan progress is logged to the logger of the context without one
+	Logger *slog.Logger
+}
+
+// LogScanPoolsProvider discovers every pair of a factory from its PairCreated logs instead of
+// looking up pairs between a list of top tokens. Every GetPools call scans the blocks produced
+// since the previous one, so only the first call walks the whole history.
+type LogScanPoolsProvider struct {
+	config LogScanConfig
+
+	mu sync.Mutex
+	// next block to scan and the pools found before it
+	scan logScanCheckpoint
+	// pools the last GetPools dropped for their liquidity
+	thin []Pool
+}
+
+// logScanCheckpoint is the progress of a scan, saved with gob
+type logScanCheckpoint struct {
+	Factory   common.Address
+	NextBlock uint64
+	Pools     []Pool
+}
+
+// NewLogScanPoolsProvider resumes from config.CheckpointPath when it holds a scan of the same factory
+func NewLogScanPoolsProvider(config LogScanConfig) (*LogScanPoolsProvider, error) {
+	if config.Filterer == nil || config.BlockNumberProvider == nil {
+		return nil, errors.New("log scan needs a Filterer and a BlockNumberProvider")
+	}
+	if config.BlockRange == 0 {
+		config.BlockRange = defaultLogScanBlockRange
+	}
+	p := &LogScanPoolsProvider{config: config, scan: logScanCheckpoint{Factory: config.Factory, NextBlock: config.StartBlock}}
+	if config.CheckpointPath == "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(config.CheckpointPath)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint logScanCheckpoint
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&checkpoint); err != nil {
+		return nil, fmt.Errorf("decoding log scan checkpoint %v: %w", config.CheckpointPath, err)
+	}
+	if checkpoint.Factory != config.Factory {
+		return nil, fmt.Errorf("log scan checkpoint %v is for factory %v", config.CheckpointPath, checkpoint.Factory.String())
+	}
+	if checkpoint.NextBlock > config.StartBlock {
+		p.scan = checkpoint
+	}
+	return p, nil
+}
+
+func (p *LogScanPoolsProvider) GetPools(ctx context.Context) ([]Pool, error) {
+	pools, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.config.PoolReservesProvider == nil || p.config.TokenDecimalsProvider == nil {
+		return pools, nil
+	}
+	filter := liquidityFilter{
+		poolReservesProvider:  p.config.PoolReservesProvider,
+		tokenDecimalsProvider: p.config.TokenDecimalsProvider,
+		minLiquidityUSD:       p.config.MinLiquidityUSD,
+		stablecoins:           p.config.Stablecoins,
+	}
+	pools, thin, err := filter.filter(ctx, pools)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.thin = thin
+	p.mu.Unlock()
+	return pools, nil
+}
+
+// PoolsBelowLiquidityThreshold returns the pools the last GetPools dropped for holding less than
+// MinLiquidityUSD
+func (p *LogScanPoolsProvider) PoolsBelowLiquidityThreshold() []Pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.thin
+}
+
+// discover scans up to the latest block and returns a copy of every pool found so far
+func (p *LogScanPoolsProvider) discover(ctx context.Context) ([]Pool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	head, err := p.config.BlockNumberProvider.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for p.scan.NextBlock <= head {
+		to := p.scan.NextBlock + p.config.BlockRange - 1
+		if to > head {
+			to = head
+		}
+		logs, err := p.config.Filterer.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(p.scan.NextBlock),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{p.config.Factory},
+			Topics:    [][]common.Hash{{pairCreatedTopic}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scanning blocks %v-%v: %w", p.scan.NextBlock, to, err)
+		}
+		for _, log := range logs {
+			pool, err := parsePairCreated(log)
+			if err != nil {
+				return nil, err
+			}
+			p.scan.Pools = append(p.scan.Pools, pool)
+		}
+		p.scan.NextBlock = to + 1
+		logInfo(ctx, p.config.Logger, "scanned PairCreated logs", "block", to, "pools", len(p.scan.Pools))
+		if err := p.save(); err != nil {
+			return nil, err
+		}
+	}
+	return append([]Pool(nil), p.scan.Pools...), nil
+}
+
+func parsePairCreated(log types.Log) (Pool, error) {
+	if len(log.Topics) != 3 || log.Topics[0] != pairCreatedTopic || len(log.Data) != 64 {
+		return Pool{}, fmt.Errorf("malformed PairCreated log %v/%v", log.BlockNumber, log.Index)
+	}
+	return Pool{
+		Token0:   common.BytesToAddress(log.Topics[1].Bytes()),
+		Token1:   common.BytesToAddress(log.Topics[2].Bytes()),
+		Contract: common.BytesToAddress(log.Data[:32]),
+	}, nil
+}
+
+// save writes to a temporary file first so an interrupted write never corrupts the last checkpoint
+func (p *LogScanPoolsProvider) save() error {
+	if p.config.CheckpointPath == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(p.scan); err != nil {
+		return err
+	}
+	tmpPath := p.config.CheckpointPath + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, p.config.CheckpointPath)
+}
+
+func (p *LogScanPoolsProvider) CacheSummary() CacheSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return CacheSummary{Entries: len(p.scan.Pools), Detail: fmt.Sprintf("next block %v", p.scan.NextBlock)}
+}
@@ -0,0 +1,133 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// pairCreatedLogsFake serves PairCreated logs by block and records the scanned ranges
+type pairCreatedLogsFake struct {
+	logs   []types.Log
+	ranges [][2]uint64
+	// fails queries starting at this block when set
+	failFrom uint64
+}
+
+func (f *pairCreatedLogsFake) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+	if f.failFrom != 0 && from >= f.failFrom {
+		return nil, errors.New("rpc unavailable")
+	}
+	f.ranges = append(f.ranges, [2]uint64{from, to})
+	logs := []types.Log{}
+	for _, log := range f.logs {
+		if log.BlockNumber >= from && log.BlockNumber <= to {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func (f *pairCreatedLogsFake) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("not supported")
+}
+
+func pairCreatedLog(blockNumber uint64, tokenA, tokenB common.Address) types.Log {
+	data := make([]byte, 64)
+	copy(data[12:32], fakePairAddress(tokenA, tokenB).Bytes())
+	return types.Log{
+		BlockNumber: blockNumber,
+		Topics:      []common.Hash{pairCreatedTopic, common.BytesToHash(tokenA.Bytes()), common.BytesToHash(tokenB.Bytes())},
+		Data:        data,
+	}
+}
+
+func TestLogScanPoolsProvider(t *testing.T) {
+	filterer := &pairCreatedLogsFake{logs: []types.Log{
+		pairCreatedLog(105, common.HexToAddress(USDC), common.HexToAddress(WETH)),
+		pairCreatedLog(112, common.HexToAddress(DAI), common.HexToAddress(WETH)),
+		pairCreatedLog(131, common.HexToAddress(PAXG), common.HexToAddress(WISE)),
+	}}
+	config := LogScanConfig{
+		Factory:             common.HexToAddress(FACTORY_ADDRESS),
+		Filterer:            filterer,
+		BlockNumberProvider: staticBlockNumberProvider(120),
+		StartBlock:          100,
+		BlockRange:          10,
+		CheckpointPath:      filepath.Join(t.TempDir(), "scan.gob"),
+	}
+	provider, err := NewLogScanPoolsProvider(config)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	pools, err := provider.GetPools(context.Background())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(pools) != 2 || pools[1].Contract != fakePairAddress(common.HexToAddress(DAI), common.HexToAddress(WETH)) || pools[1].Token0 != common.HexToAddress(DAI) {
+		t.Errorf("got pools %v want the USDC/WETH and DAI/WETH pairs", pools)
+	}
+	if len(filterer.ranges) != 3 || filterer.ranges[2] != [2]uint64{120, 120} {
+		t.Errorf("got ranges %v want 100-109, 110-119, 120-120", filterer.ranges)
+	}
+
+	// a new provider resumes after the checkpoint, a failed range is retried on the next call
+	config.BlockNumberProvider = staticBlockNumberProvider(140)
+	filterer.ranges, filterer.failFrom = nil, 131
+	provider, err = NewLogScanPoolsProvider(config)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if _, err := provider.GetPools(context.Background()); err == nil {
+		t.Fatal("expected the failing range to fail the scan")
+	}
+	filterer.failFrom = 0
+	pools, err = provider.GetPools(context.Background())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(pools) != 3 {
+		t.Errorf("got pools %v want 3", pools)
+	}
+	if len(filterer.ranges) != 2 || filterer.ranges[0] != [2]uint64{121, 130} || filterer.ranges[1] != [2]uint64{131, 140} {
+		t.Errorf("got ranges %v want 121-130, 131-140", filterer.ranges)
+	}
+
+	config.Factory = common.HexToAddress(SUSHISWAP_FACTORY_ADDRESS)
+	if _, err := NewLogScanPoolsProvider(config); err == nil {
+		t.Error("expected an error resuming another factory's scan")
+	}
+}
+
+func TestLogScanPoolsProviderFiltersByLiquidity(t *testing.T) {
+	graph := &v2GraphFake{}
+	// $2M a side and $1k a side
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(WETH), 2000000, 1000)
+	graph.addPool(common.HexToAddress(DAI), common.HexToAddress(USDC), 1000, 1000)
+	filterer := &pairCreatedLogsFake{logs: []types.Log{
+		pairCreatedLog(1, common.HexToAddress(USDC), common.HexToAddress(WETH)),
+		pairCreatedLog(2, common.HexToAddress(DAI), common.HexToAddress(USDC)),
+	}}
+	provider, err := NewLogScanPoolsProvider(LogScanConfig{
+		Filterer:              filterer,
+		BlockNumberProvider:   staticBlockNumberProvider(2),
+		PoolReservesProvider:  graph,
+		TokenDecimalsProvider: wholeTokenDecimals{},
+	})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	pools, err := provider.GetPools(context.Background())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(pools) != 1 || pools[0].Token1 != common.HexToAddress(WETH) {
+		t.Errorf("got pools %v want only USDC/WETH", pools)
+	}
+}
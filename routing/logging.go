@@ -0,0 +1,92 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// NewLogger is a structured logger writing records at level and above ("debug", "info", "warn" or
+// "error", info when empty) to w, as logfmt-style "text" lines or "json" lines
+func NewLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	leveler, err := parseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	options := &slog.HandlerOptions{Level: leveler}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, want text or json", format)
+}
+
+func parseLogLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, want debug, info, warn or error", level)
+	}
+	return parsed, nil
+}
+
+type loggerKey struct{}
+
+// WithLogger makes the providers called with the returned context log to logger unless they were
+// given their own, V2RouterConfig.Logger sets it for every route
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger of ctx, a logger discarding everything when none was set
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return discardLogger
+}
+
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logAt logs to logger, or the logger of ctx when it is nil, with the request ID of ctx so one
+// quote can be followed through the logs
+func logAt(ctx context.Context, logger *slog.Logger, level slog.Level, msg string, args ...any) {
+	if logger == nil {
+		logger = LoggerFromContext(ctx)
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		args = append([]any{"request_id", requestID}, args...)
+	}
+	logger.Log(ctx, level, msg, args...)
+}
+
+// logDebug logs the details of a request, requests not picked by the trace sampler are skipped
+func logDebug(ctx context.Context, logger *slog.Logger, msg string, args ...any) {
+	if isTraced(ctx) {
+		logAt(ctx, logger, slog.LevelDebug, msg, args...)
+	}
+}
+
+func logInfo(ctx context.Context, logger *slog.Logger, msg string, args ...any) {
+	logAt(ctx, logger, slog.LevelInfo, msg, args...)
+}
+
+func logWarn(ctx context.Context, logger *slog.Logger, msg string, args ...any) {
+	logAt(ctx, logger, slog.LevelWarn, msg, args...)
+}
@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNewLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := NewLogger(&out, "warn", "json")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	logger.Info("dropped")
+	logger.Warn("kept", "pairs", 3)
+	var record map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil || record["msg"] != "kept" || record["pairs"] != 3.0 {
+		t.Errorf("got %q error %v want only the warning as JSON", out.String(), err)
+	}
+	for _, settings := range [][2]string{{"loud", "text"}, {"info", "xml"}} {
+		if _, err := NewLogger(&out, settings[0], settings[1]); err == nil {
+			t.Errorf("got no error for level %q and format %q", settings[0], settings[1])
+		}
+	}
+
+	if _, err := LoadSettings("", envFake(map[string]string{"ROUTER_LOG_LEVEL": "loud"})); err == nil || !strings.Contains(err.Error(), "log:") {
+		t.Errorf("got error %v want the log level reported", err)
+	}
+}
+
+func TestRouteLogging(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1000000000)
+	var out bytes.Buffer
+	logger, _ := NewLogger(&out, "debug", "text")
+	router := newFakeRouter(graph)
+	router.logger = logger
+	ctx := WithRequestID(context.Background(), "quote-1")
+
+	if _, err := router.Route(ctx, big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 2); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// searchRoute has no logger of its own and logs through the router's on the context
+	if !strings.Contains(out.String(), `msg="best amount" request_id=quote-1 trade_type=exactin hops=2`) {
+		t.Errorf("got logs %q want the best amount of the route", out.String())
+	}
+
+	out.Reset()
+	router.traceSampler = RatioSampler(0)
+	router.Route(ctx, big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 2)
+	if out.Len() != 0 {
+		t.Errorf("got logs %q want no debug logs for requests the sampler skips", out.String())
+	}
+
+	// an injected logger wins over the context's, warnings are not sampled
+	var injected bytes.Buffer
+	injectedLogger, _ := NewLogger(&injected, "info", "text")
+	logWarn(withTraceDecision(WithLogger(ctx, logger), RatioSampler(0)), injectedLogger, "retrying failed read", "error", errors.New("429"))
+	if out.Len() != 0 || !strings.Contains(injected.String(), `level=WARN msg="retrying failed read" request_id=quote-1 error=429`) {
+		t.Errorf("got logs %q and %q want the warning on the injected logger", out.String(), injected.String())
+	}
+	// nothing is logged without a logger
+	logWarn(context.Background(), nil, "dropped")
+}
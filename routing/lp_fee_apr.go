@@ -0,0 +1,158 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultFeeAPRDays is how many days of volume fee estimates average when none are given
+const DefaultFeeAPRDays = 7
+
+// PoolDayVolume is one day of a pair's indexed trading: the token0 side of its swaps and token0's
+// reserve at the end of the day, in the same unit
+type PoolDayVolume struct {
+	Date     time.Time
+	Volume0  float64
+	Reserve0 float64
+}
+
+// PoolVolumeProvider returns the daily volume of a pair over its last complete days, newest first
+type PoolVolumeProvider interface {
+	GetPoolVolume(ctx context.Context, pair common.Address, days int) ([]PoolDayVolume, error)
+}
+
+// SubgraphPoolVolumeProvider reads the pairDayDatas of a Uniswap V2 subgraph
+type SubgraphPoolVolumeProvider struct {
+	url        string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewSubgraphPoolVolumeProvider queries the subgraph at url, e.g. UNISWAP_V2_SUBGRAPH_URL,
+// httpClient defaults to http.DefaultClient
+func NewSubgraphPoolVolumeProvider(url string, httpClient *http.Client) *SubgraphPoolVolumeProvider {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &SubgraphPoolVolumeProvider{url: url, httpClient: httpClient, now: time.Now}
+}
+
+func (p *SubgraphPoolVolumeProvider) GetPoolVolume(ctx context.Context, pair common.Address, days int) ([]PoolDayVolume, error) {
+	if days <= 0 || days >= maxSubgraphPageSize {
+		return nil, fmt.Errorf("days must be between 1 and %v", maxSubgraphPageSize-1)
+	}
+	query := `query($pair: Bytes!, $first: Int!) { pairDayDatas(first: $first, orderBy: date, orderDirection: desc, where: {pairAddress: $pair}) { date dailyVolumeToken0 reserve0 } }`
+	var data struct {
+		PairDayDatas []struct {
+			Date              int64  `json:"date"`
+			DailyVolumeToken0 string `json:"dailyVolumeToken0"`
+			Reserve0          string `json:"reserve0"`
+		} `json:"pairDayDatas"`
+	}
+	// one more day in case today's partial day comes first
+	variables := map[string]interface{}{"pair": strings.ToLower(pair.Hex()), "first": days + 1}
+	if err := querySubgraph(ctx, p.httpClient, p.url, query, variables, &data); err != nil {
+		return nil, err
+	}
+	today := p.now().UTC().Truncate(24 * time.Hour)
+	volumes := []PoolDayVolume{}
+	for _, day := range data.PairDayDatas {
+		date := time.Unix(day.Date, 0).UTC()
+		if !date.Before(today) || len(volumes) == days {
+			continue
+		}
+		volume, err := strconv.ParseFloat(day.DailyVolumeToken0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("subgraph returned invalid volume %q", day.DailyVolumeToken0)
+		}
+		reserve, err := strconv.ParseFloat(day.Reserve0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("subgraph returned invalid reserve %q", day.Reserve0)
+		}
+		volumes = append(volumes, PoolDayVolume{Date: date, Volume0: volume, Reserve0: reserve})
+	}
+	return volumes, nil
+}
+
+// LPFeeEstimate is the fee income a deposit into a pair can expect if the pair keeps trading
+// as it did recently
+type LPFeeEstimate struct {
+	Pair common.Address `json:"pair"`
+	// LP tokens minted for the deposit and their share of the pair after it
+	Liquidity *big.Int `json:"liquidity"`
+	PoolShare float64  `json:"poolShare"`
+	// average daily volume relative to the pair's liquidity (token0 side of swaps over token0
+	// reserve), over the days with data
+	DailyTurnover float64 `json:"dailyTurnover"`
+	Days          int     `json:"days"`
+	// the fee swaps pay the pair's LPs
+	Fee float64 `json:"fee"`
+	// yearly fees over the deposit's value, without compounding, price changes or volume
+	// growing with the deeper pool
+	APR float64 `json:"apr"`
+}
+
+// EstimateLPFeeAPR estimates the fee APR of depositing amountIn of tokenIn into pair, zapping in
+// like QuoteZapIn, from the average volume of the pair's last days complete days. Half of the
+// pair's value is its token0 reserve, so a turnover t earns LPs fee*t/2 of their value per day,
+// which the deposit dilutes by its share of the pair.
+func (r *OnChainV2Router) EstimateLPFeeAPR(ctx context.Context, pair, tokenIn common.Address, amountIn *big.Int, days, maxHops int) (*LPFeeEstimate, error) {
+	if r.poolVolumeProvider == nil {
+		return nil, errors.New("fee estimates need a PoolVolumeProvider")
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, errors.New("amountIn must be positive")
+	}
+	if days <= 0 {
+		days = DefaultFeeAPRDays
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	lp, err := r.readLPPair(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+	liquidity, err := r.zapIn(ctx, lp, tokenIn, amountIn, maxHops)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := r.poolVolumeProvider.GetPoolVolume(ctx, pair, days)
+	if err != nil {
+		return nil, fmt.Errorf("reading volume of %v: %w", pair.String(), err)
+	}
+	turnover, counted := 0.0, 0
+	for _, day := range volumes {
+		if day.Reserve0 > 0 {
+			turnover += day.Volume0 / day.Reserve0
+			counted++
+		}
+	}
+	if counted == 0 {
+		return nil, fmt.Errorf("no indexed volume for pair %v", pair.String())
+	}
+	turnover /= float64(counted)
+
+	fee, _ := hopReserves{pair: lp.address, dex: lp.dex}.fee().Float64()
+	supplyAfter := new(big.Int).Add(lp.totalSupply, liquidity)
+	share, _ := new(big.Rat).SetFrac(liquidity, supplyAfter).Float64()
+	dilution, _ := new(big.Rat).SetFrac(lp.totalSupply, supplyAfter).Float64()
+	return &LPFeeEstimate{
+		Pair:          pair,
+		Liquidity:     liquidity,
+		PoolShare:     share,
+		DailyTurnover: turnover,
+		Days:          counted,
+		Fee:           fee,
+		APR:           365 * fee * turnover / 2 * dilution,
+	}, nil
+}
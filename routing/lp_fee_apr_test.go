@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type poolVolumeFake []PoolDayVolume
+
+func (f poolVolumeFake) GetPoolVolume(ctx context.Context, pair common.Address, days int) ([]PoolDayVolume, error) {
+	return f, nil
+}
+
+func TestSubgraphPoolVolumeProvider(t *testing.T) {
+	pair := common.HexToAddress(WETH_USDC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Variables["pair"] != strings.ToLower(pair.Hex()) || request.Variables["first"] != 3.0 {
+			t.Errorf("got variables %v want the pair and 3 days", request.Variables)
+		}
+		// today is still trading and left out
+		w.Write([]byte(`{"data":{"pairDayDatas":[
+			{"date":1700092800,"dailyVolumeToken0":"5","reserve0":"100"},
+			{"date":1700006400,"dailyVolumeToken0":"20.5","reserve0":"100"},
+			{"date":1699920000,"dailyVolumeToken0":"10","reserve0":"50"}]}}`))
+	}))
+	defer server.Close()
+
+	provider := NewSubgraphPoolVolumeProvider(server.URL, nil)
+	provider.now = func() time.Time { return time.Unix(1700100000, 0) }
+	volumes, err := provider.GetPoolVolume(context.Background(), pair, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	want := []PoolDayVolume{
+		{Date: time.Unix(1700006400, 0).UTC(), Volume0: 20.5, Reserve0: 100},
+		{Date: time.Unix(1699920000, 0).UTC(), Volume0: 10, Reserve0: 50},
+	}
+	if len(volumes) != 2 || volumes[0] != want[0] || volumes[1] != want[1] {
+		t.Errorf("got %+v want %+v", volumes, want)
+	}
+}
+
+func TestEstimateLPFeeAPR(t *testing.T) {
+	router, pair := newLPRouter()
+	if _, err := router.EstimateLPFeeAPR(context.Background(), pair, common.HexToAddress(WETH), big.NewInt(10000000), 0, 2); err == nil {
+		t.Error("got no error without a PoolVolumeProvider")
+	}
+	// turnovers of 10% and 30%, the day without reserves is skipped
+	router.poolVolumeProvider = poolVolumeFake{{Volume0: 10, Reserve0: 100}, {Volume0: 60, Reserve0: 200}, {Volume0: 5}}
+	estimate, err := router.EstimateLPFeeAPR(context.Background(), pair, common.HexToAddress(WETH), big.NewInt(10000000), 0, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	zapped, _ := router.QuoteZapIn(context.Background(), common.HexToAddress(WETH), big.NewInt(10000000), pair, 2)
+	supply := 1000000.0
+	liquidity := float64(zapped.Int64())
+	want := 365 * 0.003 * 0.2 / 2 * supply / (supply + liquidity)
+	if estimate.Liquidity.Cmp(zapped) != 0 || estimate.Days != 2 || math.Abs(estimate.DailyTurnover-0.2) > 1e-12 || math.Abs(estimate.APR-want) > 1e-12 {
+		t.Errorf("got %+v want %v LP tokens, 20%% turnover over 2 days and an APR of %v", estimate, zapped, want)
+	}
+	if math.Abs(estimate.PoolShare-liquidity/(supply+liquidity)) > 1e-12 {
+		t.Errorf("got pool share %v want %v", estimate.PoolShare, liquidity/(supply+liquidity))
+	}
+
+	server := NewQuoteServer(router, nil)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/lp/apr?pair="+pair.Hex()+"&tokenIn="+WETH+"&amountIn=10000000&maxHops=2", nil))
+	var response struct {
+		Liquidity string  `json:"liquidity"`
+		APR       float64 `json:"apr"`
+	}
+	json.NewDecoder(recorder.Body).Decode(&response)
+	if recorder.Code != http.StatusOK || response.Liquidity != zapped.String() || math.Abs(response.APR-want) > 1e-12 {
+		t.Errorf("got status %v response %+v want the estimate", recorder.Code, response)
+	}
+
+	router.poolVolumeProvider = poolVolumeFake{}
+	if _, err := router.EstimateLPFeeAPR(context.Background(), pair, common.HexToAddress(WETH), big.NewInt(10000000), 0, 2); err == nil {
+		t.Error("got no error without indexed volume")
+	}
+}
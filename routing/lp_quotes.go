@@ -0,0 +1,224 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// TotalSupplyProvider returns the ERC-20 total supply of a token, for a pair the LP tokens outstanding
+type TotalSupplyProvider interface {
+	GetTotalSupply(ctx context.Context, tokenAddress common.Address) (*big.Int, error)
+}
+
+type OnChainTotalSupplyProvider struct {
+	rpcClient *ethclient.Client
+}
+
+func NewOnChainTotalSupplyProvider(rpcClient *ethclient.Client) *OnChainTotalSupplyProvider {
+	return &OnChainTotalSupplyProvider{rpcClient: rpcClient}
+}
+
+func (p *OnChainTotalSupplyProvider) GetTotalSupply(ctx context.Context, tokenAddress common.Address) (*big.Int, error) {
+	caller, err := NewMainCaller(tokenAddress, p.rpcClient)
+	if err != nil {
+		return nil, fmt.Errorf("binding token %v: %w", tokenAddress.String(), err)
+	}
+	totalSupply, err := caller.TotalSupply(newCallOpts(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("reading total supply of %v: %w", tokenAddress.String(), err)
+	}
+	return totalSupply, nil
+}
+
+// lpPair is a pair read at one block for valuing its LP token
+type lpPair struct {
+	address     common.Address
+	token0      common.Address
+	token1      common.Address
+	reserve0    *big.Int
+	reserve1    *big.Int
+	totalSupply *big.Int
+	// the DEX the pair belongs to, nil for Uniswap V2 math
+	dex DEXAdapter
+}
+
+func (r *OnChainV2Router) readLPPair(ctx context.Context, pair common.Address) (*lpPair, error) {
+	if r.pairTokensProvider == nil || r.totalSupplyProvider == nil {
+		return nil, errors.New("LP quotes need a PairTokensProvider and a TotalSupplyProvider")
+	}
+	token0, token1, err := r.pairTokensProvider.GetPairTokens(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+	reserve0, reserve1, err := r.poolReservesProvider.GetPoolReserves(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+	totalSupply, err := r.totalSupplyProvider.GetTotalSupply(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+	if totalSupply.Sign() == 0 || reserve0.Sign() == 0 || reserve1.Sign() == 0 {
+		return nil, fmt.Errorf("%w: pair %v is empty", ErrInsufficientLiquidity, pair.String())
+	}
+	lp := &lpPair{address: pair, token0: token0, token1: token1, reserve0: reserve0, reserve1: reserve1, totalSupply: totalSupply}
+	// the fee of the pair is the fee of the DEX whose factory created it
+	for _, dex := range r.adapters() {
+		address, err := dex.GetPair(ctx, token0, token1)
+		if err != nil {
+			return nil, err
+		}
+		if address == pair {
+			lp.dex = dex
+			break
+		}
+	}
+	return lp, nil
+}
+
+// QuoteZapOut returns the tokenOut received for burning liquidity LP tokens of pair and swapping
+// both underlying tokens into tokenOut. When tokenOut is one of the pair's tokens the other one is
+// swapped through the pair itself after the burn, as zap contracts do.
+func (r *OnChainV2Router) QuoteZapOut(ctx context.Context, pair common.Address, liquidity *big.Int, tokenOut common.Address, maxHops int) (*big.Int, error) {
+	if liquidity == nil || liquidity.Sign() <= 0 {
+		return nil, errors.New("liquidity must be positive")
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	lp, err := r.readLPPair(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+	if liquidity.Cmp(lp.totalSupply) > 0 {
+		return nil, fmt.Errorf("liquidity %v exceeds the total supply %v of pair %v", liquidity, lp.totalSupply, pair.String())
+	}
+	// UniswapV2Pair.burn pays out the LP share of each reserve, rounded down
+	amount0 := new(big.Int).Div(new(big.Int).Mul(liquidity, lp.reserve0), lp.totalSupply)
+	amount1 := new(big.Int).Div(new(big.Int).Mul(liquidity, lp.reserve1), lp.totalSupply)
+	reserve0 := new(big.Int).Sub(lp.reserve0, amount0)
+	reserve1 := new(big.Int).Sub(lp.reserve1, amount1)
+
+	switch tokenOut {
+	case lp.token0:
+		swapped, err := hopReserves{reserveIn: reserve1, reserveOut: reserve0, pair: pair, dex: lp.dex}.GetAmountOut(amount1)
+		if err != nil {
+			return nil, err
+		}
+		return swapped.Add(swapped, amount0), nil
+	case lp.token1:
+		swapped, err := hopReserves{reserveIn: reserve0, reserveOut: reserve1, pair: pair, dex: lp.dex}.GetAmountOut(amount0)
+		if err != nil {
+			return nil, err
+		}
+		return swapped.Add(swapped, amount1), nil
+	}
+	amountOut := new(big.Int)
+	for _, underlying := range []struct {
+		token  common.Address
+		amount *big.Int
+	}{{lp.token0, amount0}, {lp.token1, amount1}} {
+		if underlying.amount.Sign() == 0 {
+			continue
+		}
+		quote, err := r.Route(ctx, underlying.amount, underlying.token, tokenOut, maxHops)
+		if err != nil {
+			return nil, err
+		}
+		amountOut.Add(amountOut, quote.AmountOut)
+	}
+	return amountOut, nil
+}
+
+// QuoteZapIn returns the LP tokens of pair minted for amountIn of tokenIn. A pair token is split by
+// swapping the share that balances the deposit through the pair, any other token is first routed
+// into whichever pair token mints more. The route into the pair ignores that it may cross the pair.
+func (r *OnChainV2Router) QuoteZapIn(ctx context.Context, tokenIn common.Address, amountIn *big.Int, pair common.Address, maxHops int) (*big.Int, error) {
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, errors.New("amountIn must be positive")
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	lp, err := r.readLPPair(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+	return r.zapIn(ctx, lp, tokenIn, amountIn, maxHops)
+}
+
+func (r *OnChainV2Router) zapIn(ctx context.Context, lp *lpPair, tokenIn common.Address, amountIn *big.Int, maxHops int) (*big.Int, error) {
+	switch tokenIn {
+	case lp.token0:
+		return lp.zapInSingleSided(amountIn, true)
+	case lp.token1:
+		return lp.zapInSingleSided(amountIn, false)
+	}
+	var best *big.Int
+	for _, token0 := range []bool{true, false} {
+		underlying := lp.token1
+		if token0 {
+			underlying = lp.token0
+		}
+		quote, err := r.Route(ctx, amountIn, tokenIn, underlying, maxHops)
+		if err != nil {
+			continue
+		}
+		liquidity, err := lp.zapInSingleSided(quote.AmountOut, token0)
+		if err != nil {
+			continue
+		}
+		if best == nil || liquidity.Cmp(best) > 0 {
+			best = liquidity
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w from %v into pair %v", ErrNoRoute, tokenIn.String(), lp.address.String())
+	}
+	return best, nil
+}
+
+// zapInSingleSided swaps the share of amount that leaves the rest in the pool's new ratio and
+// returns the LP tokens minted for depositing both sides
+func (lp *lpPair) zapInSingleSided(amount *big.Int, token0 bool) (*big.Int, error) {
+	reserveIn, reserveOut := lp.reserve0, lp.reserve1
+	if !token0 {
+		reserveIn, reserveOut = lp.reserve1, lp.reserve0
+	}
+	hop := hopReserves{reserveIn: reserveIn, reserveOut: reserveOut, pair: lp.address, dex: lp.dex}
+	swapIn := optimalSwapAmount(amount, reserveIn, hop.fee())
+	swapOut, err := hop.GetAmountOut(swapIn)
+	if err != nil {
+		return nil, err
+	}
+	depositIn := new(big.Int).Sub(amount, swapIn)
+	newReserveIn := new(big.Int).Add(reserveIn, swapIn)
+	newReserveOut := new(big.Int).Sub(reserveOut, swapOut)
+	// UniswapV2Pair.mint credits the smaller of the two deposit shares
+	liquidityIn := new(big.Int).Div(new(big.Int).Mul(depositIn, lp.totalSupply), newReserveIn)
+	liquidityOut := new(big.Int).Div(new(big.Int).Mul(swapOut, lp.totalSupply), newReserveOut)
+	if liquidityIn.Cmp(liquidityOut) < 0 {
+		return liquidityIn, nil
+	}
+	return liquidityOut, nil
+}
+
+// optimalSwapAmount returns how much of amount to swap so the remainder and the output match the
+// pool ratio after the swap. With g = 1 - fee = n/d, solving (amount - s) / (reserveIn + s) =
+// g*s / reserveIn gives s = (sqrt(((d+n)*reserveIn)^2 + 4*n*d*reserveIn*amount) - (d+n)*reserveIn) / (2*n)
+func optimalSwapAmount(amount, reserveIn *big.Int, fee *big.Rat) *big.Int {
+	kept := new(big.Rat).Sub(big.NewRat(1, 1), fee)
+	n, d := kept.Num(), kept.Denom()
+	b := new(big.Int).Mul(new(big.Int).Add(d, n), reserveIn)
+	discriminant := new(big.Int).Mul(b, b)
+	discriminant.Add(discriminant, new(big.Int).Mul(new(big.Int).Mul(big.NewInt(4), new(big.Int).Mul(n, d)), new(big.Int).Mul(reserveIn, amount)))
+	swapIn := new(big.Int).Sub(new(big.Int).Sqrt(discriminant), b)
+	return swapIn.Div(swapIn, new(big.Int).Mul(big.NewInt(2), n))
+}
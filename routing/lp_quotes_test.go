@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type totalSupplyProviderMock map[common.Address]*big.Int
+
+func (m totalSupplyProviderMock) GetTotalSupply(ctx context.Context, tokenAddress common.Address) (*big.Int, error) {
+	return m[tokenAddress], nil
+}
+
+func newLPRouter() (*OnChainV2Router, common.Address) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000000, 2000000000000)
+	graph.addPool(common.HexToAddress(DAI), common.HexToAddress(USDC), 1000000000000, 1000000000000)
+	pair := fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC))
+	router := newFakeRouter(graph)
+	router.pairTokensProvider = graph
+	router.totalSupplyProvider = totalSupplyProviderMock{pair: big.NewInt(1000000)}
+	return router, pair
+}
+
+func TestQuoteZapOut(t *testing.T) {
+	router, pair := newLPRouter()
+	// USDC sorts before WETH so it is token0
+	liquidity := big.NewInt(100000)
+	amountUSDC := big.NewInt(200000000000)
+	amountWETH := big.NewInt(100000000)
+
+	got, err := router.QuoteZapOut(context.Background(), pair, liquidity, common.HexToAddress(WETH), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	swapped, _ := getAmountOut(amountUSDC, big.NewInt(1800000000000), big.NewInt(900000000))
+	want := new(big.Int).Add(amountWETH, swapped)
+	if got.Cmp(want) != 0 {
+		t.Errorf("got %v WETH want %v", got, want)
+	}
+
+	// DAI is not in the pair so both sides are routed
+	got, err = router.QuoteZapOut(context.Background(), pair, liquidity, common.HexToAddress(DAI), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	fromUSDC, _ := getAmountOut(amountUSDC, big.NewInt(1000000000000), big.NewInt(1000000000000))
+	if got.Cmp(fromUSDC) <= 0 {
+		t.Errorf("got %v DAI want more than the %v from the USDC side alone", got, fromUSDC)
+	}
+
+	if _, err := router.QuoteZapOut(context.Background(), pair, big.NewInt(2000000), common.HexToAddress(WETH), 2); err == nil {
+		t.Error("got no error burning more than the total supply")
+	}
+}
+
+func TestQuoteZapIn(t *testing.T) {
+	router, pair := newLPRouter()
+	amountIn := big.NewInt(10000000)
+
+	got, err := router.QuoteZapIn(context.Background(), common.HexToAddress(WETH), amountIn, pair, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// depositing half of each side without fees or price impact is the upper bound, the fee on the
+	// swapped half costs about 0.15%
+	upper := new(big.Int).Div(new(big.Int).Mul(amountIn, big.NewInt(1000000)), big.NewInt(2*1000000000))
+	lower := new(big.Int).Div(new(big.Int).Mul(upper, big.NewInt(995)), big.NewInt(1000))
+	if got.Cmp(upper) >= 0 || got.Cmp(lower) <= 0 {
+		t.Errorf("got %v LP tokens want between %v and %v", got, lower, upper)
+	}
+
+	got, err = router.QuoteZapIn(context.Background(), common.HexToAddress(DAI), big.NewInt(20000000000), pair, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if got.Sign() <= 0 {
+		t.Errorf("got %v LP tokens for DAI want a positive amount", got)
+	}
+}
+
+func TestOptimalSwapAmount(t *testing.T) {
+	// the Zapper formula for the 0.3% fee
+	amount, reserveIn := big.NewInt(1000000), big.NewInt(50000000)
+	want := new(big.Int).Mul(reserveIn, new(big.Int).Add(new(big.Int).Mul(reserveIn, big.NewInt(3988009)), new(big.Int).Mul(amount, big.NewInt(3988000))))
+	want.Sqrt(want).Sub(want, new(big.Int).Mul(reserveIn, big.NewInt(1997))).Div(want, big.NewInt(1994))
+	if got := optimalSwapAmount(amount, reserveIn, big.NewRat(3, 1000)); got.Cmp(want) != 0 {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// amounts above uint128 cannot be backed by V2 reserves, which are uint112
+var maxProbeAmount = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// MaxAmountIn finds the largest amountIn whose price impact along its best route stays at or
+// below maxPriceImpact (0.01 is 1%). It returns the amount, the route and the expected output.
+func (r *OnChainV2Router) MaxAmountIn(ctx context.Context, tokenIn, tokenOut common.Address, maxPriceImpact float64, maxHops int) (*big.Int, []common.Address, *big.Int, error) {
+	if maxPriceImpact <= 0 || maxPriceImpact >= 1 {
+		return nil, nil, nil, errors.New("maxPriceImpact must be between 0 and 1")
+	}
+	if tokenIn == tokenOut {
+		return nil, nil, nil, fmt.Errorf("%w: %v", ErrSameToken, tokenIn.String())
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	maxHops, err = r.resolveMaxHops(ctx, tokenIn, tokenOut, maxHops)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	graph, err := r.buildGraph(ctx, tokenIn, tokenOut, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// the largest amount routes unless the pools cannot take any amount at all
+	if _, _, _, err := searchRoute(ctx, graph, exactIn, maxProbeAmount, maxHops, nil, &RouteMetadata{}, nil); err != nil {
+		return nil, nil, nil, err
+	}
+	limit := new(big.Rat).SetFloat64(maxPriceImpact)
+	// smallest amount that routed so far, failures below it round to nothing in the pools
+	var routed *big.Int
+	// amounts too small to route after rounding count as within the limit, amounts the pools are
+	// too shallow for as over it
+	withinLimit := func(amountIn *big.Int) (bool, error) {
+		_, _, hops, err := searchRoute(ctx, graph, exactIn, amountIn, maxHops, nil, &RouteMetadata{}, nil)
+		if errors.Is(err, ErrInsufficientLiquidity) {
+			return routed == nil || amountIn.Cmp(routed) < 0, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if routed == nil || amountIn.Cmp(routed) < 0 {
+			routed = new(big.Int).Set(amountIn)
+		}
+		return pathPriceImpact(amountIn, hops).Cmp(limit) <= 0, nil
+	}
+
+	// double the upper bound until it breaks the limit
+	high := big.NewInt(1)
+	for high.Cmp(maxProbeAmount) < 0 {
+		within, err := withinLimit(high)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !within {
+			break
+		}
+		high.Lsh(high, 1)
+	}
+	// binary search for the largest amount within the limit, low always satisfies it
+	low := big.NewInt(0)
+	one := big.NewInt(1)
+	for new(big.Int).Sub(high, low).Cmp(one) > 0 {
+		mid := new(big.Int).Rsh(new(big.Int).Add(low, high), 1)
+		within, err := withinLimit(mid)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if within {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	if low.Sign() == 0 {
+		return nil, nil, nil, errors.New("no amount stays within the price impact limit")
+	}
+	amountOut, path, _, err := searchRoute(ctx, graph, exactIn, low, maxHops, nil, &RouteMetadata{}, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return low, path, amountOut, nil
+}
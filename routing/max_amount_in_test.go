@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMaxAmountIn(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	router := newFakeRouter(graph)
+
+	amountIn, path, amountOut, err := router.MaxAmountIn(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDC), 0.01, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(path) != 2 {
+		t.Fatalf("got path %v want the direct pair", path)
+	}
+	hops := []hopReserves{{reserveIn: big.NewInt(1000000), reserveOut: big.NewInt(2000000000)}}
+	limit := new(big.Rat).SetFloat64(0.01)
+	if pathPriceImpact(amountIn, hops).Cmp(limit) > 0 {
+		t.Errorf("impact of %d exceeds 1%%", amountIn)
+	}
+	if pathPriceImpact(new(big.Int).Add(amountIn, big.NewInt(1)), hops).Cmp(limit) <= 0 {
+		t.Errorf("%d is not the largest amount within 1%%", amountIn)
+	}
+	// 997x/(1000R + 997x) <= 1% solves to x <= 10R/987.03 for R = 1e6
+	if amountIn.Cmp(big.NewInt(10131)) != 0 {
+		t.Errorf("got %d want 10131", amountIn)
+	}
+	wantOut, _ := getAmountOut(amountIn, big.NewInt(1000000), big.NewInt(2000000000))
+	if amountOut.Cmp(wantOut) != 0 {
+		t.Errorf("got %d out want %d", amountOut, wantOut)
+	}
+
+	if _, _, _, err := router.MaxAmountIn(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDC), 1.5, 2); err == nil {
+		t.Errorf("expected error for an impact limit above 100%%")
+	}
+
+	// pools that round every amount down to nothing cannot be routed through at any size
+	shallow := &v2GraphFake{}
+	shallow.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000000, 1)
+	if _, _, _, err := newFakeRouter(shallow).MaxAmountIn(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDC), 0.01, 2); !errors.Is(err, ErrInsufficientLiquidity) {
+		t.Errorf("got error %v want ErrInsufficientLiquidity for a shallow pool", err)
+	}
+
+	// cancelled halfway through the search for the amount
+	ctx := &cancelAfterContext{Context: context.Background(), calls: -1}
+	router.MaxAmountIn(ctx, common.HexToAddress(WETH), common.HexToAddress(USDC), 0.01, 2)
+	ctx.calls, ctx.checked = ctx.checked/2, 0
+	if amountIn, _, _, err := router.MaxAmountIn(ctx, common.HexToAddress(WETH), common.HexToAddress(USDC), 0.01, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v error %v want context.Canceled", amountIn, err)
+	}
+	if ctx.checked != ctx.calls+1 {
+		t.Errorf("got %v context checks after the cancellation want the search stopped", ctx.checked-ctx.calls-1)
+	}
+}
+
+// cancelAfterContext is cancelled once Err was called calls times, never when calls is negative,
+// checked counts the calls
+type cancelAfterContext struct {
+	context.Context
+	calls, checked int
+}
+
+func (c *cancelAfterContext) Err() error {
+	c.checked++
+	if c.calls >= 0 && c.checked > c.calls {
+		return context.Canceled
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package routing
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// bounds of the route duration histogram in seconds, Prometheus' default buckets
+var routeDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// bounds of the hops histogram, deep searches past maxSupportedHops only land in +Inf
+var routeHopsBuckets = []float64{1, 2, 3, 4, 5}
+
+// MetricDefinition is the name, type, help and labels of a series Metrics serves
+type MetricDefinition struct {
+	Name string
+	// counter, gauge or histogram
+	Type   string
+	Help   string
+	Labels []string
+}
+
+// every series is named routing_<subsystem>_<quantity> with its unit as suffix, _total for
+// counters, and labelled with a subset of chain, trade_type, outcome, venue, pair, cache and
+// provider. chain is only set after Metrics.SetChain.
+var (
+	metricRouteDuration    = MetricDefinition{"routing_route_duration_seconds", "histogram", "Time to search a route in seconds.", []string{"chain", "trade_type"}}
+	metricRouteHops        = MetricDefinition{"routing_route_hops", "histogram", "Hops of the routes found.", []string{"chain", "trade_type"}}
+	metricRoutes           = MetricDefinition{"routing_routes_total", "counter", "Route searches by outcome, the error code of failures.", []string{"chain", "trade_type", "outcome"}}
+	metricRouteSwaps       = MetricDefinition{"routing_route_swaps_total", "counter", "Swaps of the routes found by venue and token pair, the pair's token addresses in order.", []string{"chain", "trade_type", "venue", "pair"}}
+	metricEdgesEvaluated   = MetricDefinition{"routing_search_edges_evaluated_total", "counter", "Hops priced by route searches.", []string{"chain", "trade_type"}}
+	metricTokensConsidered = MetricDefinition{"routing_search_tokens_considered_total", "counter", "Tokens in the graphs of route searches.", []string{"chain", "trade_type"}}
+	metricSearchCacheHits  = MetricDefinition{"routing_search_cache_hits_total", "counter", "Reserve lookups of route searches served from the per-route cache.", []string{"chain", "trade_type"}}
+	metricPruned           = MetricDefinition{"routing_search_pruned_candidates_total", "counter", "Hops route searches skipped as their input token was not reachable yet.", []string{"chain", "trade_type"}}
+	metricCacheHits        = MetricDefinition{"routing_cache_hits_total", "counter", "Lookups served from the cache.", []string{"chain", "cache"}}
+	metricCacheMisses      = MetricDefinition{"routing_cache_misses_total", "counter", "Lookups the cache passed on to its provider.", []string{"chain", "cache"}}
+	metricCacheEntries     = MetricDefinition{"routing_cache_entries", "gauge", "Entries in the cache.", []string{"chain", "cache"}}
+	metricRPCRequests      = MetricDefinition{"routing_rpc_requests_total", "counter", "JSON-RPC requests sent to the endpoint.", []string{"chain", "provider"}}
+	metricRPCFailures      = MetricDefinition{"routing_rpc_failures_total", "counter", "JSON-RPC requests the endpoint failed, failing over to the next.", []string{"chain", "provider"}}
+)
+
+// MetricDefinitions returns every series Metrics serves, in the order they are written
+func MetricDefinitions() []MetricDefinition {
+	return []MetricDefinition{
+		metricRouteDuration, metricRouteHops, metricRoutes, metricRouteSwaps,
+		metricEdgesEvaluated, metricTokensConsidered, metricSearchCacheHits, metricPruned,
+		metricCacheHits, metricCacheMisses, metricCacheEntries,
+		metricRPCRequests, metricRPCFailures,
+	}
+}
+
+// Metrics counts the routes of a router, the lookups of its caches and the RPC requests of
+// failover endpoints, and serves them in the Prometheus text format, see MetricDefinitions. Set
+// it on V2RouterConfig, QuoteServer then serves it on /metrics. GrafanaDashboard charts them.
+type Metrics struct {
+	mu sync.Mutex
+	// the chain label of every series, none when empty
+	chain     string
+	durations map[string]*histogram
+	hops      map[string]*histogram
+	routes    map[string]uint64
+	swaps     map[string]uint64
+	// search work by trade type, summed from RouteMetadata
+	edges     map[string]uint64
+	tokens    map[string]uint64
+	pairHits  map[string]uint64
+	pruned    map[string]uint64
+	caches    map[string]cacheSummarizer
+	endpoints []*FailoverTransport
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		durations: make(map[string]*histogram),
+		hops:      make(map[string]*histogram),
+		routes:    make(map[string]uint64),
+		swaps:     make(map[string]uint64),
+		edges:     make(map[string]uint64),
+		tokens:    make(map[string]uint64),
+		pairHits:  make(map[string]uint64),
+		pruned:    make(map[string]uint64),
+		caches:    make(map[string]cacheSummarizer),
+	}
+}
+
+// SetChain labels every series with the name of chain, so the metrics of routers on several
+// chains can be told apart
+func (m *Metrics) SetChain(chain Chain) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chain = chain.Name
+}
+
+// labels renders the chain label followed by pairs
+func (m *Metrics) labels(pairs ...string) string {
+	if m.chain == "" {
+		return metricLabels(pairs...)
+	}
+	return metricLabels(append([]string{"chain", m.chain}, pairs...)...)
+}
+
+// WatchCache reports the hits, misses and entries of cache under name, read on every scrape
+func (m *Metrics) WatchCache(name string, cache interface{ CacheSummary() CacheSummary }) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.caches[name] = cache
+}
+
+// WatchEndpoints reports the requests and failures of every endpoint of transport, read on every
+// scrape
+func (m *Metrics) WatchEndpoints(transport *FailoverTransport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endpoints = append(m.endpoints, transport)
+}
+
+// observeRoute records a route search that took elapsed, a nil Metrics records nothing
+func (m *Metrics) observeRoute(tradeType tradeType, elapsed time.Duration, metadata *RouteMetadata, err error) {
+	if m == nil {
+		return
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = NewErrorResponse(err).Code
+		if outcome == "" {
+			outcome = "error"
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byTradeType := m.labels("trade_type", tradeType.String())
+	m.routes[m.labels("trade_type", tradeType.String(), "outcome", outcome)]++
+	observe(m.durations, byTradeType, routeDurationBuckets, elapsed.Seconds())
+	// failed searches did the work too
+	m.edges[byTradeType] += uint64(metadata.EdgesEvaluated)
+	m.tokens[byTradeType] += uint64(metadata.TokensConsidered)
+	m.pairHits[byTradeType] += uint64(metadata.CacheHits)
+	m.pruned[byTradeType] += uint64(metadata.PrunedCandidates)
+	if err == nil {
+		observe(m.hops, byTradeType, routeHopsBuckets, float64(len(metadata.Hops)))
+		for _, hop := range metadata.Hops {
+			tokens := []common.Address{hop.TokenIn, hop.TokenOut}
+			sortAddresses(tokens)
+			m.swaps[m.labels("trade_type", tradeType.String(), "venue", hop.DEX, "pair", tokens[0].Hex()+"/"+tokens[1].Hex())]++
+		}
+	}
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.Write(w)
+}
+
+// Write writes every metric in the Prometheus text format, series sorted by their labels
+func (m *Metrics) Write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := bufio.NewWriter(w)
+	writeHistograms(out, metricRouteDuration, m.durations)
+	writeHistograms(out, metricRouteHops, m.hops)
+	writeSeries(out, metricRoutes, m.routes)
+	writeSeries(out, metricRouteSwaps, m.swaps)
+	writeSeries(out, metricEdgesEvaluated, m.edges)
+	writeSeries(out, metricTokensConsidered, m.tokens)
+	writeSeries(out, metricSearchCacheHits, m.pairHits)
+	writeSeries(out, metricPruned, m.pruned)
+
+	hits, misses, entries := map[string]uint64{}, map[string]uint64{}, map[string]uint64{}
+	for name, cache := range m.caches {
+		summary := cache.CacheSummary()
+		labels := m.labels("cache", name)
+		hits[labels], misses[labels], entries[labels] = summary.Hits, summary.Misses, uint64(summary.Entries)
+	}
+	writeSeries(out, metricCacheHits, hits)
+	writeSeries(out, metricCacheMisses, misses)
+	writeSeries(out, metricCacheEntries, entries)
+
+	requests, failures := map[string]uint64{}, map[string]uint64{}
+	for _, transport := range m.endpoints {
+		for _, status := range transport.Status() {
+			// hosts only, the path and query of most provider URLs carry the API key
+			host := status.URL
+			if parsed, err := url.Parse(status.URL); err == nil {
+				host = parsed.Host
+			}
+			labels := m.labels("provider", host)
+			requests[labels] += status.Requests
+			failures[labels] += status.Failures
+		}
+	}
+	writeSeries(out, metricRPCRequests, requests)
+	writeSeries(out, metricRPCFailures, failures)
+	return out.Flush()
+}
+
+// histogram counts observations at or below each of bounds, the last count is +Inf
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+}
+
+func observe(histograms map[string]*histogram, labels string, bounds []float64, value float64) {
+	h, ok := histograms[labels]
+	if !ok {
+		h = &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+		histograms[labels] = h
+	}
+	for i, bound := range bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.counts[len(bounds)]++
+	h.sum += value
+}
+
+// metricLabels renders name/value pairs as the inside of a Prometheus label set
+func metricLabels(pairs ...string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	labels := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, pairs[i]+`="`+escape.Replace(pairs[i+1])+`"`)
+	}
+	return strings.Join(labels, ",")
+}
+
+func sortedKeys[V any](series map[string]V) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeHeader(w io.Writer, metric MetricDefinition) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", metric.Name, metric.Help, metric.Name, metric.Type)
+}
+
+func writeSeries(w io.Writer, metric MetricDefinition, series map[string]uint64) {
+	writeHeader(w, metric)
+	name := metric.Name
+	for _, labels := range sortedKeys(series) {
+		fmt.Fprintf(w, "%v{%v} %v\n", name, labels, series[labels])
+	}
+}
+
+func writeHistograms(w io.Writer, metric MetricDefinition, histograms map[string]*histogram) {
+	writeHeader(w, metric)
+	name := metric.Name
+	for _, labels := range sortedKeys(histograms) {
+		h := histograms[labels]
+		for i, bound := range h.bounds {
+			fmt.Fprintf(w, "%v_bucket{%v,le=\"%v\"} %v\n", name, labels, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "%v_bucket{%v,le=\"+Inf\"} %v\n", name, labels, h.counts[len(h.bounds)])
+		fmt.Fprintf(w, "%v_sum{%v} %v\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%v_count{%v} %v\n", name, labels, h.counts[len(h.bounds)])
+	}
+}
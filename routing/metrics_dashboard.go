@@ -0,0 +1,124 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// grafanaDatasource points panels at the Prometheus datasource picked on the dashboard
+var grafanaDatasource = map[string]string{"type": "prometheus", "uid": "${datasource}"}
+
+type grafanaDashboard struct {
+	UID           string            `json:"uid"`
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Refresh       string            `json:"refresh"`
+	Time          map[string]string `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []grafanaPanel    `json:"panels"`
+}
+
+type grafanaTemplating struct {
+	List []map[string]interface{} `json:"list"`
+}
+
+type grafanaPanel struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Datasource  map[string]string      `json:"datasource"`
+	GridPos     map[string]int         `json:"gridPos"`
+	FieldConfig map[string]interface{} `json:"fieldConfig"`
+	Targets     []grafanaTarget        `json:"targets"`
+}
+
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+// GrafanaDashboard returns a Grafana dashboard charting every series of MetricDefinitions, one
+// panel each, with the Prometheus datasource and the chains to show picked on the dashboard.
+// routing/grafana-dashboard.json is its output, `router dashboard` prints it.
+func GrafanaDashboard() ([]byte, error) {
+	dashboard := grafanaDashboard{
+		UID:           "v2routing",
+		Title:         "Routing",
+		Tags:          []string{"routing"},
+		SchemaVersion: 39,
+		Refresh:       "30s",
+		Time:          map[string]string{"from": "now-6h", "to": "now"},
+		Templating: grafanaTemplating{List: []map[string]interface{}{
+			{"name": "datasource", "label": "Datasource", "type": "datasource", "query": "prometheus"},
+			// series without a chain label match the empty string, so they show under All
+			{"name": "chain", "label": "Chain", "type": "query", "datasource": grafanaDatasource, "query": "label_values(" + metricRoutes.Name + ", chain)", "refresh": 2, "multi": true, "includeAll": true, "allValue": ".*"},
+		}},
+	}
+	for i, metric := range MetricDefinitions() {
+		panel := grafanaPanel{
+			ID:          i + 1,
+			Type:        "timeseries",
+			Title:       metric.Name,
+			Description: metric.Help,
+			Datasource:  grafanaDatasource,
+			// two panels a row
+			GridPos:     map[string]int{"h": 8, "w": 12, "x": 12 * (i % 2), "y": 8 * (i / 2)},
+			FieldConfig: map[string]interface{}{"defaults": map[string]string{"unit": metricUnit(metric)}, "overrides": []interface{}{}},
+			Targets:     metricTargets(metric),
+		}
+		dashboard.Panels = append(dashboard.Panels, panel)
+	}
+	data, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// metricTargets queries the 50th, 95th and 99th percentile of histograms, the per second rate of
+// counters and the value of gauges, summed by every label of the series
+func metricTargets(metric MetricDefinition) []grafanaTarget {
+	legend := make([]string, len(metric.Labels))
+	for i, label := range metric.Labels {
+		legend[i] = "{{" + label + "}}"
+	}
+	selector := `{chain=~"$chain"}`
+	by := strings.Join(metric.Labels, ", ")
+	switch metric.Type {
+	case "histogram":
+		targets := []grafanaTarget{}
+		for i, percentile := range []int{50, 95, 99} {
+			targets = append(targets, grafanaTarget{
+				RefID:        string(rune('A' + i)),
+				Expr:         fmt.Sprintf("histogram_quantile(%v, sum by (le, %v) (rate(%v_bucket%v[$__rate_interval])))", float64(percentile)/100, by, metric.Name, selector),
+				LegendFormat: fmt.Sprintf("p%v %v", percentile, strings.Join(legend, " ")),
+			})
+		}
+		return targets
+	case "counter":
+		expr := fmt.Sprintf("sum by (%v) (rate(%v%v[$__rate_interval]))", by, metric.Name, selector)
+		// one series per venue and pair would crowd the panel
+		if strings.Contains(by, "pair") {
+			expr = "topk(10, " + expr + ")"
+		}
+		return []grafanaTarget{{RefID: "A", Expr: expr, LegendFormat: strings.Join(legend, " ")}}
+	default:
+		return []grafanaTarget{{RefID: "A", Expr: fmt.Sprintf("sum by (%v) (%v%v)", by, metric.Name, selector), LegendFormat: strings.Join(legend, " ")}}
+	}
+}
+
+// metricUnit is the Grafana unit of the values a panel shows
+func metricUnit(metric MetricDefinition) string {
+	switch {
+	case strings.HasSuffix(metric.Name, "_seconds"):
+		return "s"
+	case metric.Type == "counter":
+		return "ops"
+	default:
+		return "short"
+	}
+}
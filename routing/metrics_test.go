@@ -0,0 +1,200 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMetrics(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1000000000)
+	metrics := NewMetrics()
+	router := newFakeRouter(graph)
+	router.metrics = metrics
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := router.Route(ctx, big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 2); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+	}
+	if _, err := router.Route(ctx, big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(WETH), 2); err == nil {
+		t.Fatal("got no error routing a token to itself")
+	}
+
+	decimals := NewCachedTokenDecimalsProvider(graph, 0)
+	metrics.WatchCache("token decimals", decimals)
+	for i := 0; i < 3; i++ {
+		decimals.GetTokenDecimals(ctx, common.HexToAddress(DAI))
+	}
+
+	endpoint := &rpcEndpointFake{block: 100}
+	client, transport := newFailoverFake(t, FailoverConfig{}, endpoint)
+	metrics.WatchEndpoints(transport)
+	client.BlockNumber(ctx)
+	endpoint.set(100, http.StatusTooManyRequests)
+	client.BlockNumber(ctx)
+	host := strings.TrimPrefix(transport.Status()[0].URL, "http://")
+
+	recorder := httptest.NewRecorder()
+	NewQuoteServer(router, graph).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %v want 200", recorder.Code)
+	}
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"# TYPE routing_route_duration_seconds histogram",
+		`routing_route_duration_seconds_count{trade_type="exactin"} 3`,
+		`routing_route_duration_seconds_bucket{trade_type="exactin",le="+Inf"} 3`,
+		`routing_routes_total{trade_type="exactin",outcome="ok"} 2`,
+		`routing_routes_total{trade_type="exactin",outcome="same_token"} 1`,
+		// WETH to DAI goes through USDC, failed routes have no hops
+		`routing_route_hops_bucket{trade_type="exactin",le="1"} 0`,
+		`routing_route_hops_bucket{trade_type="exactin",le="2"} 2`,
+		`routing_route_hops_sum{trade_type="exactin"} 4`,
+		// two searches over WETH, USDC and DAI
+		`routing_search_tokens_considered_total{trade_type="exactin"} 6`,
+		"# TYPE routing_search_edges_evaluated_total counter",
+		"# TYPE routing_search_cache_hits_total counter",
+		"# TYPE routing_search_pruned_candidates_total counter",
+		`routing_cache_hits_total{cache="token decimals"} 2`,
+		`routing_cache_misses_total{cache="token decimals"} 1`,
+		`routing_cache_entries{cache="token decimals"} 1`,
+		`routing_rpc_requests_total{provider="` + host + `"} 2`,
+		`routing_rpc_failures_total{provider="` + host + `"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics are missing %q:\n%v", want, body)
+		}
+	}
+	if strings.Contains(body, `routing_search_edges_evaluated_total{trade_type="exactin"} 0`+"\n") {
+		t.Errorf("got no edges evaluated:\n%v", body)
+	}
+}
+
+func TestMetricsWithoutRouter(t *testing.T) {
+	// routers without Metrics neither record nor serve them
+	var metrics *Metrics
+	metrics.observeRoute(exactIn, 0, &RouteMetadata{}, nil)
+	recorder := httptest.NewRecorder()
+	NewQuoteServer(newFakeRouter(&v2GraphFake{}), &v2GraphFake{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("got status %v want 404", recorder.Code)
+	}
+	if got := metricLabels("cache", `a "b"`); got != `cache="a \"b\""` {
+		t.Errorf("got labels %v want the quotes escaped", got)
+	}
+}
+
+func TestMetricsLabels(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	graph.addPool(usdc, dai, 1000000000, 1000000000)
+	metrics := NewMetrics()
+	metrics.SetChain(Mainnet)
+	router := newFakeRouter(graph)
+	router.metrics = metrics
+	_, _, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(1000), weth, dai, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	var out strings.Builder
+	if err := metrics.Write(&out); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	body := out.String()
+	chain := `chain="` + Mainnet.Name + `"`
+	for _, want := range []string{
+		`routing_routes_total{` + chain + `,trade_type="exactin",outcome="ok"} 1`,
+		`routing_route_swaps_total{` + chain + `,trade_type="exactin",venue="` + metadata.Hops[0].DEX + `",pair="` + usdc.Hex() + "/" + weth.Hex() + `"} 1`,
+		`routing_route_swaps_total{` + chain + `,trade_type="exactin",venue="` + metadata.Hops[1].DEX + `",pair="` + dai.Hex() + "/" + usdc.Hex() + `"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics are missing %q:\n%v", want, body)
+		}
+	}
+
+	// every series written is defined with the labels it carries
+	definitions := make(map[string]MetricDefinition)
+	for _, metric := range MetricDefinitions() {
+		definitions[metric.Name] = metric
+	}
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			fields := strings.Fields(line)
+			if metric, ok := definitions[fields[2]]; !ok || metric.Type != fields[3] {
+				t.Errorf("got undefined series %v", line)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := line[:strings.Index(line, "{")]
+		metric, ok := definitions[name]
+		if !ok {
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				if metric, ok = definitions[strings.TrimSuffix(name, suffix)]; ok {
+					break
+				}
+			}
+		}
+		if !ok {
+			t.Errorf("got undefined series %v", line)
+			continue
+		}
+		allowed := map[string]bool{"le": true}
+		for _, label := range metric.Labels {
+			allowed[label] = true
+		}
+		for _, pair := range strings.Split(line[strings.Index(line, "{")+1:strings.LastIndex(line, "}")], `",`) {
+			if label := pair[:strings.Index(pair, "=")]; !allowed[label] {
+				t.Errorf("got label %v not defined for %v", label, metric.Name)
+			}
+		}
+	}
+}
+
+func TestGrafanaDashboard(t *testing.T) {
+	dashboard, err := GrafanaDashboard()
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// the shipped file is generated, regenerate it with `router dashboard > routing/grafana-dashboard.json`
+	shipped, err := os.ReadFile("grafana-dashboard.json")
+	if err != nil || string(shipped) != string(dashboard) {
+		t.Errorf("grafana-dashboard.json is out of date, error %v", err)
+	}
+	var decoded struct {
+		Panels []struct {
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(dashboard, &decoded); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	definitions := MetricDefinitions()
+	if len(decoded.Panels) != len(definitions) {
+		t.Fatalf("got %v panels want one for each of %v series", len(decoded.Panels), len(definitions))
+	}
+	for i, metric := range definitions {
+		for _, target := range decoded.Panels[i].Targets {
+			if !strings.Contains(target.Expr, metric.Name) || !strings.Contains(target.Expr, `chain=~"$chain"`) {
+				t.Errorf("got query %v for %v", target.Expr, metric.Name)
+			}
+		}
+	}
+}
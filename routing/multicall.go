@@ -0,0 +1,134 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// aggregate3 of Multicall3, deployed at the same address on most chains
+const multicall3ABI = `[{"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"}]`
+
+// defaultMulticallBatchSize keeps a single eth_call well below common node gas caps
+const defaultMulticallBatchSize = 500
+
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// BatchPoolReservesProvider reads the reserves of many pairs in as few calls as possible.
+// reserves0[i] and reserves1[i] belong to pairAddresses[i], both nil when the pair was not deployed.
+type BatchPoolReservesProvider interface {
+	PoolReservesProvider
+	GetPoolReservesBatch(ctx context.Context, pairAddresses []common.Address) ([]*big.Int, []*big.Int, error)
+}
+
+// MulticallPoolReservesProvider aggregates getReserves calls through Multicall3
+type MulticallPoolReservesProvider struct {
+	caller bind.ContractCaller
+	// calls per aggregate3, defaults to defaultMulticallBatchSize
+	batchSize int
+}
+
+func NewMulticallPoolReservesProvider(caller bind.ContractCaller) *MulticallPoolReservesProvider {
+	return &MulticallPoolReservesProvider{caller: caller}
+}
+
+func (p *MulticallPoolReservesProvider) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	reserves0, reserves1, err := p.GetPoolReservesBatch(ctx, []common.Address{pairAddress})
+	if err != nil {
+		return nil, nil, err
+	}
+	if reserves0[0] == nil {
+		return nil, nil, ErrPairNotDeployed
+	}
+	return reserves0[0], reserves1[0], nil
+}
+
+func (p *MulticallPoolReservesProvider) GetPoolReservesBatch(ctx context.Context, pairAddresses []common.Address) ([]*big.Int, []*big.Int, error) {
+	pair, err := abi.JSON(strings.NewReader(MainABI))
+	if err != nil {
+		return nil, nil, err
+	}
+	getReserves, err := pair.Pack("getReserves")
+	if err != nil {
+		return nil, nil, err
+	}
+	batchSize := p.batchSize
+	if batchSize <= 0 {
+		batchSize = defaultMulticallBatchSize
+	}
+	reserves0 := make([]*big.Int, 0, len(pairAddresses))
+	reserves1 := make([]*big.Int, 0, len(pairAddresses))
+	for start := 0; start < len(pairAddresses); start += batchSize {
+		end := start + batchSize
+		if end > len(pairAddresses) {
+			end = len(pairAddresses)
+		}
+		calls := make([]multicall3Call, 0, end-start)
+		for _, pairAddress := range pairAddresses[start:end] {
+			calls = append(calls, multicall3Call{Target: pairAddress, AllowFailure: true, CallData: getReserves})
+		}
+		results, err := aggregate3(ctx, p.caller, calls)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading reserves of %v pairs through multicall: %w", len(calls), err)
+		}
+		for i, result := range results {
+			if !result.Success {
+				return nil, nil, fmt.Errorf("getReserves failed for pair %v", calls[i].Target.String())
+			}
+			// calling an address without code succeeds with no data
+			if len(result.ReturnData) == 0 {
+				reserves0 = append(reserves0, nil)
+				reserves1 = append(reserves1, nil)
+				continue
+			}
+			reserves, err := pair.Unpack("getReserves", result.ReturnData)
+			if err != nil {
+				return nil, nil, fmt.Errorf("decoding reserves of pair %v: %w", calls[i].Target.String(), err)
+			}
+			reserves0 = append(reserves0, reserves[0].(*big.Int))
+			reserves1 = append(reserves1, reserves[1].(*big.Int))
+		}
+	}
+	return reserves0, reserves1, nil
+}
+
+// aggregate3 sends calls in a single Multicall3 aggregate3 eth_call at the block of ctx
+func aggregate3(ctx context.Context, caller bind.ContractCaller, calls []multicall3Call) ([]multicall3Result, error) {
+	multicall, err := abi.JSON(strings.NewReader(multicall3ABI))
+	if err != nil {
+		return nil, err
+	}
+	input, err := multicall.Pack("aggregate3", calls)
+	if err != nil {
+		return nil, err
+	}
+	multicallAddress := common.HexToAddress(MULTICALL3_ADDRESS)
+	output, err := caller.CallContract(ctx, ethereum.CallMsg{To: &multicallAddress, Data: input}, BlockNumberFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	unpacked, err := multicall.Unpack("aggregate3", output)
+	if err != nil {
+		return nil, err
+	}
+	results := *abi.ConvertType(unpacked[0], new([]multicall3Result)).(*[]multicall3Result)
+	if len(results) != len(calls) {
+		return nil, fmt.Errorf("multicall returned %v results for %v calls", len(results), len(calls))
+	}
+	return results, nil
+}
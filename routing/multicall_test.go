@@ -0,0 +1,123 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// multicallFake answers aggregate3 calls of getReserves from the fake graph
+type multicallFake struct {
+	graph      *v2GraphFake
+	calls      int
+	seenBlocks []*big.Int
+	// answer calls to unknown pairs like an address without code instead of failing
+	unknownHasNoCode bool
+}
+
+func (m *multicallFake) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (m *multicallFake) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	m.calls++
+	m.seenBlocks = append(m.seenBlocks, blockNumber)
+	if *call.To != common.HexToAddress(MULTICALL3_ADDRESS) {
+		return nil, fmt.Errorf("unexpected call to %v", call.To)
+	}
+	multicall, _ := abi.JSON(strings.NewReader(multicall3ABI))
+	pair, _ := abi.JSON(strings.NewReader(MainABI))
+	method := multicall.Methods["aggregate3"]
+	inputs, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	calls := *abi.ConvertType(inputs[0], new([]multicall3Call)).(*[]multicall3Call)
+	results := make([]multicall3Result, len(calls))
+	for i, c := range calls {
+		reserves, ok := m.graph.reserves[c.Target]
+		if !ok {
+			// calling an address without code succeeds with no data
+			if m.unknownHasNoCode {
+				results[i] = multicall3Result{Success: true}
+			}
+			continue
+		}
+		reserve0, reserve1 := reserves[0], reserves[1]
+		returnData, err := pair.Methods["getReserves"].Outputs.Pack(reserve0, reserve1, uint32(0))
+		if err != nil {
+			return nil, err
+		}
+		results[i] = multicall3Result{Success: true, ReturnData: returnData}
+	}
+	return method.Outputs.Pack(results)
+}
+
+func TestMulticallPoolReservesProvider(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1100000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 100000, 150000000)
+	caller := &multicallFake{graph: graph}
+	provider := &MulticallPoolReservesProvider{caller: caller, batchSize: 2}
+
+	pairs := []common.Address{
+		fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC)),
+		fakePairAddress(common.HexToAddress(USDC), common.HexToAddress(DAI)),
+		fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(DAI)),
+	}
+	ctx := WithBlockNumber(context.Background(), big.NewInt(16000000))
+	reserves0, reserves1, err := provider.GetPoolReservesBatch(ctx, pairs)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	for i, pair := range pairs {
+		want0, want1, _ := graph.GetPoolReserves(ctx, pair)
+		if reserves0[i].Cmp(want0) != 0 || reserves1[i].Cmp(want1) != 0 {
+			t.Errorf("pair %v: got %v/%v want %v/%v", pair, reserves0[i], reserves1[i], want0, want1)
+		}
+	}
+	if caller.calls != 2 {
+		t.Errorf("got %d calls want 2 batches", caller.calls)
+	}
+	for _, block := range caller.seenBlocks {
+		if block == nil || block.Uint64() != 16000000 {
+			t.Errorf("got call at block %v want 16000000", block)
+		}
+	}
+
+	if _, _, err := provider.GetPoolReservesBatch(ctx, []common.Address{common.HexToAddress(WISE)}); err == nil {
+		t.Errorf("expected an error for a failed getReserves")
+	}
+}
+
+func TestRouteReadsReservesInOneMulticall(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1100000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 100000, 150000000)
+	caller := &multicallFake{graph: graph}
+	router := newFakeRouter(graph)
+	router.poolReservesProvider = &MulticallPoolReservesProvider{caller: caller}
+
+	got, err := router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	want, err := newFakeRouter(graph).Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if got.AmountOut.Cmp(want.AmountOut) != 0 || got.HopCount() != want.HopCount() {
+		t.Errorf("got %v via %v want %v via %v", got.AmountOut, got.Path(), want.AmountOut, want.Path())
+	}
+	if caller.calls != 1 {
+		t.Errorf("got %d calls want 1", caller.calls)
+	}
+}
@@ -0,0 +1,146 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// NoRouteReason is a machine-readable explanation of why routing failed
+type NoRouteReason string
+
+const (
+	// no pair was ever created between tokenIn and any token in the graph
+	NoPoolsForTokenIn NoRouteReason = "no_pools_for_token_in"
+	// no pair was ever created between tokenOut and any token in the graph
+	NoPoolsForTokenOut NoRouteReason = "no_pools_for_token_out"
+	// pairs exist but are empty or too shallow for the requested amount
+	InsufficientLiquidity NoRouteReason = "insufficient_liquidity"
+	// tokenOut is reachable, but only with more hops than allowed
+	MaxHopsTooSmall NoRouteReason = "max_hops_too_small"
+	// tokenIn and tokenOut both have liquidity but are not connected through the graph
+	TokensNotConnected NoRouteReason = "tokens_not_connected"
+	// routes exist, but all those tried lose more to price impact than V2RouterConfig.MaxPriceImpact
+	PriceImpactCapExceeded NoRouteReason = "price_impact_cap_exceeded"
+	// the PoolsProvider dropped pools of tokenIn or tokenOut for holding less than its minimum
+	// liquidity, and the pools left do not connect them
+	PoolsBelowLiquidityThreshold NoRouteReason = "pools_below_liquidity_threshold"
+)
+
+// thinPoolsReporter is implemented by PoolsProviders that drop pools below a liquidity threshold
+type thinPoolsReporter interface {
+	PoolsBelowLiquidityThreshold() []Pool
+}
+
+// NoRouteError is returned by the router when no path connects tokenIn to tokenOut
+type NoRouteError struct {
+	TokenIn  common.Address
+	TokenOut common.Address
+	MaxHops  int
+	Reasons  []NoRouteReason
+}
+
+func (e *NoRouteError) Error() string {
+	reasons := make([]string, len(e.Reasons))
+	for i, reason := range e.Reasons {
+		reasons[i] = string(reason)
+	}
+	return fmt.Sprintf("no route from %v to %v within %v hops: %v", e.TokenIn.String(), e.TokenOut.String(), e.MaxHops, strings.Join(reasons, ", "))
+}
+
+// Is matches ErrNoRoute, and ErrInsufficientLiquidity when the pools were too shallow
+func (e *NoRouteError) Is(target error) bool {
+	if target == ErrNoRoute {
+		return true
+	}
+	if target == ErrInsufficientLiquidity {
+		for _, reason := range e.Reasons {
+			if reason == InsufficientLiquidity {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// diagnoseNoRoute inspects the pairs known to the router to explain a failed search
+func diagnoseNoRoute(graph *routeGraph, maxHops int) []NoRouteReason {
+	tokens, tokenInIndex, tokenOutIndex := graph.tokens, graph.tokenInIndex, graph.tokenOutIndex
+	pairExists := func(i, j int) bool {
+		_, ok := graph.reserves[newPairKey(tokens[i], tokens[j])]
+		return ok
+	}
+	hasLiquidity := func(i, j int) bool {
+		for _, hop := range graph.reserves[newPairKey(tokens[i], tokens[j])] {
+			if hop.reserveIn.Sign() > 0 && hop.reserveOut.Sign() > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	hasThinPools := func(token common.Address) bool {
+		for _, pool := range graph.thin {
+			if pool.Token0 == token || pool.Token1 == token {
+				return true
+			}
+		}
+		return false
+	}
+	tokenPools := func(token int) (bool, bool) {
+		hasPair, liquid := false, false
+		for other := range tokens {
+			if other == token {
+				continue
+			}
+			hasPair = hasPair || pairExists(token, other)
+			liquid = liquid || hasLiquidity(token, other)
+		}
+		return hasPair, liquid
+	}
+
+	reasons := []NoRouteReason{}
+	inHasPair, inLiquid := tokenPools(tokenInIndex)
+	outHasPair, outLiquid := tokenPools(tokenOutIndex)
+	if !inHasPair {
+		reasons = append(reasons, NoPoolsForTokenIn)
+	}
+	if !outHasPair {
+		reasons = append(reasons, NoPoolsForTokenOut)
+	}
+	if (inHasPair && !inLiquid) || (outHasPair && !outLiquid) {
+		reasons = append(reasons, InsufficientLiquidity)
+	}
+	if (!inLiquid && hasThinPools(tokens[tokenInIndex])) || (!outLiquid && hasThinPools(tokens[tokenOutIndex])) {
+		reasons = append(reasons, PoolsBelowLiquidityThreshold)
+	}
+	if len(reasons) > 0 {
+		return reasons
+	}
+
+	// breadth first search over liquid pairs to find the minimum number of hops needed
+	distance := map[int]int{tokenInIndex: 0}
+	queue := []int{tokenInIndex}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for next := range tokens {
+			if _, seen := distance[next]; seen || next == current || !hasLiquidity(current, next) {
+				continue
+			}
+			distance[next] = distance[current] + 1
+			queue = append(queue, next)
+		}
+	}
+	if hops, ok := distance[tokenOutIndex]; ok {
+		if hops > maxHops {
+			return []NoRouteReason{MaxHopsTooSmall}
+		}
+		// connected within maxHops, so the pools are too shallow for the requested amount
+		return []NoRouteReason{InsufficientLiquidity}
+	}
+	if hasThinPools(tokens[tokenInIndex]) || hasThinPools(tokens[tokenOutIndex]) {
+		return []NoRouteReason{TokensNotConnected, PoolsBelowLiquidityThreshold}
+	}
+	return []NoRouteReason{TokensNotConnected}
+}
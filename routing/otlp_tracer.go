@@ -0,0 +1,257 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultOTLPExportInterval is how often OTLPTracer.Run sends the finished spans
+const DefaultOTLPExportInterval = 5 * time.Second
+
+// spans held while the collector is unreachable, newer ones are dropped
+const maxBufferedSpans = 4096
+
+// OTLPTracer exports spans to an OpenTelemetry collector (or Jaeger, Tempo, ...) with OTLP over
+// HTTP in its JSON encoding. Finished spans are buffered until Flush, which Run calls every
+// DefaultOTLPExportInterval.
+type OTLPTracer struct {
+	// the collector's traces URL, e.g. http://localhost:4318/v1/traces
+	endpoint string
+	// service.name of the exported spans
+	service string
+	client  *http.Client
+
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+func NewOTLPTracer(endpoint, service string) *OTLPTracer {
+	return &OTLPTracer{endpoint: endpoint, service: service, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type otlpSpanKey struct{}
+
+func (t *OTLPTracer) Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span) {
+	span := &tracedSpan{tracer: t, name: name, spanID: randomHex(8), start: time.Now(), attributes: attributes}
+	if parent, ok := ctx.Value(otlpSpanKey{}).(*tracedSpan); ok {
+		span.traceID, span.parentID = parent.traceID, parent.spanID
+	} else {
+		span.traceID = randomHex(16)
+	}
+	return context.WithValue(ctx, otlpSpanKey{}, span), span
+}
+
+// Flush sends every finished span to the collector, the spans are dropped when it fails
+func (t *OTLPTracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes([]Attribute{{Key: "service.name", Value: t.service}})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "v2Routing/routing"}, Spans: spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting %v spans: %w", len(spans), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("exporting %v spans: collector answered %v", len(spans), resp.Status)
+	}
+	return nil
+}
+
+// Run flushes every DefaultOTLPExportInterval until ctx is done or an export fails, with a last
+// flush when ctx is done
+func (t *OTLPTracer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(DefaultOTLPExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			t.Flush(flushCtx)
+			return ctx.Err()
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (t *OTLPTracer) finish(span otlpSpan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.spans) < maxBufferedSpans {
+		t.spans = append(t.spans, span)
+	}
+}
+
+// tracedSpan is a span of OTLPTracer, exported once it ends
+type tracedSpan struct {
+	tracer                    *OTLPTracer
+	name                      string
+	traceID, spanID, parentID string
+	start                     time.Time
+
+	mu         sync.Mutex
+	attributes []Attribute
+	err        error
+	ended      bool
+}
+
+func (s *tracedSpan) SetAttributes(attributes ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, attributes...)
+}
+
+func (s *tracedSpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *tracedSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+	span := otlpSpan{
+		TraceID:      s.traceID,
+		SpanID:       s.spanID,
+		ParentSpanID: s.parentID,
+		Name:         s.name,
+		Kind:         otlpSpanKindInternal,
+		Start:        strconv.FormatInt(s.start.UnixNano(), 10),
+		End:          strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes:   otlpAttributes(s.attributes),
+	}
+	if s.err != nil {
+		span.Status = &otlpStatus{Code: otlpStatusError, Message: s.err.Error()}
+	}
+	s.tracer.finish(span)
+}
+
+func randomHex(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// the JSON encoding of an OTLP ExportTraceServiceRequest, IDs are hex and 64-bit integers strings
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusError      = 2
+)
+
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	String *string  `json:"stringValue,omitempty"`
+	Int    *string  `json:"intValue,omitempty"`
+	Double *float64 `json:"doubleValue,omitempty"`
+	Bool   *bool    `json:"boolValue,omitempty"`
+}
+
+func otlpAttributes(attributes []Attribute) []otlpAttribute {
+	converted := make([]otlpAttribute, 0, len(attributes))
+	for _, attribute := range attributes {
+		var value otlpValue
+		integer := func(i int64) { s := strconv.FormatInt(i, 10); value.Int = &s }
+		switch v := attribute.Value.(type) {
+		case string:
+			value.String = &v
+		case int:
+			integer(int64(v))
+		case int64:
+			integer(v)
+		case uint64:
+			if v > math.MaxInt64 {
+				s := strconv.FormatUint(v, 10)
+				value.String = &s
+			} else {
+				integer(int64(v))
+			}
+		case float64:
+			value.Double = &v
+		case bool:
+			value.Bool = &v
+		default:
+			s := fmt.Sprint(v)
+			value.String = &s
+		}
+		converted = append(converted, otlpAttribute{Key: attribute.Key, Value: value})
+	}
+	return converted
+}
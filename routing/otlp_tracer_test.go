@@ -0,0 +1,114 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// otlpCollectorFake keeps the spans of every export it receives
+type otlpCollectorFake struct {
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+func (f *otlpCollectorFake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request otlpRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || r.URL.Path != "/v1/traces" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, resource := range request.ResourceSpans {
+		for _, scope := range resource.ScopeSpans {
+			f.spans = append(f.spans, scope.Spans...)
+		}
+	}
+}
+
+func (f *otlpCollectorFake) byName() map[string]otlpSpan {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	spans := make(map[string]otlpSpan)
+	for _, span := range f.spans {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func spanAttribute(span otlpSpan, key string) string {
+	for _, attribute := range span.Attributes {
+		if attribute.Key == key {
+			switch {
+			case attribute.Value.String != nil:
+				return *attribute.Value.String
+			case attribute.Value.Int != nil:
+				return *attribute.Value.Int
+			}
+		}
+	}
+	return ""
+}
+
+func TestOTLPTracer(t *testing.T) {
+	collector := &otlpCollectorFake{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	tracer := NewOTLPTracer(server.URL+"/v1/traces", "router")
+
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1000000000)
+	router := newFakeRouter(graph)
+	router.tracer = tracer
+	ctx := context.Background()
+	if _, err := router.Route(ctx, big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 2); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	endpoint := &rpcEndpointFake{block: 100}
+	rpcServer := httptest.NewServer(endpoint)
+	defer rpcServer.Close()
+	client, err := DialEthClient(rpcServer.URL)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	client.BlockNumber(WithTracer(ctx, tracer))
+	if err := tracer.Flush(ctx); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	spans := collector.byName()
+	route, ok := spans["route"]
+	if !ok || spanAttribute(route, "routing.hops") != "2" || spanAttribute(route, "routing.token_in") != common.HexToAddress(WETH).Hex() || route.ParentSpanID != "" {
+		t.Fatalf("got spans %+v want a root route span over 2 hops", spans)
+	}
+	for _, name := range []string{"pool discovery", "reserve fetching"} {
+		if span, ok := spans[name]; !ok || span.TraceID != route.TraceID || span.ParentSpanID != route.SpanID {
+			t.Errorf("got %v span %+v want a child of the route span", name, span)
+		}
+	}
+	if spanAttribute(spans["pool discovery"], "routing.pools") != "2" {
+		t.Errorf("got pool discovery span %+v want 2 pools", spans["pool discovery"])
+	}
+	if span, ok := spans["rpc eth_blockNumber"]; !ok || spanAttribute(span, "server.address") != strings.TrimPrefix(rpcServer.URL, "http://") || span.TraceID == route.TraceID {
+		t.Errorf("got spans %+v want an RPC span in its own trace", spans)
+	}
+
+	// failed routes are marked, routes the sampler skips record nothing
+	collector.spans = nil
+	router.Route(ctx, big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(WETH), 2)
+	router.traceSampler = RatioSampler(0)
+	router.Route(ctx, big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 2)
+	tracer.Flush(ctx)
+	if len(collector.spans) != 1 || collector.spans[0].Status == nil || collector.spans[0].Status.Code != otlpStatusError {
+		t.Errorf("got spans %+v want one failed route span", collector.spans)
+	}
+}
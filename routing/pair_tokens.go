@@ -0,0 +1,89 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"v2Routing/v2math"
+)
+
+// PairTokensProvider returns the tokens of a pair in the order its reserves are reported
+type PairTokensProvider interface {
+	GetPairTokens(ctx context.Context, pairAddress common.Address) (common.Address, common.Address, error)
+}
+
+// OnChainPairTokensProvider reads token0()/token1() from the pair, the answer never changes so it is cached
+type OnChainPairTokensProvider struct {
+	rpcClient *ethclient.Client
+	mu        sync.RWMutex
+	cache     map[common.Address][2]common.Address
+	counters  cacheCounters
+}
+
+func NewOnChainPairTokensProvider(rpcClient *ethclient.Client) *OnChainPairTokensProvider {
+	return &OnChainPairTokensProvider{rpcClient: rpcClient}
+}
+
+func (p *OnChainPairTokensProvider) GetPairTokens(ctx context.Context, pairAddress common.Address) (common.Address, common.Address, error) {
+	p.mu.RLock()
+	tokens, ok := p.cache[pairAddress]
+	p.mu.RUnlock()
+	p.counters.count(ok)
+	if ok {
+		return tokens[0], tokens[1], nil
+	}
+	caller, err := NewMainCaller(pairAddress, p.rpcClient)
+	if err != nil {
+		return common.Address{}, common.Address{}, fmt.Errorf("binding pair %v: %w", pairAddress.String(), err)
+	}
+	callOpts := newCallOpts(ctx)
+	token0, err := caller.Token0(callOpts)
+	if err != nil {
+		return common.Address{}, common.Address{}, fmt.Errorf("reading token0 of pair %v: %w", pairAddress.String(), err)
+	}
+	token1, err := caller.Token1(callOpts)
+	if err != nil {
+		return common.Address{}, common.Address{}, fmt.Errorf("reading token1 of pair %v: %w", pairAddress.String(), err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cache == nil {
+		p.cache = make(map[common.Address][2]common.Address)
+	}
+	p.cache[pairAddress] = [2]common.Address{token0, token1}
+	return token0, token1, nil
+}
+
+func (p *OnChainPairTokensProvider) CacheSummary() CacheSummary {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.counters.summary(len(p.cache), "")
+}
+
+// orientReserves returns the reserves of pairAddress as (reserve of tokenA, reserve of tokenB).
+// Without a PairTokensProvider the V2 factory convention of token0 < token1 is assumed.
+func orientReserves(ctx context.Context, pairTokensProvider PairTokensProvider, pairAddress, tokenA, tokenB common.Address, reserve0, reserve1 *big.Int) (*big.Int, *big.Int, error) {
+	if pairTokensProvider == nil {
+		if token0, _ := v2math.SortTokens(tokenA, tokenB); token0 == tokenA {
+			return reserve0, reserve1, nil
+		}
+		return reserve1, reserve0, nil
+	}
+	token0, token1, err := pairTokensProvider.GetPairTokens(ctx, pairAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case token0 == tokenA && token1 == tokenB:
+		return reserve0, reserve1, nil
+	case token0 == tokenB && token1 == tokenA:
+		return reserve1, reserve0, nil
+	default:
+		return nil, nil, fmt.Errorf("pair %v holds %v/%v, not %v/%v", pairAddress.String(), token0.String(), token1.String(), tokenA.String(), tokenB.String())
+	}
+}
@@ -0,0 +1,220 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// allowance of Uniswap's Permit2, the nonce of the next signed permit comes with it
+const permit2ABI = `[{"inputs":[{"name":"owner","type":"address"},{"name":"token","type":"address"},{"name":"spender","type":"address"}],"name":"allowance","outputs":[{"name":"amount","type":"uint160"},{"name":"expiration","type":"uint48"},{"name":"nonce","type":"uint48"}],"stateMutability":"view","type":"function"}]`
+
+var (
+	permit2Parsed         = mustParseABI(permit2ABI)
+	ErrPermit2NotApproved = errors.New("Permit2 has no allowance for the token, approve it once with an ApprovalManager for PERMIT2_ADDRESS, or relay a signed EIP-2612 permit (SignPermit) for tokens supporting one")
+
+	eip2612PermitTypeHash  = crypto.Keccak256Hash([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+	permit2DomainTypeHash  = crypto.Keccak256Hash([]byte("EIP712Domain(string name,uint256 chainId,address verifyingContract)"))
+	permit2DetailsTypeHash = crypto.Keccak256Hash([]byte("PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)"))
+	permit2SingleTypeHash  = crypto.Keccak256Hash([]byte("PermitSingle(PermitDetails details,address spender,uint256 sigDeadline)PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)"))
+	maxUint160             = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+)
+
+// SupportsPermit reports whether token implements EIP-2612 permit, probing DOMAIN_SEPARATOR and
+// nonces. Tokens whose PERMIT_TYPEHASH is not EIP-2612's, like DAI's older permit, do not count.
+func SupportsPermit(ctx context.Context, caller bind.ContractCaller, token, owner common.Address) (bool, error) {
+	code, err := caller.CodeAt(ctx, token, BlockNumberFromContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	if len(code) == 0 {
+		return false, nil
+	}
+	erc20, err := NewMainCaller(token, caller)
+	if err != nil {
+		return false, err
+	}
+	opts := newCallOpts(ctx)
+	if _, err := erc20.DOMAINSEPARATOR(opts); err != nil {
+		return false, nil
+	}
+	if _, err := erc20.Nonces(opts, owner); err != nil {
+		return false, nil
+	}
+	// PERMIT_TYPEHASH is optional
+	if typeHash, err := erc20.PERMITTYPEHASH(opts); err == nil && common.Hash(typeHash) != eip2612PermitTypeHash {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Permit is a signed EIP-2612 approval, anyone can submit it with Call
+type Permit struct {
+	Token     common.Address
+	Owner     common.Address
+	Spender   common.Address
+	Value     *big.Int
+	Nonce     *big.Int
+	Deadline  time.Time
+	Signature []byte
+}
+
+// SignPermit signs an EIP-2612 permit letting spender move value of the signer's token until
+// deadline, with the token's current domain separator and nonce
+func SignPermit(ctx context.Context, caller bind.ContractCaller, signer *Signer, token, spender common.Address, value *big.Int, deadline time.Time) (*Permit, error) {
+	erc20, err := NewMainCaller(token, caller)
+	if err != nil {
+		return nil, err
+	}
+	opts := newCallOpts(ctx)
+	domainSeparator, err := erc20.DOMAINSEPARATOR(opts)
+	if err != nil {
+		return nil, fmt.Errorf("reading DOMAIN_SEPARATOR of %v: %w", token.String(), err)
+	}
+	nonce, err := erc20.Nonces(opts, signer.Address())
+	if err != nil {
+		return nil, fmt.Errorf("reading permit nonce of %v: %w", token.String(), err)
+	}
+	permit := &Permit{Token: token, Owner: signer.Address(), Spender: spender, Value: value, Nonce: nonce, Deadline: deadline}
+	structHash := crypto.Keccak256Hash(
+		eip2612PermitTypeHash.Bytes(),
+		common.LeftPadBytes(permit.Owner.Bytes(), 32),
+		common.LeftPadBytes(spender.Bytes(), 32),
+		abiWord(value),
+		abiWord(nonce),
+		abiWord(big.NewInt(deadline.Unix())),
+	)
+	permit.Signature, err = signer.SignHash(typedDataHash(domainSeparator, structHash))
+	if err != nil {
+		return nil, err
+	}
+	return permit, nil
+}
+
+// Call is the token's permit call, e.g. for a relayer to submit on the owner's behalf
+func (p *Permit) Call() (*SwapTx, error) {
+	var r, s [32]byte
+	copy(r[:], p.Signature[:32])
+	copy(s[:], p.Signature[32:64])
+	data, err := erc20Parsed.Pack("permit", p.Owner, p.Spender, p.Value, big.NewInt(p.Deadline.Unix()), p.Signature[64], r, s)
+	if err != nil {
+		return nil, err
+	}
+	return &SwapTx{To: p.Token, Data: data, Value: new(big.Int), Method: "permit"}, nil
+}
+
+// Permit2Permit is a signed Permit2 PermitSingle, letting Spender pull Amount of Token through
+// Permit2 until Expiration
+type Permit2Permit struct {
+	Token       common.Address
+	Amount      *big.Int
+	Expiration  time.Time
+	Nonce       *big.Int
+	Spender     common.Address
+	SigDeadline time.Time
+	Signature   []byte
+}
+
+// SignPermit2 signs a Permit2 PermitSingle for spender, usually UNIVERSAL_ROUTER_ADDRESS, with
+// the signer's next Permit2 nonce for the token. The signature and allowance expire at deadline.
+func SignPermit2(ctx context.Context, caller bind.ContractCaller, signer *Signer, chainID *big.Int, token, spender common.Address, amount *big.Int, deadline time.Time) (*Permit2Permit, error) {
+	if amount.Sign() < 0 || amount.Cmp(maxUint160) > 0 {
+		return nil, fmt.Errorf("permit2 amounts are uint160, %v is out of range", amount)
+	}
+	permit2 := common.HexToAddress(PERMIT2_ADDRESS)
+	var allowance struct {
+		Amount     *big.Int
+		Expiration *big.Int
+		Nonce      *big.Int
+	}
+	out := []interface{}{&allowance}
+	if err := bind.NewBoundContract(permit2, permit2Parsed, caller, nil, nil).Call(newCallOpts(ctx), &out, "allowance", signer.Address(), token, spender); err != nil {
+		return nil, fmt.Errorf("reading Permit2 nonce of %v: %w", token.String(), err)
+	}
+	permit := &Permit2Permit{
+		Token:       token,
+		Amount:      amount,
+		Expiration:  deadline,
+		Nonce:       allowance.Nonce,
+		Spender:     spender,
+		SigDeadline: deadline,
+	}
+	domainSeparator := crypto.Keccak256Hash(
+		permit2DomainTypeHash.Bytes(),
+		crypto.Keccak256([]byte("Permit2")),
+		abiWord(chainID),
+		common.LeftPadBytes(permit2.Bytes(), 32),
+	)
+	detailsHash := crypto.Keccak256(
+		permit2DetailsTypeHash.Bytes(),
+		common.LeftPadBytes(token.Bytes(), 32),
+		abiWord(amount),
+		abiWord(big.NewInt(deadline.Unix())),
+		abiWord(permit.Nonce),
+	)
+	structHash := crypto.Keccak256Hash(
+		permit2SingleTypeHash.Bytes(),
+		detailsHash,
+		common.LeftPadBytes(spender.Bytes(), 32),
+		abiWord(big.NewInt(deadline.Unix())),
+	)
+	var err error
+	permit.Signature, err = signer.SignHash(typedDataHash(domainSeparator, structHash))
+	if err != nil {
+		return nil, err
+	}
+	return permit, nil
+}
+
+// permit2PermitArguments are the input of the Universal Router's PERMIT2_PERMIT command:
+// (PermitSingle permitSingle, bytes signature)
+func permit2PermitArguments() abi.Arguments {
+	single, _ := abi.NewType("tuple", "", []abi.ArgumentMarshaling{
+		{Name: "details", Type: "tuple", Components: []abi.ArgumentMarshaling{
+			{Name: "token", Type: "address"},
+			{Name: "amount", Type: "uint160"},
+			{Name: "expiration", Type: "uint48"},
+			{Name: "nonce", Type: "uint48"},
+		}},
+		{Name: "spender", Type: "address"},
+		{Name: "sigDeadline", Type: "uint256"},
+	})
+	signature, _ := abi.NewType("bytes", "", nil)
+	return abi.Arguments{{Type: single}, {Type: signature}}
+}
+
+func (p *Permit2Permit) encode() ([]byte, error) {
+	type permitDetails struct {
+		Token      common.Address
+		Amount     *big.Int
+		Expiration *big.Int
+		Nonce      *big.Int
+	}
+	type permitSingle struct {
+		Details     permitDetails
+		Spender     common.Address
+		SigDeadline *big.Int
+	}
+	return permit2PermitArguments().Pack(permitSingle{
+		Details:     permitDetails{Token: p.Token, Amount: p.Amount, Expiration: big.NewInt(p.Expiration.Unix()), Nonce: p.Nonce},
+		Spender:     p.Spender,
+		SigDeadline: big.NewInt(p.SigDeadline.Unix()),
+	}, p.Signature)
+}
+
+// typedDataHash is the EIP-712 digest of a struct in a domain
+func typedDataHash(domainSeparator [32]byte, structHash common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domainSeparator[:], structHash.Bytes())
+}
+
+// abiWord is n as a 32 byte ABI word
+func abiWord(n *big.Int) []byte {
+	return common.LeftPadBytes(n.Bytes(), 32)
+}
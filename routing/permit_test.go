@@ -0,0 +1,247 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// permitTokenFake is an EIP-2612 token next to Permit2. Tokens in typeHashes answer
+// PERMIT_TYPEHASH with their value, tokens missing from domains do not support permits.
+type permitTokenFake struct {
+	domains    map[common.Address][32]byte
+	typeHashes map[common.Address]common.Hash
+	nonces     map[common.Address]*big.Int
+	// ERC20 allowances to Permit2
+	allowances map[common.Address]*big.Int
+}
+
+func (f *permitTokenFake) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if contract == (common.Address{}) {
+		return nil, nil
+	}
+	return []byte{1}, nil
+}
+
+func (f *permitTokenFake) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	token := *call.To
+	reverted := errors.New("execution reverted")
+	if token == common.HexToAddress(PERMIT2_ADDRESS) {
+		method := permit2Parsed.Methods["allowance"]
+		return method.Outputs.Pack(new(big.Int), new(big.Int), big.NewInt(5))
+	}
+	method, err := erc20Parsed.MethodById(call.Data[:4])
+	if err != nil {
+		return nil, reverted
+	}
+	domain, ok := f.domains[token]
+	switch method.Name {
+	case "DOMAIN_SEPARATOR":
+		if !ok {
+			return nil, reverted
+		}
+		return method.Outputs.Pack(domain)
+	case "nonces":
+		if !ok {
+			return nil, reverted
+		}
+		args, _ := method.Inputs.Unpack(call.Data[4:])
+		nonce, ok := f.nonces[args[0].(common.Address)]
+		if !ok {
+			nonce = new(big.Int)
+		}
+		return method.Outputs.Pack(nonce)
+	case "PERMIT_TYPEHASH":
+		typeHash, ok := f.typeHashes[token]
+		if !ok {
+			return nil, reverted
+		}
+		return method.Outputs.Pack([32]byte(typeHash))
+	case "allowance":
+		allowance, ok := f.allowances[token]
+		if !ok {
+			allowance = new(big.Int)
+		}
+		return method.Outputs.Pack(allowance)
+	}
+	return nil, reverted
+}
+
+// recoverTypedData recovers the signer of typed data hashed by go-ethereum's EIP-712 implementation
+func recoverTypedData(t *testing.T, typedData apitypes.TypedData, signature []byte) common.Address {
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	sig := append([]byte{}, signature...)
+	sig[crypto.RecoveryIDOffset] -= 27
+	publicKey, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	return crypto.PubkeyToAddress(*publicKey)
+}
+
+func TestSignPermit(t *testing.T) {
+	uni, dai, weth := common.HexToAddress(UNI), common.HexToAddress(DAI), common.HexToAddress(WETH)
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	types := apitypes.Types{
+		"EIP712Domain": {{Name: "name", Type: "string"}, {Name: "version", Type: "string"}, {Name: "chainId", Type: "uint256"}, {Name: "verifyingContract", Type: "address"}},
+		"Permit":       {{Name: "owner", Type: "address"}, {Name: "spender", Type: "address"}, {Name: "value", Type: "uint256"}, {Name: "nonce", Type: "uint256"}, {Name: "deadline", Type: "uint256"}},
+	}
+	domain := apitypes.TypedDataDomain{Name: "Uniswap", Version: "1", ChainId: math.NewHexOrDecimal256(1), VerifyingContract: uni.Hex()}
+	domainSeparator, err := (&apitypes.TypedData{Types: types, Domain: domain}).HashStruct("EIP712Domain", domain.Map())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	tokens := &permitTokenFake{
+		domains:    map[common.Address][32]byte{uni: common.BytesToHash(domainSeparator), dai: {1}},
+		typeHashes: map[common.Address]common.Hash{dai: crypto.Keccak256Hash([]byte("Permit(address holder,address spender,uint256 nonce,uint256 expiry,bool allowed)"))},
+		nonces:     map[common.Address]*big.Int{signer.Address(): big.NewInt(2)},
+	}
+
+	for token, want := range map[common.Address]bool{uni: true, dai: false, weth: false, {}: false} {
+		if got, err := SupportsPermit(context.Background(), tokens, token, signer.Address()); err != nil || got != want {
+			t.Errorf("%v: got %v error %v want %v", token, got, err, want)
+		}
+	}
+
+	spender, deadline := common.HexToAddress(PERMIT2_ADDRESS), time.Unix(1700000000, 0)
+	permit, err := SignPermit(context.Background(), tokens, signer, uni, spender, math.MaxBig256, deadline)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	message := apitypes.TypedDataMessage{
+		"owner":    signer.Address().Hex(),
+		"spender":  spender.Hex(),
+		"value":    math.MaxBig256.String(),
+		"nonce":    "2",
+		"deadline": "1700000000",
+	}
+	if from := recoverTypedData(t, apitypes.TypedData{Types: types, PrimaryType: "Permit", Domain: domain, Message: message}, permit.Signature); from != signer.Address() {
+		t.Errorf("got permit signed by %v want %v", from, signer.Address())
+	}
+	call, err := permit.Call()
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	args, err := erc20Parsed.Methods["permit"].Inputs.Unpack(call.Data[4:])
+	if err != nil || call.To != uni || args[0].(common.Address) != signer.Address() || args[4].(uint8) != permit.Signature[64] {
+		t.Errorf("got permit call to %v args %v error %v", call.To, args, err)
+	}
+}
+
+func TestSignPermit2(t *testing.T) {
+	usdc := common.HexToAddress(USDC)
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	spender, deadline := common.HexToAddress(UNIVERSAL_ROUTER_ADDRESS), time.Unix(1700000000, 0)
+	permit, err := SignPermit2(context.Background(), &permitTokenFake{}, signer, big.NewInt(1), usdc, spender, big.NewInt(1000), deadline)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if permit.Nonce.Int64() != 5 {
+		t.Errorf("got nonce %v want Permit2's 5", permit.Nonce)
+	}
+	// go-ethereum's EIP-712 encoder has no uint160, the struct is hashed with the ABI encoder
+	// against the type hashes published in Permit2's source instead
+	if permit2DetailsTypeHash != common.HexToHash("0x65626cad6cb96493bf6f5ebea28756c966f023ab9e8a83a7101849d5573b3678") ||
+		permit2SingleTypeHash != common.HexToHash("0xf3841cd1ff0085026a6327b620b67997ce40f282c88a8e905a7a5626e310f3d0") {
+		t.Fatalf("got type hashes %v and %v want Permit2's", permit2DetailsTypeHash, permit2SingleTypeHash)
+	}
+	words := func(types []string, values ...interface{}) []byte {
+		arguments := abi.Arguments{}
+		for _, name := range types {
+			arguments = append(arguments, abi.Argument{Type: abiType(name)})
+		}
+		packed, err := arguments.Pack(values...)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		return packed
+	}
+	domain := apitypes.TypedDataDomain{Name: "Permit2", ChainId: math.NewHexOrDecimal256(1), VerifyingContract: PERMIT2_ADDRESS}
+	domainTypes := apitypes.Types{"EIP712Domain": {{Name: "name", Type: "string"}, {Name: "chainId", Type: "uint256"}, {Name: "verifyingContract", Type: "address"}}}
+	domainSeparator, err := (&apitypes.TypedData{Types: domainTypes, Domain: domain}).HashStruct("EIP712Domain", domain.Map())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	details := crypto.Keccak256Hash(words([]string{"bytes32", "address", "uint160", "uint48", "uint48"}, permit2DetailsTypeHash, usdc, big.NewInt(1000), big.NewInt(1700000000), big.NewInt(5)))
+	single := crypto.Keccak256Hash(words([]string{"bytes32", "bytes32", "address", "uint256"}, permit2SingleTypeHash, details, spender, big.NewInt(1700000000)))
+	digest := crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, single.Bytes())
+	sig := append([]byte{}, permit.Signature...)
+	sig[crypto.RecoveryIDOffset] -= 27
+	if publicKey, err := crypto.SigToPub(digest, sig); err != nil || crypto.PubkeyToAddress(*publicKey) != signer.Address() {
+		t.Errorf("got permit not signed by %v, error %v", signer.Address(), err)
+	}
+
+	encoded, err := permit.encode()
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	values, err := permit2PermitArguments().Unpack(encoded)
+	if err != nil || len(values) != 2 || string(values[1].([]byte)) != string(permit.Signature) {
+		t.Errorf("got %v error %v want the permit and its signature", values, err)
+	}
+	if _, err := SignPermit2(context.Background(), &permitTokenFake{}, signer, big.NewInt(1), usdc, spender, math.MaxBig256, deadline); err == nil {
+		t.Error("got no error for an amount above uint160")
+	}
+}
+
+func TestExecuteWithPermit2(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	quote, err := newFakeRouter(graph).Route(context.Background(), big.NewInt(2000000), usdc, weth, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	backend := &transactionBackendFake{}
+	executor := NewExecutor(backend, signer, NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil))
+	tokens := &permitTokenFake{allowances: map[common.Address]*big.Int{}}
+
+	if _, err := executor.ExecuteWithPermit2(context.Background(), tokens, quote, SwapOptions{Recipient: signer.Address()}); !errors.Is(err, ErrPermit2NotApproved) {
+		t.Fatalf("got error %v want ErrPermit2NotApproved", err)
+	}
+	tokens.allowances[usdc] = math.MaxBig256
+	hash, err := executor.ExecuteWithPermit2(context.Background(), tokens, quote, SwapOptions{Recipient: signer.Address()})
+	if err != nil || len(backend.sent) != 1 || backend.sent[0].Hash() != hash {
+		t.Fatalf("got hash %v error %v and %v transactions want the swap sent", hash, err, len(backend.sent))
+	}
+	tx := backend.sent[0]
+	args, _ := universalRouterParsed.Methods["execute"].Inputs.Unpack(tx.Data()[4:])
+	inputs := args[1].([][]byte)
+	values, err := permit2PermitArguments().Unpack(inputs[0])
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	permit := values[0].(struct {
+		Details struct {
+			Token      common.Address `json:"token"`
+			Amount     *big.Int       `json:"amount"`
+			Expiration *big.Int       `json:"expiration"`
+			Nonce      *big.Int       `json:"nonce"`
+		} `json:"details"`
+		Spender     common.Address `json:"spender"`
+		SigDeadline *big.Int       `json:"sigDeadline"`
+	})
+	if permit.Details.Token != usdc || permit.Details.Amount.Cmp(quote.AmountIn) != 0 || permit.Spender != common.HexToAddress(UNIVERSAL_ROUTER_ADDRESS) || permit.SigDeadline.Cmp(args[2].(*big.Int)) != 0 {
+		t.Errorf("got permit %+v want the swap's input for the Universal Router until its deadline", permit)
+	}
+	swaps, err := DecodeSwapCalldata(tx.Data(), tx.Value())
+	if err != nil || *tx.To() != common.HexToAddress(UNIVERSAL_ROUTER_ADDRESS) || swaps[0].AmountIn.Cmp(quote.AmountIn) != 0 || swaps[0].Recipient != signer.Address() {
+		t.Errorf("got tx to %v swaps %+v error %v", tx.To(), swaps, err)
+	}
+}
@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PoolInvalidator drops what is cached about pools, e.g. once a trade through them landed, so the
+// next quotes read their new reserves instead of waiting for a new block or Sync event.
+// OnChainV2Router, BlockReservesCache and SyncPoolReservesProvider are ones, QuoteServerInvalidator
+// asks a QuoteServer in another process.
+type PoolInvalidator interface {
+	InvalidatePools(ctx context.Context, pairs []common.Address) error
+}
+
+// invalidateProvider forwards an invalidation to the provider a cache reads through, when it caches
+// as well
+func invalidateProvider(ctx context.Context, provider interface{}, pairs []common.Address) error {
+	if invalidator, ok := provider.(PoolInvalidator); ok {
+		return invalidator.InvalidatePools(ctx, pairs)
+	}
+	return nil
+}
+
+// InvalidatePools drops the reserves of pairs from the caches of the router's reserves provider
+func (r *OnChainV2Router) InvalidatePools(ctx context.Context, pairs []common.Address) error {
+	logDebug(ctx, r.logger, "invalidating pools", "pairs", pairs)
+	return invalidateProvider(ctx, r.poolReservesProvider, pairs)
+}
+
+// InvalidateRequest is the body of POST /invalidate
+type InvalidateRequest struct {
+	Pools []common.Address `json:"pools"`
+}
+
+// QuoteServerInvalidator invalidates pools through the POST /invalidate endpoint of a QuoteServer
+type QuoteServerInvalidator struct {
+	url    string
+	client *http.Client
+}
+
+// NewQuoteServerInvalidator invalidates pools on the QuoteServer at baseURL, e.g.
+// https://quotes.example.com, client may be nil for http.DefaultClient
+func NewQuoteServerInvalidator(baseURL string, client *http.Client) *QuoteServerInvalidator {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &QuoteServerInvalidator{url: strings.TrimSuffix(baseURL, "/") + "/invalidate", client: client}
+}
+
+func (i *QuoteServerInvalidator) InvalidatePools(ctx context.Context, pairs []common.Address) error {
+	body, err := json.Marshal(InvalidateRequest{Pools: pairs})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := i.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("invalidating %v pools: %w", len(pairs), err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		var failure ErrorResponse
+		json.NewDecoder(response.Body).Decode(&failure)
+		return fmt.Errorf("invalidating %v pools: %v %v", len(pairs), response.Status, failure.Error)
+	}
+	return nil
+}
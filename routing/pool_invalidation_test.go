@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestInvalidatePools(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	pair := fakePairAddress(weth, usdc)
+	node := &countingReservesProvider{PoolReservesProvider: graph}
+	blockCache := NewBlockReservesCache(nil, node)
+	filterer := &logSubscriptionFake{subscribed: make(chan struct{})}
+	syncProvider, err := NewSyncPoolReservesProvider(filterer, blockCache)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go syncProvider.Run(ctx)
+	<-filterer.subscribed
+
+	router := newFakeRouter(graph)
+	router.poolReservesProvider = syncProvider
+	server := httptest.NewServer(NewQuoteServer(router, graph))
+	defer server.Close()
+
+	// both caches hold the pool before the trade
+	atBlock := WithBlockNumber(context.Background(), big.NewInt(100))
+	blockCache.GetPoolReserves(atBlock, pair)
+	syncProvider.GetPoolReserves(context.Background(), pair)
+	syncProvider.GetPoolReserves(context.Background(), pair)
+	if node.reads != 2 {
+		t.Fatalf("got %v node reads want 2", node.reads)
+	}
+
+	quote, err := router.Route(context.Background(), big.NewInt(1000), weth, usdc, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	backend := &transactionBackendFake{status: types.ReceiptStatusSuccessful}
+	executor := NewExecutor(backend, signer, NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil))
+	executor.SetPoolInvalidator(NewQuoteServerInvalidator(server.URL+"/", nil))
+	hash, err := executor.Execute(context.Background(), quote, SwapOptions{Recipient: signer.Address(), NativeIn: true})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	graph.setReserves(weth, usdc, 1001000, 1998000000)
+	if _, err := executor.WaitForTrade(context.Background(), hash, quote, time.Millisecond); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	// the invalidation went through the server to the router and from the sync cache to the block cache
+	reserve0, reserve1, _ := syncProvider.GetPoolReserves(context.Background(), pair)
+	if node.reads != 3 || reserve0.Int64()+reserve1.Int64() != 1999001000 {
+		t.Errorf("got %v/%v after %v node reads want the new reserves read from the node", reserve0, reserve1, node.reads)
+	}
+	blockCache.GetPoolReserves(atBlock, pair)
+	if node.reads != 4 {
+		t.Errorf("got %v node reads want the block cache entry dropped", node.reads)
+	}
+
+	for _, test := range []struct {
+		method, body string
+		want         int
+	}{
+		{method: http.MethodGet, want: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, body: "{", want: http.StatusBadRequest},
+		{method: http.MethodPost, body: `{"pools":[]}`, want: http.StatusOK},
+	} {
+		request := httptest.NewRequest(test.method, "/invalidate", strings.NewReader(test.body))
+		recorder := httptest.NewRecorder()
+		NewQuoteServer(router, graph).ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%v %q: got status %v want %v", test.method, test.body, recorder.Code, test.want)
+		}
+	}
+	if err := NewQuoteServerInvalidator(server.URL+"/missing", nil).InvalidatePools(context.Background(), []common.Address{pair}); err == nil {
+		t.Errorf("got no error from a server without the endpoint")
+	}
+}
@@ -0,0 +1,152 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// defaultMinLiquidityUSD is the liquidity below which a pool is too thin to quote through
+const defaultMinLiquidityUSD = 500000
+
+// liquidityFilter values pools in USD through their reserves, see filter
+type liquidityFilter struct {
+	poolReservesProvider  PoolReservesProvider
+	tokenDecimalsProvider TokenDecimalsProvider
+	// defaults to defaultMinLiquidityUSD
+	minLiquidityUSD float64
+	// valued at $1, defaults to Mainnet's
+	stablecoins []common.Address
+	parallelism int
+}
+
+// filter drops pools that were never created or hold less than minLiquidityUSD, and returns the
+// deployed pools it dropped for their liquidity separately. Pools with no token that can be
+// priced count as below the threshold.
+func (p liquidityFilter) filter(ctx context.Context, candidates []Pool) ([]Pool, []Pool, error) {
+	minLiquidityUSD := p.minLiquidityUSD
+	if minLiquidityUSD == 0 {
+		minLiquidityUSD = defaultMinLiquidityUSD
+	}
+	pools := []Pool{}
+	for _, pool := range candidates {
+		// the factory returns the zero address for pairs that were never created
+		if pool.Contract != (common.Address{}) {
+			pools = append(pools, pool)
+		}
+	}
+
+	// balances[i] holds the reserves of pools[i] in whole Token0 and Token1 units, nil for pair
+	// addresses that were computed but never deployed
+	balances := make([][2]*big.Rat, len(pools))
+	err := forEachParallel(ctx, len(pools), p.parallelism, func(ctx context.Context, i int) error {
+		reserve0, reserve1, err := p.poolReservesProvider.GetPoolReserves(ctx, pools[i].Contract)
+		if errors.Is(err, ErrPairNotDeployed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		reserveA, reserveB, err := orientReserves(ctx, nil, pools[i].Contract, pools[i].Token0, pools[i].Token1, reserve0, reserve1)
+		if err != nil {
+			return err
+		}
+		decimalsA, err := p.tokenDecimalsProvider.GetTokenDecimals(ctx, pools[i].Token0)
+		if err != nil {
+			return err
+		}
+		decimalsB, err := p.tokenDecimalsProvider.GetTokenDecimals(ctx, pools[i].Token1)
+		if err != nil {
+			return err
+		}
+		balances[i] = [2]*big.Rat{tokenUnits(reserveA, decimalsA), tokenUnits(reserveB, decimalsB)}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deployed := 0
+	for i := range pools {
+		if balances[i][0] != nil {
+			pools[deployed], balances[deployed] = pools[i], balances[i]
+			deployed++
+		}
+	}
+	pools, balances = pools[:deployed], balances[:deployed]
+
+	stablecoins := p.stablecoins
+	if len(stablecoins) == 0 {
+		stablecoins = Mainnet.Stablecoins
+	}
+	prices := usdPrices(pools, balances, stablecoins)
+	liquid, thin := []Pool{}, []Pool{}
+	for i, pool := range pools {
+		liquidity, ok := poolLiquidityUSD(prices, pool, balances[i])
+		if ok && liquidity >= minLiquidityUSD {
+			liquid = append(liquid, pool)
+		} else {
+			thin = append(thin, pool)
+		}
+	}
+	return liquid, thin, nil
+}
+
+// usdPrices prices tokens outward from the stablecoins at $1, one pool hop per round so every price
+// comes from the deepest pool with an already priced token: the wrapped native token is priced
+// from its stablecoin pools and the rest mostly from the wrapped native token
+func usdPrices(pools []Pool, balances [][2]*big.Rat, stablecoins []common.Address) map[common.Address]*big.Rat {
+	prices := make(map[common.Address]*big.Rat)
+	for _, stablecoin := range stablecoins {
+		prices[stablecoin] = big.NewRat(1, 1)
+	}
+	for {
+		round := make(map[common.Address]*big.Rat)
+		depth := make(map[common.Address]*big.Rat)
+		for i, pool := range pools {
+			tokens := [2]common.Address{pool.Token0, pool.Token1}
+			for side := 0; side < 2; side++ {
+				priced, unpriced := tokens[side], tokens[1-side]
+				price, ok := prices[priced]
+				if !ok || prices[unpriced] != nil || balances[i][1-side].Sign() == 0 {
+					continue
+				}
+				value := new(big.Rat).Mul(balances[i][side], price)
+				if depth[unpriced] != nil && value.Cmp(depth[unpriced]) <= 0 {
+					continue
+				}
+				depth[unpriced] = value
+				round[unpriced] = new(big.Rat).Quo(value, balances[i][1-side])
+			}
+		}
+		if len(round) == 0 {
+			return prices
+		}
+		for token, price := range round {
+			prices[token] = price
+		}
+	}
+}
+
+// poolLiquidityUSD values both sides of the pool, a V2 pool holds equal value on each side so a
+// pool with one priced token is worth twice that side
+func poolLiquidityUSD(prices map[common.Address]*big.Rat, pool Pool, balance [2]*big.Rat) (float64, bool) {
+	price0, price1 := prices[pool.Token0], prices[pool.Token1]
+	switch {
+	case price0 != nil && price1 != nil:
+		value := new(big.Rat).Mul(balance[0], price0)
+		value.Add(value, new(big.Rat).Mul(balance[1], price1))
+		liquidity, _ := value.Float64()
+		return liquidity, true
+	case price0 != nil:
+		liquidity, _ := new(big.Rat).Mul(balance[0], price0).Float64()
+		return 2 * liquidity, true
+	case price1 != nil:
+		liquidity, _ := new(big.Rat).Mul(balance[1], price1).Float64()
+		return 2 * liquidity, true
+	default:
+		return 0, false
+	}
+}
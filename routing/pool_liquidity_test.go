@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// wholeTokenDecimals makes fake reserves whole tokens so they fit in int64
+type wholeTokenDecimals struct{}
+
+func (wholeTokenDecimals) GetTokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	return 0, nil
+}
+
+func TestGetPoolsFiltersByLiquidity(t *testing.T) {
+	graph := &v2GraphFake{}
+	// $2M a side
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000, 2000000)
+	// $200k a side
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 100, 200000)
+	// priced through WETH: $300k a side
+	graph.addPool(common.HexToAddress(UNI), common.HexToAddress(WETH), 50000, 150)
+	// priced through UNI at $6: $240k a side
+	graph.addPool(common.HexToAddress(WBTC), common.HexToAddress(UNI), 10, 40000)
+	provider := NewOnChainPoolsProvider(graph, &StaticTopTokensProvider{}, graph, wholeTokenDecimals{}, 0)
+
+	pools, err := provider.GetPools(context.Background())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	got := make(map[common.Address]bool)
+	for _, pool := range pools {
+		got[pool.Contract] = true
+	}
+	want := map[common.Address]bool{
+		fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC)): true,
+		fakePairAddress(common.HexToAddress(UNI), common.HexToAddress(WETH)):  true,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v pools want %v", len(got), len(want))
+	}
+	for pair := range want {
+		if !got[pair] {
+			t.Errorf("missing pool %v", pair)
+		}
+	}
+	thin := provider.PoolsBelowLiquidityThreshold()
+	if len(thin) != 2 {
+		t.Errorf("got %v pools below the threshold want the WETH/DAI and WBTC/UNI pools", len(thin))
+	}
+	for _, pool := range thin {
+		if got[pool.Contract] {
+			t.Errorf("pool %v reported below the threshold but kept", pool.Contract)
+		}
+	}
+
+	// a lower threshold keeps the thinner pools, pairs that were never created are still dropped
+	provider = NewOnChainPoolsProvider(graph, &StaticTopTokensProvider{}, graph, wholeTokenDecimals{}, 100000)
+	pools, err = provider.GetPools(context.Background())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(pools) != 4 {
+		t.Errorf("got %v pools want all 4 created pools", len(pools))
+	}
+	if thin := provider.PoolsBelowLiquidityThreshold(); len(thin) != 0 {
+		t.Errorf("got %v pools below the threshold want none", len(thin))
+	}
+}
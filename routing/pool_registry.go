@@ -0,0 +1,256 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"v2Routing/v2math"
+)
+
+// keys of the registry, addresses are written in hex
+const (
+	registryVersionKey     = "meta/version"
+	registryPoolsKey       = "pools/updated"
+	registryPoolPrefix     = "pool/"
+	registryDecimalsPrefix = "decimals/"
+	registryPairPrefix     = "pair/"
+)
+
+// registryMigrations upgrade the database one schema version at a time, migration i brings a
+// database at version i to version i+1. Version 0 is an empty database.
+var registryMigrations = []func(batch *leveldb.Batch){
+	// 1: pools, token decimals and pair addresses stored as JSON with the time they were read
+	func(batch *leveldb.Batch) {},
+}
+
+// PoolRegistry persists discovered pools, token decimals and pair addresses in an embedded
+// LevelDB database so a restarted router does not re-read them from chain. Entries older than
+// ttl are read again, a ttl of 0 keeps them forever.
+type PoolRegistry struct {
+	db  *leveldb.DB
+	ttl time.Duration
+	now func() time.Time
+}
+
+// registryEntry wraps every stored value with the time it was read
+type registryEntry struct {
+	Value     json.RawMessage `json:"value"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// OpenPoolRegistry opens or creates the database at path and migrates it to the current schema
+func OpenPoolRegistry(path string, ttl time.Duration) (*PoolRegistry, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	registry := &PoolRegistry{db: db, ttl: ttl, now: time.Now}
+	if err := registry.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return registry, nil
+}
+
+func (r *PoolRegistry) Close() error {
+	return r.db.Close()
+}
+
+func (r *PoolRegistry) migrate() error {
+	version := 0
+	data, err := r.db.Get([]byte(registryVersionKey), nil)
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return err
+	}
+	if err == nil {
+		if version, err = strconv.Atoi(string(data)); err != nil {
+			return fmt.Errorf("invalid pool registry version %q", data)
+		}
+	}
+	if version > len(registryMigrations) {
+		return fmt.Errorf("pool registry version %v is newer than this router supports (%v)", version, len(registryMigrations))
+	}
+	for ; version < len(registryMigrations); version++ {
+		// every step and its version bump are written atomically
+		batch := new(leveldb.Batch)
+		registryMigrations[version](batch)
+		batch.Put([]byte(registryVersionKey), []byte(strconv.Itoa(version+1)))
+		if err := r.db.Write(batch, nil); err != nil {
+			return fmt.Errorf("migrating pool registry to version %v: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+// get decodes the entry at key into value, ok is false when it is missing or older than ttl
+func (r *PoolRegistry) get(key string, value interface{}) (bool, error) {
+	data, err := r.db.Get([]byte(key), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var entry registryEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return false, fmt.Errorf("decoding pool registry entry %v: %w", key, err)
+	}
+	if r.expired(entry.UpdatedAt) {
+		return false, nil
+	}
+	return true, json.Unmarshal(entry.Value, value)
+}
+
+func (r *PoolRegistry) expired(updatedAt time.Time) bool {
+	return r.ttl > 0 && r.now().Sub(updatedAt) >= r.ttl
+}
+
+func (r *PoolRegistry) encode(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(registryEntry{Value: data, UpdatedAt: r.now()})
+}
+
+func (r *PoolRegistry) put(key string, value interface{}) error {
+	data, err := r.encode(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Put([]byte(key), data, nil)
+}
+
+// PoolsProvider returns provider with its pools persisted, the registry's pools are served
+// until they are older than the ttl
+func (r *PoolRegistry) PoolsProvider(provider PoolsProvider) PoolsProvider {
+	return &registryPoolsProvider{registry: r, provider: provider}
+}
+
+// TokenDecimalsProvider returns provider with the decimals it reads persisted
+func (r *PoolRegistry) TokenDecimalsProvider(provider TokenDecimalsProvider) TokenDecimalsProvider {
+	return &registryTokenDecimalsProvider{registry: r, provider: provider}
+}
+
+// TradingPairProvider returns provider with the pair addresses it reads persisted, including the
+// zero address of pairs that did not exist yet
+func (r *PoolRegistry) TradingPairProvider(provider TradingPairProvider) TradingPairProvider {
+	return &registryTradingPairProvider{registry: r, provider: provider}
+}
+
+type registryPoolsProvider struct {
+	registry *PoolRegistry
+	provider PoolsProvider
+}
+
+func (p *registryPoolsProvider) GetPools(ctx context.Context) ([]Pool, error) {
+	var updated bool
+	ok, err := p.registry.get(registryPoolsKey, &updated)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		pools := []Pool{}
+		iter := p.registry.db.NewIterator(util.BytesPrefix([]byte(registryPoolPrefix)), nil)
+		defer iter.Release()
+		for iter.Next() {
+			var entry registryEntry
+			var pool Pool
+			if err := json.Unmarshal(iter.Value(), &entry); err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(entry.Value, &pool); err != nil {
+				return nil, err
+			}
+			pools = append(pools, pool)
+		}
+		return pools, iter.Error()
+	}
+
+	pools, err := p.provider.GetPools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the stored list is replaced as a whole so pools dropped by the provider do not linger
+	batch := new(leveldb.Batch)
+	iter := p.registry.db.NewIterator(util.BytesPrefix([]byte(registryPoolPrefix)), nil)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	for _, pool := range pools {
+		data, err := p.registry.encode(pool)
+		if err != nil {
+			return nil, err
+		}
+		batch.Put([]byte(registryPoolPrefix+pool.Contract.Hex()), data)
+	}
+	data, err := p.registry.encode(true)
+	if err != nil {
+		return nil, err
+	}
+	batch.Put([]byte(registryPoolsKey), data)
+	if err := p.registry.db.Write(batch, nil); err != nil {
+		return nil, err
+	}
+	return pools, nil
+}
+
+// PoolsBelowLiquidityThreshold forwards the report of the wrapped provider, empty when the pools
+// were served from the registry
+func (p *registryPoolsProvider) PoolsBelowLiquidityThreshold() []Pool {
+	if reporter, ok := p.provider.(thinPoolsReporter); ok {
+		return reporter.PoolsBelowLiquidityThreshold()
+	}
+	return nil
+}
+
+type registryTokenDecimalsProvider struct {
+	registry *PoolRegistry
+	provider TokenDecimalsProvider
+}
+
+func (p *registryTokenDecimalsProvider) GetTokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	key := registryDecimalsPrefix + tokenAddress.Hex()
+	var decimals uint8
+	ok, err := p.registry.get(key, &decimals)
+	if err != nil || ok {
+		return decimals, err
+	}
+	decimals, err = p.provider.GetTokenDecimals(ctx, tokenAddress)
+	if err != nil {
+		return 0, err
+	}
+	return decimals, p.registry.put(key, decimals)
+}
+
+type registryTradingPairProvider struct {
+	registry *PoolRegistry
+	provider TradingPairProvider
+}
+
+func (p *registryTradingPairProvider) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	token0, token1 := v2math.SortTokens(tokenA, tokenB)
+	key := registryPairPrefix + token0.Hex() + "/" + token1.Hex()
+	var pair common.Address
+	ok, err := p.registry.get(key, &pair)
+	if err != nil || ok {
+		return pair, err
+	}
+	pair, err = p.provider.GetTradingPair(ctx, tokenA, tokenB)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return pair, p.registry.put(key, pair)
+}
@@ -0,0 +1,101 @@
+package routing
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func TestPoolRegistryPersistsAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "registry")
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1000000000)
+
+	registry, err := OpenPoolRegistry(path, time.Hour)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	decimals := &TokenDecimalsProviderMock{}
+	decimals.On("GetTokenDecimals", ctx, common.HexToAddress(USDC)).Return()
+	pairs := &TradingPairProviderMock{}
+	pairs.On("GetTradingPair", ctx, common.HexToAddress(WETH), common.HexToAddress(USDC)).Return(common.HexToAddress(WETH_USDC), nil)
+	if _, err := registry.PoolsProvider(graph).GetPools(ctx); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if _, err := registry.TokenDecimalsProvider(decimals).GetTokenDecimals(ctx, common.HexToAddress(USDC)); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if _, err := registry.TradingPairProvider(pairs).GetTradingPair(ctx, common.HexToAddress(WETH), common.HexToAddress(USDC)); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	registry.Close()
+
+	// after a restart everything is served from disk, in either token order
+	registry, err = OpenPoolRegistry(path, time.Hour)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	defer registry.Close()
+	pools, err := registry.PoolsProvider(&v2GraphFake{}).GetPools(ctx)
+	if err != nil || len(pools) != 2 {
+		t.Errorf("got pools %v error %v want the 2 stored pools", pools, err)
+	}
+	if _, err := registry.TokenDecimalsProvider(decimals).GetTokenDecimals(ctx, common.HexToAddress(USDC)); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	pair, err := registry.TradingPairProvider(pairs).GetTradingPair(ctx, common.HexToAddress(USDC), common.HexToAddress(WETH))
+	if err != nil || pair != common.HexToAddress(WETH_USDC) {
+		t.Errorf("got pair %v error %v want the stored WETH/USDC pair", pair, err)
+	}
+	decimals.AssertNumberOfCalls(t, "GetTokenDecimals", 1)
+	pairs.AssertNumberOfCalls(t, "GetTradingPair", 1)
+
+	// expired entries are read again and replace the stored ones
+	registry.now = func() time.Time { return time.Now().Add(time.Hour) }
+	fewer := &v2GraphFake{}
+	fewer.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	if pools, err := registry.PoolsProvider(fewer).GetPools(ctx); err != nil || len(pools) != 1 {
+		t.Errorf("got pools %v error %v want the refreshed pool", pools, err)
+	}
+	registry.now = time.Now
+	if pools, err := registry.PoolsProvider(graph).GetPools(ctx); err != nil || len(pools) != 1 {
+		t.Errorf("got pools %v error %v want only the refreshed pool stored", pools, err)
+	}
+	registry.TokenDecimalsProvider(decimals).GetTokenDecimals(ctx, common.HexToAddress(USDC))
+	decimals.AssertNumberOfCalls(t, "GetTokenDecimals", 1)
+	registry.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	registry.TokenDecimalsProvider(decimals).GetTokenDecimals(ctx, common.HexToAddress(USDC))
+	decimals.AssertNumberOfCalls(t, "GetTokenDecimals", 2)
+}
+
+func TestPoolRegistryRejectsNewerVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry")
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	db.Put([]byte(registryVersionKey), []byte("99"), nil)
+	db.Close()
+	if _, err := OpenPoolRegistry(path, 0); err == nil {
+		t.Error("expected an error opening a registry written by a newer router")
+	}
+
+	db, _ = leveldb.OpenFile(path, nil)
+	db.Delete([]byte(registryVersionKey), nil)
+	db.Close()
+	registry, err := OpenPoolRegistry(path, 0)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	defer registry.Close()
+	version, _ := registry.db.Get([]byte(registryVersionKey), nil)
+	if string(version) != "1" {
+		t.Errorf("got version %s want an empty database migrated to 1", version)
+	}
+}
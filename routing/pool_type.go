@@ -0,0 +1,127 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PoolType is the AMM interface a pool address implements
+type PoolType int
+
+const (
+	// no code at the address
+	PoolTypeNotContract PoolType = iota
+	// a contract without a known pool interface
+	PoolTypeUnknown
+	// token0/token1/getReserves
+	PoolTypeV2
+	// token0/token1/fee/liquidity
+	PoolTypeV3
+)
+
+func (t PoolType) String() string {
+	switch t {
+	case PoolTypeNotContract:
+		return "not a contract"
+	case PoolTypeV2:
+		return "v2"
+	case PoolTypeV3:
+		return "v3"
+	default:
+		return "unknown"
+	}
+}
+
+const v3PoolProbeABI = `[{"inputs":[],"name":"fee","outputs":[{"name":"","type":"uint24"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"liquidity","outputs":[{"name":"","type":"uint128"}],"stateMutability":"view","type":"function"}]`
+
+type PoolTypeProvider interface {
+	GetPoolType(ctx context.Context, poolAddress common.Address) (PoolType, error)
+}
+
+// OnChainPoolTypeProvider classifies an address by probing the view functions of each pool
+// interface. A matched type never changes so it is cached, unknown results are not in case a
+// probe failed for a transient reason.
+type OnChainPoolTypeProvider struct {
+	caller   bind.ContractCaller
+	mu       sync.RWMutex
+	cache    map[common.Address]PoolType
+	counters cacheCounters
+}
+
+func NewOnChainPoolTypeProvider(caller bind.ContractCaller) *OnChainPoolTypeProvider {
+	return &OnChainPoolTypeProvider{caller: caller}
+}
+
+func (p *OnChainPoolTypeProvider) GetPoolType(ctx context.Context, poolAddress common.Address) (PoolType, error) {
+	p.mu.RLock()
+	poolType, ok := p.cache[poolAddress]
+	p.mu.RUnlock()
+	p.counters.count(ok)
+	if ok {
+		return poolType, nil
+	}
+	code, err := p.caller.CodeAt(ctx, poolAddress, BlockNumberFromContext(ctx))
+	if err != nil {
+		return PoolTypeUnknown, fmt.Errorf("reading code of %v: %w", poolAddress.String(), err)
+	}
+	if len(code) == 0 {
+		return PoolTypeNotContract, nil
+	}
+	v2, err := abi.JSON(strings.NewReader(MainABI))
+	if err != nil {
+		return PoolTypeUnknown, err
+	}
+	v3, err := abi.JSON(strings.NewReader(v3PoolProbeABI))
+	if err != nil {
+		return PoolTypeUnknown, err
+	}
+	if !p.probe(ctx, v2, poolAddress, "token0", "token1") {
+		return PoolTypeUnknown, nil
+	}
+	switch {
+	case p.probe(ctx, v2, poolAddress, "getReserves"):
+		poolType = PoolTypeV2
+	case p.probe(ctx, v3, poolAddress, "fee", "liquidity"):
+		poolType = PoolTypeV3
+	default:
+		return PoolTypeUnknown, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cache == nil {
+		p.cache = make(map[common.Address]PoolType)
+	}
+	p.cache[poolAddress] = poolType
+	return poolType, nil
+}
+
+// probe reports whether every method can be called on the address and returns data that decodes
+func (p *OnChainPoolTypeProvider) probe(ctx context.Context, contract abi.ABI, address common.Address, methods ...string) bool {
+	for _, method := range methods {
+		input, err := contract.Pack(method)
+		if err != nil {
+			return false
+		}
+		output, err := p.caller.CallContract(ctx, ethereum.CallMsg{To: &address, Data: input}, BlockNumberFromContext(ctx))
+		if err != nil {
+			return false
+		}
+		if _, err := contract.Unpack(method, output); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *OnChainPoolTypeProvider) CacheSummary() CacheSummary {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.counters.summary(len(p.cache), "")
+}
@@ -0,0 +1,112 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// poolContractsFake answers the view calls each fake contract implements
+type poolContractsFake struct {
+	code    map[common.Address]bool
+	methods map[common.Address][]string
+	calls   int
+}
+
+func (f *poolContractsFake) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if f.code[contract] {
+		return []byte{1}, nil
+	}
+	return nil, nil
+}
+
+func (f *poolContractsFake) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	f.calls++
+	v2, _ := abi.JSON(strings.NewReader(MainABI))
+	v3, _ := abi.JSON(strings.NewReader(v3PoolProbeABI))
+	for _, name := range f.methods[*call.To] {
+		switch name {
+		case "token0", "token1":
+			if string(call.Data[:4]) == string(v2.Methods[name].ID) {
+				return v2.Methods[name].Outputs.Pack(common.HexToAddress(WETH))
+			}
+		case "getReserves":
+			if string(call.Data[:4]) == string(v2.Methods[name].ID) {
+				return v2.Methods[name].Outputs.Pack(big.NewInt(1), big.NewInt(1), uint32(0))
+			}
+		case "fee":
+			if string(call.Data[:4]) == string(v3.Methods[name].ID) {
+				return v3.Methods[name].Outputs.Pack(big.NewInt(3000))
+			}
+		case "liquidity":
+			if string(call.Data[:4]) == string(v3.Methods[name].ID) {
+				return v3.Methods[name].Outputs.Pack(big.NewInt(1))
+			}
+		}
+	}
+	return nil, errors.New("execution reverted")
+}
+
+func TestOnChainPoolTypeProvider(t *testing.T) {
+	v2Pool := common.HexToAddress("0x01")
+	v3Pool := common.HexToAddress("0x02")
+	token := common.HexToAddress("0x03")
+	account := common.HexToAddress("0x04")
+	caller := &poolContractsFake{
+		code: map[common.Address]bool{v2Pool: true, v3Pool: true, token: true},
+		methods: map[common.Address][]string{
+			v2Pool: {"token0", "token1", "getReserves"},
+			v3Pool: {"token0", "token1", "fee", "liquidity"},
+		},
+	}
+	provider := NewOnChainPoolTypeProvider(caller)
+
+	for address, want := range map[common.Address]PoolType{v2Pool: PoolTypeV2, v3Pool: PoolTypeV3, token: PoolTypeUnknown, account: PoolTypeNotContract} {
+		got, err := provider.GetPoolType(context.Background(), address)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if got != want {
+			t.Errorf("%v: got %v want %v", address, got, want)
+		}
+	}
+
+	// matched types are served from the cache
+	calls := caller.calls
+	provider.GetPoolType(context.Background(), v2Pool)
+	if caller.calls != calls {
+		t.Errorf("got %v new calls for a cached pool want 0", caller.calls-calls)
+	}
+}
+
+type poolTypeProviderMock map[common.Address]PoolType
+
+func (m poolTypeProviderMock) GetPoolType(ctx context.Context, poolAddress common.Address) (PoolType, error) {
+	if poolType, ok := m[poolAddress]; ok {
+		return poolType, nil
+	}
+	return PoolTypeV2, nil
+}
+
+func TestRouteSkipsPairsThatAreNotV2Pools(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(DAI), common.HexToAddress(USDC), 1000000000, 1000000000)
+	router := newFakeRouter(graph)
+	router.poolTypeProvider = poolTypeProviderMock{fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC)): PoolTypeUnknown}
+
+	quote, err := router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(USDC), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if path := quote.Path(); len(path) != 3 || path[1] != common.HexToAddress(DAI) {
+		t.Errorf("got path %v want WETH -> DAI -> USDC around the unknown contract", path)
+	}
+}
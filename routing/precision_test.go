@@ -0,0 +1,120 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// maxQuoteRelativeError is the largest relative error quotes may have against exact big.Rat math,
+// rates are exact
+const maxQuoteRelativeError = 1e-9
+
+// precisionPair is a single USDC/WETH pair with arbitrary reserves and decimals, USDC sorts first
+// so it is token0
+type precisionPair struct {
+	reserveA, reserveB   *big.Int
+	decimalsA, decimalsB uint8
+}
+
+func (p precisionPair) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	return common.HexToAddress(WETH_USDC), nil
+}
+
+func (p precisionPair) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	return p.reserveA, p.reserveB, nil
+}
+
+func (p precisionPair) GetTokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	if tokenAddress == common.HexToAddress(USDC) {
+		return p.decimalsA, nil
+	}
+	return p.decimalsB, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// precisionReserves spans 1 to 10^30 in the tokens' smallest unit
+var precisionReserves = []*big.Int{pow10(0), pow10(1), pow10(6), pow10(9), pow10(12), pow10(18), pow10(24), pow10(30)}
+
+// relativeError returns |got - want| / want
+func relativeError(got, want *big.Rat) float64 {
+	diff := new(big.Rat).Sub(got, want)
+	relative, _ := diff.Abs(diff).Quo(diff, want).Float64()
+	return relative
+}
+
+func TestGetExchangeRatePrecision(t *testing.T) {
+	ctx := context.Background()
+	for decimalsA := 0; decimalsA <= 24; decimalsA++ {
+		for decimalsB := 0; decimalsB <= 24; decimalsB++ {
+			for _, reserveA := range precisionReserves {
+				for _, reserveB := range precisionReserves {
+					pair := precisionPair{reserveA: reserveA, reserveB: reserveB, decimalsA: uint8(decimalsA), decimalsB: uint8(decimalsB)}
+					provider := NewOnChainExchangeRateProvider(pair, pair, pair, nil)
+					rate, err := provider.GetExchangeRate(ctx, common.HexToAddress(USDC), common.HexToAddress(WETH))
+					if err != nil {
+						t.Fatalf("got error %v", err)
+					}
+					// (reserveB / 10^decimalsB) / (reserveA / 10^decimalsA)
+					want := new(big.Rat).SetFrac(new(big.Int).Mul(reserveB, pow10(decimalsA)), new(big.Int).Mul(reserveA, pow10(decimalsB)))
+					if rate.Cmp(want) != 0 {
+						t.Fatalf("decimals %v/%v reserves %v/%v: got rate %v want %v", decimalsA, decimalsB, reserveA, reserveB, rate, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// exactAmountOut is getAmountOut without truncation
+func exactAmountOut(amountIn *big.Rat, hop hopReserves) *big.Rat {
+	amountInWithFee := new(big.Rat).Mul(amountIn, new(big.Rat).SetFrac(feeNumerator, feeDenominator))
+	numerator := new(big.Rat).Mul(amountInWithFee, new(big.Rat).SetInt(hop.reserveOut))
+	return numerator.Quo(numerator, new(big.Rat).Add(new(big.Rat).SetInt(hop.reserveIn), amountInWithFee))
+}
+
+func TestGetAmountsOutPrecision(t *testing.T) {
+	// truncating an amount loses at most one unit, which stays within the tolerance once every
+	// amount along the path is this large
+	minAmount := new(big.Rat).SetFloat64(1 / maxQuoteRelativeError)
+	for decimals := 0; decimals <= 24; decimals++ {
+		// one whole token of the input
+		amountIn := pow10(decimals)
+		for _, reserve0 := range precisionReserves {
+			for _, reserve1 := range precisionReserves {
+				for _, reserve2 := range precisionReserves {
+					hops := []hopReserves{
+						{reserveIn: reserve0, reserveOut: reserve1},
+						{reserveIn: reserve1, reserveOut: reserve2},
+					}
+					want := new(big.Rat).SetInt(amountIn)
+					dust := want.Cmp(minAmount) < 0
+					for _, hop := range hops {
+						want = exactAmountOut(want, hop)
+						dust = dust || want.Cmp(minAmount) < 0
+					}
+					amounts, err := getAmountsOut(amountIn, hops)
+					if err != nil && dust {
+						// an intermediate amount truncated to zero
+						continue
+					}
+					if err != nil {
+						t.Fatalf("got error %v", err)
+					}
+					got := new(big.Rat).SetInt(amounts[len(hops)])
+					if got.Cmp(want) > 0 {
+						t.Fatalf("decimals %v reserves %v/%v/%v: quote %v exceeds exact output %v", decimals, reserve0, reserve1, reserve2, got, want.FloatString(6))
+					}
+					if !dust && relativeError(got, want) > maxQuoteRelativeError {
+						t.Fatalf("decimals %v reserves %v/%v/%v: got %v want %v", decimals, reserve0, reserve1, reserve2, got, want.FloatString(6))
+					}
+				}
+			}
+		}
+	}
+}
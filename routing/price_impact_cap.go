@@ -0,0 +1,45 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+
+	"v2Routing/pathfinder"
+)
+
+// routes tried after the best one when it exceeds maxPriceImpact
+const priceImpactCapAlternatives = 8
+
+// capPriceImpact returns found when its price impact is within maxPriceImpact, or else the best of
+// the next routes through its graph that is. When none of them is, a NoRouteError with
+// PriceImpactCapExceeded is returned with found.
+func (r *OnChainV2Router) capPriceImpact(ctx context.Context, found *graphSearch, amount *big.Int, maxHops int) (*graphSearch, error) {
+	limit := new(big.Rat).SetFloat64(r.maxPriceImpact)
+	if found.priceImpact().Cmp(limit) <= 0 {
+		return found, nil
+	}
+	graph := found.graph
+	tokenIn, tokenOut := graph.tokens[graph.tokenInIndex], graph.tokens[graph.tokenOutIndex]
+	results, err := pathfinder.TopK(ctx, graph.searchAlgorithm(), graph.searchGraph(), pathfinder.Request{
+		TokenIn:   tokenIn,
+		TokenOut:  tokenOut,
+		TradeType: found.tradeType,
+		Amount:    amount,
+		MaxHops:   maxHops,
+		HopCost:   found.hopCost,
+	}, priceImpactCapAlternatives)
+	if err != nil {
+		return found, err
+	}
+	for _, result := range results {
+		alternative := &graphSearch{tradeType: found.tradeType, graph: graph, result: result.Amount, path: result.Path, hops: graph.resultHops(result), hopCost: found.hopCost, amountIn: amount}
+		if found.tradeType == exactOut {
+			alternative.amountIn = result.Amount
+		}
+		if alternative.priceImpact().Cmp(limit) <= 0 {
+			logDebug(ctx, r.logger, "best route exceeds the price impact cap", "price_impact", found.priceImpact().FloatString(6), "hops", len(alternative.hops))
+			return alternative, nil
+		}
+	}
+	return found, &NoRouteError{TokenIn: tokenIn, TokenOut: tokenOut, MaxHops: maxHops, Reasons: []NoRouteReason{PriceImpactCapExceeded}}
+}
@@ -0,0 +1,161 @@
+package routing
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HighPriceImpact is the price impact above which integrators should warn users before trading
+const HighPriceImpact = 0.01
+
+// Quote is a route returned by OnChainV2Router.Route and RouteExactOut with the state it was priced
+// against. For exact-in routes AmountIn is the requested amount, for exact-out routes AmountOut.
+type Quote struct {
+	TokenIn   common.Address
+	TokenOut  common.Address
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// pools and reserves of the route in swap order
+	Hops []RouteHop
+	// share of output lost to trade size, see pathPriceImpact
+	PriceImpact *big.Rat
+	// tokens of the route that take a fee on transfer, exact-in amounts are already net of them
+	TransferFees []TransferFee
+	// block the reserves were read at, nil when reads were not pinned
+	BlockNumber *big.Int
+	// when the quote was taken
+	Timestamp time.Time
+	// build and data sources of the router that produced the quote
+	Deployment *Deployment
+	ExactOut   bool
+	// limits to submit the swap with, see SetSlippage. For exact-in routes AmountInMax is AmountIn,
+	// for exact-out routes AmountOutMin is AmountOut.
+	SlippageBips int64
+	AmountOutMin *big.Int
+	AmountInMax  *big.Int
+	// routes through the same tokens swapping some hops in other pools, best first, only filled
+	// by RouteTopK. SetSlippage does not change them.
+	Alternatives []*Quote
+}
+
+// NewQuote is the Quote of an exact-in route found with RouteWithMetadata, with the limits of a
+// slippage tolerance of slippageBips basis points, e.g. to build its swap next to a QuoteResponse
+func NewQuote(tokenIn, tokenOut common.Address, amountIn, amountOut *big.Int, metadata *RouteMetadata, slippageBips int64) (*Quote, error) {
+	quote := newQuote(exactIn, tokenIn, tokenOut, amountIn, amountOut, metadata)
+	if err := quote.SetSlippage(slippageBips); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+func newQuote(tradeType tradeType, tokenIn, tokenOut common.Address, amountIn, amountOut *big.Int, metadata *RouteMetadata) *Quote {
+	return &Quote{
+		ExactOut:     tradeType == exactOut,
+		TokenIn:      tokenIn,
+		TokenOut:     tokenOut,
+		AmountIn:     amountIn,
+		AmountOut:    amountOut,
+		Hops:         metadata.Hops,
+		PriceImpact:  metadata.PriceImpact,
+		TransferFees: metadata.TransferFees,
+		BlockNumber:  metadata.BlockNumber,
+		Timestamp:    time.Now(),
+		Deployment:   metadata.Deployment,
+	}
+}
+
+// SetSlippage recomputes AmountOutMin and AmountInMax for a slippage tolerance of slippageBips
+// basis points, only the side the route did not fix is adjusted
+func (q *Quote) SetSlippage(slippageBips int64) error {
+	amountOutMin, amountInMax := q.AmountOut, q.AmountIn
+	var err error
+	if q.ExactOut {
+		amountInMax, err = AmountInMax(q.AmountIn, slippageBips)
+	} else {
+		amountOutMin, err = AmountOutMin(q.AmountOut, slippageBips)
+	}
+	if err != nil {
+		return err
+	}
+	q.SlippageBips, q.AmountOutMin, q.AmountInMax = slippageBips, amountOutMin, amountInMax
+	return nil
+}
+
+// FormattedAmounts are the amounts of a quote in token units followed by the token's symbol, e.g.
+// "1.5 WETH", see Token.FormatAmount
+type FormattedAmounts struct {
+	AmountIn     string `json:"amountIn"`
+	AmountOut    string `json:"amountOut"`
+	AmountOutMin string `json:"amountOutMin,omitempty"`
+	AmountInMax  string `json:"amountInMax,omitempty"`
+	GasCost      string `json:"gasCost,omitempty"`
+}
+
+// Format writes the amounts of the quote in units of tokenIn and tokenOut, e.g. from a
+// TokenMetadataProvider
+func (q *Quote) Format(tokenIn, tokenOut Token) FormattedAmounts {
+	formatted := FormattedAmounts{AmountIn: tokenIn.FormatAmount(q.AmountIn), AmountOut: tokenOut.FormatAmount(q.AmountOut)}
+	if q.AmountOutMin != nil {
+		formatted.AmountOutMin = tokenOut.FormatAmount(q.AmountOutMin)
+	}
+	if q.AmountInMax != nil {
+		formatted.AmountInMax = tokenIn.FormatAmount(q.AmountInMax)
+	}
+	return formatted
+}
+
+// Path returns the tokens visited by the route, starting with tokenIn
+func (q *Quote) Path() []common.Address {
+	if len(q.Hops) == 0 {
+		return []common.Address{}
+	}
+	path := []common.Address{q.Hops[0].TokenIn}
+	for _, hop := range q.Hops {
+		path = append(path, hop.TokenOut)
+	}
+	return path
+}
+
+// HasTransferFees reports whether a token of the route takes a fee on transfer, such swaps must
+// use Router02's SupportingFeeOnTransferTokens methods
+func (q *Quote) HasTransferFees() bool {
+	return len(q.TransferFees) > 0
+}
+
+func (q *Quote) HopCount() int {
+	return len(q.Hops)
+}
+
+// Price is the tokenOut paid per tokenIn of the quote in whole tokens, exactly. decimalsIn and
+// decimalsOut are the tokens' decimals, e.g. from a TokenDecimalsProvider.
+func (q *Quote) Price(decimalsIn, decimalsOut uint8) *big.Rat {
+	return unitPrice(q.AmountIn, decimalsIn, q.AmountOut, decimalsOut)
+}
+
+// PriceFloat64 is Price rounded to the nearest float64, for display only: amounts to submit come
+// from AmountIn, AmountOut and the slippage limits
+func (q *Quote) PriceFloat64(decimalsIn, decimalsOut uint8) float64 {
+	price, _ := q.Price(decimalsIn, decimalsOut).Float64()
+	return price
+}
+
+// PriceImpactPercent is the share of output lost to trade size compared with trading at the
+// mid-price of the route's reserves, in percent
+func (q *Quote) PriceImpactPercent() float64 {
+	return priceImpactPercent(q.PriceImpact)
+}
+
+// HasHighPriceImpact reports whether the price impact exceeds HighPriceImpact
+func (q *Quote) HasHighPriceImpact() bool {
+	return q.PriceImpactPercent() > HighPriceImpact*100
+}
+
+func priceImpactPercent(priceImpact *big.Rat) float64 {
+	if priceImpact == nil {
+		return 0
+	}
+	percent, _ := new(big.Rat).Mul(priceImpact, big.NewRat(100, 1)).Float64()
+	return percent
+}
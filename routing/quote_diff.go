@@ -0,0 +1,116 @@
+package routing
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// maxTrackedQuotes bounds the quotes QuoteServer remembers for diffs, the history is dropped
+// when it is reached
+const maxTrackedQuotes = 10000
+
+// QuoteDiff compares a quote with the same request quoted at an earlier block
+type QuoteDiff struct {
+	PreviousBlockNumber *big.Int `json:"previousBlockNumber"`
+	PreviousAmountOut   string   `json:"previousAmountOut"`
+	// relative change of the output in percent, positive when the new quote pays more
+	AmountOutChangePercent float64 `json:"amountOutChangePercent"`
+	PathChanged            bool    `json:"pathChanged"`
+	// pools used by both quotes whose reserves changed between the blocks
+	MovedPools []common.Address `json:"movedPools"`
+}
+
+// quoteKey identifies a repeated quote request
+type quoteKey struct {
+	tokenIn  common.Address
+	tokenOut common.Address
+	amountIn string
+	maxHops  int
+}
+
+type quoteSnapshot struct {
+	blockNumber *big.Int
+	amountOut   *big.Int
+	hops        []RouteHop
+}
+
+// quoteHistory keeps the latest quote of every request and the one from the block before it, so
+// requotes within a block are still compared with the previous block
+type quoteHistory struct {
+	mu      sync.Mutex
+	entries map[quoteKey]*quoteHistoryEntry
+}
+
+type quoteHistoryEntry struct {
+	latest   quoteSnapshot
+	previous *quoteSnapshot
+}
+
+// record stores quote and returns its diff with the previous block, nil for the first quote of a
+// request and for unpinned quotes
+func (h *quoteHistory) record(key quoteKey, quote quoteSnapshot) *QuoteDiff {
+	if quote.blockNumber == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.entries == nil || len(h.entries) >= maxTrackedQuotes {
+		h.entries = make(map[quoteKey]*quoteHistoryEntry)
+	}
+	entry, ok := h.entries[key]
+	if !ok {
+		h.entries[key] = &quoteHistoryEntry{latest: quote}
+		return nil
+	}
+	switch entry.latest.blockNumber.Cmp(quote.blockNumber) {
+	case -1:
+		previous := entry.latest
+		entry.previous, entry.latest = &previous, quote
+	case 0:
+		entry.latest = quote
+	default:
+		// a node behind the one the latest quote came from, not worth a diff
+		return nil
+	}
+	if entry.previous == nil {
+		return nil
+	}
+	return diffQuotes(*entry.previous, quote)
+}
+
+func diffQuotes(previous, current quoteSnapshot) *QuoteDiff {
+	diff := &QuoteDiff{
+		PreviousBlockNumber: previous.blockNumber,
+		PreviousAmountOut:   previous.amountOut.String(),
+		PathChanged:         len(previous.hops) != len(current.hops),
+		MovedPools:          []common.Address{},
+	}
+	if previous.amountOut.Sign() > 0 {
+		change := new(big.Rat).SetFrac(new(big.Int).Sub(current.amountOut, previous.amountOut), previous.amountOut)
+		diff.AmountOutChangePercent, _ = change.Mul(change, big.NewRat(100, 1)).Float64()
+	}
+	// the endpoints are the same, so the route is the same when every hop enters the same pool
+	// with the same token
+	for i := 0; !diff.PathChanged && i < len(current.hops); i++ {
+		diff.PathChanged = previous.hops[i].Pair != current.hops[i].Pair || previous.hops[i].TokenIn != current.hops[i].TokenIn
+	}
+	for _, hop := range current.hops {
+		for _, previousHop := range previous.hops {
+			if hop.Pair != previousHop.Pair || hop.ReserveIn == nil || previousHop.ReserveIn == nil {
+				continue
+			}
+			// the same pool may be crossed in the other direction
+			reserveIn, reserveOut := previousHop.ReserveIn, previousHop.ReserveOut
+			if hop.TokenIn != previousHop.TokenIn {
+				reserveIn, reserveOut = reserveOut, reserveIn
+			}
+			if hop.ReserveIn.Cmp(reserveIn) != 0 || hop.ReserveOut.Cmp(reserveOut) != 0 {
+				diff.MovedPools = append(diff.MovedPools, hop.Pair)
+			}
+			break
+		}
+	}
+	return diff
+}
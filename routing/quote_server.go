@@ -0,0 +1,222 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+)
+
+// QuoteServer exposes the router over HTTP:
+//
+//	GET /quote?tokenIn=&tokenOut=&amountIn=&maxHops=&slippageBps=
+//	                                                  best exact-in route, maxHops defaults to AutoMaxHops,
+//	                                                  slippageBps to the router's slippage tolerance,
+//	                                                  diffed against the same quote at the previous block
+//	GET /pools                                        pools the router searches
+//	GET /lp/apr?pair=&tokenIn=&amountIn=&days=&maxHops=
+//	                                                  fee APR of depositing amountIn into pair, from the
+//	                                                  volume of the last days (DefaultFeeAPRDays)
+//	GET /metrics                                      the router's Metrics, when it has them
+//	POST /invalidate                                  drops the cached reserves of the pools of an
+//	                                                  InvalidateRequest, e.g. after a trade landed
+//
+// Clients outside SetAllowedIPs get 403 Forbidden, ListenAndServe adds TLS.
+type QuoteServer struct {
+	router        *OnChainV2Router
+	poolsProvider PoolsProvider
+	mux           *http.ServeMux
+	history       quoteHistory
+	allowed       ipAllowList
+}
+
+func NewQuoteServer(router *OnChainV2Router, poolsProvider PoolsProvider) *QuoteServer {
+	s := &QuoteServer{
+		router:        router,
+		poolsProvider: poolsProvider,
+		mux:           http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/quote", s.handleQuote)
+	s.mux.HandleFunc("/pools", s.handlePools)
+	s.mux.HandleFunc("/lp/apr", s.handleLPFeeAPR)
+	s.mux.HandleFunc("/invalidate", s.handleInvalidate)
+	if router.metrics != nil {
+		s.mux.Handle("/metrics", router.metrics)
+	}
+	return s
+}
+
+// SetAllowedIPs only serves clients connecting from the given IPs and CIDR ranges, every client
+// when empty
+func (s *QuoteServer) SetAllowedIPs(allowed []string) error {
+	list, err := parseIPAllowList(allowed)
+	if err != nil {
+		return err
+	}
+	s.allowed = list
+	return nil
+}
+
+// ListenAndServe serves on addr with the TLS, client certificates and allowed IPs of security
+func (s *QuoteServer) ListenAndServe(addr string, security ServerSecurity) error {
+	if err := s.SetAllowedIPs(security.AllowedIPs); err != nil {
+		return err
+	}
+	tlsConfig, err := security.TLSConfig()
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: addr, Handler: s, TLSConfig: tlsConfig}
+	if tlsConfig == nil {
+		return server.ListenAndServe()
+	}
+	// the certificates are in tlsConfig
+	return server.ListenAndServeTLS("", "")
+}
+
+func (s *QuoteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.allowed.allows(r) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden"})
+		return
+	}
+	if s.router.deployment != nil {
+		s.router.deployment.setHeaders(w.Header())
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *QuoteServer) handleQuote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	query := r.URL.Query()
+	tokenIn, err := ParseAddress(query.Get("tokenIn"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("tokenIn: %v", err)})
+		return
+	}
+	tokenOut, err := ParseAddress(query.Get("tokenOut"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("tokenOut: %v", err)})
+		return
+	}
+	amountIn, ok := new(big.Int).SetString(query.Get("amountIn"), 10)
+	if !ok || amountIn.Sign() <= 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "amountIn must be a positive integer"})
+		return
+	}
+	maxHops := AutoMaxHops
+	if raw := query.Get("maxHops"); raw != "" {
+		maxHops, err = strconv.Atoi(raw)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "maxHops must be an integer"})
+			return
+		}
+	}
+	slippageBips := s.router.slippage()
+	if raw := query.Get("slippageBps"); raw != "" {
+		slippageBips, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || slippageBips < 0 || slippageBips >= 10000 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "slippageBps must be an integer between 0 and 9999"})
+			return
+		}
+	}
+
+	ctx := WithRequestID(r.Context(), NewRequestID())
+	amountOut, path, metadata, err := s.router.RouteWithMetadata(ctx, amountIn, tokenIn, tokenOut, maxHops)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, NewErrorResponse(err))
+		return
+	}
+	response, err := NewQuoteResponse(tokenIn, tokenOut, amountIn, amountOut, path, metadata, slippageBips)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	response.Diff = s.history.record(quoteKey{tokenIn: tokenIn, tokenOut: tokenOut, amountIn: amountIn.String(), maxHops: maxHops}, quoteSnapshot{
+		blockNumber: metadata.BlockNumber,
+		amountOut:   amountOut,
+		hops:        metadata.Hops,
+	})
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *QuoteServer) handlePools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pools, err := s.poolsProvider.GetPools(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, pools)
+}
+
+func (s *QuoteServer) handleLPFeeAPR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	query := r.URL.Query()
+	pair, err := ParseAddress(query.Get("pair"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("pair: %v", err)})
+		return
+	}
+	tokenIn, err := ParseAddress(query.Get("tokenIn"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("tokenIn: %v", err)})
+		return
+	}
+	amountIn, ok := new(big.Int).SetString(query.Get("amountIn"), 10)
+	if !ok || amountIn.Sign() <= 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "amountIn must be a positive integer"})
+		return
+	}
+	days, maxHops := DefaultFeeAPRDays, AutoMaxHops
+	for name, value := range map[string]*int{"days": &days, "maxHops": &maxHops} {
+		if raw := query.Get(name); raw != "" {
+			if *value, err = strconv.Atoi(raw); err != nil {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: name + " must be an integer"})
+				return
+			}
+		}
+	}
+	estimate, err := s.router.EstimateLPFeeAPR(WithRequestID(r.Context(), NewRequestID()), pair, tokenIn, amountIn, days, maxHops)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	// amounts are strings like in QuoteResponse
+	writeJSON(w, http.StatusOK, struct {
+		*LPFeeEstimate
+		Liquidity string `json:"liquidity"`
+	}{LPFeeEstimate: estimate, Liquidity: estimate.Liquidity.String()})
+}
+
+func (s *QuoteServer) handleInvalidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	var request InvalidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if err := s.router.InvalidatePools(r.Context(), request.Pools); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, request)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,134 @@
+package routing
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestQuoteServer(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	server := NewQuoteServer(newFakeRouter(graph), graph)
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/quote?tokenIn="+WETH+"&tokenOut="+USDC+"&amountIn=1000&maxHops=2", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %v body %v", recorder.Code, recorder.Body)
+	}
+	var quote QuoteResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &quote); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	wantOut, _ := getAmountOut(big.NewInt(1000), big.NewInt(1000000), big.NewInt(2000000000))
+	if quote.AmountOut != wantOut.String() {
+		t.Errorf("got amountOut %v want %v", quote.AmountOut, wantOut)
+	}
+	if len(quote.Path) != 2 || quote.Path[1] != common.HexToAddress(USDC) {
+		t.Errorf("got path %v want WETH -> USDC", quote.Path)
+	}
+	if len(quote.Hops) != 1 || quote.Hops[0].DEX != "uniswap-v2" {
+		t.Errorf("got hops %v want one uniswap-v2 hop", quote.Hops)
+	}
+	if quote.PriceImpact <= 0 || quote.PriceImpact > 0.01 {
+		t.Errorf("got price impact %v want a small positive share", quote.PriceImpact)
+	}
+	if quote.RequestID == "" {
+		t.Error("got empty request id")
+	}
+	if quote.PriceImpactPercent <= 0 || quote.PriceImpactWarning != "" {
+		t.Errorf("got price impact %v%% warning %q want a small impact without a warning", quote.PriceImpactPercent, quote.PriceImpactWarning)
+	}
+	if wantMin, _ := AmountOutMin(wantOut, DefaultSlippageBips); quote.SlippageBips != DefaultSlippageBips || quote.AmountOutMin != wantMin.String() {
+		t.Errorf("got amountOutMin %v at %v bips want %v at the default", quote.AmountOutMin, quote.SlippageBips, wantMin)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/quote?tokenIn="+WETH+"&tokenOut="+USDC+"&amountIn=1000&slippageBps=100", nil))
+	if err := json.Unmarshal(recorder.Body.Bytes(), &quote); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if wantMin, _ := AmountOutMin(wantOut, 100); quote.SlippageBips != 100 || quote.AmountOutMin != wantMin.String() {
+		t.Errorf("got amountOutMin %v at %v bips want %v at 100", quote.AmountOutMin, quote.SlippageBips, wantMin)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/quote?tokenIn="+WETH+"&tokenOut="+USDC+"&amountIn=1000&slippageBps=10000", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("got status %v for slippage of 100%% want %v", recorder.Code, http.StatusBadRequest)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/quote?tokenIn=nope&tokenOut="+USDC+"&amountIn=1000", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("got status %v for a bad tokenIn want %v", recorder.Code, http.StatusBadRequest)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/quote?tokenIn="+WETH+"&tokenOut="+WETH+"&amountIn=1000", nil))
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %v for an unroutable pair want %v", recorder.Code, http.StatusUnprocessableEntity)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/pools", nil))
+	var pools []Pool
+	if err := json.Unmarshal(recorder.Body.Bytes(), &pools); err != nil || len(pools) != 1 || pools[0].Contract != graph.pools[0].Contract {
+		t.Errorf("got pools %v error %v want the fake pool", pools, err)
+	}
+}
+
+func TestQuoteServerDiffsAgainstPreviousBlock(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(DAI), common.HexToAddress(USDC), 1000000000, 1000000000)
+	router := newFakeRouter(graph)
+	server := NewQuoteServer(router, graph)
+	quote := func(block uint64) QuoteResponse {
+		router.blockNumberProvider = staticBlockNumberProvider(block)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/quote?tokenIn="+WETH+"&tokenOut="+USDC+"&amountIn=1000&maxHops=2", nil))
+		var response QuoteResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("got error %v body %v", err, recorder.Body)
+		}
+		return response
+	}
+
+	if response := quote(100); response.Diff != nil {
+		t.Errorf("got diff %+v for the first quote", response.Diff)
+	}
+	if response := quote(100); response.Diff != nil {
+		t.Errorf("got diff %+v without a previous block", response.Diff)
+	}
+
+	// the direct pool pays a little less but stays the best route
+	wethUSDC := fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC))
+	reserves := graph.reserves[wethUSDC]
+	usdcReserve := new(big.Int).Div(new(big.Int).Mul(reserves[0], big.NewInt(999)), big.NewInt(1000))
+	graph.reserves[wethUSDC] = [2]*big.Int{usdcReserve, reserves[1]}
+	for i := 0; i < 2; i++ {
+		diff := quote(101).Diff
+		if diff == nil || diff.PreviousBlockNumber.Uint64() != 100 || diff.PathChanged {
+			t.Fatalf("got diff %+v want the same path as block 100", diff)
+		}
+		if len(diff.MovedPools) != 1 || diff.MovedPools[0] != wethUSDC {
+			t.Errorf("got moved pools %v want the WETH/USDC pool", diff.MovedPools)
+		}
+		if diff.AmountOutChangePercent == 0 {
+			t.Errorf("got no output change after the pool moved")
+		}
+	}
+
+	// draining the direct pool moves the route through DAI
+	graph.reserves[wethUSDC] = [2]*big.Int{big.NewInt(10), big.NewInt(10)}
+	diff := quote(102).Diff
+	if diff == nil || !diff.PathChanged || diff.AmountOutChangePercent >= 0 || len(diff.MovedPools) != 0 {
+		t.Errorf("got diff %+v want a new path paying less and no shared pools", diff)
+	}
+}
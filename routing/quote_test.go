@@ -0,0 +1,110 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRouteQuote(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	graph.addPool(usdc, dai, 1000000000, 1100000000)
+	router := newFakeRouter(graph)
+	router.blockNumberProvider = staticBlockNumberProvider(16000000)
+
+	quote, err := router.Route(context.Background(), big.NewInt(1000), weth, dai, 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if want := []common.Address{weth, usdc, dai}; !reflect.DeepEqual(quote.Path(), want) || quote.HopCount() != 2 {
+		t.Errorf("got path %v want %v", quote.Path(), want)
+	}
+	if quote.TokenIn != weth || quote.TokenOut != dai || quote.AmountIn.Int64() != 1000 || quote.AmountOut.Sign() <= 0 {
+		t.Errorf("got quote %+v", quote)
+	}
+	// reserves are oriented in the swap direction
+	if hop := quote.Hops[0]; hop.ReserveIn.Int64() != 1000000 || hop.ReserveOut.Int64() != 2000000000 {
+		t.Errorf("got first hop reserves %v %v want 1000000 2000000000", hop.ReserveIn, hop.ReserveOut)
+	}
+	if quote.PriceImpact == nil || quote.PriceImpact.Sign() <= 0 {
+		t.Errorf("got price impact %v want positive", quote.PriceImpact)
+	}
+	if quote.BlockNumber.Int64() != 16000000 || quote.Timestamp.IsZero() {
+		t.Errorf("got block %v at %v want block 16000000 with a timestamp", quote.BlockNumber, quote.Timestamp)
+	}
+
+	// NewQuote builds the same quote from the results of RouteWithMetadata
+	amountOut, _, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(1000), weth, dai, 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	built, err := NewQuote(weth, dai, big.NewInt(1000), amountOut, metadata, DefaultSlippageBips)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if built.AmountOutMin.Cmp(quote.AmountOutMin) != 0 || !reflect.DeepEqual(built.Hops, quote.Hops) || built.BlockNumber.Cmp(quote.BlockNumber) != 0 || built.ExactOut {
+		t.Errorf("got quote %+v want %+v", built, quote)
+	}
+
+	quote, err = router.RouteExactOut(context.Background(), weth, dai, big.NewInt(1000000), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if quote.AmountOut.Int64() != 1000000 || quote.AmountIn.Sign() <= 0 {
+		t.Errorf("got %v in for %v out", quote.AmountIn, quote.AmountOut)
+	}
+}
+
+func TestQuotePriceImpact(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	router := newFakeRouter(graph)
+
+	small, err := router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(USDC), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// 1000 into 1000000 moves the price by about 0.1%
+	if percent := small.PriceImpactPercent(); percent < 0.09 || percent > 0.11 || small.HasHighPriceImpact() {
+		t.Errorf("got price impact %v%% want about 0.1%% without a warning", percent)
+	}
+	large, err := router.Route(context.Background(), big.NewInt(100000), common.HexToAddress(WETH), common.HexToAddress(USDC), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if !large.HasHighPriceImpact() {
+		t.Errorf("got price impact %v%% without a warning", large.PriceImpactPercent())
+	}
+}
+
+func TestQuoteFormat(t *testing.T) {
+	weth := Token{Address: common.HexToAddress(WETH), Symbol: "WETH", Decimals: 18}
+	usdc := Token{Address: common.HexToAddress(USDC), Symbol: "USDC", Decimals: 6}
+	amountIn, _ := weth.ParseAmount("1.5")
+	quote := &Quote{TokenIn: weth.Address, TokenOut: usdc.Address, AmountIn: amountIn, AmountOut: big.NewInt(2850123456)}
+	if err := quote.SetSlippage(100); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	want := FormattedAmounts{AmountIn: "1.5 WETH", AmountOut: "2850.123456 USDC", AmountOutMin: "2821.622221 USDC", AmountInMax: "1.5 WETH"}
+	if formatted := quote.Format(weth, usdc); formatted != want {
+		t.Errorf("got %+v want %+v", formatted, want)
+	}
+	// 2850.123456 / 1.5 exactly, the float is for display
+	if price := quote.Price(weth.Decimals, usdc.Decimals); price.Cmp(big.NewRat(2850123456, 1500000)) != 0 || quote.PriceFloat64(weth.Decimals, usdc.Decimals) != 1900.082304 {
+		t.Errorf("got price %v want 1900.082304", price)
+	}
+
+	response, err := NewQuoteResponse(weth.Address, usdc.Address, quote.AmountIn, quote.AmountOut, quote.Path(), &RouteMetadata{GasCost: big.NewInt(4500000)}, 100)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	response.SetTokens(weth, usdc)
+	if response.Formatted.AmountOutMin != want.AmountOutMin || response.Formatted.GasCost != "4.5 USDC" || response.TokenOutInfo.Decimals != 6 {
+		t.Errorf("got %+v want the quote's amounts and gas in USDC", response.Formatted)
+	}
+}
@@ -0,0 +1,939 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/raghava-pamula/factory"
+
+	"v2Routing/pathfinder"
+)
+
+type TradingPairProvider interface {
+	GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error)
+}
+
+type OnChainTradingPairProvider struct {
+	rpcClient     *ethclient.Client
+	factoryCaller factory.FactoryCaller
+}
+
+// NewOnChainTradingPairProvider looks pairs up on the V2 factory at factoryAddress
+func NewOnChainTradingPairProvider(factoryAddress common.Address, rpcClient *ethclient.Client) (*OnChainTradingPairProvider, error) {
+	factoryCaller, err := factory.NewFactoryCaller(factoryAddress, rpcClient)
+	if err != nil {
+		return nil, err
+	}
+	return &OnChainTradingPairProvider{rpcClient: rpcClient, factoryCaller: *factoryCaller}, nil
+}
+
+func (f *OnChainTradingPairProvider) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	callOpts := newCallOpts(ctx)
+	pairAddress, err := f.factoryCaller.GetPair(callOpts, tokenA, tokenB)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("looking up the pair of %v and %v: %w", tokenA.String(), tokenB.String(), err)
+	}
+	return pairAddress, nil
+}
+
+// top tokens provider returns the top tokens on Uniswap V2
+type TopTokensProvider interface {
+	GetTopTokens(ctx context.Context) ([]common.Address, error)
+}
+
+// StaticTopTokensProvider returns a fixed list of tokens, e.g. the BaseTokens of a Chain
+type StaticTopTokensProvider struct {
+	// defaults to Mainnet's base tokens
+	Tokens []common.Address
+}
+
+func (s *StaticTopTokensProvider) GetTopTokens(ctx context.Context) ([]common.Address, error) {
+	if len(s.Tokens) == 0 {
+		return append([]common.Address{}, Mainnet.BaseTokens...), nil
+	}
+	return append([]common.Address{}, s.Tokens...), nil
+}
+
+type V2Router interface {
+	Route(ctx context.Context, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*Quote, error)
+	RouteExactOut(ctx context.Context, tokenIn, tokenOut common.Address, amountOut *big.Int, maxHops int) (*Quote, error)
+}
+
+// AutoMaxHops lets the router choose the search depth from the connectivity of the pair
+const AutoMaxHops = 0
+
+// swaps with more than 5 hops are not supported for performance and gas cost constraints
+const maxSupportedHops = 5
+
+type OnChainV2Router struct {
+	poolProvider         PoolsProvider
+	tradingPairProvider  TradingPairProvider
+	poolReservesProvider PoolReservesProvider
+	topTokensProvider    TopTokensProvider
+	// optional, when set reserves are ordered by the pair's own token0/token1
+	pairTokensProvider PairTokensProvider
+	// optional, when set all reads of a route are pinned to the same block
+	blockNumberProvider BlockNumberProvider
+	// optional, when set only sampled routes emit detailed logs
+	traceSampler TraceSampler
+	// research only: lifts the maxSupportedHops limit
+	allowDeepSearch bool
+	// optional, when set the search state is saved after every hop and resumed on the next call
+	checkpointDir string
+	// writes the checkpoints of checkpointDir, (*routeCheckpoint).save when nil. Tests interrupt
+	// searches between hops with it.
+	saveCheckpoint func(checkpoint *routeCheckpoint, path string) error
+	// optional, the route search, defaults to pathfinder.HopBounded. Only the default search is
+	// checkpointed.
+	pathfinder pathfinder.Algorithm
+	// share of a hop's input reserve a trade may use before it is flagged, defaults to defaultMaxReserveFraction
+	maxReserveFraction float64
+	// when set, routes over maxReserveFraction fail with InsufficientReservesError instead of warning
+	strictReserves bool
+	// share of its output a route may lose to price impact (0.01 is 1%), routes beyond it are not
+	// returned, 0 allows any. See capPriceImpact.
+	maxPriceImpact float64
+	// optional, when set routes are ranked by their amount net of gas
+	gasPricing *GasPricing
+	// concurrent RPC calls while building the graph, defaults to defaultParallelism
+	parallelism int
+	// optional, DEXes the graph is built from, defaults to Uniswap V2 through tradingPairProvider
+	dexAdapters []DEXAdapter
+	// optional, when set pairs that are not V2 pools are left out of the graph
+	poolTypeProvider PoolTypeProvider
+	// optional, needed with pairTokensProvider to quote LP tokens
+	totalSupplyProvider TotalSupplyProvider
+	// optional, needed with totalSupplyProvider to estimate LP fee APRs
+	poolVolumeProvider PoolVolumeProvider
+	// optional, when set the tokens of every route are checked for transfer fees and exact-in
+	// routes are priced net of them
+	transferFeeProvider TransferFeeProvider
+	// optional, when set poor routes are searched again with the neighbors of tokenIn and tokenOut
+	candidateExpansion *CandidateExpansion
+	// stamped on every quote, nil leaves quotes unstamped
+	deployment *Deployment
+	// slippage tolerance of quotes in basis points, 0 means DefaultSlippageBips
+	slippageBips int64
+	// wrapped native token for gas pricing and V2 factory of the default adapter, the zero value
+	// means Mainnet
+	chain Chain
+	// optional, when set every route is timed and counted and the caches are watched
+	metrics *Metrics
+	// optional, when set routes, pool discovery, reserve fetching and RPC calls record spans
+	tracer Tracer
+	// optional, the logger of routes and of the providers they call without their own, routes log
+	// nothing without one
+	logger *slog.Logger
+
+	debug debugState
+}
+
+// V2RouterConfig configures NewOnChainV2Router, see OnChainV2Router for what the optional fields do
+type V2RouterConfig struct {
+	PoolProvider         PoolsProvider
+	TradingPairProvider  TradingPairProvider
+	PoolReservesProvider PoolReservesProvider
+	TopTokensProvider    TopTokensProvider
+	PairTokensProvider   PairTokensProvider
+	BlockNumberProvider  BlockNumberProvider
+	TraceSampler         TraceSampler
+	AllowDeepSearch      bool
+	CheckpointDir        string
+	Pathfinder           pathfinder.Algorithm
+	MaxReserveFraction   float64
+	StrictReserves       bool
+	MaxPriceImpact       float64
+	GasPricing           *GasPricing
+	Parallelism          int
+	DEXAdapters          []DEXAdapter
+	PoolTypeProvider     PoolTypeProvider
+	TotalSupplyProvider  TotalSupplyProvider
+	PoolVolumeProvider   PoolVolumeProvider
+	TransferFeeProvider  TransferFeeProvider
+	CandidateExpansion   *CandidateExpansion
+	Deployment           Deployment
+	SlippageBips         int64
+	// contracts and tokens of the chain routed on, defaults to Mainnet
+	Chain   *Chain
+	Metrics *Metrics
+	Tracer  Tracer
+	Logger  *slog.Logger
+}
+
+func NewOnChainV2Router(config V2RouterConfig) *OnChainV2Router {
+	router := &OnChainV2Router{
+		poolProvider:         config.PoolProvider,
+		tradingPairProvider:  config.TradingPairProvider,
+		poolReservesProvider: config.PoolReservesProvider,
+		topTokensProvider:    config.TopTokensProvider,
+		pairTokensProvider:   config.PairTokensProvider,
+		blockNumberProvider:  config.BlockNumberProvider,
+		traceSampler:         config.TraceSampler,
+		allowDeepSearch:      config.AllowDeepSearch,
+		checkpointDir:        config.CheckpointDir,
+		pathfinder:           config.Pathfinder,
+		maxReserveFraction:   config.MaxReserveFraction,
+		strictReserves:       config.StrictReserves,
+		maxPriceImpact:       config.MaxPriceImpact,
+		gasPricing:           config.GasPricing,
+		parallelism:          config.Parallelism,
+		dexAdapters:          config.DEXAdapters,
+		poolTypeProvider:     config.PoolTypeProvider,
+		totalSupplyProvider:  config.TotalSupplyProvider,
+		poolVolumeProvider:   config.PoolVolumeProvider,
+		transferFeeProvider:  config.TransferFeeProvider,
+		candidateExpansion:   config.CandidateExpansion,
+		slippageBips:         config.SlippageBips,
+		metrics:              config.Metrics,
+		tracer:               config.Tracer,
+		logger:               config.Logger,
+	}
+	if config.Chain != nil {
+		router.chain = *config.Chain
+	}
+	if router.metrics != nil {
+		for name, cache := range router.caches() {
+			router.metrics.WatchCache(name, cache)
+		}
+	}
+	router.deployment = config.Deployment.withDefaults(router.adapters())
+	return router
+}
+
+// RouteMetadata describes the work done by a single route search
+type RouteMetadata struct {
+	// number of distinct tokens in the search graph
+	TokensConsidered int
+	// number of (input, output) hops priced during the search
+	EdgesEvaluated int
+	// reserve lookups served from the per-route reserves cache
+	CacheHits int
+	// hops skipped because the input token was not reachable yet
+	PrunedCandidates int
+	// block all reserves were read at, nil when reads were not pinned
+	BlockNumber *big.Int
+	// ID attached to the logs and RPC calls of this route
+	RequestID string
+	// whether the route was picked for detailed logging
+	Traced bool
+	// hops of the returned route that use a large share of their pool's reserves
+	ReserveWarnings []ReserveWarning
+	// gas cost of the returned route in tokenOut for exact-in and tokenIn for exact-out,
+	// nil without gas pricing
+	GasCost *big.Int
+	// pools of the returned route in swap order
+	Hops []RouteHop
+	// share of output lost to trade size along the returned route, see pathPriceImpact
+	PriceImpact *big.Rat
+	// fee-on-transfer tokens of the returned route, empty without a TransferFeeProvider
+	TransferFees []TransferFee
+	// every pair looked up for the graph and whether it was searched
+	Edges []EdgeDiagnostic
+	// neighbors of tokenIn and tokenOut added to the search because the pool tokens alone
+	// yielded a poor route, see CandidateExpansion
+	ExpandedTokens []common.Address
+	// build and data sources of the router that produced the route
+	Deployment *Deployment
+}
+
+// RouteHop is one swap of a route and the DEX it goes through
+type RouteHop struct {
+	TokenIn  common.Address `json:"tokenIn"`
+	TokenOut common.Address `json:"tokenOut"`
+	Pair     common.Address `json:"pair"`
+	DEX      string         `json:"dex"`
+	// reserves the hop was priced with, oriented in the swap direction
+	ReserveIn  *big.Int `json:"reserveIn"`
+	ReserveOut *big.Int `json:"reserveOut"`
+}
+
+// Route finds the path that turns amountIn of tokenIn into the most tokenOut, every hop is
+// priced with the V2 constant product formula including the 0.3% fee
+func (r *OnChainV2Router) Route(ctx context.Context, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*Quote, error) {
+	amountOut, _, metadata, err := r.RouteWithMetadata(ctx, amountIn, tokenIn, tokenOut, maxHops)
+	if err != nil {
+		return nil, err
+	}
+	return r.quote(exactIn, tokenIn, tokenOut, amountIn, amountOut, metadata)
+}
+
+// RouteWithMetadata behaves like Route and additionally reports search counters
+func (r *OnChainV2Router) RouteWithMetadata(ctx context.Context, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*big.Int, []common.Address, *RouteMetadata, error) {
+	return r.route(ctx, exactIn, amountIn, tokenIn, tokenOut, maxHops)
+}
+
+// RouteExactOut finds the path that delivers exactly amountOut of tokenOut for the least tokenIn,
+// and returns the required amountIn
+func (r *OnChainV2Router) RouteExactOut(ctx context.Context, tokenIn, tokenOut common.Address, amountOut *big.Int, maxHops int) (*Quote, error) {
+	amountIn, _, metadata, err := r.RouteExactOutWithMetadata(ctx, tokenIn, tokenOut, amountOut, maxHops)
+	if err != nil {
+		return nil, err
+	}
+	return r.quote(exactOut, tokenIn, tokenOut, amountIn, amountOut, metadata)
+}
+
+// RouteExactOutWithMetadata behaves like RouteExactOut and additionally reports search counters
+func (r *OnChainV2Router) RouteExactOutWithMetadata(ctx context.Context, tokenIn, tokenOut common.Address, amountOut *big.Int, maxHops int) (*big.Int, []common.Address, *RouteMetadata, error) {
+	return r.route(ctx, exactOut, amountOut, tokenIn, tokenOut, maxHops)
+}
+
+func (r *OnChainV2Router) quote(tradeType tradeType, tokenIn, tokenOut common.Address, amountIn, amountOut *big.Int, metadata *RouteMetadata) (*Quote, error) {
+	quote := newQuote(tradeType, tokenIn, tokenOut, amountIn, amountOut, metadata)
+	if err := quote.SetSlippage(r.slippage()); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// slippage is the tolerance quotes are given, DefaultSlippageBips unless configured
+func (r *OnChainV2Router) slippage() int64 {
+	if r.slippageBips == 0 {
+		return DefaultSlippageBips
+	}
+	return r.slippageBips
+}
+
+func (r *OnChainV2Router) route(ctx context.Context, tradeType tradeType, amount *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*big.Int, []common.Address, *RouteMetadata, error) {
+	started := time.Now()
+	if r.tracer != nil {
+		ctx = WithTracer(ctx, r.tracer)
+	}
+	if r.logger != nil {
+		ctx = WithLogger(ctx, r.logger)
+	}
+	ctx = withTraceDecision(ensureRequestID(ctx), r.traceSampler)
+	ctx, span := startSpan(ctx, "route",
+		Attribute{"routing.token_in", tokenIn},
+		Attribute{"routing.token_out", tokenOut},
+		Attribute{"routing.trade_type", tradeType.String()},
+		Attribute{"routing.amount", amount},
+		Attribute{"routing.max_hops", maxHops},
+		Attribute{"routing.request_id", RequestIDFromContext(ctx)})
+	result, path, metadata, err := r.findRoute(ctx, tradeType, amount, tokenIn, tokenOut, maxHops)
+	span.SetAttributes(Attribute{"routing.hops", len(metadata.Hops)})
+	if metadata.BlockNumber != nil {
+		span.SetAttributes(Attribute{"routing.block_number", metadata.BlockNumber.Uint64()})
+	}
+	span.RecordError(err)
+	span.End()
+	r.metrics.observeRoute(tradeType, time.Since(started), metadata, err)
+	return result, path, metadata, err
+}
+
+func (r *OnChainV2Router) findRoute(ctx context.Context, tradeType tradeType, amount *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*big.Int, []common.Address, *RouteMetadata, error) {
+	metadata := &RouteMetadata{RequestID: RequestIDFromContext(ctx), Traced: isTraced(ctx), Deployment: r.deployment}
+	if tokenIn == tokenOut {
+		return new(big.Int), make([]common.Address, 0), metadata, fmt.Errorf("%w: %v", ErrSameToken, tokenIn.String())
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return new(big.Int), make([]common.Address, 0), metadata, errors.New("amount must be positive")
+	}
+	defer r.trackRoute(ctx, tradeType, amount, tokenIn, tokenOut)()
+	ctx, err := r.pinBlock(ctx)
+	if err != nil {
+		return new(big.Int), make([]common.Address, 0), metadata, err
+	}
+	metadata.BlockNumber = BlockNumberFromContext(ctx)
+	maxHops, err = r.resolveMaxHops(ctx, tokenIn, tokenOut, maxHops)
+	if err != nil {
+		return new(big.Int), make([]common.Address, 0), metadata, err
+	}
+	found, err := r.searchGraph(ctx, tradeType, amount, tokenIn, tokenOut, maxHops, nil, metadata)
+	if r.candidateExpansion != nil && r.candidateExpansion.poor(found, err) {
+		if expanded, tokens, expandErr := r.expandSearch(ctx, tradeType, amount, tokenIn, tokenOut, maxHops, metadata); expandErr != nil {
+			logWarn(ctx, r.logger, "candidate expansion failed", "error", expandErr)
+		} else if err != nil || expanded.better(found) {
+			found, err = expanded, nil
+			metadata.ExpandedTokens = tokens
+		}
+	}
+	if err == nil && r.maxPriceImpact > 0 {
+		found, err = r.capPriceImpact(ctx, found, amount, maxHops)
+	}
+	if found != nil {
+		metadata.TokensConsidered = len(found.graph.tokens)
+		metadata.Edges = found.graph.edges
+	}
+	if err != nil {
+		return new(big.Int), make([]common.Address, 0), metadata, err
+	}
+	result, path, hops, hopCost := found.result, found.path, found.hops, found.hopCost
+	metadata.Hops = routeHops(path, hops)
+	metadata.PriceImpact = found.priceImpact()
+	if r.transferFeeProvider != nil {
+		metadata.TransferFees = r.transferFees(ctx, path, hops)
+		// exact-out routes keep their amounts, Router02 cannot swap taxed tokens for an exact output
+		if tradeType == exactIn && len(metadata.TransferFees) > 0 {
+			result, err = amountOutAfterTransferFees(amount, path, hops, metadata.TransferFees)
+			if err != nil {
+				return new(big.Int), make([]common.Address, 0), metadata, err
+			}
+			logDebug(ctx, r.logger, "route amount after transfer fees", "amount", result, "transfer_fees", metadata.TransferFees)
+		}
+	}
+	if hopCost != nil {
+		metadata.GasCost = new(big.Int).Mul(hopCost, big.NewInt(int64(len(path)-1)))
+	}
+
+	maxFraction := r.maxReserveFraction
+	if maxFraction == 0 {
+		maxFraction = defaultMaxReserveFraction
+	}
+	metadata.ReserveWarnings, err = checkReserveFractions(tradeType, amount, path, hops, maxFraction)
+	if err != nil {
+		return new(big.Int), make([]common.Address, 0), metadata, err
+	}
+	if r.strictReserves && len(metadata.ReserveWarnings) > 0 {
+		return new(big.Int), make([]common.Address, 0), metadata, &InsufficientReservesError{MaxFraction: maxFraction, Warnings: metadata.ReserveWarnings}
+	}
+	return result, path, metadata, nil
+}
+
+// routeHops describes the pools of a route for RouteMetadata.Hops
+func routeHops(path []common.Address, hops []hopReserves) []RouteHop {
+	var routeHops []RouteHop
+	for i, hop := range hops {
+		routeHops = append(routeHops, RouteHop{TokenIn: path[i], TokenOut: path[i+1], Pair: hop.pair, DEX: hop.dex.Name(), ReserveIn: hop.reserveIn, ReserveOut: hop.reserveOut})
+	}
+	return routeHops
+}
+
+// searchGraph builds the graph of the pool tokens and extraTokens and finds the best route through
+// it, the graph is returned with the error when the search fails
+func (r *OnChainV2Router) searchGraph(ctx context.Context, tradeType tradeType, amount *big.Int, tokenIn, tokenOut common.Address, maxHops int, extraTokens []common.Address, metadata *RouteMetadata) (*graphSearch, error) {
+	graph, err := r.buildGraph(ctx, tokenIn, tokenOut, extraTokens)
+	if err != nil {
+		return nil, err
+	}
+	found := &graphSearch{tradeType: tradeType, graph: graph}
+
+	var checkpoints *fileCheckpoints
+	if r.checkpointDir != "" {
+		checkpoints = &fileCheckpoints{path: routeCheckpointPath(r.checkpointDir, tradeType, tokenIn, tokenOut), save: r.saveCheckpoint}
+	}
+	if r.gasPricing != nil {
+		// gas is netted from the amount the caller does not fix
+		quoteToken := tokenOut
+		if tradeType == exactOut {
+			quoteToken = tokenIn
+		}
+		found.hopCost, err = r.gasPricing.hopCost(ctx, graph, r.routedChain().WrappedNative, quoteToken, maxHops)
+		if err != nil {
+			return found, err
+		}
+	}
+	found.result, found.path, found.hops, err = searchRoute(ctx, graph, tradeType, amount, maxHops, found.hopCost, metadata, checkpoints)
+	if err != nil {
+		return found, err
+	}
+	found.amountIn = amount
+	if tradeType == exactOut {
+		found.amountIn = found.result
+	}
+	return found, nil
+}
+
+// expandSearch searches again with the neighbors of tokenIn and tokenOut added to the graph
+func (r *OnChainV2Router) expandSearch(ctx context.Context, tradeType tradeType, amount *big.Int, tokenIn, tokenOut common.Address, maxHops int, metadata *RouteMetadata) (*graphSearch, []common.Address, error) {
+	tokens, err := r.candidateExpansion.tokens(ctx, tokenIn, tokenOut)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil, errors.New("no neighbor tokens")
+	}
+	logDebug(ctx, r.logger, "expanding the search with neighbor tokens", "tokens", len(tokens))
+	found, err := r.searchGraph(ctx, tradeType, amount, tokenIn, tokenOut, maxHops, tokens, metadata)
+	return found, tokens, err
+}
+
+func (r *OnChainV2Router) adapters() []DEXAdapter {
+	if len(r.dexAdapters) > 0 {
+		return r.dexAdapters
+	}
+	return []DEXAdapter{NewV2ForkAdapter("uniswap-v2", r.routedChain().V2Factory, r.tradingPairProvider, 30)}
+}
+
+// routedChain is the configured chain, Mainnet when none is
+func (r *OnChainV2Router) routedChain() Chain {
+	if r.chain.ID == 0 {
+		return Mainnet
+	}
+	return r.chain
+}
+
+// resolveMaxHops applies the adaptive depth and the hop limits
+func (r *OnChainV2Router) resolveMaxHops(ctx context.Context, tokenIn, tokenOut common.Address, maxHops int) (int, error) {
+	if maxHops == AutoMaxHops {
+		return r.adaptiveMaxHops(ctx, tokenIn, tokenOut)
+	}
+	// at least one hop is required to route
+	if maxHops < 1 {
+		return 0, errors.New("maxHops must be at least 1")
+	}
+	if maxHops > maxSupportedHops && !r.allowDeepSearch {
+		return 0, fmt.Errorf("%w of %v", ErrMaxHopsExceeded, maxSupportedHops)
+	}
+	return maxHops, nil
+}
+
+// buildGraph collects the candidate tokens and reads the reserves of every pair between them,
+// extraTokens are searched in addition to the tokens of the pools
+func (r *OnChainV2Router) buildGraph(ctx context.Context, tokenIn, tokenOut common.Address, extraTokens []common.Address) (*routeGraph, error) {
+	usedTokens := make(map[common.Address]bool)
+	tokens := []common.Address{}
+	poolsCtx, span := startSpan(ctx, "pool discovery")
+	pools, err := r.poolProvider.GetPools(poolsCtx)
+	span.SetAttributes(Attribute{"routing.pools", len(pools)})
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(pools); i++ {
+		pair := pools[i]
+		if !usedTokens[pair.Token0] {
+			tokens = append(tokens, pair.Token0)
+			usedTokens[pair.Token0] = true
+		}
+		if !usedTokens[pair.Token1] {
+			tokens = append(tokens, pair.Token1)
+			usedTokens[pair.Token1] = true
+		}
+	}
+
+	for _, token := range append([]common.Address{tokenIn, tokenOut}, extraTokens...) {
+		if !usedTokens[token] {
+			tokens = append(tokens, token)
+			usedTokens[token] = true
+		}
+	}
+	sortAddresses(tokens)
+
+	graph := &routeGraph{
+		tokens:    tokens,
+		reserves:  make(map[pairKey][]hopReserves),
+		algorithm: r.pathfinder,
+	}
+	if reporter, ok := r.poolProvider.(thinPoolsReporter); ok {
+		graph.thin = reporter.PoolsBelowLiquidityThreshold()
+	}
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] == tokenIn {
+			graph.tokenInIndex = i
+		}
+		if tokens[i] == tokenOut {
+			graph.tokenOutIndex = i
+		}
+	}
+
+	// find every existing pair on every DEX first so reserves can be read in a single batch
+	type graphPair struct {
+		address common.Address
+		dex     DEXAdapter
+		i, j    int
+		// index into edges
+		edge int
+	}
+	candidates := []graphPair{}
+	for _, dex := range r.adapters() {
+		for i := 0; i < len(tokens); i++ {
+			for j := i + 1; j < len(tokens); j++ {
+				candidates = append(candidates, graphPair{dex: dex, i: i, j: j, edge: len(candidates)})
+			}
+		}
+	}
+	err = forEachParallel(ctx, len(candidates), r.parallelism, func(ctx context.Context, k int) error {
+		pair, err := candidates[k].dex.GetPair(ctx, tokens[candidates[k].i], tokens[candidates[k].j])
+		candidates[k].address = pair
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	graph.edges = make([]EdgeDiagnostic, len(candidates))
+	pairs := []graphPair{}
+	for k, pair := range candidates {
+		graph.edges[k] = EdgeDiagnostic{TokenA: tokens[pair.i], TokenB: tokens[pair.j], DEX: pair.dex.Name(), Pair: pair.address}
+		// the factory returns the zero address for pairs that were never created, they are
+		// not edges and their reserves are never read
+		if pair.address == (common.Address{}) {
+			graph.edges[k].Status = EdgeNoPair
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+	if r.poolTypeProvider != nil {
+		// a factory can hand back a contract that is not a V2 pair, reading its reserves would fail
+		poolTypes := make([]PoolType, len(pairs))
+		err = forEachParallel(ctx, len(pairs), r.parallelism, func(ctx context.Context, k int) error {
+			var err error
+			poolTypes[k], err = r.poolTypeProvider.GetPoolType(ctx, pairs[k].address)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		v2Pairs := []graphPair{}
+		for k, pair := range pairs {
+			if poolTypes[k] != PoolTypeV2 {
+				logDebug(ctx, r.logger, "skipping pair", "dex", pair.dex.Name(), "pair", pair.address, "pool_type", poolTypes[k])
+				graph.edges[pair.edge].Status = EdgeNotV2Pool
+				continue
+			}
+			v2Pairs = append(v2Pairs, pair)
+		}
+		pairs = v2Pairs
+	}
+
+	reserves0 := make([]*big.Int, len(pairs))
+	reserves1 := make([]*big.Int, len(pairs))
+	reservesCtx, span := startSpan(ctx, "reserve fetching", Attribute{"routing.pairs", len(pairs)})
+	if blockNumber := BlockNumberFromContext(ctx); blockNumber != nil {
+		span.SetAttributes(Attribute{"routing.block_number", blockNumber.Uint64()})
+	}
+	if batchProvider, ok := r.poolReservesProvider.(BatchPoolReservesProvider); ok {
+		addresses := make([]common.Address, len(pairs))
+		for k, pair := range pairs {
+			addresses[k] = pair.address
+		}
+		reserves0, reserves1, err = batchProvider.GetPoolReservesBatch(reservesCtx, addresses)
+	} else {
+		err = forEachParallel(reservesCtx, len(pairs), r.parallelism, func(ctx context.Context, k int) error {
+			var err error
+			reserves0[k], reserves1[k], err = r.poolReservesProvider.GetPoolReserves(ctx, pairs[k].address)
+			if errors.Is(err, ErrPairNotDeployed) {
+				return nil
+			}
+			return err
+		})
+	}
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	// pair addresses computed with CREATE2 may not have been deployed
+	deployed := 0
+	for k := range pairs {
+		if reserves0[k] == nil {
+			graph.edges[pairs[k].edge].Status = EdgeNotDeployed
+			continue
+		}
+		pairs[deployed], reserves0[deployed], reserves1[deployed] = pairs[k], reserves0[k], reserves1[k]
+		deployed++
+	}
+	logDebug(ctx, r.logger, "graph built", "pairs", len(pairs), "skipped", skippedEdges(graph.edges))
+	pairs, reserves0, reserves1 = pairs[:deployed], reserves0[:deployed], reserves1[:deployed]
+
+	// orienting may look up the pair's token0, which is another call per pair
+	oriented := make([]hopReserves, len(pairs))
+	err = forEachParallel(ctx, len(pairs), r.parallelism, func(ctx context.Context, k int) error {
+		reserveI, reserveJ, err := orientReserves(ctx, r.pairTokensProvider, pairs[k].address, tokens[pairs[k].i], tokens[pairs[k].j], reserves0[k], reserves1[k])
+		oriented[k] = hopReserves{reserveIn: reserveI, reserveOut: reserveJ, pair: pairs[k].address, dex: pairs[k].dex}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// caches liquidity for V2 Pairs in both swap directions
+	for k, pair := range pairs {
+		tokenI, tokenJ := tokens[pair.i], tokens[pair.j]
+		reversed := oriented[k]
+		reversed.reserveIn, reversed.reserveOut = oriented[k].reserveOut, oriented[k].reserveIn
+		graph.reserves[newPairKey(tokenI, tokenJ)] = append(graph.reserves[newPairKey(tokenI, tokenJ)], oriented[k])
+		graph.reserves[newPairKey(tokenJ, tokenI)] = append(graph.reserves[newPairKey(tokenJ, tokenI)], reversed)
+	}
+	graph.sortPools()
+	r.recordGraph(ctx, graph)
+	return graph, nil
+}
+
+// pinBlock reads every reserve of a quote at the same block so it reflects one consistent state,
+// a block already pinned by the caller is kept
+func (r *OnChainV2Router) pinBlock(ctx context.Context) (context.Context, error) {
+	if BlockNumberFromContext(ctx) != nil || r.blockNumberProvider == nil {
+		return ctx, nil
+	}
+	blockNumber, err := r.blockNumberProvider.BlockNumber(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	return WithBlockNumber(ctx, new(big.Int).SetUint64(blockNumber)), nil
+}
+
+// adaptiveMaxHops searches shallow for directly paired majors and deeper for long-tail tokens
+func (r *OnChainV2Router) adaptiveMaxHops(ctx context.Context, tokenIn, tokenOut common.Address) (int, error) {
+	if r.topTokensProvider == nil {
+		return maxSupportedHops, nil
+	}
+	topTokens, err := r.topTokensProvider.GetTopTokens(ctx)
+	if err != nil {
+		return 0, err
+	}
+	isMajor := make(map[common.Address]bool)
+	for _, token := range topTokens {
+		isMajor[token] = true
+	}
+	switch {
+	case isMajor[tokenIn] && isMajor[tokenOut]:
+		pair, err := r.tradingPairProvider.GetTradingPair(ctx, tokenIn, tokenOut)
+		if err != nil {
+			return 0, err
+		}
+		if pair != (common.Address{}) {
+			return 2, nil
+		}
+		return 3, nil
+	case isMajor[tokenIn] || isMajor[tokenOut]:
+		// one long-tail hop into the majors, then at most two hops between majors
+		return 3, nil
+	default:
+		return maxSupportedHops, nil
+	}
+}
+
+func reverse(arr []common.Address) {
+	for i := 0; i < len(arr)/2; i++ {
+		arr[i], arr[len(arr)-1-i] = arr[len(arr)-1-i], arr[i]
+	}
+}
+
+type PoolReservesProvider interface {
+	// returns reserve0, reserve1 in the order of the lexically sorted token addresses in the pair
+	GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error)
+}
+
+type OnChainPoolReservesProvider struct {
+	rpcClient *ethclient.Client
+}
+
+func NewOnChainPoolReservesProvider(rpcClient *ethclient.Client) *OnChainPoolReservesProvider {
+	return &OnChainPoolReservesProvider{rpcClient: rpcClient}
+}
+
+type Pool struct {
+	Token0   common.Address `json:"token0"`
+	Token1   common.Address `json:"token1"`
+	Contract common.Address `json:"contract"`
+}
+
+type PoolsProvider interface {
+	// should return only pools with $500k liquidity or more
+	GetPools(ctx context.Context) ([]Pool, error)
+}
+
+type OnChainPoolsProvider struct {
+	tradingPairProvider TradingPairProvider
+	topTokensProvider   TopTokensProvider
+	// optional, when set with tokenDecimalsProvider pools below minLiquidityUSD are dropped
+	poolReservesProvider  PoolReservesProvider
+	tokenDecimalsProvider TokenDecimalsProvider
+	// defaults to defaultMinLiquidityUSD
+	minLiquidityUSD float64
+	// valued at $1 to price the pools, defaults to Mainnet's
+	stablecoins []common.Address
+	// concurrent pair lookups, defaults to defaultParallelism
+	parallelism int
+
+	mu sync.Mutex
+	// pools the last GetPools dropped for their liquidity
+	thin []Pool
+}
+
+// NewOnChainPoolsProvider returns the pools between the top tokens holding at least minLiquidityUSD,
+// 0 for defaultMinLiquidityUSD
+func NewOnChainPoolsProvider(tradingPairProvider TradingPairProvider, topTokensProvider TopTokensProvider, poolReservesProvider PoolReservesProvider, tokenDecimalsProvider TokenDecimalsProvider, minLiquidityUSD float64) *OnChainPoolsProvider {
+	return &OnChainPoolsProvider{
+		tradingPairProvider:   tradingPairProvider,
+		topTokensProvider:     topTokensProvider,
+		poolReservesProvider:  poolReservesProvider,
+		tokenDecimalsProvider: tokenDecimalsProvider,
+		minLiquidityUSD:       minLiquidityUSD,
+	}
+}
+
+// SetChain values liquidity against the stablecoins of chain instead of Mainnet's
+func (p *OnChainPoolsProvider) SetChain(chain Chain) {
+	p.stablecoins = chain.Stablecoins
+}
+
+func (p *OnChainPoolsProvider) GetPools(ctx context.Context) ([]Pool, error) {
+	tokens, err := p.topTokensProvider.GetTopTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []Pool{}
+	for token := range tokens {
+		for otherToken := token + 1; otherToken < len(tokens); otherToken++ {
+			if tokens[token] == tokens[otherToken] {
+				continue
+			}
+			candidates = append(candidates, Pool{Token0: tokens[token], Token1: tokens[otherToken]})
+		}
+	}
+	// pair lookups are independent, every goroutine writes only its own pool
+	err = forEachParallel(ctx, len(candidates), p.parallelism, func(ctx context.Context, i int) error {
+		pairAddress, err := p.tradingPairProvider.GetTradingPair(ctx, candidates[i].Token0, candidates[i].Token1)
+		if err != nil {
+			return err
+		}
+		candidates[i].Contract = pairAddress
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.poolReservesProvider == nil || p.tokenDecimalsProvider == nil {
+		return candidates, nil
+	}
+	filter := liquidityFilter{
+		poolReservesProvider:  p.poolReservesProvider,
+		tokenDecimalsProvider: p.tokenDecimalsProvider,
+		minLiquidityUSD:       p.minLiquidityUSD,
+		stablecoins:           p.stablecoins,
+		parallelism:           p.parallelism,
+	}
+	pools, thin, err := filter.filter(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.thin = thin
+	p.mu.Unlock()
+	return pools, nil
+}
+
+// PoolsBelowLiquidityThreshold returns the pools the last GetPools dropped for holding less than
+// minLiquidityUSD
+func (p *OnChainPoolsProvider) PoolsBelowLiquidityThreshold() []Pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.thin
+}
+
+type ExchangeRateProvider interface {
+	// returns the amount of tokenB the direct pair pays for amountIn of tokenA, including the fee and price impact
+	GetQuote(ctx context.Context, tokenA, tokenB common.Address, amountIn *big.Int) (*big.Int, error)
+	// returns the exact mid-price of tokenA in tokenB adjusted for decimals, ignoring trade size
+	GetExchangeRate(ctx context.Context, tokenA, tokenB common.Address) (*big.Rat, error)
+}
+
+type OnChainExchangeRateProvider struct {
+	pairProvider          TradingPairProvider
+	poolReservesProvider  PoolReservesProvider
+	tokenDecimalsProvider TokenDecimalsProvider
+	pairTokensProvider    PairTokensProvider
+}
+
+// NewOnChainExchangeRateProvider prices direct pairs, pairTokensProvider may be nil
+func NewOnChainExchangeRateProvider(pairProvider TradingPairProvider, poolReservesProvider PoolReservesProvider, tokenDecimalsProvider TokenDecimalsProvider, pairTokensProvider PairTokensProvider) *OnChainExchangeRateProvider {
+	return &OnChainExchangeRateProvider{
+		pairProvider:          pairProvider,
+		poolReservesProvider:  poolReservesProvider,
+		tokenDecimalsProvider: tokenDecimalsProvider,
+		pairTokensProvider:    pairTokensProvider,
+	}
+}
+
+func (f *OnChainExchangeRateProvider) GetQuote(ctx context.Context, tokenA, tokenB common.Address, amountIn *big.Int) (*big.Int, error) {
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, errors.New("amountIn must be positive")
+	}
+	reserveA, reserveB, err := f.pairReserves(ctx, tokenA, tokenB)
+	if err != nil {
+		return nil, err
+	}
+	return getAmountOut(amountIn, reserveA, reserveB)
+}
+
+func (f *OnChainExchangeRateProvider) GetExchangeRate(ctx context.Context, tokenA, tokenB common.Address) (*big.Rat, error) {
+	reserveA, reserveB, err := f.pairReserves(ctx, tokenA, tokenB)
+	if err != nil {
+		return nil, err
+	}
+	if reserveA.Sign() == 0 {
+		return nil, fmt.Errorf("%w: no %v in the pair with %v", ErrInsufficientLiquidity, tokenA.String(), tokenB.String())
+	}
+	decimalsA, err := f.tokenDecimalsProvider.GetTokenDecimals(ctx, tokenA)
+	if err != nil {
+		return nil, fmt.Errorf("token decimals: %w", err)
+	}
+	decimalsB, err := f.tokenDecimalsProvider.GetTokenDecimals(ctx, tokenB)
+	if err != nil {
+		return nil, fmt.Errorf("token decimals: %w", err)
+	}
+	return unitPrice(reserveA, decimalsA, reserveB, decimalsB), nil
+}
+
+// pairReserves returns the reserves of the direct tokenA/tokenB pair as (reserveA, reserveB)
+func (f *OnChainExchangeRateProvider) pairReserves(ctx context.Context, tokenA, tokenB common.Address) (*big.Int, *big.Int, error) {
+	if tokenA == tokenB {
+		return nil, nil, fmt.Errorf("%w: %v", ErrSameToken, tokenA.String())
+	}
+	pairAddress, err := f.pairProvider.GetTradingPair(ctx, tokenA, tokenB)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pairAddress == (common.Address{}) {
+		return nil, nil, fmt.Errorf("%w between %v and %v", ErrPairNotFound, tokenA.String(), tokenB.String())
+	}
+	reserve0, reserve1, err := f.poolReservesProvider.GetPoolReserves(ctx, pairAddress)
+	if errors.Is(err, ErrPairNotDeployed) {
+		return nil, nil, fmt.Errorf("%w between %v and %v: %v is not deployed", ErrPairNotFound, tokenA.String(), tokenB.String(), pairAddress.String())
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return orientReserves(ctx, f.pairTokensProvider, pairAddress, tokenA, tokenB, reserve0, reserve1)
+}
+
+type TokenDecimalsProvider interface {
+	GetTokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error)
+}
+
+type OnChainTokenDecimalsProvider struct {
+	rpcClient *ethclient.Client
+}
+
+func NewOnChainTokenDecimalsProvider(rpcClient *ethclient.Client) *OnChainTokenDecimalsProvider {
+	return &OnChainTokenDecimalsProvider{rpcClient: rpcClient}
+}
+
+func (f *OnChainTokenDecimalsProvider) GetTokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	caller, err := NewMainCaller(tokenAddress, f.rpcClient)
+	if err != nil {
+		return 0, fmt.Errorf("binding token %v: %w", tokenAddress.String(), err)
+	}
+	callOpts := newCallOpts(ctx)
+	decimals, err := caller.Decimals(callOpts)
+	if err != nil {
+		return 0, fmt.Errorf("reading decimals of %v: %w", tokenAddress.String(), err)
+	}
+	return decimals, nil
+}
+
+func (f *OnChainPoolReservesProvider) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	caller, err := NewMainCaller(pairAddress, f.rpcClient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("binding pair %v: %w", pairAddress.String(), err)
+	}
+	callOpts := newCallOpts(ctx)
+	resp, err := caller.GetReserves(callOpts)
+	if errors.Is(err, bind.ErrNoCode) {
+		return nil, nil, ErrPairNotDeployed
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading reserves of pair %v: %w", pairAddress.String(), err)
+	}
+	return resp.Reserve0, resp.Reserve1, nil
+}
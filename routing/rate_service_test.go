@@ -0,0 +1,704 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/mock"
+
+	"v2Routing/v2math"
+)
+
+type TradingPairProviderMock struct {
+	mock.Mock
+}
+
+func (f *TradingPairProviderMock) GetTradingPair(ctx context.Context, tokenA common.Address, tokenB common.Address) (common.Address, error) {
+	args := f.Called(ctx, tokenA, mock.Anything)
+	return args.Get(0).(common.Address), args.Error(1)
+}
+
+type PoolReservesProviderMock struct {
+	mock.Mock
+}
+
+func (f *PoolReservesProviderMock) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	args := f.Called(ctx, pairAddress)
+	return args.Get(0).(*big.Int), args.Get(1).(*big.Int), args.Error(2)
+}
+
+type TokenDecimalsProviderMock struct {
+	mock.Mock
+}
+
+func (f *TokenDecimalsProviderMock) GetTokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	args := f.Called(ctx, tokenAddress)
+	if len(args) == 0 {
+		return 0, nil
+	}
+	return args.Get(0).(uint8), args.Error(1)
+}
+
+type PairTokensProviderMock struct {
+	mock.Mock
+}
+
+func (f *PairTokensProviderMock) GetPairTokens(ctx context.Context, pairAddress common.Address) (common.Address, common.Address, error) {
+	args := f.Called(ctx, pairAddress)
+	return args.Get(0).(common.Address), args.Get(1).(common.Address), args.Error(2)
+}
+
+func TestGetExchangeRate(t *testing.T) {
+	ctx := context.Background()
+	pairProvider := &TradingPairProviderMock{}
+	tokenDecimalsProvider := &TokenDecimalsProviderMock{}
+	poolReservesProvider := &PoolReservesProviderMock{}
+	exchangeRateProvider := &OnChainExchangeRateProvider{
+		pairProvider:          pairProvider,
+		poolReservesProvider:  poolReservesProvider,
+		tokenDecimalsProvider: tokenDecimalsProvider,
+	}
+
+	// Mock the calls to the Providers
+	pairProvider.On("GetTradingPair", ctx, common.HexToAddress(WETH), common.HexToAddress(USDC)).Return(common.HexToAddress(WETH_USDC), nil)
+	tokenDecimalsProvider.On("GetTokenDecimals", ctx, mock.Anything).Return(uint8(18), nil)
+	poolReservesProvider.On("GetPoolReserves", ctx, common.HexToAddress(WETH_USDC)).Return(big.NewInt(95), big.NewInt(19), nil)
+
+	// Call the method being tested
+	gotRate, err := exchangeRateProvider.GetExchangeRate(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDC))
+	if err != nil {
+		t.Errorf("got error %v", err)
+	}
+
+	// Assert that the Providers were called
+	pairProvider.AssertCalled(t, "GetTradingPair", ctx, common.HexToAddress(WETH), common.HexToAddress(USDC))
+	poolReservesProvider.AssertCalled(t, "GetPoolReserves", ctx, common.HexToAddress(WETH_USDC))
+	tokenDecimalsProvider.AssertCalled(t, "GetTokenDecimals", ctx, common.HexToAddress(WETH))
+	tokenDecimalsProvider.AssertCalled(t, "GetTokenDecimals", ctx, common.HexToAddress(USDC))
+
+	// Assert that the result is correct
+	wantRate := big.NewRat(5, 1)
+	if gotRate.Cmp(wantRate) != 0 {
+		t.Errorf("got %v want %v", gotRate, wantRate)
+	}
+}
+
+func TestGetExchangeRateDecimalsError(t *testing.T) {
+	ctx := context.Background()
+	pairProvider := &TradingPairProviderMock{}
+	tokenDecimalsProvider := &TokenDecimalsProviderMock{}
+	poolReservesProvider := &PoolReservesProviderMock{}
+	exchangeRateProvider := &OnChainExchangeRateProvider{
+		pairProvider:          pairProvider,
+		poolReservesProvider:  poolReservesProvider,
+		tokenDecimalsProvider: tokenDecimalsProvider,
+	}
+
+	decimalsErr := errors.New("decimals reverted")
+	pairProvider.On("GetTradingPair", ctx, common.HexToAddress(WETH), common.HexToAddress(USDC)).Return(common.HexToAddress(WETH_USDC), nil)
+	tokenDecimalsProvider.On("GetTokenDecimals", ctx, common.HexToAddress(WETH)).Return(uint8(18), nil)
+	tokenDecimalsProvider.On("GetTokenDecimals", ctx, common.HexToAddress(USDC)).Return(uint8(0), decimalsErr)
+	poolReservesProvider.On("GetPoolReserves", ctx, common.HexToAddress(WETH_USDC)).Return(big.NewInt(95), big.NewInt(19), nil)
+
+	_, err := exchangeRateProvider.GetExchangeRate(ctx, common.HexToAddress(WETH), common.HexToAddress(USDC))
+	if !errors.Is(err, decimalsErr) {
+		t.Errorf("got error %v want %v", err, decimalsErr)
+	}
+}
+
+// v2GraphFake serves pools, pair addresses, reserves and decimals from memory
+type v2GraphFake struct {
+	pools    []Pool
+	reserves map[common.Address][2]*big.Int
+}
+
+func fakePairAddress(tokenA, tokenB common.Address) common.Address {
+	token0, token1 := v2math.SortTokens(tokenA, tokenB)
+	return common.BytesToAddress(crypto.Keccak256(token0.Bytes(), token1.Bytes()))
+}
+
+func (g *v2GraphFake) addPool(tokenA, tokenB common.Address, reserveA, reserveB int64) {
+	if g.reserves == nil {
+		g.reserves = make(map[common.Address][2]*big.Int)
+	}
+	pair := fakePairAddress(tokenA, tokenB)
+	g.pools = append(g.pools, Pool{Token0: tokenA, Token1: tokenB, Contract: pair})
+	if token0, _ := v2math.SortTokens(tokenA, tokenB); token0 != tokenA {
+		reserveA, reserveB = reserveB, reserveA
+	}
+	g.reserves[pair] = [2]*big.Int{big.NewInt(reserveA), big.NewInt(reserveB)}
+}
+
+func (g *v2GraphFake) GetPools(ctx context.Context) ([]Pool, error) {
+	return g.pools, nil
+}
+
+func (g *v2GraphFake) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	pair := fakePairAddress(tokenA, tokenB)
+	if _, ok := g.reserves[pair]; !ok {
+		return common.Address{}, nil
+	}
+	return pair, nil
+}
+
+func (g *v2GraphFake) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	reserves, ok := g.reserves[pairAddress]
+	if !ok {
+		return big.NewInt(0), big.NewInt(0), nil
+	}
+	return reserves[0], reserves[1], nil
+}
+
+func (g *v2GraphFake) GetPairTokens(ctx context.Context, pairAddress common.Address) (common.Address, common.Address, error) {
+	for _, pool := range g.pools {
+		if pool.Contract != pairAddress {
+			continue
+		}
+		token0, token1 := v2math.SortTokens(pool.Token0, pool.Token1)
+		return token0, token1, nil
+	}
+	return common.Address{}, common.Address{}, fmt.Errorf("unknown pair %v", pairAddress)
+}
+
+func (g *v2GraphFake) GetTokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	return 18, nil
+}
+
+func newFakeRouter(graph *v2GraphFake) *OnChainV2Router {
+	return &OnChainV2Router{
+		poolProvider:         graph,
+		tradingPairProvider:  graph,
+		poolReservesProvider: graph,
+	}
+}
+
+func TestRouteWithMetadata(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1100000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 100000, 150000000)
+	router := newFakeRouter(graph)
+
+	// the direct pool has the better mid price but is too shallow for the trade
+	amountIn := big.NewInt(1000)
+	gotAmount, gotPath, metadata, err := router.RouteWithMetadata(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(DAI), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	wantPath := []common.Address{common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)}
+	if len(gotPath) != len(wantPath) {
+		t.Fatalf("got path %v want %v", gotPath, wantPath)
+	}
+	for i := range wantPath {
+		if gotPath[i] != wantPath[i] {
+			t.Errorf("got path %v want %v", gotPath, wantPath)
+		}
+	}
+	amounts, err := getAmountsOut(amountIn, []hopReserves{
+		{reserveIn: big.NewInt(1000000), reserveOut: big.NewInt(2000000000)},
+		{reserveIn: big.NewInt(1000000000), reserveOut: big.NewInt(1100000000)},
+	})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if wantAmount := amounts[len(amounts)-1]; gotAmount.Cmp(wantAmount) != 0 {
+		t.Errorf("got %v want %v", gotAmount, wantAmount)
+	}
+
+	// hop 1 only prices edges out of WETH, hops 2 and 3 price all 6 edges
+	if metadata.TokensConsidered != 3 {
+		t.Errorf("got %d tokens considered want 3", metadata.TokensConsidered)
+	}
+	if metadata.EdgesEvaluated != 14 {
+		t.Errorf("got %d edges evaluated want 14", metadata.EdgesEvaluated)
+	}
+	if metadata.CacheHits != 14 {
+		t.Errorf("got %d cache hits want 14", metadata.CacheHits)
+	}
+	if metadata.PrunedCandidates != 4 {
+		t.Errorf("got %d pruned candidates want 4", metadata.PrunedCandidates)
+	}
+}
+
+func TestRouteExactOut(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1100000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 100000, 150000000)
+	router := newFakeRouter(graph)
+
+	amountOut := big.NewInt(2000000)
+	quote, err := router.RouteExactOut(context.Background(), common.HexToAddress(WETH), common.HexToAddress(DAI), amountOut, 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	gotAmountIn, gotPath := quote.AmountIn, quote.Path()
+	wantPath := []common.Address{common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)}
+	if len(gotPath) != len(wantPath) {
+		t.Fatalf("got path %v want %v", gotPath, wantPath)
+	}
+	for i := range wantPath {
+		if gotPath[i] != wantPath[i] {
+			t.Errorf("got path %v want %v", gotPath, wantPath)
+		}
+	}
+	amounts, err := getAmountsIn(amountOut, []hopReserves{
+		{reserveIn: big.NewInt(1000000), reserveOut: big.NewInt(2000000000)},
+		{reserveIn: big.NewInt(1000000000), reserveOut: big.NewInt(1100000000)},
+	})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if gotAmountIn.Cmp(amounts[0]) != 0 {
+		t.Errorf("got %v want %v", gotAmountIn, amounts[0])
+	}
+
+	// paying the quoted input on the exact-in side delivers at least amountOut
+	quote, err = router.Route(context.Background(), gotAmountIn, common.HexToAddress(WETH), common.HexToAddress(DAI), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if quote.AmountOut.Cmp(amountOut) < 0 {
+		t.Errorf("got %v out for %v in, want at least %v", quote.AmountOut, gotAmountIn, amountOut)
+	}
+
+	// no pool holds enough DAI to pay out more than its reserves
+	_, err = router.RouteExactOut(context.Background(), common.HexToAddress(WETH), common.HexToAddress(DAI), big.NewInt(2000000000), 3)
+	var noRouteErr *NoRouteError
+	if !errors.As(err, &noRouteErr) || len(noRouteErr.Reasons) != 1 || noRouteErr.Reasons[0] != InsufficientLiquidity {
+		t.Errorf("got error %v want %v", err, InsufficientLiquidity)
+	}
+}
+
+func TestRouteTieBreakIsDeterministic(t *testing.T) {
+	weth, usdc, dai, usdt := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI), common.HexToAddress(USDT)
+	// the routes through DAI and USDT pay the same, DAI has the lower address
+	forward, backward := &v2GraphFake{}, &v2GraphFake{}
+	for _, middle := range []common.Address{dai, usdt} {
+		forward.addPool(weth, middle, 1000000, 2000000000)
+		forward.addPool(middle, usdc, 2000000000, 2000000000)
+	}
+	for _, middle := range []common.Address{usdt, dai} {
+		backward.addPool(middle, usdc, 2000000000, 2000000000)
+		backward.addPool(weth, middle, 1000000, 2000000000)
+	}
+	want := fmt.Sprint([]common.Address{weth, dai, usdc})
+	for _, graph := range []*v2GraphFake{forward, backward} {
+		quote, err := newFakeRouter(graph).Route(context.Background(), big.NewInt(1000), weth, usdc, 2)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if got := fmt.Sprint(quote.Path()); got != want {
+			t.Errorf("got path %v want %v", got, want)
+		}
+	}
+}
+
+func TestAdaptiveMaxHops(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 100, 200000)
+	router := newFakeRouter(graph)
+	router.topTokensProvider = &StaticTopTokensProvider{}
+
+	tests := []struct {
+		tokenIn, tokenOut string
+		wantHops          int
+	}{
+		{WETH, USDC, 2},
+		{WETH, DAI, 3},
+		{WETH, PAXG, 3},
+		{WISE, DAI, 3},
+		{PAXG, WISE, maxSupportedHops},
+	}
+	for _, test := range tests {
+		gotHops, err := router.adaptiveMaxHops(context.Background(), common.HexToAddress(test.tokenIn), common.HexToAddress(test.tokenOut))
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if gotHops != test.wantHops {
+			t.Errorf("%v -> %v: got %d hops want %d", test.tokenIn, test.tokenOut, gotHops, test.wantHops)
+		}
+	}
+}
+
+func TestRouteNoRouteReasons(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 100, 200000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000, 1100)
+	graph.addPool(common.HexToAddress(DAI), common.HexToAddress(UNI), 1000, 200)
+	graph.addPool(common.HexToAddress(PAXG), common.HexToAddress(WBTC), 0, 0)
+	router := newFakeRouter(graph)
+
+	tests := []struct {
+		tokenIn, tokenOut string
+		maxHops           int
+		wantReasons       []NoRouteReason
+	}{
+		{WISE, USDC, 3, []NoRouteReason{NoPoolsForTokenIn}},
+		{PAXG, USDC, 3, []NoRouteReason{InsufficientLiquidity}},
+		{WETH, UNI, 2, []NoRouteReason{MaxHopsTooSmall}},
+	}
+	for _, test := range tests {
+		_, err := router.Route(context.Background(), big.NewInt(10), common.HexToAddress(test.tokenIn), common.HexToAddress(test.tokenOut), test.maxHops)
+		var noRouteErr *NoRouteError
+		if !errors.As(err, &noRouteErr) || !errors.Is(err, ErrNoRoute) {
+			t.Fatalf("%v -> %v: got error %v want NoRouteError", test.tokenIn, test.tokenOut, err)
+		}
+		if wantLiquidity := test.wantReasons[0] == InsufficientLiquidity; errors.Is(err, ErrInsufficientLiquidity) != wantLiquidity {
+			t.Errorf("%v -> %v: got error %v, matching ErrInsufficientLiquidity should be %v", test.tokenIn, test.tokenOut, err, wantLiquidity)
+		}
+		if len(noRouteErr.Reasons) != len(test.wantReasons) {
+			t.Fatalf("%v -> %v: got reasons %v want %v", test.tokenIn, test.tokenOut, noRouteErr.Reasons, test.wantReasons)
+		}
+		for i := range test.wantReasons {
+			if noRouteErr.Reasons[i] != test.wantReasons[i] {
+				t.Errorf("%v -> %v: got reasons %v want %v", test.tokenIn, test.tokenOut, noRouteErr.Reasons, test.wantReasons)
+			}
+		}
+	}
+
+	// the same pair routes fine once enough hops are allowed
+	if _, err := router.Route(context.Background(), big.NewInt(10), common.HexToAddress(WETH), common.HexToAddress(UNI), 3); err != nil {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestRouteNoRoutePoolsBelowLiquidityThreshold(t *testing.T) {
+	weth, usdc, dai, wbtc := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI), common.HexToAddress(WBTC)
+	graph := &v2GraphFake{}
+	// $2M a side
+	graph.addPool(weth, usdc, 1000, 2000000)
+	// WBTC's only pool holds $200k a side and is dropped by the pools provider
+	graph.addPool(dai, wbtc, 200000, 10)
+	router := newFakeRouter(graph)
+	router.poolProvider = NewOnChainPoolsProvider(graph, &StaticTopTokensProvider{}, graph, wholeTokenDecimals{}, 0)
+
+	tests := []struct {
+		tokenIn, tokenOut common.Address
+		wantReasons       []NoRouteReason
+	}{
+		{wbtc, usdc, []NoRouteReason{NoPoolsForTokenIn, PoolsBelowLiquidityThreshold}},
+		{weth, dai, []NoRouteReason{NoPoolsForTokenOut, PoolsBelowLiquidityThreshold}},
+	}
+	for _, test := range tests {
+		_, err := router.Route(context.Background(), big.NewInt(1), test.tokenIn, test.tokenOut, 3)
+		var noRouteErr *NoRouteError
+		if !errors.As(err, &noRouteErr) {
+			t.Fatalf("%v -> %v: got error %v want NoRouteError", test.tokenIn, test.tokenOut, err)
+		}
+		if fmt.Sprint(noRouteErr.Reasons) != fmt.Sprint(test.wantReasons) {
+			t.Errorf("%v -> %v: got reasons %v want %v", test.tokenIn, test.tokenOut, noRouteErr.Reasons, test.wantReasons)
+		}
+	}
+}
+
+func TestRouteMaxPriceImpact(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	graph := &v2GraphFake{}
+	// the direct pool pays the most but moves its price by about 1%, the deep pools through DAI
+	// hardly move
+	graph.addPool(weth, usdc, 1000, 2200000)
+	graph.addPool(weth, dai, 1000000, 2000000000)
+	graph.addPool(dai, usdc, 1000000000, 1000000000)
+	router := newFakeRouter(graph)
+
+	quote, err := router.Route(context.Background(), big.NewInt(10), weth, usdc, 2)
+	if err != nil || len(quote.Hops) != 1 {
+		t.Fatalf("got quote %+v error %v want the direct pool", quote, err)
+	}
+	exactOut, err := router.RouteExactOut(context.Background(), weth, usdc, big.NewInt(20000), 2)
+	if err != nil || len(exactOut.Hops) != 1 {
+		t.Fatalf("got quote %+v error %v want the direct pool", exactOut, err)
+	}
+	router.maxPriceImpact = 0.005
+	quote, err = router.Route(context.Background(), big.NewInt(10), weth, usdc, 2)
+	if err != nil || len(quote.Hops) != 2 || quote.PriceImpact.Cmp(big.NewRat(5, 1000)) > 0 {
+		t.Fatalf("got quote %+v error %v want the route through DAI within the cap", quote, err)
+	}
+	exactOut, err = router.RouteExactOut(context.Background(), weth, usdc, big.NewInt(20000), 2)
+	if err != nil || len(exactOut.Hops) != 2 {
+		t.Errorf("got quote %+v error %v want the exact-out route through DAI", exactOut, err)
+	}
+
+	router.maxPriceImpact = 0.000001
+	_, err = router.Route(context.Background(), big.NewInt(10), weth, usdc, 2)
+	var noRouteErr *NoRouteError
+	if !errors.As(err, &noRouteErr) || len(noRouteErr.Reasons) != 1 || noRouteErr.Reasons[0] != PriceImpactCapExceeded {
+		t.Errorf("got error %v want PriceImpactCapExceeded", err)
+	}
+}
+
+type staticBlockNumberProvider uint64
+
+func (b staticBlockNumberProvider) BlockNumber(ctx context.Context) (uint64, error) {
+	return uint64(b), nil
+}
+
+// blockRecordingReservesProvider records the block every reserves read was pinned to
+type blockRecordingReservesProvider struct {
+	PoolReservesProvider
+	mu         sync.Mutex
+	seenBlocks map[string]int
+}
+
+func (p *blockRecordingReservesProvider) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	p.mu.Lock()
+	p.seenBlocks[fmt.Sprint(BlockNumberFromContext(ctx))]++
+	p.mu.Unlock()
+	return p.PoolReservesProvider.GetPoolReserves(ctx, pairAddress)
+}
+
+func TestRoutePinsReservesToOneBlock(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 100, 200000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000, 1100)
+	reservesProvider := &blockRecordingReservesProvider{PoolReservesProvider: graph, seenBlocks: map[string]int{}}
+	router := newFakeRouter(graph)
+	router.poolReservesProvider = reservesProvider
+	router.blockNumberProvider = staticBlockNumberProvider(16000000)
+
+	_, _, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(10), common.HexToAddress(WETH), common.HexToAddress(DAI), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if metadata.BlockNumber == nil || metadata.BlockNumber.Uint64() != 16000000 {
+		t.Errorf("got block %v want 16000000", metadata.BlockNumber)
+	}
+	if len(reservesProvider.seenBlocks) != 1 || reservesProvider.seenBlocks["16000000"] == 0 {
+		t.Errorf("got reads at blocks %v want only 16000000", reservesProvider.seenBlocks)
+	}
+
+	// a block pinned by the caller takes precedence over the latest block
+	ctx := WithBlockNumber(context.Background(), big.NewInt(15000000))
+	_, _, metadata, err = router.RouteWithMetadata(ctx, big.NewInt(10), common.HexToAddress(WETH), common.HexToAddress(DAI), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if metadata.BlockNumber.Uint64() != 15000000 {
+		t.Errorf("got block %v want 15000000", metadata.BlockNumber)
+	}
+}
+
+func TestDeepRouteResumesFromCheckpoint(t *testing.T) {
+	graph := &v2GraphFake{}
+	tokens := []string{WETH, USDC, DAI, UNI, WBTC, USDT, PAXG, WISE}
+	// every pair is connected, but only walking the chain in order doubles the amount,
+	// so every extra hop improves the output and forces a deep search
+	for i := range tokens {
+		for j := i + 1; j < len(tokens); j++ {
+			if j == i+1 {
+				graph.addPool(common.HexToAddress(tokens[i]), common.HexToAddress(tokens[j]), 1000000000, 2000000000)
+			} else {
+				graph.addPool(common.HexToAddress(tokens[i]), common.HexToAddress(tokens[j]), 1000000000, 1000000000)
+			}
+		}
+	}
+
+	router := newFakeRouter(graph)
+	amountIn := big.NewInt(1000)
+	if _, err := router.Route(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(WISE), 7); err == nil {
+		t.Fatalf("expected deep searches to require allowDeepSearch")
+	}
+	router.allowDeepSearch = true
+	wantAmount, wantPath, fullMetadata, err := router.RouteWithMetadata(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(WISE), 7)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(wantPath) != len(tokens) {
+		t.Fatalf("got path %v want the full chain", wantPath)
+	}
+
+	router.checkpointDir = t.TempDir()
+	// interrupt the search right after the checkpoint of hop 3 is written
+	ctx, cancel := context.WithCancel(context.Background())
+	router.saveCheckpoint = func(c *routeCheckpoint, path string) error {
+		if c.Hop == 3 {
+			cancel()
+		}
+		return c.save(path)
+	}
+	if _, err := router.Route(ctx, amountIn, common.HexToAddress(WETH), common.HexToAddress(WISE), 7); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v want context.Canceled", err)
+	}
+	// a search charging gas per swap ranks routes differently and cannot continue it
+	checkpoint, err := loadRouteCheckpoint(routeCheckpointPath(router.checkpointDir, exactIn, common.HexToAddress(WETH), common.HexToAddress(WISE)))
+	if err != nil || !checkpoint.matches(checkpoint.Tokens, checkpoint.BlockNumber, exactIn, amountIn, nil, 7) {
+		t.Fatalf("got checkpoint %+v error %v want one of the interrupted search", checkpoint, err)
+	}
+	if checkpoint.matches(checkpoint.Tokens, checkpoint.BlockNumber, exactIn, amountIn, big.NewInt(1), 7) {
+		t.Errorf("got a checkpoint without hop cost matching a search with one")
+	}
+
+	router.saveCheckpoint = nil
+	gotAmount, gotPath, resumedMetadata, err := router.RouteWithMetadata(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(WISE), 7)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if gotAmount.Cmp(wantAmount) != 0 || len(gotPath) != len(wantPath) {
+		t.Errorf("got %v via %v want %v via %v", gotAmount, gotPath, wantAmount, wantPath)
+	}
+	if resumedMetadata.EdgesEvaluated >= fullMetadata.EdgesEvaluated {
+		t.Errorf("got %d edges evaluated after resuming, want fewer than %d", resumedMetadata.EdgesEvaluated, fullMetadata.EdgesEvaluated)
+	}
+	if _, err := os.Stat(routeCheckpointPath(router.checkpointDir, exactIn, common.HexToAddress(WETH), common.HexToAddress(WISE))); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint to be removed after a finished search")
+	}
+}
+
+func TestRouteErrors(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000, 2000000)
+	router := newFakeRouter(graph)
+	router.strictReserves = true
+	exchangeRates := NewOnChainExchangeRateProvider(graph, graph, graph, nil)
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"same token", routeError(router.Route(context.Background(), big.NewInt(10), weth, weth, 1)), ErrSameToken},
+		{"too many hops", routeError(router.Route(context.Background(), big.NewInt(10), weth, usdc, maxSupportedHops+1)), ErrMaxHopsExceeded},
+		{"no pair", quoteError(exchangeRates.GetQuote(context.Background(), weth, dai, big.NewInt(10))), ErrPairNotFound},
+		{"no route", routeError(router.Route(context.Background(), big.NewInt(10), weth, dai, 1)), ErrNoRoute},
+		// strict reserves refuse trades of a large share of the pool
+		{"shallow pool", routeError(router.Route(context.Background(), big.NewInt(500), weth, usdc, 1)), ErrInsufficientLiquidity},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%v: got error %v want %v", test.name, test.err, test.want)
+		}
+	}
+}
+
+func routeError(quote *Quote, err error) error {
+	return err
+}
+
+func quoteError(amount *big.Int, err error) error {
+	return err
+}
+
+func TestGetQuote(t *testing.T) {
+	ctx := context.Background()
+	pairProvider := &TradingPairProviderMock{}
+	poolReservesProvider := &PoolReservesProviderMock{}
+	exchangeRateProvider := &OnChainExchangeRateProvider{
+		pairProvider:         pairProvider,
+		poolReservesProvider: poolReservesProvider,
+	}
+
+	// USDC sorts before WETH, so reserve0 is USDC
+	pairProvider.On("GetTradingPair", ctx, common.HexToAddress(WETH), common.HexToAddress(USDC)).Return(common.HexToAddress(WETH_USDC), nil)
+	poolReservesProvider.On("GetPoolReserves", ctx, common.HexToAddress(WETH_USDC)).Return(big.NewInt(2000000000), big.NewInt(1000000), nil)
+
+	// the mid-price pays 2000 USDC per WETH, larger trades get less per unit
+	small, err := exchangeRateProvider.GetQuote(ctx, common.HexToAddress(WETH), common.HexToAddress(USDC), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if small.Cmp(big.NewInt(1993)) != 0 {
+		t.Errorf("got %v want 1993", small)
+	}
+	large, err := exchangeRateProvider.GetQuote(ctx, common.HexToAddress(WETH), common.HexToAddress(USDC), big.NewInt(100000))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	wantLarge, _ := getAmountOut(big.NewInt(100000), big.NewInt(1000000), big.NewInt(2000000000))
+	if large.Cmp(wantLarge) != 0 {
+		t.Errorf("got %v want %v", large, wantLarge)
+	}
+	if new(big.Int).Mul(small, big.NewInt(100000)).Cmp(large) <= 0 {
+		t.Errorf("got %v for 100000 want less than 100000 times %v", large, small)
+	}
+
+	if _, err := exchangeRateProvider.GetQuote(ctx, common.HexToAddress(WETH), common.HexToAddress(USDC), big.NewInt(0)); err == nil {
+		t.Errorf("expected error for a zero amount")
+	}
+}
+
+func TestGetExchangeRateUsesPairTokenOrder(t *testing.T) {
+	ctx := context.Background()
+	pairProvider := &TradingPairProviderMock{}
+	tokenDecimalsProvider := &TokenDecimalsProviderMock{}
+	poolReservesProvider := &PoolReservesProviderMock{}
+	pairTokensProvider := &PairTokensProviderMock{}
+	exchangeRateProvider := &OnChainExchangeRateProvider{
+		pairProvider:          pairProvider,
+		poolReservesProvider:  poolReservesProvider,
+		tokenDecimalsProvider: tokenDecimalsProvider,
+		pairTokensProvider:    pairTokensProvider,
+	}
+
+	// a pair that reports WETH as token0 even though USDC has the lower address
+	pairProvider.On("GetTradingPair", ctx, common.HexToAddress(WETH), common.HexToAddress(USDC)).Return(common.HexToAddress(WETH_USDC), nil)
+	tokenDecimalsProvider.On("GetTokenDecimals", ctx, mock.Anything).Return(uint8(18), nil)
+	poolReservesProvider.On("GetPoolReserves", ctx, common.HexToAddress(WETH_USDC)).Return(big.NewInt(19), big.NewInt(95), nil)
+	pairTokensProvider.On("GetPairTokens", ctx, common.HexToAddress(WETH_USDC)).Return(common.HexToAddress(WETH), common.HexToAddress(USDC), nil)
+
+	gotRate, err := exchangeRateProvider.GetExchangeRate(ctx, common.HexToAddress(WETH), common.HexToAddress(USDC))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	pairTokensProvider.AssertCalled(t, "GetPairTokens", ctx, common.HexToAddress(WETH_USDC))
+	wantRate := big.NewRat(5, 1)
+	if gotRate.Cmp(wantRate) != 0 {
+		t.Errorf("got %v want %v", gotRate, wantRate)
+	}
+
+	// a pair that does not hold the requested tokens is rejected instead of mispriced
+	pairTokensProvider.ExpectedCalls = nil
+	pairTokensProvider.On("GetPairTokens", ctx, common.HexToAddress(WETH_USDC)).Return(common.HexToAddress(WETH), common.HexToAddress(DAI), nil)
+	if _, err := exchangeRateProvider.GetExchangeRate(ctx, common.HexToAddress(WETH), common.HexToAddress(USDC)); err == nil {
+		t.Errorf("expected error for a pair holding other tokens")
+	}
+}
+
+func TestExchangeRateAndRouteAgreeOnTokenOrder(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		var tokenA, tokenB common.Address
+		rng.Read(tokenA[:])
+		rng.Read(tokenB[:])
+		// a shared first byte makes the later bytes decide the order
+		tokenB[0] = tokenA[0]
+		reserveA, reserveB := 1+rng.Int63n(1e15), 1+rng.Int63n(1e15)
+		graph := &v2GraphFake{}
+		graph.addPool(tokenA, tokenB, reserveA, reserveB)
+		router := newFakeRouter(graph)
+		exchangeRates := NewOnChainExchangeRateProvider(graph, graph, graph, nil)
+
+		rate, err := exchangeRates.GetExchangeRate(ctx, tokenA, tokenB)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if want := big.NewRat(reserveB, reserveA); rate.Cmp(want) != 0 {
+			t.Fatalf("got rate %v for %v/%v want %v", rate, tokenA, tokenB, want)
+		}
+		for _, tokens := range [][2]common.Address{{tokenA, tokenB}, {tokenB, tokenA}} {
+			amountIn := big.NewInt(1 + rng.Int63n(1e12))
+			quoted, err := exchangeRates.GetQuote(ctx, tokens[0], tokens[1], amountIn)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			routed, err := router.Route(ctx, amountIn, tokens[0], tokens[1], 1)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if routed.AmountOut.Cmp(quoted) != 0 {
+				t.Fatalf("got %v routed and %v quoted for %v of %v into %v", routed.AmountOut, quoted, amountIn, tokens[0], tokens[1])
+			}
+		}
+	}
+}
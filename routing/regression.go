@@ -0,0 +1,302 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/pathfinder"
+	"v2Routing/v2math"
+)
+
+// RoutingStrategy is a route search that can be run offline over a recorded pool graph, so
+// algorithm changes can be compared on the same liquidity before they ship
+type RoutingStrategy interface {
+	Name() string
+	// Route returns the best exact-in output and its path, every pool is priced with the
+	// Uniswap V2 formula including the 0.3% fee
+	Route(ctx context.Context, graph *GraphDump, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*big.Int, []common.Address, error)
+}
+
+// RoutingStrategies are the strategies in the tree by name: every pathfinder algorithm, of which
+// "dp" is the one the router uses by default, and "v2math"
+var RoutingStrategies = map[string]RoutingStrategy{
+	"dp":         pathfinderStrategy{pathfinder.HopBounded{}},
+	"exhaustive": pathfinderStrategy{pathfinder.Exhaustive{}},
+	"v2math":     v2mathStrategy{},
+}
+
+// pathfinderStrategy runs a pathfinder algorithm the way OnChainV2Router does with
+// V2RouterConfig.Pathfinder
+type pathfinderStrategy struct {
+	algorithm pathfinder.Algorithm
+}
+
+func (s pathfinderStrategy) Name() string {
+	return s.algorithm.Name()
+}
+
+func (s pathfinderStrategy) Route(ctx context.Context, dump *GraphDump, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*big.Int, []common.Address, error) {
+	graph, err := dump.routeGraph(tokenIn, tokenOut)
+	if err != nil {
+		return nil, nil, err
+	}
+	graph.algorithm = s.algorithm
+	amountOut, path, _, err := searchRoute(ctx, graph, exactIn, amountIn, maxHops, nil, &RouteMetadata{}, nil)
+	return amountOut, path, err
+}
+
+// v2mathStrategy is v2math.BestRoute, which relaxes every pool once per hop instead of every
+// token pair
+type v2mathStrategy struct{}
+
+func (v2mathStrategy) Name() string {
+	return "v2math"
+}
+
+func (v2mathStrategy) Route(ctx context.Context, dump *GraphDump, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*big.Int, []common.Address, error) {
+	pools := make([]v2math.Pool[common.Address], 0, len(dump.Pools))
+	for _, pool := range dump.Pools {
+		reserve0, reserve1, err := pool.reserves()
+		if err != nil {
+			return nil, nil, err
+		}
+		pools = append(pools, v2math.Pool[common.Address]{TokenA: pool.Token0, TokenB: pool.Token1, ReserveA: reserve0, ReserveB: reserve1, Fee: v2math.UniswapV2Fee})
+	}
+	amountOut, hops, err := v2math.BestRoute(pools, tokenIn, tokenOut, amountIn, maxHops)
+	if err != nil {
+		return nil, nil, err
+	}
+	path := []common.Address{tokenIn}
+	for _, hop := range hops {
+		path = append(path, hop.TokenOut)
+	}
+	return amountOut, path, nil
+}
+
+func (p GraphPool) reserves() (*big.Int, *big.Int, error) {
+	reserve0, ok0 := new(big.Int).SetString(p.Reserve0, 10)
+	reserve1, ok1 := new(big.Int).SetString(p.Reserve1, 10)
+	if !ok0 || !ok1 {
+		return nil, nil, fmt.Errorf("invalid reserves %q/%q of pool %v", p.Reserve0, p.Reserve1, p.Pair.String())
+	}
+	return reserve0, reserve1, nil
+}
+
+// routeGraph rebuilds the search graph of the dump, tokenIn and tokenOut are added when the dump
+// does not list them
+func (d *GraphDump) routeGraph(tokenIn, tokenOut common.Address) (*routeGraph, error) {
+	graph := &routeGraph{reserves: make(map[pairKey][]hopReserves)}
+	seen := make(map[common.Address]bool)
+	for _, token := range append(append([]common.Address{}, d.Tokens...), tokenIn, tokenOut) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		graph.tokens = append(graph.tokens, token)
+	}
+	sortAddresses(graph.tokens)
+	for i, token := range graph.tokens {
+		if token == tokenIn {
+			graph.tokenInIndex = i
+		}
+		if token == tokenOut {
+			graph.tokenOutIndex = i
+		}
+	}
+	for _, pool := range d.Pools {
+		reserve0, reserve1, err := pool.reserves()
+		if err != nil {
+			return nil, err
+		}
+		forward, backward := newPairKey(pool.Token0, pool.Token1), newPairKey(pool.Token1, pool.Token0)
+		graph.reserves[forward] = append(graph.reserves[forward], hopReserves{reserveIn: reserve0, reserveOut: reserve1, pair: pool.Pair})
+		graph.reserves[backward] = append(graph.reserves[backward], hopReserves{reserveIn: reserve1, reserveOut: reserve0, pair: pool.Pair})
+	}
+	graph.sortPools()
+	return graph, nil
+}
+
+// RegressionSnapshot is a recorded pool graph and the trades to route over it. Router debug dumps
+// (WriteDebugDump) load as snapshots without trades.
+type RegressionSnapshot struct {
+	Name   string            `json:"-"`
+	Graph  *GraphDump        `json:"graph"`
+	Trades []RegressionTrade `json:"trades,omitempty"`
+}
+
+type RegressionTrade struct {
+	TokenIn  common.Address `json:"tokenIn"`
+	TokenOut common.Address `json:"tokenOut"`
+	AmountIn string         `json:"amountIn"`
+	// defaults to 3
+	MaxHops int `json:"maxHops,omitempty"`
+}
+
+// LoadRegressionSnapshots reads every .json file in dir as a RegressionSnapshot
+func LoadRegressionSnapshots(dir string) ([]RegressionSnapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	snapshots := []RegressionSnapshot{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var snapshot RegressionSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("decoding snapshot %v: %w", path, err)
+		}
+		if snapshot.Graph == nil {
+			return nil, fmt.Errorf("snapshot %v has no graph", path)
+		}
+		snapshot.Name = filepath.Base(path)
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// trades returns the recorded trades, or for snapshots without any every ordered token pair of
+// the graph trading 1% of the deepest reserve of tokenIn
+func (s RegressionSnapshot) trades() ([]RegressionTrade, error) {
+	if len(s.Trades) > 0 {
+		return s.Trades, nil
+	}
+	deepest := make(map[common.Address]*big.Int)
+	for _, pool := range s.Graph.Pools {
+		reserve0, reserve1, err := pool.reserves()
+		if err != nil {
+			return nil, err
+		}
+		for token, reserve := range map[common.Address]*big.Int{pool.Token0: reserve0, pool.Token1: reserve1} {
+			if deepest[token] == nil || reserve.Cmp(deepest[token]) > 0 {
+				deepest[token] = reserve
+			}
+		}
+	}
+	trades := []RegressionTrade{}
+	for _, tokenIn := range s.Graph.Tokens {
+		amountIn := deepest[tokenIn]
+		if amountIn == nil || amountIn.Cmp(big.NewInt(100)) < 0 {
+			continue
+		}
+		for _, tokenOut := range s.Graph.Tokens {
+			if tokenIn != tokenOut {
+				trades = append(trades, RegressionTrade{TokenIn: tokenIn, TokenOut: tokenOut, AmountIn: new(big.Int).Quo(amountIn, big.NewInt(100)).String()})
+			}
+		}
+	}
+	return trades, nil
+}
+
+// RegressionReport compares a candidate strategy with a baseline over the same trades. A win is
+// a trade the candidate pays more for or routes where the baseline could not, a loss the reverse.
+type RegressionReport struct {
+	Baseline  string `json:"baseline"`
+	Candidate string `json:"candidate"`
+	Trades    int    `json:"trades"`
+	Wins      int    `json:"wins"`
+	Losses    int    `json:"losses"`
+	Ties      int    `json:"ties"`
+	// trades neither strategy could route, counted as ties
+	Unroutable int `json:"unroutable"`
+	// every trade that was not a tie
+	Diffs []RegressionDiff `json:"diffs"`
+}
+
+// RegressionDiff is a trade the strategies disagree on, amounts are empty when a strategy failed
+type RegressionDiff struct {
+	Snapshot      string           `json:"snapshot"`
+	Trade         RegressionTrade  `json:"trade"`
+	BaselineOut   string           `json:"baselineOut,omitempty"`
+	CandidateOut  string           `json:"candidateOut,omitempty"`
+	BaselinePath  []common.Address `json:"baselinePath,omitempty"`
+	CandidatePath []common.Address `json:"candidatePath,omitempty"`
+	// change of the candidate's output over the baseline's, 0 when either failed
+	ChangePercent float64 `json:"changePercent"`
+	BaselineErr   string  `json:"baselineError,omitempty"`
+	CandidateErr  string  `json:"candidateError,omitempty"`
+}
+
+func (r *RegressionReport) WinRate() float64 {
+	if r.Trades == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(r.Trades)
+}
+
+func (r *RegressionReport) LossRate() float64 {
+	if r.Trades == 0 {
+		return 0
+	}
+	return float64(r.Losses) / float64(r.Trades)
+}
+
+// CompareStrategies routes every trade of every snapshot with both strategies
+func CompareStrategies(ctx context.Context, baseline, candidate RoutingStrategy, snapshots []RegressionSnapshot) (*RegressionReport, error) {
+	report := &RegressionReport{Baseline: baseline.Name(), Candidate: candidate.Name(), Diffs: []RegressionDiff{}}
+	// thousands of searches would drown the report in per-hop logs
+	ctx = withTraceDecision(ctx, RatioSampler(0))
+	for _, snapshot := range snapshots {
+		trades, err := snapshot.trades()
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %v: %w", snapshot.Name, err)
+		}
+		for _, trade := range trades {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			amountIn, ok := new(big.Int).SetString(trade.AmountIn, 10)
+			if !ok || amountIn.Sign() <= 0 {
+				return nil, fmt.Errorf("snapshot %v: invalid amountIn %q", snapshot.Name, trade.AmountIn)
+			}
+			maxHops := trade.MaxHops
+			if maxHops == 0 {
+				maxHops = 3
+			}
+			baselineOut, baselinePath, baselineErr := baseline.Route(ctx, snapshot.Graph, amountIn, trade.TokenIn, trade.TokenOut, maxHops)
+			candidateOut, candidatePath, candidateErr := candidate.Route(ctx, snapshot.Graph, amountIn, trade.TokenIn, trade.TokenOut, maxHops)
+			report.Trades++
+			diff := RegressionDiff{Snapshot: snapshot.Name, Trade: trade, BaselinePath: baselinePath, CandidatePath: candidatePath}
+			switch {
+			case baselineErr != nil && candidateErr != nil:
+				report.Ties++
+				report.Unroutable++
+				continue
+			case baselineErr != nil:
+				report.Wins++
+				diff.BaselineErr, diff.CandidateOut = baselineErr.Error(), candidateOut.String()
+			case candidateErr != nil:
+				report.Losses++
+				diff.BaselineOut, diff.CandidateErr = baselineOut.String(), candidateErr.Error()
+			default:
+				comparison := candidateOut.Cmp(baselineOut)
+				if comparison == 0 {
+					report.Ties++
+					continue
+				}
+				if comparison > 0 {
+					report.Wins++
+				} else {
+					report.Losses++
+				}
+				diff.BaselineOut, diff.CandidateOut = baselineOut.String(), candidateOut.String()
+				if baselineOut.Sign() > 0 {
+					change, _ := new(big.Rat).SetFrac(new(big.Int).Sub(candidateOut, baselineOut), baselineOut).Float64()
+					diff.ChangePercent = change * 100
+				}
+			}
+			report.Diffs = append(report.Diffs, diff)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,141 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// directStrategy only trades through a direct pool, a deliberately worse algorithm
+type directStrategy struct{}
+
+func (directStrategy) Name() string {
+	return "direct"
+}
+
+func (directStrategy) Route(ctx context.Context, graph *GraphDump, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*big.Int, []common.Address, error) {
+	return RoutingStrategies["dp"].Route(ctx, graph, amountIn, tokenIn, tokenOut, 1)
+}
+
+func TestCompareStrategies(t *testing.T) {
+	snapshots, err := LoadRegressionSnapshots("testdata/regression")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Name != "triangle.json" {
+		t.Fatalf("got snapshots %+v want triangle.json", snapshots)
+	}
+
+	report, err := CompareStrategies(context.Background(), RoutingStrategies["dp"], RoutingStrategies["v2math"], snapshots)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// both strategies find the exact optimum on this graph
+	if report.Trades != 3 || report.Ties != 3 || report.Unroutable != 1 || len(report.Diffs) != 0 {
+		t.Errorf("got report %+v want 3 ties, one unroutable", report)
+	}
+
+	// the pathfinder's exhaustive search checks the router's on the same graphs
+	report, err = CompareStrategies(context.Background(), RoutingStrategies["dp"], RoutingStrategies["exhaustive"], snapshots)
+	if err != nil || report.Ties != 3 || report.Unroutable != 1 {
+		t.Errorf("got report %+v error %v want 3 ties, one unroutable", report, err)
+	}
+
+	report, err = CompareStrategies(context.Background(), RoutingStrategies["dp"], directStrategy{}, snapshots)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// WETH -> DAI pays more through USDC than through the shallow direct pool
+	if report.Losses != 1 || report.Wins != 0 || report.LossRate() != 1.0/3 || len(report.Diffs) != 1 {
+		t.Fatalf("got report %+v want one loss", report)
+	}
+	diff := report.Diffs[0]
+	if diff.Trade.TokenOut != common.HexToAddress(DAI) || diff.ChangePercent >= 0 || len(diff.BaselinePath) != 3 || len(diff.CandidatePath) != 2 {
+		t.Errorf("got diff %+v want the WETH -> DAI trade losing output", diff)
+	}
+}
+
+func TestRegressionSnapshotFromDebugDump(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	router := newFakeRouter(graph)
+	if _, err := router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(USDC), 1); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	dir := t.TempDir()
+	if _, err := router.WriteDebugDump(dir); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	snapshots, err := LoadRegressionSnapshots(dir)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	report, err := CompareStrategies(context.Background(), RoutingStrategies["dp"], RoutingStrategies["v2math"], snapshots)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// a dump has no trades, so both directions of the pool are traded
+	if report.Trades != 2 || report.Ties != 2 || report.Unroutable != 0 {
+		t.Errorf("got report %+v want both directions routed alike", report)
+	}
+}
+
+func TestCompareStrategiesInvalidTrade(t *testing.T) {
+	snapshots := []RegressionSnapshot{{Name: "bad", Graph: &GraphDump{}, Trades: []RegressionTrade{{AmountIn: "-1"}}}}
+	_, err := CompareStrategies(context.Background(), RoutingStrategies["dp"], RoutingStrategies["v2math"], snapshots)
+	if err == nil {
+		t.Errorf("got error %v want an invalid amount error", err)
+	}
+}
+
+// v2math.BestRoute keeps its own copy of the router's search, so it is checked against the
+// pathfinder's on random graphs to catch either drifting
+func TestV2mathMatchesPathfinder(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ctx := context.Background()
+	for i := 0; i < 200; i++ {
+		// pools priced consistently from one price per token, so no cycle pays
+		n := 3 + rng.Intn(4)
+		dump := &GraphDump{}
+		prices := make([]int64, n)
+		for t := range prices {
+			dump.Tokens = append(dump.Tokens, common.BigToAddress(big.NewInt(int64(t+1))))
+			prices[t] = 1 + rng.Int63n(1000)
+		}
+		for a := 0; a < n; a++ {
+			for b := a + 1; b < n; b++ {
+				for pools := rng.Intn(3); pools > 0; pools-- {
+					depth := 1 + rng.Int63n(1e9)
+					dump.Pools = append(dump.Pools, GraphPool{
+						Token0:   dump.Tokens[a],
+						Token1:   dump.Tokens[b],
+						Pair:     common.BigToAddress(big.NewInt(int64(1000 + len(dump.Pools)))),
+						Reserve0: new(big.Int).Mul(big.NewInt(depth), big.NewInt(prices[b])).String(),
+						Reserve1: new(big.Int).Mul(big.NewInt(depth), big.NewInt(prices[a])).String(),
+					})
+				}
+			}
+		}
+		amountIn, tokenIn, tokenOut, maxHops := big.NewInt(1+rng.Int63n(1e9)), dump.Tokens[0], dump.Tokens[n-1], 1+rng.Intn(4)
+
+		v2mathOut, v2mathPath, v2mathErr := RoutingStrategies["v2math"].Route(ctx, dump, amountIn, tokenIn, tokenOut, maxHops)
+		dpOut, dpPath, dpErr := RoutingStrategies["dp"].Route(ctx, dump, amountIn, tokenIn, tokenOut, maxHops)
+		exhaustiveOut, _, exhaustiveErr := RoutingStrategies["exhaustive"].Route(ctx, dump, amountIn, tokenIn, tokenOut, maxHops)
+		if (v2mathErr != nil) != (dpErr != nil) || (dpErr != nil) != (exhaustiveErr != nil) {
+			t.Fatalf("got errors %v, %v and %v from v2math, dp and exhaustive", v2mathErr, dpErr, exhaustiveErr)
+		}
+		if dpErr != nil {
+			continue
+		}
+		if v2mathOut.Cmp(dpOut) != 0 || len(v2mathPath) != len(dpPath) {
+			t.Fatalf("got v2math %v via %v want dp's %v via %v", v2mathOut, v2mathPath, dpOut, dpPath)
+		}
+		if dpOut.Cmp(exhaustiveOut) > 0 {
+			t.Fatalf("got dp %v above exhaustive %v", dpOut, exhaustiveOut)
+		}
+	}
+}
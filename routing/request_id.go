@@ -0,0 +1,115 @@
+package routing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RequestIDHeader carries the request ID on every JSON-RPC call made for a quote
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// WithRequestID tags all logs and RPC calls made with the returned context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID, or "" when none was set
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// NewRequestID returns a random 16 character hex ID
+func NewRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// ensureRequestID keeps an ID set by the caller and generates one otherwise
+func ensureRequestID(ctx context.Context) context.Context {
+	if RequestIDFromContext(ctx) != "" {
+		return ctx
+	}
+	return WithRequestID(ctx, NewRequestID())
+}
+
+// DialEthClient connects to an HTTP JSON-RPC endpoint, forwarding the request ID of every call as RequestIDHeader
+func DialEthClient(rawurl string) (*ethclient.Client, error) {
+	httpClient := &http.Client{Transport: &requestIDTransport{base: http.DefaultTransport}}
+	rpcClient, err := rpc.DialHTTPWithClient(rawurl, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(rpcClient), nil
+}
+
+// requestIDTransport forwards the request ID of the call context as an HTTP header to the RPC node,
+// and records a span of every request when the context carries a Tracer
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// reading the method from the body is only worth it for requests that are traced
+	if !tracing(req.Context()) {
+		return t.roundTrip(req)
+	}
+	ctx, span := startSpan(req.Context(), "rpc "+rpcRequestMethod(req), Attribute{"server.address", req.URL.Host})
+	defer span.End()
+	resp, err := t.roundTrip(req.WithContext(ctx))
+	span.RecordError(err)
+	if resp != nil {
+		span.SetAttributes(Attribute{"http.response.status_code", resp.StatusCode})
+		if resp.StatusCode >= 400 {
+			span.RecordError(fmt.Errorf("http status %v", resp.Status))
+		}
+	}
+	return resp, err
+}
+
+func (t *requestIDTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	requestID := RequestIDFromContext(req.Context())
+	if requestID == "" {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, requestID)
+	return t.base.RoundTrip(req)
+}
+
+// rpcRequestMethod names the JSON-RPC method of req, "batch" for batches of several, read from a
+// copy of the body
+func rpcRequestMethod(req *http.Request) string {
+	if req.GetBody == nil {
+		return "call"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "call"
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "call"
+	}
+	methods, err := rpcMethods(data)
+	switch {
+	case err != nil || len(methods) == 0:
+		return "call"
+	case len(methods) > 1:
+		return "batch"
+	}
+	return methods[0]
+}
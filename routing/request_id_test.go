@@ -0,0 +1,66 @@
+package routing
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestRequestIDHeaderIsSentToRPC(t *testing.T) {
+	var gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get(RequestIDHeader)
+		io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":"0xf42400"}`)
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{Transport: &requestIDTransport{base: http.DefaultTransport}}
+	rpcClient, err := rpc.DialHTTPWithClient(server.URL, httpClient)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	client := ethclient.NewClient(rpcClient)
+
+	blockNumber, err := client.BlockNumber(WithRequestID(context.Background(), "quote-1"))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if blockNumber != 16000000 {
+		t.Errorf("got block %d want 16000000", blockNumber)
+	}
+	if gotHeader != "quote-1" {
+		t.Errorf("got header %q want %q", gotHeader, "quote-1")
+	}
+}
+
+func TestRouteRequestID(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 100, 200000)
+	router := newFakeRouter(graph)
+
+	_, _, metadata, err := router.RouteWithMetadata(WithRequestID(context.Background(), "quote-2"), big.NewInt(10), common.HexToAddress(WETH), common.HexToAddress(USDC), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if metadata.RequestID != "quote-2" {
+		t.Errorf("got request ID %q want %q", metadata.RequestID, "quote-2")
+	}
+
+	// routes without a caller supplied ID still get one
+	_, _, metadata, err = router.RouteWithMetadata(context.Background(), big.NewInt(10), common.HexToAddress(WETH), common.HexToAddress(USDC), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if metadata.RequestID == "" {
+		t.Errorf("expected a generated request ID")
+	}
+}
@@ -0,0 +1,74 @@
+package routing
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// defaultMaxReserveFraction is the share of a hop's input reserve a trade may use before it is flagged
+const defaultMaxReserveFraction = 0.3
+
+// ReserveWarning flags a hop where the trade takes a large share of the pool's reserves
+type ReserveWarning struct {
+	TokenIn   common.Address `json:"tokenIn"`
+	TokenOut  common.Address `json:"tokenOut"`
+	AmountIn  *big.Int       `json:"amountIn"`
+	ReserveIn *big.Int       `json:"reserveIn"`
+	// AmountIn / ReserveIn
+	Fraction float64 `json:"fraction"`
+}
+
+func (w ReserveWarning) String() string {
+	return fmt.Sprintf("%v -> %v uses %.1f%% of the pool's %v reserve", w.TokenIn.String(), w.TokenOut.String(), w.Fraction*100, w.TokenIn.String())
+}
+
+// InsufficientReservesError is returned in strict mode instead of a quote that would mostly drain a pool
+type InsufficientReservesError struct {
+	MaxFraction float64
+	Warnings    []ReserveWarning
+}
+
+func (e *InsufficientReservesError) Error() string {
+	warnings := make([]string, len(e.Warnings))
+	for i, warning := range e.Warnings {
+		warnings[i] = warning.String()
+	}
+	return fmt.Sprintf("trade exceeds %.1f%% of reserves: %v", e.MaxFraction*100, strings.Join(warnings, ", "))
+}
+
+func (e *InsufficientReservesError) Is(target error) bool {
+	return target == ErrInsufficientLiquidity
+}
+
+// checkReserveFractions prices the trade along the pools of path and flags every hop whose input is more
+// than maxFraction of the pool's input reserve
+func checkReserveFractions(tradeType tradeType, amount *big.Int, path []common.Address, hops []hopReserves, maxFraction float64) ([]ReserveWarning, error) {
+	var amounts []*big.Int
+	var err error
+	if tradeType == exactOut {
+		amounts, err = getAmountsIn(amount, hops)
+	} else {
+		amounts, err = getAmountsOut(amount, hops)
+	}
+	if err != nil {
+		return nil, err
+	}
+	warnings := []ReserveWarning{}
+	for i, hop := range hops {
+		fraction, _ := new(big.Rat).SetFrac(amounts[i], hop.reserveIn).Float64()
+		if fraction <= maxFraction {
+			continue
+		}
+		warnings = append(warnings, ReserveWarning{
+			TokenIn:   path[i],
+			TokenOut:  path[i+1],
+			AmountIn:  amounts[i],
+			ReserveIn: hop.reserveIn,
+			Fraction:  fraction,
+		})
+	}
+	return warnings, nil
+}
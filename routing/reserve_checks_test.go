@@ -0,0 +1,57 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRouteReserveWarnings(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	router := newFakeRouter(graph)
+
+	_, _, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(USDC), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(metadata.ReserveWarnings) != 0 {
+		t.Errorf("got warnings %v want none", metadata.ReserveWarnings)
+	}
+
+	// half of the WETH reserve is over the default 30%
+	amountIn := big.NewInt(500000)
+	_, _, metadata, err = router.RouteWithMetadata(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(USDC), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(metadata.ReserveWarnings) != 1 {
+		t.Fatalf("got warnings %v want one", metadata.ReserveWarnings)
+	}
+	warning := metadata.ReserveWarnings[0]
+	if warning.TokenIn != common.HexToAddress(WETH) || warning.AmountIn.Cmp(amountIn) != 0 || warning.Fraction != 0.5 {
+		t.Errorf("got warning %+v", warning)
+	}
+
+	router.maxReserveFraction = 0.6
+	if _, _, metadata, _ = router.RouteWithMetadata(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(USDC), 1); len(metadata.ReserveWarnings) != 0 {
+		t.Errorf("got warnings %v want none under a 60%% threshold", metadata.ReserveWarnings)
+	}
+
+	router.maxReserveFraction = 0
+	router.strictReserves = true
+	_, err = router.Route(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(USDC), 1)
+	var reservesErr *InsufficientReservesError
+	if !errors.As(err, &reservesErr) || len(reservesErr.Warnings) != 1 {
+		t.Errorf("got error %v want InsufficientReservesError", err)
+	}
+
+	// exact-out checks the input the trade would need
+	_, err = router.RouteExactOut(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDC), big.NewInt(1500000000), 1)
+	if !errors.As(err, &reservesErr) {
+		t.Errorf("got error %v want InsufficientReservesError", err)
+	}
+}
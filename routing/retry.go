@@ -0,0 +1,306 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RetryPolicy is how often and how far apart failed RPC reads are tried again, the zero value
+// tries once
+type RetryPolicy struct {
+	// tries of every read including the first
+	Attempts int
+	// wait after the first failure, doubled after every further one
+	Delay time.Duration
+	// longest wait between tries, 0 for no limit
+	MaxDelay time.Duration
+	// share of every wait taken off at random, between 0 and 1, so clients failing together do
+	// not retry together
+	Jitter float64
+	// reports whether a read failing with an error may succeed when tried again, defaults to
+	// IsRetryableError
+	Retryable func(error) bool
+	// optional, retries are logged to the logger of the context without one
+	Logger *slog.Logger
+}
+
+// DefaultRetryPolicy tries reads three times over about a second
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, Delay: 250 * time.Millisecond, MaxDelay: 2 * time.Second, Jitter: 0.5}
+
+// JSON-RPC error codes nodes and providers use for load they will take again later
+const (
+	rpcCodeLimitExceeded = -32005
+	rpcCodeInternal      = -32603
+)
+
+// IsRetryableError tells transient RPC failures (rate limits, overloaded or unreachable nodes,
+// timeouts, a load balancer behind the pinned block) from permanent ones (reverts, missing
+// contracts, malformed requests, data that does not decode), which fail the same way every time
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrPairNotDeployed) || errors.Is(err, bind.ErrNoCode) {
+		return false
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode == http.StatusRequestTimeout || httpErr.StatusCode >= 500
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		message := strings.ToLower(rpcErr.Error())
+		switch {
+		case strings.Contains(message, "revert"):
+			return false
+		case rpcErr.ErrorCode() == rpcCodeLimitExceeded || rpcErr.ErrorCode() == rpcCodeInternal:
+			return true
+		default:
+			return strings.Contains(message, "rate limit") || strings.Contains(message, "too many requests") || strings.Contains(message, "header not found")
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// wait is how long to wait before the try after attempt, attempt counting from 1
+func (p RetryPolicy) wait(attempt int) time.Duration {
+	wait := p.Delay
+	for i := 1; i < attempt && (p.MaxDelay == 0 || wait < p.MaxDelay); i++ {
+		wait *= 2
+	}
+	if p.MaxDelay > 0 && wait > p.MaxDelay {
+		wait = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		wait -= time.Duration(rand.Float64() * p.Jitter * float64(wait))
+	}
+	return wait
+}
+
+// do calls read until it succeeds, fails with an error that is not retryable or the attempts run
+// out, and returns the last error
+func (p RetryPolicy) do(ctx context.Context, read func() error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryableError
+	}
+	for attempt := 1; ; attempt++ {
+		err := read()
+		if err == nil || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		if attempt >= p.Attempts {
+			if attempt > 1 {
+				return fmt.Errorf("%v attempts failed: %w", attempt, err)
+			}
+			return err
+		}
+		wait := p.wait(attempt)
+		logWarn(ctx, p.Logger, "retrying failed read", "wait", wait, "attempt", attempt, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryRead is do for reads returning a single value
+func retryRead[T any](ctx context.Context, policy RetryPolicy, read func() (T, error)) (T, error) {
+	var result T
+	err := policy.do(ctx, func() error {
+		var err error
+		result, err = read()
+		return err
+	})
+	return result, err
+}
+
+// RetryingPoolReservesProvider retries the reads of a PoolReservesProvider that fail, so a
+// momentary RPC error does not fail the whole route
+type RetryingPoolReservesProvider struct {
+	provider PoolReservesProvider
+	policy   RetryPolicy
+}
+
+func NewRetryingPoolReservesProvider(provider PoolReservesProvider, policy RetryPolicy) *RetryingPoolReservesProvider {
+	return &RetryingPoolReservesProvider{provider: provider, policy: policy}
+}
+
+func (p *RetryingPoolReservesProvider) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	var reserve0, reserve1 *big.Int
+	err := p.policy.do(ctx, func() error {
+		var err error
+		reserve0, reserve1, err = p.provider.GetPoolReserves(ctx, pairAddress)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return reserve0, reserve1, nil
+}
+
+// GetPoolReservesBatch retries whole batches of a BatchPoolReservesProvider, other providers are
+// read pair by pair with undeployed pairs left nil as in a batch
+func (p *RetryingPoolReservesProvider) GetPoolReservesBatch(ctx context.Context, pairAddresses []common.Address) ([]*big.Int, []*big.Int, error) {
+	if batchProvider, ok := p.provider.(BatchPoolReservesProvider); ok {
+		var reserves0, reserves1 []*big.Int
+		err := p.policy.do(ctx, func() error {
+			var err error
+			reserves0, reserves1, err = batchProvider.GetPoolReservesBatch(ctx, pairAddresses)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return reserves0, reserves1, nil
+	}
+	reserves0 := make([]*big.Int, len(pairAddresses))
+	reserves1 := make([]*big.Int, len(pairAddresses))
+	err := forEachParallel(ctx, len(pairAddresses), defaultParallelism, func(ctx context.Context, i int) error {
+		var err error
+		reserves0[i], reserves1[i], err = p.GetPoolReserves(ctx, pairAddresses[i])
+		if errors.Is(err, ErrPairNotDeployed) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return reserves0, reserves1, nil
+}
+
+// RetryingTradingPairProvider retries the failed pair lookups of a TradingPairProvider
+type RetryingTradingPairProvider struct {
+	provider TradingPairProvider
+	policy   RetryPolicy
+}
+
+func NewRetryingTradingPairProvider(provider TradingPairProvider, policy RetryPolicy) *RetryingTradingPairProvider {
+	return &RetryingTradingPairProvider{provider: provider, policy: policy}
+}
+
+func (p *RetryingTradingPairProvider) GetTradingPair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	return retryRead(ctx, p.policy, func() (common.Address, error) {
+		return p.provider.GetTradingPair(ctx, tokenA, tokenB)
+	})
+}
+
+// RetryingTokenDecimalsProvider retries the failed reads of a TokenDecimalsProvider
+type RetryingTokenDecimalsProvider struct {
+	provider TokenDecimalsProvider
+	policy   RetryPolicy
+}
+
+func NewRetryingTokenDecimalsProvider(provider TokenDecimalsProvider, policy RetryPolicy) *RetryingTokenDecimalsProvider {
+	return &RetryingTokenDecimalsProvider{provider: provider, policy: policy}
+}
+
+func (p *RetryingTokenDecimalsProvider) GetTokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	return retryRead(ctx, p.policy, func() (uint8, error) {
+		return p.provider.GetTokenDecimals(ctx, tokenAddress)
+	})
+}
+
+// RetryingPairTokensProvider retries the failed reads of a PairTokensProvider
+type RetryingPairTokensProvider struct {
+	provider PairTokensProvider
+	policy   RetryPolicy
+}
+
+func NewRetryingPairTokensProvider(provider PairTokensProvider, policy RetryPolicy) *RetryingPairTokensProvider {
+	return &RetryingPairTokensProvider{provider: provider, policy: policy}
+}
+
+func (p *RetryingPairTokensProvider) GetPairTokens(ctx context.Context, pairAddress common.Address) (common.Address, common.Address, error) {
+	tokens, err := retryRead(ctx, p.policy, func() ([2]common.Address, error) {
+		token0, token1, err := p.provider.GetPairTokens(ctx, pairAddress)
+		return [2]common.Address{token0, token1}, err
+	})
+	return tokens[0], tokens[1], err
+}
+
+// CacheSummary reports the cache of the wrapped provider, OnChainPairTokensProvider keeps one
+func (p *RetryingPairTokensProvider) CacheSummary() CacheSummary {
+	if summarizer, ok := p.provider.(cacheSummarizer); ok {
+		return summarizer.CacheSummary()
+	}
+	return CacheSummary{}
+}
+
+// RetryingTotalSupplyProvider retries the failed reads of a TotalSupplyProvider
+type RetryingTotalSupplyProvider struct {
+	provider TotalSupplyProvider
+	policy   RetryPolicy
+}
+
+func NewRetryingTotalSupplyProvider(provider TotalSupplyProvider, policy RetryPolicy) *RetryingTotalSupplyProvider {
+	return &RetryingTotalSupplyProvider{provider: provider, policy: policy}
+}
+
+func (p *RetryingTotalSupplyProvider) GetTotalSupply(ctx context.Context, tokenAddress common.Address) (*big.Int, error) {
+	return retryRead(ctx, p.policy, func() (*big.Int, error) {
+		return p.provider.GetTotalSupply(ctx, tokenAddress)
+	})
+}
+
+// RetryingPoolTypeProvider retries the failed probes of a PoolTypeProvider
+type RetryingPoolTypeProvider struct {
+	provider PoolTypeProvider
+	policy   RetryPolicy
+}
+
+func NewRetryingPoolTypeProvider(provider PoolTypeProvider, policy RetryPolicy) *RetryingPoolTypeProvider {
+	return &RetryingPoolTypeProvider{provider: provider, policy: policy}
+}
+
+func (p *RetryingPoolTypeProvider) GetPoolType(ctx context.Context, poolAddress common.Address) (PoolType, error) {
+	return retryRead(ctx, p.policy, func() (PoolType, error) {
+		return p.provider.GetPoolType(ctx, poolAddress)
+	})
+}
+
+// CacheSummary reports the cache of the wrapped provider, OnChainPoolTypeProvider keeps one
+func (p *RetryingPoolTypeProvider) CacheSummary() CacheSummary {
+	if summarizer, ok := p.provider.(cacheSummarizer); ok {
+		return summarizer.CacheSummary()
+	}
+	return CacheSummary{}
+}
+
+// RetryingBlockNumberProvider retries the failed reads of a BlockNumberProvider
+type RetryingBlockNumberProvider struct {
+	provider BlockNumberProvider
+	policy   RetryPolicy
+}
+
+func NewRetryingBlockNumberProvider(provider BlockNumberProvider, policy RetryPolicy) *RetryingBlockNumberProvider {
+	return &RetryingBlockNumberProvider{provider: provider, policy: policy}
+}
+
+func (p *RetryingBlockNumberProvider) BlockNumber(ctx context.Context) (uint64, error) {
+	return retryRead(ctx, p.policy, func() (uint64, error) {
+		return p.provider.BlockNumber(ctx)
+	})
+}
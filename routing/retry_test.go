@@ -0,0 +1,109 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// flakyReservesFake fails the first failures reads with err
+type flakyReservesFake struct {
+	failures int
+	err      error
+	reads    int
+}
+
+func (f *flakyReservesFake) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	f.reads++
+	if f.reads <= f.failures {
+		return nil, nil, f.err
+	}
+	return big.NewInt(1), big.NewInt(2), nil
+}
+
+func TestRetryingPoolReservesProvider(t *testing.T) {
+	pair := common.HexToAddress(WETH_USDC)
+	rateLimited := rpcErrorFake{-32005, "request rate limited"}
+	reverted := rpcErrorFake{3, "execution reverted"}
+	tests := []struct {
+		name      string
+		fake      *flakyReservesFake
+		wantErr   error
+		wantReads int
+	}{
+		{"recovers", &flakyReservesFake{failures: 2, err: rateLimited}, nil, 3},
+		{"gives up", &flakyReservesFake{failures: 5, err: rateLimited}, rateLimited, 3},
+		{"undeployed pairs are not retried", &flakyReservesFake{failures: 5, err: ErrPairNotDeployed}, ErrPairNotDeployed, 1},
+		{"reverts are not retried", &flakyReservesFake{failures: 5, err: reverted}, reverted, 1},
+	}
+	for _, test := range tests {
+		provider := NewRetryingPoolReservesProvider(test.fake, RetryPolicy{Attempts: 3})
+		_, _, err := provider.GetPoolReserves(context.Background(), pair)
+		if !errors.Is(err, test.wantErr) || (test.wantErr == nil && err != nil) || test.fake.reads != test.wantReads {
+			t.Errorf("%v: got error %v after %v reads want %v after %v", test.name, err, test.fake.reads, test.wantErr, test.wantReads)
+		}
+	}
+
+	// providers without batches are read pair by pair, undeployed pairs are left out
+	provider := NewRetryingPoolReservesProvider(&flakyReservesFake{failures: 1, err: ErrPairNotDeployed}, RetryPolicy{Attempts: 3})
+	reserves0, _, err := provider.GetPoolReservesBatch(context.Background(), []common.Address{pair})
+	if err != nil || reserves0[0] != nil {
+		t.Errorf("got reserves %v error %v want an undeployed pair", reserves0, err)
+	}
+}
+
+// rpcErrorFake is a JSON-RPC error response
+type rpcErrorFake struct {
+	code    int
+	message string
+}
+
+func (e rpcErrorFake) Error() string  { return e.message }
+func (e rpcErrorFake) ErrorCode() int { return e.code }
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{rpc.HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{rpc.HTTPError{StatusCode: http.StatusBadGateway}, true},
+		{rpc.HTTPError{StatusCode: http.StatusUnauthorized}, false},
+		{rpcErrorFake{-32005, "daily request count exceeded, request rate limited"}, true},
+		{rpcErrorFake{-32000, "header not found"}, true},
+		{rpcErrorFake{-32000, "execution reverted"}, false},
+		{rpcErrorFake{-32602, "invalid argument 0: hex string has length 3"}, false},
+		{fmt.Errorf("reserves of pair: %w", io.ErrUnexpectedEOF), true},
+		{fmt.Errorf("reserves of pair: %w", ErrPairNotDeployed), false},
+		{context.DeadlineExceeded, false},
+		{errors.New("abi: cannot unmarshal"), false},
+	}
+	for _, test := range tests {
+		if got := IsRetryableError(test.err); got != test.want {
+			t.Errorf("%v: got retryable %v want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestRetryPolicyWait(t *testing.T) {
+	policy := RetryPolicy{Delay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond} {
+		if got := policy.wait(attempt + 1); got != want {
+			t.Errorf("attempt %v: got wait %v want %v", attempt+1, got, want)
+		}
+	}
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.wait(2); got <= 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("got jittered wait %v want between 100ms and 200ms", got)
+		}
+	}
+}
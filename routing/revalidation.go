@@ -0,0 +1,148 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RouteRevalidator prices quotes again right before their swaps are sent, see Executor.SetRevalidator.
+// OnChainV2Router is one.
+type RouteRevalidator interface {
+	// RevalidateQuote prices the route of quote, through the same pools, at the latest block
+	RevalidateQuote(ctx context.Context, quote *Quote) (*Quote, error)
+	// Requote routes the trade of quote again at the latest block
+	Requote(ctx context.Context, quote *Quote) (*Quote, error)
+}
+
+// StaleRouteError is returned by Executor when the pools of a quote's route no longer pay its
+// slippage limit at the latest block, the swap is not sent
+type StaleRouteError struct {
+	Quote *Quote
+	// the route of Quote priced at Revalidated.BlockNumber
+	Revalidated *Quote
+}
+
+func (e *StaleRouteError) Error() string {
+	if e.Quote.ExactOut {
+		return fmt.Sprintf("route now costs %v %v at block %v, above the limit of %v", e.Revalidated.AmountIn, e.Quote.TokenIn.Hex(), e.Revalidated.BlockNumber, e.Quote.AmountInMax)
+	}
+	return fmt.Sprintf("route now pays %v %v at block %v, below the limit of %v", e.Revalidated.AmountOut, e.Quote.TokenOut.Hex(), e.Revalidated.BlockNumber, e.Quote.AmountOutMin)
+}
+
+// withinLimits reports whether revalidated still meets the slippage limits of quote
+func withinLimits(quote, revalidated *Quote) bool {
+	if quote.ExactOut {
+		return revalidated.AmountIn.Cmp(quote.AmountInMax) <= 0
+	}
+	return revalidated.AmountOut.Cmp(quote.AmountOutMin) >= 0
+}
+
+// RevalidateQuote reads the reserves of every pool of quote's route at the latest block, unless ctx
+// pins one, and returns the route priced against them with the slippage tolerance of quote. The
+// transfer fees found when quoting are applied again.
+func (r *OnChainV2Router) RevalidateQuote(ctx context.Context, quote *Quote) (*Quote, error) {
+	if len(quote.Hops) == 0 {
+		return nil, errors.New("quote has no hops")
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]common.Address, len(quote.Hops))
+	for i, hop := range quote.Hops {
+		pairs[i] = hop.Pair
+	}
+	reserves0 := make([]*big.Int, len(pairs))
+	reserves1 := make([]*big.Int, len(pairs))
+	if batchProvider, ok := r.poolReservesProvider.(BatchPoolReservesProvider); ok {
+		reserves0, reserves1, err = batchProvider.GetPoolReservesBatch(ctx, pairs)
+	} else {
+		err = forEachParallel(ctx, len(pairs), r.parallelism, func(ctx context.Context, k int) error {
+			var err error
+			reserves0[k], reserves1[k], err = r.poolReservesProvider.GetPoolReserves(ctx, pairs[k])
+			return err
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading the reserves of the route: %w", err)
+	}
+
+	hops := make([]hopReserves, len(quote.Hops))
+	for i, hop := range quote.Hops {
+		if reserves0[i] == nil {
+			return nil, fmt.Errorf("%w: %v", ErrPairNotDeployed, hop.Pair.Hex())
+		}
+		dex, err := r.adapter(hop.DEX)
+		if err != nil {
+			return nil, err
+		}
+		reserveIn, reserveOut, err := orientReserves(ctx, r.pairTokensProvider, hop.Pair, hop.TokenIn, hop.TokenOut, reserves0[i], reserves1[i])
+		if err != nil {
+			return nil, err
+		}
+		hops[i] = hopReserves{reserveIn: reserveIn, reserveOut: reserveOut, pair: hop.Pair, dex: dex}
+	}
+
+	path := quote.Path()
+	amountIn, amountOut := quote.AmountIn, quote.AmountOut
+	if quote.ExactOut {
+		amounts, err := getAmountsIn(amountOut, hops)
+		if err != nil {
+			return nil, err
+		}
+		amountIn = amounts[0]
+	} else {
+		amountOut, err = amountOutAfterTransferFees(amountIn, path, hops, quote.TransferFees)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tradeType := exactIn
+	if quote.ExactOut {
+		tradeType = exactOut
+	}
+	revalidated := newQuote(tradeType, quote.TokenIn, quote.TokenOut, amountIn, amountOut, &RouteMetadata{
+		Hops:         routeHops(path, hops),
+		PriceImpact:  pathPriceImpact(amountIn, hops),
+		TransferFees: quote.TransferFees,
+		BlockNumber:  BlockNumberFromContext(ctx),
+		Deployment:   r.deployment,
+	})
+	if err := revalidated.SetSlippage(quote.SlippageBips); err != nil {
+		return nil, err
+	}
+	return revalidated, nil
+}
+
+// Requote routes the trade of quote again, with its slippage tolerance, e.g. once RevalidateQuote
+// finds its route stale
+func (r *OnChainV2Router) Requote(ctx context.Context, quote *Quote) (*Quote, error) {
+	var requoted *Quote
+	var err error
+	if quote.ExactOut {
+		requoted, err = r.RouteExactOut(ctx, quote.TokenIn, quote.TokenOut, quote.AmountOut, AutoMaxHops)
+	} else {
+		requoted, err = r.Route(ctx, quote.AmountIn, quote.TokenIn, quote.TokenOut, AutoMaxHops)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := requoted.SetSlippage(quote.SlippageBips); err != nil {
+		return nil, err
+	}
+	return requoted, nil
+}
+
+// adapter is the DEXAdapter a quote's hop names
+func (r *OnChainV2Router) adapter(name string) (DEXAdapter, error) {
+	for _, dex := range r.adapters() {
+		if dex.Name() == name {
+			return dex, nil
+		}
+	}
+	return nil, fmt.Errorf("no DEX adapter named %q", name)
+}
@@ -0,0 +1,152 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"v2Routing/v2math"
+)
+
+// setReserves changes the reserves of a pool added with addPool, as a swap landing would
+func (g *v2GraphFake) setReserves(tokenA, tokenB common.Address, reserveA, reserveB int64) {
+	if token0, _ := v2math.SortTokens(tokenA, tokenB); token0 != tokenA {
+		reserveA, reserveB = reserveB, reserveA
+	}
+	g.reserves[fakePairAddress(tokenA, tokenB)] = [2]*big.Int{big.NewInt(reserveA), big.NewInt(reserveB)}
+}
+
+func TestRevalidateQuote(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	router := newFakeRouter(graph)
+	amountIn := big.NewInt(1000)
+	quote, err := router.Route(context.Background(), amountIn, weth, usdc, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	revalidated, err := router.RevalidateQuote(context.Background(), quote)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if revalidated.AmountOut.Cmp(quote.AmountOut) != 0 || revalidated.AmountOutMin.Cmp(quote.AmountOutMin) != 0 {
+		t.Errorf("got %v (min %v) want the unchanged %v (min %v)", revalidated.AmountOut, revalidated.AmountOutMin, quote.AmountOut, quote.AmountOutMin)
+	}
+
+	graph.setReserves(weth, usdc, 1100000, 1800000000)
+	revalidated, err = router.RevalidateQuote(context.Background(), quote)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	want, _ := getAmountOut(amountIn, big.NewInt(1100000), big.NewInt(1800000000))
+	if revalidated.AmountOut.Cmp(want) != 0 {
+		t.Errorf("got %v want %v at the new reserves", revalidated.AmountOut, want)
+	}
+	hop := revalidated.Hops[0]
+	if hop.Pair != quote.Hops[0].Pair || hop.ReserveIn.Int64() != 1100000 || hop.ReserveOut.Int64() != 1800000000 {
+		t.Errorf("got hop %+v want the pool of the quote with its new reserves", hop)
+	}
+	if revalidated.SlippageBips != quote.SlippageBips {
+		t.Errorf("got slippage %v want the quote's %v", revalidated.SlippageBips, quote.SlippageBips)
+	}
+
+	exactOut, err := router.RouteExactOut(context.Background(), weth, usdc, big.NewInt(1000000), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	graph.setReserves(weth, usdc, 1000000, 2000000000)
+	revalidated, err = router.RevalidateQuote(context.Background(), exactOut)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	wantIn, _ := getAmountIn(big.NewInt(1000000), big.NewInt(1000000), big.NewInt(2000000000))
+	if revalidated.AmountIn.Cmp(wantIn) != 0 || revalidated.AmountOut.Cmp(exactOut.AmountOut) != 0 {
+		t.Errorf("got %v for %v want %v", revalidated.AmountIn, revalidated.AmountOut, wantIn)
+	}
+}
+
+func TestExecutorRevalidation(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	newGraph := func() *v2GraphFake {
+		graph := &v2GraphFake{}
+		graph.addPool(weth, usdc, 1000000, 2000000000)
+		// WETH -> DAI -> USDC pays a little less until the direct pool moves
+		graph.addPool(weth, dai, 1000000, 2000000000)
+		graph.addPool(dai, usdc, 1000000000000, 1000000000000)
+		return graph
+	}
+	key, _ := crypto.GenerateKey()
+	signer := newSigner(key)
+	options := SwapOptions{Recipient: signer.Address()}
+
+	for _, test := range []struct {
+		name    string
+		reroute bool
+		// reserves of WETH/USDC and WETH/DAI after quoting
+		direct, alternative [2]int64
+		wantPath            []common.Address
+	}{
+		{name: "unchanged", direct: [2]int64{1000000, 2000000000}, alternative: [2]int64{1000000, 2000000000}, wantPath: []common.Address{weth, usdc}},
+		{name: "stale", direct: [2]int64{1000000, 1900000000}, alternative: [2]int64{1000000, 2000000000}},
+		{name: "rerouted", reroute: true, direct: [2]int64{1000000, 1900000000}, alternative: [2]int64{1000000, 2000000000}, wantPath: []common.Address{weth, dai, usdc}},
+		{name: "no route within the limit", reroute: true, direct: [2]int64{1000000, 1900000000}, alternative: [2]int64{1000000, 1900000000}},
+	} {
+		graph := newGraph()
+		router := newFakeRouter(graph)
+		quote, err := router.Route(context.Background(), big.NewInt(1000), weth, usdc, 2)
+		if err != nil {
+			t.Errorf("%v: got error %v", test.name, err)
+			continue
+		}
+		if len(quote.Hops) != 1 {
+			t.Errorf("%v: got hops %+v want the direct pool", test.name, quote.Hops)
+			continue
+		}
+		graph.setReserves(weth, usdc, test.direct[0], test.direct[1])
+		graph.setReserves(weth, dai, test.alternative[0], test.alternative[1])
+
+		backend := &transactionBackendFake{}
+		builder := NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil)
+		executor := NewExecutor(backend, signer, builder)
+		executor.SetRevalidator(router, test.reroute)
+		_, err = executor.Execute(context.Background(), quote, options)
+
+		if test.wantPath == nil {
+			var stale *StaleRouteError
+			if !errors.As(err, &stale) || stale.Revalidated.AmountOut.Cmp(quote.AmountOutMin) >= 0 {
+				t.Errorf("%v: got error %v want a StaleRouteError below %v", test.name, err, quote.AmountOutMin)
+			}
+			if len(backend.sent) != 0 || builder.DailyExposure(weth).Sign() != 0 {
+				t.Errorf("%v: got %v transactions sent and exposure %v want nothing sent and the exposure released", test.name, len(backend.sent), builder.DailyExposure(weth))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: got error %v", test.name, err)
+			continue
+		}
+		if len(backend.sent) != 1 {
+			t.Errorf("%v: got %v transactions sent want one", test.name, len(backend.sent))
+			continue
+		}
+		swaps, err := DecodeSwapCalldata(backend.sent[0].Data(), backend.sent[0].Value())
+		if err != nil {
+			t.Errorf("%v: got error %v", test.name, err)
+			continue
+		}
+		if fmt.Sprint(swaps[0].Path) != fmt.Sprint(test.wantPath) {
+			t.Errorf("%v: got path %v want %v", test.name, swaps[0].Path, test.wantPath)
+		}
+		// a rerouted swap keeps the limit of the quote it replaces when that is the tighter one
+		if swaps[0].AmountOutMin.Cmp(quote.AmountOutMin) < 0 {
+			t.Errorf("%v: got amountOutMin %v want at least the quote's %v", test.name, swaps[0].AmountOutMin, quote.AmountOutMin)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/pathfinder"
+)
+
+// routeCheckpoint is the pathfinder.State of a route search after a completed hop.
+// gob cannot encode nil pointers in a slice, so unreachable amounts are stored as zero;
+// reachable amounts are always positive. HopCost is nil without gas pricing.
+type routeCheckpoint struct {
+	Tokens      []common.Address
+	BlockNumber *big.Int
+	TradeType   tradeType
+	Amount      *big.Int
+	HopCost     *big.Int
+	Hop         int
+	Amounts     [][]*big.Int
+	Prev        []map[common.Address]pathfinder.Ref
+	Best        *big.Int
+	BestHops    int
+}
+
+func routeCheckpointPath(dir string, tradeType tradeType, tokenIn, tokenOut common.Address) string {
+	return filepath.Join(dir, fmt.Sprintf("route-%v-%v-%v.gob", tradeType, tokenIn.Hex(), tokenOut.Hex()))
+}
+
+func newRouteCheckpoint(tokens []common.Address, blockNumber *big.Int, tradeType tradeType, amount, hopCost *big.Int, state *pathfinder.State) *routeCheckpoint {
+	saved := make([][]*big.Int, len(state.Amounts))
+	for i := range saved {
+		saved[i] = make([]*big.Int, len(state.Amounts[i]))
+		for t, value := range state.Amounts[i] {
+			if value == nil {
+				value = new(big.Int)
+			}
+			saved[i][t] = value
+		}
+	}
+	return &routeCheckpoint{
+		Tokens:      tokens,
+		BlockNumber: blockNumber,
+		TradeType:   tradeType,
+		Amount:      amount,
+		HopCost:     hopCost,
+		Hop:         state.Hop,
+		Amounts:     saved,
+		Prev:        state.Prev,
+		Best:        state.Best,
+		BestHops:    state.BestHops,
+	}
+}
+
+// loadRouteCheckpoint returns nil without an error when no checkpoint was saved yet
+func loadRouteCheckpoint(path string) (*routeCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &routeCheckpoint{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(checkpoint); err != nil {
+		return nil, fmt.Errorf("decoding route checkpoint %v: %w", path, err)
+	}
+	return checkpoint, nil
+}
+
+// save writes to a temporary file first so an interrupted write never corrupts the last checkpoint
+func (c *routeCheckpoint) save(path string) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(c); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// matches reports whether the checkpoint was taken for the same trade and hop cost over the same
+// graph, at the same block, and can be continued within maxHops
+func (c *routeCheckpoint) matches(tokens []common.Address, blockNumber *big.Int, tradeType tradeType, amount, hopCost *big.Int, maxHops int) bool {
+	if c == nil || c.Hop > maxHops || len(c.Tokens) != len(tokens) || len(c.Amounts) != c.Hop+1 || len(c.Prev) != c.Hop+1 {
+		return false
+	}
+	if c.TradeType != tradeType || c.Amount == nil || c.Amount.Cmp(amount) != 0 || !equalOptional(c.HopCost, hopCost) {
+		return false
+	}
+	for i := range tokens {
+		if c.Tokens[i] != tokens[i] {
+			return false
+		}
+	}
+	return equalOptional(c.BlockNumber, blockNumber)
+}
+
+// equalOptional compares two values that may be nil, nil only equals nil
+func equalOptional(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Cmp(b) == 0
+}
+
+// state is the search state the checkpoint was taken from
+func (c *routeCheckpoint) state() *pathfinder.State {
+	amounts := make([][]*big.Int, len(c.Amounts))
+	for hop := range c.Amounts {
+		amounts[hop] = make([]*big.Int, len(c.Amounts[hop]))
+		for t, value := range c.Amounts[hop] {
+			if value.Sign() > 0 {
+				amounts[hop][t] = value
+			}
+		}
+	}
+	return &pathfinder.State{Hop: c.Hop, Amounts: amounts, Prev: c.Prev, Best: c.Best, BestHops: c.BestHops}
+}
+
+// fileCheckpoints keeps the checkpoints of one route search in a file, so a deep search
+// interrupted by a timeout or a restart resumes where it stopped
+type fileCheckpoints struct {
+	ctx       context.Context
+	path      string
+	tokens    []common.Address
+	tradeType tradeType
+	amount    *big.Int
+	hopCost   *big.Int
+	maxHops   int
+	// writes a checkpoint to path, (*routeCheckpoint).save when nil
+	save func(checkpoint *routeCheckpoint, path string) error
+}
+
+func (c *fileCheckpoints) Load() (*pathfinder.State, error) {
+	checkpoint, err := loadRouteCheckpoint(c.path)
+	if err != nil {
+		return nil, err
+	}
+	if !checkpoint.matches(c.tokens, BlockNumberFromContext(c.ctx), c.tradeType, c.amount, c.hopCost, c.maxHops) {
+		return nil, nil
+	}
+	logDebug(c.ctx, nil, "resuming route search", "hop", checkpoint.Hop+1)
+	return checkpoint.state(), nil
+}
+
+func (c *fileCheckpoints) Save(state *pathfinder.State) error {
+	save := c.save
+	if save == nil {
+		save = (*routeCheckpoint).save
+	}
+	return save(newRouteCheckpoint(c.tokens, BlockNumberFromContext(c.ctx), c.tradeType, c.amount, c.hopCost, state), c.path)
+}
+
+func (c *fileCheckpoints) Clear() {
+	os.Remove(c.path)
+}
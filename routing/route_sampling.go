@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	defaultSampledRoutes = 3
+	// routes paying within 0.1% of the best one are sampled
+	defaultSamplingTolerance = 0.001
+)
+
+// RouteSampling configures RouteSampled
+type RouteSampling struct {
+	// routes of RouteTopK sampled from, defaults to defaultSampledRoutes
+	K int
+	// share of the best output a route may give up and still be sampled (0.001 is 0.1%), defaults
+	// to defaultSamplingTolerance
+	Tolerance float64
+	// optional, returns a number in [0, 1) picking the route, rand.Float64 when nil
+	Rand func() float64
+}
+
+// RouteSampled spreads repeated trades over the best routes instead of always taking the best one,
+// so a high-frequency quoting bot does not keep hitting the same pools: it quotes the routes of
+// RouteTopK, with their Alternatives, and picks one of those paying within the tolerance of the best
+// at random, weighted by their quoted output.
+func (r *OnChainV2Router) RouteSampled(ctx context.Context, tokenIn, tokenOut common.Address, amountIn *big.Int, sampling RouteSampling) (*Quote, error) {
+	k := sampling.K
+	if k == 0 {
+		k = defaultSampledRoutes
+	}
+	routes, err := r.RouteTopK(ctx, tokenIn, tokenOut, amountIn, k)
+	if err != nil {
+		return nil, err
+	}
+	// parallel pools spread the trades as well as other routes do
+	quotes := []*Quote{}
+	for _, route := range routes {
+		quotes = append(quotes, route)
+		quotes = append(quotes, route.Alternatives...)
+	}
+	return sampleQuote(quotes, sampling), nil
+}
+
+// sampleQuote picks one of quotes, best first, with a probability proportional to its output
+// among those within sampling.Tolerance of the first
+func sampleQuote(quotes []*Quote, sampling RouteSampling) *Quote {
+	tolerance, random := sampling.Tolerance, sampling.Rand
+	if tolerance == 0 {
+		tolerance = defaultSamplingTolerance
+	}
+	if random == nil {
+		random = rand.Float64
+	}
+	best := quotes[0].AmountOut
+	total := new(big.Int)
+	candidates := []*Quote{}
+	for _, quote := range quotes {
+		shortfall, _ := new(big.Rat).SetFrac(new(big.Int).Sub(best, quote.AmountOut), best).Float64()
+		if shortfall > tolerance {
+			continue
+		}
+		candidates = append(candidates, quote)
+		total.Add(total, quote.AmountOut)
+	}
+	pick, _ := new(big.Float).Mul(new(big.Float).SetInt(total), big.NewFloat(random())).Int(nil)
+	for _, quote := range candidates {
+		pick.Sub(pick, quote.AmountOut)
+		if pick.Sign() < 0 {
+			return quote
+		}
+	}
+	return candidates[len(candidates)-1]
+}
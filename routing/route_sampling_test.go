@@ -0,0 +1,65 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSampleQuote(t *testing.T) {
+	quotes := []*Quote{{AmountOut: big.NewInt(1000)}, {AmountOut: big.NewInt(999)}, {AmountOut: big.NewInt(998)}, {AmountOut: big.NewInt(900)}}
+	for _, test := range []struct {
+		random float64
+		want   int
+	}{
+		// the first three share 2997, picked in proportion to their output
+		{0, 0},
+		{0.333, 0},
+		{0.334, 1},
+		{0.666, 1},
+		{0.668, 2},
+		{0.999, 2},
+	} {
+		random := test.random
+		got := sampleQuote(quotes, RouteSampling{Tolerance: 0.005, Rand: func() float64 { return random }})
+		if got != quotes[test.want] {
+			t.Errorf("random %v: got the quote paying %v want %v", test.random, got.AmountOut, quotes[test.want].AmountOut)
+		}
+	}
+	// the default tolerance of 0.1% only keeps the best two
+	if got := sampleQuote(quotes, RouteSampling{Rand: func() float64 { return 0.999 }}); got != quotes[1] {
+		t.Errorf("got the quote paying %v want %v", got.AmountOut, quotes[1].AmountOut)
+	}
+}
+
+func TestRouteSampled(t *testing.T) {
+	graph := &v2GraphFake{}
+	uniswap := &dexPairsFake{name: "uniswap-v2"}
+	sushiswap := &dexPairsFake{name: "sushiswap"}
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	// two nearly equal WETH/USDC pools, and a route through DAI paying far less
+	addDEXPool(graph, uniswap, weth, usdc, 1000000, 2000000000)
+	addDEXPool(graph, sushiswap, weth, usdc, 1000000, 1999000000)
+	addDEXPool(graph, uniswap, weth, dai, 1000000, 1800000000)
+	addDEXPool(graph, uniswap, dai, usdc, 1000000000, 1000000000)
+	router := newFakeRouter(graph)
+	router.dexAdapters = []DEXAdapter{NewUniswapV2Adapter(uniswap), NewSushiswapAdapter(sushiswap)}
+
+	used := make(map[common.Address]int)
+	for i := 0; i < 10; i++ {
+		random := (float64(i) + 0.5) / 10
+		quote, err := router.RouteSampled(context.Background(), weth, usdc, big.NewInt(1000), RouteSampling{Rand: func() float64 { return random }})
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if len(quote.Hops) != 1 {
+			t.Fatalf("got hops %+v want a WETH/USDC pool, the route through DAI is beyond the tolerance", quote.Hops)
+		}
+		used[quote.Hops[0].Pair]++
+	}
+	if used[uniswap.pairs[newPairKey(weth, usdc)]] != 5 || used[sushiswap.pairs[newPairKey(weth, usdc)]] != 5 {
+		t.Errorf("got pools used %v want both WETH/USDC pools used half the time", used)
+	}
+}
@@ -0,0 +1,123 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/pathfinder"
+)
+
+type tradeType = pathfinder.TradeType
+
+const (
+	// amountIn is fixed and the output is maximized
+	exactIn = pathfinder.ExactIn
+	// amountOut is fixed and the input is minimized
+	exactOut = pathfinder.ExactOut
+)
+
+// routeGraph is the liquidity snapshot a route search runs on
+type routeGraph struct {
+	// sorted by address
+	tokens        []common.Address
+	tokenInIndex  int
+	tokenOutIndex int
+	// pools of every existing pair, one per DEX, keyed and oriented by swap direction.
+	// both directions of a pair list the pools in the same order, sorted by pair address.
+	reserves map[pairKey][]hopReserves
+	// every pair looked up while building the graph, including the ones left out
+	edges []EdgeDiagnostic
+	// pools the PoolsProvider dropped for their liquidity, when it reports them
+	thin []Pool
+	// the search run on the graph, pathfinder.HopBounded when nil
+	algorithm pathfinder.Algorithm
+}
+
+// sortPools orders the pools of every pair by pair address, both directions of a pair keep
+// listing them in the same order
+func (g *routeGraph) sortPools() {
+	for _, pools := range g.reserves {
+		sort.SliceStable(pools, func(i, j int) bool {
+			return bytes.Compare(pools[i].pair.Bytes(), pools[j].pair.Bytes()) < 0
+		})
+	}
+}
+
+// searchGraph is the graph as pathfinder algorithms take it
+func (g *routeGraph) searchGraph() *pathfinder.Graph {
+	pools := make(map[pathfinder.Pair][]pathfinder.Pool, len(g.reserves))
+	for key, hops := range g.reserves {
+		pairPools := make([]pathfinder.Pool, len(hops))
+		for i := range hops {
+			pairPools[i] = hops[i]
+		}
+		pools[pathfinder.Pair{TokenIn: key.tokenIn, TokenOut: key.tokenOut}] = pairPools
+	}
+	return &pathfinder.Graph{Tokens: g.tokens, Pools: pools}
+}
+
+// searchAlgorithm is the pathfinder the graph is searched with
+func (g *routeGraph) searchAlgorithm() pathfinder.Algorithm {
+	if g.algorithm == nil {
+		return pathfinder.HopBounded{}
+	}
+	return g.algorithm
+}
+
+// searchRoute finds the best route over graph with its pathfinder. When hopCost is set, routes
+// are ranked by their amount net of hopCost per swap. The default search checkpoints every hop
+// to the file of checkpoints, keyed by the search, nil disables checkpointing.
+func searchRoute(ctx context.Context, graph *routeGraph, tradeType tradeType, amount *big.Int, maxHops int, hopCost *big.Int, metadata *RouteMetadata, checkpoints *fileCheckpoints) (*big.Int, []common.Address, []hopReserves, error) {
+	tokenIn, tokenOut := graph.tokens[graph.tokenInIndex], graph.tokens[graph.tokenOutIndex]
+	algorithm := graph.searchAlgorithm()
+	if search, ok := algorithm.(pathfinder.HopBounded); ok {
+		if search.OnHop == nil {
+			search.OnHop = func(hop int, best *big.Int) {
+				logDebug(ctx, nil, "best amount", "trade_type", tradeType, "hops", hop, "amount", best)
+			}
+		}
+		if search.Checkpoints == nil && checkpoints != nil {
+			search.Checkpoints = &fileCheckpoints{ctx: ctx, path: checkpoints.path, tokens: graph.tokens, tradeType: tradeType, amount: amount, hopCost: hopCost, maxHops: maxHops, save: checkpoints.save}
+		}
+		algorithm = search
+	}
+
+	result, err := algorithm.FindRoute(ctx, graph.searchGraph(), pathfinder.Request{
+		TokenIn:   tokenIn,
+		TokenOut:  tokenOut,
+		TradeType: tradeType,
+		Amount:    amount,
+		MaxHops:   maxHops,
+		HopCost:   hopCost,
+	})
+	if errors.Is(err, pathfinder.ErrNoRoute) {
+		return nil, nil, nil, &NoRouteError{
+			TokenIn:  tokenIn,
+			TokenOut: tokenOut,
+			MaxHops:  maxHops,
+			Reasons:  diagnoseNoRoute(graph, maxHops),
+		}
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	metadata.EdgesEvaluated += result.Stats.EdgesEvaluated
+	metadata.CacheHits += result.Stats.PairHits
+	metadata.PrunedCandidates += result.Stats.PrunedCandidates
+
+	return result.Amount, result.Path, graph.resultHops(result), nil
+}
+
+// resultHops returns the pools of a pathfinder result's route in swap order
+func (g *routeGraph) resultHops(result *pathfinder.Result) []hopReserves {
+	hops := make([]hopReserves, len(result.Pools))
+	for i, pool := range result.Pools {
+		hops[i] = g.reserves[newPairKey(result.Path[i], result.Path[i+1])][pool]
+	}
+	return hops
+}
@@ -0,0 +1,111 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/pathfinder"
+)
+
+// maxTopKVariants bounds the routes of pathfinder.TopK RouteTopK searches, as a multiple of k, while
+// parallel pools fold into fewer logical routes
+const maxTopKVariants = 4
+
+// RouteTopK returns up to k exact-in routes from tokenIn to tokenOut through different tokens, best
+// first, e.g. to present alternatives, to fall back when a pool of the best route turns stale or to
+// split a trade by hand. Routes through the same tokens that only swap some hops in another of their
+// pools, e.g. on another DEX, are one logical route: the best is returned, the others in its
+// Alternatives. All routes are priced at the same block, the search depth is picked as with
+// AutoMaxHops and gas is netted like in Route when GasPricing is configured. Fewer than k quotes are
+// returned when the graph has no more routes.
+func (r *OnChainV2Router) RouteTopK(ctx context.Context, tokenIn, tokenOut common.Address, amountIn *big.Int, k int) ([]*Quote, error) {
+	if tokenIn == tokenOut {
+		return nil, fmt.Errorf("%w: %v", ErrSameToken, tokenIn.String())
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if k < 1 {
+		return nil, errors.New("k must be at least 1")
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	maxHops, err := r.resolveMaxHops(ctx, tokenIn, tokenOut, AutoMaxHops)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := r.buildGraph(ctx, tokenIn, tokenOut, nil)
+	if err != nil {
+		return nil, err
+	}
+	var hopCost *big.Int
+	if r.gasPricing != nil {
+		hopCost, err = r.gasPricing.hopCost(ctx, graph, r.routedChain().WrappedNative, tokenOut, maxHops)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// variants through parallel pools take up routes of TopK, search more until k logical routes
+	// are found or the graph has no more
+	for searched := k; ; searched *= 2 {
+		results, err := pathfinder.TopK(ctx, graph.searchAlgorithm(), graph.searchGraph(), pathfinder.Request{
+			TokenIn:   tokenIn,
+			TokenOut:  tokenOut,
+			TradeType: exactIn,
+			Amount:    amountIn,
+			MaxHops:   maxHops,
+			HopCost:   hopCost,
+		}, searched)
+		if errors.Is(err, pathfinder.ErrNoRoute) {
+			return nil, &NoRouteError{TokenIn: tokenIn, TokenOut: tokenOut, MaxHops: maxHops, Reasons: diagnoseNoRoute(graph, maxHops)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		quotes := make([]*Quote, len(results))
+		for i, result := range results {
+			hops := graph.resultHops(result)
+			metadata := &RouteMetadata{
+				Hops:        routeHops(result.Path, hops),
+				PriceImpact: pathPriceImpact(amountIn, hops),
+				BlockNumber: BlockNumberFromContext(ctx),
+				Deployment:  r.deployment,
+			}
+			quotes[i], err = r.quote(exactIn, tokenIn, tokenOut, amountIn, result.Amount, metadata)
+			if err != nil {
+				return nil, err
+			}
+		}
+		routes := groupAlternatives(quotes)
+		if len(routes) >= k {
+			return routes[:k], nil
+		}
+		if len(results) < searched || searched >= maxTopKVariants*k {
+			return routes, nil
+		}
+	}
+}
+
+// groupAlternatives folds quotes, best first, through the same tokens into the first of them and
+// its Alternatives
+func groupAlternatives(quotes []*Quote) []*Quote {
+	routes := []*Quote{}
+	byPath := make(map[string]*Quote)
+	for _, quote := range quotes {
+		key := fmt.Sprint(quote.Path())
+		if route, ok := byPath[key]; ok {
+			route.Alternatives = append(route.Alternatives, quote)
+			continue
+		}
+		byPath[key] = quote
+		routes = append(routes, quote)
+	}
+	return routes
+}
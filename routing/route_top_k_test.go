@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRouteTopK(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1100000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 100000, 150000000)
+	router := newFakeRouter(graph)
+	amountIn := big.NewInt(1000)
+
+	quotes, err := router.RouteTopK(context.Background(), common.HexToAddress(WETH), common.HexToAddress(DAI), amountIn, 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// only two routes exist, through USDC and the shallow direct pool
+	if len(quotes) != 2 || quotes[0].HopCount() != 2 || quotes[1].HopCount() != 1 {
+		t.Fatalf("got quotes %+v want the two hop route, then the direct one", quotes)
+	}
+	best, err := router.Route(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(DAI), AutoMaxHops)
+	if err != nil || best.AmountOut.Cmp(quotes[0].AmountOut) != 0 {
+		t.Errorf("got route %+v error %v want the first quote %v", best, err, quotes[0].AmountOut)
+	}
+	direct := quotes[1]
+	if direct.Hops[0].Pair != fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(DAI)) || direct.AmountOut.Cmp(quotes[0].AmountOut) >= 0 || direct.PriceImpact == nil || direct.AmountOutMin == nil {
+		t.Errorf("got quote %+v want the direct pool paying less", direct)
+	}
+
+	if _, err := router.RouteTopK(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDT), amountIn, 3); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("got error %v want ErrNoRoute", err)
+	}
+	if _, err := router.RouteTopK(context.Background(), common.HexToAddress(WETH), common.HexToAddress(DAI), amountIn, 0); err == nil {
+		t.Errorf("got no error for k 0")
+	}
+}
+
+func TestRouteTopKAlternatives(t *testing.T) {
+	graph := &v2GraphFake{}
+	uniswap := &dexPairsFake{name: "uniswap-v2"}
+	sushiswap := &dexPairsFake{name: "sushiswap"}
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	// both the direct route and the one through DAI have a parallel pool on sushiswap
+	addDEXPool(graph, uniswap, weth, usdc, 1000000, 2000000000)
+	addDEXPool(graph, sushiswap, weth, usdc, 1000000, 1999000000)
+	addDEXPool(graph, uniswap, weth, dai, 1000000, 1800000000)
+	addDEXPool(graph, uniswap, dai, usdc, 1000000000, 1000000000)
+	addDEXPool(graph, sushiswap, dai, usdc, 1000000000, 999000000)
+	router := newFakeRouter(graph)
+	router.dexAdapters = []DEXAdapter{NewUniswapV2Adapter(uniswap), NewSushiswapAdapter(sushiswap)}
+
+	quotes, err := router.RouteTopK(context.Background(), weth, usdc, big.NewInt(1000), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(quotes) != 2 || quotes[0].HopCount() != 1 || quotes[1].HopCount() != 2 {
+		t.Fatalf("got quotes %+v want the direct route, then the one through DAI", quotes)
+	}
+	for i, quote := range quotes {
+		if len(quote.Alternatives) != 1 {
+			t.Errorf("route %v: got %v alternatives want the route through the sushiswap pool", i, len(quote.Alternatives))
+			continue
+		}
+		alternative := quote.Alternatives[0]
+		last := len(alternative.Hops) - 1
+		if fmt.Sprint(alternative.Path()) != fmt.Sprint(quote.Path()) || alternative.Hops[last].DEX != "sushiswap" || alternative.AmountOut.Cmp(quote.AmountOut) >= 0 {
+			t.Errorf("route %v: got alternative %+v want the same tokens through sushiswap paying less", i, alternative)
+		}
+	}
+}
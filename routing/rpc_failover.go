@@ -0,0 +1,361 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	defaultFailoverTimeout     = 10 * time.Second
+	defaultHealthCheckInterval = 15 * time.Second
+	defaultMaxBlockLag         = 5
+)
+
+// methods whose state lives on the node they were sent to, never spread over endpoints
+var pinnedMethods = map[string]bool{
+	"eth_sendRawTransaction":          true,
+	"eth_sendTransaction":             true,
+	"eth_newFilter":                   true,
+	"eth_newBlockFilter":              true,
+	"eth_newPendingTransactionFilter": true,
+	"eth_getFilterChanges":            true,
+	"eth_getFilterLogs":               true,
+	"eth_uninstallFilter":             true,
+}
+
+// FailoverConfig configures NewFailoverTransport, every field but URLs is optional
+type FailoverConfig struct {
+	// HTTP JSON-RPC endpoints in order of preference
+	URLs []string
+	// spreads reads round robin over the healthy endpoints instead of sending them all to the
+	// first, transactions and filters still go to the first healthy endpoint
+	LoadBalance bool
+	// limit of a request to one endpoint before the next is tried, 10s by default
+	Timeout time.Duration
+	// how often Run checks the endpoints with eth_blockNumber, 15s by default
+	HealthCheckInterval time.Duration
+	// endpoints more blocks behind the highest are unhealthy, 5 by default
+	MaxBlockLag uint64
+	// optional, failovers are logged to the logger of the request context without one
+	Logger *slog.Logger
+}
+
+// EndpointStatus is what the last health check or request found of an endpoint
+type EndpointStatus struct {
+	URL         string
+	Healthy     bool
+	BlockNumber uint64
+	// why the endpoint is unhealthy
+	Err       error
+	CheckedAt time.Time
+	// requests sent to the endpoint and the ones it failed, since the start
+	Requests uint64
+	Failures uint64
+}
+
+type failoverEndpoint struct {
+	url    *url.URL
+	status EndpointStatus
+	// kept apart from status, which every health check replaces
+	requests, failures uint64
+}
+
+// FailoverTransport sends the JSON-RPC requests of an HTTP client to several endpoints: requests
+// go to the first healthy endpoint and move on to the next when one fails with a transport
+// error, a timeout, a rate limit or a 5xx response. Failed endpoints are skipped until a health
+// check (HealthCheck, or Run in the background) finds them answering and caught up again.
+type FailoverTransport struct {
+	config FailoverConfig
+	base   http.RoundTripper
+	now    func() time.Time
+
+	mu        sync.Mutex
+	endpoints []*failoverEndpoint
+	next      uint64
+}
+
+func NewFailoverTransport(config FailoverConfig, base http.RoundTripper) (*FailoverTransport, error) {
+	if len(config.URLs) == 0 {
+		return nil, errors.New("no rpc endpoints configured")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultFailoverTimeout
+	}
+	if config.HealthCheckInterval == 0 {
+		config.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	if config.MaxBlockLag == 0 {
+		config.MaxBlockLag = defaultMaxBlockLag
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &FailoverTransport{config: config, base: base, now: time.Now}
+	for _, rawurl := range config.URLs {
+		endpointURL, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, fmt.Errorf("rpc endpoint %v: %w", rawurl, err)
+		}
+		// endpoints count as healthy until they fail
+		t.endpoints = append(t.endpoints, &failoverEndpoint{url: endpointURL, status: EndpointStatus{URL: endpointURL.Redacted(), Healthy: true}})
+	}
+	return t, nil
+}
+
+// DialFailoverEthClient connects to every endpoint of config through a FailoverTransport, forwarding
+// the request ID of every call as DialEthClient does. Run the returned transport to bring failed
+// endpoints back.
+func DialFailoverEthClient(config FailoverConfig) (*ethclient.Client, *FailoverTransport, error) {
+	transport, err := NewFailoverTransport(config, &requestIDTransport{base: http.DefaultTransport})
+	if err != nil {
+		return nil, nil, err
+	}
+	// requests are sent to the endpoints by the transport whatever URL the client dials
+	rpcClient, err := rpc.DialHTTPWithClient(config.URLs[0], &http.Client{Transport: transport})
+	if err != nil {
+		return nil, nil, err
+	}
+	return ethclient.NewClient(rpcClient), transport, nil
+}
+
+func (t *FailoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	endpoints := t.order(body)
+	for i, endpoint := range endpoints {
+		t.countRequest(endpoint)
+		resp, err := t.send(req, endpoint.url, body)
+		if err == nil && !failoverStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		// the caller gave up, which says nothing about the endpoint
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+		failure := err
+		if failure == nil {
+			failure = fmt.Errorf("http status %v", resp.Status)
+		}
+		t.markFailed(endpoint, failure)
+		// the last endpoint's answer is returned as it is, so rate limits still show as such
+		if i == len(endpoints)-1 {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		logWarn(req.Context(), t.config.Logger, "rpc endpoint failed, failing over", "endpoint", endpoint.url.Redacted(), "error", failure)
+	}
+	return nil, errors.New("no rpc endpoints configured")
+}
+
+// order lists the healthy endpoints first, rotated for reads when load balancing, then the
+// unhealthy ones as a last resort
+func (t *FailoverTransport) order(body []byte) []*failoverEndpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	healthy, unhealthy := []*failoverEndpoint{}, []*failoverEndpoint{}
+	for _, endpoint := range t.endpoints {
+		if endpoint.status.Healthy {
+			healthy = append(healthy, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+	if t.config.LoadBalance && len(healthy) > 1 && balanced(body) {
+		start := int(t.next % uint64(len(healthy)))
+		t.next++
+		healthy = append(healthy[start:], healthy[:start]...)
+	}
+	return append(healthy, unhealthy...)
+}
+
+// send posts body to endpoint, the timeout covers reading the response
+func (t *FailoverTransport) send(req *http.Request, endpoint *url.URL, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.config.Timeout)
+	out := req.Clone(ctx)
+	endpointURL := *endpoint
+	out.URL, out.Host = &endpointURL, ""
+	out.Body, out.ContentLength = io.NopCloser(bytes.NewReader(body)), int64(len(body))
+	out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *FailoverTransport) countRequest(endpoint *failoverEndpoint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	endpoint.requests++
+}
+
+func (t *FailoverTransport) markFailed(endpoint *failoverEndpoint, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	endpoint.failures++
+	endpoint.status.Healthy, endpoint.status.Err, endpoint.status.CheckedAt = false, err, t.now()
+}
+
+// HealthCheck asks every endpoint for its block number, endpoints that fail or fall more than
+// MaxBlockLag blocks behind the highest are skipped until a later check passes
+func (t *FailoverTransport) HealthCheck(ctx context.Context) {
+	statuses := make([]EndpointStatus, len(t.endpoints))
+	forEachParallel(ctx, len(t.endpoints), defaultParallelism, func(ctx context.Context, i int) error {
+		statuses[i] = EndpointStatus{URL: t.endpoints[i].url.Redacted()}
+		statuses[i].BlockNumber, statuses[i].Err = t.blockNumber(ctx, t.endpoints[i].url)
+		return nil
+	})
+	// a check cut short says nothing about the endpoints
+	if ctx.Err() != nil {
+		return
+	}
+	var highest uint64
+	for _, status := range statuses {
+		if status.Err == nil && status.BlockNumber > highest {
+			highest = status.BlockNumber
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, status := range statuses {
+		if status.Err == nil && highest-status.BlockNumber > t.config.MaxBlockLag {
+			status.Err = fmt.Errorf("block %v is %v blocks behind", status.BlockNumber, highest-status.BlockNumber)
+		}
+		status.Healthy, status.CheckedAt = status.Err == nil, t.now()
+		t.endpoints[i].status = status
+	}
+}
+
+// Run checks the endpoints every HealthCheckInterval until ctx is done
+func (t *FailoverTransport) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.config.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		t.HealthCheck(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status reports every endpoint in the configured order
+func (t *FailoverTransport) Status() []EndpointStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	statuses := make([]EndpointStatus, len(t.endpoints))
+	for i, endpoint := range t.endpoints {
+		statuses[i] = endpoint.status
+		statuses[i].Requests, statuses[i].Failures = endpoint.requests, endpoint.failures
+	}
+	return statuses
+}
+
+func (t *FailoverTransport) blockNumber(ctx context.Context, endpoint *url.URL) (uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.send(req, endpoint, []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("http status %v", resp.Status)
+	}
+	var response struct {
+		Result *hexutil.Uint64 `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, err
+	}
+	if response.Error != nil {
+		return 0, errors.New(response.Error.Message)
+	}
+	if response.Result == nil {
+		return 0, errors.New("no block number in the response")
+	}
+	return uint64(*response.Result), nil
+}
+
+// failoverStatus reports whether another endpoint may answer a request failing with status
+func failoverStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
+
+// balanced reports whether a request or batch only reads, so any endpoint can answer it
+func balanced(body []byte) bool {
+	methods, err := rpcMethods(body)
+	if err != nil {
+		return false
+	}
+	for _, method := range methods {
+		if pinnedMethods[method] {
+			return false
+		}
+	}
+	return true
+}
+
+// rpcMethods lists the methods of a JSON-RPC request or batch
+func rpcMethods(body []byte) ([]string, error) {
+	var calls []struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(body, &calls); err != nil {
+		calls = calls[:0]
+		var call struct {
+			Method string `json:"method"`
+		}
+		if err := json.Unmarshal(body, &call); err != nil {
+			return nil, err
+		}
+		calls = append(calls, call)
+	}
+	methods := make([]string, len(calls))
+	for i, call := range calls {
+		methods[i] = call.Method
+	}
+	return methods, nil
+}
+
+// cancelOnClose releases the timeout of a request once its response is read
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
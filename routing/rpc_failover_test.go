@@ -0,0 +1,132 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// rpcEndpointFake answers every call with its block number, or fails with status when it is set
+type rpcEndpointFake struct {
+	mu      sync.Mutex
+	block   uint64
+	status  int
+	methods []string
+}
+
+func (f *rpcEndpointFake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	var call struct {
+		ID     int    `json:"id"`
+		Method string `json:"method"`
+	}
+	json.Unmarshal(body, &call)
+	f.methods = append(f.methods, call.Method)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":"%v"}`, call.ID, hexutil.Uint64(f.block))
+}
+
+func (f *rpcEndpointFake) set(block uint64, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.block, f.status, f.methods = block, status, nil
+}
+
+func (f *rpcEndpointFake) calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.methods
+}
+
+func newFailoverFake(t *testing.T, config FailoverConfig, endpoints ...*rpcEndpointFake) (*ethclient.Client, *FailoverTransport) {
+	for _, endpoint := range endpoints {
+		server := httptest.NewServer(endpoint)
+		t.Cleanup(server.Close)
+		config.URLs = append(config.URLs, server.URL)
+	}
+	client, transport, err := DialFailoverEthClient(config)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	return client, transport
+}
+
+func TestFailoverTransport(t *testing.T) {
+	primary, backup := &rpcEndpointFake{block: 100}, &rpcEndpointFake{block: 100}
+	client, transport := newFailoverFake(t, FailoverConfig{}, primary, backup)
+	ctx := context.Background()
+
+	// a rate limited endpoint is skipped until a health check passes
+	primary.set(100, http.StatusTooManyRequests)
+	for i := 0; i < 2; i++ {
+		if _, err := client.BlockNumber(ctx); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+	}
+	if status := transport.Status(); status[0].Healthy || status[0].Err == nil || !status[1].Healthy || len(backup.calls()) != 2 {
+		t.Errorf("got status %+v and %v backup calls want the primary down and both calls failed over", status, len(backup.calls()))
+	}
+	primary.set(100, 0)
+	transport.HealthCheck(ctx)
+	if _, err := client.BlockNumber(ctx); err != nil || len(primary.calls()) != 2 {
+		t.Errorf("got error %v and primary calls %v want the health check and the call on the recovered primary", err, primary.calls())
+	}
+
+	// endpoints behind the others are taken out
+	backup.set(90, 0)
+	transport.HealthCheck(ctx)
+	if status := transport.Status(); !status[0].Healthy || status[1].Healthy || status[1].BlockNumber != 90 {
+		t.Errorf("got status %+v want the lagging backup down", status)
+	}
+
+	// with every endpoint down the last answer is returned
+	primary.set(100, http.StatusServiceUnavailable)
+	backup.set(100, http.StatusServiceUnavailable)
+	if _, err := client.BlockNumber(ctx); !IsRetryableError(err) {
+		t.Errorf("got error %v want a retryable HTTP error", err)
+	}
+}
+
+func TestFailoverTransportLoadBalance(t *testing.T) {
+	endpoints := []*rpcEndpointFake{{block: 100}, {block: 100}, {block: 100}}
+	client, _ := newFailoverFake(t, FailoverConfig{LoadBalance: true}, endpoints...)
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		if _, err := client.BlockNumber(ctx); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+	}
+	for i, endpoint := range endpoints {
+		if len(endpoint.calls()) != 2 {
+			t.Errorf("endpoint %v: got calls %v want 2 of the 6 reads", i, endpoint.calls())
+		}
+	}
+
+	// transactions are never spread
+	for _, endpoint := range endpoints {
+		endpoint.set(100, 0)
+	}
+	tx := types.NewTx(&types.LegacyTx{To: &common.Address{}, Gas: 21000})
+	for i := 0; i < 3; i++ {
+		client.SendTransaction(ctx, tx)
+	}
+	if len(endpoints[0].calls()) != 3 || len(endpoints[1].calls()) != 0 || len(endpoints[2].calls()) != 0 {
+		t.Errorf("got calls %v %v %v want every transaction on the first endpoint", endpoints[0].calls(), endpoints[1].calls(), endpoints[2].calls())
+	}
+}
@@ -0,0 +1,46 @@
+package routing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+)
+
+// TraceSampler decides whether a request gets detailed logging, keeping the overhead
+// bounded when quoting at high rates
+type TraceSampler interface {
+	ShouldTrace(requestID string) bool
+}
+
+// RatioSampler traces the given share of requests (0.01 is 1%). The decision is derived
+// from the request ID so every component sampling the same request agrees.
+type RatioSampler float64
+
+func (s RatioSampler) ShouldTrace(requestID string) bool {
+	if s >= 1 {
+		return true
+	}
+	if s <= 0 {
+		return false
+	}
+	hash := sha256.Sum256([]byte(requestID))
+	return float64(binary.BigEndian.Uint64(hash[:8])) < float64(s)*math.MaxUint64
+}
+
+type tracedKey struct{}
+
+// withTraceDecision records the sampling decision for the request on the context
+func withTraceDecision(ctx context.Context, sampler TraceSampler) context.Context {
+	if sampler == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracedKey{}, sampler.ShouldTrace(RequestIDFromContext(ctx)))
+}
+
+// isTraced reports whether detailed logging is enabled for the request, requests that
+// were never sampled are traced
+func isTraced(ctx context.Context) bool {
+	traced, ok := ctx.Value(tracedKey{}).(bool)
+	return !ok || traced
+}
@@ -0,0 +1,57 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRatioSampler(t *testing.T) {
+	if RatioSampler(0).ShouldTrace("quote-1") {
+		t.Errorf("a 0%% sampler must never trace")
+	}
+	if !RatioSampler(1).ShouldTrace("quote-1") {
+		t.Errorf("a 100%% sampler must always trace")
+	}
+
+	sampler := RatioSampler(0.1)
+	traced := 0
+	for i := 0; i < 10000; i++ {
+		requestID := fmt.Sprintf("quote-%d", i)
+		if sampler.ShouldTrace(requestID) != sampler.ShouldTrace(requestID) {
+			t.Fatalf("sampling decision for %v is not stable", requestID)
+		}
+		if sampler.ShouldTrace(requestID) {
+			traced++
+		}
+	}
+	if traced < 800 || traced > 1200 {
+		t.Errorf("got %d of 10000 traced want about 1000", traced)
+	}
+}
+
+func TestRouteTraceSampling(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 100, 200000)
+	router := newFakeRouter(graph)
+
+	_, _, metadata, err := router.RouteWithMetadata(context.Background(), big.NewInt(10), common.HexToAddress(WETH), common.HexToAddress(USDC), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if !metadata.Traced {
+		t.Errorf("routes are traced when no sampler is configured")
+	}
+
+	router.traceSampler = RatioSampler(0)
+	_, _, metadata, err = router.RouteWithMetadata(context.Background(), big.NewInt(10), common.HexToAddress(WETH), common.HexToAddress(USDC), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if metadata.Traced {
+		t.Errorf("got traced route with a 0%% sampler")
+	}
+}
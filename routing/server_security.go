@@ -0,0 +1,132 @@
+package routing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ServerSecurity lets QuoteServer be exposed beyond localhost without a proxy in front of it, see
+// QuoteServer.ListenAndServe. The zero value serves plain HTTP to every client.
+type ServerSecurity struct {
+	// PEM certificate chain and key to serve TLS with
+	CertFile string
+	KeyFile  string
+	// hostnames to get certificates for from Let's Encrypt instead of CertFile, cached in
+	// AutocertDir. The ACME challenge is answered over TLS, so the server has to be reachable on
+	// port 443 under these names.
+	AutocertHosts []string
+	AutocertDir   string
+	// PEM CA certificates client certificates must be signed by, enables mutual TLS
+	ClientCAFile string
+	// client IPs and CIDR ranges, e.g. 10.0.0.0/8, that may connect, every client when empty
+	AllowedIPs []string
+}
+
+// TLSConfig returns the TLS configuration of s, nil for plain HTTP
+func (s ServerSecurity) TLSConfig() (*tls.Config, error) {
+	certFiles := s.CertFile != "" || s.KeyFile != ""
+	if certFiles && len(s.AutocertHosts) > 0 {
+		return nil, errors.New("certificate files and autocert hosts are exclusive")
+	}
+	if !certFiles && len(s.AutocertHosts) == 0 {
+		if s.ClientCAFile != "" {
+			return nil, errors.New("client certificates need TLS, set a certificate or autocert hosts")
+		}
+		return nil, nil
+	}
+
+	var config *tls.Config
+	if certFiles {
+		if s.CertFile == "" || s.KeyFile == "" {
+			return nil, errors.New("TLS needs both a certificate and a key file")
+		}
+		certificate, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading the TLS certificate: %w", err)
+		}
+		config = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	} else {
+		if s.AutocertDir == "" {
+			return nil, errors.New("autocert needs a directory to cache certificates in")
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(s.AutocertHosts...),
+			Cache:      autocert.DirCache(s.AutocertDir),
+		}
+		config = manager.TLSConfig()
+	}
+	config.MinVersion = tls.VersionTLS12
+
+	if s.ClientCAFile != "" {
+		pem, err := os.ReadFile(s.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading the client CA: %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in the client CA %v", s.ClientCAFile)
+		}
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ipAllowList holds the networks clients may connect from, every client when empty
+type ipAllowList []*net.IPNet
+
+func parseIPAllowList(entries []string) (ipAllowList, error) {
+	allowed := ipAllowList{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			allowed = append(allowed, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", entry)
+		}
+		allowed = append(allowed, network)
+	}
+	return allowed, nil
+}
+
+// allows reports whether the client of r connects from an allowed address. Forwarding headers
+// are ignored, the list is meant for servers exposed without a proxy.
+func (l ipAllowList) allows(r *http.Request) bool {
+	if len(l) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
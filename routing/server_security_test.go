@@ -0,0 +1,143 @@
+package routing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuoteServerAllowedIPs(t *testing.T) {
+	server := NewQuoteServer(newFakeRouter(&v2GraphFake{}), &v2GraphFake{})
+	if err := server.SetAllowedIPs([]string{"10.0.0.0/8", "192.168.1.7", "::1"}); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	for remoteAddr, want := range map[string]int{
+		"10.1.2.3:5000":    http.StatusOK,
+		"192.168.1.7:5000": http.StatusOK,
+		"[::1]:5000":       http.StatusOK,
+		"192.168.1.8:5000": http.StatusForbidden,
+		"[::2]:5000":       http.StatusForbidden,
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/pools", nil)
+		request.RemoteAddr = remoteAddr
+		// forwarding headers are not trusted
+		request.Header.Set("X-Forwarded-For", "10.1.2.3")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		if recorder.Code != want {
+			t.Errorf("%v: got status %v want %v", remoteAddr, recorder.Code, want)
+		}
+	}
+	if err := server.SetAllowedIPs([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("got no error for an invalid range")
+	}
+}
+
+func TestServerSecurityMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCertificate(t, nil, nil, "test ca")
+	serverCert, serverKey := newTestCertificate(t, ca, caKey, "127.0.0.1")
+	clientCert, clientKey := newTestCertificate(t, ca, caKey, "client")
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", serverCert.Raw)
+	serverKeyDER, _ := x509.MarshalECPrivateKey(serverKey)
+	writePEM(t, filepath.Join(dir, "server-key.pem"), "EC PRIVATE KEY", serverKeyDER)
+
+	security := ServerSecurity{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server-key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	config, err := security.TLSConfig()
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	server := httptest.NewUnstartedServer(NewQuoteServer(newFakeRouter(&v2GraphFake{}), &v2GraphFake{}))
+	server.TLS = config
+	// the refused handshake is logged otherwise
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	client := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates}}}
+	}
+	response, err := client(tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}).Get(server.URL + "/pools")
+	if err != nil {
+		t.Fatalf("got error %v with a client certificate", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("got status %v want 200", response.StatusCode)
+	}
+	if response, err := client().Get(server.URL + "/pools"); err == nil {
+		response.Body.Close()
+		t.Errorf("got status %v without a client certificate want the handshake refused", response.StatusCode)
+	}
+
+	for _, invalid := range []ServerSecurity{
+		{ClientCAFile: security.ClientCAFile},
+		{CertFile: security.CertFile},
+		{CertFile: security.CertFile, KeyFile: security.KeyFile, AutocertHosts: []string{"quotes.example.com"}},
+		{AutocertHosts: []string{"quotes.example.com"}},
+	} {
+		if _, err := invalid.TLSConfig(); err == nil {
+			t.Errorf("got no error for %+v", invalid)
+		}
+	}
+}
+
+// newTestCertificate returns a certificate for name signed by parent, self-signed CA when nil
+func newTestCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	}
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate, key
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,281 @@
+package routing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are what a deployment of the router configures without recompiling, read from a YAML
+// file by LoadSettings and overridden by environment variables. Every field is optional, the
+// zero values fall back to the chain's registry entry and the library defaults.
+type Settings struct {
+	// registered chain by name or ID, mainnet by default. ROUTER_CHAIN
+	Chain string `yaml:"chain"`
+	RPC   struct {
+		// HTTP endpoints in order of preference, the chain's public endpoint by default.
+		// ROUTER_RPC_URLS, separated by commas
+		URLs []string `yaml:"urls"`
+		// ROUTER_RPC_LOAD_BALANCE
+		LoadBalance bool `yaml:"loadBalance"`
+		// per request and endpoint, see FailoverConfig. ROUTER_RPC_TIMEOUT
+		Timeout time.Duration `yaml:"timeout"`
+		// websocket endpoint for Sync subscriptions, the chain's by default. ROUTER_WS_URL
+		WSURL string `yaml:"wsUrl"`
+	} `yaml:"rpc"`
+	// Uniswap V2 factory and its pair init code hash, for forks on the chain. ROUTER_FACTORY and
+	// ROUTER_INIT_CODE_HASH
+	Factory      string `yaml:"factory"`
+	InitCodeHash string `yaml:"initCodeHash"`
+	// tokens searched between, the chain's base tokens by default. ROUTER_BASE_TOKENS, separated
+	// by commas
+	BaseTokens []string `yaml:"baseTokens"`
+	// pools holding less are not searched, see NewOnChainPoolsProvider. ROUTER_MIN_LIQUIDITY_USD
+	MinLiquidityUSD float64 `yaml:"minLiquidityUSD"`
+	// slippage tolerance of quotes. ROUTER_SLIPPAGE_BIPS
+	SlippageBips int64 `yaml:"slippageBips"`
+	Cache        struct {
+		// age at which pool registry entries are read again, a day by default.
+		// ROUTER_REGISTRY_TTL
+		RegistryTTL time.Duration `yaml:"registryTTL"`
+		// tokens whose decimals are kept in memory. ROUTER_DECIMALS_CACHE_SIZE
+		DecimalsSize int `yaml:"decimalsSize"`
+	} `yaml:"cache"`
+	Concurrency struct {
+		// concurrent RPC calls while building a route's graph. ROUTER_PARALLELISM
+		Parallelism int `yaml:"parallelism"`
+	} `yaml:"concurrency"`
+	// DefaultRetryPolicy by default. ROUTER_RETRY_ATTEMPTS, ROUTER_RETRY_DELAY and
+	// ROUTER_RETRY_MAX_DELAY
+	Retry struct {
+		Attempts int           `yaml:"attempts"`
+		Delay    time.Duration `yaml:"delay"`
+		MaxDelay time.Duration `yaml:"maxDelay"`
+	} `yaml:"retry"`
+	// records below the level are dropped, see NewLogger. ROUTER_LOG_LEVEL and ROUTER_LOG_FORMAT
+	Log struct {
+		// debug, info, warn or error, info by default
+		Level string `yaml:"level"`
+		// text or json, text by default
+		Format string `yaml:"format"`
+	} `yaml:"log"`
+
+	chain Chain
+}
+
+// SettingsError lists every problem found in the settings, each naming the key or environment
+// variable it comes from
+type SettingsError struct {
+	Source   string
+	Problems []string
+}
+
+func (e *SettingsError) Error() string {
+	return fmt.Sprintf("invalid settings in %v:\n  %v", e.Source, strings.Join(e.Problems, "\n  "))
+}
+
+// LoadSettings reads the YAML file at path, "" for none, applies the overrides of getenv (e.g.
+// os.Getenv) and validates the result. Unknown keys are errors, so misspelled settings are not
+// silently ignored.
+func LoadSettings(path string, getenv func(string) string) (*Settings, error) {
+	settings := &Settings{}
+	source := "the environment"
+	if path != "" {
+		source = path + " and the environment"
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading settings: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(settings); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("parsing settings %v: %w", path, err)
+		}
+	}
+	problems := settings.applyEnv(getenv)
+	problems = append(problems, settings.validate()...)
+	if len(problems) > 0 {
+		return nil, &SettingsError{Source: source, Problems: problems}
+	}
+	return settings, nil
+}
+
+// applyEnv overrides the settings with the environment variables that are set
+func (s *Settings) applyEnv(getenv func(string) string) []string {
+	problems := []string{}
+	parse := func(name string, apply func(value string) error) {
+		value := getenv(name)
+		if value == "" {
+			return
+		}
+		if err := apply(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%v=%q: %v", name, value, err))
+		}
+	}
+	list := func(value string) []string {
+		items := strings.Split(value, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		return items
+	}
+	parse("ROUTER_CHAIN", func(value string) error { s.Chain = value; return nil })
+	parse("ROUTER_RPC_URLS", func(value string) error { s.RPC.URLs = list(value); return nil })
+	parse("ROUTER_RPC_LOAD_BALANCE", func(value string) (err error) { s.RPC.LoadBalance, err = strconv.ParseBool(value); return })
+	parse("ROUTER_RPC_TIMEOUT", func(value string) (err error) { s.RPC.Timeout, err = time.ParseDuration(value); return })
+	parse("ROUTER_WS_URL", func(value string) error { s.RPC.WSURL = value; return nil })
+	parse("ROUTER_FACTORY", func(value string) error { s.Factory = value; return nil })
+	parse("ROUTER_INIT_CODE_HASH", func(value string) error { s.InitCodeHash = value; return nil })
+	parse("ROUTER_BASE_TOKENS", func(value string) error { s.BaseTokens = list(value); return nil })
+	parse("ROUTER_MIN_LIQUIDITY_USD", func(value string) (err error) { s.MinLiquidityUSD, err = strconv.ParseFloat(value, 64); return })
+	parse("ROUTER_SLIPPAGE_BIPS", func(value string) (err error) { s.SlippageBips, err = strconv.ParseInt(value, 10, 64); return })
+	parse("ROUTER_REGISTRY_TTL", func(value string) (err error) { s.Cache.RegistryTTL, err = time.ParseDuration(value); return })
+	parse("ROUTER_DECIMALS_CACHE_SIZE", func(value string) (err error) { s.Cache.DecimalsSize, err = strconv.Atoi(value); return })
+	parse("ROUTER_PARALLELISM", func(value string) (err error) { s.Concurrency.Parallelism, err = strconv.Atoi(value); return })
+	parse("ROUTER_RETRY_ATTEMPTS", func(value string) (err error) { s.Retry.Attempts, err = strconv.Atoi(value); return })
+	parse("ROUTER_RETRY_DELAY", func(value string) (err error) { s.Retry.Delay, err = time.ParseDuration(value); return })
+	parse("ROUTER_RETRY_MAX_DELAY", func(value string) (err error) { s.Retry.MaxDelay, err = time.ParseDuration(value); return })
+	parse("ROUTER_LOG_LEVEL", func(value string) error { s.Log.Level = value; return nil })
+	parse("ROUTER_LOG_FORMAT", func(value string) error { s.Log.Format = value; return nil })
+	return problems
+}
+
+// validate checks every setting and resolves the chain they configure
+func (s *Settings) validate() []string {
+	problems := []string{}
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	s.chain = Mainnet
+	if s.Chain != "" {
+		chain, err := LookupChain(s.Chain)
+		if err != nil {
+			problem("chain: %v", err)
+		}
+		s.chain = chain
+	}
+	for i, rawurl := range s.RPC.URLs {
+		if err := checkURL(rawurl, "http", "https"); err != nil {
+			problem("rpc.urls[%v]: %v", i, err)
+		}
+	}
+	if s.RPC.WSURL != "" {
+		if err := checkURL(s.RPC.WSURL, "ws", "wss"); err != nil {
+			problem("rpc.wsUrl: %v", err)
+		}
+	}
+	if s.Factory != "" && !common.IsHexAddress(s.Factory) {
+		problem("factory: %q is not an address", s.Factory)
+	}
+	if s.InitCodeHash != "" && len(common.FromHex(s.InitCodeHash)) != common.HashLength {
+		problem("initCodeHash: %q is not a 32 byte hex hash", s.InitCodeHash)
+	}
+	if (s.Factory == "") != (s.InitCodeHash == "") {
+		problem("factory and initCodeHash: set both to route on a V2 fork, pair addresses are computed from the two")
+	}
+	seen := make(map[common.Address]bool)
+	for i, token := range s.BaseTokens {
+		if !common.IsHexAddress(token) {
+			problem("baseTokens[%v]: %q is not an address", i, token)
+			continue
+		}
+		if seen[common.HexToAddress(token)] {
+			problem("baseTokens[%v]: %v is listed twice", i, token)
+		}
+		seen[common.HexToAddress(token)] = true
+	}
+	if len(s.BaseTokens) == 1 {
+		problem("baseTokens: a single token has no pools between base tokens, list at least two")
+	}
+	if s.MinLiquidityUSD < 0 {
+		problem("minLiquidityUSD: %v is negative", s.MinLiquidityUSD)
+	}
+	if s.SlippageBips < 0 || s.SlippageBips >= 10000 {
+		problem("slippageBips: %v is not between 0 and 9999", s.SlippageBips)
+	}
+	if s.RPC.Timeout < 0 || s.Cache.RegistryTTL < 0 || s.Retry.Delay < 0 || s.Retry.MaxDelay < 0 {
+		problem("rpc.timeout, cache.registryTTL, retry.delay and retry.maxDelay: durations cannot be negative")
+	}
+	if s.Cache.DecimalsSize < 0 || s.Concurrency.Parallelism < 0 || s.Retry.Attempts < 0 {
+		problem("cache.decimalsSize, concurrency.parallelism and retry.attempts: counts cannot be negative")
+	}
+	if _, err := NewLogger(io.Discard, s.Log.Level, s.Log.Format); err != nil {
+		problem("log: %v", err)
+	}
+	return problems
+}
+
+func checkURL(rawurl string, schemes ...string) error {
+	parsed, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme && parsed.Host != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a %v URL", rawurl, strings.Join(schemes, " or "))
+}
+
+// ChainConfig is the registered chain with the RPC endpoints, factory and base tokens of the
+// settings in place of its own
+func (s *Settings) ChainConfig() Chain {
+	chain := s.chain
+	if len(s.RPC.URLs) > 0 {
+		chain.RPCURL = s.RPC.URLs[0]
+	}
+	if s.RPC.WSURL != "" {
+		chain.WSURL = s.RPC.WSURL
+	}
+	if s.Factory != "" {
+		chain.V2Factory, chain.V2InitCodeHash = common.HexToAddress(s.Factory), common.HexToHash(s.InitCodeHash)
+	}
+	if len(s.BaseTokens) > 0 {
+		chain.BaseTokens = addresses(s.BaseTokens...)
+	}
+	return chain
+}
+
+// RPCURLs are the endpoints to dial, the chain's public endpoint when none are set
+func (s *Settings) RPCURLs() []string {
+	if len(s.RPC.URLs) > 0 {
+		return s.RPC.URLs
+	}
+	return []string{s.chain.RPCURL}
+}
+
+// Logger writes the records of the log level and above to w in the log format
+func (s *Settings) Logger(w io.Writer) *slog.Logger {
+	// validated on load
+	logger, err := NewLogger(w, s.Log.Level, s.Log.Format)
+	if err != nil {
+		return slog.New(slog.NewTextHandler(w, nil))
+	}
+	return logger
+}
+
+// RetryPolicy is DefaultRetryPolicy with the retry settings that are set
+func (s *Settings) RetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy
+	if s.Retry.Attempts > 0 {
+		policy.Attempts = s.Retry.Attempts
+	}
+	if s.Retry.Delay > 0 {
+		policy.Delay = s.Retry.Delay
+	}
+	if s.Retry.MaxDelay > 0 {
+		policy.MaxDelay = s.Retry.MaxDelay
+	}
+	return policy
+}
@@ -0,0 +1,85 @@
+package routing
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func envFake(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+func TestLoadSettings(t *testing.T) {
+	// the example shipped with the binary stays valid
+	settings, err := LoadSettings("../cmd/router/router.example.yaml", envFake(nil))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	chain := settings.ChainConfig()
+	if chain.ID != Base.ID || len(chain.BaseTokens) != 3 || chain.RPCURL != "https://mainnet.base.org" || len(settings.RPCURLs()) != 2 || !settings.RPC.LoadBalance {
+		t.Errorf("got chain %+v endpoints %v want Base with the file's tokens and endpoints", chain, settings.RPCURLs())
+	}
+	if settings.Cache.RegistryTTL != 12*time.Hour || settings.Concurrency.Parallelism != 16 || settings.RetryPolicy().Attempts != 4 || settings.RetryPolicy().Jitter != DefaultRetryPolicy.Jitter {
+		t.Errorf("got settings %+v want the file's durations and limits", settings)
+	}
+
+	// the environment overrides the file
+	settings, err = LoadSettings("../cmd/router/router.example.yaml", envFake(map[string]string{
+		"ROUTER_CHAIN":          "arbitrum",
+		"ROUTER_RPC_URLS":       "https://a.example, https://b.example",
+		"ROUTER_BASE_TOKENS":    "",
+		"ROUTER_PARALLELISM":    "4",
+		"ROUTER_FACTORY":        FACTORY_ADDRESS,
+		"ROUTER_INIT_CODE_HASH": INIT_CODE_HASH,
+	}))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	chain = settings.ChainConfig()
+	if chain.ID != Arbitrum.ID || chain.RPCURL != "https://a.example" || chain.V2Factory != common.HexToAddress(FACTORY_ADDRESS) || settings.Concurrency.Parallelism != 4 || len(chain.BaseTokens) != 3 {
+		t.Errorf("got chain %+v parallelism %v want Arbitrum with the overrides", chain, settings.Concurrency.Parallelism)
+	}
+
+	// without a file every setting defaults to Mainnet
+	settings, err = LoadSettings("", envFake(nil))
+	if err != nil || settings.ChainConfig().ID != Mainnet.ID || settings.RPCURLs()[0] != MAINNET_INFURA_RPC || settings.RetryPolicy().Delay != DefaultRetryPolicy.Delay {
+		t.Errorf("got settings %+v error %v want Mainnet's defaults", settings, err)
+	}
+}
+
+func TestLoadSettingsErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "router.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		return path
+	}
+
+	if _, err := LoadSettings(write("chain: base\nrpc:\n  url: https://mainnet.base.org\n"), envFake(nil)); err == nil || !strings.Contains(err.Error(), "field url not found") {
+		t.Errorf("got error %v want the misspelled key", err)
+	}
+
+	// every problem is reported at once, naming its key or variable
+	path := write("chain: solana\nrpc:\n  urls: [mainnet.base.org]\nfactory: 0x1234\nbaseTokens: [" + WETH + ", " + WETH + "]\nslippageBips: 10000\n")
+	_, err := LoadSettings(path, envFake(map[string]string{"ROUTER_REGISTRY_TTL": "a day"}))
+	var settingsErr *SettingsError
+	if !errors.As(err, &settingsErr) {
+		t.Fatalf("got error %v want a SettingsError", err)
+	}
+	for _, want := range []string{"ROUTER_REGISTRY_TTL", "chain:", "rpc.urls[0]", "factory:", "initCodeHash", "baseTokens[1]", "slippageBips"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %v want a problem with %v", err, want)
+		}
+	}
+	if len(settingsErr.Problems) != 7 {
+		t.Errorf("got %v problems want 7: %v", len(settingsErr.Problems), settingsErr.Problems)
+	}
+}
@@ -0,0 +1,121 @@
+package routing
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Signer signs transactions with the private key of a single account
+type Signer struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+func newSigner(key *ecdsa.PrivateKey) *Signer {
+	return &Signer{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// NewPrivateKeySigner loads a raw hex private key, with or without 0x
+func NewPrivateKeySigner(hexKey string) (*Signer, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(hexKey), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return newSigner(key), nil
+}
+
+// NewKeystoreSigner decrypts a geth style keystore file
+func NewKeystoreSigner(path, passphrase string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting keystore %v: %w", path, err)
+	}
+	return newSigner(key.PrivateKey), nil
+}
+
+// NewMnemonicSigner derives the key at path (BIP-32) from a BIP-39 mnemonic, e.g.
+// accounts.DefaultBaseDerivationPath for the first account of most wallets
+func NewMnemonicSigner(mnemonic, passphrase string, path accounts.DerivationPath) (*Signer, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	key, err := deriveKey(seed, path)
+	if err != nil {
+		return nil, err
+	}
+	return newSigner(key), nil
+}
+
+// deriveKey walks the BIP-32 private key derivation down path
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := new(big.Int).SetBytes(sum[:32]), sum[32:]
+	n := crypto.S256().Params().N
+	for _, index := range path {
+		var data []byte
+		if index >= 0x80000000 {
+			data = append([]byte{0}, common.LeftPadBytes(key.Bytes(), 32)...)
+		} else {
+			private, err := crypto.ToECDSA(common.LeftPadBytes(key.Bytes(), 32))
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&private.PublicKey)
+		}
+		data = binary.BigEndian.AppendUint32(data, index)
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(n) >= 0 {
+			return nil, errors.New("invalid derived key, try the next index")
+		}
+		key.Add(key, tweak).Mod(key, n)
+		if key.Sign() == 0 {
+			return nil, errors.New("invalid derived key, try the next index")
+		}
+		chainCode = sum[32:]
+	}
+	return crypto.ToECDSA(common.LeftPadBytes(key.Bytes(), 32))
+}
+
+func (s *Signer) Address() common.Address {
+	return s.address
+}
+
+// SignTx signs tx for chainID with the latest signer the chain supports
+func (s *Signer) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// SignHash signs a 32 byte digest, e.g. of EIP-712 typed data, returning r, s and v with v as 27
+// or 28 like ecrecover expects
+func (s *Signer) SignHash(hash common.Hash) ([]byte, error) {
+	signature, err := crypto.Sign(hash.Bytes(), s.key)
+	if err != nil {
+		return nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
@@ -0,0 +1,80 @@
+package routing
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// the well known test mnemonic and its first accounts
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestMnemonicSigner(t *testing.T) {
+	tests := map[string]string{
+		"m/44'/60'/0'/0/0": "0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+		"m/44'/60'/0'/0/1": "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0",
+	}
+	for path, want := range tests {
+		derivationPath, err := accounts.ParseDerivationPath(path)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		signer, err := NewMnemonicSigner(testMnemonic, "", derivationPath)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if signer.Address() != common.HexToAddress(want) {
+			t.Errorf("%v: got %v want %v", path, signer.Address(), want)
+		}
+	}
+	if _, err := NewMnemonicSigner("abandon abandon abandon", "", accounts.DefaultBaseDerivationPath); err == nil {
+		t.Error("got no error for an invalid mnemonic")
+	}
+}
+
+func TestPrivateKeyAndKeystoreSigners(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer, err := NewPrivateKeySigner("0x" + common.Bytes2Hex(crypto.FromECDSA(key)))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if want := crypto.PubkeyToAddress(key.PublicKey); signer.Address() != want {
+		t.Errorf("got %v want %v", signer.Address(), want)
+	}
+	if _, err := NewPrivateKeySigner("0x1234"); err == nil {
+		t.Error("got no error for a short key")
+	}
+
+	encrypted, err := keystore.EncryptKey(&keystore.Key{Id: uuid.New(), Address: signer.Address(), PrivateKey: key}, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.json")
+	os.WriteFile(path, encrypted, 0o600)
+	keystoreSigner, err := NewKeystoreSigner(path, "secret")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if keystoreSigner.Address() != signer.Address() {
+		t.Errorf("got %v want %v", keystoreSigner.Address(), signer.Address())
+	}
+	if _, err := NewKeystoreSigner(path, "wrong"); err == nil {
+		t.Error("got no error for a wrong passphrase")
+	}
+
+	tx, err := signer.SignTx(types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), Gas: 21000}), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), tx); err != nil || from != signer.Address() {
+		t.Errorf("got sender %v error %v want %v", from, err, signer.Address())
+	}
+}
@@ -0,0 +1,236 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SimulationIssueKind is what a simulation found wrong with a swap
+type SimulationIssueKind string
+
+const (
+	SimulationReverted SimulationIssueKind = "reverted"
+	// a token takes a fee on transfers, the recipient gets less than the pair paid out
+	SimulationFeeOnTransfer SimulationIssueKind = "fee-on-transfer"
+	// the swap gives less than the quote beyond its slippage tolerance
+	SimulationSlippage SimulationIssueKind = "slippage"
+)
+
+type SimulationIssue struct {
+	Kind   SimulationIssueKind
+	Detail string
+}
+
+// SimulationResult is a swap executed against the latest state without broadcasting it
+type SimulationResult struct {
+	Reverted bool
+	// the revert reason when the contract gave one
+	RevertReason string
+	// output Router02 computed from the reserves, nil for Universal Router swaps and reverts
+	RouterAmountOut *big.Int
+	// tokenOut, or ETH for NativeOut, that reached the recipient. nil when only eth_call was
+	// available, which returns no transfers.
+	AmountReceived *big.Int
+	GasUsed        uint64
+	Issues         []SimulationIssue
+}
+
+// OK reports whether the swap can be sent as quoted
+func (r *SimulationResult) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// SimulationError is returned by Executor when the simulation of a swap flags it
+type SimulationError struct {
+	Result *SimulationResult
+}
+
+func (e *SimulationError) Error() string {
+	details := []string{}
+	for _, issue := range e.Result.Issues {
+		details = append(details, fmt.Sprintf("%v: %v", issue.Kind, issue.Detail))
+	}
+	return "swap simulation failed: " + strings.Join(details, "; ")
+}
+
+// revert reasons of Router02 and the V2 pairs that have a known cause
+var (
+	slippageReverts      = []string{"INSUFFICIENT_OUTPUT_AMOUNT", "EXCESSIVE_INPUT_AMOUNT"}
+	feeOnTransferReverts = []string{"UniswapV2: K"}
+	transferTopic        = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+// Simulator executes built swaps with debug_traceCall (callTracer with logs) to compare what the
+// recipient receives with the quote, and falls back to eth_call on nodes without the debug API,
+// which only catches reverts and Router02's computed output
+type Simulator struct {
+	client *rpc.Client
+}
+
+func NewSimulator(client *rpc.Client) *Simulator {
+	return &Simulator{client: client}
+}
+
+// callFrame is a call of the callTracer's output
+type callFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to"`
+	Value        *hexutil.Big    `json:"value"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Output       hexutil.Bytes   `json:"output"`
+	Error        string          `json:"error"`
+	RevertReason string          `json:"revertReason"`
+	Calls        []callFrame     `json:"calls"`
+	Logs         []callLog       `json:"logs"`
+}
+
+type callLog struct {
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+}
+
+// Simulate runs swap, built from quote with options, from the account from at the latest block
+func (s *Simulator) Simulate(ctx context.Context, from common.Address, swap *SwapTx, quote *Quote, options SwapOptions) (*SimulationResult, error) {
+	call := ethereum.CallMsg{From: from, To: &swap.To, Value: swap.Value, Data: swap.Data}
+	result := &SimulationResult{}
+	var frame callFrame
+	config := map[string]interface{}{"tracer": "callTracer", "tracerConfig": map[string]interface{}{"withLog": true}}
+	err := s.client.CallContext(ctx, &frame, "debug_traceCall", toCallArg(call), "latest", config)
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601 {
+		if err := s.call(ctx, call, result); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else {
+		result.GasUsed = uint64(frame.GasUsed)
+		if frame.Error != "" {
+			result.Reverted, result.RevertReason = true, frame.RevertReason
+			if result.RevertReason == "" {
+				result.RevertReason = frame.Error
+			}
+		} else {
+			result.RouterAmountOut = router02AmountOut(swap, frame.Output)
+			result.AmountReceived = received(&frame, quote.TokenOut, options)
+		}
+	}
+	result.Issues = simulationIssues(result, quote)
+	return result, nil
+}
+
+// call runs the swap with eth_call, reverts come back as errors carrying the revert data
+func (s *Simulator) call(ctx context.Context, call ethereum.CallMsg, result *SimulationResult) error {
+	var output hexutil.Bytes
+	err := s.client.CallContext(ctx, &output, "eth_call", toCallArg(call), "latest")
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		result.Reverted, result.RevertReason = true, err.Error()
+		if data, ok := dataErr.ErrorData().(string); ok {
+			if reason, unpackErr := abi.UnpackRevert(common.FromHex(data)); unpackErr == nil {
+				result.RevertReason = reason
+			}
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result.RouterAmountOut = router02AmountOut(&SwapTx{To: *call.To, Data: call.Data}, output)
+	return nil
+}
+
+// router02AmountOut is the last of the amounts Router02's swaps return
+func router02AmountOut(swap *SwapTx, output []byte) *big.Int {
+	if _, err := router02Parsed.MethodById(swap.Data); err != nil || len(output) == 0 {
+		return nil
+	}
+	values, err := abi.Arguments{{Type: abiType("uint256[]")}}.Unpack(output)
+	if err != nil {
+		return nil
+	}
+	amounts := values[0].([]*big.Int)
+	if len(amounts) == 0 {
+		return nil
+	}
+	return amounts[len(amounts)-1]
+}
+
+// received sums the transfers of tokenOut, or the ETH sent, to the recipient in the calls that
+// did not revert
+func received(frame *callFrame, tokenOut common.Address, options SwapOptions) *big.Int {
+	total := new(big.Int)
+	var walk func(frame *callFrame, top bool)
+	walk = func(frame *callFrame, top bool) {
+		if frame.Error != "" {
+			return
+		}
+		if options.NativeOut && !top && frame.To != nil && *frame.To == options.Recipient && frame.Value != nil {
+			total.Add(total, frame.Value.ToInt())
+		}
+		for _, log := range frame.Logs {
+			if options.NativeOut || log.Address != tokenOut || len(log.Topics) != 3 || log.Topics[0] != transferTopic {
+				continue
+			}
+			if common.BytesToAddress(log.Topics[2].Bytes()) == options.Recipient {
+				total.Add(total, new(big.Int).SetBytes(log.Data))
+			}
+		}
+		for i := range frame.Calls {
+			walk(&frame.Calls[i], false)
+		}
+	}
+	walk(frame, true)
+	return total
+}
+
+func simulationIssues(result *SimulationResult, quote *Quote) []SimulationIssue {
+	issues := []SimulationIssue{}
+	if result.Reverted {
+		issues = append(issues, SimulationIssue{Kind: SimulationReverted, Detail: result.RevertReason})
+		for _, reason := range slippageReverts {
+			if strings.Contains(result.RevertReason, reason) {
+				issues = append(issues, SimulationIssue{Kind: SimulationSlippage, Detail: "the reserves moved beyond the quote's slippage tolerance"})
+			}
+		}
+		for _, reason := range feeOnTransferReverts {
+			if strings.Contains(result.RevertReason, reason) {
+				issues = append(issues, SimulationIssue{Kind: SimulationFeeOnTransfer, Detail: "the pair received less than sent, the input token likely takes a transfer fee"})
+			}
+		}
+		return issues
+	}
+	if result.AmountReceived == nil {
+		return issues
+	}
+	if result.RouterAmountOut != nil && result.AmountReceived.Cmp(result.RouterAmountOut) < 0 {
+		issues = append(issues, SimulationIssue{
+			Kind:   SimulationFeeOnTransfer,
+			Detail: fmt.Sprintf("recipient received %v of the %v paid out", result.AmountReceived, result.RouterAmountOut),
+		})
+	}
+	// exact-out swaps must deliver the amount, exact-in swaps at least the quote's minimum
+	least := quote.AmountOutMin
+	if quote.ExactOut {
+		least = quote.AmountOut
+	}
+	if least != nil && result.AmountReceived.Cmp(least) < 0 {
+		issues = append(issues, SimulationIssue{
+			Kind:   SimulationSlippage,
+			Detail: fmt.Sprintf("recipient received %v, the quote expects %v and at least %v", result.AmountReceived, quote.AmountOut, least),
+		})
+	}
+	return issues
+}
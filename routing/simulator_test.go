@@ -0,0 +1,126 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// traceServiceFake answers debug_traceCall with a canned callTracer frame
+type traceServiceFake struct {
+	frame callFrame
+}
+
+func (s *traceServiceFake) TraceCall(args map[string]interface{}, block string, config map[string]interface{}) (callFrame, error) {
+	return s.frame, nil
+}
+
+// revertError is how nodes report reverts, with the revert data
+type revertError struct {
+	data []byte
+}
+
+func (e revertError) Error() string          { return "execution reverted" }
+func (e revertError) ErrorCode() int         { return 3 }
+func (e revertError) ErrorData() interface{} { return hexutil.Encode(e.data) }
+
+// callServiceFake answers eth_call with a revert
+type callServiceFake struct {
+	reason string
+}
+
+func (s *callServiceFake) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	data, _ := abiErrorString.Inputs.Pack(s.reason)
+	return nil, revertError{data: append(abiErrorString.ID, data...)}
+}
+
+var abiErrorString = mustParseABI(`[{"inputs":[{"name":"reason","type":"string"}],"name":"Error","outputs":[],"stateMutability":"pure","type":"function"}]`).Methods["Error"]
+
+func newSimulatorFake(t *testing.T, services map[string]interface{}) *Simulator {
+	server := rpc.NewServer()
+	for name, service := range services {
+		if err := server.RegisterName(name, service); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+	}
+	t.Cleanup(server.Stop)
+	return NewSimulator(rpc.DialInProc(server))
+}
+
+// swapFrame is a Router02 call returning amounts whose pair sends received of tokenOut to recipient
+func swapFrame(tokenOut, recipient common.Address, amounts []*big.Int, received *big.Int) callFrame {
+	output, _ := abi.Arguments{{Type: abiType("uint256[]")}}.Pack(amounts)
+	transfer := callFrame{Type: "CALL", To: &tokenOut}
+	transfer.Logs = append(transfer.Logs, callLog{Address: tokenOut, Topics: []common.Hash{transferTopic, common.BytesToHash(common.HexToAddress(WETH_USDC).Bytes()), common.BytesToHash(recipient.Bytes())}, Data: common.LeftPadBytes(received.Bytes(), 32)})
+	return callFrame{Type: "CALL", GasUsed: 120000, Output: output, Calls: []callFrame{transfer}}
+}
+
+func TestSimulator(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	quote, err := newFakeRouter(graph).Route(context.Background(), big.NewInt(1000), weth, usdc, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	recipient := common.HexToAddress("0x1234")
+	options := SwapOptions{Recipient: recipient}
+	swap, err := NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil).Build(quote, options)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	amounts := []*big.Int{quote.AmountIn, quote.AmountOut}
+	// 2% less arrives than the pair paid out
+	taxed := new(big.Int).Div(new(big.Int).Mul(quote.AmountOut, big.NewInt(98)), big.NewInt(100))
+
+	tests := []struct {
+		name     string
+		services map[string]interface{}
+		want     []SimulationIssueKind
+		received *big.Int
+	}{
+		{"matches the quote", map[string]interface{}{"debug": &traceServiceFake{frame: swapFrame(usdc, recipient, amounts, quote.AmountOut)}}, nil, quote.AmountOut},
+		{"taxed output", map[string]interface{}{"debug": &traceServiceFake{frame: swapFrame(usdc, recipient, amounts, taxed)}}, []SimulationIssueKind{SimulationFeeOnTransfer, SimulationSlippage}, taxed},
+		{"moved reserves", map[string]interface{}{"debug": &traceServiceFake{frame: callFrame{Error: "execution reverted", RevertReason: "UniswapV2Router: INSUFFICIENT_OUTPUT_AMOUNT"}}}, []SimulationIssueKind{SimulationReverted, SimulationSlippage}, nil},
+		// without the debug API reverts still show with eth_call
+		{"taxed input", map[string]interface{}{"eth": &callServiceFake{reason: "UniswapV2: K"}}, []SimulationIssueKind{SimulationReverted, SimulationFeeOnTransfer}, nil},
+	}
+	for _, test := range tests {
+		result, err := newSimulatorFake(t, test.services).Simulate(context.Background(), recipient, swap, quote, options)
+		if err != nil {
+			t.Fatalf("%v: got error %v", test.name, err)
+		}
+		kinds := []SimulationIssueKind{}
+		for _, issue := range result.Issues {
+			kinds = append(kinds, issue.Kind)
+		}
+		if fmt.Sprint(kinds) != fmt.Sprint(append([]SimulationIssueKind{}, test.want...)) || result.OK() != (len(test.want) == 0) {
+			t.Errorf("%v: got issues %+v want %v", test.name, result.Issues, test.want)
+		}
+		if (test.received == nil) != (result.AmountReceived == nil) || (test.received != nil && test.received.Cmp(result.AmountReceived) != 0) {
+			t.Errorf("%v: got %v received want %v", test.name, result.AmountReceived, test.received)
+		}
+	}
+
+	// the executor refuses to send flagged swaps
+	key, _ := crypto.GenerateKey()
+	backend := &transactionBackendFake{}
+	builder := NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), map[common.Address]ExposureLimit{weth: {PerDay: big.NewInt(1000000)}})
+	executor := NewExecutor(backend, newSigner(key), builder)
+	executor.SetSimulator(newSimulatorFake(t, map[string]interface{}{"debug": &traceServiceFake{frame: swapFrame(usdc, recipient, amounts, taxed)}}))
+	var simulationErr *SimulationError
+	if _, err := executor.Execute(context.Background(), quote, options); !errors.As(err, &simulationErr) || len(backend.sent) != 0 {
+		t.Errorf("got error %v and %v transactions want a SimulationError and nothing sent", err, len(backend.sent))
+	}
+	if used := builder.DailyExposure(weth); used.Sign() != 0 {
+		t.Errorf("got %v WETH used want the refused swap released", used)
+	}
+}
@@ -0,0 +1,43 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/sync/singleflight"
+)
+
+// SingleflightPoolReservesProvider allows at most one in-flight reserves read per pool and block,
+// concurrent callers asking for the same pool share its result
+type SingleflightPoolReservesProvider struct {
+	provider PoolReservesProvider
+	group    singleflight.Group
+}
+
+func NewSingleflightPoolReservesProvider(provider PoolReservesProvider) *SingleflightPoolReservesProvider {
+	return &SingleflightPoolReservesProvider{provider: provider}
+}
+
+func (p *SingleflightPoolReservesProvider) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	// overrides differ between callers, their reads are never shared
+	if StateOverrideFromContext(ctx) != nil {
+		return p.provider.GetPoolReserves(ctx, pairAddress)
+	}
+	// reads pinned to different blocks must not share a result
+	key := fmt.Sprintf("%v@%v", pairAddress.Hex(), BlockNumberFromContext(ctx))
+	result, err, _ := p.group.Do(key, func() (interface{}, error) {
+		reserve0, reserve1, err := p.provider.GetPoolReserves(ctx, pairAddress)
+		if err != nil {
+			return nil, err
+		}
+		return [2]*big.Int{reserve0, reserve1}, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	reserves := result.([2]*big.Int)
+	// callers get their own copies since the result is shared
+	return new(big.Int).Set(reserves[0]), new(big.Int).Set(reserves[1]), nil
+}
@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// slowReservesProvider holds every read until release is closed
+type slowReservesProvider struct {
+	PoolReservesProvider
+	calls   int32
+	release chan struct{}
+}
+
+func (p *slowReservesProvider) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	atomic.AddInt32(&p.calls, 1)
+	<-p.release
+	return p.PoolReservesProvider.GetPoolReserves(ctx, pairAddress)
+}
+
+func TestSingleflightPoolReservesProvider(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	pair := fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC))
+	slow := &slowReservesProvider{PoolReservesProvider: graph, release: make(chan struct{})}
+	provider := &SingleflightPoolReservesProvider{provider: slow}
+
+	var wg sync.WaitGroup
+	results := make([]*big.Int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reserve0, _, err := provider.GetPoolReserves(context.Background(), pair)
+			if err != nil {
+				t.Errorf("got error %v", err)
+				return
+			}
+			results[i] = reserve0
+		}(i)
+	}
+	// give every goroutine time to join the in-flight read
+	time.Sleep(50 * time.Millisecond)
+	close(slow.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&slow.calls); calls != 1 {
+		t.Errorf("got %d reads want 1", calls)
+	}
+	for _, reserve0 := range results {
+		if reserve0 == nil || reserve0.Cmp(results[0]) != 0 {
+			t.Fatalf("got reserves %v want all equal", results)
+		}
+	}
+	results[0].SetInt64(0)
+	if results[1].Sign() == 0 {
+		t.Errorf("callers share the same big.Int")
+	}
+
+	// reads pinned to another block are not coalesced with the latest block
+	if _, _, err := provider.GetPoolReserves(WithBlockNumber(context.Background(), big.NewInt(16000000)), pair); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if calls := atomic.LoadInt32(&slow.calls); calls != 2 {
+		t.Errorf("got %d reads want 2", calls)
+	}
+}
@@ -0,0 +1,31 @@
+package routing
+
+import (
+	"errors"
+	"math/big"
+)
+
+// DefaultSlippageBips is the slippage tolerance quotes are given when none is configured, 0.5%
+const DefaultSlippageBips = 50
+
+var ErrInvalidSlippage = errors.New("slippage must be between 0 and 10000 basis points")
+
+// AmountOutMin is the least output an exact-in swap of a route quoted at amountOut should accept,
+// amountOut less slippageBips basis points rounded down, i.e. the swap's amountOutMin
+func AmountOutMin(amountOut *big.Int, slippageBips int64) (*big.Int, error) {
+	if slippageBips < 0 || slippageBips >= 10000 {
+		return nil, ErrInvalidSlippage
+	}
+	amountOutMin := new(big.Int).Mul(amountOut, big.NewInt(10000-slippageBips))
+	return amountOutMin.Quo(amountOutMin, big.NewInt(10000)), nil
+}
+
+// AmountInMax is the most input an exact-out swap of a route quoted at amountIn should spend,
+// amountIn plus slippageBips basis points rounded down, i.e. the swap's amountInMax
+func AmountInMax(amountIn *big.Int, slippageBips int64) (*big.Int, error) {
+	if slippageBips < 0 || slippageBips >= 10000 {
+		return nil, ErrInvalidSlippage
+	}
+	amountInMax := new(big.Int).Mul(amountIn, big.NewInt(10000+slippageBips))
+	return amountInMax.Quo(amountInMax, big.NewInt(10000)), nil
+}
@@ -0,0 +1,70 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSlippageLimits(t *testing.T) {
+	tests := []struct {
+		amount       int64
+		slippageBips int64
+		wantOutMin   int64
+		wantInMax    int64
+	}{
+		{amount: 1000000, slippageBips: 50, wantOutMin: 995000, wantInMax: 1005000},
+		{amount: 1000000, slippageBips: 0, wantOutMin: 1000000, wantInMax: 1000000},
+		// both round down
+		{amount: 999, slippageBips: 50, wantOutMin: 994, wantInMax: 1003},
+	}
+	for _, test := range tests {
+		outMin, err := AmountOutMin(big.NewInt(test.amount), test.slippageBips)
+		if err != nil || outMin.Int64() != test.wantOutMin {
+			t.Errorf("AmountOutMin(%v, %v) got %v %v want %v", test.amount, test.slippageBips, outMin, err, test.wantOutMin)
+		}
+		inMax, err := AmountInMax(big.NewInt(test.amount), test.slippageBips)
+		if err != nil || inMax.Int64() != test.wantInMax {
+			t.Errorf("AmountInMax(%v, %v) got %v %v want %v", test.amount, test.slippageBips, inMax, err, test.wantInMax)
+		}
+	}
+	for _, slippageBips := range []int64{-1, 10000} {
+		if _, err := AmountOutMin(big.NewInt(1000), slippageBips); !errors.Is(err, ErrInvalidSlippage) {
+			t.Errorf("got error %v for %v bips want ErrInvalidSlippage", err, slippageBips)
+		}
+	}
+}
+
+func TestQuoteSlippage(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	router := newFakeRouter(graph)
+
+	quote, err := router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(USDC), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	wantOutMin, _ := AmountOutMin(quote.AmountOut, DefaultSlippageBips)
+	if quote.SlippageBips != DefaultSlippageBips || quote.AmountOutMin.Cmp(wantOutMin) != 0 || quote.AmountInMax.Cmp(quote.AmountIn) != 0 {
+		t.Errorf("got %v bips, min out %v, max in %v want the default tolerance on the output", quote.SlippageBips, quote.AmountOutMin, quote.AmountInMax)
+	}
+	if err := quote.SetSlippage(100); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if wantOutMin, _ := AmountOutMin(quote.AmountOut, 100); quote.AmountOutMin.Cmp(wantOutMin) != 0 {
+		t.Errorf("got min out %v want %v at 100 bips", quote.AmountOutMin, wantOutMin)
+	}
+
+	router.slippageBips = 30
+	quote, err = router.RouteExactOut(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDC), big.NewInt(1000000), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	wantInMax, _ := AmountInMax(quote.AmountIn, 30)
+	if quote.SlippageBips != 30 || quote.AmountInMax.Cmp(wantInMax) != 0 || quote.AmountOutMin.Cmp(quote.AmountOut) != 0 {
+		t.Errorf("got %v bips, min out %v, max in %v want the configured tolerance on the input", quote.SlippageBips, quote.AmountOutMin, quote.AmountInMax)
+	}
+}
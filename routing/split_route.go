@@ -0,0 +1,192 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/pathfinder"
+)
+
+// the trade is allocated in 5% parts across at most maxSplitPaths candidate routes
+const (
+	splitParts    = 20
+	maxSplitPaths = 4
+)
+
+// SplitRoute is a trade spread over several paths that are executed one after another
+type SplitRoute struct {
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	Splits    []RouteSplit
+}
+
+// RouteSplit is the share of a SplitRoute sent along one path
+type RouteSplit struct {
+	Path []common.Address
+	// the pool of every swap, splits can share a path through different pools of its pairs
+	Pairs     []common.Address
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// AmountIn / SplitRoute.AmountIn
+	Share float64
+}
+
+// RouteWithSplits allocates amountIn across the best candidate routes so the total output is maximized.
+// Pools of the same pair on several DEXes are parallel edges, so a share can take either of them.
+// Routes sharing a pool are priced against the reserves left by the routes executed before them.
+func (r *OnChainV2Router) RouteWithSplits(ctx context.Context, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*SplitRoute, error) {
+	if tokenIn == tokenOut {
+		return nil, fmt.Errorf("%w: %v", ErrSameToken, tokenIn.String())
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	maxHops, err = r.resolveMaxHops(ctx, tokenIn, tokenOut, maxHops)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := r.buildGraph(ctx, tokenIn, tokenOut, nil)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := candidateRoutes(ctx, graph, amountIn, maxHops, maxSplitPaths)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, &NoRouteError{TokenIn: tokenIn, TokenOut: tokenOut, MaxHops: maxHops, Reasons: diagnoseNoRoute(graph, maxHops)}
+	}
+
+	// greedily hand every part to the route that increases the total output the most, the better
+	// single route on a tie
+	allocation := make([]*big.Int, len(candidates))
+	for i := range allocation {
+		allocation[i] = new(big.Int)
+	}
+	part := new(big.Int).Quo(amountIn, big.NewInt(splitParts))
+	remaining := new(big.Int).Set(amountIn)
+	for remaining.Sign() > 0 {
+		size := part
+		// the last part also takes the rounding remainder
+		if part.Sign() == 0 || remaining.Cmp(new(big.Int).Mul(part, big.NewInt(2))) < 0 {
+			size = remaining
+		}
+		bestRoute := -1
+		var bestOut *big.Int
+		for i := range candidates {
+			allocation[i].Add(allocation[i], size)
+			total, _, err := simulateSplit(candidates, allocation)
+			allocation[i].Sub(allocation[i], size)
+			if err != nil {
+				continue
+			}
+			if bestOut == nil || total.Cmp(bestOut) > 0 {
+				bestRoute, bestOut = i, total
+			}
+		}
+		if bestRoute < 0 {
+			return nil, &NoRouteError{TokenIn: tokenIn, TokenOut: tokenOut, MaxHops: maxHops, Reasons: []NoRouteReason{InsufficientLiquidity}}
+		}
+		allocation[bestRoute].Add(allocation[bestRoute], size)
+		remaining.Sub(remaining, size)
+	}
+
+	total, outputs, err := simulateSplit(candidates, allocation)
+	if err != nil {
+		return nil, err
+	}
+	route := &SplitRoute{AmountIn: new(big.Int).Set(amountIn), AmountOut: total}
+	for i, candidate := range candidates {
+		if allocation[i].Sign() == 0 {
+			continue
+		}
+		pairs := make([]common.Address, len(candidate.hops))
+		for j, hop := range candidate.hops {
+			pairs[j] = hop.pair
+		}
+		share, _ := new(big.Rat).SetFrac(allocation[i], amountIn).Float64()
+		route.Splits = append(route.Splits, RouteSplit{Path: candidate.path, Pairs: pairs, AmountIn: allocation[i], AmountOut: outputs[i], Share: share})
+	}
+	return route, nil
+}
+
+// splitCandidate is a route a share of a split trade can take, its tokens and the pool of every swap
+type splitCandidate struct {
+	path []common.Address
+	hops []hopReserves
+}
+
+// candidateRoutes returns up to limit routes from tokenIn to tokenOut of at most maxHops swaps, best
+// single route output first. They are the best routes of pathfinder.TopK, so the search stays
+// bounded on dense graphs, and routes through different pools of the same pairs are candidates
+// of their own.
+func candidateRoutes(ctx context.Context, graph *routeGraph, amountIn *big.Int, maxHops, limit int) ([]splitCandidate, error) {
+	results, err := pathfinder.TopK(ctx, graph.searchAlgorithm(), graph.searchGraph(), pathfinder.Request{
+		TokenIn:   graph.tokens[graph.tokenInIndex],
+		TokenOut:  graph.tokens[graph.tokenOutIndex],
+		TradeType: exactIn,
+		Amount:    amountIn,
+		MaxHops:   maxHops,
+	}, limit)
+	if errors.Is(err, pathfinder.ErrNoRoute) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	candidates := []splitCandidate{}
+	for _, result := range results {
+		if result.Amount.Sign() == 0 {
+			continue
+		}
+		candidates = append(candidates, splitCandidate{path: result.Path, hops: graph.resultHops(result)})
+	}
+	return candidates, nil
+}
+
+// simulateSplit executes the allocation route by route against a copy of the reserves,
+// so pools shared between routes reflect the earlier swaps
+func simulateSplit(candidates []splitCandidate, allocation []*big.Int) (*big.Int, []*big.Int, error) {
+	// keyed by pool and input token
+	reserves := make(map[[2]common.Address]hopReserves)
+	total := new(big.Int)
+	outputs := make([]*big.Int, len(candidates))
+	for i, candidate := range candidates {
+		outputs[i] = new(big.Int)
+		if allocation[i].Sign() == 0 {
+			continue
+		}
+		path := candidate.path
+		amount := allocation[i]
+		for j, pool := range candidate.hops {
+			key := [2]common.Address{pool.pair, path[j]}
+			hop, ok := reserves[key]
+			if !ok {
+				hop = pool
+			}
+			amountOut, err := hop.GetAmountOut(amount)
+			if err != nil {
+				return nil, nil, err
+			}
+			if amountOut.Sign() == 0 {
+				return nil, nil, ErrInsufficientLiquidity
+			}
+			reserveIn := new(big.Int).Add(hop.reserveIn, amount)
+			reserveOut := new(big.Int).Sub(hop.reserveOut, amountOut)
+			reserves[key] = hopReserves{reserveIn: reserveIn, reserveOut: reserveOut, pair: hop.pair, dex: hop.dex}
+			reserves[[2]common.Address{pool.pair, path[j+1]}] = hopReserves{reserveIn: reserveOut, reserveOut: reserveIn, pair: hop.pair, dex: hop.dex}
+			amount = amountOut
+		}
+		outputs[i] = amount
+		total.Add(total, amount)
+	}
+	return total, outputs, nil
+}
@@ -0,0 +1,178 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"v2Routing/pathfinder"
+)
+
+func TestRouteWithSplits(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(DAI), 1000000, 1000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 1000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000000, 1000000000)
+	router := newFakeRouter(graph)
+
+	// the trade is as large as either WETH pool, so one path alone loses far more to price impact
+	amountIn := big.NewInt(1000000)
+	route, err := router.RouteWithSplits(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(DAI), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(route.Splits) != 2 {
+		t.Fatalf("got splits %+v want two", route.Splits)
+	}
+	allocated, received := new(big.Int), new(big.Int)
+	for _, split := range route.Splits {
+		allocated.Add(allocated, split.AmountIn)
+		received.Add(received, split.AmountOut)
+	}
+	if allocated.Cmp(amountIn) != 0 {
+		t.Errorf("got %v allocated want %v", allocated, amountIn)
+	}
+	if received.Cmp(route.AmountOut) != 0 {
+		t.Errorf("got %v from the splits want %v", received, route.AmountOut)
+	}
+
+	single, err := router.Route(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(DAI), 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if route.AmountOut.Cmp(single.AmountOut) <= 0 {
+		t.Errorf("got %v from the split want more than %v from a single path", route.AmountOut, single.AmountOut)
+	}
+}
+
+func TestSimulateSplitSharesPoolReserves(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 1000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 1000000, 1000000)
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(UNI), 1000000, 1000000)
+	graph.addPool(common.HexToAddress(UNI), common.HexToAddress(DAI), 1000000, 1000000)
+	router := newFakeRouter(graph)
+	routeGraph, err := router.buildGraph(context.Background(), common.HexToAddress(WETH), common.HexToAddress(DAI), nil)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	// both routes start in the WETH/USDC pool, so the second one sees the first one's swap
+	candidates := []splitCandidate{}
+	for _, path := range [][]common.Address{
+		{common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)},
+		{common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(UNI), common.HexToAddress(DAI)},
+	} {
+		hops := routeGraph.resultHops(&pathfinder.Result{Path: path, Pools: make([]int, len(path)-1)})
+		candidates = append(candidates, splitCandidate{path: path, hops: hops})
+	}
+	amount := big.NewInt(100000)
+	_, outputs, err := simulateSplit(candidates, []*big.Int{amount, amount})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	_, alone, err := simulateSplit(candidates, []*big.Int{new(big.Int), amount})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if outputs[1].Cmp(alone[1]) >= 0 {
+		t.Errorf("got %v after a shared swap want less than %v alone", outputs[1], alone[1])
+	}
+}
+
+func TestRouteWithSplitsParallelPools(t *testing.T) {
+	graph := &v2GraphFake{}
+	uniswap := &dexPairsFake{name: "uniswap-v2"}
+	sushiswap := &dexPairsFake{name: "sushiswap"}
+	weth, dai := common.HexToAddress(WETH), common.HexToAddress(DAI)
+	// the only path is WETH -> DAI, listed on both DEXes
+	addDEXPool(graph, uniswap, weth, dai, 1000000, 2000000)
+	addDEXPool(graph, sushiswap, weth, dai, 500000, 1010000)
+	router := newFakeRouter(graph)
+	router.dexAdapters = []DEXAdapter{NewUniswapV2Adapter(uniswap), NewSushiswapAdapter(sushiswap)}
+
+	amountIn := big.NewInt(1000000)
+	route, err := router.RouteWithSplits(context.Background(), amountIn, weth, dai, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(route.Splits) != 2 {
+		t.Fatalf("got splits %+v want one per pool", route.Splits)
+	}
+	pools := make(map[common.Address]*big.Int)
+	for _, split := range route.Splits {
+		if fmt.Sprint(split.Path) != fmt.Sprint([]common.Address{weth, dai}) || len(split.Pairs) != 1 {
+			t.Fatalf("got split %+v want WETH -> DAI through one pool", split)
+		}
+		pools[split.Pairs[0]] = split.AmountIn
+	}
+	uniswapIn, sushiswapIn := pools[uniswap.pairs[newPairKey(weth, dai)]], pools[sushiswap.pairs[newPairKey(weth, dai)]]
+	if uniswapIn == nil || sushiswapIn == nil {
+		t.Fatalf("got splits %+v want both pools used", route.Splits)
+	}
+	// every split is priced in its own pool
+	uniswapOut, _ := getAmountOut(uniswapIn, big.NewInt(1000000), big.NewInt(2000000))
+	sushiswapOut, _ := getAmountOut(sushiswapIn, big.NewInt(500000), big.NewInt(1010000))
+	if want := new(big.Int).Add(uniswapOut, sushiswapOut); route.AmountOut.Cmp(want) != 0 {
+		t.Errorf("got %v want %v", route.AmountOut, want)
+	}
+	// the deeper pool takes the larger share
+	if uniswapIn.Cmp(sushiswapIn) <= 0 {
+		t.Errorf("got %v through uniswap want more than the %v through sushiswap", uniswapIn, sushiswapIn)
+	}
+
+	single, err := router.Route(context.Background(), amountIn, weth, dai, 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if route.AmountOut.Cmp(single.AmountOut) <= 0 {
+		t.Errorf("got %v from the split want more than %v from a single pool", route.AmountOut, single.AmountOut)
+	}
+}
+
+func TestCandidateRoutesBounded(t *testing.T) {
+	// every token is paired with every other, so the number of simple paths grows exponentially
+	// with the hops
+	graph := &v2GraphFake{}
+	tokens := make([]common.Address, 16)
+	for i := range tokens {
+		tokens[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+		for j := 0; j < i; j++ {
+			graph.addPool(tokens[j], tokens[i], int64(1000000+1000*i), int64(1000000+1000*j))
+		}
+	}
+	router := newFakeRouter(graph)
+	routeGraph, err := router.buildGraph(context.Background(), tokens[0], tokens[1], nil)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	maxHops := 5
+	candidates, err := candidateRoutes(context.Background(), routeGraph, big.NewInt(10000), maxHops, maxSplitPaths)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(candidates) != maxSplitPaths {
+		t.Fatalf("got %v routes want %v", len(candidates), maxSplitPaths)
+	}
+	paths := [][]common.Address{}
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		path := candidate.path
+		paths = append(paths, path)
+		if len(path)-1 > maxHops || path[0] != tokens[0] || path[len(path)-1] != tokens[1] {
+			t.Errorf("got path %v want at most %v hops from %v to %v", path, maxHops, tokens[0], tokens[1])
+		}
+		if seen[fmt.Sprint(path)] {
+			t.Errorf("got path %v twice", path)
+		}
+		seen[fmt.Sprint(path)] = true
+	}
+	amountOut, best, _, err := searchRoute(context.Background(), routeGraph, exactIn, big.NewInt(10000), maxHops, nil, &RouteMetadata{}, nil)
+	if err != nil || fmt.Sprint(paths[0]) != fmt.Sprint(best) {
+		t.Errorf("got first path %v want the best route %v (%v) error %v", paths[0], best, amountOut, err)
+	}
+}
@@ -0,0 +1,196 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// AccountOverride replaces parts of an account's state in eth_call, nil fields keep the chain's value
+type AccountOverride struct {
+	Nonce   *hexutil.Uint64 `json:"nonce,omitempty"`
+	Code    *hexutil.Bytes  `json:"code,omitempty"`
+	Balance *hexutil.Big    `json:"balance,omitempty"`
+	// storage slots to replace, the other slots keep their value
+	StateDiff map[common.Hash]common.Hash `json:"stateDiff,omitempty"`
+}
+
+// StateOverride is the state overrides argument of eth_call
+type StateOverride map[common.Address]AccountOverride
+
+// apply layers next on top of o, the fields and slots set in next win
+func (o StateOverride) apply(next StateOverride) {
+	for address, account := range next {
+		merged := o[address]
+		if account.Nonce != nil {
+			merged.Nonce = account.Nonce
+		}
+		if account.Code != nil {
+			merged.Code = account.Code
+		}
+		if account.Balance != nil {
+			merged.Balance = account.Balance
+		}
+		for slot, value := range account.StateDiff {
+			if merged.StateDiff == nil {
+				merged.StateDiff = make(map[common.Hash]common.Hash)
+			}
+			merged.StateDiff[slot] = value
+		}
+		o[address] = merged
+	}
+}
+
+type stateOverrideKey struct{}
+
+// WithStateOverride makes every on-chain read of the returned context see override, e.g. the state
+// after a pending transaction from SimulateTransactions. Reads only honor it through a
+// StateOverrideCaller, caches are bypassed since they hold the chain's state.
+func WithStateOverride(ctx context.Context, override StateOverride) context.Context {
+	return context.WithValue(ctx, stateOverrideKey{}, override)
+}
+
+// StateOverrideFromContext returns the override of ctx, nil for the chain's state
+func StateOverrideFromContext(ctx context.Context) StateOverride {
+	override, _ := ctx.Value(stateOverrideKey{}).(StateOverride)
+	return override
+}
+
+// StateOverrideCaller is a bind.ContractCaller that sends the state override of the call context
+// with every eth_call, pass it to NewMulticallPoolReservesProvider to quote against simulated state
+type StateOverrideCaller struct {
+	client *rpc.Client
+}
+
+func NewStateOverrideCaller(client *rpc.Client) *StateOverrideCaller {
+	return &StateOverrideCaller{client: client}
+}
+
+func (c *StateOverrideCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if account, ok := StateOverrideFromContext(ctx)[contract]; ok && account.Code != nil {
+		return *account.Code, nil
+	}
+	var code hexutil.Bytes
+	err := c.client.CallContext(ctx, &code, "eth_getCode", contract, toBlockNumberArg(blockNumber))
+	return code, err
+}
+
+func (c *StateOverrideCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var result hexutil.Bytes
+	override := StateOverrideFromContext(ctx)
+	if override == nil {
+		err := c.client.CallContext(ctx, &result, "eth_call", toCallArg(call), toBlockNumberArg(blockNumber))
+		return result, err
+	}
+	err := c.client.CallContext(ctx, &result, "eth_call", toCallArg(call), toBlockNumberArg(blockNumber), override)
+	return result, err
+}
+
+// prestateAccount is an account of the prestate tracer's diff mode
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   *uint64                     `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+type prestateDiff struct {
+	Pre  map[common.Address]prestateAccount `json:"pre"`
+	Post map[common.Address]prestateAccount `json:"post"`
+}
+
+// SimulateTransactions executes txs in order on top of blockNumber (nil for the latest block) and
+// returns the resulting state as an override for WithStateOverride. Every transaction sees the
+// effects of the ones before it, so a bundle can be simulated. The node must support
+// debug_traceCall with state overrides and the prestate tracer's diff mode.
+func SimulateTransactions(ctx context.Context, client *rpc.Client, txs []ethereum.CallMsg, blockNumber *big.Int) (StateOverride, error) {
+	override := make(StateOverride)
+	for i, tx := range txs {
+		config := map[string]interface{}{
+			"tracer":         "prestateTracer",
+			"tracerConfig":   map[string]interface{}{"diffMode": true},
+			"stateOverrides": override,
+		}
+		var raw json.RawMessage
+		if err := client.CallContext(ctx, &raw, "debug_traceCall", toCallArg(tx), toBlockNumberArg(blockNumber), config); err != nil {
+			return nil, fmt.Errorf("transaction %v: %w", i, err)
+		}
+		var diff prestateDiff
+		if err := json.Unmarshal(raw, &diff); err != nil {
+			return nil, fmt.Errorf("transaction %v: %w", i, err)
+		}
+		override.apply(diff.override())
+	}
+	return override, nil
+}
+
+// override turns a state diff into the override that recreates the post state
+func (d prestateDiff) override() StateOverride {
+	override := make(StateOverride)
+	for address, pre := range d.Pre {
+		_, ok := d.Post[address]
+		account := AccountOverride{StateDiff: make(map[common.Hash]common.Hash)}
+		if !ok {
+			// deleted accounts are left out of the post state
+			account.Balance, account.Nonce, account.Code = (*hexutil.Big)(new(big.Int)), new(hexutil.Uint64), &hexutil.Bytes{}
+		}
+		// cleared slots are left out of the post state
+		for slot := range pre.Storage {
+			account.StateDiff[slot] = common.Hash{}
+		}
+		override[address] = account
+	}
+	for address, post := range d.Post {
+		account := override[address]
+		if account.StateDiff == nil {
+			account.StateDiff = make(map[common.Hash]common.Hash)
+		}
+		if post.Balance != nil {
+			account.Balance = post.Balance
+		}
+		if post.Code != nil {
+			account.Code = post.Code
+		}
+		if post.Nonce != nil {
+			account.Nonce = (*hexutil.Uint64)(post.Nonce)
+		}
+		for slot, value := range post.Storage {
+			account.StateDiff[slot] = value
+		}
+		override[address] = account
+	}
+	return override
+}
+
+func toBlockNumberArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
+
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	return arg
+}
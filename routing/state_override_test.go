@@ -0,0 +1,119 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ethServiceFake records the overrides sent with eth_call
+type ethServiceFake struct {
+	overrides []StateOverride
+}
+
+func (s *ethServiceFake) Call(args map[string]interface{}, block string, override *StateOverride) (hexutil.Bytes, error) {
+	if override == nil {
+		s.overrides = append(s.overrides, nil)
+	} else {
+		s.overrides = append(s.overrides, *override)
+	}
+	return hexutil.Bytes{1}, nil
+}
+
+// debugServiceFake answers debug_traceCall with one canned diff per call
+type debugServiceFake struct {
+	diffs     []string
+	overrides []StateOverride
+}
+
+func (s *debugServiceFake) TraceCall(args map[string]interface{}, block string, config struct {
+	StateOverrides StateOverride `json:"stateOverrides"`
+}) (json.RawMessage, error) {
+	s.overrides = append(s.overrides, config.StateOverrides)
+	diff := s.diffs[0]
+	s.diffs = s.diffs[1:]
+	return json.RawMessage(diff), nil
+}
+
+func newRPCFake(t *testing.T, eth *ethServiceFake, debug *debugServiceFake) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if err := server.RegisterName("debug", debug); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	t.Cleanup(server.Stop)
+	return rpc.DialInProc(server)
+}
+
+func TestSimulateTransactions(t *testing.T) {
+	pair := common.HexToAddress(WETH_USDC)
+	reservesSlot := common.BigToHash(big.NewInt(8))
+	clearedSlot := common.BigToHash(big.NewInt(9))
+	slots := func(values ...int64) map[common.Hash]common.Hash {
+		storage := make(map[common.Hash]common.Hash)
+		for i := 0; i < len(values); i += 2 {
+			storage[common.BigToHash(big.NewInt(values[i]))] = common.BigToHash(big.NewInt(values[i+1]))
+		}
+		return storage
+	}
+	nonce := uint64(1)
+	diffs := []prestateDiff{
+		// slot 9 is cleared and left out of the post state
+		{Pre: map[common.Address]prestateAccount{pair: {Storage: slots(8, 1, 9, 5)}}, Post: map[common.Address]prestateAccount{pair: {Nonce: &nonce, Storage: slots(8, 2)}}},
+		{Pre: map[common.Address]prestateAccount{pair: {Storage: slots(8, 2)}}, Post: map[common.Address]prestateAccount{pair: {Storage: slots(8, 3)}}},
+	}
+	debug := &debugServiceFake{}
+	for _, diff := range diffs {
+		encoded, _ := json.Marshal(diff)
+		debug.diffs = append(debug.diffs, string(encoded))
+	}
+	client := newRPCFake(t, &ethServiceFake{}, debug)
+
+	txs := []ethereum.CallMsg{{To: &pair}, {To: &pair}}
+	override, err := SimulateTransactions(context.Background(), client, txs, nil)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// the second transaction runs on top of the first
+	if got := debug.overrides[1][pair].StateDiff[reservesSlot]; got != common.BigToHash(big.NewInt(2)) {
+		t.Errorf("got reserves slot %v in the second trace want 0x02", got)
+	}
+	account := override[pair]
+	if got := account.StateDiff[reservesSlot]; got != common.BigToHash(big.NewInt(3)) {
+		t.Errorf("got reserves slot %v after the bundle want 0x03", got)
+	}
+	if got, ok := account.StateDiff[clearedSlot]; !ok || got != (common.Hash{}) {
+		t.Errorf("got cleared slot %v want zero", got)
+	}
+	if account.Nonce == nil || *account.Nonce != 1 {
+		t.Errorf("got nonce %v want 1", account.Nonce)
+	}
+}
+
+func TestStateOverrideCaller(t *testing.T) {
+	eth := &ethServiceFake{}
+	caller := NewStateOverrideCaller(newRPCFake(t, eth, &debugServiceFake{}))
+	pair := common.HexToAddress(WETH_USDC)
+	override := StateOverride{pair: {StateDiff: map[common.Hash]common.Hash{common.BigToHash(big.NewInt(8)): common.BigToHash(big.NewInt(2))}}}
+
+	if _, err := caller.CallContract(context.Background(), ethereum.CallMsg{To: &pair}, nil); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if _, err := caller.CallContract(WithStateOverride(context.Background(), override), ethereum.CallMsg{To: &pair}, big.NewInt(100)); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if eth.overrides[0] != nil {
+		t.Errorf("got override %v without one on the context", eth.overrides[0])
+	}
+	if len(eth.overrides[1][pair].StateDiff) != 1 {
+		t.Errorf("got override %v want the context's", eth.overrides[1])
+	}
+}
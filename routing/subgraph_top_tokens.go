@@ -0,0 +1,188 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	defaultSubgraphTopTokens = 20
+	defaultSubgraphTTL       = 10 * time.Minute
+	// the subgraph refuses larger pages
+	maxSubgraphPageSize = 1000
+)
+
+// TopTokensOrder is what SubgraphTopTokensProvider ranks tokens by
+type TopTokensOrder int
+
+const (
+	// all-time USD volume of the token
+	TopTokensByVolume TopTokensOrder = iota
+	// USD reserves of the token's deepest pairs, tokens are taken from the pairs in reserve order
+	TopTokensByLiquidity
+)
+
+// SubgraphTopTokensConfig configures NewSubgraphTopTokensProvider, only URL is required
+type SubgraphTopTokensConfig struct {
+	// GraphQL endpoint of a Uniswap V2 subgraph, e.g. UNISWAP_V2_SUBGRAPH_URL
+	URL string
+	// number of tokens returned, defaults to 20
+	N       int
+	OrderBy TopTokensOrder
+	// how long a token list is reused before the subgraph is queried again, defaults to 10 minutes
+	TTL        time.Duration
+	HTTPClient *http.Client
+	// optional, failed refreshes are logged to the logger of the context without one
+	Logger *slog.Logger
+}
+
+// SubgraphTopTokensProvider returns the top N tokens of a Uniswap V2 subgraph. The list is cached
+// for TTL since every route asks for it, and a stale list is kept when a refresh fails.
+type SubgraphTopTokensProvider struct {
+	config SubgraphTopTokensConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	tokens    []common.Address
+	fetchedAt time.Time
+}
+
+func NewSubgraphTopTokensProvider(config SubgraphTopTokensConfig) *SubgraphTopTokensProvider {
+	if config.N <= 0 {
+		config.N = defaultSubgraphTopTokens
+	}
+	if config.TTL == 0 {
+		config.TTL = defaultSubgraphTTL
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &SubgraphTopTokensProvider{config: config, now: time.Now}
+}
+
+func (p *SubgraphTopTokensProvider) GetTopTokens(ctx context.Context) ([]common.Address, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tokens != nil && p.now().Sub(p.fetchedAt) < p.config.TTL {
+		return p.tokens, nil
+	}
+	tokens, err := p.fetch(ctx)
+	if err != nil {
+		if p.tokens != nil {
+			logWarn(ctx, p.config.Logger, "subgraph query failed, keeping cached top tokens", "tokens", len(p.tokens), "error", err)
+			return p.tokens, nil
+		}
+		return nil, err
+	}
+	p.tokens, p.fetchedAt = tokens, p.now()
+	return tokens, nil
+}
+
+type subgraphToken struct {
+	ID string `json:"id"`
+}
+
+// subgraphResponse is a GraphQL response, Data is decoded by the caller
+type subgraphResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// querySubgraph posts a GraphQL query to url and decodes the data of the response into out
+func querySubgraph(ctx context.Context, client *http.Client, url, query string, variables map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("subgraph returned status %v", resp.Status)
+	}
+	var parsed subgraphResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return err
+	}
+	if len(parsed.Errors) > 0 {
+		return fmt.Errorf("subgraph query failed: %v", parsed.Errors[0].Message)
+	}
+	return json.Unmarshal(parsed.Data, out)
+}
+
+func (p *SubgraphTopTokensProvider) fetch(ctx context.Context) ([]common.Address, error) {
+	query := `query($first: Int!) { tokens(first: $first, orderBy: tradeVolumeUSD, orderDirection: desc) { id } }`
+	first := p.config.N
+	if p.config.OrderBy == TopTokensByLiquidity {
+		query = `query($first: Int!) { pairs(first: $first, orderBy: reserveUSD, orderDirection: desc) { token0 { id } token1 { id } } }`
+		// every pair adds at most two tokens and the deepest pairs share most of theirs
+		first = 5 * p.config.N
+	}
+	if first > maxSubgraphPageSize {
+		first = maxSubgraphPageSize
+	}
+	var data struct {
+		Tokens []subgraphToken `json:"tokens"`
+		Pairs  []struct {
+			Token0 subgraphToken `json:"token0"`
+			Token1 subgraphToken `json:"token1"`
+		} `json:"pairs"`
+	}
+	if err := querySubgraph(ctx, p.config.HTTPClient, p.config.URL, query, map[string]interface{}{"first": first}, &data); err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, token := range data.Tokens {
+		ids = append(ids, token.ID)
+	}
+	for _, pair := range data.Pairs {
+		ids = append(ids, pair.Token0.ID, pair.Token1.ID)
+	}
+	tokens := []common.Address{}
+	seen := make(map[common.Address]bool)
+	for _, id := range ids {
+		if len(tokens) == p.config.N {
+			break
+		}
+		if !common.IsHexAddress(id) {
+			return nil, fmt.Errorf("subgraph returned invalid token id %q", id)
+		}
+		token := common.HexToAddress(id)
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("subgraph returned no tokens")
+	}
+	return tokens, nil
+}
+
+func (p *SubgraphTopTokensProvider) CacheSummary() CacheSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tokens == nil {
+		return CacheSummary{}
+	}
+	return CacheSummary{Entries: len(p.tokens), Detail: fmt.Sprintf("fetched at %v", p.fetchedAt.Format(time.RFC3339))}
+}
@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSubgraphTopTokensProvider(t *testing.T) {
+	queries := 0
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var request struct {
+			Query     string         `json:"query"`
+			Variables map[string]int `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("got error %v", err)
+		}
+		if strings.Contains(request.Query, "pairs(") {
+			w.Write([]byte(`{"data":{"pairs":[
+				{"token0":{"id":"` + USDC + `"},"token1":{"id":"` + WETH + `"}},
+				{"token0":{"id":"` + WETH + `"},"token1":{"id":"` + USDT + `"}},
+				{"token0":{"id":"` + DAI + `"},"token1":{"id":"` + WETH + `"}}]}}`))
+			return
+		}
+		if request.Variables["first"] != 2 {
+			t.Errorf("got first %v want 2", request.Variables["first"])
+		}
+		w.Write([]byte(`{"data":{"tokens":[{"id":"` + WETH + `"},{"id":"` + USDC + `"}]}}`))
+	}))
+	defer server.Close()
+
+	provider := NewSubgraphTopTokensProvider(SubgraphTopTokensConfig{URL: server.URL, N: 2})
+	now := time.Unix(0, 0)
+	provider.now = func() time.Time { return now }
+	tokens, err := provider.GetTopTokens(context.Background())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(tokens) != 2 || tokens[0] != common.HexToAddress(WETH) || tokens[1] != common.HexToAddress(USDC) {
+		t.Errorf("got tokens %v want WETH, USDC", tokens)
+	}
+
+	// cached within the TTL, kept when a refresh fails
+	provider.GetTopTokens(context.Background())
+	if queries != 1 {
+		t.Errorf("got %v queries want 1", queries)
+	}
+	now = now.Add(defaultSubgraphTTL)
+	failing = true
+	if tokens, err := provider.GetTopTokens(context.Background()); err != nil || len(tokens) != 2 {
+		t.Errorf("got tokens %v error %v want the stale list", tokens, err)
+	}
+	if queries != 2 {
+		t.Errorf("got %v queries want 2", queries)
+	}
+	if _, err := NewSubgraphTopTokensProvider(SubgraphTopTokensConfig{URL: server.URL}).GetTopTokens(context.Background()); err == nil {
+		t.Error("expected an error without a cached list")
+	}
+
+	failing = false
+	provider = NewSubgraphTopTokensProvider(SubgraphTopTokensConfig{URL: server.URL, N: 3, OrderBy: TopTokensByLiquidity})
+	tokens, err = provider.GetTopTokens(context.Background())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	want := []common.Address{common.HexToAddress(USDC), common.HexToAddress(WETH), common.HexToAddress(USDT)}
+	if len(tokens) != len(want) {
+		t.Fatalf("got tokens %v want %v", tokens, want)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("got tokens %v want %v", tokens, want)
+		}
+	}
+}
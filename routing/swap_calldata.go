@@ -0,0 +1,228 @@
+package routing
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// swap functions of Uniswap V2's Router02, the fee-on-transfer variants included
+const router02ABI = `[{"inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapExactTokensForTokens","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"amountOut","type":"uint256"},{"name":"amountInMax","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapTokensForExactTokens","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapExactETHForTokens","outputs":[],"stateMutability":"payable","type":"function"},{"inputs":[{"name":"amountOut","type":"uint256"},{"name":"amountInMax","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapTokensForExactETH","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapExactTokensForETH","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"amountOut","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapETHForExactTokens","outputs":[],"stateMutability":"payable","type":"function"},{"inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapExactTokensForTokensSupportingFeeOnTransferTokens","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapExactETHForTokensSupportingFeeOnTransferTokens","outputs":[],"stateMutability":"payable","type":"function"},{"inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapExactTokensForETHSupportingFeeOnTransferTokens","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+
+// both execute overloads of Uniswap's Universal Router
+const universalRouterABI = `[{"inputs":[{"name":"commands","type":"bytes"},{"name":"inputs","type":"bytes[]"},{"name":"deadline","type":"uint256"}],"name":"execute","outputs":[],"stateMutability":"payable","type":"function"},{"inputs":[{"name":"commands","type":"bytes"},{"name":"inputs","type":"bytes[]"}],"name":"execute","outputs":[],"stateMutability":"payable","type":"function"}]`
+
+// Universal Router commands that swap, the other commands (wraps, transfers, permits, ...) are skipped
+const (
+	urV3SwapExactIn  = 0x00
+	urV3SwapExactOut = 0x01
+	urV2SwapExactIn  = 0x08
+	urV2SwapExactOut = 0x09
+	// the low bits of a command byte select the command, the high bit allows it to revert
+	urCommandTypeMask = 0x3f
+)
+
+var (
+	router02Parsed        = mustParseABI(router02ABI)
+	universalRouterParsed = mustParseABI(universalRouterABI)
+	errUnknownSwapMethod  = errors.New("calldata is not a Router02 or Universal Router swap")
+)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// DecodedSwap is a swap reconstructed from router calldata. Exact-in swaps set AmountIn and
+// AmountOutMin, exact-out swaps AmountOut and AmountInMax; the other two are nil.
+type DecodedSwap struct {
+	Method   string
+	ExactOut bool
+	// tokens in swap order, for either trade type
+	Path []common.Address
+	// fee tier of every V3 hop in hundredths of a bip, nil for V2 swaps
+	Fees         []uint32
+	AmountIn     *big.Int
+	AmountOutMin *big.Int
+	AmountOut    *big.Int
+	AmountInMax  *big.Int
+	// the Universal Router uses 0x...01 for the caller and 0x...02 for itself
+	Recipient common.Address
+	// nil when the call has no deadline
+	Deadline *big.Int
+}
+
+// Slippage is the share of quote the swap accepts to lose: quote is the expected output of an
+// exact-in swap and the expected input of an exact-out swap. It is nil without a usable quote or limit.
+func (s DecodedSwap) Slippage(quote *big.Int) *big.Rat {
+	if quote == nil || quote.Sign() <= 0 {
+		return nil
+	}
+	if s.ExactOut {
+		if s.AmountInMax == nil {
+			return nil
+		}
+		return new(big.Rat).SetFrac(new(big.Int).Sub(s.AmountInMax, quote), quote)
+	}
+	if s.AmountOutMin == nil {
+		return nil
+	}
+	return new(big.Rat).SetFrac(new(big.Int).Sub(quote, s.AmountOutMin), quote)
+}
+
+// DecodeSwapCalldata reconstructs the swaps of a Router02 or Universal Router call. value is the
+// ETH sent with the transaction, it is the input of Router02's ETH swaps and may be nil otherwise.
+func DecodeSwapCalldata(data []byte, value *big.Int) ([]DecodedSwap, error) {
+	if len(data) < 4 {
+		return nil, errUnknownSwapMethod
+	}
+	if method, err := router02Parsed.MethodById(data[:4]); err == nil {
+		swap, err := decodeRouter02Swap(method, data[4:], value)
+		if err != nil {
+			return nil, err
+		}
+		return []DecodedSwap{swap}, nil
+	}
+	if method, err := universalRouterParsed.MethodById(data[:4]); err == nil {
+		return decodeUniversalRouterSwaps(method, data[4:])
+	}
+	return nil, errUnknownSwapMethod
+}
+
+func decodeRouter02Swap(method *abi.Method, data []byte, value *big.Int) (DecodedSwap, error) {
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, data); err != nil {
+		return DecodedSwap{}, fmt.Errorf("%v: %w", method.Name, err)
+	}
+	amount := func(name string) *big.Int {
+		if amount, ok := args[name].(*big.Int); ok {
+			return amount
+		}
+		return nil
+	}
+	swap := DecodedSwap{
+		Method:       method.Name,
+		ExactOut:     strings.HasPrefix(method.Name, "swapTokensForExact") || strings.HasPrefix(method.Name, "swapETHForExact"),
+		Path:         args["path"].([]common.Address),
+		AmountIn:     amount("amountIn"),
+		AmountOutMin: amount("amountOutMin"),
+		AmountOut:    amount("amountOut"),
+		AmountInMax:  amount("amountInMax"),
+		Recipient:    args["to"].(common.Address),
+		Deadline:     amount("deadline"),
+	}
+	// ETH swaps pay in the transaction's value
+	if strings.HasPrefix(method.Name, "swapExactETH") {
+		swap.AmountIn = value
+	}
+	if method.Name == "swapETHForExactTokens" {
+		swap.AmountInMax = value
+	}
+	return swap, nil
+}
+
+func decodeUniversalRouterSwaps(method *abi.Method, data []byte) ([]DecodedSwap, error) {
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, data); err != nil {
+		return nil, fmt.Errorf("execute: %w", err)
+	}
+	commands := args["commands"].([]byte)
+	inputs := args["inputs"].([][]byte)
+	if len(commands) != len(inputs) {
+		return nil, fmt.Errorf("execute: %v commands with %v inputs", len(commands), len(inputs))
+	}
+	deadline, _ := args["deadline"].(*big.Int)
+	swaps := []DecodedSwap{}
+	for i, command := range commands {
+		var swap DecodedSwap
+		var err error
+		switch command & urCommandTypeMask {
+		case urV2SwapExactIn, urV2SwapExactOut:
+			swap, err = decodeUniversalRouterV2Swap(command&urCommandTypeMask == urV2SwapExactOut, inputs[i])
+		case urV3SwapExactIn, urV3SwapExactOut:
+			swap, err = decodeUniversalRouterV3Swap(command&urCommandTypeMask == urV3SwapExactOut, inputs[i])
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("command %v: %w", i, err)
+		}
+		swap.Deadline = deadline
+		swaps = append(swaps, swap)
+	}
+	if len(swaps) == 0 {
+		return nil, errors.New("execute has no swap commands")
+	}
+	return swaps, nil
+}
+
+// universalRouterSwapArguments are the inputs of the V2 and V3 swap commands:
+// (recipient, amount, limit, path, payerIsUser)
+func universalRouterSwapArguments(pathType string) abi.Arguments {
+	types := []string{"address", "uint256", "uint256", pathType, "bool"}
+	arguments := make(abi.Arguments, len(types))
+	for i, name := range types {
+		arguments[i].Type, _ = abi.NewType(name, "", nil)
+	}
+	return arguments
+}
+
+func decodeUniversalRouterV2Swap(exactOut bool, input []byte) (DecodedSwap, error) {
+	values, err := universalRouterSwapArguments("address[]").Unpack(input)
+	if err != nil {
+		return DecodedSwap{}, err
+	}
+	swap := DecodedSwap{Method: "V2_SWAP_EXACT_IN", Recipient: values[0].(common.Address), Path: values[3].([]common.Address)}
+	if exactOut {
+		swap.Method, swap.ExactOut, swap.AmountOut, swap.AmountInMax = "V2_SWAP_EXACT_OUT", true, values[1].(*big.Int), values[2].(*big.Int)
+	} else {
+		swap.AmountIn, swap.AmountOutMin = values[1].(*big.Int), values[2].(*big.Int)
+	}
+	return swap, nil
+}
+
+func decodeUniversalRouterV3Swap(exactOut bool, input []byte) (DecodedSwap, error) {
+	values, err := universalRouterSwapArguments("bytes").Unpack(input)
+	if err != nil {
+		return DecodedSwap{}, err
+	}
+	path, fees, err := decodeV3Path(values[3].([]byte))
+	if err != nil {
+		return DecodedSwap{}, err
+	}
+	swap := DecodedSwap{Method: "V3_SWAP_EXACT_IN", Recipient: values[0].(common.Address), Path: path, Fees: fees}
+	if exactOut {
+		// exact-out paths are encoded from tokenOut back to tokenIn
+		reverse(swap.Path)
+		for i, j := 0, len(fees)-1; i < j; i, j = i+1, j-1 {
+			fees[i], fees[j] = fees[j], fees[i]
+		}
+		swap.Method, swap.ExactOut, swap.AmountOut, swap.AmountInMax = "V3_SWAP_EXACT_OUT", true, values[1].(*big.Int), values[2].(*big.Int)
+	} else {
+		swap.AmountIn, swap.AmountOutMin = values[1].(*big.Int), values[2].(*big.Int)
+	}
+	return swap, nil
+}
+
+// decodeV3Path splits a packed V3 path, token (20 bytes) followed by fee (3 bytes) and token for every hop
+func decodeV3Path(encoded []byte) ([]common.Address, []uint32, error) {
+	const hopSize = common.AddressLength + 3
+	if len(encoded) < common.AddressLength+hopSize || (len(encoded)-common.AddressLength)%hopSize != 0 {
+		return nil, nil, fmt.Errorf("invalid V3 path of %v bytes", len(encoded))
+	}
+	path := []common.Address{common.BytesToAddress(encoded[:common.AddressLength])}
+	fees := []uint32{}
+	for offset := common.AddressLength; offset < len(encoded); offset += hopSize {
+		fees = append(fees, binary.BigEndian.Uint32(append([]byte{0}, encoded[offset:offset+3]...)))
+		path = append(path, common.BytesToAddress(encoded[offset+3:offset+hopSize]))
+	}
+	return path, fees, nil
+}
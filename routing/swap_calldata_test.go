@@ -0,0 +1,90 @@
+package routing
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeSwapCalldataRouter02(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	recipient := common.HexToAddress("0x1234")
+	data, err := router02Parsed.Pack("swapExactTokensForTokens", big.NewInt(1000), big.NewInt(990), []common.Address{weth, usdc, dai}, recipient, big.NewInt(1700000000))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	swaps, err := DecodeSwapCalldata(data, nil)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	want := DecodedSwap{
+		Method:       "swapExactTokensForTokens",
+		Path:         []common.Address{weth, usdc, dai},
+		AmountIn:     big.NewInt(1000),
+		AmountOutMin: big.NewInt(990),
+		Recipient:    recipient,
+		Deadline:     big.NewInt(1700000000),
+	}
+	if len(swaps) != 1 || !reflect.DeepEqual(swaps[0], want) {
+		t.Errorf("got swaps %+v want %+v", swaps, want)
+	}
+	if slippage := swaps[0].Slippage(big.NewInt(1000)); slippage.Cmp(big.NewRat(1, 100)) != 0 {
+		t.Errorf("got slippage %v want 1/100", slippage)
+	}
+
+	// the input of ETH swaps is the transaction's value
+	data, _ = router02Parsed.Pack("swapETHForExactTokens", big.NewInt(500), []common.Address{weth, usdc}, recipient, big.NewInt(1700000000))
+	swaps, err = DecodeSwapCalldata(data, big.NewInt(600))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if !swaps[0].ExactOut || swaps[0].AmountOut.Int64() != 500 || swaps[0].AmountInMax.Int64() != 600 {
+		t.Errorf("got swap %+v want exact-out of 500 for at most 600", swaps[0])
+	}
+	if slippage := swaps[0].Slippage(big.NewInt(500)); slippage.Cmp(big.NewRat(1, 5)) != 0 {
+		t.Errorf("got slippage %v want 1/5", slippage)
+	}
+
+	if _, err := DecodeSwapCalldata([]byte{1, 2, 3, 4, 5}, nil); err == nil {
+		t.Errorf("got no error for unknown calldata")
+	}
+}
+
+func TestDecodeSwapCalldataUniversalRouter(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	msgSender := common.HexToAddress("0x01")
+	v2Input, err := universalRouterSwapArguments("address[]").Pack(msgSender, big.NewInt(1000), big.NewInt(990), []common.Address{weth, usdc}, true)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// exact-out V3 paths run from tokenOut to tokenIn: DAI <-500- USDC <-3000- WETH
+	v3Path := append(append(append(append(dai.Bytes(), 0x00, 0x01, 0xf4), usdc.Bytes()...), 0x00, 0x0b, 0xb8), weth.Bytes()...)
+	v3Input, err := universalRouterSwapArguments("bytes").Pack(msgSender, big.NewInt(2000), big.NewInt(2100), v3Path, true)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// WRAP_ETH between the swaps is skipped, the high bit allows a command to revert
+	commands := []byte{urV2SwapExactIn, 0x0b, 0x80 | urV3SwapExactOut}
+	data, err := universalRouterParsed.Methods["execute"].Inputs.Pack(commands, [][]byte{v2Input, {}, v3Input}, big.NewInt(1700000000))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	swaps, err := DecodeSwapCalldata(append(universalRouterParsed.Methods["execute"].ID, data...), nil)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(swaps) != 2 {
+		t.Fatalf("got %v swaps want 2", len(swaps))
+	}
+	if swaps[0].Method != "V2_SWAP_EXACT_IN" || !reflect.DeepEqual(swaps[0].Path, []common.Address{weth, usdc}) || swaps[0].AmountOutMin.Int64() != 990 || swaps[0].Deadline.Int64() != 1700000000 {
+		t.Errorf("got V2 swap %+v", swaps[0])
+	}
+	if !reflect.DeepEqual(swaps[1].Path, []common.Address{weth, usdc, dai}) || !reflect.DeepEqual(swaps[1].Fees, []uint32{3000, 500}) {
+		t.Errorf("got V3 path %v fees %v want WETH, USDC, DAI with fees 3000, 500", swaps[1].Path, swaps[1].Fees)
+	}
+	if !swaps[1].ExactOut || swaps[1].AmountOut.Int64() != 2000 || swaps[1].AmountInMax.Int64() != 2100 || swaps[1].Recipient != msgSender {
+		t.Errorf("got V3 swap %+v", swaps[1])
+	}
+}
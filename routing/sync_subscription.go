@@ -0,0 +1,236 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SyncPoolReservesProvider keeps reserves in memory and up to date from the Sync events every V2
+// pair emits when its reserves change, streamed over a websocket log subscription by Run.
+//
+// A pair is cached on its first Sync or its first read through provider, later Syncs replace the
+// cached reserves so repeated quotes do not re-fetch them. Events are only trusted while the
+// subscription is live: before Run and after it fails every read goes to provider.
+type SyncPoolReservesProvider struct {
+	filterer ethereum.LogFilterer
+	provider PoolReservesProvider
+	decoder  *MainFilterer
+	counters cacheCounters
+
+	mu       sync.RWMutex
+	live     bool
+	reserves map[common.Address]syncedReserves
+}
+
+// syncedReserves are the reserves of a pair as of the log at (blockNumber, index)
+type syncedReserves struct {
+	reserve0    *big.Int
+	reserve1    *big.Int
+	blockNumber uint64
+	index       uint
+}
+
+func (s syncedReserves) after(blockNumber uint64, index uint) bool {
+	return s.blockNumber > blockNumber || (s.blockNumber == blockNumber && s.index > index)
+}
+
+func NewSyncPoolReservesProvider(filterer ethereum.LogFilterer, provider PoolReservesProvider) (*SyncPoolReservesProvider, error) {
+	// the address is irrelevant for decoding, logs carry their own pair address
+	decoder, err := NewMainFilterer(common.Address{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SyncPoolReservesProvider{filterer: filterer, provider: provider, decoder: decoder}, nil
+}
+
+// Run subscribes to the Sync events of every pair and applies them until ctx is done or the
+// subscription fails. The cache is dropped on return since events may be missed from then on,
+// callers wanting to keep it fresh call Run again.
+func (p *SyncPoolReservesProvider) Run(ctx context.Context) error {
+	parsed, err := abi.JSON(strings.NewReader(MainABI))
+	if err != nil {
+		return err
+	}
+	// filtering on the topic alone follows every pair, including ones first read after subscribing
+	query := ethereum.FilterQuery{Topics: [][]common.Hash{{parsed.Events["Sync"].ID}}}
+	logs := make(chan types.Log, 256)
+	subscription, err := p.filterer.SubscribeFilterLogs(ctx, query, logs)
+	if err != nil {
+		return err
+	}
+	defer subscription.Unsubscribe()
+	p.setLive(true)
+	defer p.setLive(false)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-subscription.Err():
+			if err == nil {
+				err = errors.New("sync subscription closed")
+			}
+			return err
+		case log := <-logs:
+			if err := p.apply(log); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *SyncPoolReservesProvider) setLive(live bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.live = live
+	p.reserves = nil
+}
+
+func (p *SyncPoolReservesProvider) apply(log types.Log) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if log.Removed {
+		// reorged out, the next read goes to the node until the replacing Sync arrives
+		delete(p.reserves, log.Address)
+		return nil
+	}
+	event, err := p.decoder.ParseSync(log)
+	if err != nil {
+		return err
+	}
+	if cached, ok := p.reserves[log.Address]; ok && cached.after(log.BlockNumber, log.Index) {
+		return nil
+	}
+	if p.reserves == nil {
+		p.reserves = make(map[common.Address]syncedReserves)
+	}
+	p.reserves[log.Address] = syncedReserves{reserve0: event.Reserve0, reserve1: event.Reserve1, blockNumber: log.BlockNumber, index: log.Index}
+	return nil
+}
+
+// cached returns the reserves of pair if they are valid for the block ctx is pinned to
+func (p *SyncPoolReservesProvider) cached(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	reserves, ok := p.reserves[pairAddress]
+	if !p.live || !ok || StateOverrideFromContext(ctx) != nil {
+		return nil, nil, false
+	}
+	// reserves synced after the pinned block are newer than the read asks for
+	if blockNumber := BlockNumberFromContext(ctx); blockNumber != nil && reserves.after(blockNumber.Uint64(), math.MaxUint) {
+		return nil, nil, false
+	}
+	return new(big.Int).Set(reserves.reserve0), new(big.Int).Set(reserves.reserve1), true
+}
+
+// store caches reserves read from the node unless a Sync arrived in the meantime, which is newer
+func (p *SyncPoolReservesProvider) store(ctx context.Context, pairAddress common.Address, reserve0, reserve1 *big.Int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// reserves of a simulated state are not the chain's
+	if !p.live || StateOverrideFromContext(ctx) != nil {
+		return
+	}
+	if _, ok := p.reserves[pairAddress]; ok {
+		return
+	}
+	if p.reserves == nil {
+		p.reserves = make(map[common.Address]syncedReserves)
+	}
+	// an unpinned read is ordered before every Sync so the next one replaces it
+	synced := syncedReserves{reserve0: new(big.Int).Set(reserve0), reserve1: new(big.Int).Set(reserve1)}
+	if blockNumber := BlockNumberFromContext(ctx); blockNumber != nil {
+		synced.blockNumber, synced.index = blockNumber.Uint64(), math.MaxUint
+	}
+	p.reserves[pairAddress] = synced
+}
+
+func (p *SyncPoolReservesProvider) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	reserve0, reserve1, ok := p.cached(ctx, pairAddress)
+	p.counters.count(ok)
+	if ok {
+		return reserve0, reserve1, nil
+	}
+	reserve0, reserve1, err := p.provider.GetPoolReserves(ctx, pairAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.store(ctx, pairAddress, reserve0, reserve1)
+	return reserve0, reserve1, nil
+}
+
+// GetPoolReservesBatch serves cached pairs from memory and reads the rest in one batch when
+// provider supports it
+func (p *SyncPoolReservesProvider) GetPoolReservesBatch(ctx context.Context, pairAddresses []common.Address) ([]*big.Int, []*big.Int, error) {
+	reserves0 := make([]*big.Int, len(pairAddresses))
+	reserves1 := make([]*big.Int, len(pairAddresses))
+	missing := []int{}
+	for i, pairAddress := range pairAddresses {
+		var ok bool
+		if reserves0[i], reserves1[i], ok = p.cached(ctx, pairAddress); !ok {
+			missing = append(missing, i)
+		}
+	}
+	p.counters.hits.Add(uint64(len(pairAddresses) - len(missing)))
+	if len(missing) == 0 {
+		return reserves0, reserves1, nil
+	}
+	batchProvider, ok := p.provider.(BatchPoolReservesProvider)
+	// GetPoolReserves counts the misses it reads
+	if !ok {
+		for _, i := range missing {
+			var err error
+			reserves0[i], reserves1[i], err = p.GetPoolReserves(ctx, pairAddresses[i])
+			if errors.Is(err, ErrPairNotDeployed) {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return reserves0, reserves1, nil
+	}
+	p.counters.misses.Add(uint64(len(missing)))
+	missingAddresses := make([]common.Address, len(missing))
+	for k, i := range missing {
+		missingAddresses[k] = pairAddresses[i]
+	}
+	missing0, missing1, err := batchProvider.GetPoolReservesBatch(ctx, missingAddresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, i := range missing {
+		reserves0[i], reserves1[i] = missing0[k], missing1[k]
+		if missing0[k] != nil {
+			p.store(ctx, pairAddresses[i], missing0[k], missing1[k])
+		}
+	}
+	return reserves0, reserves1, nil
+}
+
+// InvalidatePools drops the cached reserves of pairs, and those of provider when it caches them
+// too. The next read of a pair goes to provider and is cached until its next Sync.
+func (p *SyncPoolReservesProvider) InvalidatePools(ctx context.Context, pairs []common.Address) error {
+	p.mu.Lock()
+	for _, pair := range pairs {
+		delete(p.reserves, pair)
+	}
+	p.mu.Unlock()
+	return invalidateProvider(ctx, p.provider, pairs)
+}
+
+func (p *SyncPoolReservesProvider) CacheSummary() CacheSummary {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.counters.summary(len(p.reserves), fmt.Sprintf("subscription live: %v", p.live))
+}
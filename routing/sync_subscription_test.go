@@ -0,0 +1,133 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// logSubscriptionFake hands the subscription channel to the test so it can push logs
+type logSubscriptionFake struct {
+	logs       chan<- types.Log
+	sub        event.Subscription
+	subscribed chan struct{}
+}
+
+func (f *logSubscriptionFake) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, nil
+}
+
+func (f *logSubscriptionFake) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	f.logs = ch
+	f.sub = event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+	close(f.subscribed)
+	return f.sub, nil
+}
+
+func syncLog(t *testing.T, pair common.Address, blockNumber uint64, index uint, reserve0, reserve1 int64) types.Log {
+	parsed, _ := abi.JSON(strings.NewReader(MainABI))
+	data, err := parsed.Events["Sync"].Inputs.Pack(big.NewInt(reserve0), big.NewInt(reserve1))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	return types.Log{Address: pair, Topics: []common.Hash{parsed.Events["Sync"].ID}, Data: data, BlockNumber: blockNumber, Index: index}
+}
+
+// countingReservesProvider counts reads that reach the node
+type countingReservesProvider struct {
+	PoolReservesProvider
+	reads int
+}
+
+func (p *countingReservesProvider) GetPoolReserves(ctx context.Context, pairAddress common.Address) (*big.Int, *big.Int, error) {
+	p.reads++
+	return p.PoolReservesProvider.GetPoolReserves(ctx, pairAddress)
+}
+
+func TestSyncPoolReservesProvider(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000, 2000)
+	pair := fakePairAddress(common.HexToAddress(WETH), common.HexToAddress(USDC))
+	node := &countingReservesProvider{PoolReservesProvider: graph}
+	filterer := &logSubscriptionFake{subscribed: make(chan struct{})}
+	provider, err := NewSyncPoolReservesProvider(filterer, node)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	// nothing is cached before the subscription is live
+	provider.GetPoolReserves(context.Background(), pair)
+	provider.GetPoolReserves(context.Background(), pair)
+	if node.reads != 2 {
+		t.Errorf("got %v node reads before Run want 2", node.reads)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- provider.Run(ctx) }()
+	<-filterer.subscribed
+
+	// the first read is cached until a Sync replaces it
+	provider.GetPoolReserves(context.Background(), pair)
+	provider.GetPoolReserves(context.Background(), pair)
+	if node.reads != 3 {
+		t.Errorf("got %v node reads want one more for the first read", node.reads)
+	}
+
+	filterer.logs <- syncLog(t, pair, 100, 5, 11, 22)
+	filterer.logs <- syncLog(t, pair, 100, 3, 7, 7)
+	// logs are applied in order, once the marker is in the older log at index 3 was handled
+	marker := common.HexToAddress(WETH_USDC)
+	filterer.logs <- syncLog(t, marker, 100, 6, 1, 1)
+	waitForReserves(t, provider, marker, 1)
+	reserve0, reserve1, _ := provider.GetPoolReserves(context.Background(), pair)
+	if reserve0.Int64() != 11 || reserve1.Int64() != 22 || node.reads != 3 {
+		t.Errorf("got %v/%v after %v node reads want 11/22 from the latest Sync", reserve0, reserve1, node.reads)
+	}
+
+	// reads pinned before the cached Sync go to the node
+	provider.GetPoolReserves(WithBlockNumber(context.Background(), big.NewInt(99)), pair)
+	if node.reads != 4 {
+		t.Errorf("got %v node reads want the pinned read to reach the node", node.reads)
+	}
+
+	removed := syncLog(t, pair, 100, 5, 11, 22)
+	removed.Removed = true
+	filterer.logs <- removed
+	waitForReserves(t, provider, pair, 0)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got %v want context.Canceled", err)
+	}
+	provider.GetPoolReserves(context.Background(), pair)
+	provider.GetPoolReserves(context.Background(), pair)
+	if node.reads != 6 {
+		t.Errorf("got %v node reads after Run returned want every read to reach the node", node.reads)
+	}
+}
+
+// waitForReserves waits until the cached reserve0 of pair is want, 0 meaning not cached
+func waitForReserves(t *testing.T, provider *SyncPoolReservesProvider, pair common.Address, want int64) {
+	for i := 0; i < 100; i++ {
+		provider.mu.RLock()
+		reserves, ok := provider.reserves[pair]
+		provider.mu.RUnlock()
+		if (!ok && want == 0) || (ok && reserves.reserve0.Int64() == want) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("reserve0 of %v never became %v", pair, want)
+}
@@ -0,0 +1,282 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TokenListConfig configures NewTokenListTopTokensProvider, only Source is required
+type TokenListConfig struct {
+	// URL or file path of a token list in the tokenlists.org format
+	Source string
+	// chain the tokens are taken for, defaults to mainnet
+	ChainID int64
+	// when set only tokens with at least one of these tags are used
+	Tags       []string
+	HTTPClient *http.Client
+	// optional, when set the decimals and symbols of the tokens are read through Multicall3 in
+	// one pass when the list is loaded, and compared with the list, see Mismatches
+	Caller bind.ContractCaller
+}
+
+// TokenListMismatch is a token whose decimals or symbol on chain differ from its token list entry
+type TokenListMismatch struct {
+	Token common.Address
+	// "decimals" or "symbol"
+	Field  string
+	Listed string
+	// empty when the token did not answer
+	OnChain string
+}
+
+func (m TokenListMismatch) String() string {
+	if m.OnChain == "" {
+		return fmt.Sprintf("%v of %v is listed as %q but cannot be read on chain", m.Field, m.Token.Hex(), m.Listed)
+	}
+	return fmt.Sprintf("%v of %v is listed as %q but is %q on chain", m.Field, m.Token.Hex(), m.Listed, m.OnChain)
+}
+
+// TokenListTopTokensProvider uses the tokens of a curated token list as the routing base set. The
+// list is loaded and validated on the first call and kept from then on.
+type TokenListTopTokensProvider struct {
+	config TokenListConfig
+
+	mu         sync.Mutex
+	tokens     []common.Address
+	metadata   []Token
+	mismatches []TokenListMismatch
+}
+
+func NewTokenListTopTokensProvider(config TokenListConfig) *TokenListTopTokensProvider {
+	if config.ChainID == 0 {
+		config.ChainID = 1
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &TokenListTopTokensProvider{config: config}
+}
+
+// tokenList is the part of the token list schema the router reads
+type tokenList struct {
+	Name    string `json:"name"`
+	Version *struct {
+		Major *int `json:"major"`
+		Minor *int `json:"minor"`
+		Patch *int `json:"patch"`
+	} `json:"version"`
+	Tokens []struct {
+		ChainID  int64    `json:"chainId"`
+		Address  string   `json:"address"`
+		Name     string   `json:"name"`
+		Symbol   string   `json:"symbol"`
+		Decimals *int     `json:"decimals"`
+		Tags     []string `json:"tags"`
+	} `json:"tokens"`
+}
+
+// validate checks the fields the schema requires, a list failing it is rejected as a whole
+func (l *tokenList) validate() error {
+	if l.Name == "" {
+		return errors.New("token list has no name")
+	}
+	if l.Version == nil || l.Version.Major == nil || l.Version.Minor == nil || l.Version.Patch == nil {
+		return errors.New("token list has no version")
+	}
+	if len(l.Tokens) == 0 {
+		return errors.New("token list has no tokens")
+	}
+	for i, token := range l.Tokens {
+		switch {
+		case token.ChainID <= 0:
+			return fmt.Errorf("token %v has invalid chainId %v", i, token.ChainID)
+		case !common.IsHexAddress(token.Address):
+			return fmt.Errorf("token %v has invalid address %q", i, token.Address)
+		case token.Name == "" || token.Symbol == "":
+			return fmt.Errorf("token %v has no name or symbol", i)
+		case token.Decimals == nil || *token.Decimals < 0 || *token.Decimals > 255:
+			return fmt.Errorf("token %v has invalid decimals", i)
+		}
+	}
+	return nil
+}
+
+func (p *TokenListTopTokensProvider) GetTopTokens(ctx context.Context) ([]common.Address, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tokens != nil {
+		return p.tokens, nil
+	}
+	data, err := p.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list tokenList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parsing token list: %w", err)
+	}
+	if err := list.validate(); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, tag := range p.config.Tags {
+		wanted[tag] = true
+	}
+	tokens := []common.Address{}
+	metadata := []Token{}
+	seen := make(map[common.Address]bool)
+	for _, token := range list.Tokens {
+		if token.ChainID != p.config.ChainID {
+			continue
+		}
+		tagged := len(wanted) == 0
+		for _, tag := range token.Tags {
+			tagged = tagged || wanted[tag]
+		}
+		address := common.HexToAddress(token.Address)
+		if tagged && !seen[address] {
+			seen[address] = true
+			tokens = append(tokens, address)
+			metadata = append(metadata, Token{Address: address, Symbol: token.Symbol, Decimals: uint8(*token.Decimals)})
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("token list %v has no tokens for chain %v and tags %v", list.Name, p.config.ChainID, p.config.Tags)
+	}
+	var mismatches []TokenListMismatch
+	if p.config.Caller != nil {
+		metadata, mismatches, err = verifyTokenMetadata(ctx, p.config.Caller, metadata)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.tokens, p.metadata, p.mismatches = tokens, metadata, mismatches
+	return tokens, nil
+}
+
+// Tokens returns the decimals and symbols of the tokens loaded by GetTopTokens, as read on chain
+// with TokenListConfig.Caller and as listed otherwise, see CachedTokenDecimalsProvider.Prefetch.
+func (p *TokenListTopTokensProvider) Tokens() []Token {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.metadata
+}
+
+// Mismatches returns the tokens loaded by GetTopTokens whose decimals or symbol on chain differ
+// from the list, empty without TokenListConfig.Caller
+func (p *TokenListTopTokensProvider) Mismatches() []TokenListMismatch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mismatches
+}
+
+// verifyTokenMetadata reads decimals() and symbol() of every listed token through Multicall3,
+// returning the tokens with their on-chain values and the ones differing from the list. Tokens
+// that do not answer keep their listed values.
+func verifyTokenMetadata(ctx context.Context, caller bind.ContractCaller, listed []Token) ([]Token, []TokenListMismatch, error) {
+	erc20, err := abi.JSON(strings.NewReader(MainABI))
+	if err != nil {
+		return nil, nil, err
+	}
+	decimalsCall, err := erc20.Pack("decimals")
+	if err != nil {
+		return nil, nil, err
+	}
+	symbolCall, err := erc20.Pack("symbol")
+	if err != nil {
+		return nil, nil, err
+	}
+	calls := make([]multicall3Call, 0, 2*len(listed))
+	for _, token := range listed {
+		calls = append(calls,
+			multicall3Call{Target: token.Address, AllowFailure: true, CallData: decimalsCall},
+			multicall3Call{Target: token.Address, AllowFailure: true, CallData: symbolCall})
+	}
+	results := make([]multicall3Result, 0, len(calls))
+	for start := 0; start < len(calls); start += defaultMulticallBatchSize {
+		end := start + defaultMulticallBatchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		batch, err := aggregate3(ctx, caller, calls[start:end])
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading decimals and symbols of %v tokens through multicall: %w", len(listed), err)
+		}
+		results = append(results, batch...)
+	}
+
+	verified := make([]Token, len(listed))
+	mismatches := []TokenListMismatch{}
+	for i, token := range listed {
+		verified[i] = token
+		decimals, symbol := results[2*i], results[2*i+1]
+		onChainDecimals := ""
+		if values, err := erc20.Unpack("decimals", decimals.ReturnData); decimals.Success && err == nil {
+			verified[i].Decimals = values[0].(uint8)
+			onChainDecimals = strconv.Itoa(int(verified[i].Decimals))
+		}
+		if onChainDecimals != strconv.Itoa(int(token.Decimals)) {
+			mismatches = append(mismatches, TokenListMismatch{Token: token.Address, Field: "decimals", Listed: strconv.Itoa(int(token.Decimals)), OnChain: onChainDecimals})
+		}
+		onChainSymbol := ""
+		if symbol.Success {
+			onChainSymbol = decodeSymbol(erc20, symbol.ReturnData)
+		}
+		if onChainSymbol != "" {
+			verified[i].Symbol = onChainSymbol
+		}
+		if onChainSymbol != token.Symbol {
+			mismatches = append(mismatches, TokenListMismatch{Token: token.Address, Field: "symbol", Listed: token.Symbol, OnChain: onChainSymbol})
+		}
+	}
+	return verified, mismatches, nil
+}
+
+// decodeSymbol decodes a string symbol, or the bytes32 one of tokens like MKR, empty when neither
+func decodeSymbol(erc20 abi.ABI, data []byte) string {
+	if values, err := erc20.Unpack("symbol", data); err == nil {
+		return values[0].(string)
+	}
+	if len(data) == 32 {
+		return strings.TrimRight(string(data), "\x00")
+	}
+	return ""
+}
+
+func (p *TokenListTopTokensProvider) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(p.config.Source, "http://") && !strings.HasPrefix(p.config.Source, "https://") {
+		return os.ReadFile(p.config.Source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token list returned status %v", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (p *TokenListTopTokensProvider) CacheSummary() CacheSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return CacheSummary{Entries: len(p.tokens)}
+}
@@ -0,0 +1,154 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTokenListTopTokensProvider(t *testing.T) {
+	tokens, err := NewTokenListTopTokensProvider(TokenListConfig{Source: "testdata/token_list.json"}).GetTopTokens(context.Background())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	want := []common.Address{common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)}
+	if len(tokens) != len(want) {
+		t.Fatalf("got tokens %v want the mainnet tokens %v", tokens, want)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("got tokens %v want %v", tokens, want)
+		}
+	}
+
+	data, err := os.ReadFile("testdata/token_list.json")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+	tokens, err = NewTokenListTopTokensProvider(TokenListConfig{Source: server.URL, ChainID: 10, Tags: []string{"stablecoin"}}).GetTopTokens(context.Background())
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(tokens) != 1 || tokens[0] != common.HexToAddress("0x7F5c764cBc14f9669B88837ca1490cCa17c31607") {
+		t.Errorf("got tokens %v want the Optimism USDC", tokens)
+	}
+}
+
+func TestTokenListRejectsInvalidLists(t *testing.T) {
+	lists := map[string]string{
+		"no version":    `{"name": "x", "tokens": [{"chainId": 1, "address": "` + WETH + `", "name": "Wrapped Ether", "symbol": "WETH", "decimals": 18}]}`,
+		"bad address":   `{"name": "x", "version": {"major": 1, "minor": 0, "patch": 0}, "tokens": [{"chainId": 1, "address": "0x12", "name": "Wrapped Ether", "symbol": "WETH", "decimals": 18}]}`,
+		"no decimals":   `{"name": "x", "version": {"major": 1, "minor": 0, "patch": 0}, "tokens": [{"chainId": 1, "address": "` + WETH + `", "name": "Wrapped Ether", "symbol": "WETH"}]}`,
+		"no such chain": `{"name": "x", "version": {"major": 1, "minor": 0, "patch": 0}, "tokens": [{"chainId": 5, "address": "` + WETH + `", "name": "Wrapped Ether", "symbol": "WETH", "decimals": 18}]}`,
+		"not json":      `<html>`,
+	}
+	for name, list := range lists {
+		path := t.TempDir() + "/list.json"
+		if err := os.WriteFile(path, []byte(list), 0o644); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if _, err := NewTokenListTopTokensProvider(TokenListConfig{Source: path}).GetTopTokens(context.Background()); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+// tokenMetadataFake answers aggregate3 calls of decimals() and symbol(), tokens missing from
+// decimals revert
+type tokenMetadataFake struct {
+	decimals map[common.Address]uint8
+	// symbols longer than 32 bytes are not supported, bytes32 ones are returned as such
+	symbols map[common.Address]string
+	bytes32 map[common.Address]bool
+	calls   int
+}
+
+func (f *tokenMetadataFake) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (f *tokenMetadataFake) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	f.calls++
+	multicall, _ := abi.JSON(strings.NewReader(multicall3ABI))
+	erc20, _ := abi.JSON(strings.NewReader(MainABI))
+	method := multicall.Methods["aggregate3"]
+	inputs, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	calls := *abi.ConvertType(inputs[0], new([]multicall3Call)).(*[]multicall3Call)
+	results := make([]multicall3Result, len(calls))
+	for i, c := range calls {
+		decimals, ok := f.decimals[c.Target]
+		if !ok {
+			continue
+		}
+		var data []byte
+		switch {
+		case string(c.CallData) == string(erc20.Methods["decimals"].ID):
+			data, err = erc20.Methods["decimals"].Outputs.Pack(decimals)
+		case f.bytes32[c.Target]:
+			data = common.RightPadBytes([]byte(f.symbols[c.Target]), 32)
+		default:
+			data, err = erc20.Methods["symbol"].Outputs.Pack(f.symbols[c.Target])
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[i] = multicall3Result{Success: true, ReturnData: data}
+	}
+	return method.Outputs.Pack(results)
+}
+
+func TestTokenListVerifiesMetadata(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	caller := &tokenMetadataFake{
+		// DAI does not answer
+		decimals: map[common.Address]uint8{weth: 18, usdc: 8},
+		symbols:  map[common.Address]string{weth: "WETH", usdc: "USDC.e"},
+		bytes32:  map[common.Address]bool{weth: true},
+	}
+	provider := NewTokenListTopTokensProvider(TokenListConfig{Source: "testdata/token_list.json", Caller: caller})
+	if _, err := provider.GetTopTokens(context.Background()); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if caller.calls != 1 {
+		t.Errorf("got %v calls want the list verified in one multicall", caller.calls)
+	}
+	want := []TokenListMismatch{
+		{Token: usdc, Field: "decimals", Listed: "6", OnChain: "8"},
+		{Token: usdc, Field: "symbol", Listed: "USDC", OnChain: "USDC.e"},
+		{Token: dai, Field: "decimals", Listed: "18"},
+		{Token: dai, Field: "symbol", Listed: "DAI"},
+	}
+	if got := provider.Mismatches(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got mismatches %v want %v", got, want)
+	}
+	// the on-chain values win, tokens that did not answer keep the listed ones
+	wantTokens := []Token{{Address: weth, Symbol: "WETH", Decimals: 18}, {Address: usdc, Symbol: "USDC.e", Decimals: 8}, {Address: dai, Symbol: "DAI", Decimals: 18}}
+	if got := provider.Tokens(); fmt.Sprint(got) != fmt.Sprint(wantTokens) {
+		t.Errorf("got tokens %v want %v", got, wantTokens)
+	}
+
+	decimals := &TokenDecimalsProviderMock{}
+	cache := NewCachedTokenDecimalsProvider(decimals, 0)
+	cache.Prefetch(provider.Tokens())
+	if got, err := cache.GetTokenDecimals(context.Background(), usdc); err != nil || got != 8 {
+		t.Errorf("got decimals %v error %v want the prefetched 8", got, err)
+	}
+	decimals.AssertNotCalled(t, "GetTokenDecimals", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,123 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+type TokenSymbolProvider interface {
+	GetTokenSymbol(ctx context.Context, tokenAddress common.Address) (string, error)
+}
+
+type OnChainTokenSymbolProvider struct {
+	rpcClient *ethclient.Client
+}
+
+func NewOnChainTokenSymbolProvider(rpcClient *ethclient.Client) *OnChainTokenSymbolProvider {
+	return &OnChainTokenSymbolProvider{rpcClient: rpcClient}
+}
+
+func (f *OnChainTokenSymbolProvider) GetTokenSymbol(ctx context.Context, tokenAddress common.Address) (string, error) {
+	caller, err := NewMainCaller(tokenAddress, f.rpcClient)
+	if err != nil {
+		return "", fmt.Errorf("binding token %v: %w", tokenAddress.String(), err)
+	}
+	symbol, err := caller.Symbol(newCallOpts(ctx))
+	if err != nil {
+		return "", fmt.Errorf("reading symbol of %v: %w", tokenAddress.String(), err)
+	}
+	return symbol, nil
+}
+
+// Token is what amounts of a token are parsed and displayed with
+type Token struct {
+	Address  common.Address `json:"address"`
+	Symbol   string         `json:"symbol,omitempty"`
+	Decimals uint8          `json:"decimals"`
+}
+
+// String is the symbol, or the address for tokens without a readable one
+func (t Token) String() string {
+	if t.Symbol == "" {
+		return t.Address.Hex()
+	}
+	return t.Symbol
+}
+
+// ParseAmount converts an amount in token units, e.g. "1.5", to the smallest unit
+func (t Token) ParseAmount(amount string) (*big.Int, error) {
+	return ParseUnits(amount, t.Decimals)
+}
+
+// FormatAmount writes an amount in the smallest unit in token units followed by the symbol, e.g.
+// "1.5 WETH"
+func (t Token) FormatAmount(amount *big.Int) string {
+	return FormatUnits(amount, t.Decimals) + " " + t.String()
+}
+
+// TokenMetadataProvider reads the decimals and symbol of tokens
+type TokenMetadataProvider struct {
+	decimals TokenDecimalsProvider
+	symbols  TokenSymbolProvider
+}
+
+func NewTokenMetadataProvider(decimals TokenDecimalsProvider, symbols TokenSymbolProvider) *TokenMetadataProvider {
+	return &TokenMetadataProvider{decimals: decimals, symbols: symbols}
+}
+
+// GetToken fails when the decimals cannot be read, amounts would be off by orders of magnitude
+// without them. The symbol is only for display and left empty when it cannot be read, e.g. for
+// tokens returning bytes32.
+func (p *TokenMetadataProvider) GetToken(ctx context.Context, address common.Address) (Token, error) {
+	decimals, err := p.decimals.GetTokenDecimals(ctx, address)
+	if err != nil {
+		return Token{}, err
+	}
+	symbol, _ := p.symbols.GetTokenSymbol(ctx, address)
+	return Token{Address: address, Symbol: symbol, Decimals: decimals}, nil
+}
+
+// ResolveToken parses input as an address, or else as the symbol of one of tokens, e.g. a chain's
+// BaseTokens, ignoring case, or else as an ENS name like dai.tokens.ethers.eth. Symbols are not
+// unique on chain, so only known tokens are matched and a symbol two of them share is an error.
+// Symbols win over names since some contain dots, e.g. USDC.e. ens may be nil, see ResolveAddress.
+func ResolveToken(ctx context.Context, input string, tokens []common.Address, symbols TokenSymbolProvider, ens *ENSResolver) (common.Address, error) {
+	if address, err := ParseAddress(input); err == nil {
+		return address, nil
+	}
+	input = strings.TrimSpace(input)
+	matched, errs := make([]bool, len(tokens)), make([]error, len(tokens))
+	// tokens whose symbol cannot be read, e.g. MKR's bytes32 symbol, are not matched
+	forEachParallel(ctx, len(tokens), defaultParallelism, func(ctx context.Context, i int) error {
+		var symbol string
+		symbol, errs[i] = symbols.GetTokenSymbol(ctx, tokens[i])
+		matched[i] = errs[i] == nil && strings.EqualFold(symbol, input)
+		return nil
+	})
+	found, failed := []common.Address{}, 0
+	for i, token := range tokens {
+		if matched[i] {
+			found = append(found, token)
+		}
+		if errs[i] != nil {
+			failed++
+		}
+	}
+	switch {
+	case len(found) == 0 && IsENSName(input):
+		return ResolveAddress(ctx, input, ens)
+	case len(found) == 0 && failed > 0 && failed == len(tokens):
+		// more likely the node is down than every token unreadable
+		return common.Address{}, fmt.Errorf("resolving %q: %w", input, errs[0])
+	case len(found) == 0:
+		return common.Address{}, fmt.Errorf("%q is neither an address, the symbol of a base token nor an ENS name", input)
+	case len(found) == 1:
+		return found[0], nil
+	}
+	return common.Address{}, fmt.Errorf("symbol %q is ambiguous between %v, use an address", input, found)
+}
@@ -0,0 +1,76 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type tokenSymbolsFake map[common.Address]string
+
+func (f tokenSymbolsFake) GetTokenSymbol(ctx context.Context, tokenAddress common.Address) (string, error) {
+	symbol, ok := f[tokenAddress]
+	if !ok {
+		return "", errors.New("execution reverted")
+	}
+	return symbol, nil
+}
+
+func TestResolveToken(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	symbols := tokenSymbolsFake{weth: "WETH", usdc: "USDC"}
+	tokens := []common.Address{weth, usdc, dai}
+
+	tests := []struct {
+		input string
+		want  common.Address
+	}{
+		{"WETH", weth},
+		{" usdc", usdc},
+		{DAI, dai},
+	}
+	for _, test := range tests {
+		token, err := ResolveToken(context.Background(), test.input, tokens, symbols, nil)
+		if err != nil || token != test.want {
+			t.Errorf("%q: got %v error %v want %v", test.input, token, err, test.want)
+		}
+	}
+
+	// DAI's symbol cannot be read, and symbols of tokens outside the list are never matched
+	for _, input := range []string{"DAI", "UNI", "0x1234"} {
+		if _, err := ResolveToken(context.Background(), input, tokens, symbols, nil); err == nil {
+			t.Errorf("%q: got no error", input)
+		}
+	}
+
+	if _, err := ResolveToken(context.Background(), "WETH", tokens, tokenSymbolsFake{}, nil); err == nil || !strings.Contains(err.Error(), "execution reverted") {
+		t.Errorf("got error %v want the failed read when no symbol could be read", err)
+	}
+
+	symbols[dai] = "USDC"
+	if _, err := ResolveToken(context.Background(), "USDC", tokens, symbols, nil); err == nil {
+		t.Error("got no error for a symbol two tokens share")
+	}
+}
+
+func TestTokenMetadataProvider(t *testing.T) {
+	weth, unnamed := common.HexToAddress(WETH), common.HexToAddress("0x1234")
+	provider := NewTokenMetadataProvider(&v2GraphFake{}, tokenSymbolsFake{weth: "WETH"})
+	token, err := provider.GetToken(context.Background(), weth)
+	if err != nil || token != (Token{Address: weth, Symbol: "WETH", Decimals: 18}) {
+		t.Fatalf("got token %+v error %v want WETH with 18 decimals", token, err)
+	}
+	if amount, err := token.ParseAmount("1.5"); err != nil || token.FormatAmount(amount) != "1.5 WETH" {
+		t.Errorf("got %v error %v want 1.5 WETH to survive parsing and formatting", amount, err)
+	}
+
+	// a token without a readable symbol is still usable, shown by its address
+	token, err = provider.GetToken(context.Background(), unnamed)
+	if err != nil || token.FormatAmount(big.NewInt(2e18)) != "2 "+unnamed.Hex() {
+		t.Errorf("got token %+v error %v want amounts shown with the address", token, err)
+	}
+}
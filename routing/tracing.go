@@ -0,0 +1,57 @@
+package routing
+
+import (
+	"context"
+)
+
+// Attribute describes a span, values are strings, integers, floats, bools or anything with a
+// String method like common.Address
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts the spans of a route: the route itself, pool discovery, reserve fetching and
+// every JSON-RPC request. It follows OpenTelemetry's trace.Tracer, so an adapter around an
+// OpenTelemetry SDK tracer is a few lines; OTLPTracer exports to a collector without one.
+type Tracer interface {
+	// Start begins a span, a child of the span ctx carries if any, and returns a context carrying it
+	Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span is an operation started by a Tracer
+type Span interface {
+	SetAttributes(attributes ...Attribute)
+	// RecordError marks the span failed, nil errors are ignored
+	RecordError(err error)
+	End()
+}
+
+type tracerKey struct{}
+
+// WithTracer makes the providers and RPC clients called with the returned context record spans,
+// V2RouterConfig.Tracer sets it for every route
+func WithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// startSpan starts a span with the tracer of ctx, requests without one or not picked by the trace
+// sampler get a span that records nothing
+func startSpan(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span) {
+	if !tracing(ctx) {
+		return ctx, noopSpan{}
+	}
+	return ctx.Value(tracerKey{}).(Tracer).Start(ctx, name, attributes...)
+}
+
+// tracing reports whether spans started with ctx are recorded
+func tracing(ctx context.Context) bool {
+	tracer, ok := ctx.Value(tracerKey{}).(Tracer)
+	return ok && tracer != nil && isTraced(ctx)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attributes ...Attribute) {}
+func (noopSpan) RecordError(err error)                 {}
+func (noopSpan) End()                                  {}
@@ -0,0 +1,115 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TWAPPlan splits a large order into equal slices executed one interval apart
+type TWAPPlan struct {
+	AmountIn *big.Int
+	Interval time.Duration
+	Slices   []TWAPSlice
+	// sum of the slices quoted at the current reserves, assuming arbitrage restores the pools'
+	// prices between slices
+	ExpectedAmountOut *big.Int
+	// output when prices never recover and every slice trades against the reserves the earlier
+	// slices left behind
+	WorstCaseAmountOut *big.Int
+	BlockNumber        *big.Int
+}
+
+// TWAPSlice is one slice of a TWAPPlan
+type TWAPSlice struct {
+	// offset from the start of the execution
+	At                 time.Duration
+	AmountIn           *big.Int
+	Path               []common.Address
+	ExpectedAmountOut  *big.Int
+	WorstCaseAmountOut *big.Int
+}
+
+// PlanTWAP splits amountIn into slices, quotes every slice with the router at the current reserves
+// and bounds the total between full price recovery and none at all. The last slice takes the
+// rounding remainder.
+func (r *OnChainV2Router) PlanTWAP(ctx context.Context, amountIn *big.Int, tokenIn, tokenOut common.Address, slices int, interval time.Duration, maxHops int) (*TWAPPlan, error) {
+	if tokenIn == tokenOut {
+		return nil, fmt.Errorf("%w: %v", ErrSameToken, tokenIn.String())
+	}
+	if slices < 1 {
+		return nil, errors.New("slices must be at least 1")
+	}
+	if amountIn == nil || amountIn.Cmp(big.NewInt(int64(slices))) < 0 {
+		return nil, errors.New("amountIn must be at least one unit per slice")
+	}
+	ctx, err := r.pinBlock(ensureRequestID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	maxHops, err = r.resolveMaxHops(ctx, tokenIn, tokenOut, maxHops)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := r.buildGraph(ctx, tokenIn, tokenOut, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &TWAPPlan{
+		AmountIn:           new(big.Int).Set(amountIn),
+		Interval:           interval,
+		ExpectedAmountOut:  new(big.Int),
+		WorstCaseAmountOut: new(big.Int),
+		BlockNumber:        BlockNumberFromContext(ctx),
+	}
+	sliceAmount := new(big.Int).Quo(amountIn, big.NewInt(int64(slices)))
+	remainder := new(big.Int).Sub(amountIn, new(big.Int).Mul(sliceAmount, big.NewInt(int64(slices))))
+	// reserves left by the earlier slices, by pool and input token
+	type poolSide struct {
+		pair    common.Address
+		tokenIn common.Address
+	}
+	depleted := make(map[poolSide]hopReserves)
+	for i := 0; i < slices; i++ {
+		amount := sliceAmount
+		if i == slices-1 {
+			amount = new(big.Int).Add(sliceAmount, remainder)
+		}
+		expected, path, hops, err := searchRoute(ctx, graph, exactIn, amount, maxHops, nil, &RouteMetadata{}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		worstCase := amount
+		for j, hop := range hops {
+			if current, ok := depleted[poolSide{hop.pair, path[j]}]; ok {
+				hop = current
+			}
+			amountOut, err := hop.GetAmountOut(worstCase)
+			if err != nil {
+				return nil, err
+			}
+			reserveIn := new(big.Int).Add(hop.reserveIn, worstCase)
+			reserveOut := new(big.Int).Sub(hop.reserveOut, amountOut)
+			depleted[poolSide{hop.pair, path[j]}] = hopReserves{reserveIn: reserveIn, reserveOut: reserveOut, pair: hop.pair, dex: hop.dex}
+			depleted[poolSide{hop.pair, path[j+1]}] = hopReserves{reserveIn: reserveOut, reserveOut: reserveIn, pair: hop.pair, dex: hop.dex}
+			worstCase = amountOut
+		}
+
+		plan.Slices = append(plan.Slices, TWAPSlice{
+			At:                 time.Duration(i) * interval,
+			AmountIn:           new(big.Int).Set(amount),
+			Path:               path,
+			ExpectedAmountOut:  expected,
+			WorstCaseAmountOut: worstCase,
+		})
+		plan.ExpectedAmountOut.Add(plan.ExpectedAmountOut, expected)
+		plan.WorstCaseAmountOut.Add(plan.WorstCaseAmountOut, worstCase)
+	}
+	return plan, nil
+}
@@ -0,0 +1,57 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestPlanTWAP(t *testing.T) {
+	graph := &v2GraphFake{}
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 1000000, 2000000000)
+	router := newFakeRouter(graph)
+
+	amountIn := big.NewInt(100003)
+	plan, err := router.PlanTWAP(context.Background(), amountIn, common.HexToAddress(WETH), common.HexToAddress(USDC), 4, time.Minute, 2)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(plan.Slices) != 4 {
+		t.Fatalf("got %v slices want 4", len(plan.Slices))
+	}
+	total := new(big.Int)
+	for i, slice := range plan.Slices {
+		total.Add(total, slice.AmountIn)
+		if slice.At != time.Duration(i)*time.Minute {
+			t.Errorf("slice %v: got start %v want %v", i, slice.At, time.Duration(i)*time.Minute)
+		}
+	}
+	if total.Cmp(amountIn) != 0 || plan.Slices[3].AmountIn.Cmp(big.NewInt(25003)) != 0 {
+		t.Errorf("got slices summing to %v with last %v want %v with the remainder last", total, plan.Slices[3].AmountIn, amountIn)
+	}
+
+	// with full recovery every slice gets the fresh price of a quarter of the order
+	wantSlice, _ := getAmountOut(big.NewInt(25000), big.NewInt(1000000), big.NewInt(2000000000))
+	if plan.Slices[0].ExpectedAmountOut.Cmp(wantSlice) != 0 {
+		t.Errorf("got first slice %v want %v", plan.Slices[0].ExpectedAmountOut, wantSlice)
+	}
+	// without recovery the slices pay about the price impact of one trade, slightly more since each
+	// slice's fee stays in the pool ahead of the next
+	single, _ := getAmountOut(amountIn, big.NewInt(1000000), big.NewInt(2000000000))
+	if plan.WorstCaseAmountOut.Cmp(plan.ExpectedAmountOut) >= 0 {
+		t.Errorf("got worst case %v not below expected %v", plan.WorstCaseAmountOut, plan.ExpectedAmountOut)
+	}
+	if diff := new(big.Int).Sub(single, plan.WorstCaseAmountOut); diff.Sign() < 0 || diff.Cmp(new(big.Int).Quo(single, big.NewInt(1000))) > 0 {
+		t.Errorf("got worst case %v want within 0.1%% below the single trade %v", plan.WorstCaseAmountOut, single)
+	}
+	if plan.Slices[0].WorstCaseAmountOut.Cmp(plan.Slices[0].ExpectedAmountOut) != 0 {
+		t.Errorf("got first slice worst case %v want its expected %v", plan.Slices[0].WorstCaseAmountOut, plan.Slices[0].ExpectedAmountOut)
+	}
+
+	if _, err := router.PlanTWAP(context.Background(), big.NewInt(3), common.HexToAddress(WETH), common.HexToAddress(USDC), 4, time.Minute, 2); err == nil {
+		t.Error("got no error for less than one unit per slice")
+	}
+}
@@ -0,0 +1,245 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultSwapDeadline is how long built swaps stay valid when no deadline is given
+const DefaultSwapDeadline = 20 * time.Minute
+
+var errNoSlippageLimits = errors.New("quote has no slippage limits, see Quote.SetSlippage")
+
+// SwapOptions tune the transaction TxBuilder builds from a quote
+type SwapOptions struct {
+	// receives the output, required
+	Recipient common.Address
+	// zero means DefaultSwapDeadline from now
+	Deadline time.Time
+	// pay with ETH instead of WETH, the quote must start at WETH
+	NativeIn bool
+	// receive ETH instead of WETH, the quote must end at WETH
+	NativeOut bool
+}
+
+// SwapTx is a contract call ready to be signed and sent: a router swap, a token approval or a permit
+type SwapTx struct {
+	To     common.Address
+	Data   []byte
+	Value  *big.Int
+	Method string
+	// what the swap counts towards the daily exposure limits, see TxBuilder.Release
+	exposure *ExposureReservation
+}
+
+// TxBuilder turns quotes into Uniswap V2 Router02 swap calls. Router02 only swaps through Uniswap
+// V2 pairs, so quotes with hops on other DEXes are rejected, as are swaps exceeding the exposure
+// limits of their tokens.
+type TxBuilder struct {
+	router          common.Address
+	universalRouter common.Address
+	// swapped for the native token with SwapOptions.NativeIn and NativeOut
+	wrappedNative common.Address
+	exposure      *exposureTracker
+	now           func() time.Time
+}
+
+// NewTxBuilder builds calls to the Router02 at router, limits may be nil. Every swap built counts
+// towards the daily limits until it is released, see Release. Native swaps and Universal Router calls are
+// built for Mainnet, see SetChain.
+func NewTxBuilder(router common.Address, limits map[common.Address]ExposureLimit) *TxBuilder {
+	return &TxBuilder{router: router, universalRouter: Mainnet.UniversalRouter, wrappedNative: Mainnet.WrappedNative, exposure: newExposureTracker(limits), now: time.Now}
+}
+
+// SetChain builds native swaps and Universal Router calls for chain, router stays as given
+func (b *TxBuilder) SetChain(chain Chain) {
+	b.universalRouter, b.wrappedNative = chain.UniversalRouter, chain.WrappedNative
+}
+
+// Release stops counting a swap built by b towards the daily limits, for swaps that failed or
+// were never sent. Swaps built on an earlier day and swaps released before are ignored.
+func (b *TxBuilder) Release(swap *SwapTx) {
+	b.exposure.release(swap.exposure)
+	swap.exposure = nil
+}
+
+// restore counts a reservation saved before a restart towards the daily limits again and attaches
+// it to swap, so releasing swap releases it
+func (b *TxBuilder) restore(swap *SwapTx, reservation *ExposureReservation) {
+	b.exposure.restore(b.now(), reservation)
+	swap.exposure = reservation
+}
+
+// DailyExposure returns the amount of token sold and bought by the swaps built today (UTC)
+func (b *TxBuilder) DailyExposure(token common.Address) *big.Int {
+	return b.exposure.usedOn(b.now(), token)
+}
+
+// Build encodes the swap of quote with its slippage limits: exact-in quotes become
+// swapExact*For* calls bounded by AmountOutMin, exact-out quotes swap*ForExact* calls bounded
+// by AmountInMax. Routes through fee-on-transfer tokens use the SupportingFeeOnTransferTokens
+// variants, which only exist for exact-in swaps.
+func (b *TxBuilder) Build(quote *Quote, options SwapOptions) (*SwapTx, error) {
+	deadline, err := b.check(quote, options)
+	if err != nil {
+		return nil, err
+	}
+	deadlineArg := big.NewInt(deadline.Unix())
+
+	path := quote.Path()
+	tx := &SwapTx{To: b.router, Value: new(big.Int)}
+	var args []interface{}
+	switch {
+	case !quote.ExactOut && options.NativeIn:
+		tx.Method, tx.Value = "swapExactETHForTokens", quote.AmountIn
+		args = []interface{}{quote.AmountOutMin, path, options.Recipient, deadlineArg}
+	case !quote.ExactOut && options.NativeOut:
+		tx.Method = "swapExactTokensForETH"
+		args = []interface{}{quote.AmountIn, quote.AmountOutMin, path, options.Recipient, deadlineArg}
+	case !quote.ExactOut:
+		tx.Method = "swapExactTokensForTokens"
+		args = []interface{}{quote.AmountIn, quote.AmountOutMin, path, options.Recipient, deadlineArg}
+	case options.NativeIn:
+		// Router02 refunds the ETH not spent
+		tx.Method, tx.Value = "swapETHForExactTokens", quote.AmountInMax
+		args = []interface{}{quote.AmountOut, path, options.Recipient, deadlineArg}
+	case options.NativeOut:
+		tx.Method = "swapTokensForExactETH"
+		args = []interface{}{quote.AmountOut, quote.AmountInMax, path, options.Recipient, deadlineArg}
+	default:
+		tx.Method = "swapTokensForExactTokens"
+		args = []interface{}{quote.AmountOut, quote.AmountInMax, path, options.Recipient, deadlineArg}
+	}
+	// the supporting methods take the same arguments and check the recipient's balance instead of
+	// the amounts computed from the reserves
+	if quote.HasTransferFees() {
+		tx.Method += "SupportingFeeOnTransferTokens"
+	}
+	data, err := router02Parsed.Pack(tx.Method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", tx.Method, err)
+	}
+	// the most that can be sold and the expected amount bought
+	tx.exposure, err = b.exposure.reserve(b.now(), []ReservedAmount{{quote.TokenIn, quote.AmountInMax}, {quote.TokenOut, quote.AmountOut}})
+	if err != nil {
+		return nil, err
+	}
+	tx.Data = data
+	return tx, nil
+}
+
+// check validates quote and options for a swap and returns its deadline
+func (b *TxBuilder) check(quote *Quote, options SwapOptions) (time.Time, error) {
+	if options.Recipient == (common.Address{}) {
+		return time.Time{}, errors.New("swap recipient is required")
+	}
+	if quote.AmountOutMin == nil || quote.AmountInMax == nil {
+		return time.Time{}, errNoSlippageLimits
+	}
+	if len(quote.Hops) == 0 {
+		return time.Time{}, errors.New("quote has no hops")
+	}
+	for _, hop := range quote.Hops {
+		if hop.DEX != "" && hop.DEX != "uniswap-v2" {
+			return time.Time{}, fmt.Errorf("hop %v -> %v trades on %v, Router02 only swaps through Uniswap V2 pairs", hop.TokenIn.String(), hop.TokenOut.String(), hop.DEX)
+		}
+	}
+	weth := b.wrappedNative
+	if options.NativeIn && quote.TokenIn != weth {
+		return time.Time{}, fmt.Errorf("cannot pay in ETH for a route starting at %v", quote.TokenIn.String())
+	}
+	if options.NativeOut && quote.TokenOut != weth {
+		return time.Time{}, fmt.Errorf("cannot pay out ETH from a route ending at %v", quote.TokenOut.String())
+	}
+	if options.NativeIn && options.NativeOut {
+		return time.Time{}, errors.New("cannot swap ETH for ETH")
+	}
+	if quote.ExactOut && quote.HasTransferFees() {
+		return time.Time{}, fmt.Errorf("route takes transfer fees of %v, fee-on-transfer tokens can only be swapped exact-in", quote.TransferFees)
+	}
+	deadline := options.Deadline
+	if deadline.IsZero() {
+		deadline = b.now().Add(DefaultSwapDeadline)
+	}
+	return deadline, nil
+}
+
+// Universal Router commands BuildUniversalRouter uses besides the V2 swaps
+const (
+	urPermit2Permit = 0x0a
+	urUnwrapWETH    = 0x0c
+)
+
+// the Universal Router's placeholder recipient for itself
+var universalRouterSelf = common.HexToAddress("0x0000000000000000000000000000000000000002")
+
+// BuildUniversalRouter encodes the swap of quote as a Universal Router execute call at
+// UNIVERSAL_ROUTER_ADDRESS, paid through Permit2 instead of a Router02 allowance. A permit signed
+// for the Universal Router (SignPermit2) is run first, so no approval transaction is needed per
+// swap; without one the Universal Router must already hold a Permit2 allowance.
+func (b *TxBuilder) BuildUniversalRouter(quote *Quote, options SwapOptions, permit *Permit2Permit) (*SwapTx, error) {
+	deadline, err := b.check(quote, options)
+	if err != nil {
+		return nil, err
+	}
+	if options.NativeIn {
+		return nil, errors.New("swaps paid in ETH need no allowance, build them for Router02 instead")
+	}
+	if permit != nil && (permit.Token != quote.TokenIn || permit.Amount.Cmp(quote.AmountInMax) < 0) {
+		return nil, fmt.Errorf("permit covers %v %v, the swap sells up to %v %v", permit.Amount, permit.Token.String(), quote.AmountInMax, quote.TokenIn.String())
+	}
+	var commands []byte
+	var inputs [][]byte
+	if permit != nil {
+		input, err := permit.encode()
+		if err != nil {
+			return nil, fmt.Errorf("PERMIT2_PERMIT: %w", err)
+		}
+		commands, inputs = append(commands, urPermit2Permit), append(inputs, input)
+	}
+	// ETH is unwrapped by the router after swapping to itself
+	recipient := options.Recipient
+	if options.NativeOut {
+		recipient = universalRouterSelf
+	}
+	command, amount, limit := byte(urV2SwapExactIn), quote.AmountIn, quote.AmountOutMin
+	if quote.ExactOut {
+		command, amount, limit = urV2SwapExactOut, quote.AmountOut, quote.AmountInMax
+	}
+	// the tokens are pulled from the sender through Permit2
+	swap, err := universalRouterSwapArguments("address[]").Pack(recipient, amount, limit, quote.Path(), true)
+	if err != nil {
+		return nil, fmt.Errorf("V2 swap: %w", err)
+	}
+	commands, inputs = append(commands, command), append(inputs, swap)
+	if options.NativeOut {
+		minimum := quote.AmountOutMin
+		if quote.ExactOut {
+			minimum = quote.AmountOut
+		}
+		unwrap, err := abi.Arguments{{Type: abiType("address")}, {Type: abiType("uint256")}}.Pack(options.Recipient, minimum)
+		if err != nil {
+			return nil, fmt.Errorf("UNWRAP_WETH: %w", err)
+		}
+		commands, inputs = append(commands, urUnwrapWETH), append(inputs, unwrap)
+	}
+	data, err := universalRouterParsed.Pack("execute", commands, inputs, big.NewInt(deadline.Unix()))
+	if err != nil {
+		return nil, fmt.Errorf("execute: %w", err)
+	}
+	exposure, err := b.exposure.reserve(b.now(), []ReservedAmount{{quote.TokenIn, quote.AmountInMax}, {quote.TokenOut, quote.AmountOut}})
+	if err != nil {
+		return nil, err
+	}
+	return &SwapTx{To: b.universalRouter, Data: data, Value: new(big.Int), Method: "execute", exposure: exposure}, nil
+}
+
+func abiType(name string) abi.Type {
+	t, _ := abi.NewType(name, "", nil)
+	return t
+}
@@ -0,0 +1,142 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTxBuilder(t *testing.T) {
+	weth, usdc, dai := common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	graph.addPool(usdc, dai, 1000000000, 1100000000)
+	router := newFakeRouter(graph)
+	builder := NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil)
+	now := time.Unix(1700000000, 0)
+	builder.now = func() time.Time { return now }
+	recipient := common.HexToAddress("0x1234")
+
+	quote, err := router.Route(context.Background(), big.NewInt(1000), weth, dai, 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	tx, err := builder.Build(quote, SwapOptions{Recipient: recipient})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	swaps, err := DecodeSwapCalldata(tx.Data, tx.Value)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	want := DecodedSwap{
+		Method:       "swapExactTokensForTokens",
+		Path:         []common.Address{weth, usdc, dai},
+		AmountIn:     quote.AmountIn,
+		AmountOutMin: quote.AmountOutMin,
+		Recipient:    recipient,
+		Deadline:     big.NewInt(now.Add(DefaultSwapDeadline).Unix()),
+	}
+	if tx.To != common.HexToAddress(ROUTER02_ADDRESS) || tx.Value.Sign() != 0 || !reflect.DeepEqual(swaps, []DecodedSwap{want}) {
+		t.Errorf("got tx to %v value %v swaps %+v want %+v", tx.To, tx.Value, swaps, want)
+	}
+
+	deadline := now.Add(time.Minute)
+	tx, err = builder.Build(quote, SwapOptions{Recipient: recipient, NativeIn: true, Deadline: deadline})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	swaps, _ = DecodeSwapCalldata(tx.Data, tx.Value)
+	if tx.Method != "swapExactETHForTokens" || tx.Value.Cmp(quote.AmountIn) != 0 || swaps[0].AmountIn.Cmp(quote.AmountIn) != 0 || swaps[0].Deadline.Int64() != deadline.Unix() {
+		t.Errorf("got %v paying %v with swap %+v want the quote's input in ETH", tx.Method, tx.Value, swaps[0])
+	}
+
+	quote, err = router.RouteExactOut(context.Background(), dai, weth, big.NewInt(100), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	tx, err = builder.Build(quote, SwapOptions{Recipient: recipient, NativeOut: true})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	swaps, _ = DecodeSwapCalldata(tx.Data, tx.Value)
+	if tx.Method != "swapTokensForExactETH" || !swaps[0].ExactOut || swaps[0].AmountOut.Int64() != 100 || swaps[0].AmountInMax.Cmp(quote.AmountInMax) != 0 {
+		t.Errorf("got %v with swap %+v want an exact-out swap bounded by the quote's max input", tx.Method, swaps[0])
+	}
+}
+
+func TestTxBuilderRejects(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	recipient := common.HexToAddress("0x1234")
+	quote := &Quote{
+		TokenIn:      weth,
+		TokenOut:     usdc,
+		AmountIn:     big.NewInt(1000),
+		AmountOut:    big.NewInt(1990),
+		AmountOutMin: big.NewInt(1980),
+		AmountInMax:  big.NewInt(1000),
+		Hops:         []RouteHop{{TokenIn: weth, TokenOut: usdc, DEX: "uniswap-v2"}},
+	}
+	builder := NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil)
+
+	tests := map[string]struct {
+		quote   *Quote
+		options SwapOptions
+	}{
+		"no recipient":    {quote: quote, options: SwapOptions{}},
+		"ETH out of USDC": {quote: quote, options: SwapOptions{Recipient: recipient, NativeOut: true}},
+		"sushiswap hop":   {quote: &Quote{TokenIn: weth, TokenOut: usdc, AmountIn: quote.AmountIn, AmountOut: quote.AmountOut, AmountOutMin: quote.AmountOutMin, AmountInMax: quote.AmountInMax, Hops: []RouteHop{{TokenIn: weth, TokenOut: usdc, DEX: "sushiswap"}}}, options: SwapOptions{Recipient: recipient}},
+		"no slippage":     {quote: &Quote{TokenIn: weth, TokenOut: usdc, AmountIn: quote.AmountIn, AmountOut: quote.AmountOut, Hops: quote.Hops}, options: SwapOptions{Recipient: recipient}},
+		"ETH in, ETH out": {quote: &Quote{TokenIn: weth, TokenOut: weth, AmountIn: quote.AmountIn, AmountOut: quote.AmountOut, AmountOutMin: quote.AmountOutMin, AmountInMax: quote.AmountInMax, Hops: quote.Hops}, options: SwapOptions{Recipient: recipient, NativeIn: true, NativeOut: true}},
+	}
+	for name, test := range tests {
+		if _, err := builder.Build(test.quote, test.options); err == nil {
+			t.Errorf("%v: got no error", name)
+		}
+	}
+}
+
+func TestTxBuilderUniversalRouter(t *testing.T) {
+	weth, usdc := common.HexToAddress(WETH), common.HexToAddress(USDC)
+	graph := &v2GraphFake{}
+	graph.addPool(weth, usdc, 1000000, 2000000000)
+	router := newFakeRouter(graph)
+	builder := NewTxBuilder(common.HexToAddress(ROUTER02_ADDRESS), nil)
+	recipient := common.HexToAddress("0x1234")
+
+	quote, err := router.RouteExactOut(context.Background(), usdc, weth, big.NewInt(100), 1)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	permit := &Permit2Permit{Token: usdc, Amount: quote.AmountInMax, Expiration: time.Unix(1700000000, 0), Nonce: big.NewInt(3), Spender: common.HexToAddress(UNIVERSAL_ROUTER_ADDRESS), SigDeadline: time.Unix(1700000000, 0), Signature: make([]byte, 65)}
+	tx, err := builder.BuildUniversalRouter(quote, SwapOptions{Recipient: recipient, NativeOut: true}, permit)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	args, err := universalRouterParsed.Methods["execute"].Inputs.Unpack(tx.Data[4:])
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if commands := args[0].([]byte); !reflect.DeepEqual(commands, []byte{urPermit2Permit, urV2SwapExactOut, urUnwrapWETH}) {
+		t.Errorf("got commands %x want permit, swap and unwrap", commands)
+	}
+	swaps, err := DecodeSwapCalldata(tx.Data, tx.Value)
+	if err != nil || len(swaps) != 1 {
+		t.Fatalf("got swaps %+v error %v", swaps, err)
+	}
+	if tx.To != common.HexToAddress(UNIVERSAL_ROUTER_ADDRESS) || swaps[0].Recipient != universalRouterSelf || swaps[0].AmountOut.Int64() != 100 || swaps[0].AmountInMax.Cmp(quote.AmountInMax) != 0 {
+		t.Errorf("got tx to %v swap %+v want the WETH bought to the router", tx.To, swaps[0])
+	}
+
+	quote.AmountInMax = new(big.Int).Add(quote.AmountInMax, big.NewInt(1))
+	if _, err := builder.BuildUniversalRouter(quote, SwapOptions{Recipient: recipient}, permit); err == nil {
+		t.Error("got no error for a permit below the swap's input")
+	}
+	if _, err := builder.BuildUniversalRouter(quote, SwapOptions{Recipient: recipient, NativeIn: true}, nil); err == nil {
+		t.Error("got no error paying ETH through Permit2")
+	}
+}
@@ -0,0 +1,38 @@
+package routing
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// uniswapAppChains are the chain names app.uniswap.org accepts in its chain parameter
+var uniswapAppChains = map[int64]string{
+	1:     "mainnet",
+	10:    "optimism",
+	56:    "bnb",
+	137:   "polygon",
+	8453:  "base",
+	42161: "arbitrum",
+}
+
+// UniswapAppURL returns an app.uniswap.org link prefilled with the trade so it can be executed by
+// hand. The app routes the trade itself, the link only carries the tokens, amount and chain.
+func UniswapAppURL(chainID int64, tokenIn, tokenOut common.Address, amountIn *big.Int, decimalsIn uint8) (string, error) {
+	chain, ok := uniswapAppChains[chainID]
+	if !ok {
+		return "", fmt.Errorf("chain %v is not supported by the Uniswap app", chainID)
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return "", fmt.Errorf("amountIn must be positive")
+	}
+	query := url.Values{}
+	query.Set("chain", chain)
+	query.Set("inputCurrency", tokenIn.Hex())
+	query.Set("outputCurrency", tokenOut.Hex())
+	query.Set("exactField", "input")
+	query.Set("exactAmount", FormatUnits(amountIn, decimalsIn))
+	return "https://app.uniswap.org/swap?" + query.Encode(), nil
+}
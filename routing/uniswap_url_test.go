@@ -0,0 +1,34 @@
+package routing
+
+import (
+	"math/big"
+	"net/url"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestUniswapAppURL(t *testing.T) {
+	link, err := UniswapAppURL(1, common.HexToAddress(USDC), common.HexToAddress(WETH), big.NewInt(1500000), 6)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	query := parsed.Query()
+	if parsed.Host != "app.uniswap.org" || parsed.Path != "/swap" {
+		t.Errorf("got link %v want an app.uniswap.org swap link", link)
+	}
+	if query.Get("chain") != "mainnet" || query.Get("exactAmount") != "1.5" || query.Get("exactField") != "input" {
+		t.Errorf("got query %v want 1.5 input on mainnet", query)
+	}
+	if common.HexToAddress(query.Get("inputCurrency")) != common.HexToAddress(USDC) || common.HexToAddress(query.Get("outputCurrency")) != common.HexToAddress(WETH) {
+		t.Errorf("got query %v want USDC -> WETH", query)
+	}
+
+	if _, err := UniswapAppURL(31337, common.HexToAddress(USDC), common.HexToAddress(WETH), big.NewInt(1), 6); err == nil {
+		t.Error("expected an error for an unsupported chain")
+	}
+}
@@ -0,0 +1,65 @@
+package routing
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ParseUnits converts an amount in token units, e.g. "1.5", to the token's smallest unit. Amounts
+// with more fractional digits than the token has decimals are rejected rather than rounded.
+func ParseUnits(amount string, decimals uint8) (*big.Int, error) {
+	amount = strings.TrimSpace(amount)
+	whole, fraction, _ := strings.Cut(amount, ".")
+	if whole+fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return nil, fmt.Errorf("invalid amount %q, want a decimal number like 1.5", amount)
+	}
+	if len(fraction) > int(decimals) {
+		return nil, fmt.Errorf("amount %q has more than the token's %v decimals", amount, decimals)
+	}
+	units, _ := new(big.Int).SetString(whole+fraction+strings.Repeat("0", int(decimals)-len(fraction)), 10)
+	return units, nil
+}
+
+// ScaleAmount converts an amount in the smallest unit of a token with fromDecimals to one with
+// toDecimals, in either direction. Scaling down truncates like Solidity's integer division, amounts
+// scaled up scale back exactly.
+func ScaleAmount(amount *big.Int, fromDecimals, toDecimals uint8) *big.Int {
+	switch {
+	case toDecimals > fromDecimals:
+		return new(big.Int).Mul(amount, decimalsScale(toDecimals-fromDecimals))
+	case toDecimals < fromDecimals:
+		return new(big.Int).Quo(amount, decimalsScale(fromDecimals-toDecimals))
+	}
+	return new(big.Int).Set(amount)
+}
+
+// decimalsScale is 10^decimals
+func decimalsScale(decimals uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
+
+// tokenUnits converts an amount in the token's smallest unit to whole tokens, exactly
+func tokenUnits(amount *big.Int, decimals uint8) *big.Rat {
+	return new(big.Rat).SetFrac(amount, decimalsScale(decimals))
+}
+
+// unitPrice is amountOut per amountIn in whole tokens. Both amounts are scaled up to the larger of
+// the decimals, so the price is exact for tokens with any decimals.
+func unitPrice(amountIn *big.Int, decimalsIn uint8, amountOut *big.Int, decimalsOut uint8) *big.Rat {
+	decimals := decimalsIn
+	if decimalsOut > decimals {
+		decimals = decimalsOut
+	}
+	return new(big.Rat).SetFrac(ScaleAmount(amountOut, decimalsOut, decimals), ScaleAmount(amountIn, decimalsIn, decimals))
+}
+
+// FormatUnits converts an amount in a token's smallest unit to token units without trailing
+// zeros, e.g. 1500000000000000000 with 18 decimals is "1.5"
+func FormatUnits(amount *big.Int, decimals uint8) string {
+	formatted := tokenUnits(amount, decimals).FloatString(int(decimals))
+	if strings.Contains(formatted, ".") {
+		formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+	return formatted
+}
@@ -0,0 +1,85 @@
+package routing
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFormatUnits(t *testing.T) {
+	cases := []struct {
+		amount   int64
+		decimals uint8
+		want     string
+	}{
+		{1500000, 6, "1.5"},
+		{1000000, 6, "1"},
+		{1, 18, "0.000000000000000001"},
+		{42, 0, "42"},
+	}
+	for _, c := range cases {
+		if got := FormatUnits(big.NewInt(c.amount), c.decimals); got != c.want {
+			t.Errorf("FormatUnits(%v, %v) = %v want %v", c.amount, c.decimals, got, c.want)
+		}
+	}
+}
+
+func TestScaleAmount(t *testing.T) {
+	cases := []struct {
+		amount   string
+		from, to uint8
+		want     string
+	}{
+		{"1", 6, 18, "1000000000000"},
+		{"1000000000000", 18, 6, "1"},
+		{"1999999999999", 18, 6, "1"},
+		{"999999", 24, 18, "0"},
+		{"123", 18, 18, "123"},
+		{"1", 0, 30, "1000000000000000000000000000000"},
+		{"1000000000000000000000000000000", 30, 0, "1"},
+	}
+	for _, c := range cases {
+		amount, _ := new(big.Int).SetString(c.amount, 10)
+		if got := ScaleAmount(amount, c.from, c.to); got.String() != c.want {
+			t.Errorf("ScaleAmount(%v, %v, %v) = %v want %v", c.amount, c.from, c.to, got, c.want)
+		}
+		if up := ScaleAmount(amount, c.from, c.from+12); ScaleAmount(up, c.from+12, c.from).Cmp(amount) != 0 {
+			t.Errorf("ScaleAmount(%v, %v, %v) does not scale back", c.amount, c.from, c.from+12)
+		}
+	}
+	// the result never aliases the argument
+	amount := big.NewInt(5)
+	ScaleAmount(amount, 6, 6).SetInt64(7)
+	if amount.Int64() != 5 {
+		t.Errorf("got %v want ScaleAmount to leave its argument alone", amount)
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	cases := []struct {
+		amount   string
+		decimals uint8
+		want     string
+	}{
+		{"1.5", 18, "1500000000000000000"},
+		{" 1.5 ", 6, "1500000"},
+		{"2500", 6, "2500000000"},
+		{".25", 2, "25"},
+		{"0.000000000000000001", 18, "1"},
+		{"42", 0, "42"},
+	}
+	for _, c := range cases {
+		got, err := ParseUnits(c.amount, c.decimals)
+		if err != nil || got.String() != c.want {
+			t.Errorf("ParseUnits(%q, %v) = %v, %v want %v", c.amount, c.decimals, got, err, c.want)
+			continue
+		}
+		if back, _ := ParseUnits(FormatUnits(got, c.decimals), c.decimals); back.Cmp(got) != 0 {
+			t.Errorf("FormatUnits(%v, %v) does not parse back", got, c.decimals)
+		}
+	}
+	for _, amount := range []string{"", ".", "-1", "1e18", "1,5", "0x10", "1.2345678"} {
+		if got, err := ParseUnits(amount, 6); err == nil {
+			t.Errorf("ParseUnits(%q, 6) = %v want an error", amount, got)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const v3FactoryABI = `[{"inputs":[{"name":"tokenA","type":"address"},{"name":"tokenB","type":"address"},{"name":"fee","type":"uint24"}],"name":"getPool","outputs":[{"name":"pool","type":"address"}],"stateMutability":"view","type":"function"}]`
+
+const v3QuoterV2ABI = `[{"inputs":[{"components":[{"name":"tokenIn","type":"address"},{"name":"tokenOut","type":"address"},{"name":"amountIn","type":"uint256"},{"name":"fee","type":"uint24"},{"name":"sqrtPriceLimitX96","type":"uint160"}],"name":"params","type":"tuple"}],"name":"quoteExactInputSingle","outputs":[{"name":"amountOut","type":"uint256"},{"name":"sqrtPriceX96After","type":"uint160"},{"name":"initializedTicksCrossed","type":"uint32"},{"name":"gasEstimate","type":"uint256"}],"stateMutability":"nonpayable","type":"function"}]`
+
+// V3FeeTiers are the fee tiers enabled on the Uniswap V3 factory, in hundredths of a bip
+var V3FeeTiers = []uint32{100, 500, 3000, 10000}
+
+type V3Pool struct {
+	Token0   common.Address
+	Token1   common.Address
+	Fee      uint32
+	Contract common.Address
+}
+
+type V3PoolProvider interface {
+	// returns every pool between tokenA and tokenB, one per fee tier that was created
+	GetV3Pools(ctx context.Context, tokenA, tokenB common.Address) ([]V3Pool, error)
+}
+
+type OnChainV3PoolProvider struct {
+	caller bind.ContractCaller
+	// zero means Mainnet's
+	factory common.Address
+}
+
+// NewOnChainV3PoolProvider looks up pools on Mainnet's V3 factory, see SetChain
+func NewOnChainV3PoolProvider(caller bind.ContractCaller) *OnChainV3PoolProvider {
+	return &OnChainV3PoolProvider{caller: caller}
+}
+
+// SetChain looks up pools on the V3 factory of chain
+func (p *OnChainV3PoolProvider) SetChain(chain Chain) {
+	p.factory = chain.V3Factory
+}
+
+func (p *OnChainV3PoolProvider) GetV3Pools(ctx context.Context, tokenA, tokenB common.Address) ([]V3Pool, error) {
+	factory, err := abi.JSON(strings.NewReader(v3FactoryABI))
+	if err != nil {
+		return nil, err
+	}
+	factoryAddress := p.factory
+	if factoryAddress == (common.Address{}) {
+		factoryAddress = Mainnet.V3Factory
+	}
+	pools := []V3Pool{}
+	for _, fee := range V3FeeTiers {
+		input, err := factory.Pack("getPool", tokenA, tokenB, new(big.Int).SetUint64(uint64(fee)))
+		if err != nil {
+			return nil, err
+		}
+		output, err := p.caller.CallContract(ctx, ethereum.CallMsg{To: &factoryAddress, Data: input}, BlockNumberFromContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		unpacked, err := factory.Unpack("getPool", output)
+		if err != nil {
+			return nil, err
+		}
+		pool := unpacked[0].(common.Address)
+		// the factory returns the zero address for tiers without a pool
+		if pool == (common.Address{}) {
+			continue
+		}
+		pools = append(pools, V3Pool{Token0: tokenA, Token1: tokenB, Fee: fee, Contract: pool})
+	}
+	return pools, nil
+}
+
+type V3QuoteProvider interface {
+	// returns the output of swapping amountIn through the tokenIn/tokenOut pool of the fee tier
+	QuoteExactInputSingle(ctx context.Context, tokenIn, tokenOut common.Address, fee uint32, amountIn *big.Int) (*big.Int, error)
+}
+
+// OnChainV3QuoteProvider simulates swaps with QuoterV2, which walks the pool's initialized ticks
+type OnChainV3QuoteProvider struct {
+	caller bind.ContractCaller
+	// zero means Mainnet's
+	quoter common.Address
+}
+
+// NewOnChainV3QuoteProvider quotes with Mainnet's QuoterV2, see SetChain
+func NewOnChainV3QuoteProvider(caller bind.ContractCaller) *OnChainV3QuoteProvider {
+	return &OnChainV3QuoteProvider{caller: caller}
+}
+
+// SetChain quotes with the QuoterV2 of chain
+func (p *OnChainV3QuoteProvider) SetChain(chain Chain) {
+	p.quoter = chain.V3QuoterV2
+}
+
+type v3QuoteExactInputSingleParams struct {
+	TokenIn           common.Address
+	TokenOut          common.Address
+	AmountIn          *big.Int
+	Fee               *big.Int
+	SqrtPriceLimitX96 *big.Int
+}
+
+func (p *OnChainV3QuoteProvider) QuoteExactInputSingle(ctx context.Context, tokenIn, tokenOut common.Address, fee uint32, amountIn *big.Int) (*big.Int, error) {
+	quoter, err := abi.JSON(strings.NewReader(v3QuoterV2ABI))
+	if err != nil {
+		return nil, err
+	}
+	input, err := quoter.Pack("quoteExactInputSingle", v3QuoteExactInputSingleParams{
+		TokenIn:  tokenIn,
+		TokenOut: tokenOut,
+		AmountIn: amountIn,
+		Fee:      new(big.Int).SetUint64(uint64(fee)),
+		// no price limit
+		SqrtPriceLimitX96: new(big.Int),
+	})
+	if err != nil {
+		return nil, err
+	}
+	quoterAddress := p.quoter
+	if quoterAddress == (common.Address{}) {
+		quoterAddress = Mainnet.V3QuoterV2
+	}
+	output, err := p.caller.CallContract(ctx, ethereum.CallMsg{To: &quoterAddress, Data: input}, BlockNumberFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	unpacked, err := quoter.Unpack("quoteExactInputSingle", output)
+	if err != nil {
+		return nil, err
+	}
+	return unpacked[0].(*big.Int), nil
+}
+
+// V3Hop is one swap of a V3 route
+type V3Hop struct {
+	TokenIn  common.Address `json:"tokenIn"`
+	TokenOut common.Address `json:"tokenOut"`
+	Fee      uint32         `json:"fee"`
+}
+
+type V3Route struct {
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	Hops      []V3Hop
+}
+
+// Path returns the tokens visited by the route, starting with tokenIn
+func (r *V3Route) Path() []common.Address {
+	if len(r.Hops) == 0 {
+		return []common.Address{}
+	}
+	path := []common.Address{r.Hops[0].TokenIn}
+	for _, hop := range r.Hops {
+		path = append(path, hop.TokenOut)
+	}
+	return path
+}
+
+// OnChainV3Router finds the best exact-in route through Uniswap V3 pools. V3 liquidity is
+// concentrated in ticks, so every hop is priced by the quoter instead of local math.
+type OnChainV3Router struct {
+	poolProvider      V3PoolProvider
+	quoteProvider     V3QuoteProvider
+	topTokensProvider TopTokensProvider
+	// optional, when set all reads of a route are pinned to the same block
+	blockNumberProvider BlockNumberProvider
+	// optional, defaults to the logger of the context
+	logger *slog.Logger
+}
+
+// NewOnChainV3Router routes through V3 pools between tokenIn, tokenOut and the top tokens,
+// blockNumberProvider may be nil
+func NewOnChainV3Router(poolProvider V3PoolProvider, quoteProvider V3QuoteProvider, topTokensProvider TopTokensProvider, blockNumberProvider BlockNumberProvider) *OnChainV3Router {
+	return &OnChainV3Router{
+		poolProvider:        poolProvider,
+		quoteProvider:       quoteProvider,
+		topTokensProvider:   topTokensProvider,
+		blockNumberProvider: blockNumberProvider,
+	}
+}
+
+// SetLogger logs the routes of the router to logger instead of the logger of their context
+func (r *OnChainV3Router) SetLogger(logger *slog.Logger) {
+	r.logger = logger
+}
+
+// v3Ref points at the token, layer and fee tier an amount was reached from
+type v3Ref struct {
+	token common.Address
+	hop   int
+	fee   uint32
+}
+
+func (r *OnChainV3Router) Route(ctx context.Context, amountIn *big.Int, tokenIn, tokenOut common.Address, maxHops int) (*V3Route, error) {
+	if tokenIn == tokenOut {
+		return nil, fmt.Errorf("%w: %v", ErrSameToken, tokenIn.String())
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if maxHops < 1 {
+		return nil, errors.New("maxHops must be at least 1")
+	}
+	if maxHops > maxSupportedHops {
+		return nil, fmt.Errorf("%w of %v", ErrMaxHopsExceeded, maxSupportedHops)
+	}
+	ctx = ensureRequestID(ctx)
+	if r.blockNumberProvider != nil && BlockNumberFromContext(ctx) == nil {
+		blockNumber, err := r.blockNumberProvider.BlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ctx = WithBlockNumber(ctx, new(big.Int).SetUint64(blockNumber))
+	}
+
+	tokens := []common.Address{tokenIn, tokenOut}
+	used := map[common.Address]bool{tokenIn: true, tokenOut: true}
+	topTokens, err := r.topTokensProvider.GetTopTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range topTokens {
+		if !used[token] {
+			tokens = append(tokens, token)
+			used[token] = true
+		}
+	}
+	sortAddresses(tokens)
+
+	// pools are discovered once per unordered pair
+	pools := make(map[pairKey][]V3Pool)
+	for i := range tokens {
+		for j := i + 1; j < len(tokens); j++ {
+			found, err := r.poolProvider.GetV3Pools(ctx, tokens[i], tokens[j])
+			if err != nil {
+				return nil, err
+			}
+			pools[newPairKey(tokens[i], tokens[j])] = found
+			pools[newPairKey(tokens[j], tokens[i])] = found
+		}
+	}
+
+	amounts := make([]map[common.Address]*big.Int, maxHops+1)
+	prev := make([]map[common.Address]v3Ref, maxHops+1)
+	amounts[0] = map[common.Address]*big.Int{tokenIn: new(big.Int).Set(amountIn)}
+	prev[0] = map[common.Address]v3Ref{}
+	bestHops := -1
+	for i := 1; i <= maxHops; i++ {
+		amounts[i] = make(map[common.Address]*big.Int)
+		prev[i] = make(map[common.Address]v3Ref)
+		// amounts reachable with fewer hops stay reachable
+		for token, amount := range amounts[i-1] {
+			amounts[i][token] = amount
+		}
+		for token, ref := range prev[i-1] {
+			prev[i][token] = ref
+		}
+		for _, from := range tokens {
+			fromAmount, ok := amounts[i-1][from]
+			if !ok {
+				continue
+			}
+			for _, to := range tokens {
+				if from == to {
+					continue
+				}
+				for _, pool := range pools[newPairKey(from, to)] {
+					if err := ctx.Err(); err != nil {
+						return nil, err
+					}
+					// the quoter reverts when the pool cannot fill the amount
+					candidate, err := r.quoteProvider.QuoteExactInputSingle(ctx, from, to, pool.Fee, fromAmount)
+					if err != nil || candidate.Sign() == 0 {
+						continue
+					}
+					if current, ok := amounts[i][to]; !ok || candidate.Cmp(current) > 0 {
+						amounts[i][to] = candidate
+						prev[i][to] = v3Ref{token: from, hop: i - 1, fee: pool.Fee}
+					}
+				}
+			}
+		}
+		logDebug(ctx, r.logger, "best v3 amount", "hops", i, "amount", amounts[i][tokenOut])
+		result, ok := amounts[i][tokenOut]
+		if !ok {
+			continue
+		}
+		// stop once another hop no longer improves the result
+		if previous, ok := amounts[i-1][tokenOut]; ok && result.Cmp(previous) <= 0 {
+			break
+		}
+		bestHops = i
+	}
+	if bestHops < 0 {
+		return nil, &NoRouteError{TokenIn: tokenIn, TokenOut: tokenOut, MaxHops: maxHops, Reasons: []NoRouteReason{TokensNotConnected}}
+	}
+
+	hops := []V3Hop{}
+	token, hop := tokenOut, bestHops
+	for {
+		ref, ok := prev[hop][token]
+		if !ok {
+			break
+		}
+		hops = append([]V3Hop{{TokenIn: ref.token, TokenOut: token, Fee: ref.fee}}, hops...)
+		token, hop = ref.token, ref.hop
+	}
+	return &V3Route{AmountIn: new(big.Int).Set(amountIn), AmountOut: amounts[bestHops][tokenOut], Hops: hops}, nil
+}
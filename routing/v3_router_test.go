@@ -0,0 +1,132 @@
+package routing
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// v3GraphFake prices every V3 pool as a constant product pool charging its fee tier
+type v3GraphFake struct {
+	reserves map[pairKey]map[uint32][2]int64
+}
+
+func (g *v3GraphFake) addPool(tokenA, tokenB common.Address, fee uint32, reserveA, reserveB int64) {
+	if g.reserves == nil {
+		g.reserves = make(map[pairKey]map[uint32][2]int64)
+	}
+	for _, key := range []pairKey{newPairKey(tokenA, tokenB), newPairKey(tokenB, tokenA)} {
+		if g.reserves[key] == nil {
+			g.reserves[key] = make(map[uint32][2]int64)
+		}
+	}
+	g.reserves[newPairKey(tokenA, tokenB)][fee] = [2]int64{reserveA, reserveB}
+	g.reserves[newPairKey(tokenB, tokenA)][fee] = [2]int64{reserveB, reserveA}
+}
+
+func (g *v3GraphFake) GetV3Pools(ctx context.Context, tokenA, tokenB common.Address) ([]V3Pool, error) {
+	pools := []V3Pool{}
+	for _, fee := range V3FeeTiers {
+		if _, ok := g.reserves[newPairKey(tokenA, tokenB)][fee]; ok {
+			pools = append(pools, V3Pool{Token0: tokenA, Token1: tokenB, Fee: fee, Contract: fakePairAddress(tokenA, tokenB)})
+		}
+	}
+	return pools, nil
+}
+
+func (g *v3GraphFake) QuoteExactInputSingle(ctx context.Context, tokenIn, tokenOut common.Address, fee uint32, amountIn *big.Int) (*big.Int, error) {
+	reserves := g.reserves[newPairKey(tokenIn, tokenOut)][fee]
+	amountInWithFee := new(big.Int).Mul(amountIn, big.NewInt(int64(1000000-fee)))
+	numerator := new(big.Int).Mul(amountInWithFee, big.NewInt(reserves[1]))
+	denominator := new(big.Int).Add(new(big.Int).Mul(big.NewInt(reserves[0]), big.NewInt(1000000)), amountInWithFee)
+	return numerator.Quo(numerator, denominator), nil
+}
+
+type staticTokensProvider []common.Address
+
+func (p staticTokensProvider) GetTopTokens(ctx context.Context) ([]common.Address, error) {
+	return p, nil
+}
+
+func TestV3RoutePicksFeeTiers(t *testing.T) {
+	graph := &v3GraphFake{}
+	// the 0.05% tier is cheaper but too shallow for the trade, the 0.3% tier wins
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 500, 1000, 2000000)
+	graph.addPool(common.HexToAddress(WETH), common.HexToAddress(USDC), 3000, 1000000, 2000000000)
+	// the stable pair only has a deep 0.01% pool
+	graph.addPool(common.HexToAddress(USDC), common.HexToAddress(DAI), 100, 1000000000, 1000000000)
+	router := &OnChainV3Router{
+		poolProvider:      graph,
+		quoteProvider:     graph,
+		topTokensProvider: staticTokensProvider{common.HexToAddress(WETH), common.HexToAddress(USDC), common.HexToAddress(DAI)},
+	}
+
+	route, err := router.Route(context.Background(), big.NewInt(1000), common.HexToAddress(WETH), common.HexToAddress(DAI), 3)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	wantHops := []V3Hop{
+		{TokenIn: common.HexToAddress(WETH), TokenOut: common.HexToAddress(USDC), Fee: 3000},
+		{TokenIn: common.HexToAddress(USDC), TokenOut: common.HexToAddress(DAI), Fee: 100},
+	}
+	if len(route.Hops) != len(wantHops) {
+		t.Fatalf("got hops %+v want %+v", route.Hops, wantHops)
+	}
+	for i := range wantHops {
+		if route.Hops[i] != wantHops[i] {
+			t.Errorf("got hops %+v want %+v", route.Hops, wantHops)
+		}
+	}
+	if path := route.Path(); len(path) != 3 || path[0] != common.HexToAddress(WETH) || path[2] != common.HexToAddress(DAI) {
+		t.Errorf("got path %v", path)
+	}
+	usdc, _ := graph.QuoteExactInputSingle(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDC), 3000, big.NewInt(1000))
+	wantOut, _ := graph.QuoteExactInputSingle(context.Background(), common.HexToAddress(USDC), common.HexToAddress(DAI), 100, usdc)
+	if route.AmountOut.Cmp(wantOut) != 0 {
+		t.Errorf("got %v want %v", route.AmountOut, wantOut)
+	}
+}
+
+// quoterFake checks the QuoterV2 call encoding and answers with a fixed amount
+type quoterFake struct {
+	t      *testing.T
+	params v3QuoteExactInputSingleParams
+}
+
+func (q *quoterFake) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (q *quoterFake) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if *call.To != common.HexToAddress(UNISWAP_V3_QUOTER_V2_ADDRESS) {
+		q.t.Errorf("got call to %v want the quoter", call.To)
+	}
+	quoter, _ := abi.JSON(strings.NewReader(v3QuoterV2ABI))
+	method := quoter.Methods["quoteExactInputSingle"]
+	inputs, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	q.params = *abi.ConvertType(inputs[0], new(v3QuoteExactInputSingleParams)).(*v3QuoteExactInputSingleParams)
+	return method.Outputs.Pack(big.NewInt(1993), new(big.Int), uint32(1), big.NewInt(90000))
+}
+
+func TestOnChainV3QuoteProvider(t *testing.T) {
+	caller := &quoterFake{t: t}
+	provider := &OnChainV3QuoteProvider{caller: caller}
+	amountOut, err := provider.QuoteExactInputSingle(context.Background(), common.HexToAddress(WETH), common.HexToAddress(USDC), 500, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if amountOut.Cmp(big.NewInt(1993)) != 0 {
+		t.Errorf("got %v want 1993", amountOut)
+	}
+	if caller.params.TokenIn != common.HexToAddress(WETH) || caller.params.TokenOut != common.HexToAddress(USDC) || caller.params.Fee.Uint64() != 500 || caller.params.AmountIn.Int64() != 1 {
+		t.Errorf("got params %+v", caller.params)
+	}
+}
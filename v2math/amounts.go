@@ -0,0 +1,58 @@
+// Package v2math is the constant product math and route search of the router on plain data. It
+// depends on the standard library only, so route optimization can run offline on any reserves.
+package v2math
+
+import (
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrInsufficientInputAmount  = errors.New("insufficient input amount")
+	ErrInsufficientOutputAmount = errors.New("insufficient output amount")
+	ErrInsufficientReserves     = errors.New("insufficient reserves")
+)
+
+// Fee is the share of the input a pool lets through to the swap, 997/1000 for Uniswap V2
+type Fee struct {
+	Numerator   *big.Int
+	Denominator *big.Int
+}
+
+// UniswapV2Fee is the 0.3% fee of Uniswap V2 and most of its forks
+var UniswapV2Fee = Fee{Numerator: big.NewInt(997), Denominator: big.NewInt(1000)}
+
+// FeeFromBips returns the Fee of a pool charging bips basis points, e.g. 25 for PancakeSwap V2
+func FeeFromBips(bips int64) Fee {
+	return Fee{Numerator: big.NewInt(10000 - bips), Denominator: big.NewInt(10000)}
+}
+
+// GetAmountOut mirrors UniswapV2Library.getAmountOut, the division truncates so the result is
+// never more than the pair would actually pay
+func GetAmountOut(amountIn, reserveIn, reserveOut *big.Int, fee Fee) (*big.Int, error) {
+	if amountIn.Sign() <= 0 {
+		return nil, ErrInsufficientInputAmount
+	}
+	if reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 {
+		return nil, ErrInsufficientReserves
+	}
+	amountInWithFee := new(big.Int).Mul(amountIn, fee.Numerator)
+	numerator := new(big.Int).Mul(amountInWithFee, reserveOut)
+	denominator := new(big.Int).Add(new(big.Int).Mul(reserveIn, fee.Denominator), amountInWithFee)
+	return numerator.Quo(numerator, denominator), nil
+}
+
+// GetAmountIn mirrors UniswapV2Library.getAmountIn, the result is rounded up so paying it always
+// yields at least amountOut
+func GetAmountIn(amountOut, reserveIn, reserveOut *big.Int, fee Fee) (*big.Int, error) {
+	if amountOut.Sign() <= 0 {
+		return nil, ErrInsufficientOutputAmount
+	}
+	if reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 || amountOut.Cmp(reserveOut) >= 0 {
+		return nil, ErrInsufficientReserves
+	}
+	numerator := new(big.Int).Mul(new(big.Int).Mul(reserveIn, amountOut), fee.Denominator)
+	denominator := new(big.Int).Mul(new(big.Int).Sub(reserveOut, amountOut), fee.Numerator)
+	amountIn := numerator.Quo(numerator, denominator)
+	return amountIn.Add(amountIn, big.NewInt(1)), nil
+}
@@ -0,0 +1,106 @@
+package v2math
+
+import (
+	"errors"
+	"math/big"
+)
+
+// ErrNoRoute is returned when tokenOut cannot be reached from tokenIn within maxHops
+var ErrNoRoute = errors.New("no route")
+
+// Pool is a constant product pool between TokenA and TokenB. T identifies tokens, e.g. an
+// address string or a [20]byte.
+type Pool[T comparable] struct {
+	TokenA   T
+	TokenB   T
+	ReserveA *big.Int
+	ReserveB *big.Int
+	Fee      Fee
+}
+
+// Hop is one swap of a route, Pool indexes the pools passed to BestRoute
+type Hop[T comparable] struct {
+	TokenIn   T
+	TokenOut  T
+	Pool      int
+	AmountOut *big.Int
+}
+
+// BestRoute returns the swaps turning amountIn of tokenIn into the most tokenOut using at most
+// maxHops pools. amounts[i][t] is the best amount of t within i swaps, every round extends the
+// previous one by a swap through every pool in both directions, and the search stops once more
+// swaps no longer help. It is the router's pathfinder.HopBounded search without its dependencies,
+// the routing package's tests check both find the same routes.
+func BestRoute[T comparable](pools []Pool[T], tokenIn, tokenOut T, amountIn *big.Int, maxHops int) (*big.Int, []Hop[T], error) {
+	if tokenIn == tokenOut {
+		return nil, nil, errors.New("tokenIn and tokenOut cannot be the same")
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, nil, ErrInsufficientInputAmount
+	}
+	type ref struct {
+		token T
+		pool  int
+	}
+	amounts := []map[T]*big.Int{{tokenIn: new(big.Int).Set(amountIn)}}
+	prev := []map[T]ref{{}}
+	bestHops := 0
+	for i := 1; i <= maxHops; i++ {
+		current := make(map[T]*big.Int)
+		refs := make(map[T]ref)
+		for token, amount := range amounts[i-1] {
+			current[token], refs[token] = amount, ref{token: token, pool: -1}
+		}
+		for p, pool := range pools {
+			sides := [2]struct {
+				in, out               T
+				reserveIn, reserveOut *big.Int
+			}{
+				{pool.TokenA, pool.TokenB, pool.ReserveA, pool.ReserveB},
+				{pool.TokenB, pool.TokenA, pool.ReserveB, pool.ReserveA},
+			}
+			for _, side := range sides {
+				amount, ok := amounts[i-1][side.in]
+				if !ok {
+					continue
+				}
+				out, err := GetAmountOut(amount, side.reserveIn, side.reserveOut, pool.Fee)
+				if err != nil || out.Sign() == 0 {
+					continue
+				}
+				if best, ok := current[side.out]; !ok || out.Cmp(best) > 0 {
+					current[side.out], refs[side.out] = out, ref{token: side.in, pool: p}
+				}
+			}
+		}
+		amounts, prev = append(amounts, current), append(prev, refs)
+		improved := false
+		for token, r := range refs {
+			if r.pool >= 0 {
+				improved = true
+				if token == tokenOut {
+					bestHops = i
+				}
+			}
+		}
+		// nothing got cheaper to reach so no longer route can either
+		if !improved {
+			break
+		}
+	}
+	if bestHops == 0 {
+		return nil, nil, ErrNoRoute
+	}
+
+	hops := []Hop[T]{}
+	token := tokenOut
+	for i := bestHops; i > 0; i-- {
+		r := prev[i][token]
+		if r.pool < 0 {
+			continue
+		}
+		hops = append([]Hop[T]{{TokenIn: r.token, TokenOut: token, Pool: r.pool, AmountOut: amounts[i][token]}}, hops...)
+		token = r.token
+	}
+	return new(big.Int).Set(amounts[bestHops][tokenOut]), hops, nil
+}
@@ -0,0 +1,67 @@
+package v2math
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func amount(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func TestGetAmountOutMatchesUniswapV2(t *testing.T) {
+	// 1 WETH into a 1000 WETH / 2,000,000 USDC pair
+	amountOut, err := GetAmountOut(amount("1000000000000000000"), amount("1000000000000000000000"), amount("2000000000000"), UniswapV2Fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amountOut.Cmp(amount("1992013962")) != 0 {
+		t.Fatalf("expected 1992013962, got %v", amountOut)
+	}
+	amountIn, err := GetAmountIn(amountOut, amount("1000000000000000000000"), amount("2000000000000"), UniswapV2Fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amountIn.Cmp(amount("1000000000000000000")) > 0 {
+		t.Fatalf("expected at most 1 WETH in, got %v", amountIn)
+	}
+	if _, err := GetAmountIn(amount("2000000000000"), amount("1"), amount("2000000000000"), UniswapV2Fee); !errors.Is(err, ErrInsufficientReserves) {
+		t.Fatalf("expected ErrInsufficientReserves, got %v", err)
+	}
+}
+
+func TestBestRoutePrefersDeeperMultiHop(t *testing.T) {
+	pools := []Pool[string]{
+		// thin direct pool
+		{TokenA: "A", TokenB: "C", ReserveA: amount("1000"), ReserveB: amount("1000"), Fee: UniswapV2Fee},
+		{TokenA: "A", TokenB: "B", ReserveA: amount("1000000"), ReserveB: amount("1000000"), Fee: UniswapV2Fee},
+		{TokenA: "C", TokenB: "B", ReserveA: amount("1000000"), ReserveB: amount("1000000"), Fee: FeeFromBips(25)},
+	}
+	amountOut, hops, err := BestRoute(pools, "A", "C", amount("1000"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hops) != 2 || hops[0].Pool != 1 || hops[1].Pool != 2 || hops[0].TokenOut != "B" {
+		t.Fatalf("expected A -> B -> C, got %+v", hops)
+	}
+	if hops[1].AmountOut.Cmp(amountOut) != 0 {
+		t.Fatalf("last hop pays %v, route pays %v", hops[1].AmountOut, amountOut)
+	}
+
+	direct, _, err := BestRoute(pools, "A", "C", amount("1000"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direct.Cmp(amountOut) >= 0 {
+		t.Fatalf("expected the thin direct pool to pay less, got %v vs %v", direct, amountOut)
+	}
+}
+
+func TestBestRouteNoRoute(t *testing.T) {
+	pools := []Pool[string]{{TokenA: "A", TokenB: "B", ReserveA: amount("1000"), ReserveB: amount("1000"), Fee: UniswapV2Fee}}
+	if _, _, err := BestRoute(pools, "A", "C", amount("10"), 3); !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("expected ErrNoRoute, got %v", err)
+	}
+}
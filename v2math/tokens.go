@@ -0,0 +1,13 @@
+package v2math
+
+import "bytes"
+
+// SortTokens returns a and b as the token0 and token1 of their V2 pair: the lower address first.
+// Comparing the bytes is comparing the addresses as numbers, or as lowercase hex, but not as
+// checksummed hex, whose mixed case orders differently.
+func SortTokens[T ~[20]byte](a, b T) (T, T) {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		return b, a
+	}
+	return a, b
+}
@@ -0,0 +1,47 @@
+package v2math
+
+import (
+	"encoding/hex"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func randomToken(rng *rand.Rand) [20]byte {
+	var token [20]byte
+	rng.Read(token[:])
+	// shared prefixes make the later bytes decide the order
+	if rng.Intn(2) == 0 {
+		token[0] = 0
+	}
+	return token
+}
+
+func TestSortTokens(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		a, b := randomToken(rng), randomToken(rng)
+		token0, token1 := SortTokens(a, b)
+		if !(token0 == a && token1 == b || token0 == b && token1 == a) {
+			t.Fatalf("got %x/%x for %x/%x want the same tokens", token0, token1, a, b)
+		}
+		if swapped0, swapped1 := SortTokens(b, a); swapped0 != token0 || swapped1 != token1 {
+			t.Fatalf("got %x/%x and %x/%x for both orders of the same tokens", token0, token1, swapped0, swapped1)
+		}
+		if new(big.Int).SetBytes(token0[:]).Cmp(new(big.Int).SetBytes(token1[:])) > 0 {
+			t.Fatalf("got %x before %x want the numerically lower address first", token0, token1)
+		}
+		if strings.Compare(hex.EncodeToString(token0[:]), hex.EncodeToString(token1[:])) > 0 {
+			t.Fatalf("got %x before %x want the lexicographically lower address first", token0, token1)
+		}
+	}
+
+	// USDC is token0 of the mainnet USDC/WETH pair
+	var usdc, weth [20]byte
+	hex.Decode(usdc[:], []byte("a0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"))
+	hex.Decode(weth[:], []byte("c02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"))
+	if token0, _ := SortTokens(weth, usdc); token0 != usdc {
+		t.Errorf("got token0 %x want USDC", token0)
+	}
+}